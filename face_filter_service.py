@@ -1,9 +1,17 @@
+import argparse
+import base64
+import dlib
+import hashlib
 import face_recognition
 import numpy as np
 import os
 import json
 import time
 import requests
+import uuid
+from collections import OrderedDict
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from io import BytesIO
 from watchdog.observers import Observer
 from watchdog.events import FileSystemEventHandler
 from typing import Optional, List, Dict, Any
@@ -12,9 +20,18 @@ from datetime import datetime
 import humanize  # For human readable file sizes
 
 class Config:
-    def __init__(self, config_file="config.json"):
+    def __init__(self, config_file="config.json", dry_run=False, pipeline=None):
         self.config_file = config_file
         self.config = self.load_config()
+        self.dry_run = dry_run or self.config.get("dry_run", False)
+        # A named pipeline has its own photo queue and destinations, and face detection settings over the top-level ones
+        self.pipeline = pipeline
+        self.face_detection = dict(self.config.get("face_detection", {}))
+        if pipeline:
+            pipelines = self.config.get("pipelines") or {}
+            if pipeline not in pipelines:
+                raise ValueError(f"Pipeline {pipeline} is not in {config_file}, configured are: {', '.join(sorted(pipelines)) or 'none'}")
+            self.face_detection.update(pipelines[pipeline].get("face_detection") or {})
         
         # Create debug directory if debug mode is enabled
         if self.get_debug_mode():
@@ -37,28 +54,81 @@ class Config:
         return self.config["media"]["allowed_extensions"]
 
     def get_media_store_path(self):
-        return self.config["media"]["store_path"]
+        return self.config["media"].get("store_path", "whatsapp-bridge/store/media")
+
+    def get_queue_dir(self):
+        """Where the bridge puts new photos of this pipeline"""
+        if self.pipeline:
+            return os.path.join(self.get_media_store_path(), "pipelines", self.pipeline)
+        return self.get_media_store_path()
+
+    def get_api_port(self):
+        return os.environ.get("JMK_API_PORT", self.config.get("api_port", 8080))
 
     def get_known_faces_dir(self):
-        return self.config["face_detection"]["known_faces_dir"]
+        return self.face_detection["known_faces_dir"]
 
     def get_confidence_threshold(self):
-        return self.config["face_detection"]["confidence_threshold"]
+        return self.face_detection["confidence_threshold"]
     
     def get_face_detection_model(self):
-        return self.config["face_detection"].get("model", "hog")
+        return self.face_detection.get("model", "hog")
 
     def get_min_matching_faces(self):
-        return self.config["face_detection"].get("min_matching_faces", 2)
+        return self.face_detection.get("min_matching_faces", 2)
+
+    def get_cluster_min_size(self):
+        return self.face_detection.get("cluster_min_size", 3)
+
+    def get_auto_tune(self) -> bool:
+        return self.face_detection.get("auto_tune", True)
+
+    def get_inference(self) -> Dict[str, Any]:
+        """Settings of the remote inference server faces are detected on, empty to detect them locally"""
+        return self.face_detection.get("inference", {})
+
+    def is_dry_run(self) -> bool:
+        return self.dry_run
+
+    def get_destinations(self) -> Dict[str, Any]:
+        if self.pipeline:
+            return self.config["pipelines"][self.pipeline].get("destinations") or {}
+        return self.config.get("destinations") or {}
 
     def get_destination_info(self, kid_name):
-        return self.config["destinations"].get(kid_name)
+        return self.get_destinations().get(kid_name)
+
+    def matches_person(self, name: str) -> bool:
+        """Whether faces of this person are matched here: a named pipeline matches its own destinations,
+        the top-level one everyone but the destinations of named pipelines"""
+        if self.pipeline:
+            return name in self.get_destinations()
+        return not any(name in (p.get("destinations") or {}) for p in (self.config.get("pipelines") or {}).values())
 
 def main():
-    config = Config()
+    parser = argparse.ArgumentParser(description="Forward photos of your kids from WhatsApp groups")
+    parser.add_argument("--dry-run", action="store_true",
+                        help="Match faces and log what would be forwarded, without sending anything")
+    parser.add_argument("--cluster", action="store_true",
+                        help="Group the faces of all stored photos into clusters for labelling in the bridge, then exit")
+    parser.add_argument("--serve-inference", type=int, metavar="PORT",
+                        help="Detect faces for other face filter services on this port, e.g. on a machine with a GPU")
+    parser.add_argument("--pipeline", metavar="NAME",
+                        help="Match the photos of this pipeline from config.json instead of the top-level input groups")
+    args = parser.parse_args()
+
+    config = Config(dry_run=args.dry_run, pipeline=args.pipeline)
+    if args.serve_inference:
+        serve_inference(config, args.serve_inference)
+        return
+    if args.cluster:
+        cluster_archive(config)
+        return
+    if config.is_dry_run():
+        print("[DRY-RUN] Matches are recorded by the bridge but not sent (see /api/forwards?dry_run=true)")
     
-    # Create and clean media directory
-    media_dir = config.get_media_store_path()
+    # Create and clean the photo queue
+    media_dir = config.get_queue_dir()
     os.makedirs(media_dir, exist_ok=True)
     
     print("\nCleaning up media directory...")
@@ -79,25 +149,158 @@ def main():
     observer.schedule(event_handler, media_dir, recursive=False)
     observer.start()
 
-    print(f"\nWatching for new images in {media_dir}...")
+    pipeline = f" of pipeline {config.pipeline}" if config.pipeline else ""
+    print(f"\nWatching for new images{pipeline} in {media_dir}...")
     
     try:
+        last_labels_check = time.time()
         while True:
             time.sleep(1)
+            # Pick up reference photos uploaded and clusters labelled in the bridge since
+            if time.time() - last_labels_check >= BRIDGE_FACES_REFRESH_SECONDS:
+                event_handler.face_filter.refresh_bridge_faces()
+                last_labels_check = time.time()
     except KeyboardInterrupt:
         observer.stop()
     observer.join()
 
+# How often reference photos and the faces of labelled clusters are fetched from the bridge again
+BRIDGE_FACES_REFRESH_SECONDS = 60
+
+def api_headers() -> Dict[str, str]:
+    """Headers for requests to the bridge API"""
+    api_key = os.environ.get("WHATSAPP_API_KEY", "")
+    return {"X-API-Key": api_key} if api_key else {}
+
+def cluster_archive(config):
+    """Find the faces in every stored photo, group similar ones and upload the groups to the bridge,
+    where parents label them with a destination"""
+    media_dir = config.get_media_store_path()
+    blob_dir = os.path.join(media_dir, "sha256")
+    face_cv = make_face_cv(config)
+
+    # Encodings are cached by file name: stored photos are named after their content, so they never change
+    cache_path = os.path.join(os.path.dirname(os.path.normpath(media_dir)), "face_encodings.json")
+    cache = {}
+    if os.path.exists(cache_path):
+        with open(cache_path, 'r') as f:
+            cache = json.load(f)
+
+    def save_cache():
+        with open(cache_path + ".tmp", 'w') as f:
+            json.dump(cache, f)
+        os.replace(cache_path + ".tmp", cache_path)
+
+    paths = []
+    for root, _, filenames in os.walk(blob_dir):
+        for filename in filenames:
+            if filename.lower().endswith(tuple(config.get_allowed_extensions())):
+                paths.append(os.path.join(root, filename))
+    paths.sort()
+    print(f"Clustering faces in {len(paths)} stored photos...")
+
+    uncached = [path for path in paths if os.path.basename(path) not in cache]
+    new = 0
+    for start in range(0, len(uncached), face_cv.batch_size):
+        batch = uncached[start:start + face_cv.batch_size]
+        try:
+            results = face_cv.process_images(batch)
+        except Exception as e:
+            print(f"  Error processing {', '.join(os.path.basename(p) for p in batch)}: {e}")
+            continue
+        for path, found in zip(batch, results):
+            cache[os.path.basename(path)] = [{'box': list(f['location']), 'encoding': f['encoding'].tolist()} for f in found]
+        previous, new = new, new + len(batch)
+        if new // 50 > previous // 50:
+            print(f"  Processed {new}/{len(uncached)} new photos")
+            save_cache()
+    save_cache()
+
+    faces = []
+    for path in paths:
+        for face in cache.get(os.path.basename(path), []):
+            faces.append({'media_path': path, 'box': face['box'], 'encoding': face['encoding']})
+    print(f"Found {len(faces)} faces ({new} photos processed, the rest cached in {cache_path})")
+
+    # Chinese whispers joins faces closer than the threshold, like matching against reference photos
+    labels = []
+    if faces:
+        labels = dlib.chinese_whispers_clustering([dlib.vector(f['encoding']) for f in faces],
+                                                  config.get_confidence_threshold())
+    groups = {}
+    for face, label in zip(faces, labels):
+        groups.setdefault(label, []).append(face)
+    # Small clusters are mostly strangers in the background
+    clusters = [{'faces': g} for g in groups.values() if len(g) >= config.get_cluster_min_size()]
+    clusters.sort(key=lambda c: len(c['faces']), reverse=True)
+    print(f"Grouped into {len(groups)} clusters, uploading the {len(clusters)} with at least {config.get_cluster_min_size()} faces")
+
+    response = requests.put(
+        f"http://localhost:{config.get_api_port()}/api/admin/face-clusters",
+        json={'clusters': clusters},
+        headers=api_headers(),
+        timeout=300
+    )
+    response.raise_for_status()
+    result = response.json()
+    print(f"Uploaded {result['clusters']} clusters of {result['faces']} faces, {result['labels_kept']} kept their label")
+    print(f"Label them at GET /api/face-clusters and POST /api/admin/face-clusters/{{id}}/label")
+
+def serve_inference(config, port: int):
+    """Detect faces for face filter services configured with this machine as their inference server.
+    Requests need the INFERENCE_TOKEN environment variable as bearer token if it is set."""
+    face_cv = FaceCV(config)
+    token = os.environ.get("INFERENCE_TOKEN", "")
+
+    class InferenceHandler(BaseHTTPRequestHandler):
+        def do_POST(self):
+            if token and self.headers.get("Authorization", "") != f"Bearer {token}":
+                self.send_error(401, "Unauthorized")
+                return
+            try:
+                request = json.loads(self.rfile.read(int(self.headers.get("Content-Length", 0))))
+                results = []
+                for image in request["images"]:
+                    faces = face_cv.process_image(BytesIO(base64.b64decode(image["data"])),
+                                                  model=request.get("model") or face_cv.model)
+                    results.append({"id": image["id"], "faces": [
+                        {"box": list(f['location']), "encoding": f['encoding'].tolist()} for f in faces]})
+            except Exception as e:
+                self.send_error(400, f"Invalid request: {e}")
+                return
+            body = json.dumps({"results": results}).encode()
+            self.send_response(200)
+            self.send_header("Content-Type", "application/json")
+            self.send_header("Content-Length", str(len(body)))
+            self.end_headers()
+            self.wfile.write(body)
+
+    print(f"Serving face detection on port {port}...")
+    HTTPServer(("", port), InferenceHandler).serve_forever()
+
+def make_face_cv(config):
+    """Detect faces on the configured inference server, or locally without one"""
+    if config.get_inference().get("url"):
+        return RemoteFaceCV(config)
+    return FaceCV(config)
+
 class FaceCV:
+    # Images are processed one at a time locally
+    batch_size = 1
+
     def __init__(self, config):
         self.config = config
         self.model = config.get_face_detection_model()
         print(f"Using face detection model: {self.model}")
 
-    def process_image(self, image_path: str) -> List[Dict[str, Any]]:
-        """Process an image and return detected faces with their encodings"""
+    def process_images(self, images: List[Any]) -> List[List[Dict[str, Any]]]:
+        """Process several images, returning the faces of each"""
+        return [self.process_image(image) for image in images]
+
+    def process_image(self, image_path, model: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Process an image (a path or a file object) and return detected faces with their encodings"""
         image = face_recognition.load_image_file(image_path)
-        face_locations = face_recognition.face_locations(image, model=self.model)
+        face_locations = face_recognition.face_locations(image, model=model or self.model)
         face_encodings = face_recognition.face_encodings(image, face_locations)
         
         results = []
@@ -115,18 +318,89 @@ class FaceCV:
         """Compare two face encodings using L2 distance"""
         return np.linalg.norm(known_encoding - face_encoding)
 
+class RemoteFaceCV(FaceCV):
+    """Detects faces on a remote inference server, so the face filter can run on a small machine.
+    Results are cached by image hash, since forwarded and replayed photos are often seen again."""
+
+    def __init__(self, config):
+        self.config = config
+        self.model = config.get_face_detection_model()
+        inference = config.get_inference()
+        self.url = inference["url"]
+        self.batch_size = inference.get("batch_size", 8)
+        self.timeout = inference.get("timeout", 60)
+        self.cache_size = inference.get("cache_size", 1000)
+        self.cache = OrderedDict()  # {sha256: [(location, encoding)]}, least recently used first
+        print(f"Using face detection model: {self.model} on {self.url}")
+
+    def process_image(self, image_path, model: Optional[str] = None) -> List[Dict[str, Any]]:
+        return self.process_images([image_path])[0]
+
+    def process_images(self, images: List[Any]) -> List[List[Dict[str, Any]]]:
+        contents = []
+        for image in images:
+            if isinstance(image, str):
+                with open(image, 'rb') as f:
+                    contents.append(f.read())
+            else:
+                contents.append(image.read())
+        hashes = [hashlib.sha256(data).hexdigest() for data in contents]
+
+        missing = {h: data for h, data in zip(hashes, contents) if h not in self.cache}
+        pending = list(missing.items())
+        for start in range(0, len(pending), self.batch_size):
+            batch = pending[start:start + self.batch_size]
+            headers = {}
+            if os.environ.get("INFERENCE_TOKEN"):
+                headers["Authorization"] = f"Bearer {os.environ['INFERENCE_TOKEN']}"
+            response = requests.post(self.url, json={
+                "model": self.model,
+                "images": [{"id": h, "data": base64.b64encode(data).decode()} for h, data in batch]
+            }, headers=headers, timeout=self.timeout)
+            response.raise_for_status()
+            for result in response.json()["results"]:
+                self.cache[result["id"]] = [(tuple(f["box"]), np.array(f["encoding"])) for f in result["faces"]]
+                while len(self.cache) > self.cache_size:
+                    self.cache.popitem(last=False)
+
+        results = []
+        for h, data in zip(hashes, contents):
+            faces = self.cache.get(h)
+            if faces is None:
+                raise RuntimeError(f"inference server returned no result for image {h[:12]}")
+            self.cache.move_to_end(h)
+            image = None
+            found = []
+            for location, encoding in faces:
+                face_image = None
+                # Faces are only cut out for the debug output, which needs the image decoded here
+                if self.config.get_debug_mode():
+                    if image is None:
+                        image = face_recognition.load_image_file(BytesIO(data))
+                    top, right, bottom, left = location
+                    face_image = image[top:bottom, left:right]
+                found.append({'encoding': encoding, 'location': location, 'face_image': face_image})
+            results.append(found)
+        return results
+
 class FaceFilter:
     def __init__(self, config):
         self.config = config
         self.known_faces_dir = config.get_known_faces_dir()
         self.known_faces = {}  # {name: [encodings]}
-        self.face_cv = FaceCV(config)
+        self.reference_faces = {}  # {name: [encodings]} from reference photos
+        self.cluster_faces = {}  # {name: [encodings]} from clusters labelled in the bridge
+        self.uploaded_faces = {}  # {name: [encodings]} from reference photos uploaded to the bridge
+        self.uploaded_encodings = {}  # {photo id: encoding or None}, so each upload is only processed once
+        self.thresholds = {}  # {name: threshold} tuned by the bridge from feedback on past decisions
+        self.last_decisions = []  # The decision per person on the last processed image, for review
+        self.face_cv = make_face_cv(config)
         self.load_known_faces()
 
     def load_known_faces(self):
-        """Load known faces from the data directory"""
+        """Load known faces from the data directory, and the reference photos and labelled clusters of the bridge"""
         print("Loading known faces...")
-        self.known_faces = {}
+        self.reference_faces = {}
 
         for person_dir in os.listdir(self.known_faces_dir):
             person_path = os.path.join(self.known_faces_dir, person_dir)
@@ -149,20 +423,123 @@ class FaceFilter:
                         print(f"  Error processing {filename}: {e}")
 
             if person_encodings:
-                self.known_faces[person_dir] = person_encodings
+                self.reference_faces[person_dir] = person_encodings
                 print(f"  Loaded {len(person_encodings)} faces for {person_dir}")
             else:
                 print(f"  No valid faces found for {person_dir}")
 
+        self.uploaded_faces = self.fetch_uploaded_faces() or {}
+        self.cluster_faces = self.fetch_cluster_faces() or {}
+        self.thresholds = self.fetch_thresholds() or {}
+        self.merge_known_faces()
         print(f"\nLoaded faces for {len(self.known_faces)} people")
 
+    def fetch_cluster_faces(self) -> Optional[Dict[str, List[np.ndarray]]]:
+        """Fetch the faces of clusters labelled in the bridge, or None if the bridge can't be reached"""
+        try:
+            response = requests.get(
+                f"http://localhost:{self.config.get_api_port()}/api/face-clusters/labels",
+                headers=api_headers(),
+                timeout=30
+            )
+            response.raise_for_status()
+            return {name: [np.array(e) for e in encodings] for name, encodings in response.json().items()}
+        except Exception as e:
+            print(f"Could not load labelled face clusters: {e}")
+            return None
+
+    def fetch_uploaded_faces(self) -> Optional[Dict[str, List[np.ndarray]]]:
+        """Fetch the reference photos uploaded to the bridge, or None if the bridge can't be reached.
+        Photos are named after their content, so only new ones are downloaded and processed."""
+        base_url = f"http://localhost:{self.config.get_api_port()}"
+        try:
+            response = requests.get(f"{base_url}/api/reference-photos", headers=api_headers(), timeout=30)
+            response.raise_for_status()
+            uploaded_faces = {}
+            for photo in response.json():
+                if photo['id'] not in self.uploaded_encodings:
+                    image = requests.get(base_url + photo['url'], headers=api_headers(), timeout=30)
+                    image.raise_for_status()
+                    faces = self.face_cv.process_image(BytesIO(image.content))
+                    self.uploaded_encodings[photo['id']] = faces[0]['encoding'] if faces else None
+                    if not faces:
+                        print(f"  No face found in reference photo {photo['id'][:12]} of {photo['destination']}")
+                encoding = self.uploaded_encodings[photo['id']]
+                if encoding is not None:
+                    uploaded_faces.setdefault(photo['destination'], []).append(encoding)
+            return uploaded_faces
+        except Exception as e:
+            print(f"Could not load reference photos from the bridge: {e}")
+            return None
+
+    def fetch_thresholds(self) -> Optional[Dict[str, float]]:
+        """Fetch the per-person thresholds the bridge tuned from feedback, or None if the bridge can't be reached"""
+        if not self.config.get_auto_tune():
+            return {}
+        try:
+            response = requests.get(
+                f"http://localhost:{self.config.get_api_port()}/api/face-matches/thresholds",
+                params={"base": self.config.get_confidence_threshold()},
+                headers=api_headers(),
+                timeout=30
+            )
+            response.raise_for_status()
+            return {name: t['threshold'] for name, t in response.json().items() if t['tuned']}
+        except Exception as e:
+            print(f"Could not load tuned thresholds: {e}")
+            return None
+
+    def threshold_for(self, person_name: str) -> float:
+        """The distance threshold of a person: tuned from feedback if there was enough, else the configured one"""
+        return self.thresholds.get(person_name, self.config.get_confidence_threshold())
+
+    def refresh_bridge_faces(self):
+        """Fetch the uploaded reference photos, labelled clusters and tuned thresholds again, and use them if they changed"""
+        thresholds = self.fetch_thresholds()
+        if thresholds is not None and thresholds != self.thresholds:
+            for name, threshold in sorted(thresholds.items()):
+                print(f"Threshold for {name} tuned to {threshold:.3f} from feedback")
+            self.thresholds = thresholds
+        def as_lists(faces):
+            return {name: [e.tolist() for e in encodings] for name, encodings in faces.items()}
+        changed = False
+        uploaded_faces = self.fetch_uploaded_faces()
+        if uploaded_faces is not None and as_lists(uploaded_faces) != as_lists(self.uploaded_faces):
+            self.uploaded_faces = uploaded_faces
+            changed = True
+        cluster_faces = self.fetch_cluster_faces()
+        if cluster_faces is not None and as_lists(cluster_faces) != as_lists(self.cluster_faces):
+            self.cluster_faces = cluster_faces
+            changed = True
+        if changed:
+            print("Reloading known faces from the bridge...")
+            self.merge_known_faces()
+
+    def merge_known_faces(self):
+        """Match against uploaded reference photos and the faces of labelled clusters next to the reference photos"""
+        known_faces = {name: list(encodings) for name, encodings in self.reference_faces.items()}
+        for name, encodings in self.uploaded_faces.items():
+            known_faces.setdefault(name, []).extend(encodings)
+            print(f"  Loaded {len(encodings)} faces for {name} from uploaded reference photos")
+        for name, encodings in self.cluster_faces.items():
+            known_faces.setdefault(name, []).extend(encodings)
+            print(f"  Loaded {len(encodings)} faces for {name} from labelled clusters")
+        # Children of other pipelines are matched by their own service
+        self.known_faces = {name: encodings for name, encodings in known_faces.items() if self.config.matches_person(name)}
+
     def process_image(self, image_path: str) -> List[Dict[str, Any]]:
-        """Process image and find matches with known faces"""
+        """Process image and find matches with known faces. The decision for every known person, with the
+        closest distance to their reference faces, is left in last_decisions."""
+        # Per person: the closest distance of any face and the most reference faces one face matched
+        closest = {name: {'distance': None, 'matched_count': 0, 'total_references': len(encodings)}
+                   for name, encodings in self.known_faces.items()}
+        self.last_decisions = []
         try:
             # Detect faces in image
             faces = self.face_cv.process_image(image_path)
             if not faces:
                 print("No faces found in image")
+                self.last_decisions = self.decisions(closest, [])
                 return []
 
             results = []
@@ -174,14 +551,19 @@ class FaceFilter:
                     # Track matches for each reference image
                     matched_references = []
                     
+                    threshold = self.threshold_for(person_name)
                     for ref_enc in reference_encodings:
                         distance = self.face_cv.compare_faces(ref_enc, face['encoding'])
+                        stats = closest[person_name]
+                        if stats['distance'] is None or distance < stats['distance']:
+                            stats['distance'] = float(distance)
                         # If distance is below threshold, this reference image is a match
-                        if distance < self.config.get_confidence_threshold():
+                        if distance < threshold:
                             matched_references.append({
                                 'distance': distance,
                                 'encoding': ref_enc
                             })
+                    closest[person_name]['matched_count'] = max(closest[person_name]['matched_count'], len(matched_references))
                     
                     # If we have enough matching reference images, consider this a match
                     min_matches_required = self.config.get_min_matching_faces()
@@ -221,12 +603,24 @@ class FaceFilter:
                     'match': best_match
                 })
 
+            self.last_decisions = self.decisions(closest, [r['match']['name'] for r in results])
             return results
 
         except Exception as e:
             print(f"Error processing image: {e}")
             return []
 
+    def decisions(self, closest: Dict[str, Dict[str, Any]], matched_names: List[str]) -> List[Dict[str, Any]]:
+        """The decision for every known person on an image, as recorded by the bridge"""
+        return [{
+            'destination': name,
+            'distance': stats['distance'],
+            'threshold': self.threshold_for(name),
+            'matched_count': stats['matched_count'],
+            'total_references': stats['total_references'],
+            'matched': name in matched_names
+        } for name, stats in closest.items()]
+
 class ImageHandler(FileSystemEventHandler):
     def __init__(self, config):
         self.config = config
@@ -257,6 +651,7 @@ class ImageHandler(FileSystemEventHandler):
             return []
             
         matches = self.face_filter.process_image(image_path)
+        self.record_decisions(image_path, self.face_filter.last_decisions)
         
         if not matches:
             print(f"No matching faces found in {os.path.basename(image_path)}")
@@ -265,6 +660,21 @@ class ImageHandler(FileSystemEventHandler):
             
         return matches
     
+    def record_decisions(self, image_path: str, decisions: List[Dict[str, Any]]) -> None:
+        """Record the decisions on an image in the bridge, where parents can mark them wrong or missed"""
+        if not decisions:
+            return
+        try:
+            response = requests.post(
+                f"http://localhost:{self.config.get_api_port()}/api/face-matches",
+                json={"media_path": image_path, "matches": decisions},
+                headers=api_headers(),
+                timeout=30
+            )
+            response.raise_for_status()
+        except Exception as e:
+            print(f"Could not record match decisions: {e}")
+
     def send_notification(self, person_name: str, image_path: str) -> bool:
         """Send a single WhatsApp notification"""
         # Check again if image exists before sending
@@ -277,6 +687,7 @@ class ImageHandler(FileSystemEventHandler):
             print(f"No destination info found for {person_name}")
             return False
 
+        request_id = uuid.uuid4().hex[:16]
         try:
             payload = {
                 "phone": dest_info["group"],
@@ -285,23 +696,31 @@ class ImageHandler(FileSystemEventHandler):
                 "media_type": "image",
                 "caption": dest_info["name"]
             }
+            if self.config.is_dry_run():
+                payload["dry_run"] = True
             
             # Verify image exists right before sending
             if not os.path.exists(image_path):
                 print(f"Error: Image {image_path} was deleted before sending")
                 return False
                 
+            # The request ID shows up in the bridge logs, so failed sends can be traced there
+            headers = {"X-Request-ID": request_id, **api_headers()}
             response = requests.post(
-                "http://localhost:8080/api/send", 
+                f"http://localhost:{self.config.get_api_port()}/api/send", 
                 json=payload, 
+                headers=headers,
                 timeout=30
             )
             response.raise_for_status()
-            print(f"Notification sent successfully for {person_name}")
+            if response.json().get("dry_run"):
+                print(f"[DRY-RUN] Would have sent notification for {person_name} (request {request_id})")
+            else:
+                print(f"Notification sent successfully for {person_name} (request {request_id})")
             return True
             
         except Exception as e:
-            print(f"Error sending notification for {person_name}: {e}")
+            print(f"Error sending notification for {person_name} (request {request_id}): {e}")
             return False
     
     def send_all_notifications(self, matches: List[Dict], image_path: str) -> bool:
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// The chat and sender of synthetic messages; the chat is monitored for the run only
+const (
+	benchGroup  = "120363999999999999@g.us"
+	benchSender = "972599999999@s.whatsapp.net"
+)
+
+// benchOptions sizes a bench run
+type benchOptions struct {
+	Messages int
+	Images   int
+	// Long side of the synthetic photos in pixels; phone cameras take about 4000
+	ImageSize int
+	// Messages handled at once; 0 for history.workers
+	Concurrency int
+}
+
+// benchResult holds the latencies of one phase of a bench run
+type benchResult struct {
+	name      string
+	elapsed   time.Duration
+	failed    int
+	latencies []time.Duration
+}
+
+// runBench pushes synthetic messages and photos through the message handling with the configured SQLite
+// and download settings, and reports throughput and latencies. Everything is written to a scratch
+// directory inside the data directory, on the same disk as the real store, and removed afterwards.
+func runBench(cfg config.Config, opts benchOptions) error {
+	if err := os.MkdirAll(store.Dir, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(store.Dir, "bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	store.Dir, media.Dir = dir, filepath.Join(dir, "media")
+
+	cfg.InputGroups = []string{benchGroup}
+	config.Set(cfg)
+	if err := routing.CompileRedactionRules(cfg.Privacy.RedactionRules); err != nil {
+		return err
+	}
+	session.ConfigureDownloads(cfg.Media)
+
+	messageStore, err := store.New()
+	if err != nil {
+		return fmt.Errorf("failed to open message store: %v", err)
+	}
+	defer messageStore.Close()
+
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = cfg.History.Workers
+	}
+	fmt.Printf("[BENCH] %d messages and %d photos of %d px, %d at a time, in %s\n", opts.Messages, opts.Images, opts.ImageSize, workers, dir)
+
+	mock := session.NewMock()
+	start := time.Now().Add(-time.Minute)
+	var results []benchResult
+
+	// Plain inserts show what SQLite itself sustains
+	results = append(results, benchPhase("db write", opts.Messages, workers, func(i int) error {
+		return messageStore.StoreIncoming(store.IncomingMessage{
+			ID:        fmt.Sprintf("BENCHDB%08d", i),
+			ChatJID:   benchGroup,
+			Sender:    benchSender,
+			Content:   benchText(i),
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}))
+
+	// Text messages go through redaction, spam scoring, storage and event detection
+	results = append(results, benchPhase("text message", opts.Messages, workers, func(i int) error {
+		evt, err := mock.Inject(session.MockMessageRequest{
+			ID:      fmt.Sprintf("BENCHMSG%08d", i),
+			ChatJID: benchGroup,
+			Sender:  benchSender,
+			Content: benchText(i),
+		})
+		if err != nil {
+			return err
+		}
+		session.HandleMessage(mock, messageStore, evt, waLog.Noop)
+		return nil
+	}))
+
+	if opts.Images > 0 {
+		paths, err := writeBenchPhotos(dir, opts.Images, opts.ImageSize)
+		if err != nil {
+			return err
+		}
+
+		// Photos are downloaded within the live download limits, stored by hash and queued for the face filter
+		results = append(results, benchPhase("photo message", opts.Images, workers, func(i int) error {
+			evt, err := mock.Inject(session.MockMessageRequest{
+				ID:        fmt.Sprintf("BENCHIMG%08d", i),
+				ChatJID:   benchGroup,
+				Sender:    benchSender,
+				MediaPath: paths[i],
+			})
+			if err != nil {
+				return err
+			}
+			session.HandleMessage(mock, messageStore, evt, waLog.Noop)
+			return nil
+		}))
+
+		// Forwarding decodes each photo and encodes it as JPEG again
+		results = append(results, benchPhase("photo conversion", opts.Images, workers, func(i int) error {
+			data, err := os.ReadFile(paths[i])
+			if err != nil {
+				return err
+			}
+			_, _, _, err = media.VerifyAndConvertImage(data, cfg.Media.JPEGQuality, nil)
+			return err
+		}))
+	}
+
+	fmt.Println("\n=== Bench Report ===")
+	fmt.Printf("%-17s %7s %9s %9s %9s %9s %9s %7s\n", "phase", "count", "per sec", "p50", "p95", "p99", "max", "failed")
+	for _, result := range results {
+		result.print()
+	}
+	sizes := store.DatabaseSizes()
+	fmt.Printf("\nmessages.db grew to %d KB\n", sizes["messages.db"]>>10)
+	return nil
+}
+
+// benchPhase runs op for 0..n-1 with the given number of workers and records how long each call took
+func benchPhase(name string, n, workers int, op func(i int) error) benchResult {
+	result := benchResult{name: name, latencies: make([]time.Duration, n)}
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	began := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				callStart := time.Now()
+				err := op(i)
+				result.latencies[i] = time.Since(callStart)
+				if err != nil {
+					mu.Lock()
+					result.failed++
+					mu.Unlock()
+					fmt.Printf("[BENCH] %s %d failed: %v\n", name, i, err)
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	result.elapsed = time.Since(began)
+	return result
+}
+
+// print writes one line of the report
+func (r benchResult) print() {
+	if len(r.latencies) == 0 {
+		return
+	}
+	sorted := append([]time.Duration{}, r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	fmt.Printf("%-17s %7d %9.1f %9s %9s %9s %9s %7d\n", r.name, len(sorted), float64(len(sorted))/r.elapsed.Seconds(),
+		roundLatency(percentile(0.5)), roundLatency(percentile(0.95)), roundLatency(percentile(0.99)), roundLatency(sorted[len(sorted)-1]), r.failed)
+}
+
+// roundLatency keeps three significant digits or so
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// benchText returns the text of synthetic message i, of the length kindergarten messages usually have
+func benchText(i int) string {
+	return fmt.Sprintf("Message %d: tomorrow the children go out to the garden, please send them with a hat and a bottle of water", i)
+}
+
+// writeBenchPhotos writes n distinct JPEG photos of the given long side into dir. The photo is encoded
+// once; each copy gets its number appended after the end-of-image marker, which decoders ignore, so the
+// copies hash differently and none is stored only once.
+func writeBenchPhotos(dir string, n, size int) ([]string, error) {
+	width, height := size, size*3/4
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), uint8((x ^ y) & 0xff), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("photo-%d.jpg", i))
+		data := append(buf.Bytes()[:buf.Len():buf.Len()], fmt.Sprintf("%d", i)...)
+		if err := os.WriteFile(paths[i], data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
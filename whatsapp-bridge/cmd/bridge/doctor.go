@@ -0,0 +1,369 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/store"
+)
+
+// doctorReport collects the outcome of the self-test checks
+type doctorReport struct {
+	failures int
+	warnings int
+}
+
+func (d *doctorReport) ok(format string, args ...interface{}) {
+	fmt.Printf("  [OK]   %s\n", fmt.Sprintf(format, args...))
+}
+
+func (d *doctorReport) warn(fix, format string, args ...interface{}) {
+	d.warnings++
+	fmt.Printf("  [WARN] %s\n", fmt.Sprintf(format, args...))
+	if fix != "" {
+		fmt.Printf("         -> %s\n", fix)
+	}
+}
+
+func (d *doctorReport) fail(fix, format string, args ...interface{}) {
+	d.failures++
+	fmt.Printf("  [FAIL] %s\n", fmt.Sprintf(format, args...))
+	if fix != "" {
+		fmt.Printf("         -> %s\n", fix)
+	}
+}
+
+// runDoctor checks configuration, storage, session and connectivity and prints fixes for anything
+// that is wrong. It returns false if any check failed.
+func runDoctor(apiPort int, overrides overrideFlags) bool {
+	report := &doctorReport{}
+
+	fmt.Println("\n=== Configuration ===")
+	cfg, configOK := doctorCheckConfig(report, overrides)
+	useDataDir(cfg.DataDir, cfg.Media.StorePath)
+
+	fmt.Println("\n=== Storage ===")
+	doctorCheckStorage(report, cfg)
+
+	fmt.Println("\n=== Connectivity ===")
+	online := doctorCheckConnectivity(report)
+
+	fmt.Println("\n=== WhatsApp session ===")
+	if apiPort == 0 {
+		apiPort = cfg.APIPort
+	}
+	if apiPort == 0 {
+		apiPort = config.DefaultAPIPort
+	}
+	if bridgeRunning(apiPort) {
+		report.warn("Stop the bridge to let doctor verify the session and group membership",
+			"The bridge is running on port %d; skipping live checks to avoid replacing its connection", apiPort)
+	} else {
+		doctorCheckSession(report, cfg, configOK && online)
+	}
+
+	fmt.Printf("\n%d failed, %d warnings\n", report.failures, report.warnings)
+	return report.failures == 0
+}
+
+// doctorCheckConfig validates config.json and returns it for the later checks
+func doctorCheckConfig(report *doctorReport, overrides overrideFlags) (config.Config, bool) {
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		report.warn("Copy config.template.json to config.json in the project root, or set JMK_* environment variables",
+			"%s does not exist, using environment variables and defaults", configPath)
+		data = []byte("{}")
+	} else if err != nil {
+		report.fail("Give the bridge user read access to the file", "Cannot read %s: %v", configPath, err)
+		return config.Config{}, false
+	}
+	cfg, err := config.Parse(data)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			report.fail("Fix the JSON syntax (trailing commas and comments are not allowed in config.json)", "%s: %v", configPath, err)
+		} else {
+			report.fail("Compare the field types with config.template.json", "%s could not be parsed: %v", configPath, err)
+		}
+		return cfg, false
+	}
+	if err := overrides.apply(&cfg); err != nil {
+		report.fail("Use -set key=value with a key from config.json, e.g. -set media.jpeg_quality=90", "%v", err)
+		return cfg, false
+	}
+	report.ok("%s parsed", configPath)
+
+	// Misspelled keys are ignored when the file is read, so they only show up here
+	for _, schemaErr := range config.CheckSchema(data) {
+		report.warn("Compare with config.schema.json, or run the validate command", "%s line %d: %s: %s", configPath, schemaErr.Line, schemaErr.Key, schemaErr.Message)
+	}
+
+	problems := cfg.Validate()
+	for _, problem := range problems {
+		if problem.Warning {
+			report.warn(problem.Fix, "%s", problem.Message)
+		} else {
+			report.fail(problem.Fix, "%s", problem.Message)
+		}
+	}
+	valid := !config.HasErrors(problems)
+
+	// Reference photos are read by the face detection service, relative to the project root (the directory
+	// of config.json)
+	var faceConfig struct {
+		FaceDetection struct {
+			KnownFacesDir string `json:"known_faces_dir"`
+		} `json:"face_detection"`
+	}
+	json.Unmarshal(data, &faceConfig)
+	knownFacesDir := faceConfig.FaceDetection.KnownFacesDir
+	if knownFacesDir == "" {
+		knownFacesDir = "reference_images"
+	}
+	for name := range cfg.Destinations {
+		if entries, err := os.ReadDir(filepath.Join(filepath.Dir(configPath), knownFacesDir, name)); err != nil || len(entries) == 0 {
+			report.warn(fmt.Sprintf("Add a few photos of %s to %s/%s", name, knownFacesDir, name), "No reference photos found for destination %q", name)
+		}
+	}
+
+	if valid {
+		report.ok("%d input groups, %d channels, %d destinations", len(cfg.InputGroups), len(cfg.InputChannels), len(cfg.Destinations))
+	}
+	return cfg, valid
+}
+
+// doctorCheckWritable verifies that dir exists (or can be created) and accepts new files
+func doctorCheckWritable(report *doctorReport, dir, purpose string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		report.fail("Create the directory and give the bridge user write access", "%s directory %s cannot be created: %v", purpose, dir, err)
+		return
+	}
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		report.fail(fmt.Sprintf("Give the bridge user write access, e.g. chmod u+w %s", dir), "%s directory %s is not writable: %v", purpose, dir, err)
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	report.ok("%s directory %s is writable", purpose, dir)
+}
+
+// doctorCheckStorage checks the media directories and the message store schema
+func doctorCheckStorage(report *doctorReport, cfg config.Config) {
+	doctorCheckWritable(report, store.Dir, "Store")
+	// The bridge writes media to store_path, where the face detection service picks it up
+	doctorCheckWritable(report, media.Dir, "Media")
+	if media.PureGo {
+		report.ok("Pure-Go image pipeline (purego build): large photos are scaled down when decoded, HEIC photos can't be read")
+	}
+
+	dbPath := store.Path("messages.db")
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		report.warn("It is created on the first start of the bridge", "Message store %s does not exist yet", dbPath)
+		return
+	}
+	if err := store.CheckDatabaseIntegrity(dbPath); err != nil {
+		report.fail("Restore the store from a backup with -restore", "%s failed the integrity check: %v", dbPath, err)
+		return
+	}
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		report.fail("Restore the store from a backup with -restore", "Cannot open %s: %v", dbPath, err)
+		return
+	}
+	defer db.Close()
+
+	version, err := store.SchemaVersion(db)
+	switch {
+	case err != nil:
+		report.fail("Restore the store from a backup with -restore", "Cannot read the schema version of %s: %v", dbPath, err)
+	case version < store.LatestSchemaVersion():
+		report.warn("Start the bridge once to apply pending migrations", "Message store schema is at version %d, current is %d", version, store.LatestSchemaVersion())
+	case version > store.LatestSchemaVersion():
+		report.fail("Update the bridge; this store was written by a newer version", "Message store schema version %d is newer than supported (%d)", version, store.LatestSchemaVersion())
+	default:
+		report.ok("Message store schema is up to date (version %d)", version)
+	}
+}
+
+// doctorCheckConnectivity verifies that WhatsApp's servers can be reached
+func doctorCheckConnectivity(report *doctorReport) bool {
+	online := true
+	for _, target := range []struct{ addr, purpose string }{
+		{"web.whatsapp.com:443", "WhatsApp web socket"},
+		{"mmg.whatsapp.net:443", "WhatsApp media servers"},
+	} {
+		conn, err := net.DialTimeout("tcp", target.addr, 5*time.Second)
+		if err != nil {
+			report.fail("Check the internet connection, DNS and any firewall or proxy blocking outbound port 443",
+				"Cannot reach %s (%s): %v", target.addr, target.purpose, err)
+			online = false
+			continue
+		}
+		conn.Close()
+		report.ok("%s reachable (%s)", target.addr, target.purpose)
+	}
+	return online
+}
+
+// bridgeRunning reports whether something already listens on the REST API port
+func bridgeRunning(port int) bool {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// doctorCheckSession verifies the stored session and, when live is set, that every configured group
+// and channel exists and the account is a member
+func doctorCheckSession(report *doctorReport, cfg config.Config, live bool) {
+	sessionPath := store.Path("whatsapp.db")
+	if _, err := os.Stat(sessionPath); os.IsNotExist(err) {
+		report.fail("Start the bridge and scan the QR code with WhatsApp > Linked devices", "Not paired with WhatsApp yet")
+		return
+	}
+	container, err := sqlstore.New("sqlite3", "file:"+sessionPath+"?_foreign_keys=on", waLog.Noop)
+	if err != nil {
+		report.fail("Delete "+sessionPath+" and pair again", "Cannot open the session store: %v", err)
+		return
+	}
+	device, err := container.GetFirstDevice()
+	if err != nil || device.ID == nil {
+		report.fail("Start the bridge and scan the QR code with WhatsApp > Linked devices", "No paired device in the session store")
+		return
+	}
+	report.ok("Session stored for %s", device.ID.User)
+	if !live {
+		report.warn("Fix the failures above and run doctor again", "Skipping live session checks")
+		return
+	}
+
+	client := whatsmeow.NewClient(device, waLog.Noop)
+	result := make(chan error, 1)
+	client.AddEventHandler(func(evt interface{}) {
+		switch v := evt.(type) {
+		case *events.Connected:
+			select {
+			case result <- nil:
+			default:
+			}
+		case *events.LoggedOut:
+			select {
+			case result <- fmt.Errorf("logged out: %s", v.Reason):
+			default:
+			}
+		}
+	})
+	if err := client.Connect(); err != nil {
+		report.fail("Check connectivity and try again", "Failed to connect: %v", err)
+		return
+	}
+	defer client.Disconnect()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			report.fail("The phone unlinked this device; delete "+sessionPath+" and pair again", "Session is no longer valid (%v)", err)
+			return
+		}
+	case <-time.After(30 * time.Second):
+		report.fail("Check connectivity, or delete "+sessionPath+" and pair again", "Timed out connecting with the stored session")
+		return
+	}
+	report.ok("Session is valid and connected")
+
+	// Groups given by alias are checked as the groups the aliases resolve to
+	var groups []*types.GroupInfo
+	if len(cfg.GroupAliases) > 0 || len(cfg.InputGroupPatterns) > 0 {
+		if groups, err = client.GetJoinedGroups(); err != nil {
+			report.fail("Check connectivity and try again", "Failed to list groups to resolve group aliases and patterns: %v", err)
+			return
+		}
+	}
+	if len(cfg.GroupAliases) > 0 {
+		jids, problems := cfg.MatchGroupAliases(groups)
+		for _, problem := range problems {
+			report.fail(problem.Fix, "%s", problem.Message)
+		}
+		for _, alias := range slices.Sorted(maps.Keys(jids)) {
+			report.ok("Group alias %q is %s", alias, jids[alias])
+		}
+		cfg = cfg.WithGroupJIDs(jids)
+	}
+	if len(cfg.InputGroupPatterns) > 0 {
+		names := make(map[string]string)
+		for _, group := range groups {
+			names[group.JID.String()] = group.Name
+		}
+		matched := cfg.MatchInputGroupPatterns(groups)
+		if len(matched) == 0 {
+			report.warn("Check input_group_patterns against the group names listed by -list-groups", "input_group_patterns match none of the joined groups")
+		}
+		for _, jid := range matched {
+			report.ok("Input group %s (%s) matches input_group_patterns", jid, names[jid])
+		}
+	}
+
+	checkGroup := func(jidStr, role string) {
+		// Aliases still unresolved have been reported above
+		if cfg.IsGroupAlias(jidStr) {
+			return
+		}
+		jid, err := types.ParseJID(jidStr)
+		if err != nil {
+			return
+		}
+		if jid.Server == types.DefaultUserServer {
+			report.ok("%s %s is a direct chat", role, jidStr)
+			return
+		}
+		info, err := client.GetGroupInfo(jid)
+		if err != nil {
+			report.fail("Make sure the linked account is a member of the group; run with -list-groups to copy the JID",
+				"%s %s is not accessible: %v", role, jidStr, err)
+			return
+		}
+		report.ok("%s %s (%s)", role, jidStr, info.Name)
+	}
+	for _, jid := range cfg.InputGroups {
+		checkGroup(jid, "Input group")
+	}
+	for name, dest := range cfg.Destinations {
+		group := dest.Group
+		if !strings.Contains(group, "@") && !cfg.IsGroupAlias(group) {
+			group = strings.TrimPrefix(group, "+") + "@" + types.DefaultUserServer
+		}
+		checkGroup(group, "Destination "+name)
+	}
+	for _, jidStr := range cfg.InputChannels {
+		jid, err := types.ParseJID(jidStr)
+		if err != nil {
+			continue
+		}
+		info, err := client.GetNewsletterInfo(jid)
+		if err != nil {
+			report.fail("Run with -list-channels to copy the channel JID", "Channel %s is not accessible: %v", jidStr, err)
+			continue
+		}
+		report.ok("Channel %s (%s)", jidStr, info.ThreadMeta.Name.Text)
+	}
+}
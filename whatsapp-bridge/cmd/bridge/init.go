@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"whatsapp-client/internal/assets"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/store"
+)
+
+// usage prints the commands and flags of the bridge
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  init             Pair with WhatsApp and pick groups and destinations to write config.json (into -data-dir if given)")
+	fmt.Fprintln(out, "  validate [file]  Check config.json (or file) against the schema and report problems by line")
+	fmt.Fprintln(out, "  schema           Print the JSON Schema of config.json")
+	fmt.Fprintln(out, "  bench            Push synthetic messages and photos through message handling and report throughput and latencies")
+	fmt.Fprintln(out, "  user list|add NAME ROLE|remove NAME")
+	fmt.Fprintln(out, "                   Manage who may log in to the dashboard and API, as admin, sender or viewer")
+	fmt.Fprintln(out, "  (none)           Run the bridge")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// initDataDir creates the database and media directories and sets up config.json. On a terminal it runs
+// the setup wizard; otherwise, e.g. in a container, it writes the embedded config template to configPath
+// unless config.json exists already.
+func initDataDir(dataDir string) error {
+	if dataDir != "" {
+		useDataDir(dataDir, "")
+	}
+	for _, dir := range []string{filepath.Dir(configPath), store.Dir, media.Dir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+	}
+	if isTerminal(os.Stdin) {
+		return runWizard(dataDir)
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		fmt.Printf("%s already exists, leaving it unchanged\n", configPath)
+	} else {
+		if err := os.WriteFile(configPath, assets.ConfigTemplate, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %v", configPath, err)
+		}
+		fmt.Printf("Wrote starter configuration to %s\n", configPath)
+	}
+
+	fmt.Println("\nNext steps:")
+	fmt.Printf("  1. Start the bridge and scan the QR code: %s\n", commandLine(dataDir))
+	fmt.Printf("  2. List your groups with %s -list-groups and put their JIDs into %s\n", commandLine(dataDir), configPath)
+	fmt.Printf("  3. Check the setup with %s -doctor\n", commandLine(dataDir))
+	return nil
+}
+
+// commandLine is how the user runs the bridge with the same data directory
+func commandLine(dataDir string) string {
+	name := filepath.Base(os.Args[0])
+	if dataDir == "" {
+		return name
+	}
+	return name + " -data-dir " + dataDir
+}
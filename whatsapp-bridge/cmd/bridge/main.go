@@ -0,0 +1,604 @@
+// Command bridge connects to WhatsApp, stores messages of the monitored chats and serves the REST API
+// the face detection service forwards photos through.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+	// The zone database is built in, so timezone works on images without one
+	_ "time/tzdata"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/absence"
+	"whatsapp-client/internal/acks"
+	"whatsapp-client/internal/api"
+	"whatsapp-client/internal/app"
+	"whatsapp-client/internal/chaos"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/embed"
+	"whatsapp-client/internal/forms"
+	"whatsapp-client/internal/importer"
+	"whatsapp-client/internal/logs"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/payments"
+	"whatsapp-client/internal/publish"
+	"whatsapp-client/internal/reports"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/topics"
+	"whatsapp-client/internal/tracing"
+	"whatsapp-client/internal/version"
+)
+
+func main() {
+	// Command line flags
+	listGroupsFlag := flag.Bool("list-groups", false, "List all WhatsApp groups and exit")
+	listChannelsFlag := flag.Bool("list-channels", false, "List all followed WhatsApp channels and exit")
+	apiPort := flag.Int("port", 0, "Port for the REST API server (default: api_port from config.json, or 8080)")
+	backupFlag := flag.Bool("backup", false, "Create a backup archive of the databases and exit")
+	backupMediaFlag := flag.Bool("backup-media", false, "Include the media directory in the backup")
+	backupDir := flag.String("backup-dir", "", "Directory where backup archives are written (default: backups, inside -data-dir if given)")
+	restorePath := flag.String("restore", "", "Restore the store from a backup archive and exit (bridge must be stopped)")
+	exportSessionPath := flag.String("export-session", "", "Write the WhatsApp session, encrypted with a passphrase, to this file and exit")
+	importSessionPath := flag.String("import-session", "", "Install a session written by -export-session and exit (bridge must be stopped)")
+	verifyMediaFlag := flag.Bool("verify-media", false, "Check stored messages against the media directory and exit")
+	importPath := flag.String("import", "", "Import a WhatsApp \"Export chat\" ZIP into the message store and exit")
+	importChat := flag.String("import-chat", "", "JID of the chat the imported export belongs to (required with -import)")
+	importName := flag.String("import-name", "", "Display name for the imported chat (defaults to the export file name)")
+	importMonthFirst := flag.Bool("import-month-first", false, "Parse export dates as MM/DD/YYYY instead of DD/MM/YYYY")
+	exportPath := flag.String("export", "", "Export stored messages to this file (plus a .manifest.json of media) and exit")
+	exportFormat := flag.String("export-format", "jsonl", "Export format: jsonl or csv")
+	exportChat := flag.String("export-chat", "", "Only export (or re-parse) messages from this chat JID")
+	exportFrom := flag.String("export-from", "", "Only export (or re-parse) messages from this date on (YYYY-MM-DD)")
+	exportTo := flag.String("export-to", "", "Only export (or re-parse) messages up to and including this date (YYYY-MM-DD)")
+	exportTopic := flag.String("export-topic", "", "Only export messages tagged with this topic, e.g. health")
+	reparseFlag := flag.Bool("reparse", false, "Parse archived raw messages again, update the stored messages and exit")
+	dryRunFlag := flag.Bool("dry-run", false, "Record what /api/send would send instead of sending it")
+	doctorFlag := flag.Bool("doctor", false, "Check configuration, storage, session and connectivity, print fixes and exit")
+	mockFlag := flag.Bool("mock", false, "Run without a WhatsApp connection: inject messages via /api/mock/messages, sends are captured")
+	var bench benchOptions
+	flag.IntVar(&bench.Messages, "bench-messages", 2000, "Synthetic text messages the bench command handles")
+	flag.IntVar(&bench.Images, "bench-images", 50, "Synthetic photos the bench command handles")
+	flag.IntVar(&bench.ImageSize, "bench-image-size", 4000, "Long side in pixels of the bench command's photos")
+	flag.IntVar(&bench.Concurrency, "bench-concurrency", 0, "Messages the bench command handles at once (default: history.workers)")
+	versionFlag := flag.Bool("version", false, "Print the version of the bridge and exit")
+	dataDir := flag.String("data-dir", "", "Keep config.json, the databases, media and backups in this one directory")
+	var overrides overrideFlags
+	flag.Var(&overrides, "set", "Override a config key, e.g. -set media.jpeg_quality=90 (repeatable; also JMK_MEDIA_JPEG_QUALITY=90)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *versionFlag {
+		fmt.Println(version.Get())
+		return
+	}
+
+	// Subcommands come first, their flags may follow them (bridge init -data-dir /data)
+	command := flag.Arg(0)
+	if command != "" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	// With -data-dir everything lives in one directory, so the binary can run from anywhere with a volume.
+	// An explicit -set data_dir still takes precedence.
+	if *dataDir != "" {
+		configPath = filepath.Join(*dataDir, "config.json")
+		overrides = append(overrideFlags{"data_dir=" + *dataDir}, overrides...)
+	}
+	if *backupDir == "" {
+		*backupDir = filepath.Join(*dataDir, "backups")
+	}
+
+	switch command {
+	case "", "bench", "user":
+	case "init":
+		if err := initDataDir(*dataDir); err != nil {
+			fmt.Printf("Init failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "validate":
+		path := configPath
+		if flag.NArg() > 0 {
+			path = flag.Arg(0)
+		}
+		if !validateConfig(path) {
+			os.Exit(1)
+		}
+		return
+	case "schema":
+		if err := printSchema(); err != nil {
+			fmt.Printf("Schema failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Printf("Unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The self-test reads the config itself so it can report problems with it
+	if *doctorFlag {
+		if !runDoctor(*apiPort, overrides) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Settings come from config.json, JMK_* environment variables and -set flags, in increasing precedence.
+	// The offline commands below only need data_dir and fall back to the default without a valid config,
+	// except for those storing messages, which need its redaction rules.
+	cfg, cfgErr := loadConfig(overrides)
+	if cfgErr != nil {
+		fmt.Printf("[CONFIG] Error: %v\n", cfgErr)
+	}
+	useDataDir(cfg.DataDir, cfg.Media.StorePath)
+
+	// The bench runs the message handling on synthetic messages in a scratch store
+	if command == "bench" {
+		if cfgErr != nil {
+			os.Exit(1)
+		}
+		if err := runBench(cfg, bench); err != nil {
+			fmt.Printf("Bench failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Users are managed in the message store, also while the bridge runs
+	if command == "user" {
+		if err := runUsers(flag.Args()); err != nil {
+			fmt.Printf("User command failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Backup and restore run without connecting to WhatsApp
+	if *backupFlag {
+		archivePath, err := store.CreateBackup(*backupDir, *backupMediaFlag)
+		if err != nil {
+			fmt.Printf("Backup failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Backup written to %s\n", archivePath)
+		return
+	}
+	if *restorePath != "" {
+		if err := store.RestoreBackup(*restorePath); err != nil {
+			fmt.Printf("Restore failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Moving the session to another machine; the passphrase comes from JMK_SESSION_PASSPHRASE or a prompt
+	if *exportSessionPath != "" || *importSessionPath != "" {
+		passphrase, err := sessionPassphrase(*exportSessionPath != "")
+		if err == nil {
+			if *exportSessionPath != "" {
+				err = store.ExportSession(*exportSessionPath, passphrase)
+			} else {
+				err = store.ImportSession(*importSessionPath, passphrase)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Session transfer failed: %v\n", err)
+			os.Exit(1)
+		}
+		if *exportSessionPath != "" {
+			fmt.Printf("Session written to %s. Stop this bridge before starting one with the session elsewhere.\n", *exportSessionPath)
+		} else {
+			fmt.Printf("Session imported from %s\n", *importSessionPath)
+		}
+		return
+	}
+
+	// Media follows media.store_path: it has to be writable, and files stored elsewhere before are moved
+	if cfgErr == nil {
+		if err := checkWritable(media.Dir); err != nil {
+			fmt.Printf("[CONFIG] Error: media directory %s is not writable: %v (check media.store_path)\n", media.Dir, err)
+			os.Exit(1)
+		}
+		if err := relocateMedia(cfg); err != nil {
+			fmt.Printf("Failed to move media to %s: %v\n", media.Dir, err)
+			os.Exit(1)
+		}
+	}
+
+	// Media verification runs offline; re-downloading missing files is available via the API
+	if *verifyMediaFlag {
+		messageStore, err := store.New()
+		if err != nil {
+			fmt.Printf("Failed to open message store: %v\n", err)
+			os.Exit(1)
+		}
+		defer messageStore.Close()
+		report, err := messageStore.VerifyMedia(media.Dir, nil)
+		if err != nil {
+			fmt.Printf("Media verification failed: %v\n", err)
+			os.Exit(1)
+		}
+		printMediaReport(report)
+		return
+	}
+
+	// Importing an export only touches the local store
+	if *importPath != "" {
+		if *importChat == "" {
+			fmt.Println("-import-chat is required with -import (use -list-groups to find the group JID)")
+			os.Exit(1)
+		}
+		if err := useRedactionRules(cfg, cfgErr); err != nil {
+			fmt.Printf("Import failed: %v\n", err)
+			os.Exit(1)
+		}
+		messageStore, err := store.New()
+		if err != nil {
+			fmt.Printf("Failed to open message store: %v\n", err)
+			os.Exit(1)
+		}
+		defer messageStore.Close()
+		defer store.ClosePipelines()
+		result, err := importer.ImportChatExport(messageStore, *importPath, *importChat, *importName, !*importMonthFirst)
+		if err != nil {
+			fmt.Printf("Import failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Import complete: %d parsed, %d imported, %d already present, %d media files\n",
+			result.Parsed, result.Imported, result.Skipped, result.Media)
+		return
+	}
+
+	// Exporting only reads the local store
+	if *exportPath != "" {
+		filter, err := store.ParseExportFilter(*exportChat, *exportFrom, *exportTo, *exportTopic)
+		if err != nil {
+			fmt.Printf("Export failed: %v\n", err)
+			os.Exit(1)
+		}
+		messageStore, err := store.New()
+		if err != nil {
+			fmt.Printf("Failed to open message store: %v\n", err)
+			os.Exit(1)
+		}
+		defer messageStore.Close()
+		mediaCount, err := messageStore.ExportToFile(filter, *exportFormat, *exportPath)
+		if err != nil {
+			fmt.Printf("Export failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported messages to %s (%d media files listed in %s.manifest.json)\n", *exportPath, mediaCount, *exportPath)
+		return
+	}
+
+	// Re-parsing archived raw messages only touches the local store
+	if *reparseFlag {
+		filter, err := store.ParseExportFilter(*exportChat, *exportFrom, *exportTo, "")
+		if err != nil {
+			fmt.Printf("Re-parse failed: %v\n", err)
+			os.Exit(1)
+		}
+		if err := useRedactionRules(cfg, cfgErr); err != nil {
+			fmt.Printf("Re-parse failed: %v\n", err)
+			os.Exit(1)
+		}
+		messageStore, err := store.New()
+		if err != nil {
+			fmt.Printf("Failed to open message store: %v\n", err)
+			os.Exit(1)
+		}
+		defer messageStore.Close()
+		result, err := session.Reparse(nil, messageStore, filter, waLog.Stdout("Reparse", "INFO", true))
+		if err != nil {
+			fmt.Printf("Re-parse failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Re-parse complete: %d raw messages, %d updated, %d added, %d failed\n",
+			result.Scanned, result.Updated, result.Added, result.Failed)
+		return
+	}
+
+	if cfgErr != nil {
+		os.Exit(1)
+	}
+	config.Set(cfg)
+
+	// Report configuration problems; errors stop the bridge unless it only lists groups or channels
+	problems := cfg.Validate()
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Printf("[CONFIG] Warning: %s\n", problem)
+		} else {
+			fmt.Printf("[CONFIG] Error: %s\n", problem)
+		}
+	}
+	if config.HasErrors(problems) && !*listGroupsFlag && !*listChannelsFlag {
+		fmt.Printf("Fix the errors in %s or the JMK_* environment (run with -doctor for details)\n", configPath)
+		os.Exit(1)
+	}
+
+	// The -port flag takes precedence over api_port
+	port := cfg.APIPort
+	if *apiPort != 0 {
+		port = *apiPort
+	}
+
+	// Compile content redaction rules
+	if err := routing.CompileRedactionRules(cfg.Privacy.RedactionRules); err != nil {
+		fmt.Printf("Error in privacy config: %v\n", err)
+		return
+	}
+
+	// In dry-run mode nothing is sent, sends are only logged and recorded in the forward ledger
+	routing.DryRunMode = *dryRunFlag
+	if routing.DryRunMode {
+		fmt.Println("[DRY-RUN] Dry-run mode enabled, messages will be recorded but not sent")
+	}
+
+	// Limit media downloads so history backfill doesn't saturate the connection
+	session.ConfigureDownloads(cfg.Media)
+
+	// Export pipeline spans to an OpenTelemetry collector if configured
+	tracing.Start(cfg.Tracing)
+
+	// Set up logger with debug level
+	logger := waLog.Stdout("Client", "INFO", true)
+
+	// Faults for trying out retries and reconnects, turned on by JMK_CHAOS_* variables
+	faults, err := chaos.Configure()
+	if err != nil {
+		fmt.Printf("Error in chaos settings: %v\n", err)
+		return
+	}
+	for _, fault := range faults {
+		fmt.Printf("[CHAOS] Fault injection is on: %s\n", fault)
+	}
+
+	// Mock mode replaces the WhatsApp connection with an in-memory fake
+	if *mockFlag {
+		runMock(port, cfg, overrides, logger)
+		return
+	}
+
+	logger.Infof("[STARTUP] Starting WhatsApp client...")
+
+	// Create database connection for storing session data
+	dbLog := waLog.Stdout("Database", "DEBUG", true)
+
+	// Create directory for database if it doesn't exist
+	if err := os.MkdirAll(store.Dir, 0755); err != nil {
+		logger.Errorf("[ERROR] Failed to create store directory: %v", err)
+		return
+	}
+
+	container, err := sqlstore.New("sqlite3", "file:"+store.Path("whatsapp.db")+"?_foreign_keys=on", dbLog)
+	if err != nil {
+		logger.Errorf("[ERROR] Failed to connect to database: %v", err)
+		return
+	}
+
+	// Get device store - This contains session information
+	deviceStore, err := container.GetFirstDevice()
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// No device exists, create one
+			deviceStore = container.NewDevice()
+			logger.Infof("[SETUP] Created new device")
+		} else {
+			logger.Errorf("[ERROR] Failed to get device: %v", err)
+			return
+		}
+	}
+
+	// Create client instance
+	client := whatsmeow.NewClient(deviceStore, logger)
+	if client == nil {
+		logger.Errorf("[ERROR] Failed to create WhatsApp client")
+		return
+	}
+	// The first app state sync carries the mute, archive and pin settings of every chat
+	client.EmitAppStateEventsOnFullSync = true
+
+	// Initialize message store
+	messageStore, err := store.New()
+	if err != nil {
+		logger.Errorf("[ERROR] Failed to initialize message store: %v", err)
+		return
+	}
+	defer messageStore.Close()
+	defer store.ClosePipelines()
+	// Keep warnings and errors in the store for /api/logs
+	defer logs.Capture(messageStore)()
+
+	// Send stored-message events to Kafka or NATS if configured
+	publish.Start(cfg.Publisher, messageStore)
+	embed.Start(cfg.Embeddings, messageStore)
+
+	// Mark the start so crashes while connected show up as drops in the connection history
+	session.LogConnectionEvent(messageStore, store.ConnEventStarted, "", logger)
+
+	bridge := app.New(client, messageStore, func() (config.Config, error) { return loadConfig(overrides) }, logger)
+
+	// If we're only listing groups or channels, do it once connected and exit
+	if *listGroupsFlag || *listChannelsFlag {
+		client.AddEventHandler(func(evt interface{}) {
+			if _, ok := evt.(*events.Connected); !ok {
+				return
+			}
+			if *listGroupsFlag {
+				if err := session.ListGroups(client); err != nil {
+					logger.Errorf("Failed to list groups: %v", err)
+				}
+			} else if err := session.ListChannels(client); err != nil {
+				logger.Errorf("Failed to list channels: %v", err)
+			}
+			client.Disconnect()
+			os.Exit(0)
+		})
+	}
+
+	// Setup event handling for messages and history sync
+	client.AddEventHandler(bridge.HandleEvent)
+
+	// Start REST API server; before connecting, so the QR code can be scanned from the browser
+	api.Start(client, messageStore, port)
+
+	// Connect to WhatsApp
+	if client.Store.ID == nil {
+		// No ID stored, this is a new client, need to pair with phone
+		fmt.Printf("\nTo pair from a browser, open http://localhost:%d/api/admin/pair\n", port)
+		if err := session.Pair(client, time.Duration(cfg.Timeouts.PairingSeconds)*time.Second); err != nil {
+			logger.Errorf("Failed to pair: %v", err)
+			return
+		}
+		fmt.Println("\nSuccessfully connected and authenticated!")
+	} else {
+		// Already logged in, just connect
+		err = client.Connect()
+		if err != nil {
+			logger.Errorf("Failed to connect: %v", err)
+			return
+		}
+	}
+
+	// Wait a moment for connection to stabilize
+	time.Sleep(2 * time.Second)
+
+	if !client.IsConnected() {
+		logger.Errorf("Failed to establish stable connection")
+		return
+	}
+
+	fmt.Println("\n✓ Connected to WhatsApp! Type 'help' for commands.")
+
+	// Problems such as a full disk are sent to the alerts chat from now on
+	notify.Configure(cfg.Alerts.ChatJID, session.AlertSender(client))
+
+	// Scheduled reports, such as the monthly activity report and photo books
+	reports.Start(session.ShutdownContext(), messageStore, session.AlertSender(client), session.DocumentSender(client))
+	topics.Start(messageStore, session.AnnouncementSender(client))
+	payments.Start(session.ShutdownContext(), messageStore, session.AlertSender(client), func() string { return session.OwnChat(client) })
+	forms.Start(session.ShutdownContext(), messageStore, session.ForwardSender(client), func() string { return session.OwnChat(client) })
+	absence.RegisterCommands(messageStore, session.AlertSender(client))
+	acks.Start(session.ShutdownContext(), messageStore, session.AnnouncementSender(client))
+
+	// Reconnect when WhatsApp stops answering although the socket looks connected
+	go session.WatchLiveness(client, messageStore, logger)
+
+	// A manual disconnect neither reports itself nor reconnects, so chaos drops do both as a lost connection would
+	go chaos.Disconnects(session.ShutdownContext(), func() {
+		client.Disconnect()
+		bridge.HandleEvent(&events.Disconnected{})
+	}, func() {
+		if err := client.Connect(); err != nil {
+			logger.Errorf("[CHAOS] Failed to reconnect: %v", err)
+		}
+	})
+
+	fmt.Printf("REST server is running on port %d. Press Ctrl+C to disconnect and exit.\n", port)
+
+	// Wait for termination signal
+	waitForExit(bridge)
+
+	// Let API requests in flight finish, then cancel whatever is still running
+	api.Stop(time.Duration(cfg.Timeouts.ShutdownSeconds) * time.Second)
+	session.Shutdown()
+
+	fmt.Println("Disconnecting...")
+	// Disconnect client
+	client.Disconnect()
+	session.LogConnectionEvent(messageStore, store.ConnEventShutdown, "", logger)
+	tracing.Stop(5 * time.Second)
+}
+
+// runMock serves the API on top of the in-memory fake until interrupted
+func runMock(port int, cfg config.Config, overrides overrideFlags, logger waLog.Logger) {
+	logger.Infof("[MOCK] Running without a WhatsApp connection, sends are captured and not delivered")
+	mock := session.NewMock()
+
+	messageStore, err := store.New()
+	if err != nil {
+		logger.Errorf("[ERROR] Failed to initialize message store: %v", err)
+		return
+	}
+	defer messageStore.Close()
+	defer store.ClosePipelines()
+	// Keep warnings and errors in the store for /api/logs
+	defer logs.Capture(messageStore)()
+	publish.Start(cfg.Publisher, messageStore)
+	embed.Start(cfg.Embeddings, messageStore)
+
+	notify.Configure(cfg.Alerts.ChatJID, session.AlertSender(mock))
+	reports.Start(session.ShutdownContext(), messageStore, session.AlertSender(mock), session.DocumentSender(mock))
+	topics.Start(messageStore, session.AnnouncementSender(mock))
+	payments.Start(session.ShutdownContext(), messageStore, session.AlertSender(mock), func() string { return session.OwnChat(mock) })
+	forms.Start(session.ShutdownContext(), messageStore, session.ForwardSender(mock), func() string { return session.OwnChat(mock) })
+	absence.RegisterCommands(messageStore, session.AlertSender(mock))
+	acks.Start(session.ShutdownContext(), messageStore, session.AnnouncementSender(mock))
+	api.RegisterMockHandlers(mock, messageStore, logger)
+	api.Start(mock, messageStore, port)
+
+	bridge := app.New(mock, messageStore, func() (config.Config, error) { return loadConfig(overrides) }, logger)
+	go chaos.Disconnects(session.ShutdownContext(), func() {
+		mock.SetConnected(false)
+		bridge.HandleEvent(&events.Disconnected{})
+	}, func() {
+		mock.SetConnected(true)
+		bridge.HandleEvent(&events.Connected{})
+	})
+
+	fmt.Printf("Mock REST server is running on port %d. Press Ctrl+C to exit.\n", port)
+	waitForExit(bridge)
+	api.Stop(time.Duration(cfg.Timeouts.ShutdownSeconds) * time.Second)
+	session.Shutdown()
+	tracing.Stop(5 * time.Second)
+}
+
+// waitForExit blocks until the bridge is interrupted or terminated. SIGHUP reloads the configuration.
+func waitForExit(bridge *app.App) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		fmt.Printf("[CONFIG] Reloading %s\n", configPath)
+		if err := bridge.Reload(); err != nil {
+			fmt.Printf("[CONFIG] Reload failed: %v\n", err)
+		}
+	}
+}
+
+// printMediaReport writes a human-readable verification report to stdout
+func printMediaReport(report *store.MediaVerifyReport) {
+	fmt.Println("\n=== Media Integrity Report ===")
+	fmt.Printf("Messages with media: %d\n", report.Referenced)
+	fmt.Printf("Files on disk:       %d\n", report.FilesOnDisk)
+	fmt.Printf("Missing files:       %d\n", len(report.Missing))
+	for _, missing := range report.Missing {
+		fmt.Printf("  - %s (message %s in %s)\n", missing.Path, missing.MessageID, missing.ChatJID)
+	}
+	fmt.Printf("Orphaned files:      %d\n", len(report.Orphans))
+	for _, orphan := range report.Orphans {
+		fmt.Printf("  - %s\n", orphan)
+	}
+	if report.Redownloaded > 0 {
+		fmt.Printf("Re-downloaded:       %d\n", report.Redownloaded)
+	}
+}
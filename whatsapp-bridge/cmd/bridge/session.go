@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// minPassphraseLength is the shortest passphrase accepted for session exports
+const minPassphraseLength = 8
+
+// sessionPassphrase returns the passphrase of a session export from JMK_SESSION_PASSPHRASE, or asks for
+// it on the terminal; twice when exporting, so a typo doesn't lock the session away
+func sessionPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("JMK_SESSION_PASSPHRASE"); passphrase != "" {
+		return passphrase, checkPassphrase(passphrase)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Session passphrase: ")
+	passphrase, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	passphrase = strings.TrimRight(passphrase, "\r\n")
+	if !confirm {
+		return passphrase, nil
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return "", err
+	}
+	fmt.Print("Repeat passphrase: ")
+	repeated, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	if strings.TrimRight(repeated, "\r\n") != passphrase {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// checkPassphrase rejects passphrases too short to protect a session
+func checkPassphrase(passphrase string) error {
+	if len(passphrase) < minPassphraseLength {
+		return fmt.Errorf("the passphrase must have at least %d characters", minPassphraseLength)
+	}
+	return nil
+}
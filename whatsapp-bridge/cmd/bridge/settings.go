@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// configPath is where the bridge reads config.json, relative to its working directory. With -data-dir it
+// is config.json in the data directory.
+var configPath = "../config.json"
+
+// overrideFlags collects repeated -set key=value flags
+type overrideFlags []string
+
+func (o *overrideFlags) String() string {
+	return strings.Join(*o, ", ")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected key=value, e.g. media.jpeg_quality=90")
+	}
+	*o = append(*o, value)
+	return nil
+}
+
+// apply sets each key on cfg; they take precedence over config.json and JMK_* environment variables
+func (o overrideFlags) apply(cfg *config.Config) error {
+	for _, override := range o {
+		key, value, _ := strings.Cut(override, "=")
+		if err := cfg.Override(strings.TrimSpace(key), value); err != nil {
+			return fmt.Errorf("invalid -set %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// loadConfig reads config.json with JMK_* environment variables and -set flags layered over it
+func loadConfig(overrides overrideFlags) (config.Config, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return cfg, err
+	}
+	if err := overrides.apply(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// useRedactionRules applies the redaction rules of cfg for a command that stores messages without
+// connecting, failing when config.json couldn't be loaded or a rule doesn't compile, so messages are never
+// stored unredacted by mistake
+func useRedactionRules(cfg config.Config, cfgErr error) error {
+	if cfgErr != nil {
+		return cfgErr
+	}
+	config.Set(cfg)
+	return routing.CompileRedactionRules(cfg.Privacy.RedactionRules)
+}
+
+// useDataDir points the databases at dir and the media directory at storePath, or at media in dir
+// when storePath is empty
+func useDataDir(dir, storePath string) {
+	if dir == "" {
+		dir = config.DefaultDataDir
+	}
+	store.Dir = dir
+	media.Dir = mediaDir(dir, storePath)
+}
+
+// mediaDir resolves media.store_path. A relative path is taken from the directory of config.json, as
+// the face detection service does, and kept relative to the working directory when it lies inside it,
+// so stored media paths stay short.
+func mediaDir(dataDir, storePath string) string {
+	if storePath == "" {
+		return filepath.Join(dataDir, "media")
+	}
+	if filepath.IsAbs(storePath) {
+		return filepath.Clean(storePath)
+	}
+	dir := filepath.Join(filepath.Dir(configPath), storePath)
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir
+	}
+	if rel, err := filepath.Rel(wd, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return dir
+}
+
+// checkWritable makes sure dir exists (or can be created) and accepts new files
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// relocateMedia moves media files to the configured media directory when it changed since the last
+// start, so stored messages keep their photos
+func relocateMedia(cfg config.Config) error {
+	messageStore, err := store.New()
+	if err != nil {
+		return err
+	}
+	defer messageStore.Close()
+	moved, err := messageStore.RelocateMedia(filepath.Join(cfg.DataDir, "media"), media.Dir)
+	if moved > 0 {
+		fmt.Printf("[MEDIA] Moved %d media files to %s\n", moved, media.Dir)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"whatsapp-client/internal/store"
+)
+
+// runUsers manages the users of the dashboard and API: user list, user add NAME ROLE, user remove NAME.
+// Adding an existing user changes their role and password.
+func runUsers(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected list, add NAME ROLE or remove NAME")
+	}
+	messageStore, err := store.New()
+	if err != nil {
+		return fmt.Errorf("failed to open message store: %v", err)
+	}
+	defer messageStore.Close()
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		users, err := messageStore.ListUsers()
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			fmt.Println("No users, the API is open unless api_keys are configured")
+		}
+		for _, user := range users {
+			fmt.Printf("%-20s %s\n", user.Name, user.Role)
+		}
+		return nil
+	case args[0] == "add" && len(args) == 3:
+		name, role := args[1], args[2]
+		if err := store.CheckUser(name, "", role); err != nil {
+			return err
+		}
+		password, err := userPassword(name)
+		if err != nil {
+			return err
+		}
+		if err := messageStore.SaveUser(name, password, role); err != nil {
+			return err
+		}
+		fmt.Printf("User %s saved as %s\n", name, role)
+		return nil
+	case args[0] == "remove" && len(args) == 2:
+		if err := messageStore.DeleteUser(args[1]); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", args[1], err)
+		}
+		fmt.Printf("User %s removed\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("expected list, add NAME ROLE or remove NAME")
+}
+
+// userPassword returns the password of a user being added from JMK_USER_PASSWORD, or asks for it on the
+// terminal
+func userPassword(name string) (string, error) {
+	if password := os.Getenv("JMK_USER_PASSWORD"); password != "" {
+		return password, nil
+	}
+	fmt.Printf("Password for %s: ", name)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
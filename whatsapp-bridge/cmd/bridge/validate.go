@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"whatsapp-client/internal/config"
+)
+
+// validateConfig checks the config file at path against the schema, then with the checks the bridge runs
+// at startup, and prints every problem. Schema problems come as path:line: so editors can jump to them.
+// Returns whether the file has no errors; warnings don't count.
+func validateConfig(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Cannot read %s: %v\n", path, err)
+		return false
+	}
+
+	schemaErrors := config.CheckSchema(data)
+	for _, schemaErr := range schemaErrors {
+		if schemaErr.Key == "" {
+			fmt.Printf("%s:%d: %s\n", path, schemaErr.Line, schemaErr.Message)
+		} else {
+			fmt.Printf("%s:%d: %s: %s\n", path, schemaErr.Line, schemaErr.Key, schemaErr.Message)
+		}
+	}
+
+	// Values the schema can't judge, such as JIDs, URLs and references between settings. JMK_* environment
+	// variables apply as they would when running the bridge.
+	cfg, err := config.Parse(data)
+	if err != nil {
+		if len(schemaErrors) == 0 {
+			fmt.Printf("%s: %v\n", path, err)
+		}
+		return false
+	}
+	problems := cfg.Validate()
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Printf("%s: warning: %s\n", path, problem)
+		} else {
+			fmt.Printf("%s: error: %s\n", path, problem)
+		}
+	}
+
+	valid := len(schemaErrors) == 0 && !config.HasErrors(problems)
+	if valid {
+		fmt.Printf("%s is valid\n", path)
+	}
+	return valid
+}
+
+// printSchema writes the JSON Schema of config.json, as published in config.schema.json
+func printSchema() error {
+	data, err := config.SchemaJSON()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
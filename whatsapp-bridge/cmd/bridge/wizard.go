@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/assets"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// setupConnectTimeout is how long the wizard waits for WhatsApp once the session is paired
+const setupConnectTimeout = time.Minute
+
+// wizardDestination is a child the wizard forwards photos of, in the order they were entered
+type wizardDestination struct {
+	key  string
+	dest config.DestinationConfig
+}
+
+// runWizard pairs the bridge with WhatsApp if needed, lists the groups the account has joined and asks
+// which of them to watch and where each child's photos go, then writes the answers into config.json
+// (the starter template, or the existing file with its other settings kept).
+func runWizard(dataDir string) error {
+	in := bufio.NewReader(os.Stdin)
+	base := assets.ConfigTemplate
+	if existing, err := os.ReadFile(configPath); err == nil {
+		replace, err := askYesNo(in, fmt.Sprintf("%s already exists. Replace its input groups and destinations?", configPath))
+		if err != nil {
+			return err
+		}
+		if !replace {
+			fmt.Printf("Leaving %s unchanged\n", configPath)
+			return nil
+		}
+		base = existing
+	}
+
+	// Another connection with the same session would take over from a running bridge
+	if cfg, err := config.Parse(base); err == nil && bridgeRunning(cfg.APIPort) {
+		return fmt.Errorf("the bridge is running on port %d, stop it before running init", cfg.APIPort)
+	}
+
+	client, err := connectForSetup()
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
+	groups, err := client.GetJoinedGroups()
+	if err != nil {
+		return fmt.Errorf("failed to get groups: %v", err)
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("the account hasn't joined any groups; join the kindergarten group on the phone and run init again")
+	}
+	sort.Slice(groups, func(i, j int) bool { return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name) })
+	fmt.Println("\nGroups of this account:")
+	for i, group := range groups {
+		fmt.Printf("  %2d. %s (%d members)\n", i+1, group.Name, len(group.Participants))
+	}
+
+	// Groups whose photos are matched against the reference photos
+	var inputGroups []string
+	for len(inputGroups) == 0 {
+		answer, err := ask(in, "\nGroups to watch for photos of your kids (numbers, separated by commas): ")
+		if err != nil {
+			return err
+		}
+		for _, field := range strings.Split(answer, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(groups) {
+				fmt.Printf("%q is not a number from the list\n", field)
+				inputGroups = nil
+				break
+			}
+			inputGroups = append(inputGroups, groups[n-1].JID.String())
+		}
+	}
+
+	// Where each child's photos are forwarded
+	var destinations []wizardDestination
+	for {
+		name, err := ask(in, "\nName of a child to forward photos of (empty when done): ")
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			break
+		}
+		key := strings.ToLower(strings.Join(strings.Fields(name), "_"))
+		target := ""
+		for target == "" {
+			answer, err := ask(in, fmt.Sprintf("Where should photos of %s go? A group number from the list, or a phone number with country code: ", name))
+			if err != nil {
+				return err
+			}
+			target = destinationTarget(answer, groups)
+			if target == "" {
+				fmt.Printf("%q is neither a number from the list nor a phone number\n", answer)
+			}
+		}
+		destinations = append(destinations, wizardDestination{key: key, dest: config.DestinationConfig{Name: name, Group: target}})
+	}
+	if len(destinations) == 0 {
+		fmt.Println("No destinations: photos are matched but not forwarded until you add some to config.json")
+	}
+
+	data, err := configWithSetup(base, bytes.Equal(base, assets.ConfigTemplate), inputGroups, destinations)
+	if err != nil {
+		return err
+	}
+	if !checkSetup(data) {
+		return fmt.Errorf("the new configuration has errors, %s was not written", configPath)
+	}
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", configPath, err)
+	}
+	fmt.Printf("\nWrote %s with %d input groups and %d destinations\n", configPath, len(inputGroups), len(destinations))
+
+	// The face detection service looks for reference photos in a folder per destination
+	referenceDir := filepath.Join(filepath.Dir(configPath), "reference_images")
+	for _, destination := range destinations {
+		if err := os.MkdirAll(filepath.Join(referenceDir, destination.key), 0755); err != nil {
+			return fmt.Errorf("failed to create reference photo folder: %v", err)
+		}
+	}
+
+	fmt.Println("\nNext steps:")
+	if len(destinations) > 0 {
+		fmt.Printf("  1. Put 5-10 photos of each child into its folder in %s\n", referenceDir)
+	} else {
+		fmt.Printf("  1. Add destinations to %s and reference photos of each child to %s\n", configPath, referenceDir)
+	}
+	fmt.Printf("  2. Start the bridge: %s\n", commandLine(dataDir))
+	fmt.Printf("  3. Check the setup with %s -doctor\n", commandLine(dataDir))
+	return nil
+}
+
+// connectForSetup opens the session store, pairs with the phone if there's no session yet, and waits
+// until the client is connected
+func connectForSetup() (*whatsmeow.Client, error) {
+	container, err := sqlstore.New("sqlite3", "file:"+store.Path("whatsapp.db")+"?_foreign_keys=on", waLog.Noop)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the session store: %v", err)
+	}
+	device, err := container.GetFirstDevice()
+	if err == sql.ErrNoRows {
+		device = container.NewDevice()
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get device: %v", err)
+	}
+
+	client := whatsmeow.NewClient(device, waLog.Noop)
+	connected := make(chan struct{}, 1)
+	client.AddEventHandler(func(evt interface{}) {
+		if _, ok := evt.(*events.Connected); ok {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	if client.Store.ID == nil {
+		fmt.Println("\nLink the bridge in WhatsApp on your phone: Settings > Linked devices > Link a device")
+		if err := session.Pair(client, 0); err != nil {
+			return nil, fmt.Errorf("failed to pair: %v", err)
+		}
+		fmt.Println("\nPaired with WhatsApp")
+	} else if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+
+	select {
+	case <-connected:
+		return client, nil
+	case <-time.After(setupConnectTimeout):
+		client.Disconnect()
+		return nil, fmt.Errorf("timed out connecting to WhatsApp")
+	}
+}
+
+// destinationTarget turns an answer into a destination group: a number from the group list, or a phone
+// number with country code. Returns "" if the answer is neither.
+func destinationTarget(answer string, groups []*types.GroupInfo) string {
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(groups) {
+		return groups[n-1].JID.String()
+	}
+	phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimPrefix(answer, "+"))
+	if len(phone) < 8 {
+		return ""
+	}
+	for _, r := range phone {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return "+" + phone
+}
+
+// configWithSetup replaces input_groups and destinations in a config file, and the example channel if it
+// is the template, and keeps every other setting where it is
+func configWithSetup(base []byte, fromTemplate bool, inputGroups []string, destinations []wizardDestination) ([]byte, error) {
+	var dests bytes.Buffer
+	dests.WriteString("{")
+	for i, destination := range destinations {
+		if i > 0 {
+			dests.WriteString(",")
+		}
+		key, _ := json.Marshal(destination.key)
+		value, err := json.Marshal(struct {
+			Name  string `json:"name"`
+			Group string `json:"group"`
+		}{destination.dest.Name, destination.dest.Group})
+		if err != nil {
+			return nil, err
+		}
+		dests.Write(key)
+		dests.WriteString(":")
+		dests.Write(value)
+	}
+	dests.WriteString("}")
+
+	groups, err := json.Marshal(inputGroups)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]json.RawMessage{"input_groups": groups, "destinations": dests.Bytes()}
+	if fromTemplate {
+		// The template's channel is a placeholder
+		values["input_channels"] = json.RawMessage("[]")
+	}
+	return setTopLevelKeys(base, values)
+}
+
+// setTopLevelKeys sets keys of a JSON object to the given values, in place for keys it has and at the end
+// for the others. The object is written indented with four spaces like the template.
+func setTopLevelKeys(data []byte, values map[string]json.RawMessage) ([]byte, error) {
+	type entry struct {
+		key   string
+		value json.RawMessage
+	}
+	var entries []entry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("%s is not a JSON object", configPath)
+	}
+	seen := map[string]bool{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", configPath, err)
+		}
+		key := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", configPath, err)
+		}
+		seen[key] = true
+		entries = append(entries, entry{key, value})
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if !seen[key] {
+			entries = append(entries, entry{key, nil})
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("{\n")
+	for i, e := range entries {
+		key, _ := json.Marshal(e.key)
+		fmt.Fprintf(&out, "    %s: ", key)
+		// Values that stay are copied as they were written, only new ones are indented
+		if replacement, ok := values[e.key]; ok {
+			if err := json.Indent(&out, replacement, "    ", "    "); err != nil {
+				return nil, err
+			}
+		} else {
+			out.Write(e.value)
+		}
+		if i < len(entries)-1 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString("}\n")
+	return out.Bytes(), nil
+}
+
+// checkSetup prints the problems of a configuration the wizard made and reports whether it has no errors
+func checkSetup(data []byte) bool {
+	cfg, err := config.Parse(data)
+	if err != nil {
+		fmt.Printf("[CONFIG] Error: %v\n", err)
+		return false
+	}
+	problems := cfg.Validate()
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Printf("[CONFIG] Warning: %s\n", problem)
+		} else {
+			fmt.Printf("[CONFIG] Error: %s\n", problem)
+		}
+	}
+	return !config.HasErrors(problems)
+}
+
+// errNoAnswer is returned when the input ends before the wizard has its answers
+var errNoAnswer = errors.New("setup cancelled, no more input")
+
+// ask prints a prompt and returns the answer typed, trimmed
+func ask(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	answer, err := in.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return "", errNoAnswer
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// askYesNo asks a question that defaults to no
+func askYesNo(in *bufio.Reader, question string) (bool, error) {
+	answer, err := ask(in, question+" [y/N] ")
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", err
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// /dev/null is a character device too, and stdin of containers run without -it
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+// Package absence tells a child's teacher the child won't come, from a templated message, and keeps a
+// record of every report for the parents.
+package absence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/commands"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+var (
+	// ErrUnknownChild is returned for a destination that isn't configured
+	ErrUnknownChild = errors.New("unknown destination")
+	// ErrNoTeacher is returned for a destination without a teacher_jid
+	ErrNoTeacher = errors.New("destination has no teacher_jid")
+	// ErrUnknownReason is returned for a reason other than sick or absent
+	ErrUnknownReason = errors.New("unknown reason")
+)
+
+// Request is an absence to report
+type Request struct {
+	// Destination of the child
+	Destination string
+	// sick or absent; empty means sick
+	Reason string
+	// Added to the message on a line of its own, e.g. "fever since last night"
+	Note string
+	// Day of the absence; zero means today
+	Day time.Time
+	// Only record the report instead of sending it
+	DryRun bool
+	// "api" or "command"
+	Source string
+}
+
+// Report sends the message of req to the child's teacher with send, unless it is a dry run, and records
+// it. A message that can't be sent isn't recorded.
+func Report(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, req Request) (store.AbsenceReport, error) {
+	dest, ok := config.Current().Destinations[req.Destination]
+	if !ok {
+		return store.AbsenceReport{}, ErrUnknownChild
+	}
+	if dest.TeacherJID == "" {
+		return store.AbsenceReport{}, ErrNoTeacher
+	}
+	if req.Reason == "" {
+		req.Reason = config.AbsenceSick
+	}
+	if !slices.Contains(config.AbsenceReasons, req.Reason) {
+		return store.AbsenceReport{}, ErrUnknownReason
+	}
+	now := time.Now().In(config.Current().Location())
+	if req.Day.IsZero() {
+		req.Day = now
+	}
+	name := dest.Name
+	if name == "" {
+		name = req.Destination
+	}
+
+	report := store.AbsenceReport{
+		Destination: req.Destination,
+		ChildName:   name,
+		ChatJID:     dest.TeacherJID,
+		Reason:      req.Reason,
+		Note:        strings.TrimSpace(req.Note),
+		Text:        message(name, req.Reason, req.Day, now),
+		Day:         req.Day.Format("2006-01-02"),
+		ReportedAt:  now,
+		Source:      req.Source,
+		DryRun:      routing.IsDryRunSend(dest.TeacherJID, req.DryRun),
+	}
+	if report.Note != "" {
+		report.Text += "\n" + report.Note
+	}
+	if report.DryRun {
+		fmt.Printf("[DRY-RUN] Would report %s %s on %s to %s: %q\n", req.Destination, req.Reason, report.Day, dest.TeacherJID, report.Text)
+	} else if err := send(ctx, dest.TeacherJID, report.Text); err != nil {
+		return store.AbsenceReport{}, err
+	}
+	id, err := messageStore.StoreAbsenceReport(report)
+	if err != nil {
+		// The teacher has the message; only the record is missing
+		fmt.Printf("[ABSENCE] Failed to record the report of %s on %s: %v\n", req.Destination, report.Day, err)
+	}
+	report.ID = id
+	fmt.Printf("[ABSENCE] Reported %s %s on %s to %s\n", req.Destination, req.Reason, report.Day, dest.TeacherJID)
+	return report, nil
+}
+
+// message words a report from the configured template of the reason, or else the locale's, which
+// names the day unless it is today
+func message(name, reason string, day, now time.Time) string {
+	date := i18n.Current().ShortDate(day)
+	if template := config.Current().Absence.Templates[reason]; template != "" {
+		return strings.NewReplacer("{name}", name, "{date}", date).Replace(template)
+	}
+	if day.Format("2006-01-02") == now.Format("2006-01-02") {
+		return i18n.T("absence."+reason, name)
+	}
+	return i18n.T("absence."+reason+"_on", name, date)
+}
+
+// findChild returns the destination a child goes by, its key or its name in any case
+func findChild(word string) (string, bool) {
+	destinations := config.Current().Destinations
+	for key, dest := range destinations {
+		if strings.EqualFold(key, word) || dest.Name != "" && strings.EqualFold(dest.Name, word) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// RegisterCommands offers reporting an absence in the owner's chat, sent with send: "sick <child>
+// [note]" and "absent <child> [note]"
+func RegisterCommands(messageStore *store.MessageStore, send notify.Sender) {
+	reportCommand := func(names []string, reason, help string) commands.Command {
+		return commands.Command{
+			Names: names,
+			Usage: "<child> [note]",
+			Help:  help,
+			Run: func(ctx context.Context, args []string) string {
+				if len(args) == 0 {
+					return i18n.T("absence.usage", names[0])
+				}
+				destination, ok := findChild(args[0])
+				if !ok {
+					return i18n.T("absence.unknown_child", args[0])
+				}
+				report, err := Report(ctx, messageStore, send, Request{
+					Destination: destination,
+					Reason:      reason,
+					Note:        strings.Join(args[1:], " "),
+					Source:      "command",
+				})
+				switch {
+				case errors.Is(err, ErrNoTeacher):
+					return i18n.T("absence.no_teacher", destination)
+				case err != nil:
+					fmt.Printf("[ABSENCE] Failed to report %s %s: %v\n", destination, reason, err)
+					return i18n.T("commands.failed")
+				case report.DryRun:
+					return i18n.T("absence.dry_run", report.Text)
+				}
+				return i18n.T("absence.sent", report.Text)
+			},
+		}
+	}
+	commands.Register(reportCommand([]string{"sick", "חולה"}, config.AbsenceSick, "absence.help_sick"))
+	commands.Register(reportCommand([]string{"absent", "נעדר"}, config.AbsenceAbsent, "absence.help_absent"))
+}
@@ -0,0 +1,49 @@
+// Package acks nudges the chats that didn't acknowledge a message forwarded to them by topic, by
+// replying to it or reacting on it, so an important notice isn't lost in a busy family chat.
+package acks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/reminders"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/topics"
+)
+
+// Start sends due nudges with send in the background until ctx is done
+func Start(ctx context.Context, messageStore *store.MessageStore, send topics.Sender) {
+	reminders.Start(ctx, "the acknowledgment nudges", func(ctx context.Context, now time.Time) {
+		sendNudges(ctx, messageStore, send, now)
+	})
+}
+
+// sendNudges sends the forward of every announcement due at now again, headed by a request to
+// acknowledge it; a reply to or reaction on the nudge acknowledges it as well
+func sendNudges(ctx context.Context, messageStore *store.MessageStore, send topics.Sender, now time.Time) {
+	cfg := config.Current().Acknowledgments
+	if !cfg.Enabled || cfg.MaxNudges == 0 {
+		return
+	}
+	due, err := messageStore.DueNudges(now.Add(-time.Duration(cfg.NudgeHours)*time.Hour), cfg.MaxNudges)
+	if err != nil {
+		fmt.Printf("[ACKS] Failed to get due nudges: %v\n", err)
+		return
+	}
+	for _, announcement := range due {
+		sendCtx, cancel := reminders.SendTimeout(ctx)
+		sentID, err := send(sendCtx, announcement.ChatJID, i18n.T("acks.nudge")+"\n\n"+announcement.Text, "", "")
+		cancel()
+		if err != nil {
+			fmt.Printf("[ACKS] Failed to nudge %s about %s: %v\n", announcement.ChatJID, announcement.MessageID, err)
+			continue
+		}
+		if err := messageStore.RecordNudge(announcement.ID, sentID, now); err != nil {
+			fmt.Printf("[ACKS] Failed to record the nudge about %s: %v\n", announcement.MessageID, err)
+		}
+		fmt.Printf("[ACKS] Nudged %s about %s (%d of %d)\n", announcement.ChatJID, announcement.MessageID, announcement.Nudges+1, cfg.MaxNudges)
+	}
+}
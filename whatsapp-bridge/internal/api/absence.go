@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"whatsapp-client/internal/absence"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// ReportAbsenceRequest is the body of POST /api/actions/report-absence
+type ReportAbsenceRequest struct {
+	// Destination of the child
+	Destination string `json:"destination"`
+	// sick (default) or absent
+	Reason string `json:"reason,omitempty"`
+	// Added to the message on a line of its own
+	Note string `json:"note,omitempty"`
+	// Day of the absence as YYYY-MM-DD; today by default
+	Date   string `json:"date,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// handleReportAbsence serves POST /api/actions/report-absence, telling a child's teacher the child
+// won't come. Keys given the destination may report its absences.
+func handleReportAbsence(client session.WhatsAppClient, messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/actions/report-absence from %s\n", r.Method, r.RemoteAddr)
+		var req ReportAbsenceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		dest, ok := config.Current().Destinations[req.Destination]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %q", req.Destination))
+			return
+		}
+		if !authorizeChat(w, r, dest.Group) {
+			return
+		}
+		var day time.Time
+		if req.Date != "" {
+			var err error
+			if day, err = time.ParseInLocation("2006-01-02", req.Date, config.Current().Location()); err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid date, use YYYY-MM-DD")
+				return
+			}
+		}
+
+		report, err := absence.Report(r.Context(), messageStore, session.AlertSender(client), absence.Request{
+			Destination: req.Destination,
+			Reason:      req.Reason,
+			Note:        req.Note,
+			Day:         day,
+			DryRun:      req.DryRun,
+			Source:      "api",
+		})
+		switch {
+		case errors.Is(err, absence.ErrNoTeacher):
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Destination %q has no teacher_jid", req.Destination))
+			return
+		case errors.Is(err, absence.ErrUnknownReason):
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid reason, use sick or absent")
+			return
+		case err != nil:
+			fmt.Printf("[ERROR] [%s] Failed to report the absence of %s: %v\n", requestID(r), req.Destination, err)
+			writeSendError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleGetAbsences serves GET /api/absences?destination=&from=&to=, the absences reported to the
+// teachers, latest first
+func handleGetAbsences(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/absences from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		for _, name := range []string{"from", "to"} {
+			if value := query.Get(name); value != "" {
+				if _, err := time.Parse("2006-01-02", value); err != nil {
+					writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid %s, use YYYY-MM-DD", name))
+					return
+				}
+			}
+		}
+		destination := query.Get("destination")
+		if destination != "" {
+			dest, ok := config.Current().Destinations[destination]
+			if !ok {
+				writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %q", destination))
+				return
+			}
+			if !authorizeChat(w, r, dest.Group) {
+				return
+			}
+		}
+
+		reports, err := messageStore.GetAbsenceReports(destination, query.Get("from"), query.Get("to"))
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get absence reports: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get absence reports")
+			return
+		}
+		// Keys given some destinations only see theirs
+		if key := requestKey(r); key != nil {
+			visible := reports[:0]
+			for _, report := range reports {
+				if key.AllowsChat(config.Current().Destinations[report.Destination].Group) {
+					visible = append(visible, report)
+				}
+			}
+			reports = visible
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reports); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+)
+
+// secretParams are query parameters that carry credentials; they are never logged
+var secretParams = []string{"key", "code", "state", "token"}
+
+// textParams are query parameters that may carry message text or names; they are logged only unredacted
+var textParams = []string{"message", "text", "caption", "content", "q", "query", "search", "name"}
+
+// phoneNumber matches phone numbers and the user part of JIDs, with the server that follows if any. Group
+// and channel IDs are long digit runs too, but they don't identify a person.
+var phoneNumber = regexp.MustCompile(`(?:\+|%2B)?\d{7,}(@[a-z.]+)?`)
+
+// maskPhoneNumbers replaces all but the last two digits of phone numbers in s
+func maskPhoneNumbers(s string) string {
+	return phoneNumber.ReplaceAllStringFunc(s, func(match string) string {
+		number, server, _ := strings.Cut(match, "@")
+		if server == "g.us" || server == "newsletter" {
+			return match
+		}
+		masked := "…" + number[len(number)-2:]
+		if server != "" {
+			masked += "@" + server
+		}
+		return masked
+	})
+}
+
+// redactTarget returns the path and query of a request as they may be logged
+func redactTarget(u *url.URL, unredacted bool) string {
+	path := u.Path
+	if !unredacted {
+		path = maskPhoneNumbers(path)
+	}
+	if u.RawQuery == "" {
+		return path
+	}
+	query := u.Query()
+	for name, values := range query {
+		for i, value := range values {
+			switch {
+			case slices.Contains(secretParams, name):
+				values[i] = "[redacted]"
+			case unredacted:
+			case slices.Contains(textParams, name):
+				values[i] = "[redacted]"
+			default:
+				values[i] = maskPhoneNumbers(value)
+			}
+		}
+	}
+	target, _ := url.QueryUnescape(query.Encode())
+	return path + "?" + target
+}
+
+// accessEntry collects what the handlers learn about a request for its access log line
+type accessEntry struct {
+	// Name of the API key or user the request was authenticated as
+	principal string
+}
+
+type accessEntryKey struct{}
+
+// setAccessPrincipal records who made a request, for the access log
+func setAccessPrincipal(ctx context.Context, name string) {
+	if entry, ok := ctx.Value(accessEntryKey{}).(*accessEntry); ok {
+		entry.principal = name
+	}
+}
+
+// statusRecorder remembers the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection, which streaming responses flush
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLog writes a line per API request when access_log is enabled: method, path, status, latency,
+// response size, and the API key or user that made it. It wraps authentication, so rejected requests
+// are logged too.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Current().AccessLog
+		if !cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		entry := &accessEntry{principal: "-"}
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry)))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		fmt.Printf("[ACCESS] [%s] %s %s %d %dms %dB by %s\n", requestID(r), r.Method, redactTarget(r.URL, cfg.Unredacted),
+			recorder.status, time.Since(start).Milliseconds(), recorder.bytes, entry.principal)
+	})
+}
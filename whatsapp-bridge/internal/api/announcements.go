@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleGetAnnouncements serves GET /api/announcements?status=open|acknowledged|all&chat_jid=, the
+// messages forwarded by topic with who acknowledged them, newest first. Only the open ones are listed
+// by default.
+func handleGetAnnouncements(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/announcements from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		var acknowledged *bool
+		switch query.Get("status") {
+		case "", "open":
+			acknowledged = new(bool)
+		case "acknowledged":
+			acknowledged = new(bool)
+			*acknowledged = true
+		case "all":
+		default:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid status, use open, acknowledged or all")
+			return
+		}
+		chatJID := query.Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		announcements, err := messageStore.GetAnnouncements(chatJID, acknowledged)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get announcements: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get announcements")
+			return
+		}
+		// Scoped keys only see the forwards to their own chats
+		if key := requestKey(r); key != nil {
+			visible := announcements[:0]
+			for _, announcement := range announcements {
+				if key.AllowsChat(announcement.ChatJID) {
+					visible = append(visible, announcement)
+				}
+			}
+			announcements = visible
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(announcements); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
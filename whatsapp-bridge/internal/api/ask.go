@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"whatsapp-client/internal/ask"
+	"whatsapp-client/internal/store"
+)
+
+// AskRequest is the body of POST /api/ask
+type AskRequest struct {
+	Question string `json:"question"`
+	// Optional chat and inclusive YYYY-MM-DD dates to search in
+	ChatJID string `json:"chat_jid"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	// Optional topic the messages are tagged with, e.g. health
+	Topic string `json:"topic"`
+	// Most messages to return, up to ask.max_messages
+	Limit int `json:"limit"`
+}
+
+// handleAsk serves POST /api/ask: the stored messages that best match a question and, with ask.llm
+// configured, an answer written from them. Keys scoped to chats only get messages of their chats.
+func handleAsk(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/ask from %s\n", r.Method, r.RemoteAddr)
+		var req AskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		if strings.TrimSpace(req.Question) == "" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "question is required")
+			return
+		}
+		filter, err := store.ParseExportFilter(req.ChatJID, req.From, req.To, req.Topic)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		question := ask.Question{Text: req.Question, Filter: filter, Limit: req.Limit}
+		if filter.ChatJID != "" {
+			if !authorizeChat(w, r, filter.ChatJID) {
+				return
+			}
+		} else if key := requestKey(r); key != nil {
+			question.Allow = key.AllowsChat
+		}
+
+		answer, err := ask.Ask(r.Context(), messageStore, question)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to search messages: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to search messages")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(answer); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/tracing"
+)
+
+type apiKeyContextKey struct{}
+
+// requestOperation classifies a request into the operation it needs
+func requestOperation(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/admin/"), strings.HasPrefix(r.URL.Path, "/api/mock/"), strings.HasPrefix(r.URL.Path, "/api/debug/"), r.URL.Path == "/api/logs":
+		return config.OperationAdmin
+	case strings.HasPrefix(r.URL.Path, "/debug/"):
+		// net/http/pprof also registers itself under /debug/pprof/ on the default mux
+		return config.OperationAdmin
+	case r.Method == http.MethodDelete:
+		return config.OperationDelete
+	case r.URL.Path == "/api/send", strings.HasPrefix(r.URL.Path, "/api/actions/"):
+		return config.OperationSend
+	case r.Method == http.MethodPost && (strings.HasPrefix(r.URL.Path, "/api/reference-photos/") || strings.HasPrefix(r.URL.Path, "/api/face-matches")):
+		// Reference photos, match decisions and the feedback on them decide what is forwarded to a destination
+		return config.OperationSend
+	case r.Method == http.MethodPut && (strings.HasPrefix(r.URL.Path, "/api/payments/") || strings.HasPrefix(r.URL.Path, "/api/forms/")):
+		// Marking a payment request paid or a form handled stops its reminders
+		return config.OperationSend
+	default:
+		return config.OperationRead
+	}
+}
+
+// requestAPIKey returns the token sent with a request. Feed readers and calendar apps can't set
+// headers, so the token may also be passed as ?key=.
+func requestAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
+
+// findAPIKey looks up the configured key matching token
+func findAPIKey(token string) *config.APIKeyConfig {
+	if token == "" {
+		return nil
+	}
+	keys := config.Current().APIKeys
+	for i := range keys {
+		if subtle.ConstantTimeCompare([]byte(keys[i].Key), []byte(token)) == 1 {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// publicPaths may be requested without credentials: logging in is how a user gets them
+var publicPaths = []string{"/api/login", "/api/oidc/login", "/api/oidc/callback"}
+
+// requireAuth authenticates every request against the configured API keys and the sessions of users
+// who logged in, and checks that the key or the user's role was granted the requested operation.
+// Without configured keys or users the API stays open as before.
+func requireAuth(messageStore *store.MessageStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hasUsers, err := messageStore.HasUsers()
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to look up users: %v\n", requestID(r), err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to authenticate")
+			return
+		}
+		if (len(config.Current().APIKeys) == 0 && !hasUsers) || slices.Contains(publicPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := requestAPIKey(r)
+		key := findAPIKey(token)
+		var user *store.User
+		if key == nil && hasUsers {
+			key, user = sessionKey(messageStore, r, token)
+		}
+		if key == nil {
+			fmt.Printf("[AUTH] [%s] Rejected unauthenticated %s request to %s from %s\n", requestID(r), r.Method, r.URL.Path, r.RemoteAddr)
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+			return
+		}
+		setAccessPrincipal(r.Context(), key.Name)
+		if operation := requestOperation(r); !key.Allows(operation) {
+			fmt.Printf("[AUTH] [%s] Key %q is not allowed to %s (%s %s)\n", requestID(r), key.Name, operation, r.Method, r.URL.Path)
+			writeError(w, r, http.StatusForbidden, CodeForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withUser(context.WithValue(r.Context(), apiKeyContextKey{}, key), user)))
+	})
+}
+
+// requestKey returns the API key a request was authenticated with, or that stands for the user who
+// made it, or nil if no keys or users are configured
+func requestKey(r *http.Request) *config.APIKeyConfig {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*config.APIKeyConfig)
+	return key
+}
+
+// authorizeChat checks that the request's API key may access chatJID and writes a 403 if not.
+// An empty chatJID means "all chats", which only unscoped keys may access.
+func authorizeChat(w http.ResponseWriter, r *http.Request, chatJID string) bool {
+	key := requestKey(r)
+	if key == nil || key.AllowsChat(chatJID) {
+		return true
+	}
+	if chatJID == "" {
+		fmt.Printf("[AUTH] [%s] Key %q must name a chat for %s %s\n", requestID(r), key.Name, r.Method, r.URL.Path)
+	} else {
+		fmt.Printf("[AUTH] [%s] Key %q is not allowed to access %s\n", requestID(r), key.Name, chatJID)
+	}
+	writeError(w, r, http.StatusForbidden, CodeForbidden, "Forbidden")
+	return false
+}
+
+// requestID returns the correlation ID of an API request
+func requestID(r *http.Request) string {
+	return tracing.RequestIDFromContext(r.Context())
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/store"
+)
+
+// BackupResponse represents the response for the backup API
+type BackupResponse struct {
+	Success bool   `json:"success"`
+	Path    string `json:"path"`
+}
+
+// handleBackup serves POST /api/admin/backup?include_media=true
+func handleBackup(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("[HTTP] Received %s request to /api/admin/backup from %s\n", r.Method, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	archivePath, err := store.CreateBackup("backups", r.URL.Query().Get("include_media") == "true")
+	if err != nil {
+		fmt.Printf("[ERROR] Backup failed: %v\n", err)
+		writeErrorDetails(w, r, http.StatusInternalServerError, CodeInternal, "Backup failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := BackupResponse{
+		Success: true,
+		Path:    archivePath,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+	}
+}
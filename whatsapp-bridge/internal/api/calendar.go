@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/calendar"
+	"whatsapp-client/internal/store"
+)
+
+// handleCalendarFeed serves GET /api/calendar.ics?chat_jid= for calendar subscriptions
+func handleCalendarFeed(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/calendar.ics from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		chatJID := r.URL.Query().Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+		events, err := messageStore.GetCalendarEvents(chatJID)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get calendar events: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get calendar events")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write(calendar.BuildICS(events))
+	}
+}
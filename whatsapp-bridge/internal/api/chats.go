@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleGetChats serves GET /api/chats?muted=&archived=&pinned=, the stored chats with their mute,
+// archive and pin state on the phone
+func handleGetChats(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/chats from %s\n", r.Method, r.RemoteAddr)
+		var filter store.ChatStateFilter
+		for name, field := range map[string]**bool{"muted": &filter.Muted, "archived": &filter.Archived, "pinned": &filter.Pinned} {
+			v := r.URL.Query().Get(name)
+			if v == "" {
+				continue
+			}
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid %s, use true or false", name))
+				return
+			}
+			*field = &parsed
+		}
+
+		chats, err := messageStore.GetChatStates(filter)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get chats: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get chats")
+			return
+		}
+		// Scoped keys only see their own chats
+		if key := requestKey(r); key != nil {
+			visible := chats[:0]
+			for _, chat := range chats {
+				if key.AllowsChat(chat.JID) {
+					visible = append(visible, chat)
+				}
+			}
+			chats = visible
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(chats); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/tracing"
+)
+
+// allowedOrigin returns the value of Access-Control-Allow-Origin for origin, or "" if it may not call the API
+func allowedOrigin(cfg config.CORSConfig, origin string) string {
+	if slices.Contains(cfg.AllowedOrigins, "*") {
+		return "*"
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// cors answers preflight requests and adds CORS headers for allowed origins. It runs before
+// authentication, because browsers send preflights without credentials. Requests from other origins
+// are served unchanged; the browser withholds the response from the page.
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Current().CORS
+		origin := r.Header.Get("Origin")
+		if len(cfg.AllowedOrigins) == 0 || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := allowedOrigin(cfg, origin)
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Expose-Headers", tracing.RequestIDHeader)
+		}
+
+		// Preflight: the browser asks whether the real request may be sent
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed == "" {
+				writeError(w, r, http.StatusForbidden, CodeForbidden, "Origin not allowed")
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAgeSeconds))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/store"
+)
+
+// defaultConsumer reads the stream when neither an API key nor ?consumer= names one
+const defaultConsumer = "default"
+
+// AdvanceCursorRequest represents the request body for the cursor API
+type AdvanceCursorRequest struct {
+	ChatJID   string `json:"chat_jid"`
+	MessageID string `json:"message_id"`
+}
+
+// consumerName identifies who is reading: the name of the request's API key, so every token keeps its
+// own cursors. Without configured keys consumers name themselves with ?consumer=.
+func consumerName(r *http.Request) string {
+	if key := requestKey(r); key != nil {
+		return key.Name
+	}
+	if consumer := r.URL.Query().Get("consumer"); consumer != "" {
+		return consumer
+	}
+	return defaultConsumer
+}
+
+// handleGetUnread serves GET /api/unread?chat_jid=, the consumer's cursor and unread count per chat
+func handleGetUnread(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/unread from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		chatJID := r.URL.Query().Get("chat_jid")
+		if chatJID != "" && !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		counts, err := messageStore.GetUnreadCounts(consumerName(r), chatJID)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get unread counts: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get unread counts")
+			return
+		}
+
+		// Keys restricted to some chats only see those
+		if key := requestKey(r); key != nil && chatJID == "" {
+			allowed := []store.UnreadCount{}
+			for _, count := range counts {
+				if key.AllowsChat(count.ChatJID) {
+					allowed = append(allowed, count)
+				}
+			}
+			counts = allowed
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(counts); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleAdvanceCursor serves POST /api/cursors, marking a chat as read up to a message for the consumer
+func handleAdvanceCursor(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/cursors from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		var req AdvanceCursorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		if req.ChatJID == "" || req.MessageID == "" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "chat_jid and message_id are required")
+			return
+		}
+		if !authorizeChat(w, r, req.ChatJID) {
+			return
+		}
+
+		consumer := consumerName(r)
+		count, err := messageStore.AdvanceCursor(consumer, req.ChatJID, req.MessageID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Message not found")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to advance cursor of %s: %v\n", consumer, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to advance cursor")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(count); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// RuntimeSnapshot is the state of the bridge process, served by /api/debug/runtime
+type RuntimeSnapshot struct {
+	GoVersion     string `json:"go_version"`
+	NumCPU        int    `json:"num_cpu"`
+	GOMAXPROCS    int    `json:"gomaxprocs"`
+	Goroutines    int    `json:"goroutines"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	// Whether a garbage collection ran before the snapshot, so the heap holds only live memory
+	Collected bool        `json:"collected"`
+	Memory    MemoryStats `json:"memory"`
+	// Size in bytes of each database, including its write-ahead log
+	Databases map[string]int64 `json:"databases"`
+	// Connection pools of the message store and of the isolated pipelines opened so far
+	Connections map[string]store.ConnectionStats `json:"connections"`
+	Queues      session.Queues                   `json:"queues"`
+}
+
+// MemoryStats are the memory figures of runtime.MemStats that tell growth apart from garbage, in bytes
+type MemoryStats struct {
+	// Memory of reachable objects and of garbage not collected yet
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapIdle    uint64 `json:"heap_idle"`
+	HeapObjects uint64 `json:"heap_objects"`
+	// Idle heap memory returned to the operating system
+	HeapReleased uint64 `json:"heap_released"`
+	StackInuse   uint64 `json:"stack_inuse"`
+	// Everything the process got from the operating system
+	Sys uint64 `json:"sys"`
+	// Bytes allocated since the start, including freed ones
+	TotalAlloc uint64 `json:"total_alloc"`
+	// Heap size at which the next garbage collection starts
+	NextGC       uint64     `json:"next_gc"`
+	NumGC        uint32     `json:"num_gc"`
+	GCPauseTotal int64      `json:"gc_pause_total_ms"`
+	LastGCAt     *time.Time `json:"last_gc_at,omitempty"`
+}
+
+// handleRuntime serves GET /api/debug/runtime?gc=true. With gc, memory is collected and returned to the
+// operating system first, so what the heap still holds after a history sync is what is really retained.
+func handleRuntime(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/debug/runtime from %s\n", r.Method, r.RemoteAddr)
+		collect := r.URL.Query().Get("gc") == "true"
+		if collect {
+			debug.FreeOSMemory()
+		}
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		snapshot := RuntimeSnapshot{
+			GoVersion:     runtime.Version(),
+			NumCPU:        runtime.NumCPU(),
+			GOMAXPROCS:    runtime.GOMAXPROCS(0),
+			Goroutines:    runtime.NumGoroutine(),
+			UptimeSeconds: int64(time.Since(session.Started()).Seconds()),
+			Collected:     collect,
+			Memory: MemoryStats{
+				HeapAlloc:    mem.HeapAlloc,
+				HeapInuse:    mem.HeapInuse,
+				HeapIdle:     mem.HeapIdle,
+				HeapObjects:  mem.HeapObjects,
+				HeapReleased: mem.HeapReleased,
+				StackInuse:   mem.StackInuse,
+				Sys:          mem.Sys,
+				TotalAlloc:   mem.TotalAlloc,
+				NextGC:       mem.NextGC,
+				NumGC:        mem.NumGC,
+				GCPauseTotal: time.Duration(mem.PauseTotalNs).Milliseconds(),
+			},
+			Databases:   store.DatabaseSizes(),
+			Connections: map[string]store.ConnectionStats{"messages.db": messageStore.ConnectionStats()},
+			Queues:      session.QueueDepths(),
+		}
+		if mem.LastGC != 0 {
+			last := time.Unix(0, int64(mem.LastGC))
+			snapshot.Memory.LastGCAt = &last
+		}
+		for pipeline, pipelineStore := range store.PipelineStores() {
+			snapshot.Connections["pipelines/"+pipeline+"/messages.db"] = pipelineStore.ConnectionStats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// registerPprof serves the profiles of net/http/pprof under /api/debug/pprof/, where they are gated like
+// the admin endpoints. pprof's index looks up profiles under /debug/pprof/, so the /api prefix is cut
+// off for it.
+func registerPprof() {
+	http.Handle("/api/debug/pprof/", http.StripPrefix("/api", http.HandlerFunc(pprof.Index)))
+	http.HandleFunc("/api/debug/pprof/cmdline", pprof.Cmdline)
+	http.HandleFunc("/api/debug/pprof/profile", pprof.Profile)
+	http.HandleFunc("/api/debug/pprof/symbol", pprof.Symbol)
+	http.HandleFunc("/api/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// DeleteResponse represents the response for the data deletion API
+type DeleteResponse struct {
+	Success         bool     `json:"success"`
+	MessagesDeleted int64    `json:"messages_deleted"`
+	FilesDeleted    int      `json:"files_deleted"`
+	FileErrors      []string `json:"file_errors,omitempty"`
+}
+
+// writeDeletionResult removes the media of a finished deletion and writes the JSON response
+func writeDeletionResult(w http.ResponseWriter, r *http.Request, mediaPaths []string, deleted int64, err error) {
+	if err == sql.ErrNoRows {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+		return
+	}
+	if errors.Is(err, store.ErrInvalidSender) {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Give the sender as a phone number or JID")
+		return
+	}
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to delete data: %v\n", err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete data")
+		return
+	}
+
+	filesDeleted, fileErrors := store.RemoveMediaFiles(mediaPaths)
+	response := DeleteResponse{
+		Success:         len(fileErrors) == 0,
+		MessagesDeleted: deleted,
+		FilesDeleted:    filesDeleted,
+		FileErrors:      fileErrors,
+	}
+	fmt.Printf("[DELETE] Removed %d messages and %d media files\n", deleted, filesDeleted)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Success {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+	}
+}
+
+// deleteFrom runs a deletion on each of stores and adds up what it removed; it is only not found when no
+// store had anything
+func deleteFrom(stores []*store.MessageStore, deletion func(*store.MessageStore) ([]string, int64, error)) ([]string, int64, error) {
+	var mediaPaths []string
+	var deleted int64
+	found := false
+	for _, messageStore := range stores {
+		paths, n, err := deletion(messageStore)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		found = true
+		mediaPaths = append(mediaPaths, paths...)
+		deleted += n
+	}
+	if !found {
+		return nil, 0, sql.ErrNoRows
+	}
+	return mediaPaths, deleted, nil
+}
+
+// chatStores returns the store a chat's messages are kept in and, for isolated pipelines, the shared
+// store too, which keeps the payment requests, forms and forwards found in them. Without a chat, the
+// message may be in any store.
+func chatStores(messageStore *store.MessageStore, chatJID string) ([]*store.MessageStore, error) {
+	if chatJID == "" {
+		return routing.Stores(messageStore)
+	}
+	chatStore, err := routing.ChatStore(messageStore, chatJID)
+	if err != nil || chatStore == messageStore {
+		return []*store.MessageStore{messageStore}, err
+	}
+	return []*store.MessageStore{chatStore, messageStore}, nil
+}
+
+// registerDeletionHandlers adds the data deletion endpoints to the REST server
+func registerDeletionHandlers(messageStore *store.MessageStore) {
+	// Delete a chat with all its messages and media
+	http.HandleFunc("DELETE /api/chats/{jid}", func(w http.ResponseWriter, r *http.Request) {
+		jid := r.PathValue("jid")
+		fmt.Printf("[HTTP] Received DELETE request for chat %s from %s\n", jid, r.RemoteAddr)
+		if !authorizeChat(w, r, jid) {
+			return
+		}
+		stores, err := chatStores(messageStore, jid)
+		if err != nil {
+			writeDeletionResult(w, r, nil, 0, err)
+			return
+		}
+		mediaPaths, deleted, err := deleteFrom(stores, func(s *store.MessageStore) ([]string, int64, error) { return s.DeleteChat(jid) })
+		writeDeletionResult(w, r, mediaPaths, deleted, err)
+	})
+
+	// Delete a single message (optionally scoped with ?chat_jid=) and its media
+	http.HandleFunc("DELETE /api/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		fmt.Printf("[HTTP] Received DELETE request for message %s from %s\n", id, r.RemoteAddr)
+		chatJID := r.URL.Query().Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+		stores, err := chatStores(messageStore, chatJID)
+		if err != nil {
+			writeDeletionResult(w, r, nil, 0, err)
+			return
+		}
+		mediaPaths, deleted, err := deleteFrom(stores, func(s *store.MessageStore) ([]string, int64, error) { return s.DeleteMessage(id, chatJID) })
+		writeDeletionResult(w, r, mediaPaths, deleted, err)
+	})
+
+	// Purge everything a sender posted across all chats, of every pipeline
+	http.HandleFunc("DELETE /api/senders/{sender}", func(w http.ResponseWriter, r *http.Request) {
+		sender := r.PathValue("sender")
+		fmt.Printf("[HTTP] Received DELETE request for sender %s from %s\n", sender, r.RemoteAddr)
+		// A purge spans every chat, so keys restricted to some chats can't run it
+		if !authorizeChat(w, r, "") {
+			return
+		}
+		stores, err := routing.Stores(messageStore)
+		if err != nil {
+			writeDeletionResult(w, r, nil, 0, err)
+			return
+		}
+		mediaPaths, deleted, err := deleteFrom(stores, func(s *store.MessageStore) ([]string, int64, error) { return s.DeleteMessagesBySender(sender) })
+		writeDeletionResult(w, r, mediaPaths, deleted, err)
+	})
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/session"
+)
+
+// Error codes of the JSON error envelope, stable for clients to branch on
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeNotConnected     = "not_connected"
+	CodeInvalidJID       = "invalid_jid"
+	CodeMediaTooLarge    = "media_too_large"
+	CodeInvalidMedia     = "invalid_media"
+	CodeUploadFailed     = "upload_failed"
+	CodeSendFailed       = "send_failed"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+)
+
+// ErrorResponse is the body of every failed API request
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// APIError describes what went wrong. Details carries extra context, e.g. the underlying error.
+type APIError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// writeError replies with the JSON error envelope
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeErrorDetails(w, r, status, code, message, nil)
+}
+
+// writeErrorDetails replies with the JSON error envelope including details
+func writeErrorDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	response := ErrorResponse{Error: APIError{Code: code, Message: message, Details: details, RequestID: requestID(r)}}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		fmt.Printf("[ERROR] Failed to encode error response: %v\n", err)
+	}
+}
+
+// methodNotAllowed replies that the endpoint doesn't support the request's method
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Sprintf("Method %s not allowed", r.Method))
+}
+
+// writeSendError maps a failed send to its status and code
+func writeSendError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code, message := http.StatusInternalServerError, CodeSendFailed, "Sending the message failed"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status, code, message = http.StatusGatewayTimeout, CodeTimeout, "WhatsApp did not respond in time"
+	case errors.Is(err, session.ErrNotConnected):
+		status, code, message = http.StatusServiceUnavailable, CodeNotConnected, "Not connected to WhatsApp"
+	case errors.Is(err, session.ErrInvalidJID):
+		status, code, message = http.StatusBadRequest, CodeInvalidJID, "Recipient is not a phone number, user JID or group JID"
+	case errors.Is(err, session.ErrMediaTooLarge):
+		status, code, message = http.StatusRequestEntityTooLarge, CodeMediaTooLarge, fmt.Sprintf("Media files may be at most %d MB, documents %d MB", session.MaxMediaSize>>20, session.MaxDocumentSize>>20)
+	case errors.Is(err, media.ErrTooManyPixels):
+		status, code, message = http.StatusRequestEntityTooLarge, CodeMediaTooLarge, fmt.Sprintf("Images may have at most %d megapixels", media.MaxPixels/1_000_000)
+	case errors.Is(err, session.ErrInvalidMedia):
+		status, code, message = http.StatusBadRequest, CodeInvalidMedia, "Media file can't be read or isn't a supported image"
+	case errors.Is(err, session.ErrUploadFailed):
+		status, code, message = http.StatusBadGateway, CodeUploadFailed, "Uploading the media to WhatsApp failed"
+	case errors.Is(err, session.ErrSendFailed):
+		status = http.StatusBadGateway
+	}
+	writeErrorDetails(w, r, status, code, message, err.Error())
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/store"
+)
+
+// maxEventsLimit caps how many events a single /api/events request returns
+const maxEventsLimit = 1000
+
+// EventsResponse represents the response for the events API
+type EventsResponse struct {
+	Events []store.Event `json:"events"`
+	// Seq to pass as after_seq to continue; unchanged when there were no new events
+	LastSeq int64 `json:"last_seq"`
+}
+
+// handleGetEvents serves GET /api/events?after_seq=&chat_jid=&limit=, the change log of stored messages
+func handleGetEvents(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/events from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		chatJID := query.Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		var afterSeq int64
+		if v := query.Get("after_seq"); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || parsed < 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid after_seq")
+				return
+			}
+			afterSeq = parsed
+		}
+		limit := 100
+		if v := query.Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+				return
+			}
+			limit = min(parsed, maxEventsLimit)
+		}
+
+		events, err := messageStore.GetEvents(afterSeq, chatJID, limit)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get events: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get events")
+			return
+		}
+
+		response := EventsResponse{Events: events, LastSeq: afterSeq}
+		if len(events) > 0 {
+			response.LastSeq = events[len(events)-1].Seq
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleExport serves GET /api/export?chat_jid=&from=&to=&topic=&format=jsonl|csv[&manifest=true]
+func handleExport(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/export from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		filter, err := store.ParseExportFilter(query.Get("chat_jid"), query.Get("from"), query.Get("to"), query.Get("topic"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		if !authorizeChat(w, r, filter.ChatJID) {
+			return
+		}
+		format := query.Get("format")
+		if format == "" {
+			format = "jsonl"
+		}
+		if format != "jsonl" && format != "csv" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Unsupported format, use jsonl or csv")
+			return
+		}
+
+		// The manifest alone is returned as JSON, without the messages themselves
+		if query.Get("manifest") == "true" {
+			manifest, err := messageStore.Export(filter, format, io.Discard)
+			if err != nil {
+				fmt.Printf("[ERROR] Export failed: %v\n", err)
+				writeError(w, r, http.StatusInternalServerError, CodeInternal, "Export failed")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(manifest)
+			return
+		}
+
+		contentType := "application/x-ndjson"
+		if format == "csv" {
+			contentType = "text/csv"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"messages.%s\"", format))
+		if _, err := messageStore.Export(filter, format, w); err != nil {
+			// Headers are already sent at this point, so the error can only be logged
+			fmt.Printf("[ERROR] Export failed: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// Bounds of threshold tuning, unless the face filter asks for others
+const (
+	// How far a tuned threshold may move from the configured one
+	defaultThresholdMaxShift = 0.1
+	// Reported mistakes a destination needs before its threshold moves
+	defaultThresholdMinMistakes = 5
+)
+
+// RecordFaceMatchesRequest is the body of POST /api/face-matches: the face filter's decisions on a photo,
+// one per destination it compared the photo with
+type RecordFaceMatchesRequest struct {
+	MediaPath string            `json:"media_path"`
+	Matches   []store.FaceMatch `json:"matches"`
+}
+
+// FaceMatchFeedbackRequest is the body of POST /api/face-matches/{id}/feedback
+type FaceMatchFeedbackRequest struct {
+	// wrong, missed or correct, or empty to clear the feedback
+	Feedback string `json:"feedback"`
+}
+
+// handleRecordFaceMatches serves POST /api/face-matches, where the face filter service records each
+// decision with its distance and threshold, so parents can review them
+func handleRecordFaceMatches(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-matches from %s\n", r.Method, r.RemoteAddr)
+		var req RecordFaceMatchesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		if req.MediaPath == "" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "media_path is required")
+			return
+		}
+
+		mediaPath, messageID, chatJID := bridgeMediaPath(messageStore, req.MediaPath)
+		for i := range req.Matches {
+			if req.Matches[i].Destination == "" {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Every match needs a destination")
+				return
+			}
+			req.Matches[i].MediaPath, req.Matches[i].MessageID, req.Matches[i].ChatJID = mediaPath, messageID, chatJID
+		}
+		if err := messageStore.RecordFaceMatches(req.Matches); err != nil {
+			fmt.Printf("[ERROR] Failed to record face matches: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to record face matches")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "recorded": len(req.Matches)})
+	}
+}
+
+// handleGetFaceMatches serves GET /api/face-matches?destination=&matched=&feedback=&limit=100, the most
+// recent decisions for review
+func handleGetFaceMatches(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-matches from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		filter := store.FaceMatchFilter{Destination: query.Get("destination"), Feedback: query.Get("feedback"), Limit: 100}
+		group := ""
+		if filter.Destination != "" {
+			dest, ok := config.Current().Destinations[filter.Destination]
+			if !ok {
+				writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %s", filter.Destination))
+				return
+			}
+			group = dest.Group
+		}
+		if !authorizeChat(w, r, group) {
+			return
+		}
+
+		if v := query.Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+				return
+			}
+			filter.Limit = parsed
+		}
+		if v := query.Get("matched"); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid matched")
+				return
+			}
+			filter.Matched = &parsed
+		}
+		switch filter.Feedback {
+		case "", "none", store.FeedbackWrong, store.FeedbackMissed, store.FeedbackCorrect:
+		default:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid feedback, use wrong, missed, correct or none")
+			return
+		}
+
+		matches, err := messageStore.GetFaceMatches(filter)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get face matches: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get face matches")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(matches); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleFaceMatchFeedback serves POST /api/face-matches/{id}/feedback, where parents mark a forwarded
+// photo as wrong, a photo that wasn't forwarded as missed, or a decision as correct
+func handleFaceMatchFeedback(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-matches/%s/feedback from %s\n", r.Method, r.PathValue("id"), r.RemoteAddr)
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid match ID")
+			return
+		}
+		var req FaceMatchFeedbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+
+		match, err := messageStore.GetFaceMatch(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No face match %d", id))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get face match %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get face match")
+			return
+		}
+		// Keys given the destination may review its decisions
+		if !authorizeChat(w, r, config.Current().Destinations[match.Destination].Group) {
+			return
+		}
+
+		switch {
+		case req.Feedback == store.FeedbackWrong && !match.Matched:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Only forwarded photos can be wrong, mark this one as missed or correct")
+			return
+		case req.Feedback == store.FeedbackMissed && match.Matched:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "This photo was forwarded, mark it as wrong or correct")
+			return
+		case req.Feedback != "" && req.Feedback != store.FeedbackWrong && req.Feedback != store.FeedbackMissed && req.Feedback != store.FeedbackCorrect:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid feedback, use wrong, missed or correct")
+			return
+		}
+
+		if err := messageStore.SetFaceMatchFeedback(id, req.Feedback); err != nil {
+			fmt.Printf("[ERROR] Failed to record feedback on face match %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to record feedback")
+			return
+		}
+		fmt.Printf("[FACES] Feedback on match %d of %s: %q\n", id, match.Destination, req.Feedback)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "feedback": req.Feedback})
+	}
+}
+
+// handleGetFaceThresholds serves GET /api/face-matches/thresholds?base=0.5, the distance threshold of
+// each destination tuned from the feedback on its decisions, for the face filter service. base is the
+// configured threshold; max_shift and min_mistakes bound the tuning.
+func handleGetFaceThresholds(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-matches/thresholds from %s\n", r.Method, r.RemoteAddr)
+		if !authorizeChat(w, r, "") {
+			return
+		}
+		query := r.URL.Query()
+		base, err := strconv.ParseFloat(query.Get("base"), 64)
+		if err != nil || base <= 0 {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "base, the configured threshold, is required")
+			return
+		}
+		maxShift := defaultThresholdMaxShift
+		if v := query.Get("max_shift"); v != "" {
+			if maxShift, err = strconv.ParseFloat(v, 64); err != nil || maxShift < 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid max_shift")
+				return
+			}
+		}
+		minMistakes := defaultThresholdMinMistakes
+		if v := query.Get("min_mistakes"); v != "" {
+			if minMistakes, err = strconv.Atoi(v); err != nil || minMistakes < 1 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid min_mistakes")
+				return
+			}
+		}
+
+		tunings, err := messageStore.TuneThresholds(base, maxShift, minMistakes)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to tune face thresholds: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to tune thresholds")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tunings); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
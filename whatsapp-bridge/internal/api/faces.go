@@ -0,0 +1,319 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// ReplaceFaceClustersRequest is the body of PUT /api/admin/face-clusters, the result of a clustering run
+// of the face filter service
+type ReplaceFaceClustersRequest struct {
+	Clusters []struct {
+		Faces []store.Face `json:"faces"`
+	} `json:"clusters"`
+}
+
+// ReplaceFaceClustersResponse tells the face filter service what was stored
+type ReplaceFaceClustersResponse struct {
+	Clusters int `json:"clusters"`
+	Faces    int `json:"faces"`
+	// Clusters that kept the label their faces had before
+	LabelsKept int `json:"labels_kept"`
+}
+
+// LabelFaceClusterRequest is the body of POST /api/admin/face-clusters/{id}/label
+type LabelFaceClusterRequest struct {
+	// A destination key, or empty to clear the label
+	Label string `json:"label"`
+}
+
+// bridgeMediaPath finds the file the face filter service saw at path as the bridge knows it, with the
+// message it belongs to. The service reads the media directory under its own relative path.
+func bridgeMediaPath(messageStore *store.MessageStore, path string) (string, string, string) {
+	if id, chatJID, ok := routing.FindMessageByMedia(messageStore, path); ok {
+		if file, err := messageStore.GetMediaFile(id, chatJID); err == nil {
+			return file.Path, id, chatJID
+		}
+		return path, id, chatJID
+	}
+	name := media.FileKey(path)
+	if hash := strings.TrimSuffix(name, filepath.Ext(name)); len(hash) >= 4 {
+		if blob := media.BlobPath(hash, filepath.Ext(name)); fileExists(blob) {
+			return blob, "", ""
+		}
+	}
+	return path, "", ""
+}
+
+// fileExists reports whether path is an existing file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// faceURL is the API path serving a face cropped from its photo
+func faceURL(clusterID, faceID int64) string {
+	return fmt.Sprintf("/api/face-clusters/%d/faces/%d", clusterID, faceID)
+}
+
+// handleReplaceFaceClusters serves PUT /api/admin/face-clusters, where the face filter service uploads
+// the clusters it found across all stored photos
+func handleReplaceFaceClusters(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/face-clusters from %s\n", r.Method, r.RemoteAddr)
+		var req ReplaceFaceClustersRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+
+		var clusters [][]store.Face
+		response := ReplaceFaceClustersResponse{}
+		for _, cluster := range req.Clusters {
+			if len(cluster.Faces) == 0 {
+				continue
+			}
+			for i := range cluster.Faces {
+				face := &cluster.Faces[i]
+				if face.MediaPath == "" {
+					writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Every face needs a media_path")
+					return
+				}
+				face.MediaPath, face.MessageID, face.ChatJID = bridgeMediaPath(messageStore, face.MediaPath)
+			}
+			clusters = append(clusters, cluster.Faces)
+			response.Clusters++
+			response.Faces += len(cluster.Faces)
+		}
+
+		kept, err := messageStore.ReplaceFaceClusters(clusters)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to store face clusters: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to store face clusters")
+			return
+		}
+		response.LabelsKept = kept
+		fmt.Printf("[FACES] Stored %d clusters of %d faces, %d kept their label\n", response.Clusters, response.Faces, kept)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleGetFaceClusters serves GET /api/face-clusters?samples=6, the clusters largest first with a few
+// of their faces each
+func handleGetFaceClusters(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-clusters from %s\n", r.Method, r.RemoteAddr)
+		// Clusters span the photos of all chats
+		if !authorizeChat(w, r, "") {
+			return
+		}
+		samples := 6
+		if v := r.URL.Query().Get("samples"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid samples")
+				return
+			}
+			samples = parsed
+		}
+
+		clusters, err := messageStore.GetFaceClusters(samples)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get face clusters: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get face clusters")
+			return
+		}
+		for i := range clusters {
+			for j := range clusters[i].Faces {
+				clusters[i].Faces[j].URL = faceURL(clusters[i].ID, clusters[i].Faces[j].ID)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(clusters); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// parseClusterID reads the {id} of a face cluster path, writing a 400 if it isn't a number
+func parseClusterID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid cluster ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// handleGetFaceCluster serves GET /api/face-clusters/{id}, a cluster with all its faces
+func handleGetFaceCluster(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-clusters/%s from %s\n", r.Method, r.PathValue("id"), r.RemoteAddr)
+		if !authorizeChat(w, r, "") {
+			return
+		}
+		id, ok := parseClusterID(w, r)
+		if !ok {
+			return
+		}
+
+		cluster, err := messageStore.GetFaceCluster(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No face cluster %d", id))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get face cluster %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get face cluster")
+			return
+		}
+		for i := range cluster.Faces {
+			cluster.Faces[i].URL = faceURL(cluster.ID, cluster.Faces[i].ID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(cluster); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleGetFace serves GET /api/face-clusters/{id}/faces/{face}, a face cropped from its photo as a JPEG
+func handleGetFace(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-clusters/%s/faces/%s from %s\n", r.Method, r.PathValue("id"), r.PathValue("face"), r.RemoteAddr)
+		id, ok := parseClusterID(w, r)
+		if !ok {
+			return
+		}
+		faceID, err := strconv.ParseInt(r.PathValue("face"), 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid face ID")
+			return
+		}
+
+		face, err := messageStore.GetFace(id, faceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "No such face")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get face %d: %v\n", faceID, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get face")
+			return
+		}
+		if !authorizeChat(w, r, face.ChatJID) {
+			return
+		}
+
+		data, err := os.ReadFile(face.MediaPath)
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Photo of the face is missing")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to read %s: %v\n", face.MediaPath, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to read photo")
+			return
+		}
+		crop, err := media.FaceCrop(data, face.Box)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to crop face %d: %v\n", faceID, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to crop face")
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", mediaCacheControl)
+		w.Write(crop)
+	}
+}
+
+// handleLabelFaceCluster serves POST /api/admin/face-clusters/{id}/label, telling the bridge whose faces
+// a cluster holds. The face filter service then matches photos against them like reference photos.
+func handleLabelFaceCluster(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/face-clusters/%s/label from %s\n", r.Method, r.PathValue("id"), r.RemoteAddr)
+		id, ok := parseClusterID(w, r)
+		if !ok {
+			return
+		}
+		var req LabelFaceClusterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		if _, ok := config.Current().Destinations[req.Label]; req.Label != "" && !ok {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("No destination %s, label clusters with a destination key", req.Label))
+			return
+		}
+
+		err := messageStore.LabelFaceCluster(id, req.Label)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No face cluster %d", id))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to label face cluster %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to label face cluster")
+			return
+		}
+		if req.Label == "" {
+			fmt.Printf("[FACES] Cleared the label of cluster %d\n", id)
+		} else {
+			fmt.Printf("[FACES] Labelled cluster %d as %s\n", id, req.Label)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "label": req.Label})
+	}
+}
+
+// handleGetFaceLabels serves GET /api/face-clusters/labels?per_label=20, the encodings of labelled
+// faces per destination, which the face filter service adds to the reference photos
+func handleGetFaceLabels(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/face-clusters/labels from %s\n", r.Method, r.RemoteAddr)
+		if !authorizeChat(w, r, "") {
+			return
+		}
+		perLabel := 20
+		if v := r.URL.Query().Get("per_label"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid per_label")
+				return
+			}
+			perLabel = parsed
+		}
+
+		encodings, err := messageStore.FaceLabelEncodings(perLabel)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get labelled faces: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get labelled faces")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(encodings); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
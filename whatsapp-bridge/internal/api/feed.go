@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// feedEntryLimit caps the number of entries in a chat feed
+const feedEntryLimit = 50
+
+// atomFeed and its children describe the subset of RFC 4287 the bridge emits
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedText is the text of an entry: the message, or the caption of a photo
+func feedText(item store.FeedItem) string {
+	if item.Content != "" {
+		return item.Content
+	}
+	return item.Caption
+}
+
+// feedTitle builds a one-line entry title from the message text
+func feedTitle(item store.FeedItem) string {
+	title := strings.TrimSpace(strings.SplitN(feedText(item), "\n", 2)[0])
+	if title == "" {
+		if item.MediaType == "image" {
+			return "New photo"
+		}
+		return "New " + item.MediaType
+	}
+	if runes := []rune(title); len(runes) > 80 {
+		title = string(runes[:80]) + "…"
+	}
+	return title
+}
+
+// buildAtomFeed renders the items of a chat as an Atom document; baseURL is used for self and media links
+// and linkQuery (e.g. "?key=...") is appended to them
+func buildAtomFeed(chatJID, chatName, baseURL, linkQuery string, items []store.FeedItem) ([]byte, error) {
+	escapedJID := url.PathEscape(chatJID)
+	feed := atomFeed{
+		ID:      "urn:whatsapp:chat:" + chatJID,
+		Title:   chatName,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: baseURL + "/feeds/" + escapedJID + ".atom" + linkQuery, Rel: "self", Type: "application/atom+xml"},
+	}
+	if len(items) > 0 {
+		feed.Updated = items[0].Timestamp.UTC().Format(time.RFC3339)
+	}
+
+	for _, item := range items {
+		entry := atomEntry{
+			ID:      "urn:whatsapp:message:" + chatJID + ":" + item.ID,
+			Title:   feedTitle(item),
+			Updated: item.Timestamp.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: item.Sender},
+			Content: atomContent{Type: "html"},
+		}
+
+		var body strings.Builder
+		if item.MediaPath != "" {
+			if _, err := os.Stat(item.MediaPath); err == nil {
+				mediaURL := baseURL + "/feeds/" + escapedJID + "/media/" + url.PathEscape(item.ID) + linkQuery
+				entry.Links = append(entry.Links, atomLink{Href: mediaURL, Rel: "enclosure"})
+				if item.MediaType == "image" {
+					fmt.Fprintf(&body, "<p><img src=\"%s\" alt=\"photo\"/></p>", html.EscapeString(mediaURL))
+				} else if item.MediaType == "video" && item.PosterPath != "" {
+					// Readers rarely play video inline, so the poster frame links to the file
+					posterURL := baseURL + "/feeds/" + escapedJID + "/poster/" + url.PathEscape(item.ID) + linkQuery
+					fmt.Fprintf(&body, "<p><a href=\"%s\"><img src=\"%s\" alt=\"video\"/></a></p>", html.EscapeString(mediaURL), html.EscapeString(posterURL))
+				}
+			}
+		}
+		if text := feedText(item); text != "" {
+			body.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br/>") + "</p>")
+		}
+		entry.Content.Body = body.String()
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// feedBaseURL reconstructs the externally visible base URL of the request
+func feedBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// registerFeedHandlers adds the per-chat Atom feeds. Only monitored groups and channels are published.
+func registerFeedHandlers(messageStore *store.MessageStore) {
+	// Atom feed of a chat: /feeds/{jid}.atom
+	http.HandleFunc("GET /feeds/{file}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received GET request to /feeds/%s from %s\n", r.PathValue("file"), r.RemoteAddr)
+		chatJID, ok := strings.CutSuffix(r.PathValue("file"), ".atom")
+		if !ok || !routing.IsMonitored(chatJID) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+			return
+		}
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		items, err := messageStore.GetFeedItems(chatJID, feedEntryLimit)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get feed items: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to build feed")
+			return
+		}
+		chatName := messageStore.ChatName(chatJID)
+		if chatName == "" {
+			chatName = chatJID
+		}
+
+		// Feed readers fetch photos without headers, so a key given in the URL is passed on to the media links
+		linkQuery := ""
+		if key := r.URL.Query().Get("key"); key != "" {
+			linkQuery = "?key=" + url.QueryEscape(key)
+		}
+		data, err := buildAtomFeed(chatJID, chatName, feedBaseURL(r), linkQuery, items)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to build feed: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to build feed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write(data)
+	})
+
+	// Media referenced by feed entries
+	http.HandleFunc("GET /feeds/{jid}/media/{id}", func(w http.ResponseWriter, r *http.Request) {
+		chatJID := r.PathValue("jid")
+		if !routing.IsMonitored(chatJID) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+			return
+		}
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+		path, err := messageStore.GetFeedMediaPath(chatJID, r.PathValue("id"))
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filepath.Base(path)))
+		http.ServeFile(w, r, path)
+	})
+
+	// Poster frames of videos in feed entries
+	http.HandleFunc("GET /feeds/{jid}/poster/{id}", func(w http.ResponseWriter, r *http.Request) {
+		chatJID := r.PathValue("jid")
+		if !routing.IsMonitored(chatJID) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+			return
+		}
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+		file, err := messageStore.GetMediaFile(r.PathValue("id"), chatJID)
+		if err != nil || file.PosterPath == "" {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+			return
+		}
+		http.ServeFile(w, r, file.PosterPath)
+	})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/store"
+)
+
+// FormUpdateRequest is the body of PUT /api/forms/{id}
+type FormUpdateRequest struct {
+	// Handled, which stops the reminders, or open again
+	Acknowledged bool `json:"acknowledged"`
+}
+
+// handleGetForms serves GET /api/forms?status=open|acknowledged|all&chat_jid=, the forms found in
+// monitored chats, newest first. Only the open ones are listed by default.
+func handleGetForms(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/forms from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		var acknowledged *bool
+		switch query.Get("status") {
+		case "", "open":
+			acknowledged = new(bool)
+		case "acknowledged":
+			acknowledged = new(bool)
+			*acknowledged = true
+		case "all":
+		default:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid status, use open, acknowledged or all")
+			return
+		}
+		chatJID := query.Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		forms, err := messageStore.GetForms(chatJID, acknowledged)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get forms: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get forms")
+			return
+		}
+		// Scoped keys only see their own chats
+		if key := requestKey(r); key != nil {
+			visible := forms[:0]
+			for _, form := range forms {
+				if key.AllowsChat(form.ChatJID) {
+					visible = append(visible, form)
+				}
+			}
+			forms = visible
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(forms); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleUpdateForm serves PUT /api/forms/{id}, marking a form handled or open again
+func handleUpdateForm(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/forms/%s from %s\n", r.Method, r.PathValue("id"), r.RemoteAddr)
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid form ID")
+			return
+		}
+		var req FormUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+
+		form, err := messageStore.GetForm(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No form %d", id))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get form %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get form")
+			return
+		}
+		if !authorizeChat(w, r, form.ChatJID) {
+			return
+		}
+
+		if err := messageStore.AcknowledgeForm(id, req.Acknowledged); err != nil {
+			fmt.Printf("[ERROR] Failed to update form %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update form")
+			return
+		}
+		fmt.Printf("[FORMS] Form #%d marked acknowledged=%t\n", id, req.Acknowledged)
+
+		form, err = messageStore.GetForm(id)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get form %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get form")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(form); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
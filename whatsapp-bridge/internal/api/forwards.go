@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleGetForwards serves GET /api/forwards?dry_run=true|false&limit=100
+func handleGetForwards(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/forwards from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+		// The ledger spans all destinations
+		if !authorizeChat(w, r, "") {
+			return
+		}
+
+		query := r.URL.Query()
+		limit := 100
+		if v := query.Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+				return
+			}
+			limit = parsed
+		}
+		var dryRun *bool
+		if v := query.Get("dry_run"); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid dry_run")
+				return
+			}
+			dryRun = &parsed
+		}
+
+		forwards, err := messageStore.GetForwards(dryRun, limit)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get forwards: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get forwards")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(forwards); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
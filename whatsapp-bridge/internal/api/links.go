@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleGetLinks serves GET /api/links?chat_jid=&limit=
+func handleGetLinks(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/links from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		limit := 100
+		if v := r.URL.Query().Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+				return
+			}
+			limit = parsed
+		}
+
+		chatJID := r.URL.Query().Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+		links, err := messageStore.GetLinks(chatJID, limit)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get links: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get links")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(links); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
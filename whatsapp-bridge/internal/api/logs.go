@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// handleGetLogs serves GET /api/logs?level=warn|error&since=24h&limit=200. since is a time (RFC 3339) or
+// how far back to go (a duration such as 90m or 24h); the last day by default.
+func handleGetLogs(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/logs from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+
+		level := query.Get("level")
+		if level == "" {
+			level = config.LogLevelWarn
+		}
+		if level != config.LogLevelWarn && level != config.LogLevelError {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid level, use warn or error")
+			return
+		}
+		since := time.Now().Add(-24 * time.Hour)
+		if v := query.Get("since"); v != "" {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				since = t
+			} else if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				since = time.Now().Add(-d)
+			} else {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid since, use a time such as 2024-05-01T08:00:00Z or a duration such as 24h")
+				return
+			}
+		}
+		limit := 200
+		if v := query.Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 || parsed > 1000 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit, use 1 to 1000")
+				return
+			}
+			limit = parsed
+		}
+
+		entries, err := messageStore.GetLogEntries(level, since, limit)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get log entries: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get log entries")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
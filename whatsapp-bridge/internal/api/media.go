@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// handleVerifyMedia serves POST /api/admin/verify?redownload=true
+func handleVerifyMedia(client session.WhatsAppClient, messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/verify from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		// Missing files are fetched again with the stored media keys on request
+		var fetch func(store.MediaRef) ([]byte, error)
+		if r.URL.Query().Get("redownload") == "true" {
+			fetch = func(ref store.MediaRef) ([]byte, error) {
+				return session.DownloadMediaRef(r.Context(), client, ref)
+			}
+		}
+
+		report, err := messageStore.VerifyMedia(media.Dir, fetch)
+		if err != nil {
+			fmt.Printf("[ERROR] Media verification failed: %v\n", err)
+			writeErrorDetails(w, r, http.StatusInternalServerError, CodeInternal, "Media verification failed", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"whatsapp-client/internal/store"
+)
+
+// mediaCacheControl lets browsers keep media: a message's file never changes, and deleted messages
+// are gone from the API anyway
+const mediaCacheControl = "private, max-age=31536000, immutable"
+
+// handleGetMedia serves GET /api/media/{id}?chat_jid=&poster=, the stored media file of a message, or
+// with poster=true the poster frame of a video. Range requests are supported, so videos can be
+// scrubbed in a browser without downloading them whole, and ETag and Last-Modified make repeated
+// requests cheap.
+func handleGetMedia(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		fmt.Printf("[HTTP] Received %s request to /api/media/%s from %s\n", r.Method, id, r.RemoteAddr)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		file, err := messageStore.GetMediaFile(id, r.URL.Query().Get("chat_jid"))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Message has no media")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get media of %s: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get media")
+			return
+		}
+		if !authorizeChat(w, r, file.ChatJID) {
+			return
+		}
+
+		path, name, mimeType := file.Path, file.Filename, file.MimeType
+		if r.URL.Query().Get("poster") == "true" {
+			if file.PosterPath == "" {
+				writeError(w, r, http.StatusNotFound, CodeNotFound, "Message has no poster frame")
+				return
+			}
+			path, name, mimeType = file.PosterPath, "", "image/jpeg"
+		}
+
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Media file is missing, see /api/admin/verify")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to open media file %s: %v\n", path, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to read media")
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to stat media file %s: %v\n", path, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to read media")
+			return
+		}
+
+		if name == "" {
+			name = filepath.Base(path)
+		}
+		if mimeType != "" {
+			w.Header().Set("Content-Type", mimeType)
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+		w.Header().Set("Cache-Control", mediaCacheControl)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+
+		// ServeContent answers Range, If-Range, If-None-Match and If-Modified-Since requests
+		http.ServeContent(w, r, name, info.ModTime(), f)
+	}
+}
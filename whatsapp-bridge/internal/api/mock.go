@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// RegisterMockHandlers exposes message and participant change injection and the captured sends
+func RegisterMockHandlers(mock *session.Mock, messageStore *store.MessageStore, logger waLog.Logger) {
+	http.HandleFunc("/api/mock/messages", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/mock/messages from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+		var req session.MockMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		evt, err := mock.Inject(req)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		// Handled synchronously, so the message is stored by the time the response arrives
+		session.HandleMessage(mock, messageStore, evt, logger)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": evt.Info.ID})
+	})
+
+	http.HandleFunc("/api/mock/participants", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/mock/participants from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+		var req session.MockParticipantsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		evt, err := mock.InjectParticipants(req)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		session.HandleGroupInfo(mock, messageStore, evt, logger)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	})
+
+	http.HandleFunc("/api/mock/sent", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/mock/sent from %s\n", r.Method, r.RemoteAddr)
+		switch r.Method {
+		case http.MethodGet:
+			sent := mock.Sent()
+			if to := r.URL.Query().Get("to"); to != "" {
+				var filtered []session.MockSend
+				for _, send := range sent {
+					if strings.EqualFold(send.To, to) {
+						filtered = append(filtered, send)
+					}
+				}
+				sent = filtered
+			}
+			if sent == nil {
+				sent = []session.MockSend{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(sent)
+		case http.MethodDelete:
+			mock.Reset()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, r)
+		}
+	})
+}
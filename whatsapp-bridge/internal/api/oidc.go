@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/oidc"
+	"whatsapp-client/internal/store"
+)
+
+// oidcStateCookie ties the browser that started a login to the one coming back from the provider
+const oidcStateCookie = "jmk_oidc_state"
+
+// handleOIDCLogin serves GET /api/oidc/login, sending the browser to the OIDC provider to log in
+func handleOIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/oidc/login from %s\n", r.Method, r.RemoteAddr)
+		cfg := config.Current().OIDC
+		if !cfg.Enabled() {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "OIDC login is not configured")
+			return
+		}
+		authURL, state, err := oidc.Begin(r.Context(), cfg)
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to start OIDC login: %v\n", requestID(r), err)
+			writeError(w, r, http.StatusBadGateway, CodeInternal, "The login provider can't be reached")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/api/oidc/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   isHTTPS(r),
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// handleOIDCCallback serves GET /api/oidc/callback, where the provider sends the browser back. The user
+// gets the role their groups map to and a session like a password login.
+func handleOIDCCallback(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/oidc/callback from %s\n", r.Method, r.RemoteAddr)
+		cfg := config.Current().OIDC
+		if !cfg.Enabled() {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "OIDC login is not configured")
+			return
+		}
+		query := r.URL.Query()
+		if reason := query.Get("error"); reason != "" {
+			fmt.Printf("[AUTH] [%s] OIDC login refused by the provider: %s %s\n", requestID(r), reason, query.Get("error_description"))
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Login refused by the provider")
+			return
+		}
+		state := query.Get("state")
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil || state == "" || cookie.Value != state {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Login was started in another browser or expired, start again")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/api/oidc/", MaxAge: -1, HttpOnly: true})
+
+		identity, err := oidc.Finish(r.Context(), cfg, state, query.Get("code"))
+		if err != nil {
+			fmt.Printf("[AUTH] [%s] OIDC login failed: %v\n", requestID(r), err)
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Login failed")
+			return
+		}
+		role := cfg.Role(identity.Name, identity.Groups)
+		if role == "" {
+			fmt.Printf("[AUTH] [%s] %s logged in through OIDC but has no role (groups: %v)\n", requestID(r), identity.Name, identity.Groups)
+			writeError(w, r, http.StatusForbidden, CodeForbidden, fmt.Sprintf("%s may not use the bridge", identity.Name))
+			return
+		}
+		user, err := messageStore.SaveSignedInUser(identity.Name, role)
+		if errors.Is(err, store.ErrPasswordUser) {
+			fmt.Printf("[AUTH] [%s] %s logged in through OIDC but is a user with a password\n", requestID(r), identity.Name)
+			writeError(w, r, http.StatusForbidden, CodeForbidden, fmt.Sprintf("%s logs in with a password", identity.Name))
+			return
+		}
+		if errors.Is(err, store.ErrNoAdmin) {
+			fmt.Printf("[AUTH] [%s] %s logged in through OIDC as %s but there is no other admin\n", requestID(r), identity.Name, role)
+			writeError(w, r, http.StatusForbidden, CodeForbidden, fmt.Sprintf("%s may log in once there is an admin", identity.Name))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to save user %s: %v\n", requestID(r), identity.Name, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to log in")
+			return
+		}
+		token, expires, err := messageStore.StartSession(user.Name, sessionLifetime)
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to log in %s: %v\n", requestID(r), user.Name, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to log in")
+			return
+		}
+		setSessionCookie(w, r, token, expires)
+		fmt.Printf("[AUTH] [%s] %s logged in through OIDC as %s\n", requestID(r), user.Name, user.Role)
+		http.Redirect(w, r, cfg.AfterLoginURL, http.StatusFound)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"rsc.io/qr"
+
+	"whatsapp-client/internal/session"
+)
+
+// LogoutRequest is the body of POST /api/admin/logout
+type LogoutRequest struct {
+	// Must be true: logging out unlinks the device and deletes the session, which can't be undone
+	Confirm bool `json:"confirm"`
+}
+
+// LogoutResponse is returned once the bridge has logged out
+type LogoutResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// handleLogout serves POST /api/admin/logout
+func handleLogout(client session.WhatsAppClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/logout from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		var req LogoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		if !req.Confirm {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, `Logging out deletes the WhatsApp session; send {"confirm": true} to do it`)
+			return
+		}
+		if loggedIn, _ := session.Account(client); !loggedIn {
+			writeError(w, r, http.StatusConflict, CodeNotConnected, "Not logged in")
+			return
+		}
+
+		if err := session.Logout(client); err != nil {
+			fmt.Printf("[ERROR] Failed to log out: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, fmt.Sprintf("Failed to log out: %v", err))
+			return
+		}
+		fmt.Println("[AUTH] Logged out through the API, waiting to be paired again")
+
+		w.Header().Set("Content-Type", "application/json")
+		response := LogoutResponse{Success: true, Message: "Logged out; scan the QR code from /api/admin/qr to pair again"}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// pairPage shows the pairing QR code and reloads it as codes rotate, for deployments where the terminal
+// can't render it, such as docker compose logs. The API key the page was opened with is passed on.
+const pairPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pair WhatsApp bridge</title>
+<style>
+body { font-family: sans-serif; text-align: center; margin: 2em; }
+img { width: 320px; height: 320px; image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<h1>Pair the WhatsApp bridge</h1>
+<p id="status">Loading QR code…</p>
+<img id="qr" alt="QR code" hidden>
+<p>On your phone open WhatsApp, Settings, Linked devices, Link a device, and scan the code.</p>
+<script>
+const img = document.getElementById("qr");
+const status = document.getElementById("status");
+async function refresh() {
+  try {
+    const resp = await fetch("qr" + location.search, {cache: "no-store"});
+    if (resp.status === 404) {
+      img.hidden = true;
+      status.textContent = "The bridge is paired, you can close this page.";
+      return;
+    }
+    if (resp.status === 503) {
+      img.hidden = true;
+      status.textContent = "Waiting for a new QR code…";
+      setTimeout(refresh, 2000);
+      return;
+    }
+    if (!resp.ok) throw new Error(resp.status + " " + resp.statusText);
+    const old = img.src;
+    img.src = URL.createObjectURL(await resp.blob());
+    if (old) URL.revokeObjectURL(old);
+    img.hidden = false;
+    status.textContent = "Scan this QR code with WhatsApp. It changes every few seconds.";
+  } catch (err) {
+    status.textContent = "Can't reach the bridge: " + err.message;
+  }
+  setTimeout(refresh, 3000);
+}
+refresh();
+</script>
+</body>
+</html>
+`
+
+// handlePairPage serves GET /api/admin/pair, a page showing the current QR code
+func handlePairPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/pair from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(pairPage))
+	}
+}
+
+// handleQR serves GET /api/admin/qr, the current pairing QR code as a PNG
+func handleQR() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/qr from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		code, err := session.CurrentQR()
+		if errors.Is(err, session.ErrNoQR) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "No pairing in progress, the bridge is logged in")
+			return
+		}
+		if errors.Is(err, session.ErrQRPending) {
+			w.Header().Set("Retry-After", "2")
+			writeError(w, r, http.StatusServiceUnavailable, CodeNotConnected, "Waiting for WhatsApp to issue a QR code")
+			return
+		}
+		image, err := qr.Encode(code, qr.L)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to encode QR code: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to encode QR code")
+			return
+		}
+
+		// Codes rotate every 20 seconds or so
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(image.PNG())
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// handleGetParticipantChanges serves GET /api/participants?chat_jid=&from=&to=&action=, who joined,
+// left, was added, removed, promoted or demoted in the monitored groups, latest first. chat_jid may be a
+// group alias and action a comma-separated list, e.g. action=leave,remove for everyone who left.
+func handleGetParticipantChanges(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/participants from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		chatJID := query.Get("chat_jid")
+		if jid, isAlias := config.LookupGroupAlias(chatJID); isAlias {
+			if jid == "" {
+				writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("Group alias %q matches no joined group", chatJID))
+				return
+			}
+			chatJID = jid
+		}
+		filter, err := store.ParseExportFilter(chatJID, query.Get("from"), query.Get("to"), "")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		var actions []string
+		if value := query.Get("action"); value != "" {
+			for _, action := range strings.Split(value, ",") {
+				action = strings.TrimSpace(action)
+				if !slices.Contains(store.ParticipantActions, action) {
+					writeError(w, r, http.StatusBadRequest, CodeInvalidRequest,
+						fmt.Sprintf("Invalid action %q, use %s", action, strings.Join(store.ParticipantActions, ", ")))
+					return
+				}
+				actions = append(actions, action)
+			}
+		}
+		if !authorizeChat(w, r, filter.ChatJID) {
+			return
+		}
+
+		// Groups of isolated pipelines keep their history in the pipeline's database, so the changes of
+		// the group's databases, or without a group of all of them, are merged
+		stores, err := chatStores(messageStore, filter.ChatJID)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to open the message stores of %q: %v\n", filter.ChatJID, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get participant changes")
+			return
+		}
+		changes := []store.ParticipantChange{}
+		for _, pipelineStore := range stores {
+			found, err := pipelineStore.GetParticipantChanges(filter, actions)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to get participant changes: %v\n", err)
+				writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get participant changes")
+				return
+			}
+			changes = append(changes, found...)
+		}
+		slices.SortStableFunc(changes, func(a, b store.ParticipantChange) int { return b.ChangedAt.Compare(a.ChangedAt) })
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(changes); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/store"
+)
+
+// PaymentUpdateRequest is the body of PUT /api/payments/{id}
+type PaymentUpdateRequest struct {
+	// Paid, which stops the reminders, or open again
+	Done bool `json:"done"`
+}
+
+// handleGetPayments serves GET /api/payments?status=open|done|all&chat_jid=, the payment requests found
+// in monitored chats, newest first. Only the open ones are listed by default.
+func handleGetPayments(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/payments from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		var done *bool
+		switch query.Get("status") {
+		case "", "open":
+			done = new(bool)
+		case "done":
+			done = new(bool)
+			*done = true
+		case "all":
+		default:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid status, use open, done or all")
+			return
+		}
+		chatJID := query.Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		requests, err := messageStore.GetPaymentRequests(chatJID, done)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get payment requests: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get payment requests")
+			return
+		}
+		// Scoped keys only see their own chats
+		if key := requestKey(r); key != nil {
+			visible := requests[:0]
+			for _, request := range requests {
+				if key.AllowsChat(request.ChatJID) {
+					visible = append(visible, request)
+				}
+			}
+			requests = visible
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(requests); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleUpdatePayment serves PUT /api/payments/{id}, marking a payment request paid or open again
+func handleUpdatePayment(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/payments/%s from %s\n", r.Method, r.PathValue("id"), r.RemoteAddr)
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid payment request ID")
+			return
+		}
+		var req PaymentUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+
+		request, err := messageStore.GetPaymentRequest(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No payment request %d", id))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get payment request %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get payment request")
+			return
+		}
+		if !authorizeChat(w, r, request.ChatJID) {
+			return
+		}
+
+		if err := messageStore.SetPaymentDone(id, req.Done); err != nil {
+			fmt.Printf("[ERROR] Failed to update payment request %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update payment request")
+			return
+		}
+		fmt.Printf("[PAYMENTS] Payment request #%d marked done=%t\n", id, req.Done)
+
+		request, err = messageStore.GetPaymentRequest(id)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get payment request %d: %v\n", id, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get payment request")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(request); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/photobook"
+	"whatsapp-client/internal/store"
+)
+
+// handleGetPhotoBook serves GET /api/photobook/{destination}?month=YYYY-MM, the PDF photo book of a
+// destination for a month, last month by default
+func handleGetPhotoBook(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("destination")
+		fmt.Printf("[HTTP] Received %s request to /api/photobook/%s from %s\n", r.Method, key, r.RemoteAddr)
+		dest, ok := config.Current().Destinations[key]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %s", key))
+			return
+		}
+		if !authorizeChat(w, r, dest.Group) {
+			return
+		}
+
+		location := config.Current().Location()
+		month := photobook.MonthStart(time.Now().In(location)).AddDate(0, -1, 0)
+		if v := r.URL.Query().Get("month"); v != "" {
+			parsed, err := time.ParseInLocation("2006-01", v, location)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid month, use YYYY-MM")
+				return
+			}
+			month = parsed
+		}
+
+		book, err := photobook.ForMonth(messageStore, key, month)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to collect the photo book of %s: %v\n", key, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to make photo book")
+			return
+		}
+		if len(book.Photos) == 0 {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No photos of %s in %s", key, month.Format("January 2006")))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.pdf\"", key, month.Format("2006-01")))
+		if _, err := photobook.Write(w, book); err != nil {
+			// Headers are already sent at this point, so the error can only be logged
+			fmt.Printf("[ERROR] Failed to write the photo book of %s: %v\n", key, err)
+		}
+	}
+}
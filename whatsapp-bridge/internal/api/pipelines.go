@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// defaultPipeline is how the top-level input groups and destinations are named among the pipelines
+const defaultPipeline = "default"
+
+// PipelineStats is the activity of one pipeline over a period
+type PipelineStats struct {
+	Name          string   `json:"name"`
+	InputGroups   []string `json:"input_groups"`
+	InputChannels []string `json:"input_channels"`
+	Destinations  []string `json:"destinations"`
+	// Whether the pipeline keeps its messages and media apart
+	Isolated bool `json:"isolated"`
+	// Photos waiting for the pipeline's face filter service
+	Pending int `json:"pending"`
+	// Messages and photos or videos posted in the pipeline's chats; probable spam isn't counted
+	Messages int `json:"messages"`
+	Media    int `json:"media"`
+	// Different photos forwarded to the pipeline's destinations, dry runs included
+	Forwarded int `json:"forwarded"`
+}
+
+// PipelinesResponse is the body of GET /api/pipelines
+type PipelinesResponse struct {
+	Period    string          `json:"period"`
+	From      *time.Time      `json:"from,omitempty"`
+	Pipelines []PipelineStats `json:"pipelines"`
+}
+
+// handleGetPipelines serves GET /api/pipelines?period=, the chats, destinations, queue and activity of
+// every pipeline, the top-level one first as "default". period is as for /api/stats.
+func handleGetPipelines(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/pipelines from %s\n", r.Method, r.RemoteAddr)
+		// Pipelines span chats a scoped key may not see
+		if !authorizeChat(w, r, "") {
+			return
+		}
+		period := r.URL.Query().Get("period")
+		if period == "" {
+			period = "month"
+		}
+		length, ok := statsPeriods[period]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid period, use day, week, month, year or all")
+			return
+		}
+		response := PipelinesResponse{Period: period, Pipelines: []PipelineStats{}}
+		var from time.Time
+		if length > 0 {
+			from = time.Now().Add(-length)
+			response.From = &from
+		}
+
+		cfg := config.Current()
+		for _, name := range append([]string{""}, cfg.PipelineNames()...) {
+			stats := PipelineStats{Name: name, InputGroups: []string{}, InputChannels: []string{}, Destinations: []string{}, Pending: media.PendingFaceFilter(name)}
+			if name == "" {
+				stats.Name = defaultPipeline
+			}
+			stats.Isolated = cfg.Pipelines[name].Isolated
+			pipelineStore, err := routing.PipelineStore(messageStore, name)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to open the message store of pipeline %s: %v\n", name, err)
+				writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get stats")
+				return
+			}
+			for _, jid := range cfg.InputGroups {
+				if cfg.PipelineOf(jid) == name {
+					stats.InputGroups = append(stats.InputGroups, jid)
+				}
+			}
+			for _, jid := range cfg.InputChannels {
+				if cfg.PipelineOf(jid) == name {
+					stats.InputChannels = append(stats.InputChannels, jid)
+				}
+			}
+			for dest := range cfg.Destinations {
+				if cfg.DestinationPipeline(dest) == name {
+					stats.Destinations = append(stats.Destinations, dest)
+				}
+			}
+			slices.Sort(stats.Destinations)
+
+			for _, jid := range append(slices.Clone(stats.InputGroups), stats.InputChannels...) {
+				chat, err := pipelineStore.GetChatStats(store.ExportFilter{ChatJID: jid, From: from})
+				if err != nil {
+					fmt.Printf("[ERROR] Failed to get stats of %s: %v\n", jid, err)
+					writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get stats")
+					return
+				}
+				stats.Messages += chat.Messages
+				stats.Media += chat.Media
+			}
+			for _, dest := range stats.Destinations {
+				forwarded, err := pipelineStore.CountForwardedPhotos(dest, from)
+				if err != nil {
+					fmt.Printf("[ERROR] Failed to count photos forwarded to %s: %v\n", dest, err)
+					writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get stats")
+					return
+				}
+				stats.Forwarded += forwarded
+			}
+			response.Pipelines = append(response.Pipelines, stats)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/session"
+)
+
+// Limits WhatsApp puts on the profile
+const (
+	maxProfileNameLength  = 25
+	maxProfileAboutLength = 139
+)
+
+// UpdateProfileRequest is the body of POST /api/admin/profile; fields left out stay unchanged
+type UpdateProfileRequest struct {
+	Name  *string `json:"name,omitempty"`
+	About *string `json:"about,omitempty"`
+}
+
+// handleProfile serves GET and POST /api/admin/profile, the push name and about text of the bridge's
+// account
+func handleProfile(client session.WhatsAppClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/profile from %s\n", r.Method, r.RemoteAddr)
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req UpdateProfileRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+				return
+			}
+			if req.Name == nil && req.About == nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Nothing to change, send name or about")
+				return
+			}
+			if req.Name != nil && (*req.Name == "" || utf8.RuneCountInString(*req.Name) > maxProfileNameLength) {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("The name must be 1 to %d characters", maxProfileNameLength))
+				return
+			}
+			if req.About != nil && utf8.RuneCountInString(*req.About) > maxProfileAboutLength {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("The about text may be at most %d characters", maxProfileAboutLength))
+				return
+			}
+			if !client.IsConnected() {
+				writeError(w, r, http.StatusServiceUnavailable, CodeNotConnected, "Not connected to WhatsApp")
+				return
+			}
+
+			if req.Name != nil {
+				if err := session.SetProfileName(client, *req.Name); err != nil {
+					fmt.Printf("[ERROR] Failed to set the profile name: %v\n", err)
+					writeError(w, r, http.StatusInternalServerError, CodeInternal, fmt.Sprintf("Failed to set the profile name: %v", err))
+					return
+				}
+				fmt.Printf("[PROFILE] Name changed to %q\n", *req.Name)
+			}
+			if req.About != nil {
+				if err := session.SetProfileAbout(client, *req.About); err != nil {
+					fmt.Printf("[ERROR] Failed to set the about text: %v\n", err)
+					writeError(w, r, http.StatusInternalServerError, CodeInternal, fmt.Sprintf("Failed to set the about text: %v", err))
+					return
+				}
+				fmt.Printf("[PROFILE] About text changed to %q\n", *req.About)
+			}
+		default:
+			methodNotAllowed(w, r)
+			return
+		}
+
+		profile, err := session.GetProfile(client)
+		if err != nil {
+			writeError(w, r, http.StatusConflict, CodeNotConnected, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(profile); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleProfilePhoto serves PUT /api/admin/profile/photo, with the new picture as the request body, and
+// DELETE to remove the picture
+func handleProfilePhoto(client session.WhatsAppClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/profile/photo from %s\n", r.Method, r.RemoteAddr)
+		var photo []byte
+		switch r.Method {
+		case http.MethodPut:
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, session.MaxMediaSize))
+			if err != nil {
+				writeError(w, r, http.StatusRequestEntityTooLarge, CodeMediaTooLarge, fmt.Sprintf("Photos may be at most %d MB", session.MaxMediaSize>>20))
+				return
+			}
+			if photo, err = media.ProfilePhoto(data); err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidMedia, err.Error())
+				return
+			}
+		case http.MethodDelete:
+		default:
+			methodNotAllowed(w, r)
+			return
+		}
+		if !client.IsConnected() {
+			writeError(w, r, http.StatusServiceUnavailable, CodeNotConnected, "Not connected to WhatsApp")
+			return
+		}
+
+		if err := session.SetProfilePhoto(client, photo); err != nil {
+			fmt.Printf("[ERROR] Failed to set the profile photo: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, fmt.Sprintf("Failed to set the profile photo: %v", err))
+			return
+		}
+		if photo == nil {
+			fmt.Println("[PROFILE] Profile photo removed")
+		} else {
+			fmt.Printf("[PROFILE] Profile photo changed (%d bytes)\n", len(photo))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
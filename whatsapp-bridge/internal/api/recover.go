@@ -0,0 +1,32 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"whatsapp-client/internal/crash"
+)
+
+// recoverPanics turns a panic in a handler into a 500 response and a crash report, instead of a dropped
+// connection and a stack trace only net/http's log sees
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				// Handlers abort responses this way on purpose; net/http handles it quietly
+				panic(value)
+			}
+			crash.Report(fmt.Sprintf("%s %s", r.Method, maskPhoneNumbers(r.URL.Path)), value, debug.Stack())
+			if recorder.status == 0 {
+				writeError(recorder, r, http.StatusInternalServerError, CodeInternal, "Internal error")
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/references"
+	"whatsapp-client/internal/session"
+)
+
+// referencePhotoURL is the API path serving a reference photo
+func referencePhotoURL(photo references.Photo) string {
+	return fmt.Sprintf("/api/reference-photos/%s/%s", photo.Destination, photo.ID)
+}
+
+// referenceDestination looks up the {destination} of a reference photo path and checks the request's
+// key may access it, writing the error response if not
+func referenceDestination(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := r.PathValue("destination")
+	dest, ok := config.Current().Destinations[key]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %s", key))
+		return "", false
+	}
+	if !authorizeChat(w, r, dest.Group) {
+		return "", false
+	}
+	return key, true
+}
+
+// handleListReferencePhotos serves GET /api/reference-photos?destination=, the reference photos of a
+// destination, or of all of them for the face filter service
+func handleListReferencePhotos() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/reference-photos from %s\n", r.Method, r.RemoteAddr)
+		key := r.URL.Query().Get("destination")
+		if key == "" {
+			if !authorizeChat(w, r, "") {
+				return
+			}
+		} else {
+			dest, ok := config.Current().Destinations[key]
+			if !ok {
+				writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %s", key))
+				return
+			}
+			if !authorizeChat(w, r, dest.Group) {
+				return
+			}
+		}
+
+		photos, err := references.List(key)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to list reference photos: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list reference photos")
+			return
+		}
+		for i := range photos {
+			photos[i].URL = referencePhotoURL(photos[i])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(photos); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleAddReferencePhoto serves POST /api/reference-photos/{destination}, adding the image in the
+// request body as a reference photo of the destination
+func handleAddReferencePhoto() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/reference-photos/%s from %s\n", r.Method, r.PathValue("destination"), r.RemoteAddr)
+		key, ok := referenceDestination(w, r)
+		if !ok {
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, session.MaxMediaSize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, CodeInvalidRequest, fmt.Sprintf("The photo may be at most %d bytes", session.MaxMediaSize))
+			return
+		}
+		if len(data) == 0 {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Send the photo as the request body")
+			return
+		}
+
+		photo, created, err := references.Add(key, data)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to add reference photo of %s: %v\n", key, err)
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Not a supported image")
+			return
+		}
+		photo.URL = referencePhotoURL(photo)
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+			fmt.Printf("[REFERENCES] Added reference photo %s of %s\n", photo.ID, key)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(photo); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleGetReferencePhoto serves GET /api/reference-photos/{destination}/{id}, the photo itself
+func handleGetReferencePhoto() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/reference-photos/%s/%s from %s\n", r.Method, r.PathValue("destination"), r.PathValue("id"), r.RemoteAddr)
+		key, ok := referenceDestination(w, r)
+		if !ok {
+			return
+		}
+		path, err := references.Path(key, r.PathValue("id"))
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "No such reference photo")
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", mediaCacheControl)
+		http.ServeFile(w, r, path)
+	}
+}
+
+// handleDeleteReferencePhoto serves DELETE /api/reference-photos/{destination}/{id}
+func handleDeleteReferencePhoto() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/reference-photos/%s/%s from %s\n", r.Method, r.PathValue("destination"), r.PathValue("id"), r.RemoteAddr)
+		key, ok := referenceDestination(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		err := references.Remove(key, id)
+		if errors.Is(err, references.ErrInvalidID) || errors.Is(err, os.ErrNotExist) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "No such reference photo")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to delete reference photo %s of %s: %v\n", id, key, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete reference photo")
+			return
+		}
+		fmt.Printf("[REFERENCES] Deleted reference photo %s of %s\n", id, key)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(DeleteResponse{Success: true, FilesDeleted: 1}); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
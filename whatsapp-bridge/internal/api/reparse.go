@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// ReparseResponse is returned once archived raw messages were parsed again
+type ReparseResponse struct {
+	Success bool `json:"success"`
+	session.ReparseResult
+}
+
+// handleReparse serves POST /api/admin/reparse?chat_jid=&from=&to=
+func handleReparse(client session.WhatsAppClient, messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/reparse from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		filter, err := store.ParseExportFilter(query.Get("chat_jid"), query.Get("from"), query.Get("to"), "")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		if !authorizeChat(w, r, filter.ChatJID) {
+			return
+		}
+
+		// Parsing only touches the local store, so it runs within the request
+		result, err := session.Reparse(client, messageStore, filter, waLog.Stdout("Reparse", "INFO", true))
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to re-parse messages: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to re-parse messages")
+			return
+		}
+		fmt.Printf("[REPARSE] %d raw messages parsed again: %d updated, %d added, %d failed\n", result.Scanned, result.Updated, result.Added, result.Failed)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ReparseResponse{Success: true, ReparseResult: result}); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
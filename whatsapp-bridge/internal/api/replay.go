@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// ReplayResponse is returned when a replay is started
+type ReplayResponse struct {
+	Success  bool   `json:"success"`
+	Messages int    `json:"messages"`
+	Force    bool   `json:"force"`
+	Message  string `json:"message"`
+}
+
+// handleReplay serves POST /api/admin/replay?chat_jid=&from=&to=&force=true
+func handleReplay(client session.WhatsAppClient, messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/replay from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		filter, err := store.ParseExportFilter(query.Get("chat_jid"), query.Get("from"), query.Get("to"), "")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		if !authorizeChat(w, r, filter.ChatJID) {
+			return
+		}
+		force := query.Get("force") == "true"
+
+		// The photos of an isolated pipeline's chat are in that pipeline's database
+		chatStore, err := routing.ChatStore(messageStore, filter.ChatJID)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to open the message store of %s: %v\n", filter.ChatJID, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to read messages")
+			return
+		}
+		refs, err := chatStore.GetMediaRefs(filter)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to read messages for replay: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to read messages")
+			return
+		}
+		var photos []store.MediaRef
+		for _, ref := range refs {
+			// Probable spam was never forwarded, so replaying shouldn't forward it either
+			if ref.MediaType == "image" && !ref.Spam {
+				photos = append(photos, ref)
+			}
+		}
+
+		// Replaying can take a while, so it runs in the background until the bridge shuts down
+		go routing.Replay(photos, force, func(ref store.MediaRef) ([]byte, error) {
+			return session.DownloadMediaRef(session.ShutdownContext(), client, ref)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(ReplayResponse{
+			Success:  true,
+			Messages: len(photos),
+			Force:    force,
+			Message:  fmt.Sprintf("Replaying %d photos through the face detection rules", len(photos)),
+		})
+	}
+}
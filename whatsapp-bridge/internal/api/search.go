@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"whatsapp-client/internal/embed"
+	"whatsapp-client/internal/search"
+	"whatsapp-client/internal/store"
+)
+
+// Bounds of the limit parameter of /api/search
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// handleSearch serves GET /api/search?q=...&mode=hybrid: stored messages matching the words of q, close
+// to it in meaning, or both (the default). Keys scoped to chats only get messages of their chats.
+func handleSearch(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/search from %s\n", r.Method, r.RemoteAddr)
+		params := r.URL.Query()
+		text := strings.TrimSpace(params.Get("q"))
+		if text == "" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "q is required")
+			return
+		}
+		mode := params.Get("mode")
+		switch mode {
+		case "":
+			mode = search.ModeHybrid
+		case search.ModeText, search.ModeHybrid:
+		case search.ModeSemantic:
+			if embed.Active() == nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Semantic search is off, set embeddings.embedder to turn it on")
+				return
+			}
+		default:
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "mode must be text, semantic or hybrid")
+			return
+		}
+		limit := defaultSearchLimit
+		if value := params.Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxSearchLimit {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("limit must be a number from 1 to %d", maxSearchLimit))
+				return
+			}
+			limit = parsed
+		}
+		filter, err := store.ParseExportFilter(params.Get("chat_jid"), params.Get("from"), params.Get("to"), params.Get("topic"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		query := search.Query{Text: text, Filter: filter, Mode: mode, Limit: limit}
+		if filter.ChatJID != "" {
+			if !authorizeChat(w, r, filter.ChatJID) {
+				return
+			}
+		} else if key := requestKey(r); key != nil {
+			query.Allow = key.AllowsChat
+		}
+
+		results, err := search.Messages(r.Context(), messageStore, query)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to search messages: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to search messages")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package api serves the bridge's REST API.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/tracing"
+)
+
+// SendMessageResponse represents the response for the send message API
+type SendMessageResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// SendMessageRequest represents the request body for the send message API
+type SendMessageRequest struct {
+	Phone         string   `json:"phone"`
+	Message       string   `json:"message"`
+	MediaURL      string   `json:"media_url,omitempty"`
+	MediaType     string   `json:"media_type,omitempty"`
+	Caption       string   `json:"caption,omitempty"`
+	Mentions      []string `json:"mentions,omitempty"`
+	NoLinkPreview bool     `json:"no_link_preview,omitempty"`
+	DryRun        bool     `json:"dry_run,omitempty"`
+}
+
+// Start runs the REST API server exposing the WhatsApp client functionality in the background
+func Start(client session.WhatsAppClient, messageStore *store.MessageStore, port int) {
+	// Handler for sending messages
+	http.HandleFunc("/api/send", func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		fmt.Printf("[HTTP] [%s] Received %s request to /api/send from %s\n", requestID(r), r.Method, r.RemoteAddr)
+		if r.Method != http.MethodPost {
+			fmt.Printf("[ERROR] Method %s not allowed\n", r.Method)
+			methodNotAllowed(w, r)
+			return
+		}
+
+		// Parse the request body
+		var req SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to parse request body: %v\n", requestID(r), err)
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+
+		fmt.Printf("[DEBUG] [%s] Received message request: phone=%s, hasMedia=%v, mediaType=%s\n",
+			requestID(r), req.Phone, req.MediaURL != "", req.MediaType)
+
+		// Validate request
+		if req.Phone == "" || (req.Message == "" && req.MediaURL == "") {
+			fmt.Printf("[ERROR] [%s] Invalid request: phone=%s, message=%s, mediaURL=%s\n",
+				requestID(r), req.Phone, req.Message, req.MediaURL)
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Phone and either message or media URL are required")
+			return
+		}
+
+		// Only media the bridge stored can be sent: any other file of the host, such as the WhatsApp
+		// session or config.json, must not leave it. Documents are sent by the bridge itself only.
+		// Users may access every chat, so only admins among them may send stored media on.
+		if req.MediaURL != "" {
+			if user := requestUser(r); user != nil && user.Role != config.RoleAdmin {
+				writeError(w, r, http.StatusForbidden, CodeForbidden, "Only admins may send media")
+				return
+			}
+			if req.MediaType == "document" {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Documents can't be sent through /api/send, use image or video")
+				return
+			}
+			if !media.InDir(req.MediaURL) {
+				fmt.Printf("[ERROR] [%s] Refusing to send %s, it is outside the media directory\n", requestID(r), req.MediaURL)
+				writeError(w, r, http.StatusBadRequest, CodeInvalidMedia, "media_url must be a file in the media directory")
+				return
+			}
+		}
+
+		// Keys restricted to some chats may only send there
+		if !authorizeChat(w, r, req.Phone) {
+			return
+		}
+
+		// Photos already forwarded to this destination are skipped (the dedup ledger)
+		caption := routing.ForwardCaption(req.Caption, req.Message)
+		if duplicate, err := routing.AlreadyForwarded(messageStore, req.Phone, req.MediaURL, caption); err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to check forward ledger: %v\n", requestID(r), err)
+		} else if duplicate {
+			fmt.Printf("[DEBUG] [%s] Skipping duplicate forward of %s to %s\n", requestID(r), req.MediaURL, req.Phone)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(SendMessageResponse{
+				Success:   true,
+				Message:   fmt.Sprintf("Already forwarded to %s, not sent again", req.Phone),
+				RequestID: requestID(r),
+				Duplicate: true,
+			})
+			return
+		}
+
+		// Send the message, or only record what would have been sent in dry-run mode
+		dryRun := routing.IsDryRunSend(req.Phone, req.DryRun)
+		var message string
+		if dryRun {
+			fmt.Printf("[DRY-RUN] [%s] Would send to %s: message=%q, media=%s, caption=%q\n",
+				requestID(r), req.Phone, req.Message, req.MediaURL, req.Caption)
+			message = fmt.Sprintf("Dry run: message to %s recorded, not sent", req.Phone)
+		} else {
+			var err error
+			message, err = session.SendMessage(r.Context(), client, req.Phone, req.Message, req.MediaURL, req.MediaType, req.Caption, req.Mentions, !req.NoLinkPreview)
+			if err != nil {
+				fmt.Printf("[ERROR] [%s] Message send failed: %v\n", requestID(r), err)
+				writeSendError(w, r, err)
+				return
+			}
+		}
+		fmt.Printf("[DEBUG] [%s] Message send result: %s\n", requestID(r), message)
+
+		// Keep a ledger of everything forwarded (or that would have been)
+		forward := store.Forward{Destination: req.Phone, MediaPath: req.MediaURL, Caption: caption, DryRun: dryRun, RequestID: requestID(r)}
+		if err := routing.RecordForward(messageStore, forward); err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to record forward: %v\n", requestID(r), err)
+		}
+
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		response := SendMessageResponse{
+			Success:   true,
+			Message:   message,
+			RequestID: requestID(r),
+			DryRun:    dryRun,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	})
+
+	// Handler for reconstructing reply threads
+	http.HandleFunc("/api/thread", handleGetThread(messageStore))
+
+	// Handler for the change log of stored messages
+	http.HandleFunc("/api/events", handleGetEvents(messageStore))
+
+	// Handler streaming new messages and connection changes as server-sent events
+	http.HandleFunc("/api/sse", handleSSE(client, messageStore))
+
+	// Handlers for per-consumer read cursors and unread counts
+	http.HandleFunc("/api/unread", handleGetUnread(messageStore))
+	http.HandleFunc("/api/cursors", handleAdvanceCursor(messageStore))
+
+	// Handler for listing links shared in stored messages
+	http.HandleFunc("/api/links", handleGetLinks(messageStore))
+
+	// Handlers for erasing chats, messages, and senders on request
+	registerDeletionHandlers(messageStore)
+
+	// Handler for creating backups on demand
+	http.HandleFunc("/api/admin/backup", handleBackup)
+
+	// Handler for the ICS feed of events detected in group messages
+	http.HandleFunc("/api/calendar.ics", handleCalendarFeed(messageStore))
+
+	// Atom feeds of monitored groups for relatives outside WhatsApp
+	registerFeedHandlers(messageStore)
+
+	// Handler serving stored media files, with Range support for videos
+	http.HandleFunc("/api/media/{id}", handleGetMedia(messageStore))
+
+	// Handler for exporting messages as JSONL or CSV
+	http.HandleFunc("/api/export", handleExport(messageStore))
+
+	// Handler for checking (and repairing) the media directory
+	http.HandleFunc("/api/admin/verify", handleVerifyMedia(client, messageStore))
+
+	// Handler for re-running stored photos through the face detection rules
+	http.HandleFunc("/api/admin/replay", handleReplay(client, messageStore))
+
+	// Handler for parsing archived raw messages again after parser improvements
+	http.HandleFunc("/api/admin/reparse", handleReparse(client, messageStore))
+
+	// Handlers for logging out and pairing again without access to the terminal
+	http.HandleFunc("/api/admin/logout", handleLogout(client))
+	http.HandleFunc("/api/admin/qr", handleQR())
+	http.HandleFunc("/api/admin/pair", handlePairPage())
+
+	// Handlers for branding the bridge's own account: push name, about text and profile photo
+	http.HandleFunc("/api/admin/profile", handleProfile(client))
+	http.HandleFunc("/api/admin/profile/photo", handleProfilePhoto(client))
+
+	// Handler listing chats with their mute, archive and pin state
+	http.HandleFunc("GET /api/chats", handleGetChats(messageStore))
+
+	// Handler for per-sender activity statistics
+	http.HandleFunc("GET /api/stats", handleGetStats(messageStore))
+
+	// Handler for the number of messages of each topic
+	http.HandleFunc("GET /api/topics", handleGetTopics(messageStore))
+	http.HandleFunc("GET /api/announcements", handleGetAnnouncements(messageStore))
+	http.HandleFunc("GET /api/payments", handleGetPayments(messageStore))
+	http.HandleFunc("PUT /api/payments/{id}", handleUpdatePayment(messageStore))
+	http.HandleFunc("GET /api/forms", handleGetForms(messageStore))
+	http.HandleFunc("PUT /api/forms/{id}", handleUpdateForm(messageStore))
+	http.HandleFunc("POST /api/actions/report-absence", handleReportAbsence(client, messageStore))
+	http.HandleFunc("GET /api/absences", handleGetAbsences(messageStore))
+
+	// Handler for who joined and left the monitored groups
+	http.HandleFunc("GET /api/participants", handleGetParticipantChanges(messageStore))
+
+	// Handler for the chats, queue and activity of each photo pipeline
+	http.HandleFunc("GET /api/pipelines", handleGetPipelines(messageStore))
+
+	// Handler for a child's timeline of photos, mentions and events
+	http.HandleFunc("GET /api/timeline/{destination}", handleGetTimeline(messageStore))
+
+	// Handlers searching stored messages by their words and meaning, and answering questions with them
+	http.HandleFunc("GET /api/search", handleSearch(messageStore))
+	http.HandleFunc("POST /api/ask", handleAsk(messageStore))
+
+	// Handler for a destination's monthly PDF photo book
+	http.HandleFunc("GET /api/photobook/{destination}", handleGetPhotoBook(messageStore))
+
+	// Handlers for clusters of similar faces across stored photos, which parents label with a destination
+	http.HandleFunc("PUT /api/admin/face-clusters", handleReplaceFaceClusters(messageStore))
+	http.HandleFunc("GET /api/face-clusters", handleGetFaceClusters(messageStore))
+	http.HandleFunc("GET /api/face-clusters/labels", handleGetFaceLabels(messageStore))
+	http.HandleFunc("GET /api/face-clusters/{id}", handleGetFaceCluster(messageStore))
+	http.HandleFunc("GET /api/face-clusters/{id}/faces/{face}", handleGetFace(messageStore))
+	http.HandleFunc("POST /api/admin/face-clusters/{id}/label", handleLabelFaceCluster(messageStore))
+
+	// Handlers for managing the reference photos the face filter matches against
+	http.HandleFunc("GET /api/reference-photos", handleListReferencePhotos())
+	http.HandleFunc("POST /api/reference-photos/{destination}", handleAddReferencePhoto())
+	http.HandleFunc("GET /api/reference-photos/{destination}/{id}", handleGetReferencePhoto())
+	http.HandleFunc("DELETE /api/reference-photos/{destination}/{id}", handleDeleteReferencePhoto())
+
+	// Handlers for the face filter's match decisions, feedback on them and the thresholds tuned from it
+	http.HandleFunc("POST /api/face-matches", handleRecordFaceMatches(messageStore))
+	http.HandleFunc("GET /api/face-matches", handleGetFaceMatches(messageStore))
+	http.HandleFunc("GET /api/face-matches/thresholds", handleGetFaceThresholds(messageStore))
+	http.HandleFunc("POST /api/face-matches/{id}/feedback", handleFaceMatchFeedback(messageStore))
+
+	// Handlers for users logging in to the dashboard and API, and for admins managing them
+	http.HandleFunc("POST /api/login", handleLogin(messageStore))
+	http.HandleFunc("POST /api/logout", handleUserLogout(messageStore))
+	http.HandleFunc("GET /api/me", handleMe())
+	http.HandleFunc("GET /api/oidc/login", handleOIDCLogin())
+	http.HandleFunc("GET /api/oidc/callback", handleOIDCCallback(messageStore))
+	http.HandleFunc("GET /api/admin/users", handleListUsers(messageStore))
+	http.HandleFunc("PUT /api/admin/users/{name}", handleSaveUser(messageStore))
+	http.HandleFunc("DELETE /api/admin/users/{name}", handleDeleteUser(messageStore))
+
+	// Handler for the ledger of forwarded (and dry-run) messages
+	http.HandleFunc("/api/forwards", handleGetForwards(messageStore))
+
+	// Handler for the health of the bridge, including media storage
+	http.HandleFunc("/api/status", handleStatus(client))
+
+	// Handler for connection uptime statistics
+	http.HandleFunc("/api/status/history", handleConnectionHistory(messageStore))
+
+	// Handler for the warnings and errors of the bridge's log
+	http.HandleFunc("GET /api/logs", handleGetLogs(messageStore))
+
+	// Handlers for diagnosing memory and goroutine growth: a runtime snapshot and the pprof profiles
+	http.HandleFunc("GET /api/debug/runtime", handleRuntime(messageStore))
+	registerPprof()
+
+	// Unknown API paths get the JSON error envelope too
+	http.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No endpoint %s", r.URL.Path))
+	})
+
+	// Start the server
+	serverAddr := fmt.Sprintf(":%d", port)
+	fmt.Printf("[SERVER] Starting REST API server on %s...\n", serverAddr)
+
+	// Requests derive their context from the shutdown context, so Ctrl+C cancels sends still in flight
+	server = &http.Server{
+		Addr:              serverAddr,
+		Handler:           tracing.AssignRequestID(accessLog(recoverPanics(tracing.Middleware(cors(requireAuth(messageStore, http.DefaultServeMux)))))),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return session.ShutdownContext()
+		},
+	}
+
+	// Run server in a goroutine so it doesn't block
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("[ERROR] REST API server error: %v\n", err)
+		}
+	}()
+}
+
+// server is the running REST API server, if any
+var server *http.Server
+
+// stopping is closed when the server shuts down, ending streams that would otherwise never finish
+var stopping = make(chan struct{})
+
+// Stop stops accepting requests and waits up to timeout for those in flight to finish
+func Stop(timeout time.Duration) {
+	if server == nil {
+		return
+	}
+	close(stopping)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("[SERVER] Requests still running after %s are cancelled: %v\n", timeout, err)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+const (
+	// ssePollInterval is how often new events are looked up for each open stream
+	ssePollInterval = time.Second
+	// sseKeepAlive is how often a comment is sent on an idle stream, so proxies don't close it
+	sseKeepAlive = 15 * time.Second
+)
+
+// ConnectionStatus is sent when a stream opens, before any other event
+type ConnectionStatus struct {
+	Connected bool `json:"connected"`
+}
+
+// writeSSE writes one server-sent event; id is left out when empty
+func writeSSE(w http.ResponseWriter, id, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// handleSSE serves GET /api/sse?chat_jid=&after_seq=, streaming stored-message events (named after
+// their type, with their seq as the event ID) and connection changes (event "connection") as
+// server-sent events. Without after_seq or a Last-Event-ID header only new events are sent; with one,
+// the stream resumes after that seq.
+func handleSSE(client session.WhatsAppClient, messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/sse from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		chatJID := query.Get("chat_jid")
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		// Browsers reconnect with Last-Event-ID, scripts can pass after_seq
+		resume := r.Header.Get("Last-Event-ID")
+		if v := query.Get("after_seq"); v != "" {
+			resume = v
+		}
+		var seq int64
+		var err error
+		if resume != "" {
+			seq, err = strconv.ParseInt(resume, 10, 64)
+			if err != nil || seq < 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid after_seq")
+				return
+			}
+		} else if seq, err = messageStore.GetLastEventSeq(); err != nil {
+			fmt.Printf("[ERROR] Failed to get last event: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to open event stream")
+			return
+		}
+		connID, err := messageStore.GetLastConnectionEventID()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get last connection event: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to open event stream")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		flusher := http.NewResponseController(w)
+		if err := writeSSE(w, "", "status", ConnectionStatus{Connected: client.IsConnected()}); err != nil {
+			return
+		}
+		if err := flusher.Flush(); err != nil {
+			fmt.Printf("[ERROR] Event stream can't be flushed: %v\n", err)
+			return
+		}
+
+		poll := time.NewTicker(ssePollInterval)
+		defer poll.Stop()
+		lastWrite := time.Now()
+		for {
+			select {
+			case <-r.Context().Done():
+				fmt.Printf("[SSE] Stream to %s closed\n", r.RemoteAddr)
+				return
+			case <-stopping:
+				return
+			case <-poll.C:
+			}
+
+			wrote := false
+			events, err := messageStore.GetEvents(seq, chatJID, maxEventsLimit)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to get events for stream: %v\n", err)
+				continue
+			}
+			for _, event := range events {
+				if err := writeSSE(w, strconv.FormatInt(event.Seq, 10), event.Type, event); err != nil {
+					return
+				}
+				seq, wrote = event.Seq, true
+			}
+
+			changes, lastID, err := messageStore.GetConnectionEventsAfter(connID)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to get connection events for stream: %v\n", err)
+				continue
+			}
+			for _, change := range changes {
+				if err := writeSSE(w, "", "connection", change); err != nil {
+					return
+				}
+				wrote = true
+			}
+			connID = lastID
+
+			if !wrote && time.Since(lastWrite) >= sseKeepAlive {
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				wrote = true
+			}
+			if wrote {
+				if err := flusher.Flush(); err != nil {
+					return
+				}
+				lastWrite = time.Now()
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"whatsapp-client/internal/store"
+)
+
+// statsPeriods are the periods GET /api/stats reports on, ending now; "all" has no start
+var statsPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+// handleGetStats serves GET /api/stats?chat_jid=&period=&topic=, per-sender message, media and reaction
+// counts with the busiest hours of the day. period is day, week, month (the default), year or all.
+func handleGetStats(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/stats from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		period := query.Get("period")
+		if period == "" {
+			period = "month"
+		}
+		length, ok := statsPeriods[period]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid period, use day, week, month, year or all")
+			return
+		}
+		filter := store.ExportFilter{ChatJID: query.Get("chat_jid"), Topic: query.Get("topic")}
+		if !authorizeChat(w, r, filter.ChatJID) {
+			return
+		}
+		if length > 0 {
+			filter.From = time.Now().Add(-length)
+		}
+
+		stats, err := messageStore.GetChatStats(filter)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get stats: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get stats")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
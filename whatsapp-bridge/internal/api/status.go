@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/version"
+)
+
+// BridgeStatus is the health of the bridge, served by /api/status
+type BridgeStatus struct {
+	Connected bool `json:"connected"`
+	// The bridge's own pings to WhatsApp, which tell a working connection from one that only looks up
+	Liveness session.LivenessStatus `json:"liveness"`
+	// Whether the bridge is paired with a WhatsApp account; false means the QR code has to be scanned again
+	LoggedIn bool   `json:"logged_in"`
+	JID      string `json:"jid,omitempty"`
+	// Build of the bridge, so clients can detect versions they don't support
+	Version version.Info `json:"version"`
+	// Since when the bridge is running, and for how many seconds
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	LastEventAt   *time.Time     `json:"last_event_at,omitempty"`
+	Queues        session.Queues `json:"queues"`
+	// Size in bytes of each database, including its write-ahead log
+	Databases map[string]int64 `json:"databases"`
+	// Media directory usage; when full, only thumbnails of new photos and videos are stored
+	Storage media.StorageStatus `json:"storage"`
+}
+
+// handleStatus serves GET /api/status
+func handleStatus(client session.WhatsAppClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/status from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		status := BridgeStatus{
+			Connected:     client.IsConnected(),
+			Liveness:      session.Liveness(),
+			Version:       version.Get(),
+			StartedAt:     session.Started(),
+			UptimeSeconds: int64(time.Since(session.Started()).Seconds()),
+			Queues:        session.QueueDepths(),
+			Databases:     store.DatabaseSizes(),
+			Storage:       media.Storage(),
+		}
+		status.LoggedIn, status.JID = session.Account(client)
+		if last := session.LastEvent(); !last.IsZero() {
+			status.LastEventAt = &last
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
+
+// handleConnectionHistory serves GET /api/status/history?days=7
+func handleConnectionHistory(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/status/history from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		days := 7
+		if v := r.URL.Query().Get("days"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid days")
+				return
+			}
+			days = parsed
+		}
+
+		history, err := messageStore.GetConnectionHistory(time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get connection history: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get connection history")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(history); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleGetThread serves GET /api/thread?chat_jid=&message_id=, the reply thread a message belongs to
+func handleGetThread(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/thread from %s\n", r.Method, r.RemoteAddr)
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r)
+			return
+		}
+
+		chatJID := r.URL.Query().Get("chat_jid")
+		messageID := r.URL.Query().Get("message_id")
+		if chatJID == "" || messageID == "" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "chat_jid and message_id are required")
+			return
+		}
+		if !authorizeChat(w, r, chatJID) {
+			return
+		}
+
+		thread, err := messageStore.GetThread(chatJID, messageID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, "Message not found")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get thread: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get thread")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(thread); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
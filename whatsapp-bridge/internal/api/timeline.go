@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// TimelineResponse is a child's timeline, the data behind a "year at gan" view
+type TimelineResponse struct {
+	Destination string               `json:"destination"`
+	Name        string               `json:"name,omitempty"`
+	Items       []store.TimelineItem `json:"items"`
+}
+
+// handleGetTimeline serves GET /api/timeline/{destination}?from=&to=&limit=1000, the photos forwarded
+// to a destination interleaved with the messages mentioning the child by name and the events detected
+// in the monitored groups, oldest first
+func handleGetTimeline(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("destination")
+		fmt.Printf("[HTTP] Received %s request to /api/timeline/%s from %s\n", r.Method, key, r.RemoteAddr)
+		cfg := config.Current()
+		dest, ok := cfg.Destinations[key]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No destination %s", key))
+			return
+		}
+		// Keys given a destination may see its child's timeline
+		if !authorizeChat(w, r, dest.Group) {
+			return
+		}
+
+		query := r.URL.Query()
+		filter, err := store.ParseExportFilter("", query.Get("from"), query.Get("to"), "")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		limit := 1000
+		if v := query.Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid limit")
+				return
+			}
+			limit = parsed
+		}
+
+		chats := append(append([]string{}, cfg.InputGroups...), cfg.InputChannels...)
+		items, err := messageStore.GetTimeline(dest.Group, dest.Name, chats, filter, limit)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get timeline of %s: %v\n", key, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get timeline")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(TimelineResponse{Destination: key, Name: dest.Name, Items: items}); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"whatsapp-client/internal/store"
+)
+
+// handleGetTopics serves GET /api/topics?chat_jid=&from=&to=, how many messages are tagged with each
+// topic, most first
+func handleGetTopics(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/topics from %s\n", r.Method, r.RemoteAddr)
+		query := r.URL.Query()
+		filter, err := store.ParseExportFilter(query.Get("chat_jid"), query.Get("from"), query.Get("to"), "")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		if !authorizeChat(w, r, filter.ChatJID) {
+			return
+		}
+
+		counts, err := messageStore.CountTopics(filter)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to count topics: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to count topics")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(counts); err != nil {
+			fmt.Printf("[ERROR] Failed to encode response: %v\n", err)
+		}
+	}
+}
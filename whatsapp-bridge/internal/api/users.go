@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// sessionCookie is the cookie the dashboard keeps a user's session token in
+const sessionCookie = "jmk_session"
+
+// sessionLifetime is how long a login lasts
+const sessionLifetime = 30 * 24 * time.Hour
+
+type userContextKey struct{}
+
+// sessionKey looks up the user whose session token came with the request, in its Authorization header
+// or session cookie, and returns a key with the operations of their role. Users aren't restricted to
+// chats. Returns nil without a valid session.
+func sessionKey(messageStore *store.MessageStore, r *http.Request, token string) (*config.APIKeyConfig, *store.User) {
+	if token == "" {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		return nil, nil
+	}
+	user, err := messageStore.SessionUser(token)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			fmt.Printf("[ERROR] [%s] Failed to look up session: %v\n", requestID(r), err)
+		}
+		return nil, nil
+	}
+	return &config.APIKeyConfig{Name: "user:" + user.Name, Operations: config.RoleOperations(user.Role)}, user
+}
+
+// requestUser returns the user who made a request, or nil for requests made with an API key or
+// without authentication
+func requestUser(r *http.Request) *store.User {
+	user, _ := r.Context().Value(userContextKey{}).(*store.User)
+	return user
+}
+
+// withUser adds the user who made a request to its context
+func withUser(ctx context.Context, user *store.User) context.Context {
+	if user == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// LoginRequest is the body of POST /api/login
+type LoginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// LoginResponse is returned on a successful login. The token works like an API key until it expires;
+// browsers get it as a cookie as well.
+type LoginResponse struct {
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleLogin serves POST /api/login
+func handleLogin(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/login from %s\n", r.Method, r.RemoteAddr)
+		var req LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		user, token, expires, err := messageStore.Login(req.Name, req.Password, sessionLifetime)
+		if errors.Is(err, store.ErrInvalidLogin) {
+			fmt.Printf("[AUTH] [%s] Failed login as %q from %s\n", requestID(r), req.Name, r.RemoteAddr)
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Invalid user name or password")
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Failed to log in %q: %v\n", requestID(r), req.Name, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to log in")
+			return
+		}
+		fmt.Printf("[AUTH] [%s] %s logged in as %s\n", requestID(r), user.Name, user.Role)
+
+		setSessionCookie(w, r, token, expires)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LoginResponse{Name: user.Name, Role: user.Role, Token: token, ExpiresAt: expires})
+	}
+}
+
+// setSessionCookie hands a browser its session token. Lax cookies aren't sent with requests other sites
+// make, so they can't act on behalf of a logged in user.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// isHTTPS reports whether the browser reached the bridge over HTTPS, directly or through a proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// handleUserLogout serves POST /api/logout, ending the session the request was made with
+func handleUserLogout(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/logout from %s\n", r.Method, r.RemoteAddr)
+		token := requestAPIKey(r)
+		if cookie, err := r.Cookie(sessionCookie); err == nil && token == "" {
+			token = cookie.Value
+		}
+		if token != "" {
+			if err := messageStore.Logout(token); err != nil {
+				fmt.Printf("[ERROR] [%s] Failed to log out: %v\n", requestID(r), err)
+				writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to log out")
+				return
+			}
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MeResponse tells who a request was made by and what they may do
+type MeResponse struct {
+	Name       string   `json:"name,omitempty"`
+	Role       string   `json:"role,omitempty"`
+	Operations []string `json:"operations"`
+}
+
+// handleMe serves GET /api/me, the user or API key of the request. Without keys or users everyone
+// may do everything.
+func handleMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := MeResponse{Operations: []string{"*"}}
+		if user := requestUser(r); user != nil {
+			response = MeResponse{Name: user.Name, Role: user.Role, Operations: config.RoleOperations(user.Role)}
+		} else if key := requestKey(r); key != nil {
+			response = MeResponse{Name: key.Name, Operations: key.Operations}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// SaveUserRequest is the body of PUT /api/admin/users/{name}. An empty password keeps the current one.
+type SaveUserRequest struct {
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// handleListUsers serves GET /api/admin/users
+func handleListUsers(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[HTTP] Received %s request to /api/admin/users from %s\n", r.Method, r.RemoteAddr)
+		users, err := messageStore.ListUsers()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to list users: %v\n", err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list users")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+	}
+}
+
+// handleSaveUser serves PUT /api/admin/users/{name}, adding a user or changing their role or password
+func handleSaveUser(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		fmt.Printf("[HTTP] Received %s request to /api/admin/users/%s from %s\n", r.Method, name, r.RemoteAddr)
+		var req SaveUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request format")
+			return
+		}
+		if err := store.CheckUser(name, req.Password, req.Role); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		if req.Role != config.RoleAdmin && lastAdmin(w, r, messageStore, name) {
+			return
+		}
+		if err := messageStore.SaveUser(name, req.Password, req.Role); err != nil {
+			fmt.Printf("[ERROR] Failed to save user %s: %v\n", name, err)
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Failed to save user: %v", err))
+			return
+		}
+		fmt.Printf("[AUTH] [%s] User %s saved as %s\n", requestID(r), name, req.Role)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleDeleteUser serves DELETE /api/admin/users/{name}
+func handleDeleteUser(messageStore *store.MessageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		fmt.Printf("[HTTP] Received %s request to /api/admin/users/%s from %s\n", r.Method, name, r.RemoteAddr)
+		if lastAdmin(w, r, messageStore, name) {
+			return
+		}
+		err := messageStore.DeleteUser(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("No user %s", name))
+			return
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to delete user %s: %v\n", name, err)
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete user")
+			return
+		}
+		fmt.Printf("[AUTH] [%s] User %s deleted\n", requestID(r), name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// lastAdmin keeps the last admin from being removed or demoted through the API, which would leave
+// nobody able to manage users without an admin API key, and writes the error response if name is it
+func lastAdmin(w http.ResponseWriter, r *http.Request, messageStore *store.MessageStore, name string) bool {
+	users, err := messageStore.ListUsers()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to list users: %v\n", err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list users")
+		return true
+	}
+	admins, isAdmin := 0, false
+	for _, user := range users {
+		if user.Role == config.RoleAdmin {
+			admins++
+			isAdmin = isAdmin || user.Name == name
+		}
+	}
+	if isAdmin && admins == 1 {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("%s is the last admin, make someone else admin first", name))
+		return true
+	}
+	return false
+}
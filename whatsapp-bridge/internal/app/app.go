@@ -0,0 +1,200 @@
+// Package app ties the running bridge together: the WhatsApp connection, the message store and the
+// configuration, which can be reloaded while messages are handled and API requests are served.
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/session"
+	"whatsapp-client/internal/store"
+)
+
+// App is the running bridge. Event callbacks, API handlers and reloads share it from many goroutines:
+// the client and store are safe for concurrent use, and the configuration is only ever replaced as a
+// whole, never changed in place.
+type App struct {
+	Client session.WhatsAppClient
+	Store  *store.MessageStore
+	Logger waLog.Logger
+
+	// load reads the configuration again, with environment variables and -set flags layered over it
+	load func() (config.Config, error)
+	// reloading serializes reloads, so two can't interleave their checks and updates
+	reloading sync.Mutex
+}
+
+// New returns the bridge running with client and messageStore. load is how Reload reads the
+// configuration; the configuration in use must have been set with config.Set already.
+func New(client session.WhatsAppClient, messageStore *store.MessageStore, load func() (config.Config, error), logger waLog.Logger) *App {
+	return &App{Client: client, Store: messageStore, Logger: logger, load: load}
+}
+
+// Config returns the configuration the bridge runs with. A reload may replace it at any time, so
+// callers that read several settings should keep the returned snapshot rather than call Config again.
+func (a *App) Config() *config.Config {
+	return config.Current()
+}
+
+// Reload reads the configuration again and makes it current. Destinations, input groups, API keys,
+// redaction and the other settings read as messages and requests come in apply at once; the data
+// directory, media store path and API port keep their startup values until the bridge is restarted.
+// A configuration with errors is refused and the running one kept.
+func (a *App) Reload() error {
+	a.reloading.Lock()
+	defer a.reloading.Unlock()
+
+	cfg, err := a.load()
+	if err != nil {
+		return err
+	}
+	problems := cfg.Validate()
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Printf("[CONFIG] Warning: %s\n", problem)
+		} else {
+			fmt.Printf("[CONFIG] Error: %s\n", problem)
+		}
+	}
+	if config.HasErrors(problems) {
+		return fmt.Errorf("the configuration has errors, keeping the running one")
+	}
+
+	// Settings the bridge was started with can't change under it
+	running := a.Config()
+	if cfg.DataDir != running.DataDir || cfg.Media.StorePath != running.Media.StorePath || cfg.APIPort != running.APIPort {
+		fmt.Println("[CONFIG] data_dir, media.store_path and api_port take effect when the bridge is restarted")
+		cfg.DataDir, cfg.Media.StorePath, cfg.APIPort = running.DataDir, running.Media.StorePath, running.APIPort
+	}
+
+	if err := routing.CompileRedactionRules(cfg.Privacy.RedactionRules); err != nil {
+		return err
+	}
+	config.Set(cfg)
+	// New aliases and patterns apply now rather than on the next connect
+	if (len(cfg.GroupAliases) > 0 || len(cfg.InputGroupPatterns) > 0 || len(config.DiscoveredGroups()) > 0) && a.Client.IsConnected() {
+		if groups, err := a.Client.GetJoinedGroups(); err == nil {
+			a.resolveGroups(groups)
+		} else {
+			fmt.Printf("[CONFIG] Group aliases and patterns apply on the next connect, listing groups failed: %v\n", err)
+		}
+	}
+	fmt.Printf("[CONFIG] Reloaded: %d destinations, %d input groups\n", len(cfg.Destinations), len(cfg.InputGroups))
+	return nil
+}
+
+// HandleEvent handles an event of the WhatsApp connection. Events that need the full whatsmeow client,
+// such as history syncs, are skipped when running on the mock.
+func (a *App) HandleEvent(evt interface{}) {
+	defer crash.Recover(fmt.Sprintf("handling a %T event", evt))
+	logger := a.Logger
+	logger.Infof("[EVENT] Received event type: %T", evt)
+	session.NoteEvent()
+	client, _ := a.Client.(*whatsmeow.Client)
+
+	switch v := evt.(type) {
+	case *events.Message:
+		logger.Infof("[MESSAGE] Processing incoming message event")
+		session.HandleMessage(a.Client, a.Store, v, logger)
+
+	case *events.HistorySync:
+		if client != nil {
+			logger.Infof("[SYNC] Processing history sync event")
+			session.HandleHistorySync(client, a.Store, v, logger)
+		}
+
+	case *events.PushName:
+		if client != nil {
+			session.HandlePushName(client, a.Store, v, logger)
+		}
+
+	case *events.Contact:
+		session.HandleContact(a.Store, v, logger)
+
+	case *events.Mute:
+		session.HandleMute(a.Store, v, logger)
+
+	case *events.Archive:
+		session.HandleArchive(a.Store, v, logger)
+
+	case *events.Pin:
+		session.HandlePin(a.Store, v, logger)
+
+	case *events.Connected:
+		logger.Infof("[CONNECTION] Connected to WhatsApp")
+		session.LogConnectionEvent(a.Store, store.ConnEventConnected, "", logger)
+		if client == nil {
+			return
+		}
+		// Show as online only when the presence settings say so
+		session.ApplyPresence(client)
+		// List all groups when connected
+		if groups, err := client.GetJoinedGroups(); err == nil {
+			logger.Infof("[GROUPS] Found %d groups:", len(groups))
+			for _, group := range groups {
+				logger.Infof("[GROUP] Name: %s (JID: %s)", group.Name, group.JID)
+			}
+			// Aliases first, so destinations given by alias are checked as JIDs
+			a.resolveGroups(groups)
+			session.CheckDestinations(groups, logger)
+		}
+		// Follow configured channels so their posts arrive as messages
+		session.FollowChannels(client, logger)
+
+	case *events.JoinedGroup:
+		// A group created or joined while connected may be named by an alias or match a pattern
+		a.refreshGroups()
+
+	case *events.GroupInfo:
+		if v.Name != nil {
+			a.refreshGroups()
+		}
+		session.HandleGroupInfo(a.Client, a.Store, v, logger)
+
+	case *events.LoggedOut:
+		logger.Warnf("[AUTH] Device logged out, please scan QR code to log in again")
+		session.LogConnectionEvent(a.Store, store.ConnEventLoggedOut, v.Reason.String(), logger)
+
+	case *events.Disconnected:
+		logger.Infof("[CONNECTION] Disconnected from WhatsApp")
+		session.LogConnectionEvent(a.Store, store.ConnEventDisconnected, "", logger)
+
+	case *events.KeepAliveTimeout:
+		logger.Warnf("[CONNECTION] Keepalive timed out (%d errors)", v.ErrorCount)
+		session.LogConnectionEvent(a.Store, store.ConnEventKeepAliveTimeout, fmt.Sprintf("%d errors since %s", v.ErrorCount, v.LastSuccess.Format(time.RFC3339)), logger)
+
+	case *events.KeepAliveRestored:
+		logger.Infof("[CONNECTION] Keepalive restored")
+		session.LogConnectionEvent(a.Store, store.ConnEventKeepAliveRestored, "", logger)
+	}
+}
+
+// resolveGroups resolves group aliases and input group patterns against the joined groups
+func (a *App) resolveGroups(groups []*types.GroupInfo) {
+	session.ResolveGroupAliases(groups, a.Logger)
+	session.DiscoverInputGroups(groups, a.Logger)
+}
+
+// refreshGroups lists the joined groups again and resolves aliases and patterns against them, when the
+// configuration has any
+func (a *App) refreshGroups() {
+	cfg := a.Config()
+	if len(cfg.GroupAliases) == 0 && len(cfg.InputGroupPatterns) == 0 {
+		return
+	}
+	groups, err := a.Client.GetJoinedGroups()
+	if err != nil {
+		a.Logger.Warnf("[GROUPS] Failed to list groups, aliases and patterns apply on the next connect: %v", err)
+		return
+	}
+	a.resolveGroups(groups)
+}
@@ -0,0 +1,63 @@
+// Package ask answers questions about the archive, such as "what did the teacher say about the Purim
+// party?": it finds the stored messages that bear on the question and, when ask.llm is configured, has a
+// language model write an answer from them.
+package ask
+
+import (
+	"context"
+	"fmt"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/search"
+	"whatsapp-client/internal/store"
+)
+
+// Question is a question about the messages matching Filter. Allow, when set, tells which chats the
+// asker may read; messages of other chats are neither returned nor shown to the model.
+type Question struct {
+	Text   string
+	Filter store.ExportFilter
+	Limit  int
+	Allow  func(chatJID string) bool
+}
+
+// Answer holds the messages found for a question and the model's answer
+type Answer struct {
+	Question string               `json:"question"`
+	Answer   string               `json:"answer,omitempty"`
+	Model    string               `json:"model,omitempty"`
+	Messages []store.SearchResult `json:"messages"`
+	// Why no answer was written although a model is configured
+	AnswerError string `json:"answer_error,omitempty"`
+}
+
+// Ask finds the messages that best match the question, by its words and, with an embedder configured,
+// by its meaning, and with a model configured and messages found, asks it for an answer. A failing
+// model leaves the answer out rather than failing the question.
+func Ask(ctx context.Context, messageStore *store.MessageStore, question Question) (*Answer, error) {
+	cfg := config.Current().Ask
+	limit := question.Limit
+	if limit <= 0 || limit > cfg.MaxMessages {
+		limit = cfg.MaxMessages
+	}
+
+	found, err := search.Messages(ctx, messageStore, search.Query{
+		Text: question.Text, Filter: question.Filter, Mode: search.ModeHybrid, Limit: limit, Allow: question.Allow,
+	})
+	if err != nil {
+		return nil, err
+	}
+	answer := &Answer{Question: question.Text, Messages: found}
+
+	if cfg.LLM.URL == "" || len(answer.Messages) == 0 {
+		return answer, nil
+	}
+	text, err := compose(ctx, cfg.LLM, question.Text, answer.Messages)
+	if err != nil {
+		fmt.Printf("[ASK] Failed to get an answer from %s: %v\n", cfg.LLM.Model, err)
+		answer.AnswerError = err.Error()
+		return answer, nil
+	}
+	answer.Answer, answer.Model = text, cfg.LLM.Model
+	return answer, nil
+}
@@ -0,0 +1,104 @@
+package ask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// systemPrompt tells the model to answer from the messages only
+const systemPrompt = `You answer questions of a parent about the WhatsApp groups of their children's kindergarten and school.
+Answer only from the messages given, which are the ones found for the question, best matches first. If they don't answer it, say so.
+Answer briefly, in the language of the question, and mention the dates of the messages you rely on.`
+
+// maxResponseSize bounds the model's response that is read
+const maxResponseSize = 1 << 20
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// compose asks the model of cfg to answer question from messages
+func compose(ctx context.Context, cfg config.LLMConfig, question string, messages []store.SearchResult) (string, error) {
+	location := config.Current().Location()
+	var prompt strings.Builder
+	prompt.WriteString("Messages:\n")
+	for _, message := range messages {
+		sender := message.SenderName
+		if sender == "" {
+			sender = "+" + message.Sender
+		}
+		chat := message.ChatName
+		if chat == "" {
+			chat = message.ChatJID
+		}
+		fmt.Fprintf(&prompt, "[%s] %s in %s: %s\n", message.Timestamp.In(location).Format("Mon 2 Jan 2006 15:04"), sender, chat,
+			strings.ReplaceAll(message.Text, "\n", " "))
+	}
+	fmt.Fprintf(&prompt, "\nQuestion: %s", question)
+
+	body, err := json.Marshal(chatRequest{
+		Model:    cfg.Model,
+		Messages: []chatMessage{{Role: "system", Content: systemPrompt}, {Role: "user", Content: prompt.String()}},
+	})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result chatResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		if resp.StatusCode >= 300 {
+			return "", fmt.Errorf("model endpoint returned %s", resp.Status)
+		}
+		return "", fmt.Errorf("failed to read the model's response: %v", err)
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("model endpoint returned %s: %s", resp.Status, result.Error.Message)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("model endpoint returned %s", resp.Status)
+	}
+	if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("the model returned no answer")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,12 @@
+// Package assets holds files compiled into the bridge binary, so it runs without the repository checkout.
+package assets
+
+import _ "embed"
+
+//go:generate cp ../../../config.template.json config.template.json
+
+// ConfigTemplate is the starter config.json written by the init command. It is a copy of
+// config.template.json in the project root; run go generate after changing that file.
+//
+//go:embed config.template.json
+var ConfigTemplate []byte
@@ -0,0 +1,132 @@
+// Package autoreply answers messages to the bridge's number by configured rules, so someone writing to
+// an archive account learns whom to contact instead. Each sender is answered at most once per rate limit
+// and can ask not to be answered again.
+package autoreply
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// stopWords opt a sender out of automatic replies when they are the whole message
+var stopWords = []string{"stop", "unsubscribe", "הסר", "הסרה", "די"}
+
+// answering serializes answers, so messages arriving together get one reply
+var answering sync.Mutex
+
+// Message is a message someone else sent, in a chat with the bridge's number or a monitored group
+type Message struct {
+	ID      string
+	ChatJID string
+	Sender  string
+	// The sender's phone number, if known
+	Phone string
+	Text  string
+	Group bool
+	// The sender is among the account's contacts
+	Contact bool
+	At      time.Time
+}
+
+// Answer replies to msg with send by the first rule that matches it, unless the sender was answered
+// within the rate limit, is on the opt-out list or opted out. A sender answered before who replies with
+// a stop word is opted out.
+func Answer(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, msg Message, logger waLog.Logger) {
+	cfg := config.Current().AutoReply
+	if !cfg.Enabled || cfg.StrangersOnly && msg.Contact || optedOut(cfg.OptOut, msg.Phone) {
+		return
+	}
+	answering.Lock()
+	defer answering.Unlock()
+
+	if opted, err := messageStore.OptedOutOfAutoReplies(msg.Sender); err != nil || opted {
+		return
+	}
+	last, err := messageStore.LastAutoReply(msg.Sender)
+	if err != nil {
+		logger.Warnf("Failed to look up the last automatic reply to %s: %v", msg.Sender, err)
+		return
+	}
+	if !msg.Group && !last.IsZero() && slices.Contains(stopWords, strings.ToLower(strings.TrimSpace(msg.Text))) {
+		if err := messageStore.OptOutOfAutoReplies(msg.Sender, msg.At); err != nil {
+			logger.Warnf("Failed to opt %s out of automatic replies: %v", msg.Sender, err)
+			return
+		}
+		fmt.Printf("[AUTOREPLY] %s opted out of automatic replies\n", msg.Sender)
+		reply(ctx, messageStore, send, msg, i18n.T("autoreply.opted_out"), logger)
+		return
+	}
+	if msg.At.Sub(last) < time.Duration(cfg.RateLimitHours)*time.Hour {
+		return
+	}
+	rule, ok := match(cfg.Rules, msg)
+	if !ok {
+		return
+	}
+	text := rule.Text
+	if !msg.Group {
+		text += "\n\n" + i18n.T("autoreply.opt_out_hint")
+	}
+	reply(ctx, messageStore, send, msg, text, logger)
+}
+
+// reply sends text to the chat of msg, unless sends there are dry runs, and records it
+func reply(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, msg Message, text string, logger waLog.Logger) {
+	answer := store.AutoReply{
+		ChatJID:   msg.ChatJID,
+		Sender:    msg.Sender,
+		MessageID: msg.ID,
+		Text:      text,
+		RepliedAt: msg.At,
+		DryRun:    routing.IsDryRunSend(msg.ChatJID, false),
+	}
+	if answer.DryRun {
+		fmt.Printf("[DRY-RUN] Would answer %s in %s: %q\n", msg.Sender, msg.ChatJID, text)
+	} else if err := send(ctx, msg.ChatJID, text); err != nil {
+		logger.Warnf("Failed to answer %s automatically: %v", msg.Sender, err)
+		return
+	}
+	if err := messageStore.StoreAutoReply(answer); err != nil {
+		logger.Warnf("Failed to record the automatic reply to %s: %v", msg.Sender, err)
+	}
+	fmt.Printf("[AUTOREPLY] Answered %s in %s\n", msg.Sender, msg.ChatJID)
+}
+
+// match returns the first rule answering msg: one for its kind of chat, with a keyword it contains or
+// without keywords
+func match(rules []config.AutoReplyRule, msg Message) (config.AutoReplyRule, bool) {
+	text := strings.ToLower(msg.Text)
+	for _, rule := range rules {
+		if (rule.Chats == config.AutoReplyGroups) != msg.Group {
+			continue
+		}
+		if len(rule.Keywords) == 0 || slices.ContainsFunc(rule.Keywords, func(keyword string) bool {
+			return keyword != "" && strings.Contains(text, strings.ToLower(keyword))
+		}) {
+			return rule, true
+		}
+	}
+	return config.AutoReplyRule{}, false
+}
+
+// optedOut reports whether phone is on the opt-out list, which may write numbers with "+" or spaces
+func optedOut(list []string, phone string) bool {
+	if phone == "" {
+		return false
+	}
+	return slices.ContainsFunc(list, func(number string) bool {
+		return strings.NewReplacer("+", "", " ", "", "-", "").Replace(number) == phone
+	})
+}
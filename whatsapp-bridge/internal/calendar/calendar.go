@@ -0,0 +1,261 @@
+// Package calendar detects events announced in monitored chats and renders them as an iCalendar feed.
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// EventDetector finds events announced in a message sent at the given time
+type EventDetector interface {
+	Detect(text string, sentAt time.Time) ([]store.CalendarEvent, error)
+}
+
+// defaultEventKeywords are matched as substrings; Hebrew entries are word stems so that construct
+// forms ("מסיבת חנוכה", "אסיפת הורים") match as well
+var defaultEventKeywords = []string{
+	"trip", "party", "meeting", "ceremony", "holiday", "show", "birthday", "event", "celebration",
+	"טיול", "מסיב", "אסיפ", "טקס", "הצג", "יום הולדת", "אירוע", "חגיג", "מפגש",
+}
+
+var (
+	explicitDatePattern = regexp.MustCompile(`\b(\d{1,2})[./](\d{1,2})(?:[./](\d{2,4}))?\b`)
+	timeOfDayPattern    = regexp.MustCompile(`\b([01]?\d|2[0-3])[:.]([0-5]\d)\b`)
+	tomorrowPattern     = regexp.MustCompile(`(?i)\btomorrow\b|מחר`)
+	weekdayNames        = []struct {
+		name    string
+		weekday time.Weekday
+	}{
+		{"sunday", time.Sunday}, {"monday", time.Monday}, {"tuesday", time.Tuesday}, {"wednesday", time.Wednesday},
+		{"thursday", time.Thursday}, {"friday", time.Friday}, {"saturday", time.Saturday},
+		{"יום ראשון", time.Sunday}, {"יום שני", time.Monday}, {"יום שלישי", time.Tuesday}, {"יום רביעי", time.Wednesday},
+		{"יום חמישי", time.Thursday}, {"יום שישי", time.Friday}, {"שבת", time.Saturday},
+	}
+)
+
+// ruleEventDetector recognizes "<keyword> ... <date or weekday> [time]" announcements
+type ruleEventDetector struct {
+	keywords []string
+}
+
+// Detect implements EventDetector using keyword and date/time rules
+func (d ruleEventDetector) Detect(text string, sentAt time.Time) ([]store.CalendarEvent, error) {
+	lower := strings.ToLower(text)
+	hasKeyword := false
+	for _, keyword := range d.keywords {
+		if strings.Contains(lower, strings.ToLower(keyword)) {
+			hasKeyword = true
+			break
+		}
+	}
+	if !hasKeyword {
+		return nil, nil
+	}
+
+	day, span, ok := findDate(lower, sentAt)
+	if !ok {
+		return nil, nil
+	}
+
+	// The date itself isn't a time, "17.10" is the 17th of October rather than 17:10
+	if span != nil {
+		lower = lower[:span[0]] + strings.Repeat(" ", span[1]-span[0]) + lower[span[1]:]
+	}
+	event := store.CalendarEvent{Title: eventTitle(text), Details: text, AllDay: true, Start: day}
+	if match := timeOfDayPattern.FindStringSubmatch(lower); match != nil {
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		event.Start = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+		event.AllDay = false
+	}
+	return []store.CalendarEvent{event}, nil
+}
+
+// FindDate resolves an explicit date, "tomorrow", or a weekday name in text relative to sentAt, to the
+// start of that day
+func FindDate(text string, sentAt time.Time) (time.Time, bool) {
+	day, _, ok := findDate(strings.ToLower(text), sentAt)
+	return day, ok
+}
+
+// findDate is FindDate on lowercased text, also returning where an explicit date was found
+func findDate(text string, sentAt time.Time) (time.Time, []int, bool) {
+	base := time.Date(sentAt.Year(), sentAt.Month(), sentAt.Day(), 0, 0, 0, 0, sentAt.Location())
+
+	if match := explicitDatePattern.FindStringSubmatch(text); match != nil {
+		span := explicitDatePattern.FindStringIndex(text)
+		day, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		year := base.Year()
+		if match[3] != "" {
+			year, _ = strconv.Atoi(match[3])
+			if year < 100 {
+				year += 2000
+			}
+		}
+		if day >= 1 && day <= 31 && month >= 1 && month <= 12 {
+			date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, base.Location())
+			// A date without a year that already passed refers to next year
+			if match[3] == "" && date.Before(base) {
+				date = date.AddDate(1, 0, 0)
+			}
+			return date, span, true
+		}
+	}
+
+	if tomorrowPattern.MatchString(text) {
+		return base.AddDate(0, 0, 1), nil, true
+	}
+
+	// The weekday named first
+	at, found := len(text), false
+	var weekday time.Weekday
+	for _, day := range weekdayNames {
+		if i := strings.Index(text, day.name); i >= 0 && i < at {
+			at, found, weekday = i, true, day.weekday
+		}
+	}
+	if found {
+		days := (int(weekday) - int(base.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return base.AddDate(0, 0, days), nil, true
+	}
+	return time.Time{}, nil, false
+}
+
+// eventTitle uses the first line of the message, shortened, as the event summary
+func eventTitle(text string) string {
+	title := strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+	if runes := []rune(title); len(runes) > 60 {
+		title = string(runes[:60]) + "…"
+	}
+	return title
+}
+
+// remoteEventDetector delegates detection to an external NLP service
+type remoteEventDetector struct {
+	url    string
+	client *http.Client
+}
+
+// Detect implements EventDetector by POSTing the message to the configured service
+func (d remoteEventDetector) Detect(text string, sentAt time.Time) ([]store.CalendarEvent, error) {
+	body, err := json.Marshal(map[string]interface{}{"text": text, "timestamp": sentAt})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Post(d.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("detector returned %s", resp.Status)
+	}
+
+	var events []store.CalendarEvent
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, fmt.Errorf("invalid detector response: %v", err)
+	}
+	return events, nil
+}
+
+// newEventDetector builds the detector selected by the calendar configuration
+func newEventDetector(cfg config.CalendarConfig) EventDetector {
+	if cfg.DetectorURL != "" {
+		return remoteEventDetector{url: cfg.DetectorURL, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return ruleEventDetector{keywords: append(append([]string{}, defaultEventKeywords...), cfg.Keywords...)}
+}
+
+// DetectEvents runs event detection on a monitored group message and stores the results
+func DetectEvents(messageStore *store.MessageStore, messageID, chatJID, content string, sentAt time.Time, logger waLog.Logger) {
+	cfg := config.Current().Calendar
+	if !cfg.Enabled || content == "" || !routing.IsMonitored(chatJID) {
+		return
+	}
+
+	// "Tomorrow at 17:00" means the day and hour where the group lives
+	events, err := newEventDetector(cfg).Detect(content, sentAt.In(config.Current().Location()))
+	if err != nil {
+		logger.Warnf("Failed to detect calendar events: %v", err)
+		return
+	}
+	for _, event := range events {
+		event.MessageID = messageID
+		event.ChatJID = chatJID
+		if event.Details == "" {
+			event.Details = content
+		}
+		if err := messageStore.StoreCalendarEvent(event); err != nil {
+			logger.Warnf("Failed to store calendar event: %v", err)
+			continue
+		}
+		logger.Infof("[CALENDAR] Detected event %q on %s", event.Title, event.Start.Format("2006-01-02 15:04"))
+	}
+}
+
+// escapeICSText escapes a value for use in an iCalendar text property
+func escapeICSText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+// writeICSLine writes a content line folded at 75 octets as required by RFC 5545
+func writeICSLine(buf *bytes.Buffer, line string) {
+	for len(line) > 75 {
+		cut := 75
+		// Don't split a multi-byte UTF-8 character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	buf.WriteString(line + "\r\n")
+}
+
+// BuildICS renders events as an iCalendar feed
+func BuildICS(events []store.CalendarEvent) []byte {
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//just-my-kids//whatsapp-bridge//EN")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	writeICSLine(&buf, "X-WR-CALNAME:Kindergarten events")
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, event := range events {
+		writeICSLine(&buf, "BEGIN:VEVENT")
+		writeICSLine(&buf, fmt.Sprintf("UID:event-%d@just-my-kids", event.ID))
+		writeICSLine(&buf, "DTSTAMP:"+stamp)
+		if event.AllDay {
+			day := event.Start.In(config.Current().Location())
+			writeICSLine(&buf, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+			writeICSLine(&buf, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+		} else {
+			writeICSLine(&buf, "DTSTART:"+event.Start.UTC().Format("20060102T150405Z"))
+			writeICSLine(&buf, "DTEND:"+event.Start.Add(time.Hour).UTC().Format("20060102T150405Z"))
+		}
+		writeICSLine(&buf, "SUMMARY:"+escapeICSText(event.Title))
+		writeICSLine(&buf, "DESCRIPTION:"+escapeICSText(event.Details))
+		writeICSLine(&buf, "END:VEVENT")
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
@@ -0,0 +1,188 @@
+// Package chaos injects faults for development: failing media downloads, a slow or busy database and
+// dropped WhatsApp connections, so the retries, queues and reconnect handling can be exercised locally
+// without waiting for the real thing. Everything is off unless JMK_CHAOS_* environment variables turn it
+// on, and random faults follow JMK_CHAOS_SEED, so a run can be repeated.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Environment variables that turn on faults
+const (
+	// Seed of the random faults (default 1)
+	EnvSeed = "JMK_CHAOS_SEED"
+	// Fraction (0 to 1) of media downloads that fail
+	EnvDownloadFailureRate = "JMK_CHAOS_DOWNLOAD_FAILURE_RATE"
+	// Delay added to every database write, e.g. 200ms
+	EnvDBDelay = "JMK_CHAOS_DB_DELAY"
+	// Fraction (0 to 1) of database writes that find the database busy
+	EnvDBBusyRate = "JMK_CHAOS_DB_BUSY_RATE"
+	// Interval at which the WhatsApp connection is dropped, e.g. 5m
+	EnvDisconnectEvery = "JMK_CHAOS_DISCONNECT_EVERY"
+	// How long a dropped connection stays down (default 10s)
+	EnvDisconnectFor = "JMK_CHAOS_DISCONNECT_FOR"
+)
+
+// defaultDisconnectFor is how long a dropped connection stays down unless JMK_CHAOS_DISCONNECT_FOR says otherwise
+const defaultDisconnectFor = 10 * time.Second
+
+// ErrInjected marks failures made up by this package
+var ErrInjected = errors.New("chaos: injected fault")
+
+// settings are the faults turned on; the zero value injects none
+type settings struct {
+	downloadFailureRate float64
+	dbDelay             time.Duration
+	dbBusyRate          float64
+	disconnectEvery     time.Duration
+	disconnectFor       time.Duration
+}
+
+var (
+	faults settings
+	// random draws the faults; guarded by mu, so a seed gives the same sequence of draws
+	mu     sync.Mutex
+	random = rand.New(rand.NewSource(1))
+)
+
+// Configure reads the JMK_CHAOS_* environment variables and returns a description of each fault they
+// turn on, for the startup log. It must be called before the bridge starts handling messages.
+func Configure() ([]string, error) {
+	var s settings
+	var err error
+	if s.downloadFailureRate, err = rateEnv(EnvDownloadFailureRate); err != nil {
+		return nil, err
+	}
+	if s.dbBusyRate, err = rateEnv(EnvDBBusyRate); err != nil {
+		return nil, err
+	}
+	if s.dbDelay, err = durationEnv(EnvDBDelay); err != nil {
+		return nil, err
+	}
+	if s.disconnectEvery, err = durationEnv(EnvDisconnectEvery); err != nil {
+		return nil, err
+	}
+	if s.disconnectFor, err = durationEnv(EnvDisconnectFor); err != nil {
+		return nil, err
+	}
+	if s.disconnectFor == 0 {
+		s.disconnectFor = defaultDisconnectFor
+	}
+	seed := int64(1)
+	if value := os.Getenv(EnvSeed); value != "" {
+		if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", EnvSeed, err)
+		}
+	}
+
+	var active []string
+	if s.downloadFailureRate > 0 {
+		active = append(active, fmt.Sprintf("%.0f%% of media downloads fail", s.downloadFailureRate*100))
+	}
+	if s.dbDelay > 0 {
+		active = append(active, fmt.Sprintf("database writes take %s longer", s.dbDelay))
+	}
+	if s.dbBusyRate > 0 {
+		active = append(active, fmt.Sprintf("%.0f%% of database writes find the database busy", s.dbBusyRate*100))
+	}
+	if s.disconnectEvery > 0 {
+		active = append(active, fmt.Sprintf("the WhatsApp connection drops every %s for %s", s.disconnectEvery, s.disconnectFor))
+	}
+	if len(active) > 0 {
+		active = append(active, fmt.Sprintf("random faults follow seed %d", seed))
+	}
+
+	mu.Lock()
+	faults = s
+	random = rand.New(rand.NewSource(seed))
+	mu.Unlock()
+	return active, nil
+}
+
+// rateEnv parses a fraction between 0 and 1 from the environment variable name, 0 if unset
+func rateEnv(name string) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid %s %q, use a fraction between 0 and 1", name, value)
+	}
+	return rate, nil
+}
+
+// durationEnv parses a duration such as 200ms from the environment variable name, 0 if unset
+func durationEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q, use a duration such as 500ms or 5m", name, value)
+	}
+	return d, nil
+}
+
+// roll reports whether a fault with the given rate happens this time
+func roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return random.Float64() < rate
+}
+
+// FailDownload returns an error for the share of media downloads set to fail
+func FailDownload() error {
+	if roll(faults.downloadFailureRate) {
+		return fmt.Errorf("%w: download failed", ErrInjected)
+	}
+	return nil
+}
+
+// SlowWrite delays a database write by the configured delay and reports whether it should find the
+// database busy
+func SlowWrite() (busy bool) {
+	if faults.dbDelay > 0 {
+		time.Sleep(faults.dbDelay)
+	}
+	return roll(faults.dbBusyRate)
+}
+
+// Disconnects drops the WhatsApp connection at the configured interval until ctx is done: drop is called,
+// and restore once the connection has been down for the configured time. It returns at once if
+// disconnects aren't turned on.
+func Disconnects(ctx context.Context, drop, restore func()) {
+	if faults.disconnectEvery <= 0 {
+		return
+	}
+	ticker := time.NewTicker(faults.disconnectEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fmt.Printf("[CHAOS] Dropping the WhatsApp connection for %s\n", faults.disconnectFor)
+		drop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(faults.disconnectFor):
+		}
+		fmt.Println("[CHAOS] Restoring the WhatsApp connection")
+		restore()
+	}
+}
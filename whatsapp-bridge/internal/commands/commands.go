@@ -0,0 +1,80 @@
+// Package commands answers the commands the owner of the linked account sends to themselves, in
+// WhatsApp's "message yourself" chat, such as "paid 3". Other packages register the commands they offer.
+package commands
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"whatsapp-client/internal/i18n"
+)
+
+// Command is something the owner can ask the bridge for in their own chat
+type Command struct {
+	// Words that start the command in lower case, e.g. "paid" and "שולם"; the first is shown in help
+	Names []string
+	// Arguments shown in help, e.g. "<number>"
+	Usage string
+	// i18n key of the command's description
+	Help string
+	// Run carries out the command with the words that followed its name and returns the reply
+	Run func(ctx context.Context, args []string) string
+}
+
+var (
+	mu       sync.Mutex
+	commands []Command
+)
+
+// Register offers a command; a command registered again under the same first name replaces the earlier one
+func Register(command Command) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, registered := range commands {
+		if registered.Names[0] == command.Names[0] {
+			commands[i] = command
+			return
+		}
+	}
+	commands = append(commands, command)
+}
+
+// Run carries out the command text starts with, in any case, and returns its reply, or ok false when
+// text isn't a command. Replies start with an emoji, so they are never taken for a command themselves.
+func Run(ctx context.Context, text string) (reply string, ok bool) {
+	words := strings.Fields(strings.TrimLeft(strings.TrimSpace(text), "/!"))
+	if len(words) == 0 {
+		return "", false
+	}
+	name := strings.ToLower(words[0])
+	if name == "help" || name == "עזרה" {
+		return help(), true
+	}
+	mu.Lock()
+	var found *Command
+	for i := range commands {
+		for _, commandName := range commands[i].Names {
+			if name == commandName {
+				found = &commands[i]
+			}
+		}
+	}
+	mu.Unlock()
+	if found == nil {
+		return "", false
+	}
+	return found.Run(ctx, words[1:]), true
+}
+
+// help lists the registered commands
+func help() string {
+	mu.Lock()
+	defer mu.Unlock()
+	lines := []string{i18n.T("commands.help")}
+	for _, command := range commands {
+		usage := strings.TrimSpace(command.Names[0] + " " + command.Usage)
+		lines = append(lines, "• "+usage+": "+i18n.T(command.Help))
+	}
+	return strings.Join(lines, "\n")
+}
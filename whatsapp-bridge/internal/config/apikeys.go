@@ -0,0 +1,158 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// API operations a key can be granted
+const (
+	OperationSend   = "send"
+	OperationRead   = "read"
+	OperationDelete = "delete"
+	OperationAdmin  = "admin"
+)
+
+// Roles of users who log in, each granting a set of operations: admins may do everything, senders may
+// read and forward photos, viewers may only read, e.g. grandparents browsing the gallery
+const (
+	RoleAdmin  = "admin"
+	RoleSender = "sender"
+	RoleViewer = "viewer"
+)
+
+// Roles lists the roles a user can have
+var Roles = []string{RoleAdmin, RoleSender, RoleViewer}
+
+// RoleOperations returns the operations a role is granted
+func RoleOperations(role string) []string {
+	switch role {
+	case RoleAdmin:
+		return []string{"*"}
+	case RoleSender:
+		return []string{OperationRead, OperationSend}
+	case RoleViewer:
+		return []string{OperationRead}
+	}
+	return nil
+}
+
+// OIDCConfig lets people log in through an OpenID Connect provider such as Google or Authentik instead
+// of with a password. Their role follows the groups the provider puts into the ID token.
+type OIDCConfig struct {
+	// Issuer URL, e.g. https://accounts.google.com or https://auth.example.com/application/o/bridge/;
+	// empty turns OIDC login off
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// The bridge's callback as registered with the provider, e.g. https://bridge.example.com/api/oidc/callback
+	RedirectURL string `json:"redirect_url"`
+	// Scopes requested besides openid
+	Scopes []string `json:"scopes"`
+	// ID token claim with the user's name, e.g. email or preferred_username
+	UsernameClaim string `json:"username_claim"`
+	// ID token claim with the user's groups
+	GroupsClaim string `json:"groups_claim"`
+	// Role of the members of each group; with several, the one granting the most wins
+	GroupRoles map[string]string `json:"group_roles"`
+	// Role of single users by name, over their groups' roles, for providers without groups such as Google
+	UserRoles map[string]string `json:"user_roles"`
+	// Role of everyone else; empty refuses them
+	DefaultRole string `json:"default_role"`
+	// Where the browser goes after logging in
+	AfterLoginURL string `json:"after_login_url"`
+}
+
+// Enabled reports whether OIDC login is configured
+func (o *OIDCConfig) Enabled() bool {
+	return o.Issuer != ""
+}
+
+// Role returns the role of a user logging in with the given name and groups, or "" if they may not log in
+func (o *OIDCConfig) Role(name string, groups []string) string {
+	if role, ok := o.UserRoles[name]; ok {
+		return role
+	}
+	best := -1
+	for _, group := range groups {
+		if rank := slices.Index(Roles, o.GroupRoles[group]); rank >= 0 && (best < 0 || rank < best) {
+			best = rank
+		}
+	}
+	if best >= 0 {
+		return Roles[best]
+	}
+	return o.DefaultRole
+}
+
+// APIKeyConfig is an API token together with what it may do. A key without chats or destinations
+// is not restricted to specific chats; a key without operations may do nothing.
+type APIKeyConfig struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Chat JIDs or phone numbers the key may access
+	Chats []string `json:"chats"`
+	// Names of configured destinations whose groups the key may access
+	Destinations []string `json:"destinations"`
+	// Allowed operations: send, read, delete, admin, or * for all
+	Operations []string `json:"operations"`
+}
+
+// Allows reports whether the key was granted the operation
+func (k *APIKeyConfig) Allows(operation string) bool {
+	for _, op := range k.Operations {
+		if op == operation || op == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Scoped reports whether the key is restricted to specific chats
+func (k *APIKeyConfig) Scoped() bool {
+	return len(k.Chats) > 0 || len(k.Destinations) > 0
+}
+
+// JIDUser returns the user part of a JID or phone number ("+972501234567", "972501234567@s.whatsapp.net:1")
+func JIDUser(jid string) string {
+	user := strings.TrimPrefix(jid, "+")
+	if i := strings.IndexAny(user, "@:"); i >= 0 {
+		user = user[:i]
+	}
+	return user
+}
+
+// DestinationFor returns the configured destination whose group is jid, a JID or phone number
+func DestinationFor(jid string) (DestinationConfig, bool) {
+	for _, dest := range Current().Destinations {
+		if dest.Group != "" && JIDUser(dest.Group) == JIDUser(jid) {
+			return dest, true
+		}
+	}
+	return DestinationConfig{}, false
+}
+
+// AllowsChat reports whether the key may access the given chat JID or phone number
+func (k *APIKeyConfig) AllowsChat(chatJID string) bool {
+	if !k.Scoped() {
+		return true
+	}
+	if chatJID == "" {
+		return false
+	}
+	if jid, isAlias := LookupGroupAlias(chatJID); isAlias {
+		chatJID = jid
+	}
+	allowed := append([]string{}, k.Chats...)
+	for _, name := range k.Destinations {
+		if dest, ok := Current().Destinations[name]; ok {
+			allowed = append(allowed, dest.Group)
+		}
+	}
+	for _, chat := range allowed {
+		if chat == chatJID || JIDUser(chat) == JIDUser(chatJID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SchemaError is a place where a config file doesn't match the schema
+type SchemaError struct {
+	Line int
+	// Dotted key of the setting, with list positions as [i]
+	Key     string
+	Message string
+}
+
+// String formats the error as line: key: message
+func (e SchemaError) String() string {
+	if e.Key == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Key, e.Message)
+}
+
+// CheckSchema checks the contents of a config file against ConfigSchema and returns every mismatch with
+// the line it is on: unknown keys, values of the wrong type, and values outside the allowed ones. A
+// file that isn't valid JSON returns the syntax error alone.
+func CheckSchema(data []byte) []SchemaError {
+	checker := &schemaChecker{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	checker.dec.UseNumber()
+	if err := checker.value(ConfigSchema(), ""); err != nil {
+		return []SchemaError{checker.syntaxError(err)}
+	}
+	if _, err := checker.dec.Token(); err != io.EOF {
+		return []SchemaError{{Line: checker.lineAt(checker.dec.InputOffset()), Message: "unexpected content after the end of the configuration"}}
+	}
+	return checker.errors
+}
+
+// schemaChecker walks the tokens of a config file alongside the schema
+type schemaChecker struct {
+	data   []byte
+	dec    *json.Decoder
+	errors []SchemaError
+}
+
+// anyValue is the schema of values that aren't checked, such as the contents of unknown keys
+var anyValue = &Schema{}
+
+// value reads the next value from the decoder and checks it against schema
+func (c *schemaChecker) value(schema *Schema, key string) error {
+	line := c.lineAt(c.dec.InputOffset())
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	fail := func(format string, args ...interface{}) {
+		c.errors = append(c.errors, SchemaError{Line: line, Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			if schema.Type != "" && schema.Type != "object" {
+				fail("must be %s, not an object", describeType(schema.Type))
+				schema = anyValue
+			}
+			return c.object(schema, key)
+		}
+		if schema.Type != "" && schema.Type != "array" {
+			fail("must be %s, not a list", describeType(schema.Type))
+			schema = anyValue
+		}
+		items := schema.Items
+		if items == nil {
+			items = anyValue
+		}
+		for i := 0; c.dec.More(); i++ {
+			if err := c.value(items, fmt.Sprintf("%s[%d]", key, i)); err != nil {
+				return err
+			}
+		}
+		_, err := c.dec.Token()
+		return err
+
+	case string:
+		if schema.Type != "" && schema.Type != "string" {
+			fail("must be %s, not the text %q", describeType(schema.Type), v)
+		} else if v != "" && len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(allowed string) bool { return strings.EqualFold(allowed, v) }) {
+			fail("%q is not one of %s", v, strings.Join(quoteAll(schema.Enum), ", "))
+		}
+
+	case json.Number:
+		switch schema.Type {
+		case "", "number":
+		case "integer":
+			n, err := strconv.ParseInt(v.String(), 10, 64)
+			if err != nil {
+				fail("must be a whole number, not %s", v)
+			} else if schema.Minimum != nil && n < *schema.Minimum {
+				fail("%d is below the minimum of %d", n, *schema.Minimum)
+			} else if schema.Maximum != nil && n > *schema.Maximum {
+				fail("%d is above the maximum of %d", n, *schema.Maximum)
+			}
+		default:
+			fail("must be %s, not the number %s", describeType(schema.Type), v)
+		}
+
+	case bool:
+		if schema.Type != "" && schema.Type != "boolean" {
+			fail("must be %s, not %t", describeType(schema.Type), v)
+		}
+	}
+	// null leaves a setting at its default, like leaving it out
+	return nil
+}
+
+// object checks the keys and values of an object whose opening brace has been read
+func (c *schemaChecker) object(schema *Schema, key string) error {
+	for c.dec.More() {
+		line := c.lineAt(c.dec.InputOffset())
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		child := property(schema, name)
+		if child == nil {
+			switch additional := schema.AdditionalProperties.(type) {
+			case *Schema:
+				child = additional
+			case bool:
+				message := "unknown key, it is ignored"
+				if suggestion := closestKey(name, schema.Properties); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				c.errors = append(c.errors, SchemaError{Line: line, Key: childKey(key, name), Message: message})
+			}
+		}
+		if child == nil {
+			child = anyValue
+		}
+		if err := c.value(child, childKey(key, name)); err != nil {
+			return err
+		}
+	}
+	_, err := c.dec.Token()
+	return err
+}
+
+// property returns the schema of key name of an object, matched without regard to case like config.json
+// is read, or nil if the object has no such key
+func property(schema *Schema, name string) *Schema {
+	if child, ok := schema.Properties[name]; ok {
+		return child
+	}
+	for known, child := range schema.Properties {
+		if strings.EqualFold(known, name) {
+			return child
+		}
+	}
+	return nil
+}
+
+// lineAt returns the line of the first token at or after offset, skipping the separators before it
+func (c *schemaChecker) lineAt(offset int64) int {
+	i := int(offset)
+	for i < len(c.data) && strings.IndexByte(" \t\r\n,:", c.data[i]) >= 0 {
+		i++
+	}
+	return bytes.Count(c.data[:i], []byte("\n")) + 1
+}
+
+// syntaxError turns a decoding error into a SchemaError on the line it happened
+func (c *schemaChecker) syntaxError(err error) SchemaError {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line := bytes.Count(c.data[:syntaxErr.Offset], []byte("\n")) + 1
+		return SchemaError{Line: line, Message: "not valid JSON: " + syntaxErr.Error()}
+	}
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return SchemaError{Line: bytes.Count(c.data, []byte("\n")) + 1, Message: "the configuration ends too early, a closing } or ] is missing"}
+	}
+	return SchemaError{Line: c.lineAt(c.dec.InputOffset()), Message: err.Error()}
+}
+
+// describeType names a schema type for error messages
+func describeType(schemaType string) string {
+	switch schemaType {
+	case "object":
+		return "an object"
+	case "array":
+		return "a list"
+	case "integer":
+		return "a whole number"
+	case "boolean":
+		return "true or false"
+	}
+	return "a " + schemaType
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return quoted
+}
+
+// closestKey returns the known key that a misspelled one most likely meant, or "" if none is close
+func closestKey(name string, properties map[string]*Schema) string {
+	known := slices.Sorted(maps.Keys(properties))
+	best, bestDistance := "", 3
+	for _, candidate := range known {
+		if d := editDistance(strings.ToLower(name), candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		row := make([]int, len(b)+1)
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			row[j] = min(previous[j]+1, row[j-1]+1, previous[j-1]+cost)
+		}
+		previous = row
+	}
+	return previous[len(b)]
+}
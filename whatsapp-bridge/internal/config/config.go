@@ -0,0 +1,640 @@
+// Package config holds the bridge configuration read from config.json.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Config represents the application configuration
+type Config struct {
+	// Port of the REST API; the -port flag takes precedence
+	APIPort int `json:"api_port"`
+	// Directory of the databases and downloaded media, relative to the bridge's working directory
+	DataDir string `json:"data_dir"`
+	// IANA timezone such as Asia/Jerusalem in which days start and times are shown: in exports, reports,
+	// photo books and presence windows. Empty for the machine's timezone. Timestamps are stored in UTC.
+	Timezone string `json:"timezone"`
+	// Language of the texts the bridge writes itself: reports, alerts, photo book captions and pages and
+	// the watermark date. en or he.
+	Locale string `json:"locale"`
+	// Group JIDs (…@g.us) whose messages are stored and whose photos are matched
+	InputGroups []string `json:"input_groups"`
+	// Regular expressions over group names: joined groups whose name matches one are monitored like
+	// input_groups, including groups created later, e.g. "^גן .*2025$" for each year's class groups
+	InputGroupPatterns []string `json:"input_group_patterns"`
+	// Channel JIDs (…@newsletter) whose posts are stored and whose photos are matched
+	InputChannels []string `json:"input_channels"`
+	// Where photos of each child are forwarded, by the child's name in the reference photos
+	Destinations map[string]DestinationConfig `json:"destinations"`
+	// Named photo pipelines running next to the top-level one, e.g. "after_school", each with its own
+	// groups, filters, face matching and destinations
+	Pipelines map[string]PipelineConfig `json:"pipelines"`
+	// Names that can be written instead of group JIDs in input_groups, destinations and the other chat
+	// settings, each standing for the joined group with exactly this name. They are resolved whenever the
+	// bridge connects, so a group recreated under the same name needs no config change.
+	GroupAliases    map[string]string     `json:"group_aliases"`
+	Media           MediaConfig           `json:"media"`
+	Privacy         PrivacyConfig         `json:"privacy"`
+	Calendar        CalendarConfig        `json:"calendar"`
+	APIKeys         []APIKeyConfig        `json:"api_keys"`
+	OIDC            OIDCConfig            `json:"oidc"`
+	Tracing         TracingConfig         `json:"tracing"`
+	History         HistoryConfig         `json:"history"`
+	Publisher       PublisherConfig       `json:"publisher"`
+	Timeouts        TimeoutsConfig        `json:"timeouts"`
+	Liveness        LivenessConfig        `json:"liveness"`
+	CORS            CORSConfig            `json:"cors"`
+	AccessLog       AccessLogConfig       `json:"access_log"`
+	Logs            LogsConfig            `json:"logs"`
+	Alerts          AlertsConfig          `json:"alerts"`
+	Presence        PresenceConfig        `json:"presence"`
+	Chats           ChatsConfig           `json:"chats"`
+	Spam            SpamConfig            `json:"spam"`
+	Archive         ArchiveConfig         `json:"archive"`
+	Stats           StatsConfig           `json:"stats"`
+	PhotoBook       PhotoBookConfig       `json:"photo_book"`
+	Ask             AskConfig             `json:"ask"`
+	Embeddings      EmbeddingsConfig      `json:"embeddings"`
+	Topics          TopicsConfig          `json:"topics"`
+	Payments        PaymentsConfig        `json:"payments"`
+	Forms           FormsConfig           `json:"forms"`
+	Absence         AbsenceConfig         `json:"absence"`
+	Acknowledgments AcknowledgmentsConfig `json:"acknowledgments"`
+	AutoReply       AutoReplyConfig       `json:"auto_reply"`
+	Welcome         WelcomeConfig         `json:"welcome"`
+}
+
+// StatsConfig controls the activity reports the bridge posts by itself
+type StatsConfig struct {
+	MonthlyReport MonthlyReportConfig `json:"monthly_report"`
+	// Day of the week (mon-sun) the destinations' weekly_photos are checked, at noon
+	WeeklyCheckDay string `json:"weekly_check_day"`
+}
+
+// MonthlyReportConfig posts last month's activity of monitored groups on the first of every month
+type MonthlyReportConfig struct {
+	// Group JID or phone number the report is sent to; empty turns the report off
+	ChatJID string `json:"chat_jid"`
+	// Groups to report on; empty for every input group
+	Chats []string `json:"chats"`
+}
+
+// PhotoBookConfig makes a printable PDF photo book of last month's photos for every destination on the
+// first of the month
+type PhotoBookConfig struct {
+	Enabled bool `json:"enabled"`
+	// Where the books are saved, as <dir>/<destination>/<YYYY-MM>.pdf; defaults to photobooks in the data directory
+	Dir string `json:"dir"`
+	// Also send each book into its destination's chat
+	Share bool `json:"share"`
+	// .ttf or .otf font to write with, needed for Hebrew names and captions
+	FontPath string `json:"font_path"`
+}
+
+// AskConfig tunes POST /api/ask, which finds the stored messages that answer a question
+type AskConfig struct {
+	// Most messages returned for a question and handed to the LLM
+	MaxMessages int `json:"max_messages"`
+	// Model that writes an answer from the messages found; without a URL only the messages are returned
+	LLM LLMConfig `json:"llm"`
+}
+
+// LLMConfig is a chat completions endpoint of the OpenAI API or a server compatible with it, such as
+// Ollama or llama.cpp. The messages found for a question are sent to it.
+type LLMConfig struct {
+	// Chat completions URL, e.g. http://localhost:11434/v1/chat/completions
+	URL   string `json:"url"`
+	Model string `json:"model"`
+	// Bearer token, or ${NAME} to read it from the environment variable NAME
+	APIKey         string `json:"api_key"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// Embedders of stored messages
+const (
+	EmbedderLocal  = "local"
+	EmbedderRemote = "remote"
+)
+
+// EmbeddingsConfig indexes stored messages by meaning for semantic search, alongside the full-text index
+type EmbeddingsConfig struct {
+	// local for the built-in embedder of words and their spelling, remote for an embeddings endpoint, or
+	// empty to turn semantic search off
+	Embedder string `json:"embedder"`
+	// Embeddings URL of the OpenAI API or a server compatible with it, e.g. http://localhost:11434/v1/embeddings
+	URL   string `json:"url"`
+	Model string `json:"model"`
+	// Bearer token, or ${NAME} to read it from the environment variable NAME
+	APIKey string `json:"api_key"`
+	// Messages embedded per request
+	BatchSize int `json:"batch_size"`
+}
+
+// Built-in topics stored messages are tagged with
+const (
+	TopicLogistics = "logistics"
+	TopicHealth    = "health"
+	TopicPhotos    = "photos"
+	TopicPayments  = "payments"
+	TopicEvents    = "events"
+)
+
+// BuiltinTopics are the topics tagged without configuration
+var BuiltinTopics = []string{TopicLogistics, TopicHealth, TopicPhotos, TopicPayments, TopicEvents}
+
+// topicName is a valid topic name; topics are stored comma-separated
+var topicName = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// TopicsConfig tags stored messages with what they are about, such as health or payments. Exports,
+// searches and stats can be limited to a topic, and new messages of a topic can be sent on to another chat.
+type TopicsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Extra words per topic, on top of the built-in ones of logistics, health, photos, payments and events;
+	// other names add topics of their own. A word matches the words starting with it, so Hebrew stems match
+	// their forms; words of up to three letters only match themselves, and words with spaces or symbols
+	// match anywhere in the text.
+	Keywords map[string][]string `json:"keywords"`
+	// Optional classifier, such as a machine learning model, that receives {"text", "media_type"} and
+	// returns {"topics": [...]}; when set it replaces the keywords, which are still used while it fails
+	ClassifierURL string `json:"classifier_url"`
+	// Chats that get a copy of new messages of monitored chats tagged with a topic
+	Forwards []TopicForward `json:"forwards"`
+}
+
+// TopicForward sends new messages of a topic to a chat, e.g. health messages to the other parent
+type TopicForward struct {
+	Topic string `json:"topic"`
+	// Group JID, group alias or phone number with country code
+	ChatJID string `json:"chat_jid"`
+	// Also send the message's photo or video. Off by default, as photos reach family only through face
+	// matching.
+	Media bool `json:"media"`
+}
+
+// AcknowledgmentsConfig tracks whether the chats messages are forwarded to by topic saw them: a reply to
+// or a reaction on the forward acknowledges it, and so does any message in a chat with one person.
+// Forwards nobody acknowledged are sent again as a nudge.
+type AcknowledgmentsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Hours after a forward, or the last nudge, before a chat that didn't acknowledge it is nudged
+	NudgeHours int `json:"nudge_hours"`
+	// Nudges sent per forward; 0 only tracks acknowledgments
+	MaxNudges int `json:"max_nudges"`
+}
+
+// WelcomeConfig greets the people who join groups. Who joins and leaves the monitored groups is
+// recorded either way.
+type WelcomeConfig struct {
+	// Greeting per group; a group may be greeted without being monitored
+	Messages []WelcomeMessage `json:"messages"`
+}
+
+// WelcomeMessage is the greeting of a group
+type WelcomeMessage struct {
+	// Group JID or group alias
+	ChatJID string `json:"chat_jid"`
+	// {name} is replaced by a mention of each person who joined, e.g. the group's rules with "Welcome {name}!"
+	Text string `json:"text"`
+}
+
+// AutoReplyConfig answers messages to the bridge's number by rules, e.g. telling strangers it is an
+// automated archive and whom to contact instead
+type AutoReplyConfig struct {
+	Enabled bool `json:"enabled"`
+	// Tried in order; the first that matches a message answers it
+	Rules []AutoReplyRule `json:"rules"`
+	// Hours before the same sender is answered again
+	RateLimitHours int `json:"rate_limit_hours"`
+	// Phone numbers never answered, e.g. family. Senders can also opt out by sending "stop".
+	OptOut []string `json:"opt_out"`
+	// Only answer senders who aren't among the account's contacts
+	StrangersOnly bool `json:"strangers_only"`
+}
+
+// AutoReplyRule is a reply and the messages it answers
+type AutoReplyRule struct {
+	// direct (default) for chats with the bridge's number, groups for monitored groups
+	Chats string `json:"chats"`
+	// Words of which a message must contain one; empty matches every message. Required for groups.
+	Keywords []string `json:"keywords"`
+	Text     string   `json:"text"`
+}
+
+// Chats an auto-reply rule answers
+const (
+	AutoReplyDirect = "direct"
+	AutoReplyGroups = "groups"
+)
+
+// PaymentsConfig finds requests for money in monitored chats, such as a Bit or PayBox link or "please
+// transfer 50₪", and reminds about each until it is marked paid, through the API or by sending
+// "paid <number>" to yourself
+type PaymentsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Group JID, group alias or phone number reminders are sent to; empty for the bridge account's chat
+	// with itself ("message yourself")
+	ChatJID string `json:"chat_jid"`
+	// Hours between reminders of a request that isn't paid; the first is sent when it is found
+	ReminderHours int `json:"reminder_hours"`
+	// Reminders sent per request, counting the first
+	MaxReminders int `json:"max_reminders"`
+	// Extra words that mark a message with an amount as asking for money, on top of the built-in list
+	Keywords []string `json:"keywords"`
+}
+
+// FormsConfig finds forms parents must fill in or sign, such as permission slips and health
+// declarations, among the documents and photos of monitored chats, and reminds about each until it is
+// acknowledged, through the API or by sending "ack <number>" to yourself
+type FormsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Group JID, group alias or phone number reminders are sent to; empty for the bridge account's chat
+	// with itself ("message yourself")
+	ChatJID string `json:"chat_jid"`
+	// Hours between reminders of a form that isn't acknowledged; the first is sent when it is found
+	ReminderHours int `json:"reminder_hours"`
+	// Extra words that mark a document or photo as a form, on top of the built-in list
+	Keywords []string `json:"keywords"`
+	// Optional OCR service that receives a photo and returns {"text"}, so forms are recognized by what
+	// they say rather than only by their caption
+	OCRURL string `json:"ocr_url"`
+}
+
+// AbsenceConfig words the messages that tell a child's teacher the child won't come, sent with
+// POST /api/actions/report-absence or by sending "sick <child>" to yourself
+type AbsenceConfig struct {
+	// Message per reason (sick, absent) replacing the built-in one of the locale; {name} is replaced by
+	// the child's name and {date} by the day of the absence
+	Templates map[string]string `json:"templates"`
+}
+
+// Reasons of an absence
+const (
+	AbsenceSick   = "sick"
+	AbsenceAbsent = "absent"
+)
+
+// AbsenceReasons are the reasons an absence can be reported with
+var AbsenceReasons = []string{AbsenceSick, AbsenceAbsent}
+
+// ArchiveConfig keeps more of each received message than the bridge parses today
+type ArchiveConfig struct {
+	// Store the raw protobuf of every received message, so content the bridge can't parse yet (polls, new
+	// message types) can be extracted later. Not kept for media-only groups.
+	RawMessages bool `json:"raw_messages"`
+}
+
+// SpamConfig scores incoming messages of monitored chats. Messages reaching the threshold are stored
+// tagged as spam and are not forwarded or shown in feeds. Rule scores of 0 use the default, -1 turns
+// the rule off.
+type SpamConfig struct {
+	Enabled   bool `json:"enabled"`
+	Threshold int  `json:"threshold"`
+	// Message that is nothing but links
+	LinkOnly int `json:"link_only"`
+	// Sender who isn't a phone contact and hasn't posted in the chat before
+	UnknownSender int `json:"unknown_sender"`
+	// Text already posted within repeat_window_hours, in any chat
+	Repeated          int `json:"repeated"`
+	RepeatWindowHours int `json:"repeat_window_hours"`
+	// Phone numbers or JIDs whose messages are never spam
+	TrustedSenders []string `json:"trusted_senders"`
+}
+
+// ChatsConfig controls how monitored chats are kept on the linked phone
+type ChatsConfig struct {
+	// Archive monitored groups and channels on the phone once the bridge has stored their messages,
+	// so they don't crowd the chat list
+	ArchiveMonitored bool `json:"archive_monitored"`
+}
+
+// Presence modes
+const (
+	PresenceUnavailable = "unavailable"
+	PresenceAvailable   = "available"
+	PresenceSchedule    = "schedule"
+)
+
+// PresenceConfig controls when the linked account shows as online to contacts. Online also sends read
+// receipts as "active", which makes contacts think their messages were read.
+type PresenceConfig struct {
+	// unavailable (never online), available (always online) or schedule (online during windows)
+	Mode    string           `json:"mode"`
+	Windows []PresenceWindow `json:"windows"`
+}
+
+// PresenceWindow is a time of day, in local time, during which a schedule shows the account online
+type PresenceWindow struct {
+	// mon, tue, wed, thu, fri, sat, sun; every day if empty
+	Days []string `json:"days"`
+	// HH:MM; a window whose end is before its start runs past midnight
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AlertsConfig sends problems that need attention, such as a full disk, to a WhatsApp chat
+type AlertsConfig struct {
+	// Group JID or phone number; empty only logs alerts
+	ChatJID string `json:"chat_jid"`
+	// Also send panics the bridge recovered from, such as one caused by a malformed message. They are
+	// always written to crashes.log in the data directory.
+	Crashes bool `json:"crashes"`
+}
+
+// HistoryConfig controls how history syncs from the phone are processed
+type HistoryConfig struct {
+	// Conversations stored in parallel
+	Workers int `json:"workers"`
+	// Download photos of synced history too, instead of only storing their messages
+	DownloadMedia bool `json:"download_media"`
+}
+
+// DestinationConfig is the chat a child's photos are forwarded to
+type DestinationConfig struct {
+	Name  string `json:"name"`
+	Group string `json:"group"`
+	// Only record sends to this destination instead of performing them
+	DryRun bool `json:"dry_run"`
+	// Text stamped onto photos sent to this destination
+	Watermark WatermarkConfig `json:"watermark"`
+	// Alert when fewer photos than expected were forwarded in a week
+	WeeklyPhotos WeeklyPhotosConfig `json:"weekly_photos"`
+	// Phone number or JID of the child's teacher, where absence reports are sent
+	TeacherJID string `json:"teacher_jid"`
+}
+
+// PipelineConfig is a set of input groups whose photos are matched against their own reference photos
+// and forwarded to their own destinations. Its photos wait in pipelines/<name> of the media directory
+// for a face detection service started with --pipeline <name>.
+type PipelineConfig struct {
+	// Group JIDs (…@g.us) or aliases whose photos go through this pipeline
+	InputGroups []string `json:"input_groups"`
+	// Channel JIDs (…@newsletter) whose photos go through this pipeline
+	InputChannels []string `json:"input_channels"`
+	// Phone numbers whose photos are matched, e.g. the teachers'; empty matches everyone's photos
+	Senders []string `json:"senders"`
+	// Where photos of each child are forwarded; names must differ from those of other pipelines
+	Destinations map[string]DestinationConfig `json:"destinations"`
+	// Face matching of this pipeline, over the top-level face_detection settings
+	FaceDetection FaceDetectionSettings `json:"face_detection"`
+	// Keep the messages of this pipeline in a database of its own, pipelines/<name>/messages.db in the
+	// data directory, and its media in pipelines/<name> of the media directory, so its archive can be
+	// handed over or deleted without touching the others
+	Isolated bool `json:"isolated"`
+}
+
+// WeeklyPhotosConfig is the number of photos a destination expects per week. When the forward ledger
+// shows fewer, a message is sent, so parents know to ask the teacher.
+type WeeklyPhotosConfig struct {
+	// 0 turns the check off
+	Minimum int `json:"minimum"`
+	// Group JID or phone number told about a shortfall; empty uses alerts.chat_jid
+	ChatJID string `json:"chat_jid"`
+}
+
+// Watermark positions
+const (
+	PositionBottomRight = "bottom-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionTopRight    = "top-right"
+	PositionTopLeft     = "top-left"
+)
+
+// WatermarkConfig stamps forwarded photos with a small caption, e.g. for a digital photo frame that
+// shows no WhatsApp captions
+type WatermarkConfig struct {
+	Enabled bool `json:"enabled"`
+	// {name} is replaced by the destination's name and {date} by the day the photo was received
+	Text     string `json:"text"`
+	Position string `json:"position"`
+	// TrueType/OpenType font for names in scripts the built-in Go font lacks, such as Hebrew
+	FontPath string `json:"font_path"`
+}
+
+// MediaConfig controls which media is downloaded and stored, and how photos are converted
+type MediaConfig struct {
+	AllowedExtensions []string `json:"allowed_extensions"`
+	// Where media is stored, relative to the directory of config.json; empty for media in data_dir
+	StorePath string `json:"store_path"`
+	// JPEG quality (1-100) of images re-encoded before sending
+	JPEGQuality int `json:"jpeg_quality"`
+	// Limits for downloading media of new messages and for backfill (history sync, replay, re-downloads)
+	LiveDownloads    DownloadLimits `json:"live_downloads"`
+	HistoryDownloads DownloadLimits `json:"history_downloads"`
+	// Originals stop being downloaded (thumbnails are still kept) once media takes up QuotaBytes (0 for
+	// no quota) or the disk has less than MinFreeBytes free
+	QuotaBytes   int64 `json:"quota_bytes"`
+	MinFreeBytes int64 `json:"min_free_bytes"`
+	// Images with more pixels are refused instead of decoded, so a huge photo can't exhaust memory
+	MaxImagePixels int64 `json:"max_image_pixels"`
+}
+
+// DownloadLimits caps concurrent media downloads and their bandwidth; 0 means unlimited
+type DownloadLimits struct {
+	Concurrency    int   `json:"concurrency"`
+	BytesPerSecond int64 `json:"bytes_per_second"`
+}
+
+// PrivacyConfig controls what message content is written to the message store
+type PrivacyConfig struct {
+	RedactionRules  []RedactionRule `json:"redaction_rules"`
+	MediaOnlyGroups []string        `json:"media_only_groups"`
+}
+
+// RedactionRule replaces every match of Pattern in message content with Replacement before storage
+type RedactionRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// CalendarConfig controls event detection in monitored group messages
+type CalendarConfig struct {
+	Enabled bool `json:"enabled"`
+	// Extra words that mark a message as announcing an event, on top of the built-in list
+	Keywords []string `json:"keywords"`
+	// Optional NLP service that receives {"text", "timestamp"} and returns detected events as JSON;
+	// when set it replaces the built-in rules
+	DetectorURL string `json:"detector_url"`
+}
+
+// TracingConfig controls export of pipeline spans to an OpenTelemetry collector over OTLP/HTTP
+type TracingConfig struct {
+	Enabled bool `json:"enabled"`
+	// Base URL of the collector's OTLP/HTTP receiver, e.g. http://localhost:4318
+	Endpoint    string            `json:"otlp_endpoint"`
+	ServiceName string            `json:"service_name"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// Event bus types the publisher supports
+const (
+	PublisherKafka = "kafka"
+	PublisherNATS  = "nats"
+)
+
+// PublisherConfig sends every stored-message event to an event bus as well
+type PublisherConfig struct {
+	// kafka or nats; empty disables publishing
+	Type string `json:"type"`
+	// Kafka REST Proxy (http://localhost:8082) or NATS server (nats://localhost:4222)
+	URL string `json:"url"`
+	// Kafka topic or NATS subject
+	Topic string `json:"topic"`
+	// Basic auth for the REST Proxy, user and password for NATS
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// NATS token authentication
+	Token string `json:"token"`
+}
+
+// TimeoutsConfig bounds how long calls to WhatsApp may take, in seconds, so a hung upload or download
+// fails instead of blocking its request or worker forever
+type TimeoutsConfig struct {
+	SendSeconds     int `json:"send_seconds"`
+	UploadSeconds   int `json:"upload_seconds"`
+	DownloadSeconds int `json:"download_seconds"`
+	// How long in-flight API requests may finish after Ctrl+C before they are cancelled
+	ShutdownSeconds int `json:"shutdown_seconds"`
+	// How long the bridge waits for a QR code to be scanned before it gives up; 0 waits until it is
+	PairingSeconds int `json:"pairing_seconds"`
+}
+
+// LivenessConfig checks that the WhatsApp connection still answers. The websocket can look connected
+// while requests go unanswered; the bridge then pings WhatsApp itself and reconnects once the pings have
+// failed for long enough.
+type LivenessConfig struct {
+	// How often WhatsApp is pinged, in seconds; -1 turns the check off
+	IntervalSeconds int `json:"interval_seconds"`
+	// How long pings may fail while the connection looks up before the bridge reconnects, in seconds
+	FailureWindowSeconds int `json:"failure_window_seconds"`
+}
+
+// AccessLogConfig logs every API request with its method, path, status, latency and the API key or user
+// that made it. Phone numbers in paths and query strings are masked and text parameters, such as the
+// message of a send, are left out; request and response bodies are never logged.
+type AccessLogConfig struct {
+	Enabled bool `json:"enabled"`
+	// Log phone numbers and text parameters as they are, e.g. on a development machine. API keys and
+	// login codes are left out regardless.
+	Unredacted bool `json:"unredacted"`
+}
+
+// Languages the bridge writes its own texts in
+const (
+	LocaleEnglish = "en"
+	LocaleHebrew  = "he"
+)
+
+// Levels of log lines kept in the message store
+const (
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+	LogLevelOff   = "off"
+)
+
+// LogsConfig keeps the bridge's warnings and errors in the message store, where GET /api/logs serves them
+type LogsConfig struct {
+	// Lowest level kept: warn, error, or off to keep none
+	Level string `json:"level"`
+	// How long kept lines are stored
+	RetentionDays int `json:"retention_days"`
+}
+
+// CORSConfig lets browser apps on other origins (a dashboard, a family gallery) call the API
+type CORSConfig struct {
+	// Origins such as http://localhost:3000, or "*" for any; empty disables CORS
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	// How long browsers may cache a preflight response
+	MaxAgeSeconds int `json:"max_age_seconds"`
+}
+
+// current is the configuration the bridge runs with. A reload replaces it as a whole, so readers
+// holding a snapshot from Current never see a half-applied change.
+var current atomic.Pointer[Config]
+
+func init() {
+	current.Store(&Config{})
+}
+
+// secretReference matches values written as ${NAME}, which are read from the environment instead
+var secretReference = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// Load reads and parses a configuration file. A missing file is treated as empty, so the bridge can be
+// configured through JMK_* environment variables alone.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data = []byte("{}")
+	} else if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a configuration, applies JMK_* environment overrides, resolves secrets given as ${NAME}
+// from the environment and fills in defaults. Syntax errors report the line they occur on.
+func Parse(data []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := strings.Count(string(data[:syntaxErr.Offset]), "\n") + 1
+			return cfg, fmt.Errorf("config file is not valid JSON at line %d: %w", line, err)
+		}
+		return cfg, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	cfg.resolveSecrets()
+	cfg.ApplyDefaults()
+	return cfg, nil
+}
+
+// resolveSecrets replaces ${NAME} API keys, tracing headers, publisher credentials, the OIDC client secret and the keys of the LLM and the embedder with the environment variable NAME, so
+// tokens don't have to be written into config.json
+func (c *Config) resolveSecrets() {
+	for i := range c.APIKeys {
+		c.APIKeys[i].Key = expandSecret(c.APIKeys[i].Key)
+	}
+	for name, value := range c.Tracing.Headers {
+		c.Tracing.Headers[name] = expandSecret(value)
+	}
+	c.Publisher.Password = expandSecret(c.Publisher.Password)
+	c.Publisher.Token = expandSecret(c.Publisher.Token)
+	c.OIDC.ClientSecret = expandSecret(c.OIDC.ClientSecret)
+	c.Ask.LLM.APIKey = expandSecret(c.Ask.LLM.APIKey)
+	c.Embeddings.APIKey = expandSecret(c.Embeddings.APIKey)
+}
+
+// expandSecret returns the environment variable a ${NAME} value refers to, or the value itself
+func expandSecret(value string) string {
+	if match := secretReference.FindStringSubmatch(value); match != nil {
+		return os.Getenv(match[1])
+	}
+	return value
+}
+
+// Set makes cfg the configuration used by the bridge, with its group aliases replaced by the JIDs they
+// resolved to and the groups matching input_group_patterns added to its input groups. It is safe to
+// call while messages are handled and requests served.
+func Set(cfg Config) {
+	setting.Lock()
+	defer setting.Unlock()
+	configured.Store(&cfg)
+	publish()
+}
+
+// Current returns the configuration the bridge runs with. It is shared and must not be modified; Set
+// replaces it instead.
+func Current() *Config {
+	return current.Load()
+}
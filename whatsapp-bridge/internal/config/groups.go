@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+var (
+	// configured is the configuration as last set, with group aliases as written
+	configured atomic.Pointer[Config]
+	// groupJIDs maps group aliases to the JIDs of the groups they name, as found on the last connect
+	groupJIDs atomic.Pointer[map[string]string]
+	// discoveredGroups are the JIDs of the joined groups whose names match input_group_patterns
+	discoveredGroups atomic.Pointer[[]string]
+	// setting serializes Set, SetGroupJIDs and SetDiscoveredGroups, so a configuration is never published
+	// with stale groups
+	setting sync.Mutex
+)
+
+func init() {
+	configured.Store(&Config{})
+	groupJIDs.Store(&map[string]string{})
+	discoveredGroups.Store(&[]string{})
+}
+
+// publish makes the configuration as last set current, with aliases resolved and discovered groups
+// added to its input groups. Callers hold setting.
+func publish() {
+	resolved := configured.Load().WithGroupJIDs(*groupJIDs.Load()).withPipelines()
+	for _, jid := range *discoveredGroups.Load() {
+		if !slices.Contains(resolved.InputGroups, jid) {
+			resolved.InputGroups = append(slices.Clip(resolved.InputGroups), jid)
+		}
+	}
+	current.Store(&resolved)
+}
+
+// SetGroupJIDs makes aliases resolve to the given group JIDs, by alias, and updates the current
+// configuration. Aliases missing from jids stay unresolved: they match no chat and can't be sent to.
+func SetGroupJIDs(jids map[string]string) {
+	setting.Lock()
+	defer setting.Unlock()
+	groupJIDs.Store(&jids)
+	publish()
+}
+
+// SetDiscoveredGroups makes the given groups input groups in addition to the configured ones, and
+// updates the current configuration
+func SetDiscoveredGroups(jids []string) {
+	setting.Lock()
+	defer setting.Unlock()
+	discoveredGroups.Store(&jids)
+	publish()
+}
+
+// DiscoveredGroups returns the groups monitored because their names match input_group_patterns
+func DiscoveredGroups() []string {
+	return *discoveredGroups.Load()
+}
+
+// LookupGroupAlias returns the JID of the group an alias names. isAlias is false for names that aren't
+// aliases; jid is empty for aliases that didn't resolve to a group.
+func LookupGroupAlias(name string) (jid string, isAlias bool) {
+	if _, ok := configured.Load().GroupAliases[name]; !ok {
+		return "", false
+	}
+	return (*groupJIDs.Load())[name], true
+}
+
+// IsGroupAlias reports whether name is one of the configured group aliases
+func (c *Config) IsGroupAlias(name string) bool {
+	_, ok := c.GroupAliases[name]
+	return ok
+}
+
+// WithGroupJIDs returns a copy of the configuration with every group alias found in jids replaced by its
+// JID, wherever a chat can be given: input groups and destinations, also those of pipelines, alert and
+// report chats, media-only groups, topic forwards, the payment and form reminder chats, welcomed
+// groups and API key chats. The receiver is left unchanged.
+func (c Config) WithGroupJIDs(jids map[string]string) Config {
+	if len(c.GroupAliases) == 0 || len(jids) == 0 {
+		return c
+	}
+	resolve := func(chat string) string {
+		if jid, ok := jids[chat]; ok {
+			return jid
+		}
+		return chat
+	}
+	resolveAll := func(chats []string) []string {
+		if chats == nil {
+			return nil
+		}
+		resolved := make([]string, len(chats))
+		for i, chat := range chats {
+			resolved[i] = resolve(chat)
+		}
+		return resolved
+	}
+	resolveDestinations := func(dests map[string]DestinationConfig) map[string]DestinationConfig {
+		if dests == nil {
+			return nil
+		}
+		resolved := make(map[string]DestinationConfig, len(dests))
+		for name, dest := range dests {
+			dest.Group = resolve(dest.Group)
+			dest.WeeklyPhotos.ChatJID = resolve(dest.WeeklyPhotos.ChatJID)
+			dest.TeacherJID = resolve(dest.TeacherJID)
+			resolved[name] = dest
+		}
+		return resolved
+	}
+
+	c.InputGroups = resolveAll(c.InputGroups)
+	c.Destinations = resolveDestinations(c.Destinations)
+	if c.Pipelines != nil {
+		pipelines := make(map[string]PipelineConfig, len(c.Pipelines))
+		for name, pipeline := range c.Pipelines {
+			pipeline.InputGroups = resolveAll(pipeline.InputGroups)
+			pipeline.Destinations = resolveDestinations(pipeline.Destinations)
+			pipelines[name] = pipeline
+		}
+		c.Pipelines = pipelines
+	}
+	c.Alerts.ChatJID = resolve(c.Alerts.ChatJID)
+	c.Payments.ChatJID = resolve(c.Payments.ChatJID)
+	c.Forms.ChatJID = resolve(c.Forms.ChatJID)
+	if c.Welcome.Messages != nil {
+		messages := make([]WelcomeMessage, len(c.Welcome.Messages))
+		for i, welcome := range c.Welcome.Messages {
+			welcome.ChatJID = resolve(welcome.ChatJID)
+			messages[i] = welcome
+		}
+		c.Welcome.Messages = messages
+	}
+	c.Stats.MonthlyReport.ChatJID = resolve(c.Stats.MonthlyReport.ChatJID)
+	c.Stats.MonthlyReport.Chats = resolveAll(c.Stats.MonthlyReport.Chats)
+	c.Privacy.MediaOnlyGroups = resolveAll(c.Privacy.MediaOnlyGroups)
+	if c.Topics.Forwards != nil {
+		forwards := make([]TopicForward, len(c.Topics.Forwards))
+		for i, forward := range c.Topics.Forwards {
+			forward.ChatJID = resolve(forward.ChatJID)
+			forwards[i] = forward
+		}
+		c.Topics.Forwards = forwards
+	}
+	if c.APIKeys != nil {
+		keys := make([]APIKeyConfig, len(c.APIKeys))
+		for i, key := range c.APIKeys {
+			key.Chats = resolveAll(key.Chats)
+			keys[i] = key
+		}
+		c.APIKeys = keys
+	}
+	return c
+}
+
+// MatchGroupAliases finds the group each alias names among the joined groups, by exact name. Returns the
+// JIDs by alias, and a problem for every alias that matches no group or more than one.
+func (c *Config) MatchGroupAliases(groups []*types.GroupInfo) (map[string]string, []Problem) {
+	byName := make(map[string][]string)
+	for _, group := range groups {
+		byName[group.Name] = append(byName[group.Name], group.JID.String())
+	}
+
+	jids := make(map[string]string)
+	var problems []Problem
+	for _, alias := range slices.Sorted(maps.Keys(c.GroupAliases)) {
+		name := c.GroupAliases[alias]
+		matches := byName[name]
+		sort.Strings(matches)
+		switch len(matches) {
+		case 1:
+			jids[alias] = matches[0]
+		case 0:
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("Group alias %q: no joined group is named %q", alias, name),
+				Fix:     "Use the group's exact name as shown on the phone (run with -list-groups), or join the group",
+			})
+		default:
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("Group alias %q: %d joined groups are named %q (%s)", alias, len(matches), name, strings.Join(matches, ", ")),
+				Fix:     "Rename or leave all but one of the groups on the phone, or use the JID instead of the alias",
+			})
+		}
+	}
+	return jids, problems
+}
+
+// MatchInputGroupPatterns returns the JIDs of the joined groups whose names match one of the input group
+// patterns, sorted. Patterns that don't compile are skipped; Validate reports them.
+func (c *Config) MatchInputGroupPatterns(groups []*types.GroupInfo) []string {
+	var patterns []*regexp.Regexp
+	for _, pattern := range c.InputGroupPatterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			patterns = append(patterns, re)
+		}
+	}
+	var jids []string
+	for _, group := range groups {
+		if slices.ContainsFunc(patterns, func(re *regexp.Regexp) bool { return re.MatchString(group.Name) }) {
+			jids = append(jids, group.JID.String())
+		}
+	}
+	sort.Strings(jids)
+	return jids
+}
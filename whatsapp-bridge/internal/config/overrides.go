@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the name of every environment variable that overrides a config key
+const EnvPrefix = "JMK_"
+
+// APIKeyEnv holds a single full-access API key, for deployments that don't need scoped keys
+const APIKeyEnv = EnvPrefix + "API_KEY"
+
+// Keys lists the dotted names of all settings that can be overridden, e.g. "media.jpeg_quality".
+// Lists of objects and maps are single keys whose value is JSON.
+func Keys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonName(field)
+			if name == "" {
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, prefix+name+".")
+				continue
+			}
+			keys = append(keys, prefix+name)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvName returns the environment variable that overrides a config key, e.g. JMK_MEDIA_JPEG_QUALITY
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Override sets a config key from its text form. Lists of strings may be given comma separated;
+// lists of objects and maps are given as JSON.
+func (c *Config) Override(key, value string) error {
+	field := reflect.ValueOf(c).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if field.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		next, ok := fieldByJSONName(field, part)
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		field = next
+	}
+	if field.Kind() == reflect.Struct {
+		return fmt.Errorf("config key %q is a section, set one of its keys instead", key)
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", key, value)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", key, value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+		field.SetBool(b)
+	default:
+		value = strings.TrimSpace(value)
+		if field.Type() == reflect.TypeOf([]string{}) && !strings.HasPrefix(value, "[") {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+			return nil
+		}
+		target := reflect.New(field.Type())
+		if err := json.Unmarshal([]byte(value), target.Interface()); err != nil {
+			return fmt.Errorf("%s must be JSON: %v", key, err)
+		}
+		field.Set(target.Elem())
+	}
+	return nil
+}
+
+// applyEnv overrides every key whose JMK_* environment variable is set and adds the JMK_API_KEY key
+func (c *Config) applyEnv() error {
+	for _, key := range Keys() {
+		if value, ok := os.LookupEnv(EnvName(key)); ok {
+			if err := c.Override(key, value); err != nil {
+				return fmt.Errorf("invalid %s: %w", EnvName(key), err)
+			}
+		}
+	}
+	if key := os.Getenv(APIKeyEnv); key != "" {
+		c.APIKeys = append(c.APIKeys, APIKeyConfig{Name: "environment", Key: key, Operations: []string{"*"}})
+	}
+	return nil
+}
+
+// jsonName returns the config key of a struct field, or "" for fields that aren't part of the file
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// fieldByJSONName finds the field of a struct value that is stored under name in config.json
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		if jsonName(v.Type().Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
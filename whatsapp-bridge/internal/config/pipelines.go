@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PipelineNames returns the names of the named pipelines, sorted
+func (c *Config) PipelineNames() []string {
+	return slices.Sorted(maps.Keys(c.Pipelines))
+}
+
+// PipelineOf returns the name of the pipeline whose input groups or channels include chatJID, or "" for
+// chats of the top-level one
+func (c *Config) PipelineOf(chatJID string) string {
+	for name, pipeline := range c.Pipelines {
+		if slices.Contains(pipeline.InputGroups, chatJID) || slices.Contains(pipeline.InputChannels, chatJID) {
+			return name
+		}
+	}
+	return ""
+}
+
+// DestinationPipeline returns the name of the pipeline a destination belongs to, or "" for the
+// top-level destinations
+func (c *Config) DestinationPipeline(destination string) string {
+	for name, pipeline := range c.Pipelines {
+		if _, ok := pipeline.Destinations[destination]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// AcceptsPhotoFrom reports whether photos sender posts in chatJID are matched, which a pipeline's senders
+// can restrict. The top-level pipeline matches everyone's photos.
+func (c *Config) AcceptsPhotoFrom(chatJID, sender string) bool {
+	name := c.PipelineOf(chatJID)
+	if name == "" || len(c.Pipelines[name].Senders) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(c.Pipelines[name].Senders, func(allowed string) bool {
+		return JIDUser(allowed) == JIDUser(sender)
+	})
+}
+
+// withPipelines returns a copy of the configuration with the input groups, channels and destinations of
+// the named pipelines added to the top-level ones, so everything that goes by them, such as storing
+// messages and the weekly photo check, covers every pipeline
+func (c Config) withPipelines() Config {
+	if len(c.Pipelines) == 0 {
+		return c
+	}
+	c.InputGroups = slices.Clone(c.InputGroups)
+	c.InputChannels = slices.Clone(c.InputChannels)
+	destinations := maps.Clone(c.Destinations)
+	if destinations == nil {
+		destinations = make(map[string]DestinationConfig)
+	}
+	for _, name := range c.PipelineNames() {
+		pipeline := c.Pipelines[name]
+		c.InputGroups = append(c.InputGroups, pipeline.InputGroups...)
+		c.InputChannels = append(c.InputChannels, pipeline.InputChannels...)
+		maps.Copy(destinations, pipeline.Destinations)
+	}
+	c.Destinations = destinations
+	return c
+}
+
+// pipelineName is what pipeline names may look like; they name a directory and a command line option
+var pipelineName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// validatePipelines reports pipelines with bad names, and groups, channels and destinations that are
+// part of more than one pipeline, since a photo goes through one pipeline only
+func (c *Config) validatePipelines(fail func(fix, format string, args ...interface{})) {
+	chats := make(map[string]string)
+	destinations := make(map[string]string)
+	for _, jid := range append(slices.Clone(c.InputGroups), c.InputChannels...) {
+		chats[jid] = "the top-level input groups"
+	}
+	for name := range c.Destinations {
+		destinations[name] = "the top-level destinations"
+	}
+	for _, name := range c.PipelineNames() {
+		pipeline := c.Pipelines[name]
+		where := fmt.Sprintf("pipeline %q", name)
+		if !pipelineName.MatchString(name) || name == "default" {
+			fail("Use lowercase letters, digits, _ and -, e.g. after_school; default stands for the top-level pipeline", "Pipeline name %q is not allowed", name)
+		}
+		if len(pipeline.InputGroups) == 0 && len(pipeline.InputChannels) == 0 {
+			fail("Add the groups whose photos go through it to its input_groups", "Pipeline %q has no input_groups or input_channels", name)
+		}
+		for _, jid := range append(slices.Clone(pipeline.InputGroups), pipeline.InputChannels...) {
+			if other, ok := chats[jid]; ok {
+				fail("Keep each group in one pipeline only", "%s is in both %s and %s", jid, other, where)
+			}
+			chats[jid] = where
+		}
+		for dest := range pipeline.Destinations {
+			if other, ok := destinations[dest]; ok {
+				fail("Give the destination a name of its own in each pipeline", "Destination %q is in both %s and %s", dest, other, where)
+			}
+			destinations[dest] = where
+		}
+		for _, sender := range pipeline.Senders {
+			if user := JIDUser(sender); user == "" || strings.Trim(user, "0123456789") != "" {
+				fail("Use phone numbers with country code, e.g. +972501234567", "Pipeline %q has an invalid sender %q", name, sender)
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+//go:generate sh -c "go run ../../cmd/bridge schema > ../../../config.schema.json"
+
+// SchemaID names the schema of config.json; editors that know it offer completion and checks while editing
+const SchemaID = "https://raw.githubusercontent.com/Yakirbe/just-my-kids/main/config.schema.json"
+
+// The sources of the config structs, whose field comments describe the settings in the schema
+//
+//go:embed config.go
+var configSource string
+
+//go:embed apikeys.go
+var apiKeysSource string
+
+//go:embed schema.go
+var schemaSource string
+
+// faceServiceSettings are the keys of config.json read by the face detection service rather than the
+// bridge; they are only part of the schema
+type faceServiceSettings struct {
+	FaceDetection FaceDetectionSettings `json:"face_detection"`
+	// Save images with the detected faces marked
+	Debug DebugSettings `json:"debug"`
+	// Match faces and record what would be forwarded, without sending anything
+	DryRun bool `json:"dry_run"`
+}
+
+// FaceDetectionSettings controls how the face detection service matches faces to destinations
+type FaceDetectionSettings struct {
+	// Directory with a folder of reference photos per destination
+	KnownFacesDir string `json:"known_faces_dir"`
+	// Reference photos that need to match for a positive identification
+	MinMatchingFaces int `json:"min_matching_faces"`
+	// Face distance threshold (0.0-1.0); lower is stricter, 0.5 is a good start
+	ConfidenceThreshold float64 `json:"confidence_threshold"`
+	// hog (faster) or cnn (more accurate, needs a strong machine)
+	Model string `json:"model"`
+	// Fewest faces a cluster needs to be kept when clustering stored photos
+	ClusterMinSize int `json:"cluster_min_size"`
+	// Use a threshold per child tuned from feedback on past decisions
+	AutoTune bool `json:"auto_tune"`
+	// Detect faces on a remote inference server instead of locally
+	Inference InferenceSettings `json:"inference"`
+}
+
+// InferenceSettings points the face detection service at a remote inference server
+type InferenceSettings struct {
+	URL string `json:"url"`
+	// Photos sent per request
+	BatchSize int `json:"batch_size"`
+	// Seconds a request may take
+	Timeout int `json:"timeout"`
+	// Photos whose faces are remembered by hash, so they aren't sent again
+	CacheSize int `json:"cache_size"`
+}
+
+// DebugSettings makes the face detection service save annotated images
+type DebugSettings struct {
+	Enabled   bool   `json:"enabled"`
+	OutputDir string `json:"output_dir"`
+}
+
+// Schema is a JSON Schema (draft 2020-12) subset: the keywords the config schema uses
+type Schema struct {
+	SchemaURI   string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// A schema for the values of objects used as maps, or false for structs, which allow no other keys
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	Minimum              *int64      `json:"minimum,omitempty"`
+	Maximum              *int64      `json:"maximum,omitempty"`
+}
+
+// schemaLimits are the allowed values and ranges of settings, by key; "*" stands for a map key or list item.
+// Checks that need more than one setting stay in Validate.
+var schemaLimits = map[string]Schema{
+	"api_port":                                         {Minimum: bound(1), Maximum: bound(65535)},
+	"media.jpeg_quality":                               {Minimum: bound(0), Maximum: bound(100)},
+	"media.quota_bytes":                                {Minimum: bound(0)},
+	"media.min_free_bytes":                             {Minimum: bound(-1)},
+	"media.max_image_pixels":                           {Minimum: bound(0)},
+	"media.live_downloads.concurrency":                 {Minimum: bound(0)},
+	"media.history_downloads.concurrency":              {Minimum: bound(0)},
+	"history.workers":                                  {Minimum: bound(0)},
+	"presence.mode":                                    {Enum: []string{PresenceUnavailable, PresenceAvailable, PresenceSchedule}},
+	"presence.windows.*.days.*":                        {Enum: Weekdays},
+	"stats.weekly_check_day":                           {Enum: Weekdays},
+	"destinations.*.watermark.position":                {Enum: []string{PositionBottomRight, PositionBottomLeft, PositionTopRight, PositionTopLeft}},
+	"destinations.*.weekly_photos.minimum":             {Minimum: bound(0)},
+	"api_keys.*.operations.*":                          {Enum: []string{OperationSend, OperationRead, OperationDelete, OperationAdmin, "*"}},
+	"oidc.group_roles.*":                               {Enum: Roles},
+	"oidc.user_roles.*":                                {Enum: Roles},
+	"oidc.default_role":                                {Enum: append([]string{""}, Roles...)},
+	"publisher.type":                                   {Enum: []string{"", PublisherKafka, PublisherNATS}},
+	"spam.link_only":                                   {Minimum: bound(-1)},
+	"spam.unknown_sender":                              {Minimum: bound(-1)},
+	"spam.repeated":                                    {Minimum: bound(-1)},
+	"spam.repeat_window_hours":                         {Minimum: bound(0)},
+	"timeouts.pairing_seconds":                         {Minimum: bound(0)},
+	"ask.max_messages":                                 {Minimum: bound(1), Maximum: bound(100)},
+	"ask.llm.timeout_seconds":                          {Minimum: bound(1)},
+	"embeddings.embedder":                              {Enum: []string{"", EmbedderLocal, EmbedderRemote}},
+	"embeddings.batch_size":                            {Minimum: bound(1)},
+	"payments.reminder_hours":                          {Minimum: bound(1)},
+	"payments.max_reminders":                           {Minimum: bound(1)},
+	"forms.reminder_hours":                             {Minimum: bound(1)},
+	"acknowledgments.nudge_hours":                      {Minimum: bound(1)},
+	"acknowledgments.max_nudges":                       {Minimum: bound(0)},
+	"auto_reply.rate_limit_hours":                      {Minimum: bound(1)},
+	"auto_reply.rules.chats":                           {Enum: []string{"", AutoReplyDirect, AutoReplyGroups}},
+	"liveness.interval_seconds":                        {Minimum: bound(-1)},
+	"liveness.failure_window_seconds":                  {Minimum: bound(0)},
+	"locale":                                           {Enum: []string{LocaleEnglish, LocaleHebrew}},
+	"logs.level":                                       {Enum: []string{LogLevelWarn, LogLevelError, LogLevelOff}},
+	"logs.retention_days":                              {Minimum: bound(0)},
+	"cors.max_age_seconds":                             {Minimum: bound(0)},
+	"face_detection.model":                             {Enum: []string{"hog", "cnn"}},
+	"face_detection.min_matching_faces":                {Minimum: bound(1)},
+	"pipelines.*.destinations.*.watermark.position":    {Enum: []string{PositionBottomRight, PositionBottomLeft, PositionTopRight, PositionTopLeft}},
+	"pipelines.*.destinations.*.weekly_photos.minimum": {Minimum: bound(0)},
+	"pipelines.*.face_detection.model":                 {Enum: []string{"hog", "cnn"}},
+	"pipelines.*.face_detection.min_matching_faces":    {Minimum: bound(1)},
+}
+
+func bound(n int64) *int64 {
+	return &n
+}
+
+// ConfigSchema returns the JSON Schema of config.json, generated from the Config struct: every key with
+// its type, the comment on its field as description, and the allowed values and ranges of schemaLimits.
+// Unknown keys are errors, so a misspelled setting doesn't silently fall back to its default.
+func ConfigSchema() *Schema {
+	docs := fieldDocs(configSource, apiKeysSource, schemaSource)
+	schema := schemaFor(reflect.TypeOf(Config{}), "", docs)
+	// config.json is shared with the face detection service
+	for name, property := range schemaFor(reflect.TypeOf(faceServiceSettings{}), "", docs).Properties {
+		schema.Properties[name] = property
+	}
+	schema.SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+	schema.ID = SchemaID
+	schema.Title = "just-my-kids WhatsApp bridge configuration"
+	// Editors find the schema through the $schema key of the file itself
+	schema.Properties["$schema"] = &Schema{Type: "string", Description: "The schema of this file, " + SchemaID}
+	return schema
+}
+
+// schemaFor describes values of type t, found at key (dotted, "" for the top level)
+func schemaFor(t reflect.Type, key string, docs map[string]string) *Schema {
+	var schema *Schema
+	switch t.Kind() {
+	case reflect.String:
+		schema = &Schema{Type: "string"}
+	case reflect.Int, reflect.Int64:
+		schema = &Schema{Type: "integer"}
+	case reflect.Float64:
+		schema = &Schema{Type: "number"}
+	case reflect.Bool:
+		schema = &Schema{Type: "boolean"}
+	case reflect.Slice:
+		schema = &Schema{Type: "array", Items: schemaFor(t.Elem(), childKey(key, "*"), docs)}
+	case reflect.Map:
+		schema = &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), childKey(key, "*"), docs)}
+	case reflect.Struct:
+		schema = &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonName(field)
+			if name == "" {
+				continue
+			}
+			property := schemaFor(field.Type, childKey(key, name), docs)
+			if doc := docs[t.Name()+"."+field.Name]; doc != "" {
+				property.Description = doc
+			} else if property.Description == "" {
+				property.Description = docs[elemType(field.Type).Name()]
+			}
+			schema.Properties[name] = property
+		}
+	default:
+		schema = &Schema{}
+	}
+	if limits, ok := schemaLimits[key]; ok {
+		schema.Enum, schema.Minimum, schema.Maximum = limits.Enum, limits.Minimum, limits.Maximum
+	}
+	return schema
+}
+
+// elemType is the type of the items of lists and values of maps, and t itself otherwise
+func elemType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		return t.Elem()
+	}
+	return t
+}
+
+func childKey(key, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + "." + name
+}
+
+// fieldDocs reads the comments of the structs in Go sources, keyed by Type.Field for fields and by Type
+// for the types themselves, each joined into one line. A field without a comment of its own is
+// described by the comment of its type.
+func fieldDocs(sources ...string) map[string]string {
+	docs := map[string]string{}
+	fset := token.NewFileSet()
+	for _, source := range sources {
+		file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				if gen.Doc != nil {
+					// "StatsConfig controls the reports" describes a setting as "Controls the reports"
+					doc := strings.TrimPrefix(oneLine(gen.Doc.Text()), typeSpec.Name.Name+" ")
+					docs[typeSpec.Name.Name] = strings.ToUpper(doc[:1]) + doc[1:]
+				}
+				for _, field := range structType.Fields.List {
+					if field.Doc == nil {
+						continue
+					}
+					// A comment above fields declared together describes all of them
+					for _, name := range field.Names {
+						docs[typeSpec.Name.Name+"."+name.Name] = oneLine(field.Doc.Text())
+					}
+				}
+			}
+		}
+	}
+	return docs
+}
+
+// oneLine joins the lines of a comment
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// SchemaJSON returns the schema as indented JSON, as published in config.schema.json
+func SchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(ConfigSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// locations are the timezones loaded so far, by name
+	locations   = make(map[string]*time.Location)
+	locationsMu sync.Mutex
+)
+
+// Location returns the timezone dates are shown in and days start in, the machine's unless timezone is
+// set. Stored timestamps are UTC; convert them with In before taking their day, hour or weekday.
+func (c *Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	locationsMu.Lock()
+	defer locationsMu.Unlock()
+	if location, ok := locations[c.Timezone]; ok {
+		return location
+	}
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		// Validate rejects unknown names, so this is a config that skipped validation
+		location = time.Local
+	}
+	locations[c.Timezone] = location
+	return location
+}
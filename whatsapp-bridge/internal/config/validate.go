@@ -0,0 +1,645 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Defaults used for settings missing from config.json
+const (
+	DefaultAPIPort         = 8080
+	DefaultDataDir         = "store"
+	DefaultJPEGQuality     = 100
+	DefaultTracingEndpoint = "http://localhost:4318"
+	DefaultServiceName     = "whatsapp-bridge"
+	DefaultHistoryWorkers  = 4
+	// Live photos are few and should arrive quickly; backfill runs in the background
+	DefaultLiveDownloadConcurrency    = 4
+	DefaultHistoryDownloadConcurrency = 2
+	DefaultSendTimeout                = 30
+	DefaultUploadTimeout              = 120
+	DefaultDownloadTimeout            = 120
+	DefaultShutdownTimeout            = 10
+	DefaultLivenessInterval           = 60
+	DefaultLivenessFailureWindow      = 300
+	DefaultLocale                     = LocaleEnglish
+	DefaultLogLevel                   = LogLevelWarn
+	DefaultLogRetentionDays           = 14
+	DefaultAskMaxMessages             = 10
+	DefaultLLMTimeoutSeconds          = 60
+	DefaultEmbeddingBatchSize         = 32
+	DefaultPaymentReminderHours       = 24
+	DefaultPaymentMaxReminders        = 5
+	DefaultFormReminderHours          = 24
+	DefaultNudgeHours                 = 24
+	DefaultAutoReplyRateLimitHours    = 24
+	DefaultCORSMaxAge                 = 600
+	DefaultWatermarkText              = "{name} · {date}"
+	DefaultWatermarkPosition          = PositionBottomRight
+	DefaultMinFreeBytes               = 512 << 20
+	DefaultPresenceMode               = PresenceUnavailable
+	DefaultSpamThreshold              = 3
+	DefaultSpamLinkOnly               = 2
+	DefaultSpamUnknownSender          = 1
+	DefaultSpamRepeated               = 2
+	DefaultSpamRepeatWindowHours      = 24
+	// A 60 megapixel image takes 240 MB once decoded; phone cameras stay below 50
+	DefaultMaxImagePixels = 60_000_000
+	// Kindergarten weeks end on Thursday or Friday in Israel
+	DefaultWeeklyCheckDay    = "fri"
+	DefaultOIDCUsernameClaim = "email"
+	DefaultOIDCGroupsClaim   = "groups"
+	DefaultOIDCAfterLoginURL = "/api/me"
+)
+
+// Weekdays as written in presence windows, indexed by time.Weekday
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Default CORS methods and headers: everything the API uses, including authentication and request IDs
+var (
+	DefaultCORSMethods = []string{"GET", "POST", "DELETE"}
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID"}
+	DefaultOIDCScopes  = []string{"email", "profile"}
+)
+
+// Problem is something wrong with the configuration, together with how to fix it. Warnings don't
+// keep the bridge from starting.
+type Problem struct {
+	Message string
+	Fix     string
+	Warning bool
+}
+
+// String formats the problem and its fix for the log
+func (p Problem) String() string {
+	if p.Fix == "" {
+		return p.Message
+	}
+	return p.Message + " (" + p.Fix + ")"
+}
+
+// ApplyDefaults fills in settings that were left out
+func (c *Config) ApplyDefaults() {
+	if c.APIPort == 0 {
+		c.APIPort = DefaultAPIPort
+	}
+	if c.DataDir == "" {
+		c.DataDir = DefaultDataDir
+	}
+	if c.Media.JPEGQuality == 0 {
+		c.Media.JPEGQuality = DefaultJPEGQuality
+	}
+	if c.Media.LiveDownloads.Concurrency == 0 {
+		c.Media.LiveDownloads.Concurrency = DefaultLiveDownloadConcurrency
+	}
+	if c.Media.HistoryDownloads.Concurrency == 0 {
+		c.Media.HistoryDownloads.Concurrency = DefaultHistoryDownloadConcurrency
+	}
+	if c.Media.MinFreeBytes == 0 {
+		c.Media.MinFreeBytes = DefaultMinFreeBytes
+	}
+	if c.Media.MaxImagePixels == 0 {
+		c.Media.MaxImagePixels = DefaultMaxImagePixels
+	}
+	if c.Tracing.Endpoint == "" {
+		c.Tracing.Endpoint = DefaultTracingEndpoint
+	}
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = DefaultServiceName
+	}
+	if c.History.Workers == 0 {
+		c.History.Workers = DefaultHistoryWorkers
+	}
+	if c.Timeouts.SendSeconds == 0 {
+		c.Timeouts.SendSeconds = DefaultSendTimeout
+	}
+	if c.Timeouts.UploadSeconds == 0 {
+		c.Timeouts.UploadSeconds = DefaultUploadTimeout
+	}
+	if c.Timeouts.DownloadSeconds == 0 {
+		c.Timeouts.DownloadSeconds = DefaultDownloadTimeout
+	}
+	if c.Timeouts.ShutdownSeconds == 0 {
+		c.Timeouts.ShutdownSeconds = DefaultShutdownTimeout
+	}
+	if c.Liveness.IntervalSeconds == 0 {
+		c.Liveness.IntervalSeconds = DefaultLivenessInterval
+	}
+	if c.Liveness.FailureWindowSeconds == 0 {
+		c.Liveness.FailureWindowSeconds = DefaultLivenessFailureWindow
+	}
+	if c.Locale == "" {
+		c.Locale = DefaultLocale
+	}
+	if c.Logs.Level == "" {
+		c.Logs.Level = DefaultLogLevel
+	}
+	if c.Logs.RetentionDays == 0 {
+		c.Logs.RetentionDays = DefaultLogRetentionDays
+	}
+	if c.Ask.MaxMessages == 0 {
+		c.Ask.MaxMessages = DefaultAskMaxMessages
+	}
+	if c.Ask.LLM.TimeoutSeconds == 0 {
+		c.Ask.LLM.TimeoutSeconds = DefaultLLMTimeoutSeconds
+	}
+	if c.Embeddings.BatchSize == 0 {
+		c.Embeddings.BatchSize = DefaultEmbeddingBatchSize
+	}
+	if c.Payments.ReminderHours == 0 {
+		c.Payments.ReminderHours = DefaultPaymentReminderHours
+	}
+	if c.Payments.MaxReminders == 0 {
+		c.Payments.MaxReminders = DefaultPaymentMaxReminders
+	}
+	if c.Forms.ReminderHours == 0 {
+		c.Forms.ReminderHours = DefaultFormReminderHours
+	}
+	if c.Acknowledgments.NudgeHours == 0 {
+		c.Acknowledgments.NudgeHours = DefaultNudgeHours
+	}
+	if c.AutoReply.RateLimitHours == 0 {
+		c.AutoReply.RateLimitHours = DefaultAutoReplyRateLimitHours
+	}
+	if len(c.CORS.AllowedMethods) == 0 {
+		c.CORS.AllowedMethods = DefaultCORSMethods
+	}
+	if len(c.CORS.AllowedHeaders) == 0 {
+		c.CORS.AllowedHeaders = DefaultCORSHeaders
+	}
+	if c.CORS.MaxAgeSeconds == 0 {
+		c.CORS.MaxAgeSeconds = DefaultCORSMaxAge
+	}
+	if c.Presence.Mode == "" {
+		c.Presence.Mode = DefaultPresenceMode
+	}
+	if c.Stats.WeeklyCheckDay == "" {
+		c.Stats.WeeklyCheckDay = DefaultWeeklyCheckDay
+	}
+	if len(c.OIDC.Scopes) == 0 {
+		c.OIDC.Scopes = DefaultOIDCScopes
+	}
+	if c.OIDC.UsernameClaim == "" {
+		c.OIDC.UsernameClaim = DefaultOIDCUsernameClaim
+	}
+	if c.OIDC.GroupsClaim == "" {
+		c.OIDC.GroupsClaim = DefaultOIDCGroupsClaim
+	}
+	if c.OIDC.AfterLoginURL == "" {
+		c.OIDC.AfterLoginURL = DefaultOIDCAfterLoginURL
+	}
+	if c.PhotoBook.Dir == "" {
+		c.PhotoBook.Dir = filepath.Join(c.DataDir, "photobooks")
+	}
+	spam := &c.Spam
+	for _, setting := range []struct {
+		value *int
+		def   int
+	}{
+		{&spam.Threshold, DefaultSpamThreshold}, {&spam.LinkOnly, DefaultSpamLinkOnly}, {&spam.UnknownSender, DefaultSpamUnknownSender},
+		{&spam.Repeated, DefaultSpamRepeated}, {&spam.RepeatWindowHours, DefaultSpamRepeatWindowHours},
+	} {
+		if *setting.value == 0 {
+			*setting.value = setting.def
+		}
+	}
+	destinations := []map[string]DestinationConfig{c.Destinations}
+	for _, pipeline := range c.Pipelines {
+		destinations = append(destinations, pipeline.Destinations)
+	}
+	for _, dests := range destinations {
+		for name, dest := range dests {
+			if dest.Watermark.Text == "" {
+				dest.Watermark.Text = DefaultWatermarkText
+			}
+			if dest.Watermark.Position == "" {
+				dest.Watermark.Position = DefaultWatermarkPosition
+			}
+			dests[name] = dest
+		}
+	}
+}
+
+// Validate checks JID formats, patterns, API keys and URLs and returns everything that is wrong
+func (c *Config) Validate() []Problem {
+	var problems []Problem
+	fail := func(fix, format string, args ...interface{}) {
+		problems = append(problems, Problem{Message: fmt.Sprintf(format, args...), Fix: fix})
+	}
+	warn := func(fix, format string, args ...interface{}) {
+		problems = append(problems, Problem{Message: fmt.Sprintf(format, args...), Fix: fix, Warning: true})
+	}
+
+	// Input groups, channels and destinations are checked with those of the pipelines
+	all := c.withPipelines()
+	if len(all.InputGroups) == 0 && len(all.InputChannels) == 0 && len(c.InputGroupPatterns) == 0 {
+		fail("Add group JIDs to input_groups (run with -list-groups to find them)", "No input_groups or input_channels configured, nothing will be monitored")
+	}
+	for _, pattern := range c.InputGroupPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			fail("Use a Go regular expression, e.g. \"^Gan .*2025$\"", "input_group_patterns entry %q is invalid: %v", pattern, err)
+		}
+	}
+	for alias, name := range c.GroupAliases {
+		if strings.Contains(alias, "@") || strings.Trim(alias, "+0123456789") == "" {
+			fail("Use a name like gan-alonim, without @ and not just digits", "group_aliases key %q could be mistaken for a JID or phone number", alias)
+		}
+		if strings.TrimSpace(name) == "" {
+			fail("Set the exact name of the group on the phone", "Group alias %q has no group name", alias)
+		}
+	}
+	for _, jid := range all.InputGroups {
+		if !strings.HasSuffix(jid, "@g.us") && !c.IsGroupAlias(jid) {
+			fail("Group JIDs end in @g.us; run with -list-groups to copy the right one", "input_groups entry %q is not a group JID or alias", jid)
+		}
+	}
+	for _, jid := range all.InputChannels {
+		if !strings.HasSuffix(jid, "@newsletter") {
+			fail("Channel JIDs end in @newsletter; run with -list-channels to copy the right one", "input_channels entry %q is not a channel JID", jid)
+		}
+	}
+
+	c.validatePipelines(fail)
+
+	if len(all.Destinations) == 0 {
+		warn("Add a destination per child so matched photos are forwarded", "No destinations configured")
+	}
+	for name, dest := range all.Destinations {
+		if dest.Group == "" {
+			fail("Set the group (JID or phone number) photos of "+name+" are sent to", "Destination %q has no group", name)
+		} else if c.IsGroupAlias(dest.Group) {
+		} else if _, err := types.ParseJID(dest.Group); err != nil || (strings.Contains(dest.Group, "@") && !strings.HasSuffix(dest.Group, "@g.us") && !strings.HasSuffix(dest.Group, "@s.whatsapp.net")) {
+			fail("Use a group JID (…@g.us) or a phone number with country code", "Destination %q has an invalid group %q", name, dest.Group)
+		}
+		switch dest.Watermark.Position {
+		case PositionBottomRight, PositionBottomLeft, PositionTopRight, PositionTopLeft:
+		default:
+			fail("Use bottom-right, bottom-left, top-right or top-left", "Destination %q has an invalid watermark position %q", name, dest.Watermark.Position)
+		}
+		if dest.Watermark.Enabled && dest.Watermark.FontPath != "" {
+			if _, err := os.Stat(dest.Watermark.FontPath); err != nil {
+				fail("Point font_path to a .ttf or .otf file, or remove it to use the built-in font", "Watermark font of destination %q can't be read: %v", name, err)
+			}
+		}
+		if dest.WeeklyPhotos.Minimum < 0 {
+			fail("Use the number of photos expected per week, or 0 to turn the check off", "Destination %q has a negative weekly_photos.minimum", name)
+		} else if dest.WeeklyPhotos.Minimum > 0 && dest.WeeklyPhotos.ChatJID == "" && c.Alerts.ChatJID == "" {
+			warn("Set weekly_photos.chat_jid or alerts.chat_jid", "Destination %q has weekly_photos but no chat to tell, shortfalls are only logged", name)
+		}
+		if dest.TeacherJID != "" && !c.IsGroupAlias(dest.TeacherJID) {
+			if _, err := types.ParseJID(dest.TeacherJID); err != nil {
+				fail("Use the teacher's phone number with country code", "Destination %q has an invalid teacher_jid %q", name, dest.TeacherJID)
+			}
+		}
+	}
+	for reason, template := range c.Absence.Templates {
+		if !slices.Contains(AbsenceReasons, reason) {
+			fail("Use "+strings.Join(AbsenceReasons, " or "), "absence.templates has an unknown reason %q", reason)
+		} else if strings.TrimSpace(template) == "" {
+			fail("Write the message, or leave the reason out for the built-in one", "absence.templates.%s is empty", reason)
+		}
+	}
+	if !slices.Contains(Weekdays, strings.ToLower(c.Stats.WeeklyCheckDay)) {
+		fail("Use mon, tue, wed, thu, fri, sat or sun", "stats.weekly_check_day %q is not a day of the week", c.Stats.WeeklyCheckDay)
+	}
+	if c.PhotoBook.Enabled && c.PhotoBook.FontPath != "" {
+		if _, err := os.Stat(c.PhotoBook.FontPath); err != nil {
+			fail("Point font_path to a .ttf or .otf file, or remove it to use the built-in font", "photo_book.font_path can't be read: %v", err)
+		}
+	}
+
+	if c.APIPort < 1 || c.APIPort > 65535 {
+		fail("Use a port between 1 and 65535", "api_port %d is out of range", c.APIPort)
+	}
+	if len(c.Media.AllowedExtensions) == 0 {
+		warn("Set media.allowed_extensions, e.g. [\".jpg\", \".jpeg\", \".png\"]", "No allowed media extensions configured")
+	}
+	if c.Media.JPEGQuality < 1 || c.Media.JPEGQuality > 100 {
+		fail("Use a value between 1 and 100", "media.jpeg_quality %d is out of range", c.Media.JPEGQuality)
+	}
+	if c.Media.LiveDownloads.Concurrency < 0 || c.Media.LiveDownloads.BytesPerSecond < 0 {
+		fail("Use positive numbers, or 0 for no limit", "media.live_downloads limits must not be negative")
+	}
+	if c.Media.HistoryDownloads.Concurrency < 0 || c.Media.HistoryDownloads.BytesPerSecond < 0 {
+		fail("Use positive numbers, or 0 for no limit", "media.history_downloads limits must not be negative")
+	}
+	if c.Media.QuotaBytes < 0 {
+		fail("Use a size in bytes, or 0 for no quota", "media.quota_bytes must not be negative")
+	}
+	if c.Media.MinFreeBytes < -1 {
+		fail("Use a size in bytes, or -1 to not check the free disk space", "media.min_free_bytes %d is out of range", c.Media.MinFreeBytes)
+	}
+	if c.Media.MaxImagePixels < 0 {
+		fail("Use a number of pixels, e.g. 60000000", "media.max_image_pixels must not be negative")
+	}
+	if c.Alerts.ChatJID != "" {
+		if _, err := types.ParseJID(c.Alerts.ChatJID); err != nil {
+			fail("Use a group JID (…@g.us) or a phone number with country code", "alerts.chat_jid %q is invalid", c.Alerts.ChatJID)
+		}
+	}
+	switch c.Presence.Mode {
+	case PresenceUnavailable, PresenceAvailable:
+	case PresenceSchedule:
+		if len(c.Presence.Windows) == 0 {
+			warn("Add presence.windows, or use \"unavailable\"", "presence.mode is schedule without windows, the account never shows online")
+		}
+	default:
+		fail("Use unavailable, available or schedule", "presence.mode %q is not supported", c.Presence.Mode)
+	}
+	for i, window := range c.Presence.Windows {
+		_, errFrom := time.Parse("15:04", window.From)
+		_, errTo := time.Parse("15:04", window.To)
+		if errFrom != nil || errTo != nil {
+			fail("Use times like 08:30 and 21:00", "presence.windows[%d] has an invalid from or to", i)
+		}
+		for _, day := range window.Days {
+			if !slices.Contains(Weekdays, strings.ToLower(day)) {
+				fail("Use mon, tue, wed, thu, fri, sat or sun", "presence.windows[%d] has unknown day %q", i, day)
+			}
+		}
+	}
+	if c.Spam.Threshold < 1 {
+		fail("Use a score of at least 1", "spam.threshold %d is out of range", c.Spam.Threshold)
+	}
+	if c.Spam.LinkOnly < -1 || c.Spam.UnknownSender < -1 || c.Spam.Repeated < -1 {
+		fail("Use a positive score, 0 for the default or -1 to turn the rule off", "spam rule scores must not be below -1")
+	}
+	if c.Spam.RepeatWindowHours < 1 {
+		fail("Use a number of hours of at least 1", "spam.repeat_window_hours %d is out of range", c.Spam.RepeatWindowHours)
+	}
+	if c.Archive.RawMessages && len(c.Privacy.RedactionRules) > 0 {
+		warn("Turn off archive.raw_messages if redacted content must not be stored at all", "Archived raw messages keep the content redaction_rules remove")
+	}
+	for _, rule := range c.Privacy.RedactionRules {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			fail("Fix the regular expression (Go RE2 syntax)", "Redaction rule %q does not compile: %v", rule.Name, err)
+		}
+	}
+
+	for _, key := range c.APIKeys {
+		if key.Key == "" {
+			fail("Set the key, or export the environment variable it refers to", "API key %q is empty", key.Name)
+		} else if len(key.Key) < 16 {
+			warn("Use a long random token, e.g. the output of `openssl rand -hex 24`", "API key %q is shorter than 16 characters", key.Name)
+		}
+		for _, op := range key.Operations {
+			switch op {
+			case OperationSend, OperationRead, OperationDelete, OperationAdmin, "*":
+			default:
+				fail("Use send, read, delete, admin or *", "API key %q has unknown operation %q", key.Name, op)
+			}
+		}
+		for _, name := range key.Destinations {
+			if _, ok := all.Destinations[name]; !ok {
+				fail("Use a name from destinations", "API key %q refers to unknown destination %q", key.Name, name)
+			}
+		}
+	}
+
+	if c.OIDC.Enabled() {
+		if u, err := url.Parse(c.OIDC.Issuer); err != nil || u.Scheme != "https" || u.Host == "" {
+			fail("Use the issuer URL the provider documents, e.g. https://accounts.google.com", "oidc.issuer %q is not an https URL", c.OIDC.Issuer)
+		}
+		if c.OIDC.ClientID == "" {
+			fail("Copy the client ID of the application registered with the provider", "oidc.client_id is empty")
+		}
+		if u, err := url.Parse(c.OIDC.RedirectURL); err != nil || u.Host == "" || u.Path != "/api/oidc/callback" {
+			fail("Use the bridge's address with /api/oidc/callback, e.g. https://bridge.example.com/api/oidc/callback", "oidc.redirect_url %q is not the callback URL", c.OIDC.RedirectURL)
+		}
+		checkRole := func(role, setting string) {
+			if role != "" && !slices.Contains(Roles, role) {
+				fail("Use admin, sender or viewer", "oidc.%s has unknown role %q", setting, role)
+			}
+		}
+		for _, group := range slices.Sorted(maps.Keys(c.OIDC.GroupRoles)) {
+			checkRole(c.OIDC.GroupRoles[group], "group_roles."+group)
+		}
+		for _, user := range slices.Sorted(maps.Keys(c.OIDC.UserRoles)) {
+			checkRole(c.OIDC.UserRoles[user], "user_roles."+user)
+		}
+		checkRole(c.OIDC.DefaultRole, "default_role")
+		if len(c.OIDC.GroupRoles) == 0 && len(c.OIDC.UserRoles) == 0 && c.OIDC.DefaultRole == "" {
+			fail("Give groups or users a role with oidc.group_roles or oidc.user_roles", "Nobody can log in through OIDC")
+		}
+	}
+
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			fail("Use an IANA timezone name such as Asia/Jerusalem or Europe/London", "timezone %q is unknown", c.Timezone)
+		}
+	}
+
+	if c.History.Workers < 1 {
+		fail("Use at least 1", "history.workers %d is out of range", c.History.Workers)
+	}
+	timeouts := c.Timeouts
+	if timeouts.SendSeconds < 1 || timeouts.UploadSeconds < 1 || timeouts.DownloadSeconds < 1 || timeouts.ShutdownSeconds < 1 {
+		fail("Use a number of seconds of at least 1, or leave the setting out for the default", "timeouts must be positive")
+	}
+	if timeouts.PairingSeconds < 0 {
+		fail("Use a number of seconds, or 0 to wait until a QR code is scanned", "timeouts.pairing_seconds must not be negative")
+	}
+
+	if liveness := c.Liveness; liveness.IntervalSeconds > 0 && liveness.FailureWindowSeconds < 2*liveness.IntervalSeconds {
+		fail("Allow at least two pings, or leave the setting out for the default", "liveness.failure_window_seconds %d is shorter than two intervals of %d seconds",
+			liveness.FailureWindowSeconds, liveness.IntervalSeconds)
+	}
+
+	switch c.Locale {
+	case LocaleEnglish, LocaleHebrew:
+	default:
+		fail("Use en or he", "locale %q is not supported", c.Locale)
+	}
+
+	switch c.Logs.Level {
+	case LogLevelWarn, LogLevelError, LogLevelOff:
+	default:
+		fail("Use warn, error or off", "logs.level %q is not supported", c.Logs.Level)
+	}
+	if c.Logs.RetentionDays < 1 {
+		fail("Use a number of days of at least 1, or leave it out for the default", "logs.retention_days must be positive")
+	}
+
+	if c.Ask.MaxMessages < 1 || c.Ask.MaxMessages > 100 {
+		fail("Use a number from 1 to 100, or leave it out for the default", "ask.max_messages %d is out of range", c.Ask.MaxMessages)
+	}
+	if c.Ask.LLM.TimeoutSeconds < 1 {
+		fail("Use a number of seconds, or leave it out for the default", "ask.llm.timeout_seconds must be positive")
+	}
+	if llm := c.Ask.LLM; llm.URL != "" {
+		if u, err := url.Parse(llm.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("Use the full chat completions URL, e.g. http://localhost:11434/v1/chat/completions", "ask.llm.url %q is not an http(s) URL", llm.URL)
+		}
+		if llm.Model == "" {
+			fail("Name the model to ask, e.g. llama3.1", "ask.llm.model is required with ask.llm.url")
+		}
+	}
+
+	switch embeddings := c.Embeddings; embeddings.Embedder {
+	case "", EmbedderLocal:
+	case EmbedderRemote:
+		if u, err := url.Parse(embeddings.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("Use the full embeddings URL, e.g. http://localhost:11434/v1/embeddings", "embeddings.url %q is not an http(s) URL", embeddings.URL)
+		}
+		if embeddings.Model == "" {
+			fail("Name the embedding model, e.g. nomic-embed-text or a multilingual one for Hebrew", "embeddings.model is required with the remote embedder")
+		}
+	default:
+		fail("Use local or remote, or leave it out to turn semantic search off", "embeddings.embedder %q is not supported", embeddings.Embedder)
+	}
+	if c.Embeddings.BatchSize < 1 {
+		fail("Use a number of messages of at least 1, or leave it out for the default", "embeddings.batch_size must be positive")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fail("Use scheme and host only, e.g. http://localhost:3000, or *", "cors.allowed_origins entry %q is not an origin", origin)
+		}
+	}
+	if c.CORS.MaxAgeSeconds < 0 {
+		fail("Use a number of seconds, or leave it out for the default", "cors.max_age_seconds must not be negative")
+	}
+
+	for topic := range c.Topics.Keywords {
+		if !topicName.MatchString(topic) {
+			fail("Use letters, digits, - and _ only, e.g. after_school", "topics.keywords has an invalid topic name %q", topic)
+		}
+	}
+	if c.Topics.ClassifierURL != "" {
+		if u, err := url.Parse(c.Topics.ClassifierURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("Use a full http(s) URL", "topics.classifier_url %q is not a valid URL", c.Topics.ClassifierURL)
+		}
+	}
+	for i, forward := range c.Topics.Forwards {
+		_, custom := c.Topics.Keywords[forward.Topic]
+		if forward.Topic == "" || !custom && !slices.Contains(BuiltinTopics, forward.Topic) && c.Topics.ClassifierURL == "" {
+			fail("Use a built-in topic ("+strings.Join(BuiltinTopics, ", ")+") or one of topics.keywords", "topics.forwards[%d] has an unknown topic %q", i, forward.Topic)
+		}
+		if _, err := types.ParseJID(forward.ChatJID); err != nil || forward.ChatJID == "" {
+			fail("Use a group JID (…@g.us) or a phone number with country code", "topics.forwards[%d].chat_jid %q is invalid", i, forward.ChatJID)
+		}
+		if forward.Media && forward.Topic == TopicPhotos {
+			warn("Leave media off so photos are only sent on by face matching", "topics.forwards[%d] sends every photo to %s, whoever is in it", i, forward.ChatJID)
+		}
+	}
+	if len(c.Topics.Forwards) > 0 && !c.Topics.Enabled {
+		warn("Set topics.enabled to true", "topics.forwards are set but topics are off, nothing is forwarded")
+	}
+	if c.Acknowledgments.NudgeHours < 1 {
+		fail("Use a number of hours of at least 1, or leave it out for the default", "acknowledgments.nudge_hours must be positive")
+	}
+	if c.Acknowledgments.MaxNudges < 0 {
+		fail("Use the number of nudges per forward, or 0 to only track acknowledgments", "acknowledgments.max_nudges can't be negative")
+	}
+	if c.Acknowledgments.Enabled && (!c.Topics.Enabled || len(c.Topics.Forwards) == 0) {
+		warn("Set topics.enabled and topics.forwards", "acknowledgments are on but nothing is forwarded by topic, there is nothing to acknowledge")
+	}
+
+	for i, rule := range c.AutoReply.Rules {
+		switch rule.Chats {
+		case "", AutoReplyDirect:
+		case AutoReplyGroups:
+			if len(rule.Keywords) == 0 {
+				fail("Add keywords, so the rule doesn't answer every message of the groups", "auto_reply.rules[%d] answers groups without keywords", i)
+			}
+		default:
+			fail("Use direct or groups", "auto_reply.rules[%d].chats %q is invalid", i, rule.Chats)
+		}
+		if strings.TrimSpace(rule.Text) == "" {
+			fail("Write the reply", "auto_reply.rules[%d] has no text", i)
+		}
+	}
+	if c.AutoReply.RateLimitHours < 1 {
+		fail("Use a number of hours of at least 1, or leave it out for the default", "auto_reply.rate_limit_hours must be positive")
+	}
+	if c.AutoReply.Enabled && len(c.AutoReply.Rules) == 0 {
+		warn("Add auto_reply.rules", "auto_reply is on without rules, nothing is answered")
+	}
+
+	for i, welcome := range c.Welcome.Messages {
+		if !strings.HasSuffix(welcome.ChatJID, "@g.us") && !c.IsGroupAlias(welcome.ChatJID) {
+			fail("Use a group JID (…@g.us) or a group alias", "welcome.messages[%d].chat_jid %q is not a group", i, welcome.ChatJID)
+		}
+		if strings.TrimSpace(welcome.Text) == "" {
+			fail("Write the greeting", "welcome.messages[%d] has no text", i)
+		}
+	}
+
+	if c.Payments.ChatJID != "" {
+		if _, err := types.ParseJID(c.Payments.ChatJID); err != nil {
+			fail("Use a group JID (…@g.us) or a phone number with country code, or leave it out for your own chat", "payments.chat_jid %q is invalid", c.Payments.ChatJID)
+		}
+	}
+	if c.Payments.ReminderHours < 1 {
+		fail("Use a number of hours of at least 1, or leave it out for the default", "payments.reminder_hours must be positive")
+	}
+	if c.Payments.MaxReminders < 1 {
+		fail("Use a number of reminders of at least 1, or leave it out for the default", "payments.max_reminders must be positive")
+	}
+
+	if c.Forms.ChatJID != "" {
+		if _, err := types.ParseJID(c.Forms.ChatJID); err != nil {
+			fail("Use a group JID (…@g.us) or a phone number with country code, or leave it out for your own chat", "forms.chat_jid %q is invalid", c.Forms.ChatJID)
+		}
+	}
+	if c.Forms.ReminderHours < 1 {
+		fail("Use a number of hours of at least 1, or leave it out for the default", "forms.reminder_hours must be positive")
+	}
+	if c.Forms.OCRURL != "" {
+		if u, err := url.Parse(c.Forms.OCRURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("Use a full http(s) URL", "forms.ocr_url %q is not a valid URL", c.Forms.OCRURL)
+		}
+	}
+
+	if c.Calendar.DetectorURL != "" {
+		if u, err := url.Parse(c.Calendar.DetectorURL); err != nil || u.Host == "" {
+			fail("Use a full http(s) URL", "calendar.detector_url %q is not a valid URL", c.Calendar.DetectorURL)
+		}
+	}
+	if c.Tracing.Enabled {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Host == "" {
+			fail("Use the base URL of the collector, e.g. http://localhost:4318", "tracing.otlp_endpoint %q is not a valid URL", c.Tracing.Endpoint)
+		}
+	}
+	switch c.Publisher.Type {
+	case "":
+	case PublisherKafka, PublisherNATS:
+		schemes := map[string]bool{"http": true, "https": true}
+		example := "http://localhost:8082"
+		if c.Publisher.Type == PublisherNATS {
+			schemes = map[string]bool{"nats": true, "tls": true}
+			example = "nats://localhost:4222"
+		}
+		if u, err := url.Parse(c.Publisher.URL); err != nil || !schemes[u.Scheme] || u.Host == "" {
+			fail("Use a URL like "+example, "publisher.url %q is not a valid %s URL", c.Publisher.URL, c.Publisher.Type)
+		}
+		if c.Publisher.Topic == "" {
+			fail("Set the Kafka topic or NATS subject events are published to", "publisher.topic is empty")
+		}
+	default:
+		fail(`Use "kafka", "nats", or leave it empty`, "publisher.type %q is not supported", c.Publisher.Type)
+	}
+	return problems
+}
+
+// HasErrors reports whether any of the problems is more than a warning
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
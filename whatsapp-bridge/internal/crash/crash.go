@@ -0,0 +1,72 @@
+// Package crash keeps a panic while handling one message, request or background job from taking the
+// bridge down: the panic is recovered and written with its stack trace to a crash file in the data
+// directory, and sent to the alerts chat when alerts.crashes is set.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/version"
+)
+
+// FileName is the crash file in the data directory
+const FileName = "crashes.log"
+
+// maxFileSize is the size at which the crash file is moved to FileName.1 and started anew, so a panic
+// on every message can't fill the disk
+const maxFileSize = 4 << 20
+
+// fileMu serializes writes to the crash file
+var fileMu sync.Mutex
+
+// Recover recovers a panic and reports it as having happened in where. Defer it directly, as a deferred
+// call of Recover itself, at the top of the code it protects:
+//
+//	defer crash.Recover("history sync worker")
+func Recover(where string) {
+	if value := recover(); value != nil {
+		Report(where, value, debug.Stack())
+	}
+}
+
+// Report records a recovered panic: to the log, to the crash file and, when alerts.crashes is set, to
+// the alerts chat
+func Report(where string, value interface{}, stack []byte) {
+	fmt.Printf("[CRASH] Recovered from a panic in %s: %v\n%s", where, value, stack)
+	if err := appendToFile(where, value, stack); err != nil {
+		fmt.Printf("[ERROR] Failed to write the crash file: %v\n", err)
+	}
+	if config.Current().Alerts.Crashes {
+		notify.Alert("crash:"+where, i18n.T("alert.crash", where, value, store.Path(FileName)))
+	}
+}
+
+// appendToFile adds a crash to the crash file, starting a new file when it grew too big
+func appendToFile(where string, value interface{}, stack []byte) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	path := store.Path(FileName)
+	if info, err := os.Stat(path); err == nil && info.Size() > maxFileSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "=== %s, %s, version %s\npanic: %v\n\n%s\n", time.Now().Format(time.RFC3339), where, version.Get().Version, value, stack)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+// Package embed turns the text of stored messages into vectors that are close for messages of similar
+// meaning, and keeps the message store's vectors table up to date, so searches find messages despite
+// typos and whether they were written in Hebrew or in English.
+package embed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/store"
+)
+
+const (
+	// idleInterval is how often new messages are looked for once all stored ones are embedded
+	idleInterval = 30 * time.Second
+	// maxRetryDelay caps the wait between attempts while the embedder fails
+	maxRetryDelay = 10 * time.Minute
+	// embedTimeout bounds one batch
+	embedTimeout = 2 * time.Minute
+)
+
+// Embedder computes embeddings of texts
+type Embedder interface {
+	// Model names the embeddings, which are only compared with embeddings of the same model
+	Model() string
+	// Embed returns a vector per text, in the order of texts
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// active is the embedder indexing the message store, nil while semantic search is off
+var active atomic.Pointer[Embedder]
+
+// New returns the embedder of cfg, or nil when semantic search is off
+func New(cfg config.EmbeddingsConfig) Embedder {
+	switch cfg.Embedder {
+	case config.EmbedderLocal:
+		return localEmbedder{}
+	case config.EmbedderRemote:
+		return newRemoteEmbedder(cfg)
+	default:
+		return nil
+	}
+}
+
+// Active returns the embedder the message store is indexed with, or nil while semantic search is off
+func Active() Embedder {
+	if embedder := active.Load(); embedder != nil {
+		return *embedder
+	}
+	return nil
+}
+
+// Start embeds the stored messages that have no embedding of the configured model in the background,
+// newest first, then each new message as it is stored
+func Start(cfg config.EmbeddingsConfig, messageStore *store.MessageStore) {
+	embedder := New(cfg)
+	if embedder == nil {
+		return
+	}
+	active.Store(&embedder)
+	if embedded, total, err := messageStore.CountEmbeddings(embedder.Model()); err == nil {
+		fmt.Printf("[EMBED] Embedding messages with %s, %d of %d done\n", embedder.Model(), embedded, total)
+	}
+	go index(embedder, messageStore, cfg.BatchSize)
+}
+
+// index embeds pending messages batch after batch until the process exits
+func index(embedder Embedder, messageStore *store.MessageStore, batchSize int) {
+	retryDelay := idleInterval
+	for {
+		count, err := indexBatch(embedder, messageStore, batchSize)
+		switch {
+		case err != nil:
+			fmt.Printf("[EMBED] Failed to embed messages, retrying in %s: %v\n", retryDelay, err)
+			time.Sleep(retryDelay)
+			retryDelay = min(retryDelay*2, maxRetryDelay)
+		case count < batchSize:
+			retryDelay = idleInterval
+			time.Sleep(idleInterval)
+		default:
+			retryDelay = idleInterval
+		}
+	}
+}
+
+// indexBatch embeds up to batchSize pending messages and returns how many there were. A panic in the
+// embedder fails the batch rather than stopping the indexing.
+func indexBatch(embedder Embedder, messageStore *store.MessageStore, batchSize int) (count int, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			crash.Report("the embedding indexer", value, debug.Stack())
+			err = fmt.Errorf("embedder panicked: %v", value)
+		}
+	}()
+	jobs, err := messageStore.PendingEmbeddings(embedder.Model(), batchSize)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+	texts := make([]string, len(jobs))
+	for i, job := range jobs {
+		texts[i] = job.Text
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), embedTimeout)
+	defer cancel()
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	if len(vectors) != len(jobs) {
+		return 0, fmt.Errorf("got %d embeddings for %d messages", len(vectors), len(jobs))
+	}
+	embeddings := make([]store.Embedding, len(jobs))
+	for i, job := range jobs {
+		embeddings[i] = store.Embedding{Rowid: job.Rowid, Vector: normalize(vectors[i])}
+	}
+	return len(jobs), messageStore.SaveEmbeddings(embedder.Model(), embeddings)
+}
+
+// Nearest returns up to limit messages matching filter closest in meaning to text, most similar first,
+// or nil while semantic search is off
+func Nearest(ctx context.Context, messageStore *store.MessageStore, text string, filter store.ExportFilter, limit int) ([]store.SearchResult, error) {
+	embedder := Active()
+	if embedder == nil {
+		return nil, nil
+	}
+	vectors, err := embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for one text", len(vectors))
+	}
+	return messageStore.NearestMessages(embedder.Model(), normalize(vectors[0]), filter, limit)
+}
+
+// normalize scales vector to unit length, so the dot product of two vectors is their cosine similarity
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, value := range vector {
+		sum += float64(value) * float64(value)
+	}
+	if sum == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
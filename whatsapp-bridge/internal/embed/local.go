@@ -0,0 +1,85 @@
+package embed
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+
+	"whatsapp-client/internal/store"
+)
+
+// localDimensions is the length of the local embedder's vectors
+const localDimensions = 512
+
+// localEmbedder needs no model: a text's vector counts the letter trigrams of its words, which a typo
+// changes only a few of, and the trigrams of the words' consonants with Hebrew letters written as Latin
+// ones, which are mostly the same for a word written in either script, such as פורים and Purim. It knows
+// nothing of synonyms; a remote model does.
+type localEmbedder struct{}
+
+func (localEmbedder) Model() string {
+	return "local-trigrams-v1"
+}
+
+func (localEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, localDimensions)
+		for _, word := range store.SearchTerms(text) {
+			addTrigrams(vector, "^"+word+"$", "w")
+			if skeleton := consonants(word); len(skeleton) > 1 {
+				addTrigrams(vector, "^"+skeleton+"$", "c")
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// addTrigrams counts the trigrams of word in vector, hashed with kind so words and consonants don't share
+// buckets by design. Half the buckets are counted down, so collisions cancel out rather than add up.
+func addTrigrams(vector []float32, word, kind string) {
+	runes := []rune(word)
+	for i := 0; i+3 <= len(runes); i++ {
+		hash := fnv.New32a()
+		hash.Write([]byte(kind + string(runes[i:i+3])))
+		sum := hash.Sum32()
+		if sum&1 == 0 {
+			vector[(sum>>1)%localDimensions]++
+		} else {
+			vector[(sum>>1)%localDimensions]--
+		}
+	}
+}
+
+// hebrewConsonants spells Hebrew letters in Latin ones; letters that mostly stand for vowels are left out
+var hebrewConsonants = map[rune]string{
+	'ב': "b", 'ג': "g", 'ד': "d", 'ה': "h", 'ז': "z", 'ח': "h", 'ט': "t", 'כ': "k", 'ך': "k", 'ל': "l",
+	'מ': "m", 'ם': "m", 'נ': "n", 'ן': "n", 'ס': "s", 'פ': "p", 'ף': "p", 'צ': "ts", 'ץ': "ts", 'ק': "k",
+	'ר': "r", 'ש': "s", 'ת': "t",
+}
+
+// latinSounds brings Latin spellings of the same sound together, as the Hebrew letters are spelled
+var latinSounds = strings.NewReplacer("ch", "h", "sh", "s", "ph", "p", "th", "t", "tz", "ts", "ck", "k",
+	"c", "k", "q", "k", "f", "p", "v", "b", "x", "ks")
+
+// consonants reduces a word to its consonants in Latin letters, with doubled ones written once:
+// "hanukkah" and "חנוכה" both become "hnkh"
+func consonants(word string) string {
+	var latin strings.Builder
+	for _, r := range word {
+		if spelled, ok := hebrewConsonants[r]; ok {
+			latin.WriteString(spelled)
+		} else if r < 128 {
+			latin.WriteRune(r)
+		}
+	}
+	var skeleton []byte
+	for _, b := range []byte(latinSounds.Replace(latin.String())) {
+		if strings.IndexByte("aeiouyw", b) >= 0 || (len(skeleton) > 0 && skeleton[len(skeleton)-1] == b) {
+			continue
+		}
+		skeleton = append(skeleton, b)
+	}
+	return string(skeleton)
+}
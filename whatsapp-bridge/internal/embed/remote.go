@@ -0,0 +1,103 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"whatsapp-client/internal/config"
+)
+
+// maxTextLength is how much of a message is embedded; models take a few thousand tokens at most
+const maxTextLength = 4000
+
+// maxResponseSize bounds the embeddings response that is read
+const maxResponseSize = 64 << 20
+
+// remoteEmbedder asks an embeddings endpoint of the OpenAI API or a server compatible with it, such as
+// Ollama. A multilingual model places Hebrew and English texts of the same meaning close together.
+type remoteEmbedder struct {
+	config config.EmbeddingsConfig
+	client *http.Client
+}
+
+func newRemoteEmbedder(cfg config.EmbeddingsConfig) *remoteEmbedder {
+	return &remoteEmbedder{config: cfg, client: &http.Client{}}
+}
+
+func (e *remoteEmbedder) Model() string {
+	return e.config.Model
+}
+
+type embeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (e *remoteEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	input := make([]string, len(texts))
+	for i, text := range texts {
+		if runes := []rune(text); len(runes) > maxTextLength {
+			text = string(runes[:maxTextLength])
+		}
+		input[i] = text
+	}
+	body, err := json.Marshal(embeddingsRequest{Model: e.config.Model, Input: input})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result embeddingsResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("embeddings endpoint returned %s", resp.Status)
+		}
+		return nil, fmt.Errorf("failed to read the embeddings: %v", err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("embeddings endpoint returned %s: %s", resp.Status, result.Error.Message)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("embeddings endpoint returned %s", resp.Status)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(vectors) || len(item.Embedding) == 0 {
+			return nil, fmt.Errorf("embeddings endpoint returned an embedding for input %d of %d", item.Index, len(texts))
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("embeddings endpoint returned no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,322 @@
+// Package forms finds forms parents must fill in or sign, such as permission slips and health
+// declarations, among the documents and photos posted to monitored chats, keeps them in the message
+// store and reminds about each until it is acknowledged.
+package forms
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/calendar"
+	"whatsapp-client/internal/commands"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/reminders"
+	"whatsapp-client/internal/search"
+	"whatsapp-client/internal/store"
+)
+
+// ocrTimeout bounds reading the text of one photo
+const ocrTimeout = 30 * time.Second
+
+// formMimeTypes are the document formats forms are sent in; documents of other formats, and photos, are
+// only taken for forms by their words
+var formMimeTypes = []string{
+	"application/pdf",
+	"application/msword",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// defaultKeywords mark a photo or document as a form. "form" only matches itself and its plural, not
+// "format" or "formal".
+var defaultKeywords = search.Keywords{
+	Stems: []string{
+		"permission", "consent", "signature", "sign and", "please sign", "fill in", "fill out", "waiver",
+		"טופס", "טפסים", "אישור הורים", "הצהרת בריאות", "הצהרה", "חתימ", "לחתום", "חתמו", "למלא", "מלאו", "יש להחזיר",
+	},
+	Words: []string{"form", "forms"},
+}
+
+// forms is the store forms are kept in: the shared one, also for messages of isolated pipelines, so
+// reminders, commands and the API see them all
+var forms atomic.Pointer[store.MessageStore]
+
+// Message is a photo or document just received, to be checked for a form
+type Message struct {
+	ID         string
+	ChatJID    string
+	Sender     string
+	SenderName string
+	// Caption of the photo or document
+	Text string
+	// "image" or "document"
+	MediaType string
+	MimeType  string
+	FileName  string
+	// Downloaded photo, read with OCR when configured
+	MediaPath string
+	Timestamp time.Time
+}
+
+// Sender sends text to a chat, or a photo with the text as its caption when mediaPath is set
+type Sender func(ctx context.Context, chatJID, text, mediaPath, mediaType string) error
+
+// readText returns the text of a photo from the configured OCR service
+func readText(ocrURL, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: ocrTimeout}
+	resp, err := client.Post(ocrURL, http.DetectContentType(data), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OCR service returned %s", resp.Status)
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid OCR response: %v", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// Record stores msg as a form in the store Start was given if it looks like one: a PDF or Word document,
+// or a photo or document whose caption, file name or, with forms.ocr_url, text has a form's words. A
+// date in the text is taken as the day the form is due. Photos are read in the background, so a slow OCR
+// service doesn't hold up storing the message. Reminders start with the next check of the schedule.
+func Record(msg Message, logger waLog.Logger) {
+	cfg := config.Current().Forms
+	if !cfg.Enabled || forms.Load() == nil || (msg.MediaType != "image" && msg.MediaType != "document") {
+		return
+	}
+	keywords := defaultKeywords
+	keywords.Stems = append(slices.Clone(keywords.Stems), cfg.Keywords...)
+	if msg.MediaType == "document" && slices.Contains(formMimeTypes, strings.ToLower(msg.MimeType)) ||
+		keywords.Match(msg.Text+"\n"+msg.FileName) {
+		storeForm(msg, msg.Text, logger)
+		return
+	}
+	if msg.MediaType != "image" || msg.MediaPath == "" || cfg.OCRURL == "" {
+		return
+	}
+	go func() {
+		defer crash.Recover("reading the text of a photo")
+		read, err := readText(cfg.OCRURL, msg.MediaPath)
+		if err != nil {
+			logger.Warnf("Failed to read the text of %s: %v", msg.ID, err)
+			return
+		}
+		if keywords.Match(read) {
+			storeForm(msg, strings.TrimSpace(msg.Text+"\n"+read), logger)
+		}
+	}()
+}
+
+// storeForm stores msg as a form with text, which is also searched for the day it is due
+func storeForm(msg Message, text string, logger waLog.Logger) {
+	form := store.Form{
+		MessageID:  msg.ID,
+		ChatJID:    msg.ChatJID,
+		Sender:     msg.Sender,
+		SenderName: msg.SenderName,
+		Text:       text,
+		FileName:   msg.FileName,
+		MediaPath:  msg.MediaPath,
+		ReceivedAt: msg.Timestamp,
+	}
+	// "Please return by Thursday" means the Thursday where the group lives
+	if due, ok := calendar.FindDate(text, msg.Timestamp.In(config.Current().Location())); ok {
+		form.DueAt = &due
+	}
+	id, created, err := forms.Load().StoreForm(form)
+	if err != nil {
+		logger.Warnf("Failed to store form %s: %v", msg.ID, err)
+		return
+	}
+	if created {
+		logger.Infof("[FORMS] Found form #%d in %s from %s", id, msg.ChatJID, msg.Sender)
+	}
+}
+
+// Start keeps forms in messageStore, sends due reminders with send in the background until ctx is done,
+// to forms.chat_jid or else the chat ownChat returns, and offers the form commands in the owner's own
+// chat
+func Start(ctx context.Context, messageStore *store.MessageStore, send Sender, ownChat func() string) {
+	forms.Store(messageStore)
+	registerCommands(messageStore)
+	reminders.Start(ctx, "the form reminders", func(ctx context.Context, now time.Time) {
+		sendReminders(ctx, messageStore, send, ownChat, now)
+	})
+}
+
+// sendReminders reminds about the open forms due at now. The first reminder of a form carries its photo.
+func sendReminders(ctx context.Context, messageStore *store.MessageStore, send Sender, ownChat func() string, now time.Time) {
+	cfg := config.Current().Forms
+	if !cfg.Enabled {
+		return
+	}
+	chatJID := cfg.ChatJID
+	if chatJID == "" {
+		chatJID = ownChat()
+	}
+	if chatJID == "" {
+		return
+	}
+	due, err := messageStore.DueFormReminders(now.Add(-time.Duration(cfg.ReminderHours) * time.Hour))
+	if err != nil {
+		fmt.Printf("[FORMS] Failed to get due reminders: %v\n", err)
+		return
+	}
+	for _, form := range due {
+		mediaPath, mediaType := "", ""
+		if form.Reminders == 0 && form.MediaPath != "" {
+			if _, err := os.Stat(form.MediaPath); err == nil {
+				mediaPath, mediaType = form.MediaPath, "image"
+			}
+		}
+		sendCtx, cancel := reminders.SendTimeout(ctx)
+		err := send(sendCtx, chatJID, formatReminder(form, now), mediaPath, mediaType)
+		cancel()
+		if err != nil {
+			fmt.Printf("[FORMS] Failed to send a reminder of form #%d: %v\n", form.ID, err)
+			return
+		}
+		if err := messageStore.RecordFormReminder(form.ID, now); err != nil {
+			fmt.Printf("[FORMS] Failed to record the reminder of form #%d: %v\n", form.ID, err)
+		}
+		fmt.Printf("[FORMS] Sent reminder %d of form #%d to %s\n", form.Reminders+1, form.ID, chatJID)
+	}
+}
+
+// formatReminder writes the reminder of a form: the first tells it was found, the later ones that it
+// still isn't handled, with the day it is due
+func formatReminder(form store.Form, now time.Time) string {
+	sender, chat := names(form)
+	lines := make([]string, 0, 6)
+	if form.Reminders == 0 {
+		lines = append(lines, i18n.T("forms.new", form.ID, sender, chat))
+	} else {
+		lines = append(lines, i18n.T("forms.reminder", form.ID, sender, chat, day(form.ReceivedAt)))
+	}
+	if form.DueAt != nil {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if form.DueAt.Before(today) {
+			lines = append(lines, i18n.T("forms.overdue", day(*form.DueAt)))
+		} else {
+			lines = append(lines, i18n.T("forms.due", day(*form.DueAt)))
+		}
+	}
+	if form.FileName != "" && form.MediaPath == "" {
+		lines = append(lines, "📎 "+form.FileName)
+	}
+	if form.Text != "" {
+		lines = append(lines, reminders.Quote(form.Text))
+	}
+	return strings.Join(lines, "\n") + "\n\n" + i18n.T("forms.acknowledge", form.ID)
+}
+
+// names returns who sent a form and in which chat, by name where known
+func names(form store.Form) (sender, chat string) {
+	sender, chat = form.SenderName, form.ChatName
+	if sender == "" {
+		sender = strings.SplitN(form.Sender, "@", 2)[0]
+	}
+	if chat == "" {
+		chat = form.ChatJID
+	}
+	return sender, chat
+}
+
+// day writes a day in the configured timezone and locale
+func day(t time.Time) string {
+	return i18n.Current().ShortDate(t.In(config.Current().Location()))
+}
+
+// title names a form in a list: its document's name, or the start of its caption
+func title(form store.Form) string {
+	if form.FileName != "" && form.MediaPath == "" {
+		return form.FileName
+	}
+	if form.Text != "" {
+		title := strings.TrimSpace(strings.SplitN(form.Text, "\n", 2)[0])
+		if runes := []rune(title); len(runes) > 40 {
+			title = string(runes[:40]) + "…"
+		}
+		return title
+	}
+	return filepath.Base(form.MediaPath)
+}
+
+// registerCommands offers listing the open forms and acknowledging them in the owner's chat
+func registerCommands(messageStore *store.MessageStore) {
+	commands.Register(commands.Command{
+		Names: []string{"forms", "טפסים"},
+		Help:  "forms.help_list",
+		Run: func(ctx context.Context, args []string) string {
+			open := false
+			forms, err := messageStore.GetForms("", &open)
+			if err != nil {
+				fmt.Printf("[FORMS] Failed to list forms: %v\n", err)
+				return i18n.T("commands.failed")
+			}
+			if len(forms) == 0 {
+				return i18n.T("forms.none_open")
+			}
+			lines := []string{i18n.T("forms.open")}
+			for _, form := range forms {
+				sender, chat := names(form)
+				item := i18n.T("forms.item", form.ID, title(form), sender, chat, day(form.ReceivedAt))
+				if form.DueAt != nil {
+					item += ", " + i18n.T("forms.due", day(*form.DueAt))
+				}
+				lines = append(lines, item)
+			}
+			return strings.Join(lines, "\n")
+		},
+	})
+	acknowledgeCommand := func(names []string, acknowledged bool, help, reply string) commands.Command {
+		return commands.Command{
+			Names: names,
+			Usage: "<number>",
+			Help:  help,
+			Run: func(ctx context.Context, args []string) string {
+				if len(args) != 1 {
+					return i18n.T("commands.usage", names[0])
+				}
+				id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+				if err != nil {
+					return i18n.T("commands.usage", names[0])
+				}
+				if err := messageStore.AcknowledgeForm(id, acknowledged); errors.Is(err, sql.ErrNoRows) {
+					return i18n.T("forms.unknown", id)
+				} else if err != nil {
+					fmt.Printf("[FORMS] Failed to update form #%d: %v\n", id, err)
+					return i18n.T("commands.failed")
+				}
+				return i18n.T(reply, id)
+			},
+		}
+	}
+	commands.Register(acknowledgeCommand([]string{"ack", "טופל"}, true, "forms.help_ack", "forms.acknowledged"))
+	commands.Register(acknowledgeCommand([]string{"unack", "לא-טופל"}, false, "forms.help_unack", "forms.reopened"))
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// directionControls are the invisible direction characters, which fonts have no glyphs for
+var directionControls = strings.NewReplacer(
+	"\u200e", "", "\u200f", "", "\u202a", "", "\u202b", "", "\u202c", "", "\u202d", "", "\u202e", "",
+	"\u2066", "", "\u2067", "", "\u2068", "", "\u2069", "",
+)
+
+// Visual returns a line of text in the order it is drawn from left to right, for code that draws glyphs
+// one after the other, like the photo book and the watermark: Hebrew is reversed, while Latin words and
+// numbers inside it keep their order. Direction characters are dropped.
+func Visual(line string) string {
+	if !hasRightToLeft(line) {
+		return directionControls.Replace(line)
+	}
+	var paragraph bidi.Paragraph
+	if _, err := paragraph.SetString(line); err != nil {
+		return directionControls.Replace(line)
+	}
+	ordering, err := paragraph.Order()
+	if err != nil {
+		return directionControls.Replace(line)
+	}
+
+	runs := make([]string, ordering.NumRuns())
+	for i := range runs {
+		run := ordering.Run(i)
+		runs[i] = run.String()
+		if run.Direction() == bidi.RightToLeft {
+			runs[i] = bidi.ReverseString(runs[i])
+		}
+	}
+	if startsRightToLeft(line) {
+		slices.Reverse(runs)
+	}
+	return directionControls.Replace(strings.Join(runs, ""))
+}
+
+// hasRightToLeft tells whether text has a Hebrew or Arabic letter
+func hasRightToLeft(text string) bool {
+	for _, r := range text {
+		if class := classOf(r); class == bidi.R || class == bidi.AL {
+			return true
+		}
+	}
+	return false
+}
+
+// startsRightToLeft tells whether the first letter of text, outside isolates, is written right to left,
+// which makes the whole line right to left
+func startsRightToLeft(text string) bool {
+	depth := 0
+	for _, r := range text {
+		switch class := classOf(r); {
+		case class == bidi.LRI, class == bidi.RLI, class == bidi.FSI:
+			depth++
+		case class == bidi.PDI && depth > 0:
+			depth--
+		case depth > 0:
+		case class == bidi.L:
+			return false
+		case class == bidi.R, class == bidi.AL:
+			return true
+		}
+	}
+	return false
+}
+
+// classOf is the bidirectional class of r
+func classOf(r rune) bidi.Class {
+	properties, _ := bidi.LookupRune(r)
+	return properties.Class()
+}
@@ -0,0 +1,166 @@
+package i18n
+
+import "whatsapp-client/internal/config"
+
+// catalogs holds the texts of each locale by key, as fmt formats. English has every text; a missing
+// translation falls back to it.
+var catalogs = map[Locale]map[string]string{
+	config.LocaleEnglish: {
+		"report.heading":       "📊 *%s*, %s",
+		"report.totals":        "%d messages, %d photos and videos",
+		"report.most_active":   "Most active:",
+		"report.sender":        "%d. %s: %d messages, %d media",
+		"report.busiest_hours": "Busiest hours: %s",
+		"report.top_reactions": "Top reactions: %s",
+
+		"weekly.none":      "No photos of %s were found this week",
+		"weekly.one":       "Only 1 photo of %s was found this week",
+		"weekly.other":     "Only %d photos of %s were found this week",
+		"weekly.shortfall": "%s, fewer than the %d you expect. It may be worth asking the teacher.",
+
+		"photobook.caption": "📖 %s, %s: %d photos",
+		"photobook.photo":   "1 photo",
+		"photobook.photos":  "%d photos",
+		"photobook.credit":  "Photo: %s",
+
+		"alert.crash":        "The bridge recovered from a crash in %s: %v. The stack trace is in %s.",
+		"alert.storage_full": "Media storage is full, photos and videos are no longer downloaded (thumbnails are kept): %s",
+		"alert.storage_ok":   "Media storage has room again, photos and videos are downloaded again",
+
+		"topics.forward":         "🏷️ %s: %s in %s",
+		"acks.nudge":             "🔔 Did you see this? Reply or react so I know it arrived",
+		"autoreply.opt_out_hint": "(Send STOP to not get automatic replies)",
+		"autoreply.opted_out":    "👍 OK, no more automatic replies",
+		"topic.logistics":        "Logistics",
+		"topic.health":           "Health",
+		"topic.photos":           "Photos",
+		"topic.payments":         "Payments",
+		"topic.events":           "Events",
+
+		"commands.help":   "🤖 Commands you can send to yourself:",
+		"commands.failed": "⚠️ That didn't work, see the bridge's log",
+		"commands.usage":  "❓ Use: %s <number>",
+
+		"payments.new":         "💳 Payment request #%d from %s in %s:",
+		"payments.reminder":    "💳 Still unpaid: request #%d from %s in %s on %s:",
+		"payments.mark_paid":   "Once it's paid, send \"paid %d\" to yourself.",
+		"payments.open":        "💳 Open payment requests:",
+		"payments.none_open":   "✅ No open payment requests",
+		"payments.item":        "#%d %s, %s in %s, %s",
+		"payments.unknown":     "❓ There's no payment request #%d",
+		"payments.marked_paid": "✅ Payment request #%d is marked paid, no more reminders",
+		"payments.marked_open": "↩️ Payment request #%d is open again",
+		"payments.help_list":   "the payment requests that aren't paid",
+		"payments.help_paid":   "mark a payment request paid",
+		"payments.help_unpaid": "mark a payment request not paid after all",
+
+		"forms.new":          "📝 Form #%d from %s in %s:",
+		"forms.reminder":     "📝 Still not handled: form #%d from %s in %s on %s:",
+		"forms.due":          "due %s",
+		"forms.overdue":      "❗ was due %s",
+		"forms.acknowledge":  "Once it's handled, send \"ack %d\" to yourself.",
+		"forms.open":         "📝 Forms not handled yet:",
+		"forms.none_open":    "✅ No forms waiting",
+		"forms.item":         "#%d %s, %s in %s, %s",
+		"forms.unknown":      "❓ There's no form #%d",
+		"forms.acknowledged": "✅ Form #%d is handled, no more reminders",
+		"forms.reopened":     "↩️ Form #%d is open again",
+		"forms.help_list":    "the forms that aren't handled",
+		"forms.help_ack":     "mark a form filled in and handed in",
+		"forms.help_unack":   "mark a form not handled after all",
+
+		"absence.sick":          "Good morning, %s is sick today and won't come. Thank you!",
+		"absence.sick_on":       "Hello, %s is sick and won't come on %s. Thank you!",
+		"absence.absent":        "Good morning, %s won't come today. Thank you!",
+		"absence.absent_on":     "Hello, %s won't come on %s. Thank you!",
+		"absence.sent":          "📨 Sent to the teacher:\n%s",
+		"absence.dry_run":       "📝 Dry run, not sent:\n%s",
+		"absence.usage":         "❓ Use: %s <child> [note]",
+		"absence.unknown_child": "❓ There's no child called %s",
+		"absence.no_teacher":    "❓ Set teacher_jid of destination %s first",
+		"absence.help_sick":     "tell the teacher a child is sick today",
+		"absence.help_absent":   "tell the teacher a child won't come today",
+	},
+	config.LocaleHebrew: {
+		"report.heading":       "📊 *%s*, %s",
+		"report.totals":        "%d הודעות, %d תמונות וסרטונים",
+		"report.most_active":   "הכי פעילים:",
+		"report.sender":        "%d. %s: %d הודעות, %d תמונות וסרטונים",
+		"report.busiest_hours": "השעות העמוסות: %s",
+		"report.top_reactions": "התגובות הנפוצות: %s",
+
+		"weekly.none":      "השבוע לא נמצאו תמונות של %s",
+		"weekly.one":       "השבוע נמצאה רק תמונה אחת של %s",
+		"weekly.other":     "השבוע נמצאו רק %d תמונות של %s",
+		"weekly.shortfall": "%s, פחות מ־%d שציפיתם להן. אולי כדאי לשאול את הצוות.",
+
+		"photobook.caption": "📖 %s, %s: %d תמונות",
+		"photobook.photo":   "תמונה אחת",
+		"photobook.photos":  "%d תמונות",
+		"photobook.credit":  "צילום: %s",
+
+		"alert.crash":        "הגשר התאושש מקריסה (%s): %v. פרטי הקריסה נשמרו ב־%s.",
+		"alert.storage_full": "אחסון המדיה מלא, תמונות וסרטונים כבר לא יורדים (התמונות הממוזערות נשמרות): %s",
+		"alert.storage_ok":   "יש שוב מקום באחסון המדיה, תמונות וסרטונים יורדים שוב",
+
+		"topics.forward":         "🏷️ %s: %s ב%s",
+		"acks.nudge":             "🔔 ראיתם את זה? השיבו או הגיבו באימוג'י כדי שאדע שזה הגיע",
+		"autoreply.opt_out_hint": "(שלחו הסר כדי לא לקבל תשובות אוטומטיות)",
+		"autoreply.opted_out":    "👍 בסדר, לא יישלחו יותר תשובות אוטומטיות",
+		"topic.logistics":        "לוגיסטיקה",
+		"topic.health":           "בריאות",
+		"topic.photos":           "תמונות",
+		"topic.payments":         "תשלומים",
+		"topic.events":           "אירועים",
+
+		"commands.help":   "🤖 פקודות שאפשר לשלוח לעצמכם:",
+		"commands.failed": "⚠️ זה לא הצליח, הפרטים ביומן של הגשר",
+		"commands.usage":  "❓ כך: %s <מספר>",
+
+		"payments.new":         "💳 בקשת תשלום #%d מ%s ב%s:",
+		"payments.reminder":    "💳 עדיין לא שולם: בקשה #%d מ%s ב%s מ־%s:",
+		"payments.mark_paid":   "כשזה שולם, שלחו לעצמכם \"שולם %d\".",
+		"payments.open":        "💳 בקשות תשלום פתוחות:",
+		"payments.none_open":   "✅ אין בקשות תשלום פתוחות",
+		"payments.item":        "#%d %s, %s ב%s, %s",
+		"payments.unknown":     "❓ אין בקשת תשלום #%d",
+		"payments.marked_paid": "✅ בקשת תשלום #%d סומנה כשולמה, לא יהיו עוד תזכורות",
+		"payments.marked_open": "↩️ בקשת תשלום #%d פתוחה שוב",
+		"payments.help_list":   "בקשות התשלום שעוד לא שולמו",
+		"payments.help_paid":   "סימון בקשת תשלום כשולמה",
+		"payments.help_unpaid": "סימון בקשת תשלום כלא שולמה",
+
+		"forms.new":          "📝 טופס #%d מ%s ב%s:",
+		"forms.reminder":     "📝 עדיין לא טופל: טופס #%d מ%s ב%s מ־%s:",
+		"forms.due":          "להגיש עד %s",
+		"forms.overdue":      "❗ היה להגיש עד %s",
+		"forms.acknowledge":  "כשזה טופל, שלחו לעצמכם \"טופל %d\".",
+		"forms.open":         "📝 טפסים שעוד לא טופלו:",
+		"forms.none_open":    "✅ אין טפסים שמחכים",
+		"forms.item":         "#%d %s, %s ב%s, %s",
+		"forms.unknown":      "❓ אין טופס #%d",
+		"forms.acknowledged": "✅ טופס #%d טופל, לא יהיו עוד תזכורות",
+		"forms.reopened":     "↩️ טופס #%d פתוח שוב",
+		"forms.help_list":    "הטפסים שעוד לא טופלו",
+		"forms.help_ack":     "סימון טופס כממולא ומוגש",
+		"forms.help_unack":   "סימון טופס כלא טופל",
+
+		"absence.sick":          "בוקר טוב, %s חולה היום ולא יגיע/תגיע. תודה!",
+		"absence.sick_on":       "שלום, %s חולה ולא יגיע/תגיע ב־%s. תודה!",
+		"absence.absent":        "בוקר טוב, %s לא יגיע/תגיע היום. תודה!",
+		"absence.absent_on":     "שלום, %s לא יגיע/תגיע ב־%s. תודה!",
+		"absence.sent":          "📨 נשלח לגננת:\n%s",
+		"absence.dry_run":       "📝 הרצת ניסיון, לא נשלח:\n%s",
+		"absence.usage":         "❓ כך: %s <ילד/ה> [הערה]",
+		"absence.unknown_child": "❓ אין ילד/ה בשם %s",
+		"absence.no_teacher":    "❓ קודם צריך להגדיר teacher_jid ליעד %s",
+		"absence.help_sick":     "הודעה לגננת שהילד/ה חולה היום",
+		"absence.help_absent":   "הודעה לגננת שהילד/ה לא יגיע/תגיע היום",
+	},
+}
+
+// Names of months and weekdays in Hebrew, from January and from Sunday
+var (
+	hebrewMonths   = [12]string{"ינואר", "פברואר", "מרץ", "אפריל", "מאי", "יוני", "יולי", "אוגוסט", "ספטמבר", "אוקטובר", "נובמבר", "דצמבר"}
+	hebrewWeekdays = [7]string{"יום ראשון", "יום שני", "יום שלישי", "יום רביעי", "יום חמישי", "יום שישי", "שבת"}
+)
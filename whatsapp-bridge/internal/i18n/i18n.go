@@ -0,0 +1,91 @@
+// Package i18n writes the texts the bridge composes itself, such as reports, alerts and photo books, in
+// the language set by locale. Most families using the bridge read Hebrew, so the texts are also kept
+// readable when a Latin name or a phone number ends up inside a Hebrew sentence, and the other way round.
+package i18n
+
+import (
+	"fmt"
+	"time"
+
+	"whatsapp-client/internal/config"
+)
+
+// Unicode direction controls. An isolate keeps a value from taking over the direction of the sentence
+// around it; a mark sets the direction of a message whose first words are names or numbers.
+const (
+	firstStrongIsolate  = "\u2068"
+	popDirectionIsolate = "\u2069"
+	rightToLeftMark     = "\u200f"
+)
+
+// Locale is a language the bridge writes in, as in the locale setting
+type Locale string
+
+// Current is the configured locale
+func Current() Locale {
+	return Locale(config.Current().Locale)
+}
+
+// T formats the text key of the current locale
+func T(key string, args ...interface{}) string {
+	return Current().T(key, args...)
+}
+
+// RightToLeft tells whether the locale is written from right to left
+func (l Locale) RightToLeft() bool {
+	return l == config.LocaleHebrew
+}
+
+// T formats the text key with args, falling back to English for a text the locale has no translation
+// of. String arguments, such as names, are isolated so they don't turn the sentence around them.
+func (l Locale) T(key string, args ...interface{}) string {
+	format, ok := catalogs[l][key]
+	if !ok {
+		if format, ok = catalogs[config.LocaleEnglish][key]; !ok {
+			return key
+		}
+	}
+	for i, arg := range args {
+		if text, ok := arg.(string); ok {
+			args[i] = Isolate(text)
+		}
+	}
+	text := fmt.Sprintf(format, args...)
+	if l.RightToLeft() {
+		text = rightToLeftMark + text
+	}
+	return text
+}
+
+// Isolate wraps text in a first strong isolate, so it is laid out in its own direction without
+// affecting the text around it
+func Isolate(text string) string {
+	if text == "" {
+		return ""
+	}
+	return firstStrongIsolate + text + popDirectionIsolate
+}
+
+// Month names a month and its year, as in "January 2026"
+func (l Locale) Month(t time.Time) string {
+	if l == config.LocaleHebrew {
+		return fmt.Sprintf("%s %d", hebrewMonths[t.Month()-1], t.Year())
+	}
+	return t.Format("January 2006")
+}
+
+// Day names a day with its weekday, as in "Monday 2 February 2026"
+func (l Locale) Day(t time.Time) string {
+	if l == config.LocaleHebrew {
+		return fmt.Sprintf("%s, %d ב%s %d", hebrewWeekdays[t.Weekday()], t.Day(), hebrewMonths[t.Month()-1], t.Year())
+	}
+	return t.Format("Monday 2 January 2006")
+}
+
+// ShortDate is a compact date, as in "2 Feb 2026"
+func (l Locale) ShortDate(t time.Time) string {
+	if l == config.LocaleHebrew {
+		return t.Format("2.1.2006")
+	}
+	return t.Format("2 Jan 2006")
+}
@@ -0,0 +1,282 @@
+// Package importer merges WhatsApp "Export chat" archives into the message store.
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/links"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// ExportedMessage is a single message parsed from a WhatsApp "Export chat" text file
+type ExportedMessage struct {
+	Timestamp  time.Time
+	Sender     string
+	Content    string
+	Attachment string
+}
+
+// ImportResult summarizes an import run
+type ImportResult struct {
+	Parsed   int
+	Imported int
+	Skipped  int
+	Media    int
+}
+
+var (
+	// iOS:     [17/10/2025, 09:15:32] Dana: Good morning
+	// Android: 17/10/2025, 09:15 - Dana: Good morning
+	iosLinePattern     = regexp.MustCompile(`^\x{200E}?\[(\d{1,2})[./](\d{1,2})[./](\d{2,4}),? (\d{1,2}):(\d{2})(?::(\d{2}))?(?:\s*([AaPp][Mm]))?\] ([^:]+): (.*)$`)
+	androidLinePattern = regexp.MustCompile(`^\x{200E}?(\d{1,2})[./](\d{1,2})[./](\d{2,4}),? (\d{1,2}):(\d{2})(?::(\d{2}))?(?:\s*([AaPp][Mm]))? - ([^:]+): (.*)$`)
+
+	// iOS:     <attached: 00000012-PHOTO-2025-10-17-09-15-32.jpg>
+	// Android: IMG-20251017-WA0001.jpg (file attached)
+	iosAttachmentPattern     = regexp.MustCompile(`<attached: ([^>]+)>`)
+	androidAttachmentPattern = regexp.MustCompile(`^\x{200E}?(\S+\.\w+) \(file attached\)`)
+)
+
+// parseExportTimestamp builds a local timestamp from the captured date/time parts of an export line
+// (day-or-month, month-or-day, year, hour, minute, optional second, optional AM/PM). dayFirst selects
+// between DD/MM/YYYY and MM/DD/YYYY exports, which can't be told apart reliably.
+func parseExportTimestamp(parts []string, dayFirst bool) (time.Time, error) {
+	nums := make([]int, 6)
+	for i := 0; i < 6; i++ {
+		if parts[i] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return time.Time{}, err
+		}
+		nums[i] = n
+	}
+
+	day, month, year, hour, minute, second := nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]
+	if !dayFirst {
+		day, month = month, day
+	}
+	if year < 100 {
+		year += 2000
+	}
+	switch strings.ToLower(parts[6]) {
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("invalid date")
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, 0, config.Current().Location()), nil
+}
+
+// parseChatExport parses the text of a WhatsApp chat export into messages.
+// Lines that don't start with a timestamp are continuations of the previous message.
+func parseChatExport(r io.Reader, dayFirst bool) ([]ExportedMessage, error) {
+	var messages []ExportedMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		match := iosLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			match = androidLinePattern.FindStringSubmatch(line)
+		}
+		if match == nil {
+			if len(messages) > 0 {
+				messages[len(messages)-1].Content += "\n" + line
+			}
+			continue
+		}
+
+		timestamp, err := parseExportTimestamp(match[1:8], dayFirst)
+		if err != nil {
+			continue
+		}
+		msg := ExportedMessage{
+			Timestamp: timestamp,
+			Sender:    strings.TrimSpace(strings.Trim(match[8], "\u200e")),
+			Content:   strings.Trim(match[9], "\u200e"),
+		}
+		if m := iosAttachmentPattern.FindStringSubmatch(msg.Content); m != nil {
+			msg.Attachment = m[1]
+			msg.Content = strings.TrimSpace(iosAttachmentPattern.ReplaceAllString(msg.Content, ""))
+		} else if m := androidAttachmentPattern.FindStringSubmatch(msg.Content); m != nil {
+			msg.Attachment = m[1]
+			msg.Content = strings.TrimSpace(strings.TrimPrefix(strings.Trim(msg.Content, "\u200e"), m[0]))
+		}
+		messages = append(messages, msg)
+	}
+	return messages, scanner.Err()
+}
+
+// importedMessageID derives a stable ID for an imported message so repeated imports are idempotent
+func importedMessageID(chatJID string, msg ExportedMessage) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%s|%s|%s", chatJID, msg.Timestamp.Unix(), msg.Sender, msg.Content, msg.Attachment)))
+	return "import-" + hex.EncodeToString(sum[:8])
+}
+
+// importMediaType maps an attachment file name to the media_type stored with the message
+func importMediaType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".webp", ".heic":
+		return "image"
+	case ".mp4", ".mov", ".3gp":
+		return "video"
+	case ".opus", ".ogg", ".m4a", ".mp3":
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// ImportChatExport merges a WhatsApp "Export chat" ZIP into the message store under chatJID, or into
+// its pipeline's store and media directory if the chat belongs to an isolated pipeline. Attachments are
+// stored by content but not queued for the face filter, so it doesn't treat old photos as new ones.
+func ImportChatExport(messageStore *store.MessageStore, zipPath, chatJID, chatName string, dayFirst bool) (*ImportResult, error) {
+	messageStore, err := routing.ChatStore(messageStore, chatJID)
+	if err != nil {
+		return nil, err
+	}
+	mediaRoot := routing.MediaRoot(chatJID)
+
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export: %v", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File)
+	var chatFile *zip.File
+	for _, f := range archive.File {
+		files[path.Base(f.Name)] = f
+		if strings.HasSuffix(strings.ToLower(f.Name), ".txt") && (chatFile == nil || path.Base(f.Name) == "_chat.txt") {
+			chatFile = f
+		}
+	}
+	if chatFile == nil {
+		return nil, fmt.Errorf("export contains no chat text file")
+	}
+
+	reader, err := chatFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	messages, err := parseChatExport(reader, dayFirst)
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", chatFile.Name, err)
+	}
+
+	result := &ImportResult{Parsed: len(messages)}
+	if len(messages) == 0 {
+		return result, nil
+	}
+
+	if chatName == "" {
+		chatName = strings.TrimSuffix(path.Base(chatFile.Name), ".txt")
+	}
+	if err := messageStore.EnsureChat(chatJID, chatName, messages[len(messages)-1].Timestamp); err != nil {
+		return nil, fmt.Errorf("failed to store chat: %v", err)
+	}
+
+	logger := waLog.Stdout("Import", "INFO", true)
+	for _, msg := range messages {
+		id := importedMessageID(chatJID, msg)
+		content := routing.RedactContent(chatJID, msg.Content)
+
+		exists, err := messageStore.HasMessage(id, chatJID)
+		if err != nil {
+			return result, err
+		}
+		if !exists && content != "" && msg.Attachment == "" {
+			if exists, err = messageStore.HasSimilarMessage(chatJID, content, msg.Timestamp); err != nil {
+				return result, err
+			}
+		}
+		if exists {
+			result.Skipped++
+			continue
+		}
+
+		mediaPath, mediaType := "", ""
+		if f, ok := files[msg.Attachment]; ok && msg.Attachment != "" {
+			// Stored by content like downloaded media, so a photo that was also received live is one file
+			data, err := readZipFile(f)
+			if err == nil {
+				mediaPath, _, err = media.StoreBlobIn(mediaRoot, data, strings.ToLower(path.Ext(msg.Attachment)))
+			}
+			if err != nil {
+				logger.Warnf("Failed to extract %s: %v", msg.Attachment, err)
+				mediaPath = ""
+			} else {
+				mediaType = importMediaType(msg.Attachment)
+				result.Media++
+			}
+		}
+
+		// Text sent with an attachment is its caption
+		text := content
+		var details *store.MediaDetails
+		if mediaPath != "" {
+			details = &store.MediaDetails{
+				Caption:  content,
+				Filename: path.Base(msg.Attachment),
+				MimeType: mime.TypeByExtension(strings.ToLower(path.Ext(msg.Attachment))),
+				FileSize: int64(files[msg.Attachment].UncompressedSize64),
+			}
+			content = ""
+		}
+
+		err = messageStore.StoreIncoming(store.IncomingMessage{
+			ID:        id,
+			ChatJID:   chatJID,
+			Sender:    msg.Sender,
+			Content:   content,
+			Timestamp: msg.Timestamp,
+			ImageURL:  mediaPath,
+			MediaType: mediaType,
+			Details:   details,
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to store message: %v", err)
+		}
+		links.Archive(messageStore, id, chatJID, msg.Sender, text, msg.Timestamp, logger)
+		result.Imported++
+	}
+
+	return result, nil
+}
+
+// readZipFile reads a single ZIP entry
+func readZipFile(f *zip.File) ([]byte, error) {
+	src, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
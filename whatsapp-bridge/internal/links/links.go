@@ -0,0 +1,100 @@
+package links
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/store"
+)
+
+var (
+	linkTitleQueue     = make(chan titleJob, 1000)
+	linkTitleQueueOnce sync.Once
+)
+
+// titleJob is a title lookup with the store its link was archived in: the shared database and each
+// isolated pipeline's database number their links independently
+type titleJob struct {
+	store *store.MessageStore
+	store.LinkTitleJob
+}
+
+// ExtractURLs returns all distinct http(s) URLs found in the text
+func ExtractURLs(text string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		u := strings.TrimRight(match, ".,;:!?)]}'")
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// Archive stores the URLs of a message and queues their titles to be fetched in the background
+func Archive(messageStore *store.MessageStore, messageID, chatJID, sender, content string, timestamp time.Time, logger waLog.Logger) {
+	if content == "" {
+		return
+	}
+
+	jobs, err := messageStore.StoreLinks(messageID, chatJID, sender, ExtractURLs(content), timestamp)
+	if err != nil {
+		logger.Warnf("Failed to store links: %v", err)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	linkTitleQueueOnce.Do(func() {
+		go linkTitleWorker(logger)
+	})
+	for _, job := range jobs {
+		select {
+		case linkTitleQueue <- titleJob{store: messageStore, LinkTitleJob: job}:
+		default:
+			// The queue is full (e.g. during a large history sync); the link stays without a title
+			logger.Warnf("Link title queue full, skipping title lookup for %s", job.URL)
+		}
+	}
+}
+
+// linkTitleWorker fetches page titles for archived links one at a time
+func linkTitleWorker(logger waLog.Logger) {
+	for job := range linkTitleQueue {
+		storeLinkTitle(job, logger)
+	}
+}
+
+// storeLinkTitle fetches and stores the title of one link; a page that makes the parser panic only
+// loses its title
+func storeLinkTitle(job titleJob, logger waLog.Logger) {
+	defer crash.Recover("fetching the title of a link")
+	title, err := fetchPageTitle(job.URL)
+	if err != nil {
+		logger.Debugf("Failed to fetch title for %s: %v", job.URL, err)
+		return
+	}
+	if err := job.store.SetLinkTitle(job.ID, title); err != nil {
+		logger.Warnf("Failed to store title for %s: %v", job.URL, err)
+	}
+}
+
+// fetchPageTitle returns the og:title or <title> of a web page
+func fetchPageTitle(pageURL string) (string, error) {
+	body, err := fetchLimited(pageURL, linkPreviewMaxPageBytes)
+	if err != nil {
+		return "", err
+	}
+	if title := extractPageTitle(string(body), parseMetaTags(string(body))); title != "" {
+		return title, nil
+	}
+	return "", fmt.Errorf("page has no title")
+}
@@ -0,0 +1,67 @@
+package links
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/store"
+)
+
+// TestArchiveTitlesOwnStore archives a link in the shared database and one in an isolated pipeline's
+// database, which both number it 1, and checks each title lands in the database its link is in
+func TestArchiveTitlesOwnStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><head><title>%s page</title></head></html>", r.URL.Path[1:])
+	}))
+	defer server.Close()
+	// The test server listens on loopback, which the preview client refuses
+	client := linkPreviewHTTP
+	linkPreviewHTTP = server.Client()
+	defer func() { linkPreviewHTTP = client }()
+
+	store.Dir = t.TempDir()
+	shared, err := store.New()
+	if err != nil {
+		t.Fatalf("open shared store: %v", err)
+	}
+	defer shared.Close()
+	pipeline, err := store.ForPipeline("iso")
+	if err != nil {
+		t.Fatalf("open pipeline store: %v", err)
+	}
+	defer store.ClosePipelines()
+
+	now := time.Now()
+	Archive(shared, "m1", "120363000000000001@g.us", "972500000001", "see "+server.URL+"/shared", now, waLog.Noop)
+	Archive(pipeline, "m2", "120363000000000009@g.us", "972500000002", "see "+server.URL+"/pipeline", now, waLog.Noop)
+
+	for name, want := range map[string]struct {
+		store *store.MessageStore
+		title string
+	}{
+		"shared":   {shared, "shared page"},
+		"pipeline": {pipeline, "pipeline page"},
+	} {
+		var title string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			links, err := want.store.GetLinks("", 10)
+			if err != nil {
+				t.Fatalf("%s links: %v", name, err)
+			}
+			if len(links) != 1 {
+				t.Fatalf("%s store has %d links, want 1", name, len(links))
+			}
+			if title = links[0].Title; title != "" {
+				break
+			}
+		}
+		if title != want.title {
+			t.Errorf("%s link title = %q, want %q", name, title, want.title)
+		}
+	}
+}
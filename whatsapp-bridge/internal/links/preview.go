@@ -0,0 +1,222 @@
+// Package links archives the URLs shared in stored messages and builds link previews for outgoing ones.
+package links
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image/jpeg"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"whatsapp-client/internal/media"
+)
+
+// Preview holds the metadata shown by WhatsApp under a message containing a URL
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	Thumbnail   []byte
+}
+
+const (
+	linkPreviewTimeout      = 10 * time.Second
+	linkPreviewMaxPageBytes = 512 * 1024
+	linkPreviewMaxImageSize = 5 * 1024 * 1024
+	linkPreviewThumbSize    = 160
+)
+
+var (
+	urlPattern      = regexp.MustCompile(`https?://[^\s<>"]+`)
+	titleTagPattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagPattern  = regexp.MustCompile(`(?is)<meta\s+[^>]*>`)
+	metaAttrPattern = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+	// Anyone in a monitored chat can post a URL, so previews only connect to public addresses, also
+	// after redirects: never to the bridge's host, its network or a cloud metadata service
+	linkPreviewHTTP = &http.Client{
+		Timeout: linkPreviewTimeout,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: linkPreviewTimeout, Control: refuseNonPublic}).DialContext,
+			TLSHandshakeTimeout: linkPreviewTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing to follow a redirect to %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	// sharedAddressSpace is the carrier-grade NAT range, private like RFC 1918 though net doesn't say so
+	sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+)
+
+// refuseNonPublic keeps link previews from connecting to loopback, private, link-local (which includes
+// the metadata service at 169.254.169.254), multicast and unspecified addresses. It checks the address
+// actually dialed, so a host name resolving to one is refused as well.
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) ||
+		ip.To4() != nil && ip.To4()[0] == 0 {
+		return fmt.Errorf("refusing to connect to %s, it isn't a public address", host)
+	}
+	return nil
+}
+
+// findFirstURL returns the first http(s) URL in the text, stripped of trailing punctuation
+func findFirstURL(text string) string {
+	match := urlPattern.FindString(text)
+	return strings.TrimRight(match, ".,;:!?)]}'")
+}
+
+// FetchPreview downloads the page behind the first URL in text and builds preview metadata.
+// It returns nil if the text has no URL or the page doesn't provide a title.
+func FetchPreview(text string) *Preview {
+	pageURL := findFirstURL(text)
+	if pageURL == "" {
+		return nil
+	}
+
+	preview, err := buildLinkPreview(pageURL)
+	if err != nil {
+		fmt.Printf("[PREVIEW] Failed to build link preview for %s: %v\n", pageURL, err)
+		return nil
+	}
+	return preview
+}
+
+// buildLinkPreview fetches a page and extracts its title, description and thumbnail
+func buildLinkPreview(pageURL string) (*Preview, error) {
+	body, err := fetchLimited(pageURL, linkPreviewMaxPageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := parseMetaTags(string(body))
+	preview := &Preview{
+		URL:         pageURL,
+		Title:       extractPageTitle(string(body), meta),
+		Description: firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]),
+	}
+	if preview.Title == "" {
+		return nil, fmt.Errorf("page has no title")
+	}
+
+	// The thumbnail is optional, a preview without an image is still better than none
+	if imageURL := firstNonEmpty(meta["og:image"], meta["twitter:image"]); imageURL != "" {
+		if thumb, err := buildPreviewThumbnail(pageURL, imageURL); err == nil {
+			preview.Thumbnail = thumb
+		} else {
+			fmt.Printf("[PREVIEW] Failed to build thumbnail for %s: %v\n", pageURL, err)
+		}
+	}
+
+	return preview, nil
+}
+
+// buildPreviewThumbnail downloads the preview image and scales it down to a small JPEG
+func buildPreviewThumbnail(pageURL, imageURL string) ([]byte, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fetchLimited(base.ResolveReference(ref).String(), linkPreviewMaxImageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	thumb := media.ResizeToFit(img, linkPreviewThumbSize, linkPreviewThumbSize)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fetchLimited performs a GET request and reads at most maxBytes of the response body
+func fetchLimited(target string, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatsAppBridge/1.0)")
+
+	resp, err := linkPreviewHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+}
+
+// extractPageTitle returns the best title of an HTML document, preferring social metadata over <title>
+func extractPageTitle(doc string, meta map[string]string) string {
+	if title := firstNonEmpty(meta["og:title"], meta["twitter:title"]); title != "" {
+		return title
+	}
+	if match := titleTagPattern.FindStringSubmatch(doc); match != nil {
+		return strings.TrimSpace(html.UnescapeString(match[1]))
+	}
+	return ""
+}
+
+// parseMetaTags extracts <meta property|name=... content=...> pairs from an HTML document
+func parseMetaTags(doc string) map[string]string {
+	meta := make(map[string]string)
+	for _, tag := range metaTagPattern.FindAllString(doc, -1) {
+		var key, content string
+		for _, attr := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.Trim(attr[2], `"'`))
+			switch strings.ToLower(attr[1]) {
+			case "property", "name":
+				key = strings.ToLower(value)
+			case "content":
+				content = strings.TrimSpace(value)
+			}
+		}
+		if key != "" && content != "" {
+			if _, exists := meta[key]; !exists {
+				meta[key] = content
+			}
+		}
+	}
+	return meta
+}
+
+// firstNonEmpty returns the first non-empty string of the given values
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
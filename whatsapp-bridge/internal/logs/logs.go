@@ -0,0 +1,157 @@
+// Package logs keeps the bridge's warnings and errors in the message store, so they can be read through
+// the API by someone who can't get at the machine's console. Everything the bridge and whatsmeow log is
+// printed to os.Stdout; Capture puts a pipe in its place that passes every line on to the console and
+// mirrors the lines that report a problem.
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+const (
+	// flushInterval is how often captured lines are written to the store
+	flushInterval = time.Second
+	// queueSize is how many lines may wait for the store; more are dropped rather than slowing the bridge
+	queueSize = 1000
+	// pruneInterval is how often lines past logs.retention_days are deleted
+	pruneInterval = time.Hour
+	// maxMessageLength is how much of a line is stored
+	maxMessageLength = 4000
+)
+
+var (
+	// loggerLevel finds the level whatsmeow's loggers write after the module, as in [Client ERROR]
+	loggerLevel = regexp.MustCompile(`\[\w+ (DEBUG|INFO|WARN|ERROR)\]`)
+	// colorCode matches the terminal colors of whatsmeow's loggers
+	colorCode = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// Capture mirrors warnings and errors printed from now on into messageStore, until the returned function
+// is called. That function restores os.Stdout and stores the lines still waiting.
+func Capture(messageStore *store.MessageStore) func() {
+	console := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to capture the log, warnings and errors are not stored: %v\n", err)
+		return func() {}
+	}
+	os.Stdout = writer
+
+	queue := make(chan store.LogEntry, queueSize)
+	read := make(chan struct{})
+	stored := make(chan struct{})
+	go func() {
+		defer close(read)
+		defer close(queue)
+		lines := bufio.NewReader(reader)
+		for {
+			line, err := lines.ReadString('\n')
+			if line != "" {
+				console.WriteString(line)
+				enqueue(queue, line)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(stored)
+		persist(messageStore, queue, console)
+	}()
+
+	return func() {
+		os.Stdout = console
+		writer.Close()
+		<-read
+		<-stored
+		reader.Close()
+	}
+}
+
+// enqueue queues a line for the store if it reports a problem at or above logs.level
+func enqueue(queue chan<- store.LogEntry, line string) {
+	want := config.Current().Logs.Level
+	if want == config.LogLevelOff {
+		return
+	}
+	line = strings.TrimSpace(colorCode.ReplaceAllString(line, ""))
+	level := lineLevel(line)
+	if level == "" || (want == config.LogLevelError && level != config.LogLevelError) {
+		return
+	}
+	if len(line) > maxMessageLength {
+		line = line[:maxMessageLength]
+	}
+	select {
+	case queue <- store.LogEntry{Level: level, Message: line, Timestamp: time.Now()}:
+	default:
+	}
+}
+
+// lineLevel returns the level of a log line: error, warn, or "" for lines that don't report a problem.
+// Lines of whatsmeow's loggers carry their level; the bridge's own are tagged [ERROR] or [CRASH] for
+// errors, and warnings are tagged [ALERT], say Warning, or tell that something failed.
+func lineLevel(line string) string {
+	if match := loggerLevel.FindStringSubmatch(line); match != nil {
+		switch match[1] {
+		case "ERROR":
+			return config.LogLevelError
+		case "WARN":
+			return config.LogLevelWarn
+		}
+		return ""
+	}
+	switch {
+	case strings.Contains(line, "[ERROR]"), strings.Contains(line, "[CRASH]"):
+		return config.LogLevelError
+	case strings.Contains(line, "[ALERT]"), strings.Contains(line, "Warning"), strings.Contains(strings.ToLower(line), "failed"):
+		return config.LogLevelWarn
+	}
+	return ""
+}
+
+// persist writes queued lines to the message store in batches and prunes old ones, until the queue is
+// closed. Its own failures go straight to the console, so they aren't captured in turn.
+func persist(messageStore *store.MessageStore, queue <-chan store.LogEntry, console *os.File) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []store.LogEntry
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := messageStore.SaveLogEntries(batch); err != nil {
+			fmt.Fprintf(console, "[LOGS] Could not store %d log lines: %v\n", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	var pruned time.Time
+	for {
+		select {
+		case entry, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+		case <-ticker.C:
+			flush()
+			if time.Since(pruned) >= pruneInterval {
+				pruned = time.Now()
+				days := config.Current().Logs.RetentionDays
+				if _, err := messageStore.DeleteLogEntriesBefore(time.Now().AddDate(0, 0, -days)); err != nil {
+					fmt.Fprintf(console, "[LOGS] Could not delete old log lines: %v\n", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// blobDir is the subdirectory of Dir that holds media by content hash
+const blobDir = "sha256"
+
+// BlobPath returns where content with the given hex SHA-256 hash is stored: Dir/sha256/ab/cd/<hash><ext>.
+// The two levels of subdirectories keep directories small on photo-heavy installs.
+func BlobPath(hash, ext string) string {
+	return blobPathIn(Dir, hash, ext)
+}
+
+func blobPathIn(root, hash, ext string) string {
+	return filepath.Join(root, blobDir, hash[:2], hash[2:4], hash+ext)
+}
+
+// StoreBlob writes data under its SHA-256 hash and returns its path. Messages with the same photo share
+// one file: created is false when the content was already stored, and nothing is written then. The
+// file is written under a temporary name and renamed, so readers never see a partial file.
+func StoreBlob(data []byte, ext string) (path string, created bool, err error) {
+	return StoreBlobIn(Dir, data, ext)
+}
+
+// StoreBlobIn is StoreBlob with root instead of Dir, for the media of isolated pipelines
+func StoreBlobIn(root string, data []byte, ext string) (path string, created bool, err error) {
+	sum := sha256.Sum256(data)
+	path = blobPathIn(root, hex.EncodeToString(sum[:]), ext)
+	if _, err := os.Stat(path); err == nil {
+		return path, false, nil
+	}
+	if err := CheckSpace(int64(len(data))); err != nil {
+		return "", false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", false, fmt.Errorf("failed to create media directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", false, err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", false, err
+	}
+	stored(int64(len(data)))
+	return path, true, nil
+}
+
+// pipelinesDir is the subdirectory of Dir with the queue of each named pipeline
+const pipelinesDir = "pipelines"
+
+// QueueDir returns the directory where photos of a pipeline wait for its face filter service: Dir for
+// the top-level pipeline, and Dir/pipelines/<name> for named ones
+func QueueDir(pipeline string) string {
+	if pipeline == "" {
+		return Dir
+	}
+	return filepath.Join(Dir, pipelinesDir, pipeline)
+}
+
+// QueueDirs returns the queue directories of the top-level pipeline and of the named pipelines that
+// have had photos
+func QueueDirs() []string {
+	dirs := []string{Dir}
+	entries, _ := os.ReadDir(filepath.Join(Dir, pipelinesDir))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(Dir, pipelinesDir, entry.Name()))
+		}
+	}
+	return dirs
+}
+
+// QueueForFaceFilter puts a stored photo in the queue directory of a pipeline, which its face filter
+// service watches. The service deletes files there once they are processed, so the photo is linked
+// rather than moved and the blob stays; where hard links aren't supported it is copied.
+func QueueForFaceFilter(path, pipeline string) error {
+	if err := os.MkdirAll(QueueDir(pipeline), 0755); err != nil {
+		return err
+	}
+	dest := filepath.Join(QueueDir(pipeline), filepath.Base(path))
+	err := os.Link(path, dest)
+	if err == nil || errors.Is(err, os.ErrExist) {
+		return nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// PendingFaceFilter returns the number of photos waiting for the face filter service of a pipeline
+func PendingFaceFilter(pipeline string) int {
+	entries, err := os.ReadDir(QueueDir(pipeline))
+	if err != nil {
+		return 0
+	}
+	pending := 0
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			pending++
+		}
+	}
+	return pending
+}
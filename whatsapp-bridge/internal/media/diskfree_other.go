@@ -0,0 +1,8 @@
+//go:build !unix
+
+package media
+
+// freeSpace can't tell the free space on this platform, so only the quota applies
+func freeSpace(dir string) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package media
+
+import "syscall"
+
+// freeSpace returns the bytes available to the bridge on the disk holding dir
+func freeSpace(dir string) (int64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
@@ -0,0 +1,41 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"github.com/disintegration/imaging"
+)
+
+// faceCropSize is the largest width or height of a cropped face
+const faceCropSize = 200
+
+// FaceCrop cuts the face at box (top, right, bottom, left) out of a photo, with some margin around it,
+// as a JPEG. The face filter service reads photos without applying their EXIF orientation, so neither
+// does this, or the box would point elsewhere.
+func FaceCrop(data []byte, box [4]int) ([]byte, error) {
+	release := acquireImageSlot()
+	defer release()
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding image: %v", err)
+	}
+	top, right, bottom, left := box[0], box[1], box[2], box[3]
+	margin := (bottom - top) / 4
+	rect := image.Rect(left-margin, top-margin, right+margin, bottom+margin).Intersect(img.Bounds())
+	if rect.Empty() {
+		return nil, fmt.Errorf("face at %v is outside the %v image", box, img.Bounds().Size())
+	}
+	face := imaging.Crop(img, rect)
+	if face.Bounds().Dx() > faceCropSize || face.Bounds().Dy() > faceCropSize {
+		face = imaging.Fit(face, faceCropSize, faceCropSize, imaging.Lanczos)
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, face, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("Error encoding JPEG: %v", err)
+	}
+	return out.Bytes(), nil
+}
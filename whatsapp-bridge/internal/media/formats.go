@@ -0,0 +1,76 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	// Stickers arrive as WebP and scanned school letters often as TIFF
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// heifBrands are the ftyp brands of HEIC/HEIF photos, which iPhones take by default
+var heifBrands = [][]byte{[]byte("heic"), []byte("heix"), []byte("heim"), []byte("heis"), []byte("hevc"), []byte("mif1"), []byte("msf1")}
+
+// heifConverters are tried in order to turn a HEIF photo into a JPEG. No pure-Go HEVC decoder exists,
+// so one of libheif, ImageMagick or ffmpeg has to be installed.
+var heifConverters = [][]string{
+	{"heif-convert", "-q", "100"},
+	{"magick"},
+	{"convert"},
+	{"ffmpeg", "-y", "-loglevel", "error", "-i"},
+}
+
+// heifTimeout bounds converting one HEIF photo
+const heifTimeout = 30 * time.Second
+
+// isHEIF reports whether data is a HEIC/HEIF image
+func isHEIF(data []byte) bool {
+	if len(data) < 12 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+	for _, brand := range heifBrands {
+		if bytes.Equal(data[8:12], brand) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertHEIF turns a HEIF image into a JPEG with the first converter that is installed
+func convertHEIF(data []byte) ([]byte, error) {
+	if !externalTools {
+		return nil, fmt.Errorf("HEIC/HEIF images can't be read by purego builds, which start no converters")
+	}
+	dir, err := os.MkdirTemp("", "heif")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	input, output := filepath.Join(dir, "input.heic"), filepath.Join(dir, "output.jpg")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		return nil, err
+	}
+
+	for _, converter := range heifConverters {
+		path, err := exec.LookPath(converter[0])
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), heifTimeout)
+		args := append(append([]string{}, converter[1:]...), input, output)
+		out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+		cancel()
+		if err != nil {
+			fmt.Printf("HEIF conversion with %s failed: %v: %s\n", converter[0], err, out)
+			continue
+		}
+		return os.ReadFile(output)
+	}
+	return nil, fmt.Errorf("HEIC/HEIF images need heif-convert (libheif), ImageMagick or ffmpeg to be installed")
+}
@@ -0,0 +1,88 @@
+package media
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter bounds the number of concurrent media downloads and their average bandwidth. A nil Limiter
+// doesn't limit anything.
+type Limiter struct {
+	slots          chan struct{}
+	bytesPerSecond int64
+
+	mu   sync.Mutex
+	next time.Time
+
+	waiting atomic.Int64
+}
+
+// LimiterStats is the number of downloads running and waiting for a slot or their turn
+type LimiterStats struct {
+	Active  int   `json:"active"`
+	Waiting int64 `json:"waiting"`
+}
+
+// Stats returns how many downloads hold a slot and how many are queued. Without a concurrency limit
+// Active is always 0.
+func (l *Limiter) Stats() LimiterStats {
+	if l == nil {
+		return LimiterStats{}
+	}
+	return LimiterStats{Active: len(l.slots), Waiting: l.waiting.Load()}
+}
+
+// NewLimiter allows concurrency downloads at a time (0 for no limit) sharing bytesPerSecond (0 for no limit)
+func NewLimiter(concurrency int, bytesPerSecond int64) *Limiter {
+	l := &Limiter{bytesPerSecond: bytesPerSecond}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	return l
+}
+
+// Wait blocks until a download of size bytes may start and returns a function that must be called when it
+// has finished. Downloads are paced so that on average no more than bytesPerSecond are fetched. Returns
+// ctx's error, and no slot, if ctx is done first.
+func (l *Limiter) Wait(ctx context.Context, size uint64) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if l.bytesPerSecond > 0 {
+		l.mu.Lock()
+		start := time.Now()
+		if l.next.After(start) {
+			start = l.next
+		}
+		l.next = start.Add(time.Duration(float64(size) / float64(l.bytesPerSecond) * float64(time.Second)))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(start))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
@@ -0,0 +1,120 @@
+// Package media handles the media files the bridge downloads and sends.
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is where downloaded media is stored, relative to the bridge's working directory. It follows
+// data_dir and is set at startup.
+var Dir = "store/media"
+
+// InDir reports whether path names a file inside Dir, the directories of pipelines included. Both are
+// resolved against the working directory, so ".." can't lead out of Dir.
+func InDir(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	dir, err := filepath.Abs(Dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, abs)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// FileKey identifies a media file independently of the directory it is referenced from; the face
+// detection service and the bridge see the same file under different relative paths
+func FileKey(path string) string {
+	return filepath.Base(filepath.Clean(path))
+}
+
+// tempFilePixels is the size from which converted images are encoded into a temporary file instead of
+// memory, so the decoded image can be freed before the JPEG is read back
+const tempFilePixels = 16_000_000
+
+// VerifyAndConvertImage decodes an image and re-encodes it as JPEG with the given quality, returning its
+// dimensions. A non-nil overlay is drawn onto the image first. Images with more than MaxPixels pixels
+// are refused with ErrTooManyPixels.
+func VerifyAndConvertImage(data []byte, quality int, overlay *Overlay) ([]byte, int, int, error) {
+	fmt.Printf("Processing image data: %d bytes\n", len(data))
+
+	// Try to detect content type
+	contentType := http.DetectContentType(data)
+	fmt.Printf("Detected content type: %s\n", contentType)
+
+	// Decode image, applying the EXIF orientation: phones store photos sideways with a tag saying how
+	// to turn them, and the tag is lost when the image is re-encoded. HEIC photos are converted with
+	// an external tool first, image.Decode can't read them.
+	release := acquireImageSlot()
+	defer release()
+	img, format, err := decodeUpright(data)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("Error decoding image: %w", err)
+	}
+	fmt.Printf("Successfully decoded image format: %s\n", format)
+
+	// Get dimensions
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// The encoder takes the decoded image as it is; only a watermark needs a drawable RGBA copy
+	if overlay != nil && overlay.Text != "" {
+		rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+		img = rgba
+		// A broken font shouldn't keep the photo from being sent
+		if err := drawOverlay(rgba, *overlay); err != nil {
+			fmt.Printf("Sending image without watermark: %v\n", err)
+		}
+	}
+
+	var jpegData []byte
+	if width*height >= tempFilePixels {
+		jpegData, err = encodeViaTempFile(&img, quality)
+	} else {
+		var jpegBuf bytes.Buffer
+		err = jpeg.Encode(&jpegBuf, img, &jpeg.Options{Quality: quality})
+		jpegData = jpegBuf.Bytes()
+	}
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("Error encoding JPEG: %v", err)
+	}
+	fmt.Printf("Successfully converted to JPEG: %d bytes\n", len(jpegData))
+
+	return jpegData, width, height, nil
+}
+
+// encodeViaTempFile encodes *img as JPEG into a temporary file, then drops the image before reading the
+// file back. A growing buffer would otherwise hold up to twice the JPEG next to the decoded image.
+func encodeViaTempFile(img *image.Image, quality int) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "convert-*.jpg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	writer := bufio.NewWriter(tmp)
+	err = jpeg.Encode(writer, *img, &jpeg.Options{Quality: quality})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	*img = nil
+	return os.ReadFile(tmp.Name())
+}
@@ -0,0 +1,85 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// imageSlots bounds how many images are decoded and processed at once; nil for no bound
+var imageSlots chan struct{}
+
+func init() {
+	if imageConcurrency > 0 {
+		imageSlots = make(chan struct{}, imageConcurrency)
+	}
+}
+
+// acquireImageSlot waits until another image may be processed, and returns the function releasing it
+func acquireImageSlot() func() {
+	if imageSlots == nil {
+		return func() {}
+	}
+	imageSlots <- struct{}{}
+	return func() { <-imageSlots }
+}
+
+// MaxPixels is the most pixels an image may have to be decoded; a photo of 50 megapixels takes 200 MB
+// once decoded. It follows media.max_image_pixels and is set at startup.
+var MaxPixels int64 = 60_000_000
+
+// ErrTooManyPixels is returned for images larger than MaxPixels, before they are decoded
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// checkPixels reads the dimensions of an image from its header and refuses it if it exceeds MaxPixels
+func checkPixels(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); MaxPixels > 0 && pixels > MaxPixels {
+		return fmt.Errorf("%w: %dx%d is %.1f megapixels, more than the limit of %.1f (media.max_image_pixels)",
+			ErrTooManyPixels, cfg.Width, cfg.Height, float64(pixels)/1e6, float64(MaxPixels)/1e6)
+	}
+	return nil
+}
+
+// decodeUpright decodes a photo in any of the supported formats, scales it down to maxWorkingSize
+// if set and turns it upright by its EXIF orientation. Scaling comes first, so the full-size image is
+// only held in its decoded form and never copied. Images over MaxPixels are refused before they are
+// decoded. Returns the image and its format.
+func decodeUpright(data []byte) (image.Image, string, error) {
+	if isHEIF(data) {
+		converted, err := convertHEIF(data)
+		if err != nil {
+			return nil, "", err
+		}
+		data = converted
+	}
+	if err := checkPixels(data); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if bounds := img.Bounds(); maxWorkingSize > 0 && (bounds.Dx() > maxWorkingSize || bounds.Dy() > maxWorkingSize) {
+		img = imaging.Fit(img, maxWorkingSize, maxWorkingSize, imaging.Linear)
+	}
+	return applyOrientation(img, exifOrientation(data)), format, nil
+}
+
+// DecodeImage decodes a photo in any of the supported formats, HEIF included, turned upright by its
+// EXIF orientation. In purego builds large photos come back scaled down.
+func DecodeImage(data []byte) (image.Image, error) {
+	release := acquireImageSlot()
+	defer release()
+	img, _, err := decodeUpright(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding image: %v", err)
+	}
+	return img, nil
+}
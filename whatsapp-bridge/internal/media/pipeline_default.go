@@ -0,0 +1,14 @@
+//go:build !purego
+
+package media
+
+// PureGo reports whether the bridge was built with the purego tag for small ARM boards
+const PureGo = false
+
+// Images are processed at full size, as many at a time as arrive, and HEIF photos and video poster
+// frames are converted with installed programs
+const (
+	maxWorkingSize   = 0
+	imageConcurrency = 0
+	externalTools    = true
+)
@@ -0,0 +1,16 @@
+//go:build purego
+
+package media
+
+// PureGo reports whether the bridge was built with the purego tag for small ARM boards
+const PureGo = true
+
+// On boards with 1 GB of memory, images are scaled down to at most 2560 pixels as soon as they are
+// decoded and decoded one at a time, so each takes a bounded amount of memory. Only Go code touches
+// images: no converter processes are started, so HEIF photos can't be read and videos get the
+// preview WhatsApp embeds as their poster.
+const (
+	maxWorkingSize   = 2560
+	imageConcurrency = 1
+	externalTools    = false
+)
@@ -0,0 +1,40 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// posterTimeout bounds extracting a poster frame with ffmpeg
+const posterTimeout = 30 * time.Second
+
+// PosterFrame writes the first frame of a video as a JPEG to posterPath, for galleries and feeds to
+// show before the video is played. ffmpeg is used when it is installed, except in purego builds;
+// otherwise the small preview WhatsApp embeds in video messages (fallback) is written, if there is one.
+func PosterFrame(ctx context.Context, videoPath, posterPath string, fallback []byte) error {
+	if err := os.MkdirAll(filepath.Dir(posterPath), 0755); err != nil {
+		return fmt.Errorf("failed to create poster directory: %v", err)
+	}
+
+	if ffmpeg, err := exec.LookPath("ffmpeg"); err == nil && externalTools {
+		ctx, cancel := context.WithTimeout(ctx, posterTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, ffmpeg, "-y", "-loglevel", "error", "-i", videoPath, "-frames:v", "1", "-q:v", "3", posterPath)
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		if len(fallback) == 0 {
+			return fmt.Errorf("ffmpeg failed: %v: %s", err, output)
+		}
+	}
+
+	if len(fallback) == 0 {
+		return fmt.Errorf("ffmpeg is not installed (or not used by purego builds) and the message has no preview")
+	}
+	return os.WriteFile(posterPath, fallback, 0644)
+}
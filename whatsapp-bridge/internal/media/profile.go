@@ -0,0 +1,29 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+
+	"github.com/disintegration/imaging"
+)
+
+// profilePhotoSize is the width and height of profile pictures; WhatsApp shows them square and rejects
+// large ones
+const profilePhotoSize = 640
+
+// ProfilePhoto converts an image to a profile picture: a square JPEG cropped from its center
+func ProfilePhoto(data []byte) ([]byte, error) {
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	size := min(profilePhotoSize, img.Bounds().Dx(), img.Bounds().Dy())
+	square := imaging.Fill(img, size, size, imaging.Center, imaging.Lanczos)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, square, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("Error encoding JPEG: %v", err)
+	}
+	return out.Bytes(), nil
+}
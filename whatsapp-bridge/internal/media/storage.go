@@ -0,0 +1,180 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrStorageFull is returned when the media quota is used up or the disk is almost full
+var ErrStorageFull = errors.New("media storage full")
+
+// storageScanInterval is how often the size of the media directory is recounted, which picks up
+// deleted messages and files removed by hand
+const storageScanInterval = 15 * time.Minute
+
+// StorageStatus is the state of the media directory, as reported by /api/status
+type StorageStatus struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes,omitempty"`
+	// -1 when the free space of the disk can't be determined
+	FreeBytes    int64  `json:"free_bytes"`
+	MinFreeBytes int64  `json:"min_free_bytes,omitempty"`
+	Full         bool   `json:"full"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// storage tracks the media directory against its quota and the free space of its disk. A nil
+// storage doesn't limit anything.
+type storage struct {
+	quota, minFree int64
+	onChange       func(full bool, reason string)
+
+	mu     sync.Mutex
+	used   int64
+	reason string
+}
+
+// current storage limits; unlimited until ConfigureStorage is called
+var guard *storage
+
+// ConfigureStorage limits the media directory to quota bytes (0 for no quota) and stops writes when
+// the disk has less than minFree bytes left (-1 for no check). onChange is called when storage becomes
+// full, with the reason, and when there is room again.
+func ConfigureStorage(quota, minFree int64, onChange func(full bool, reason string)) {
+	guard = &storage{quota: quota, minFree: minFree, onChange: onChange}
+	guard.scan()
+	go func() {
+		for range time.Tick(storageScanInterval) {
+			guard.scan()
+			guard.check(0)
+		}
+	}()
+}
+
+// CheckSpace reports whether size more bytes of media may be written, returning ErrStorageFull with
+// the reason when not
+func CheckSpace(size int64) error {
+	if guard == nil {
+		return nil
+	}
+	return guard.check(size)
+}
+
+// Storage returns the current state of the media directory
+func Storage() StorageStatus {
+	status := StorageStatus{FreeBytes: -1}
+	if free, ok := diskFree(); ok {
+		status.FreeBytes = free
+	}
+	if guard == nil {
+		status.UsedBytes = usage()
+		return status
+	}
+	guard.check(0)
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	status.UsedBytes, status.QuotaBytes, status.MinFreeBytes = guard.used, guard.quota, guard.minFree
+	status.Full, status.Reason = guard.reason != "", guard.reason
+	return status
+}
+
+// stored accounts for a file written to the media directory
+func stored(size int64) {
+	if guard == nil {
+		return
+	}
+	guard.mu.Lock()
+	guard.used += size
+	guard.mu.Unlock()
+}
+
+// check tests the quota and free space before size more bytes are written. The quota is soft: writes
+// stop once it is used up, so the last file may go over it.
+func (s *storage) check(size int64) error {
+	s.mu.Lock()
+	used := s.used
+	s.mu.Unlock()
+	free, freeKnown := diskFree()
+
+	reason := s.limitReason(used, free, freeKnown)
+	s.mu.Lock()
+	changed := reason != s.reason
+	s.reason = reason
+	s.mu.Unlock()
+	if changed && s.onChange != nil {
+		s.onChange(reason != "", reason)
+	}
+
+	if reason == "" && freeKnown && size > free {
+		reason = fmt.Sprintf("%s don't fit on the media disk", formatSize(size))
+	}
+	if reason != "" {
+		return fmt.Errorf("%w: %s", ErrStorageFull, reason)
+	}
+	return nil
+}
+
+// limitReason describes which limit used and free bytes reach, or returns "" if neither
+func (s *storage) limitReason(used, free int64, freeKnown bool) string {
+	if s.quota > 0 && used >= s.quota {
+		return fmt.Sprintf("media quota of %s used up (%s stored)", formatSize(s.quota), formatSize(used))
+	}
+	if freeKnown && s.minFree >= 0 && free < s.minFree {
+		return fmt.Sprintf("only %s free on the media disk, at least %s are kept free", formatSize(max(free, 0)), formatSize(s.minFree))
+	}
+	return ""
+}
+
+// scan recounts the size of the media directory
+func (s *storage) scan() {
+	used := usage()
+	s.mu.Lock()
+	s.used = used
+	s.mu.Unlock()
+}
+
+// formatSize writes a byte count the way alerts show it
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%d MB", n>>20)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// diskFree returns the free space of the disk the media directory is on, measured at its closest
+// existing parent until the directory is created
+func diskFree() (int64, bool) {
+	dir := filepath.Clean(Dir)
+	for {
+		if free, ok := freeSpace(dir); ok {
+			return free, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return 0, false
+		}
+		dir = parent
+	}
+}
+
+// usage adds up the media files. Files at the top of the directory are left out: they are photos
+// waiting for the face filter, which deletes them, and mostly links to stored files.
+func usage() int64 {
+	var used int64
+	filepath.Walk(Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Dir(path) == filepath.Clean(Dir) {
+			return nil
+		}
+		used += info.Size()
+		return nil
+	})
+	return used
+}
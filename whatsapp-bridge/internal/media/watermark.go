@@ -0,0 +1,84 @@
+package media
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"whatsapp-client/internal/i18n"
+)
+
+// Overlay is text stamped onto a photo before it is sent
+type Overlay struct {
+	Text string
+	// bottom-right, bottom-left, top-right or top-left
+	Position string
+	// Font file to draw with instead of the built-in Go font
+	FontPath string
+}
+
+// LoadFont reads a .ttf or .otf font file, or returns the built-in Go font when path is empty
+func LoadFont(path string) (*opentype.Font, error) {
+	data := goregular.TTF
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return opentype.Parse(data)
+}
+
+// drawOverlay writes overlay's text in white on a translucent dark band in a corner of img. The text
+// is sized relative to the photo, so it reads the same on a phone and on a photo frame.
+func drawOverlay(img draw.Image, overlay Overlay) error {
+	parsed, err := LoadFont(overlay.FontPath)
+	if err != nil {
+		return fmt.Errorf("failed to load watermark font: %v", err)
+	}
+
+	bounds := img.Bounds()
+	size := float64(min(bounds.Dx(), bounds.Dy())) / 25
+	if size < 10 {
+		size = 10
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return fmt.Errorf("failed to load watermark font: %v", err)
+	}
+	defer face.Close()
+
+	// The drawer lays glyphs out left to right, so Hebrew is put in visual order first
+	text := i18n.Visual(overlay.Text)
+	drawer := &font.Drawer{Dst: img, Src: image.White, Face: face}
+	metrics := face.Metrics()
+	textWidth := drawer.MeasureString(text).Ceil()
+	textHeight := (metrics.Ascent + metrics.Descent).Ceil()
+	padding := int(size / 2)
+	margin := int(size / 2)
+
+	// Band around the text, in the configured corner
+	width, height := textWidth+2*padding, textHeight+padding
+	x := bounds.Max.X - margin - width
+	if strings.HasSuffix(overlay.Position, "left") {
+		x = bounds.Min.X + margin
+	}
+	y := bounds.Max.Y - margin - height
+	if strings.HasPrefix(overlay.Position, "top") {
+		y = bounds.Min.Y + margin
+	}
+	band := image.Rect(x, y, x+width, y+height)
+	draw.Draw(img, band, image.NewUniform(color.NRGBA{0, 0, 0, 110}), image.Point{}, draw.Over)
+
+	drawer.Dot = fixed.P(x+padding, y+padding/2+metrics.Ascent.Ceil())
+	drawer.DrawString(text)
+	return nil
+}
@@ -0,0 +1,57 @@
+// Package notify alerts whoever runs the bridge about problems that need attention, such as a full
+// disk, in a WhatsApp chat of their choice.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// repeatInterval is how long the same kind of alert is held back after it was sent, so a condition
+// that comes and goes doesn't flood the alerts chat
+const repeatInterval = 6 * time.Hour
+
+// sendTimeout bounds sending one alert
+const sendTimeout = time.Minute
+
+// Sender delivers an alert's text to a chat
+type Sender func(ctx context.Context, chatJID, text string) error
+
+var (
+	mu      sync.Mutex
+	chatJID string
+	send    Sender
+	sent    = make(map[string]time.Time)
+)
+
+// Configure sends alerts to chat with send. Without a chat, alerts are only logged.
+func Configure(chat string, sender Sender) {
+	mu.Lock()
+	defer mu.Unlock()
+	chatJID, send = chat, sender
+}
+
+// Alert logs text and sends it to the alerts chat, unless an alert of the same kind went out within
+// repeatInterval. Sending happens in the background.
+func Alert(kind, text string) {
+	fmt.Printf("[ALERT] %s\n", text)
+
+	mu.Lock()
+	chat, sender := chatJID, send
+	if chat == "" || sender == nil || time.Since(sent[kind]) < repeatInterval {
+		mu.Unlock()
+		return
+	}
+	sent[kind] = time.Now()
+	mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := sender(ctx, chat, "⚠️ "+text); err != nil {
+			fmt.Printf("[ERROR] Failed to send %s alert: %v\n", kind, err)
+		}
+	}()
+}
@@ -0,0 +1,237 @@
+// Package oidc logs people in through an OpenID Connect provider such as Google or Authentik: the
+// authorization code flow with PKCE, and verification of the ID token against the provider's keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-client/internal/config"
+)
+
+// loginTimeout is how long a login started at the provider may take to come back
+const loginTimeout = 10 * time.Minute
+
+// client talks to the provider; discovery, keys and token requests are small and should be quick
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// Provider is an OpenID Connect provider as described by its discovery document
+type Provider struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+
+	keys keySet
+}
+
+var (
+	// providers are the discovered providers by issuer URL
+	providers   = make(map[string]*Provider)
+	providersMu sync.Mutex
+)
+
+// discover returns the provider of an issuer, fetching its discovery document on first use
+func discover(ctx context.Context, issuer string) (*Provider, error) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if provider, ok := providers[issuer]; ok {
+		return provider, nil
+	}
+
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	var provider Provider
+	if err := getJSON(ctx, wellKnown, &provider); err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %v", issuer, err)
+	}
+	if strings.TrimSuffix(provider.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("%s describes issuer %s, not %s", wellKnown, provider.Issuer, issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, fmt.Errorf("%s lacks the authorization, token or keys endpoint", wellKnown)
+	}
+	provider.keys.uri = provider.JWKSURI
+	providers[issuer] = &provider
+	return &provider, nil
+}
+
+// pendingLogin is a login sent to the provider, waiting for the browser to come back with a code
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+var (
+	// pending are the logins under way by their state parameter
+	pending   = make(map[string]pendingLogin)
+	pendingMu sync.Mutex
+)
+
+// Begin starts a login and returns the provider URL to send the browser to, and the state the browser
+// has to come back with
+func Begin(ctx context.Context, cfg config.OIDCConfig) (string, string, error) {
+	provider, err := discover(ctx, cfg.Issuer)
+	if err != nil {
+		return "", "", err
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	pendingMu.Lock()
+	now := time.Now()
+	for key, login := range pending {
+		if now.After(login.expires) {
+			delete(pending, key)
+		}
+	}
+	pending[state] = pendingLogin{nonce: nonce, verifier: verifier, expires: now.Add(loginTimeout)}
+	pendingMu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, cfg.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// Identity is who logged in, as the provider's ID token tells
+type Identity struct {
+	Name   string
+	Groups []string
+}
+
+// Finish completes the login of state with the code the provider sent the browser back with: the code
+// is exchanged for an ID token, which is verified, and the user's name and groups are read from it
+func Finish(ctx context.Context, cfg config.OIDCConfig, state, code string) (*Identity, error) {
+	pendingMu.Lock()
+	login, ok := pending[state]
+	delete(pending, state)
+	pendingMu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		return nil, fmt.Errorf("unknown or expired login, start again")
+	}
+
+	provider, err := discover(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	rawToken, err := provider.exchange(ctx, cfg, code, login.verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := provider.verify(ctx, cfg, rawToken, login.nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	name, _ := claims[cfg.UsernameClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("the ID token has no %s claim, check oidc.username_claim and oidc.scopes", cfg.UsernameClaim)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified && cfg.UsernameClaim == "email" {
+		return nil, fmt.Errorf("the email address %s is not verified", name)
+	}
+	identity := &Identity{Name: name}
+	switch groups := claims[cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if group, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, group)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+	return identity, nil
+}
+
+// exchange trades an authorization code for the ID token
+func (p *Provider) exchange(ctx context.Context, cfg config.OIDCConfig, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	// Client secrets go in Basic auth unless the provider only takes them in the form
+	basic := len(p.TokenAuthMethods) == 0 || slices.Contains(p.TokenAuthMethods, "client_secret_basic")
+	if !basic {
+		form.Set("client_id", cfg.ClientID)
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var token struct {
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.Unmarshal(body, &token)
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		if token.Error != "" {
+			return "", fmt.Errorf("token request refused: %s %s", token.Error, token.Description)
+		}
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+	return token.IDToken, nil
+}
+
+// getJSON fetches a JSON document
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// randomString returns 32 random bytes, base64url encoded, for states, nonces and PKCE verifiers
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
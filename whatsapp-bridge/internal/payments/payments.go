@@ -0,0 +1,249 @@
+// Package payments finds requests for money in monitored chats, such as a Bit or PayBox link or "please
+// transfer 50₪", keeps them in the message store and reminds about each until it is marked paid.
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/commands"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/links"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/reminders"
+	"whatsapp-client/internal/search"
+	"whatsapp-client/internal/store"
+)
+
+// requests is the store payment requests are kept in: the shared one, also for messages of isolated
+// pipelines, so reminders, commands and the API see them all
+var requests atomic.Pointer[store.MessageStore]
+
+// paymentHosts are the hosts of payment links of Bit and PayBox, the apps parents pay each other with
+var paymentHosts = []string{"bitpay.co.il", "payboxapp.com", "payboxapp.page.link", "paybox.co.il"}
+
+// amountPattern finds an amount of shekels: "₪50", "50₪", "50 ש"ח", "1,200 NIS"
+var amountPattern = regexp.MustCompile(`(?i)₪\s*(\d{1,3}(?:,\d{3})+|\d+)(\.\d{1,2})?|(\d{1,3}(?:,\d{3})+|\d+)(\.\d{1,2})?\s*(?:₪|ש["״']?ח|שקל|nis\b|ils\b|shekel)`)
+
+// defaultKeywords mark a message with an amount as asking for it
+var defaultKeywords = search.Keywords{Stems: []string{
+	"pay", "payment", "paying", "transfer", "collect", "contribut", "bit", "paybox",
+	"לשלם", "תשלום", "תשלמו", "שלמו", "להעביר", "העבירו", "תעבירו", "העברה", "ביט", "פייבוקס", "גובים", "כסף",
+}}
+
+// Message is a message just stored, to be checked for a payment request
+type Message struct {
+	ID         string
+	ChatJID    string
+	Sender     string
+	SenderName string
+	Text       string
+	Timestamp  time.Time
+}
+
+// detect returns the amount and payment link of a message asking for money. A message asks for money
+// when it has a payment link, or an amount and one of keywords.
+func detect(text string, keywords search.Keywords) (amount float64, link string, ok bool) {
+	for _, u := range links.ExtractURLs(text) {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(parsed.Hostname())
+		if slices.ContainsFunc(paymentHosts, func(payment string) bool { return host == payment || strings.HasSuffix(host, "."+payment) }) {
+			link = u
+			break
+		}
+	}
+	if match := amountPattern.FindStringSubmatch(text); match != nil {
+		whole, fraction := match[1], match[2]
+		if whole == "" {
+			whole, fraction = match[3], match[4]
+		}
+		amount, _ = strconv.ParseFloat(strings.ReplaceAll(whole, ",", "")+fraction, 64)
+	}
+	if link != "" {
+		return amount, link, true
+	}
+	if amount == 0 || !keywords.Match(text) {
+		return 0, "", false
+	}
+	return amount, "", true
+}
+
+// Record stores msg as a payment request in the store Start was given, if it asks for money. Reminders
+// about it start with the next check of the schedule.
+func Record(msg Message, logger waLog.Logger) {
+	cfg := config.Current().Payments
+	messageStore := requests.Load()
+	if !cfg.Enabled || msg.Text == "" || messageStore == nil {
+		return
+	}
+	keywords := defaultKeywords
+	keywords.Stems = append(slices.Clone(keywords.Stems), cfg.Keywords...)
+	amount, link, ok := detect(msg.Text, keywords)
+	if !ok {
+		return
+	}
+	id, created, err := messageStore.StorePaymentRequest(store.PaymentRequest{
+		MessageID:   msg.ID,
+		ChatJID:     msg.ChatJID,
+		Sender:      msg.Sender,
+		SenderName:  msg.SenderName,
+		Text:        msg.Text,
+		Amount:      amount,
+		Link:        link,
+		RequestedAt: msg.Timestamp,
+	})
+	if err != nil {
+		logger.Warnf("Failed to store payment request %s: %v", msg.ID, err)
+		return
+	}
+	if created {
+		logger.Infof("[PAYMENTS] Found payment request #%d in %s from %s (%s)", id, msg.ChatJID, msg.Sender, formatAmount(amount))
+	}
+}
+
+// Start keeps payment requests in messageStore, sends due reminders with send in the background until
+// ctx is done, to payments.chat_jid or else the chat ownChat returns, and offers the payment commands in
+// the owner's own chat
+func Start(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, ownChat func() string) {
+	requests.Store(messageStore)
+	registerCommands(messageStore)
+	reminders.Start(ctx, "the payment reminders", func(ctx context.Context, now time.Time) {
+		sendReminders(ctx, messageStore, send, ownChat, now)
+	})
+}
+
+// sendReminders reminds about the open requests due at now
+func sendReminders(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, ownChat func() string, now time.Time) {
+	cfg := config.Current().Payments
+	if !cfg.Enabled {
+		return
+	}
+	chatJID := cfg.ChatJID
+	if chatJID == "" {
+		chatJID = ownChat()
+	}
+	if chatJID == "" {
+		return
+	}
+	due, err := messageStore.DuePaymentReminders(now.Add(-time.Duration(cfg.ReminderHours)*time.Hour), cfg.MaxReminders)
+	if err != nil {
+		fmt.Printf("[PAYMENTS] Failed to get due reminders: %v\n", err)
+		return
+	}
+	for _, request := range due {
+		sendCtx, cancel := reminders.SendTimeout(ctx)
+		err := send(sendCtx, chatJID, formatReminder(request))
+		cancel()
+		if err != nil {
+			fmt.Printf("[PAYMENTS] Failed to send a reminder of payment request #%d: %v\n", request.ID, err)
+			return
+		}
+		if err := messageStore.RecordPaymentReminder(request.ID, now); err != nil {
+			fmt.Printf("[PAYMENTS] Failed to record the reminder of payment request #%d: %v\n", request.ID, err)
+		}
+		fmt.Printf("[PAYMENTS] Sent reminder %d of payment request #%d to %s\n", request.Reminders+1, request.ID, chatJID)
+	}
+}
+
+// formatReminder writes the reminder of a payment request: the first tells it was found, the later ones
+// that it is still open
+func formatReminder(request store.PaymentRequest) string {
+	sender, chat := names(request)
+	var heading string
+	if request.Reminders == 0 {
+		heading = i18n.T("payments.new", request.ID, sender, chat)
+	} else {
+		heading = i18n.T("payments.reminder", request.ID, sender, chat, requestDay(request))
+	}
+	return heading + "\n" + reminders.Quote(request.Text) + "\n\n" + i18n.T("payments.mark_paid", request.ID)
+}
+
+// names returns who asked for a payment and in which chat, by name where known
+func names(request store.PaymentRequest) (sender, chat string) {
+	sender, chat = request.SenderName, request.ChatName
+	if sender == "" {
+		sender = strings.SplitN(request.Sender, "@", 2)[0]
+	}
+	if chat == "" {
+		chat = request.ChatJID
+	}
+	return sender, chat
+}
+
+// requestDay is the day a payment was asked for, in the configured timezone
+func requestDay(request store.PaymentRequest) string {
+	return i18n.Current().ShortDate(request.RequestedAt.In(config.Current().Location()))
+}
+
+// formatAmount writes an amount of shekels, or "?" when the request didn't say
+func formatAmount(amount float64) string {
+	if amount == 0 {
+		return "?"
+	}
+	return strconv.FormatFloat(amount, 'f', -1, 64) + " ₪"
+}
+
+// registerCommands offers listing the open requests and marking them paid or open in the owner's chat
+func registerCommands(messageStore *store.MessageStore) {
+	commands.Register(commands.Command{
+		Names: []string{"payments", "תשלומים"},
+		Help:  "payments.help_list",
+		Run: func(ctx context.Context, args []string) string {
+			open := false
+			requests, err := messageStore.GetPaymentRequests("", &open)
+			if err != nil {
+				fmt.Printf("[PAYMENTS] Failed to list payment requests: %v\n", err)
+				return i18n.T("commands.failed")
+			}
+			if len(requests) == 0 {
+				return i18n.T("payments.none_open")
+			}
+			lines := []string{i18n.T("payments.open")}
+			for _, request := range requests {
+				sender, chat := names(request)
+				lines = append(lines, i18n.T("payments.item", request.ID, formatAmount(request.Amount), sender, chat, requestDay(request)))
+			}
+			return strings.Join(lines, "\n")
+		},
+	})
+	markCommand := func(names []string, done bool, help, reply string) commands.Command {
+		return commands.Command{
+			Names: names,
+			Usage: "<number>",
+			Help:  help,
+			Run: func(ctx context.Context, args []string) string {
+				if len(args) != 1 {
+					return i18n.T("commands.usage", names[0])
+				}
+				id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+				if err != nil {
+					return i18n.T("commands.usage", names[0])
+				}
+				if err := messageStore.SetPaymentDone(id, done); errors.Is(err, sql.ErrNoRows) {
+					return i18n.T("payments.unknown", id)
+				} else if err != nil {
+					fmt.Printf("[PAYMENTS] Failed to update payment request #%d: %v\n", id, err)
+					return i18n.T("commands.failed")
+				}
+				return i18n.T(reply, id)
+			},
+		}
+	}
+	commands.Register(markCommand([]string{"paid", "שולם"}, true, "payments.help_paid", "payments.marked_paid"))
+	commands.Register(markCommand([]string{"unpaid", "לא-שולם"}, false, "payments.help_unpaid", "payments.marked_open"))
+}
@@ -0,0 +1,91 @@
+package photobook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/store"
+)
+
+// MonthStart returns the first moment of the month t falls in
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// ForMonth collects the book of a destination for the month starting at month: the photos really
+// forwarded there (not only recorded in dry-run mode), from its timeline
+func ForMonth(messageStore *store.MessageStore, key string, month time.Time) (Book, error) {
+	cfg := config.Current()
+	dest, ok := cfg.Destinations[key]
+	if !ok {
+		return Book{}, fmt.Errorf("no destination %s", key)
+	}
+	locale := i18n.Locale(cfg.Locale)
+	book := Book{Title: dest.Name, Period: locale.Month(month), FontPath: cfg.PhotoBook.FontPath, Location: cfg.Location(), Locale: locale}
+	if book.Title == "" {
+		book.Title = key
+	}
+
+	items, err := messageStore.GetTimeline(dest.Group, "", nil, store.ExportFilter{From: month, To: month.AddDate(0, 1, 0)}, 0)
+	if err != nil {
+		return Book{}, err
+	}
+	for _, item := range items {
+		if item.Type != store.TimelinePhoto || item.DryRun || item.MediaType != "image" || item.MessageID == "" {
+			continue
+		}
+		file, err := messageStore.GetMediaFile(item.MessageID, item.ChatJID)
+		if err != nil {
+			continue
+		}
+		book.Photos = append(book.Photos, Photo{Path: file.Path, Caption: item.Text, Sender: item.SenderName, Taken: item.Timestamp})
+	}
+	return book, nil
+}
+
+// Path is where the book of a destination for a month is saved
+func Path(key string, month time.Time) string {
+	return filepath.Join(config.Current().PhotoBook.Dir, key, month.Format("2006-01")+".pdf")
+}
+
+// Save writes the book of a destination for the month starting at month to its Path. A month without
+// photos gets no book; the returned count is then 0.
+func Save(messageStore *store.MessageStore, key string, month time.Time) (string, int, error) {
+	book, err := ForMonth(messageStore, key, month)
+	if err != nil || len(book.Photos) == 0 {
+		return "", 0, err
+	}
+	path := Path(key, month)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+
+	// Written next to its final name first, so a crash never leaves half a book behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".photobook-*.pdf")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	count, err := Write(tmp, book)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if count == 0 {
+		return "", 0, nil
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return path, count, nil
+}
@@ -0,0 +1,120 @@
+package photobook
+
+import (
+	"fmt"
+	"io"
+)
+
+// pdfWriter writes a PDF whose pages are each one full-page JPEG image. Pages are written as they are
+// added, so a book of hundreds of photos never has to be held in memory.
+type pdfWriter struct {
+	w       io.Writer
+	offset  int64
+	offsets []int64
+	pages   []int
+	err     error
+}
+
+// A4 in PDF points
+const (
+	pageWidthPt  = 595.28
+	pageHeightPt = 841.89
+)
+
+// Objects 1 and 2 are the catalog and the page tree, written last once all pages are known
+const (
+	catalogObject = 1
+	pagesObject   = 2
+)
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	p := &pdfWriter{w: w, offsets: make([]int64, pagesObject)}
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// object starts object id at the current offset
+func (p *pdfWriter) object(id int) {
+	for len(p.offsets) < id {
+		p.offsets = append(p.offsets, 0)
+	}
+	p.offsets[id-1] = p.offset
+	p.printf("%d 0 obj\n", id)
+}
+
+// addPage adds a page showing a JPEG of the given pixel size, stretched over the whole page
+func (p *pdfWriter) addPage(jpegData []byte, width, height int) {
+	image, content, page := len(p.offsets)+1, len(p.offsets)+2, len(p.offsets)+3
+
+	p.object(image)
+	p.printf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n",
+		width, height, len(jpegData))
+	p.write(jpegData)
+	p.printf("\nendstream\nendobj\n")
+
+	draw := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q\n", pageWidthPt, pageHeightPt)
+	p.object(content)
+	p.printf("<< /Length %d >>\nstream\n%sendstream\nendobj\n", len(draw), draw)
+
+	p.object(page)
+	p.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+		pagesObject, pageWidthPt, pageHeightPt, image, content)
+	p.pages = append(p.pages, page)
+}
+
+// close writes the page tree, catalog, cross-reference table and trailer
+func (p *pdfWriter) close(title string) error {
+	p.object(pagesObject)
+	p.printf("<< /Type /Pages /Count %d /Kids [", len(p.pages))
+	for _, page := range p.pages {
+		p.printf(" %d 0 R", page)
+	}
+	p.printf(" ] >>\nendobj\n")
+
+	p.object(catalogObject)
+	p.printf("<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pagesObject)
+
+	info := len(p.offsets) + 1
+	p.object(info)
+	p.printf("<< /Title %s /Producer (whatsapp-bridge) >>\nendobj\n", pdfString(title))
+
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, offset := range p.offsets {
+		p.printf("%010d 00000 n \n", offset)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, catalogObject, info, xref)
+	return p.err
+}
+
+// pdfString encodes text as a UTF-16 PDF string, so titles in any script show in the reader
+func pdfString(text string) string {
+	s := "<FEFF"
+	for _, r := range text {
+		if r > 0xFFFF {
+			r -= 0x10000
+			s += fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			continue
+		}
+		s += fmt.Sprintf("%04X", r)
+	}
+	return s + ">"
+}
@@ -0,0 +1,305 @@
+// Package photobook lays out a month of a child's photos as a printable PDF photo book.
+package photobook
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/media"
+)
+
+// Pages are A4 rendered at 200 DPI, enough for a printed photo book
+const (
+	pageWidth  = 1654
+	pageHeight = 2339
+	margin     = 120
+	// Space between photos on a page
+	gutter = 60
+	// Photos per day page; a day with more continues on the next page
+	photosPerPage = 4
+	jpegQuality   = 90
+)
+
+var (
+	textColor  = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	mutedColor = color.RGBA{0x88, 0x88, 0x88, 0xff}
+)
+
+// Photo is a photo of the book with the caption it was sent with and who took it
+type Photo struct {
+	Path    string
+	Caption string
+	Sender  string
+	Taken   time.Time
+}
+
+// Book is a photo book of one child over a period
+type Book struct {
+	// The child's name, on the cover
+	Title string
+	// The period covered, e.g. "September 2026"
+	Period string
+	// Oldest first
+	Photos []Photo
+	// Font to write with instead of the built-in Go font, needed for Hebrew names and captions
+	FontPath string
+	// Timezone the photos are grouped into days in
+	Location *time.Location
+	// Language of the dates and labels on the pages
+	Locale i18n.Locale
+}
+
+// layout draws the pages of a book
+type layout struct {
+	font   *opentype.Font
+	locale i18n.Locale
+}
+
+// Write renders book as a PDF: a cover, then the photos of each day with their captions and senders.
+// Photos that can't be read are left out. Returns the number of photos in the book.
+func Write(w io.Writer, book Book) (int, error) {
+	parsed, err := media.LoadFont(book.FontPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load font: %v", err)
+	}
+	l := &layout{font: parsed, locale: book.Locale}
+	pdf := newPDFWriter(w)
+
+	// Photos are decoded once to find the ones that can be used; pages decode them again when drawn,
+	// so only a page's worth is held in memory at a time
+	var photos []Photo
+	var cover image.Image
+	for _, photo := range book.Photos {
+		img, err := loadPhoto(photo.Path, pageWidth-2*margin, pageHeight/2)
+		if err != nil {
+			fmt.Printf("[PHOTOBOOK] Leaving out %s: %v\n", photo.Path, err)
+			continue
+		}
+		if cover == nil {
+			cover = img
+		}
+		photos = append(photos, photo)
+	}
+
+	page, err := l.cover(book, cover, len(photos))
+	if err != nil {
+		return 0, err
+	}
+	if err := addPage(pdf, page); err != nil {
+		return 0, err
+	}
+
+	// Photos are grouped by the day they were taken where the family lives
+	location := book.Location
+	if location == nil {
+		location = time.Local
+	}
+	for start := 0; start < len(photos); {
+		day := photos[start].Taken.In(location).Format("2006-01-02")
+		end := start
+		for end < len(photos) && photos[end].Taken.In(location).Format("2006-01-02") == day {
+			end++
+		}
+		for i := start; i < end; i += photosPerPage {
+			page, err := l.dayPage(photos[start].Taken.In(location), photos[i:min(i+photosPerPage, end)])
+			if err != nil {
+				return 0, err
+			}
+			if err := addPage(pdf, page); err != nil {
+				return 0, err
+			}
+		}
+		start = end
+	}
+	return len(photos), pdf.close(strings.TrimSpace(book.Title + " " + book.Period))
+}
+
+// addPage encodes a rendered page and adds it to the PDF
+func addPage(pdf *pdfWriter, page *image.RGBA) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, page, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return fmt.Errorf("failed to encode page: %v", err)
+	}
+	pdf.addPage(buf.Bytes(), page.Bounds().Dx(), page.Bounds().Dy())
+	return pdf.err
+}
+
+// loadPhoto reads a photo, scaled to fill width x height as far as its aspect ratio allows. Small
+// photos are scaled up too, so every photo of a page gets the same room.
+func loadPhoto(path string, width, height int) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	if bounds.Dx()*height > bounds.Dy()*width {
+		return imaging.Resize(img, width, 0, imaging.Lanczos), nil
+	}
+	return imaging.Resize(img, 0, height, imaging.Lanczos), nil
+}
+
+// newPage returns a blank white page
+func newPage() *image.RGBA {
+	page := image.NewRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+	return page
+}
+
+// face returns the book's font at size pixels
+func (l *layout) face(size float64) (font.Face, error) {
+	face, err := opentype.NewFace(l.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+	return face, nil
+}
+
+// cover draws the title page: the child's name, the period, the first photo and the photo count
+func (l *layout) cover(book Book, photo image.Image, count int) (*image.RGBA, error) {
+	page := newPage()
+	title, err := l.face(120)
+	if err != nil {
+		return nil, err
+	}
+	defer title.Close()
+	subtitle, err := l.face(60)
+	if err != nil {
+		return nil, err
+	}
+	defer subtitle.Close()
+
+	y := pageHeight / 6
+	y = drawCentered(page, title, textColor, book.Title, y)
+	y = drawCentered(page, subtitle, mutedColor, book.Period, y+30)
+	if photo != nil {
+		y += 120
+		x := (pageWidth - photo.Bounds().Dx()) / 2
+		draw.Draw(page, image.Rect(x, y, x+photo.Bounds().Dx(), y+photo.Bounds().Dy()), photo, photo.Bounds().Min, draw.Src)
+	}
+	photos := l.locale.T("photobook.photos", count)
+	if count == 1 {
+		photos = l.locale.T("photobook.photo")
+	}
+	drawCentered(page, subtitle, mutedColor, photos, pageHeight-margin-lineHeight(subtitle))
+	return page, nil
+}
+
+// dayPage draws up to photosPerPage photos of one day under the date, each with its caption and sender
+func (l *layout) dayPage(day time.Time, photos []Photo) (*image.RGBA, error) {
+	page := newPage()
+	heading, err := l.face(64)
+	if err != nil {
+		return nil, err
+	}
+	defer heading.Close()
+	small, err := l.face(36)
+	if err != nil {
+		return nil, err
+	}
+	defer small.Close()
+
+	top := drawCentered(page, heading, textColor, l.locale.Day(day), margin) + gutter
+
+	// One photo fills the page, two are stacked, three or four share a grid
+	columns, rows := 1, len(photos)
+	if len(photos) > 2 {
+		columns, rows = 2, 2
+	}
+	cellWidth := (pageWidth - 2*margin - (columns-1)*gutter) / columns
+	cellHeight := (pageHeight - margin - top - (rows-1)*gutter) / rows
+	// Room under each photo for two lines of caption and the sender
+	textHeight := 3*lineHeight(small) + 20
+
+	for i, photo := range photos {
+		x := margin + (i%columns)*(cellWidth+gutter)
+		y := top + (i/columns)*(cellHeight+gutter)
+		img, err := loadPhoto(photo.Path, cellWidth, cellHeight-textHeight)
+		if err != nil {
+			fmt.Printf("[PHOTOBOOK] Leaving out %s: %v\n", photo.Path, err)
+			continue
+		}
+		px := x + (cellWidth-img.Bounds().Dx())/2
+		draw.Draw(page, image.Rect(px, y, px+img.Bounds().Dx(), y+img.Bounds().Dy()), img, img.Bounds().Min, draw.Src)
+
+		textY := y + img.Bounds().Dy() + 20
+		for _, line := range wrap(small, photo.Caption, cellWidth, 2) {
+			textY = drawLine(page, small, textColor, line, x+(cellWidth-measure(small, line))/2, textY)
+		}
+		if photo.Sender != "" {
+			credit := l.locale.T("photobook.credit", photo.Sender)
+			drawLine(page, small, mutedColor, credit, x+(cellWidth-measure(small, credit))/2, textY)
+		}
+	}
+	return page, nil
+}
+
+// lineHeight is the height of a line of text in face
+func lineHeight(face font.Face) int {
+	metrics := face.Metrics()
+	return (metrics.Ascent + metrics.Descent).Ceil()
+}
+
+// drawLine writes text with its top at y and returns the y of the next line. Hebrew is put in visual
+// order, since the drawer lays glyphs out left to right.
+func drawLine(page draw.Image, face font.Face, c color.Color, text string, x, y int) int {
+	drawer := &font.Drawer{Dst: page, Src: image.NewUniform(c), Face: face}
+	drawer.Dot = fixed.P(x, y+face.Metrics().Ascent.Ceil())
+	drawer.DrawString(i18n.Visual(text))
+	return y + lineHeight(face)
+}
+
+// measure is the width of text as drawLine draws it, without its invisible direction characters
+func measure(face font.Face, text string) int {
+	return font.MeasureString(face, i18n.Visual(text)).Ceil()
+}
+
+// drawCentered writes text centered on the page with its top at y and returns the y below it
+func drawCentered(page draw.Image, face font.Face, c color.Color, text string, y int) int {
+	return drawLine(page, face, c, text, (pageWidth-measure(face, text))/2, y)
+}
+
+// wrap breaks text into lines no wider than width, at most maxLines of them; the last line of text
+// that doesn't fit ends in an ellipsis
+func wrap(face font.Face, text string, width, maxLines int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if line == "" || font.MeasureString(face, candidate).Ceil() <= width {
+			line = candidate
+			continue
+		}
+		lines = append(lines, line)
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) <= maxLines {
+		return lines
+	}
+	last := []rune(lines[maxLines-1] + " " + lines[maxLines])
+	for len(last) > 0 && font.MeasureString(face, string(last)+"…").Ceil() > width {
+		last = last[:len(last)-1]
+	}
+	lines[maxLines-1] = strings.TrimSpace(string(last)) + "…"
+	return lines[:maxLines]
+}
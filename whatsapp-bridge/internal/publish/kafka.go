@@ -0,0 +1,79 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// kafkaSink produces events through the Kafka REST Proxy (v2 API), so no Kafka client library is
+// needed. Events are keyed by chat, which keeps each chat's events in order on one partition.
+type kafkaSink struct {
+	config config.PublisherConfig
+	client *http.Client
+}
+
+func newKafkaSink(cfg config.PublisherConfig) *kafkaSink {
+	return &kafkaSink{config: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type kafkaRecord struct {
+	Key   string  `json:"key"`
+	Value payload `json:"value"`
+}
+
+func (k *kafkaSink) send(events []store.Event) error {
+	records := make([]kafkaRecord, 0, len(events))
+	for _, event := range payloads(events) {
+		records = append(records, kafkaRecord{Key: event.ChatJID, Value: event})
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(k.config.URL, "/") + "/topics/" + url.PathEscape(k.config.Topic)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.config.Username != "" {
+		req.SetBasicAuth(k.config.Username, k.config.Password)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("REST proxy returned %s", resp.Status)
+	}
+
+	// The proxy answers 200 even when single records failed
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to read REST proxy response: %v", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("REST proxy rejected a record: %s", offset.Error)
+		}
+	}
+	return nil
+}
+
+func (k *kafkaSink) close() {}
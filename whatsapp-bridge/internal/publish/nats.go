@@ -0,0 +1,146 @@
+package publish
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/version"
+)
+
+// natsTimeout bounds connecting and waiting for the server to acknowledge a batch
+const natsTimeout = 10 * time.Second
+
+// natsSink publishes events with the NATS client protocol, which is plain text over TCP. After each
+// batch it sends a PING and waits for the PONG, so a batch counts as delivered only once the server has
+// processed it.
+type natsSink struct {
+	config config.PublisherConfig
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// natsInfo is the part of the server's INFO message the bridge needs
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// connect dials the server, upgrades to TLS if required and authenticates
+func (n *natsSink) connect() error {
+	u, err := url.Parse(n.config.URL)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, natsTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	reader := bufio.NewReader(conn)
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to read server INFO: %v", err)
+	}
+	var info natsInfo
+	if !strings.HasPrefix(line, "INFO ") || json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info) != nil {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting from server: %q", strings.TrimSpace(line))
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "whatsapp-bridge", "lang": "go", "version": version.Version}
+	if n.config.Username != "" {
+		options["user"], options["pass"] = n.config.Username, n.config.Password
+	}
+	if n.config.Token != "" {
+		options["auth_token"] = n.config.Token
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	n.conn, n.reader = conn, reader
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		n.close()
+		return err
+	}
+	if err := n.awaitPong(); err != nil {
+		n.close()
+		return fmt.Errorf("server refused the connection: %v", err)
+	}
+	return nil
+}
+
+// awaitPong reads until the server answers a PING, failing on protocol errors
+func (n *natsSink) awaitPong() error {
+	for {
+		line, err := n.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (n *natsSink) send(events []store.Event) error {
+	if n.conn == nil {
+		if err := n.connect(); err != nil {
+			return err
+		}
+	}
+	n.conn.SetDeadline(time.Now().Add(natsTimeout))
+
+	writer := bufio.NewWriter(n.conn)
+	for _, event := range payloads(events) {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "PUB %s %d\r\n", n.config.Topic, len(data))
+		writer.Write(data)
+		writer.WriteString("\r\n")
+	}
+	writer.WriteString("PING\r\n")
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return n.awaitPong()
+}
+
+func (n *natsSink) close() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn, n.reader = nil, nil
+	}
+}
@@ -0,0 +1,23 @@
+package publish
+
+import (
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/version"
+)
+
+// payload is an event as published: the change log entry and the build of the bridge that sent it,
+// so consumers can detect versions they don't support
+type payload struct {
+	store.Event
+	Bridge version.Info `json:"bridge"`
+}
+
+// payloads wraps events for publishing
+func payloads(events []store.Event) []payload {
+	build := version.Get()
+	wrapped := make([]payload, 0, len(events))
+	for _, event := range events {
+		wrapped = append(wrapped, payload{Event: event, Bridge: build})
+	}
+	return wrapped
+}
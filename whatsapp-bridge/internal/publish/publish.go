@@ -0,0 +1,93 @@
+// Package publish forwards the change log of stored messages to an event bus (Kafka or NATS), for
+// automation that already listens there.
+package publish
+
+import (
+	"fmt"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+const (
+	// pollInterval is how often the events table is checked for new events
+	pollInterval = time.Second
+	// batchSize is the number of events sent at once
+	batchSize = 100
+	// maxRetryDelay caps the wait between attempts while the event bus is unreachable
+	maxRetryDelay = time.Minute
+)
+
+// sink delivers events to one event bus. send returns only once the bus has accepted all of them.
+type sink interface {
+	send(events []store.Event) error
+	close()
+}
+
+// publisher follows the events table and delivers every event at least once, resuming after the last
+// delivered seq when the bridge restarts
+type publisher struct {
+	name         string
+	sink         sink
+	messageStore *store.MessageStore
+}
+
+// Start publishes stored-message events in the background if a publisher is configured
+func Start(cfg config.PublisherConfig, messageStore *store.MessageStore) {
+	var s sink
+	switch cfg.Type {
+	case config.PublisherKafka:
+		s = newKafkaSink(cfg)
+	case config.PublisherNATS:
+		s = &natsSink{config: cfg}
+	default:
+		return
+	}
+	p := &publisher{name: cfg.Type + ":" + cfg.Topic, sink: s, messageStore: messageStore}
+	go p.run()
+	fmt.Printf("[PUBLISH] Publishing message events to %s %q at %s\n", cfg.Type, cfg.Topic, cfg.URL)
+}
+
+// run delivers new events until the process exits
+func (p *publisher) run() {
+	seq, err := p.messageStore.GetPublishedSeq(p.name)
+	if err != nil {
+		fmt.Printf("[PUBLISH] Failed to read the last published event, starting from the beginning: %v\n", err)
+	}
+
+	retryDelay := pollInterval
+	for {
+		next, err := p.publishPending(seq)
+		seq = next
+		if err != nil {
+			fmt.Printf("[PUBLISH] Failed to publish events after seq %d, retrying in %s: %v\n", seq, retryDelay, err)
+			p.sink.close()
+			time.Sleep(retryDelay)
+			retryDelay = min(retryDelay*2, maxRetryDelay)
+			continue
+		}
+		retryDelay = pollInterval
+		time.Sleep(pollInterval)
+	}
+}
+
+// publishPending sends all events after seq in batches and returns the seq of the last delivered one
+func (p *publisher) publishPending(seq int64) (int64, error) {
+	for {
+		events, err := p.messageStore.GetEvents(seq, "", batchSize)
+		if err != nil {
+			return seq, err
+		}
+		if len(events) == 0 {
+			return seq, nil
+		}
+		if err := p.sink.send(events); err != nil {
+			return seq, err
+		}
+		seq = events[len(events)-1].Seq
+		if err := p.messageStore.SetPublishedSeq(p.name, seq); err != nil {
+			return seq, err
+		}
+	}
+}
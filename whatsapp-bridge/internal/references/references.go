@@ -0,0 +1,154 @@
+// Package references stores the reference photos the face filter service matches forwarded photos
+// against, uploaded per destination through the API.
+package references
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+)
+
+// jpegQuality is the quality reference photos are stored with
+const jpegQuality = 92
+
+// ErrInvalidID is returned for IDs that aren't the hex SHA-256 hash of a photo
+var ErrInvalidID = errors.New("invalid reference photo ID")
+
+// Photo is a stored reference photo of a destination
+type Photo struct {
+	Destination string `json:"destination"`
+	// The SHA-256 hash of the uploaded file, so the same photo is only stored once
+	ID      string    `json:"id"`
+	Size    int64     `json:"size"`
+	AddedAt time.Time `json:"added_at"`
+	// The photo itself, served by the API
+	URL string `json:"url,omitempty"`
+}
+
+// Dir is where reference photos are stored, one subdirectory per destination
+func Dir() string {
+	return filepath.Join(config.Current().DataDir, "reference_photos")
+}
+
+// Path returns where the reference photo id of destination is stored
+func Path(destination, id string) (string, error) {
+	if len(id) != sha256.Size*2 {
+		return "", ErrInvalidID
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", ErrInvalidID
+	}
+	return filepath.Join(Dir(), destination, id+".jpg"), nil
+}
+
+// Add stores an uploaded reference photo of destination. Photos are stored as upright JPEGs, since the
+// face filter doesn't apply EXIF orientation. created is false if the photo was already stored.
+func Add(destination string, data []byte) (photo Photo, created bool, err error) {
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	path, err := Path(destination, id)
+	if err != nil {
+		return Photo{}, false, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		return Photo{Destination: destination, ID: id, Size: info.Size(), AddedAt: info.ModTime()}, false, nil
+	}
+
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		return Photo{}, false, err
+	}
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return Photo{}, false, fmt.Errorf("Error encoding JPEG: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return Photo{}, false, fmt.Errorf("failed to create reference photo directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".reference-*")
+	if err != nil {
+		return Photo{}, false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return Photo{}, false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Photo{}, false, err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return Photo{}, false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Photo{}, false, err
+	}
+	return Photo{Destination: destination, ID: id, Size: int64(out.Len()), AddedAt: time.Now()}, true, nil
+}
+
+// List returns the reference photos of a destination, or of all destinations for an empty one,
+// oldest first
+func List(destination string) ([]Photo, error) {
+	destinations := []string{destination}
+	if destination == "" {
+		entries, err := os.ReadDir(Dir())
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		destinations = nil
+		for _, entry := range entries {
+			if entry.IsDir() {
+				destinations = append(destinations, entry.Name())
+			}
+		}
+	}
+
+	photos := []Photo{}
+	for _, dest := range destinations {
+		entries, err := os.ReadDir(filepath.Join(Dir(), dest))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			id := entry.Name()[:len(entry.Name())-len(filepath.Ext(entry.Name()))]
+			if _, err := Path(dest, id); err != nil || filepath.Ext(entry.Name()) != ".jpg" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			photos = append(photos, Photo{Destination: dest, ID: id, Size: info.Size(), AddedAt: info.ModTime()})
+		}
+	}
+	sort.SliceStable(photos, func(i, j int) bool {
+		if !photos[i].AddedAt.Equal(photos[j].AddedAt) {
+			return photos[i].AddedAt.Before(photos[j].AddedAt)
+		}
+		return photos[i].ID < photos[j].ID
+	})
+	return photos, nil
+}
+
+// Remove deletes a reference photo. Returns an error wrapping os.ErrNotExist if there is no such photo.
+func Remove(destination, id string) error {
+	path, err := Path(destination, id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,63 @@
+// Package reminders runs the schedules that keep reminding about something until it is handled, such
+// as payment requests, forms and forwards nobody acknowledged.
+package reminders
+
+import (
+	"context"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+)
+
+// checkInterval is how often due reminders are looked for
+const checkInterval = 10 * time.Minute
+
+// Reminders are only sent during the day, from fromHour to toHour in the configured timezone
+const (
+	fromHour = 8
+	toHour   = 21
+)
+
+// maxQuoteLength is how much of a message a reminder quotes
+const maxQuoteLength = 300
+
+// Start calls send in the background with the local time on every check during the day, until ctx is
+// done. A check that panics is skipped rather than ending the schedule; name tells which in the crash
+// report.
+func Start(ctx context.Context, name string, send func(ctx context.Context, now time.Time)) {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			check(ctx, name, send, time.Now().In(config.Current().Location()))
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// check calls send when now is during the day
+func check(ctx context.Context, name string, send func(ctx context.Context, now time.Time), now time.Time) {
+	defer crash.Recover(name)
+	if now.Hour() < fromHour || now.Hour() >= toHour {
+		return
+	}
+	send(ctx, now)
+}
+
+// SendTimeout bounds sending one reminder
+func SendTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Minute)
+}
+
+// Quote shortens text to what a reminder quotes of it
+func Quote(text string) string {
+	if runes := []rune(text); len(runes) > maxQuoteLength {
+		return string(runes[:maxQuoteLength]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,101 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/photobook"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// FileSender sends a file as a document with a caption to a chat
+type FileSender func(ctx context.Context, chatJID, path, caption string) error
+
+// photoBookSetting remembers the last month photo books were made for, so a restart doesn't make them twice.
+// Until all are done, photoBookSetting:<destination> remembers the destinations whose book was made and
+// sent, so a retry doesn't send them twice.
+const photoBookSetting = "photo_book_month"
+
+// maxSharedBookSize is the largest document WhatsApp takes; a larger book is only saved
+const maxSharedBookSize = 100 << 20
+
+// makePhotoBooks saves last month's photo book of every destination once the new month has started,
+// on the same schedule as the monthly report, and shares them into the destinations' chats if configured.
+// A book that fails is tried again at the next check.
+func makePhotoBooks(ctx context.Context, messageStore *store.MessageStore, sendFile FileSender, now time.Time) {
+	cfg := config.Current()
+	if !cfg.PhotoBook.Enabled || now.Day() > monthlyReportLastDay || (now.Day() == 1 && now.Hour() < monthlyReportHour) {
+		return
+	}
+	month := photobook.MonthStart(now).AddDate(0, -1, 0)
+	if last, err := messageStore.Setting(photoBookSetting); err != nil || last >= month.Format("2006-01") {
+		return
+	}
+
+	keys := make([]string, 0, len(cfg.Destinations))
+	for key := range cfg.Destinations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	done := true
+	for _, key := range keys {
+		if last, err := messageStore.Setting(photoBookSetting + ":" + key); err != nil || last >= month.Format("2006-01") {
+			continue
+		}
+		if !makePhotoBook(ctx, messageStore, sendFile, key, cfg.Destinations[key], month) {
+			done = false
+			continue
+		}
+		if err := messageStore.SetSetting(photoBookSetting+":"+key, month.Format("2006-01")); err != nil {
+			fmt.Printf("[REPORT] Failed to record the photo book of %s: %v\n", key, err)
+		}
+	}
+	if !done {
+		return
+	}
+	if err := messageStore.SetSetting(photoBookSetting, month.Format("2006-01")); err != nil {
+		fmt.Printf("[REPORT] Failed to record the photo books: %v\n", err)
+	}
+}
+
+// makePhotoBook saves the photo book of one destination for month and shares it if configured. It
+// reports false if the book should be tried again.
+func makePhotoBook(ctx context.Context, messageStore *store.MessageStore, sendFile FileSender, key string, dest config.DestinationConfig, month time.Time) bool {
+	path, count, err := photobook.Save(messageStore, key, month)
+	if err != nil {
+		fmt.Printf("[REPORT] Failed to make the photo book of %s: %v\n", key, err)
+		return false
+	}
+	if count == 0 {
+		fmt.Printf("[REPORT] No photos of %s in %s, no photo book\n", key, month.Format("January 2006"))
+		return true
+	}
+	fmt.Printf("[REPORT] Saved the photo book of %s with %d photos to %s\n", key, count, path)
+
+	if !config.Current().PhotoBook.Share || dest.Group == "" {
+		return true
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxSharedBookSize {
+		fmt.Printf("[REPORT] The photo book of %s has %d MB, too large to send\n", key, info.Size()>>20)
+		return true
+	}
+	if routing.IsDryRunSend(dest.Group, false) {
+		fmt.Printf("[DRY-RUN] Would send the photo book %s to %s\n", path, dest.Group)
+		return true
+	}
+	caption := i18n.T("photobook.caption", displayName(key, dest), i18n.Current().Month(month), count)
+	sendCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	err = sendFile(sendCtx, dest.Group, path, caption)
+	cancel()
+	if err != nil {
+		fmt.Printf("[REPORT] Failed to send the photo book of %s: %v\n", key, err)
+		return false
+	}
+	return true
+}
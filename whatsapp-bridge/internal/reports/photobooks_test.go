@@ -0,0 +1,90 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// TestPhotoBookRetriedAfterFailedSend checks a photo book that couldn't be sent leaves the month open,
+// is sent at the next check, and isn't sent again once the month is recorded
+func TestPhotoBookRetriedAfterFailedSend(t *testing.T) {
+	dir := t.TempDir()
+	storeDir := store.Dir
+	t.Cleanup(func() {
+		store.Dir = storeDir
+		config.Set(config.Config{})
+	})
+	store.Dir = filepath.Join(dir, "store")
+
+	cfg, err := config.Parse([]byte(`{
+		"destinations": {"noa": {"group": "120363000000000002@g.us", "name": "Noa"}},
+		"photo_book": {"enabled": true, "share": true, "dir": "` + filepath.Join(dir, "photobooks") + `"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Set(cfg)
+
+	messageStore, err := store.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer messageStore.Close()
+
+	// One photo forwarded to Noa in September
+	photo := filepath.Join(dir, "photo.jpg")
+	file, err := os.Create(photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	location := cfg.Location()
+	err = messageStore.StoreIncoming(store.IncomingMessage{
+		ID: "photo1", ChatJID: "120363000000000001@g.us", Sender: "972500000001", SenderName: "Dana",
+		Timestamp: time.Date(2026, 9, 15, 10, 0, 0, 0, location), ImageURL: photo, MediaType: "image",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = messageStore.RecordForward(store.Forward{MessageID: "photo1", ChatJID: "120363000000000001@g.us",
+		Destination: "120363000000000002@g.us", MediaPath: photo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 1, 10, 0, 0, 0, location)
+	var sent int
+	failing := func(ctx context.Context, chatJID, path, caption string) error {
+		return errors.New("upload failed")
+	}
+	sending := func(ctx context.Context, chatJID, path, caption string) error {
+		sent++
+		return nil
+	}
+
+	makePhotoBooks(context.Background(), messageStore, failing, now)
+	if month, _ := messageStore.Setting(photoBookSetting); month != "" {
+		t.Fatalf("month recorded as %q after a failed send", month)
+	}
+
+	makePhotoBooks(context.Background(), messageStore, sending, now.Add(checkInterval))
+	makePhotoBooks(context.Background(), messageStore, sending, now.Add(2*checkInterval))
+	if sent != 1 {
+		t.Errorf("photo book sent %d times after the failed send, want 1", sent)
+	}
+	if month, _ := messageStore.Setting(photoBookSetting); month != "2026-09" {
+		t.Errorf("month recorded as %q, want 2026-09", month)
+	}
+}
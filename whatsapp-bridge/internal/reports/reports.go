@@ -0,0 +1,154 @@
+// Package reports posts summaries of the stored messages to WhatsApp chats on a schedule.
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/store"
+)
+
+// checkInterval is how often the schedule is checked
+const checkInterval = 10 * time.Minute
+
+// The monthly report is sent from 9:00 on the first of the month; a bridge that was down then catches up
+// during the first week, later the month is skipped
+const (
+	monthlyReportHour    = 9
+	monthlyReportLastDay = 7
+)
+
+// monthlyReportSetting remembers the last month reported, so a restart doesn't post it twice
+const monthlyReportSetting = "monthly_report_month"
+
+// Start checks the report schedule in the background until ctx is done, sending due reports with send
+// and photo books with sendFile
+func Start(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, sendFile FileSender) {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			runSchedule(ctx, messageStore, send, sendFile, time.Now().In(config.Current().Location()))
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// runSchedule sends the reports due at now. A report that panics is skipped until the next check rather
+// than ending the schedule.
+func runSchedule(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, sendFile FileSender, now time.Time) {
+	defer crash.Recover("the report schedule")
+	sendMonthlyReport(ctx, messageStore, send, now)
+	checkWeeklyPhotos(ctx, messageStore, send, now)
+	makePhotoBooks(ctx, messageStore, sendFile, now)
+}
+
+// sendMonthlyReport posts the report of the month before now once the new month has started
+func sendMonthlyReport(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, now time.Time) {
+	cfg := config.Current().Stats.MonthlyReport
+	if cfg.ChatJID == "" || now.Day() > monthlyReportLastDay || (now.Day() == 1 && now.Hour() < monthlyReportHour) {
+		return
+	}
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+	month := start.Format("2006-01")
+	if last, err := messageStore.Setting(monthlyReportSetting); err != nil || last >= month {
+		return
+	}
+
+	chats := cfg.Chats
+	if len(chats) == 0 {
+		chats = config.Current().InputGroups
+	}
+	var reports []string
+	for _, chatJID := range chats {
+		stats, err := messageStore.GetChatStats(store.ExportFilter{ChatJID: chatJID, From: start, To: start.AddDate(0, 1, 0)})
+		if err != nil {
+			fmt.Printf("[REPORT] Failed to get stats of %s: %v\n", chatJID, err)
+			return
+		}
+		name := messageStore.ChatName(chatJID)
+		if name == "" {
+			name = chatJID
+		}
+		reports = append(reports, FormatReport(name, i18n.Current().Month(start), stats))
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if err := send(sendCtx, cfg.ChatJID, strings.Join(reports, "\n\n")); err != nil {
+		fmt.Printf("[REPORT] Failed to send the monthly report: %v\n", err)
+		return
+	}
+	fmt.Printf("[REPORT] Sent the report of %s to %s\n", month, cfg.ChatJID)
+	if err := messageStore.SetSetting(monthlyReportSetting, month); err != nil {
+		fmt.Printf("[REPORT] Failed to record the monthly report: %v\n", err)
+	}
+}
+
+// reportTopSenders is how many of the most active senders a report lists
+const reportTopSenders = 5
+
+// FormatReport renders the activity of a chat over period as a WhatsApp message in the configured locale
+func FormatReport(chatName, period string, stats *store.ChatStats) string {
+	var b strings.Builder
+	b.WriteString(i18n.T("report.heading", chatName, period) + "\n")
+	b.WriteString(i18n.T("report.totals", stats.Messages, stats.Media) + "\n")
+	if stats.Messages == 0 {
+		return strings.TrimSpace(b.String())
+	}
+
+	b.WriteString("\n" + i18n.T("report.most_active") + "\n")
+	for i, sender := range stats.Senders {
+		if i == reportTopSenders || sender.Messages == 0 {
+			break
+		}
+		name := sender.SenderName
+		if name == "" {
+			name = "+" + sender.Sender
+		}
+		b.WriteString(i18n.T("report.sender", i+1, name, sender.Messages, sender.Media) + "\n")
+	}
+
+	b.WriteString("\n" + i18n.T("report.busiest_hours", strings.Join(busiestHours(stats.Hours, 3), ", ")) + "\n")
+	if len(stats.Reactions) > 0 {
+		var top []string
+		for i, reaction := range stats.Reactions {
+			if i == 5 {
+				break
+			}
+			top = append(top, fmt.Sprintf("%s %d", reaction.Emoji, reaction.Count))
+		}
+		b.WriteString(i18n.T("report.top_reactions", strings.Join(top, "  ")) + "\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// busiestHours returns up to n hours of the day with the most messages, busiest first, as "08:00"
+func busiestHours(hours [24]int, n int) []string {
+	var busiest []string
+	used := make(map[int]bool)
+	for len(busiest) < n {
+		best := -1
+		for hour, count := range hours {
+			if count > 0 && !used[hour] && (best < 0 || count > hours[best]) {
+				best = hour
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		busiest = append(busiest, fmt.Sprintf("%02d:00", best))
+	}
+	return busiest
+}
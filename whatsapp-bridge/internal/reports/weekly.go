@@ -0,0 +1,98 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/store"
+)
+
+// weeklyCheckHour is the hour of the check day from which the weekly photo counts are checked
+const weeklyCheckHour = 12
+
+// weeklyCheckSetting remembers the day of the last check, so a restart doesn't check twice
+const weeklyCheckSetting = "weekly_photos_checked"
+
+// checkWeeklyPhotos compares the photos forwarded to each destination over the past week with its
+// weekly_photos minimum, once on the configured day, and tells the destination's chat about shortfalls
+func checkWeeklyPhotos(ctx context.Context, messageStore *store.MessageStore, send notify.Sender, now time.Time) {
+	cfg := config.Current()
+	if config.Weekdays[now.Weekday()] != strings.ToLower(cfg.Stats.WeeklyCheckDay) || now.Hour() < weeklyCheckHour {
+		return
+	}
+	day := now.Format("2006-01-02")
+	if last, err := messageStore.Setting(weeklyCheckSetting); err != nil || last == day {
+		return
+	}
+
+	// Destinations in a stable order, so shortfalls are reported the same way every week
+	names := make([]string, 0, len(cfg.Destinations))
+	for name := range cfg.Destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	since := now.AddDate(0, 0, -7)
+	for _, name := range names {
+		dest := cfg.Destinations[name]
+		if dest.WeeklyPhotos.Minimum <= 0 {
+			continue
+		}
+		count, err := messageStore.CountForwardedPhotos(dest.Group, since)
+		if err != nil {
+			fmt.Printf("[REPORT] Failed to count the photos forwarded to %s: %v\n", name, err)
+			return
+		}
+		if count >= dest.WeeklyPhotos.Minimum {
+			fmt.Printf("[REPORT] %d photos of %s this week, expected at least %d\n", count, displayName(name, dest), dest.WeeklyPhotos.Minimum)
+			continue
+		}
+
+		text := WeeklyShortfall(displayName(name, dest), count, dest.WeeklyPhotos.Minimum)
+		fmt.Printf("[REPORT] %s\n", text)
+		chatJID := dest.WeeklyPhotos.ChatJID
+		if chatJID == "" {
+			chatJID = cfg.Alerts.ChatJID
+		}
+		if chatJID == "" {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		err = send(sendCtx, chatJID, "📷 "+text)
+		cancel()
+		if err != nil {
+			fmt.Printf("[REPORT] Failed to send the weekly photo count of %s: %v\n", name, err)
+		}
+	}
+	if err := messageStore.SetSetting(weeklyCheckSetting, day); err != nil {
+		fmt.Printf("[REPORT] Failed to record the weekly photo check: %v\n", err)
+	}
+}
+
+// displayName is the child's name of a destination, or its key without one
+func displayName(key string, dest config.DestinationConfig) string {
+	if dest.Name != "" {
+		return dest.Name
+	}
+	return key
+}
+
+// WeeklyShortfall is the message sent when fewer photos of a child were forwarded than expected, in the
+// configured locale
+func WeeklyShortfall(name string, count, minimum int) string {
+	var found string
+	switch count {
+	case 0:
+		found = i18n.T("weekly.none", name)
+	case 1:
+		found = i18n.T("weekly.one", name)
+	default:
+		found = i18n.T("weekly.other", count, name)
+	}
+	return i18n.T("weekly.shortfall", found, minimum)
+}
@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// DryRunMode makes /api/send record sends instead of performing them (set with -dry-run)
+var DryRunMode bool
+
+// IsDryRunSend reports whether a send must only be recorded: globally with -dry-run, when the request
+// asks for it, or per destination with "dry_run" in its config
+func IsDryRunSend(destination string, requested bool) bool {
+	if DryRunMode || requested {
+		return true
+	}
+	dest, ok := config.DestinationFor(destination)
+	return ok && dest.DryRun
+}
+
+// ForwardCaption is the text recorded with a forward: the caption of media, or the message itself
+func ForwardCaption(caption, message string) string {
+	if caption != "" {
+		return caption
+	}
+	return message
+}
+
+// FindMessageByMedia returns the stored message a media file (or a replayed copy of it) belongs to
+func FindMessageByMedia(messageStore *store.MessageStore, path string) (string, string, bool) {
+	if replayed, ok := replayedMessage(path); ok {
+		return replayed.messageID, replayed.chatJID, true
+	}
+	return messageStore.FindMessageByMedia(path)
+}
+
+// forwardStore returns the message store whose forward ledger sends to a destination go in: that of the
+// destination's pipeline
+func forwardStore(messageStore *store.MessageStore, destination string) (*store.MessageStore, error) {
+	return PipelineStore(messageStore, config.Current().DestinationPipeline(destination))
+}
+
+// RecordForward adds a send to the forward ledger, linking it to the message its media came from
+func RecordForward(messageStore *store.MessageStore, f store.Forward) error {
+	messageStore, err := forwardStore(messageStore, f.Destination)
+	if err != nil {
+		return err
+	}
+	f.MessageID, f.ChatJID, _ = FindMessageByMedia(messageStore, f.MediaPath)
+	return messageStore.RecordForward(f)
+}
+
+// AlreadyForwarded checks the forward ledger for an earlier real send of the same photo to the same
+// destination with the same caption. Photos replayed with force are never considered duplicates.
+func AlreadyForwarded(messageStore *store.MessageStore, destination, mediaPath, caption string) (bool, error) {
+	if mediaPath == "" {
+		return false, nil
+	}
+	if replayed, ok := replayedMessage(mediaPath); ok && replayed.force {
+		return false, nil
+	}
+	messageStore, err := forwardStore(messageStore, destination)
+	if err != nil {
+		return false, err
+	}
+	messageID, chatJID, ok := FindMessageByMedia(messageStore, mediaPath)
+	if !ok {
+		return false, nil
+	}
+	return messageStore.HasForwarded(messageID, chatJID, destination, caption)
+}
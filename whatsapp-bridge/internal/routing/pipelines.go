@@ -0,0 +1,48 @@
+package routing
+
+import (
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/store"
+)
+
+// PipelineStore returns the message store a pipeline's messages are kept in: a database of its own for
+// isolated pipelines, the shared one otherwise
+func PipelineStore(shared *store.MessageStore, pipeline string) (*store.MessageStore, error) {
+	if settings, ok := config.Current().Pipelines[pipeline]; ok && settings.Isolated {
+		return store.ForPipeline(pipeline)
+	}
+	return shared, nil
+}
+
+// Stores returns every message store: the shared one, then those of the isolated pipelines by name
+func Stores(shared *store.MessageStore) ([]*store.MessageStore, error) {
+	stores := []*store.MessageStore{shared}
+	cfg := config.Current()
+	for _, pipeline := range cfg.PipelineNames() {
+		if !cfg.Pipelines[pipeline].Isolated {
+			continue
+		}
+		pipelineStore, err := store.ForPipeline(pipeline)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, pipelineStore)
+	}
+	return stores, nil
+}
+
+// ChatStore returns the message store the messages of a chat are kept in
+func ChatStore(shared *store.MessageStore, chatJID string) (*store.MessageStore, error) {
+	return PipelineStore(shared, config.Current().PipelineOf(chatJID))
+}
+
+// MediaRoot returns the directory the media of a chat is stored under: the directory of its pipeline
+// for isolated pipelines, the media directory otherwise
+func MediaRoot(chatJID string) string {
+	cfg := config.Current()
+	if pipeline := cfg.PipelineOf(chatJID); pipeline != "" && cfg.Pipelines[pipeline].Isolated {
+		return media.QueueDir(pipeline)
+	}
+	return media.Dir
+}
@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"whatsapp-client/internal/config"
+)
+
+type compiledRedactionRule struct {
+	name        string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// redactionRules are the compiled rules of the current configuration, replaced as a whole on reload
+var redactionRules atomic.Pointer[[]compiledRedactionRule]
+
+// CompileRedactionRules validates and compiles the configured redaction patterns
+func CompileRedactionRules(rules []config.RedactionRule) error {
+	compiled := make([]compiledRedactionRule, 0, len(rules))
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid redaction rule %d (%s): %v", i, rule.Name, err)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = "[redacted]"
+		}
+		compiled = append(compiled, compiledRedactionRule{
+			name:        rule.Name,
+			pattern:     pattern,
+			replacement: replacement,
+		})
+	}
+	redactionRules.Store(&compiled)
+	return nil
+}
+
+// isMediaOnlyGroup checks if text content from the given chat must not be stored
+func isMediaOnlyGroup(chatJID string) bool {
+	for _, groupJID := range config.Current().Privacy.MediaOnlyGroups {
+		if chatJID == groupJID {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchivesRawMessages reports whether the raw protobuf of messages from chatJID is archived. Media-only
+// groups are left out, as their raw messages would keep the text the bridge must not store.
+func ArchivesRawMessages(chatJID string) bool {
+	return config.Current().Archive.RawMessages && !isMediaOnlyGroup(chatJID)
+}
+
+// RedactContent applies the privacy settings to message content before it is written to SQLite
+func RedactContent(chatJID, content string) string {
+	if content == "" {
+		return content
+	}
+	if isMediaOnlyGroup(chatJID) {
+		return ""
+	}
+	if rules := redactionRules.Load(); rules != nil {
+		for _, rule := range *rules {
+			content = rule.pattern.ReplaceAllString(content, rule.replacement)
+		}
+	}
+	return content
+}
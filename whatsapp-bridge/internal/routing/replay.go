@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/store"
+)
+
+// replayInterval spaces out replayed photos so the face detection service isn't flooded
+const replayInterval = 2 * time.Second
+
+// replayedMedia is a stored photo that was copied back into a face filter queue by a replay
+type replayedMedia struct {
+	messageID string
+	chatJID   string
+	force     bool
+}
+
+// replayedFiles maps the media file key of replayed copies to the message they came from
+var replayedFiles sync.Map
+
+// replayedMessage returns the message a replayed media file was copied from
+func replayedMessage(path string) (replayedMedia, bool) {
+	if path == "" {
+		return replayedMedia{}, false
+	}
+	value, ok := replayedFiles.Load(media.FileKey(path))
+	if !ok {
+		return replayedMedia{}, false
+	}
+	return value.(replayedMedia), true
+}
+
+// Replay copies the stored photos back into the queue of their chat's pipeline one by one so the face
+// detection service runs them through the current rules again. Photos whose file is gone are fetched
+// again with download when it is non-nil.
+func Replay(refs []store.MediaRef, force bool, download func(store.MediaRef) ([]byte, error)) {
+	replayed, missing := 0, 0
+	for i, ref := range refs {
+		data, err := os.ReadFile(ref.Path)
+		if err != nil && download != nil {
+			data, err = download(ref)
+		}
+		if err != nil {
+			fmt.Printf("[REPLAY] Skipping %s in %s: %v\n", ref.ID, ref.ChatJID, err)
+			missing++
+			continue
+		}
+
+		queue := media.QueueDir(config.Current().PipelineOf(ref.ChatJID))
+		if err := os.MkdirAll(queue, 0755); err != nil {
+			fmt.Printf("[REPLAY] Failed to create %s: %v\n", queue, err)
+			missing++
+			continue
+		}
+		dest := filepath.Join(queue, fmt.Sprintf("replay_%d_%s", time.Now().UnixNano(), media.FileKey(ref.Path)))
+		replayedFiles.Store(media.FileKey(dest), replayedMedia{messageID: ref.ID, chatJID: ref.ChatJID, force: force})
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			replayedFiles.Delete(media.FileKey(dest))
+			fmt.Printf("[REPLAY] Failed to write %s: %v\n", dest, err)
+			missing++
+			continue
+		}
+		replayed++
+		if i < len(refs)-1 {
+			time.Sleep(replayInterval)
+		}
+	}
+	fmt.Printf("[REPLAY] Finished: %d photos replayed, %d unavailable\n", replayed, missing)
+}
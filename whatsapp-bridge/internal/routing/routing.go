@@ -0,0 +1,39 @@
+// Package routing decides which chats the bridge processes, what of their content is kept, and which
+// forwards are sent, recorded or skipped.
+package routing
+
+import (
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-client/internal/config"
+)
+
+// IsKindergartenGroup checks if the given chat JID belongs to a kindergarten group
+func IsKindergartenGroup(chatJID string) bool {
+	for _, groupJID := range config.Current().InputGroups {
+		if chatJID == groupJID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsChannelJID reports whether the given JID belongs to a WhatsApp Channel (newsletter)
+func IsChannelJID(jid types.JID) bool {
+	return jid.Server == types.NewsletterServer
+}
+
+// IsMonitoredChannel checks if the given chat JID is one of the configured input channels
+func IsMonitoredChannel(chatJID string) bool {
+	for _, channelJID := range config.Current().InputChannels {
+		if chatJID == channelJID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMonitored reports whether the chat is a monitored group or channel
+func IsMonitored(chatJID string) bool {
+	return IsKindergartenGroup(chatJID) || IsMonitoredChannel(chatJID)
+}
@@ -0,0 +1,84 @@
+package routing
+
+import (
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/links"
+	"whatsapp-client/internal/store"
+)
+
+// minRepeatedLength keeps short replies such as "thanks!" from counting as repeated content
+const minRepeatedLength = 20
+
+// SpamMessage is what the spam rules look at
+type SpamMessage struct {
+	ID      string
+	ChatJID string
+	Sender  string
+	// Text or caption
+	Text      string
+	Timestamp time.Time
+	// Whether the sender is in the phone's address book
+	KnownSender bool
+}
+
+// SpamVerdict is the score of a message and the rules that contributed to it
+type SpamVerdict struct {
+	Score   int
+	Reasons []string
+	Spam    bool
+}
+
+// ScoreSpam runs the spam rules on a message of a monitored chat. Nothing is spam when the rules are
+// off, and messages of trusted senders never are.
+func ScoreSpam(messageStore *store.MessageStore, msg SpamMessage) SpamVerdict {
+	cfg := config.Current().Spam
+	var verdict SpamVerdict
+	if !cfg.Enabled || isTrustedSender(cfg.TrustedSenders, msg.Sender) {
+		return verdict
+	}
+	add := func(score int, reason string) {
+		if score > 0 {
+			verdict.Score += score
+			verdict.Reasons = append(verdict.Reasons, reason)
+		}
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	if urls := links.ExtractURLs(text); len(urls) > 0 {
+		rest := text
+		for _, u := range urls {
+			rest = strings.ReplaceAll(rest, u, "")
+		}
+		if strings.TrimSpace(rest) == "" {
+			add(cfg.LinkOnly, "link_only")
+		}
+	}
+	if !msg.KnownSender && !messageStore.HasPostedBefore(msg.ChatJID, msg.Sender, msg.Timestamp) {
+		add(cfg.UnknownSender, "unknown_sender")
+	}
+	if len(text) >= minRepeatedLength {
+		since := msg.Timestamp.Add(-time.Duration(cfg.RepeatWindowHours) * time.Hour)
+		if messageStore.CountSameText(msg.ID, text, since) > 0 {
+			add(cfg.Repeated, "repeated")
+		}
+	}
+
+	verdict.Spam = verdict.Score >= cfg.Threshold
+	return verdict
+}
+
+// isTrustedSender matches a sender JID against trusted phone numbers and JIDs
+func isTrustedSender(trusted []string, sender string) bool {
+	user, _, _ := strings.Cut(sender, "@")
+	user, _, _ = strings.Cut(user, ":")
+	for _, t := range trusted {
+		t = strings.TrimPrefix(t, "+")
+		if t == sender || t == user {
+			return true
+		}
+	}
+	return false
+}
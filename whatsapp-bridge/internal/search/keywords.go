@@ -0,0 +1,44 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// shortKeyword is the length of the longest keywords that only match a whole word, so "ביט" matches
+// "בביט" but not "ביטול"
+const shortKeyword = 3
+
+// Keywords are the words messages are recognized by, such as those of a topic or of a payment request.
+// A keyword with spaces or symbols matches anywhere in a text. Other keywords match the words starting
+// with them, also after a joined Hebrew prefix letter, so "טופס" matches "בטופס"; keywords of up to
+// three letters and Words only match whole words.
+type Keywords struct {
+	// Match the words starting with them
+	Stems []string
+	// Only match themselves, e.g. "form", which "format" starts with
+	Words []string
+}
+
+// Match reports whether text contains one of the keywords
+func (k Keywords) Match(text string) bool {
+	lower := strings.ToLower(text)
+	words := Terms(text)
+	match := func(whole bool) func(string) bool {
+		return func(keyword string) bool {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			switch {
+			case keyword == "":
+				return false
+			case strings.IndexFunc(keyword, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0:
+				return strings.Contains(lower, keyword)
+			case whole || utf8.RuneCountInString(keyword) <= shortKeyword:
+				return slices.Contains(words, keyword)
+			}
+			return slices.ContainsFunc(words, func(word string) bool { return strings.HasPrefix(word, keyword) })
+		}
+	}
+	return slices.ContainsFunc(k.Stems, match(false)) || slices.ContainsFunc(k.Words, match(true))
+}
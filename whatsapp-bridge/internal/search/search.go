@@ -0,0 +1,149 @@
+// Package search finds stored messages by their words, with the full-text index, and by their meaning,
+// with the embeddings of package embed, and merges what both find into one ranking.
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"whatsapp-client/internal/embed"
+	"whatsapp-client/internal/store"
+)
+
+// Modes of a search
+const (
+	ModeText     = "text"
+	ModeSemantic = "semantic"
+	ModeHybrid   = "hybrid"
+)
+
+const (
+	// semanticCandidates is how many of the messages closest in meaning are ranked
+	semanticCandidates = 100
+	// rankConstant keeps the first few ranks of either search from outweighing being found by both, as in
+	// reciprocal rank fusion
+	rankConstant = 60
+)
+
+// Query is a search of the messages matching Filter. Allow, when set, tells which chats the searcher
+// may read.
+type Query struct {
+	Text   string
+	Filter store.ExportFilter
+	Mode   string
+	Limit  int
+	Allow  func(chatJID string) bool
+}
+
+// Messages returns up to query.Limit messages for the query, best first. Hybrid searches rank messages
+// found by both searches first; without an embedder they are text searches, and a failing embedder
+// leaves them to the text search too.
+func Messages(ctx context.Context, messageStore *store.MessageStore, query Query) ([]store.SearchResult, error) {
+	var lists [][]store.SearchResult
+	if query.Mode != ModeSemantic {
+		found, err := messageStore.SearchMessages(Terms(query.Text), query.Filter, 0)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, found)
+	}
+	if query.Mode != ModeText {
+		found, err := embed.Nearest(ctx, messageStore, query.Text, query.Filter, semanticCandidates)
+		if err != nil {
+			if query.Mode == ModeSemantic {
+				return nil, err
+			}
+			fmt.Printf("[SEARCH] Semantic search failed, only searching words: %v\n", err)
+		}
+		lists = append(lists, found)
+	}
+
+	results := fuse(lists)
+	allowed := results[:0]
+	for _, result := range results {
+		if query.Allow == nil || query.Allow(result.ChatJID) {
+			allowed = append(allowed, result)
+		}
+	}
+	if query.Limit > 0 && len(allowed) > query.Limit {
+		allowed = allowed[:query.Limit]
+	}
+	return allowed, nil
+}
+
+// fuse merges ranked lists of results into one, ranking each message by the sum of 1/(rankConstant+rank)
+// over the lists it is in. A message in several lists keeps the score and similarity of each.
+func fuse(lists [][]store.SearchResult) []store.SearchResult {
+	results := []store.SearchResult{}
+	index := make(map[string]int)
+	weights := make(map[string]float64)
+	for _, list := range lists {
+		for rank, result := range list {
+			key := result.ChatJID + "|" + result.MessageID
+			weights[key] += 1 / float64(rankConstant+rank+1)
+			if i, ok := index[key]; ok {
+				results[i].Score = max(results[i].Score, result.Score)
+				results[i].Similarity = max(results[i].Similarity, result.Similarity)
+				continue
+			}
+			index[key] = len(results)
+			results = append(results, result)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return weights[results[i].ChatJID+"|"+results[i].MessageID] > weights[results[j].ChatJID+"|"+results[j].MessageID]
+	})
+	return results
+}
+
+// stopWords are words of a question that say nothing about what it is after, in English and Hebrew
+var stopWords = toSet(`a an the and or but if of to in on at by for from with about into over after before
+	what which who whom whose when where why how is are was were be been being do does did done have has had
+	i me my we our you your he him his she her it its they them their this that these those there here
+	say said tell told know any some all can could would should will shall may might must not no yes
+	מה מי מתי איפה איך למה האם של את על עם אל גם אבל או כי אם זה זו זאת הוא היא הם הן אני אנחנו אתה את
+	אתם יש אין היה היתה היו כל עוד רק כבר לא כן מן עד לפני אחרי אמר אמרה אמרו`)
+
+// hebrewPrefixes are the one-letter words written joined to the next word: and, the, in, to, from,
+// that, as
+const hebrewPrefixes = "והבלמשכ"
+
+// Terms picks the search terms of a query: its words without stop words, and Hebrew words also without
+// a joined prefix letter, so "במסיבה" finds "מסיבה"
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, word := range store.SearchTerms(query) {
+		if stopWords[word] || (utf8.RuneCountInString(word) < 2 && !isNumber(word)) {
+			continue
+		}
+		add(word)
+		if first, size := utf8.DecodeRuneInString(word); strings.ContainsRune(hebrewPrefixes, first) && utf8.RuneCountInString(word) > 3 {
+			add(word[size:])
+		}
+	}
+	return terms
+}
+
+// isNumber tells whether word is all digits
+func isNumber(word string) bool {
+	return strings.Trim(word, "0123456789") == ""
+}
+
+// toSet makes a set of the words in text
+func toSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		set[word] = true
+	}
+	return set
+}
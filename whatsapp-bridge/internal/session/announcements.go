@@ -0,0 +1,48 @@
+package session
+
+import (
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// acknowledgeAnnouncement marks the forwards a message answers as acknowledged: the one it reacts to or
+// replies to, or in a chat with one person, every forward sent there before it. Forwards mostly go to
+// chats that aren't monitored, so this runs before those are skipped.
+func acknowledgeAnnouncement(messageStore *store.MessageStore, msg *events.Message, logger waLog.Logger) {
+	if !config.Current().Acknowledgments.Enabled || msg.Info.IsFromMe {
+		return
+	}
+	chatJID, sender := msg.Info.Chat.String(), msg.Info.Sender.String()
+	referenced, how := "", ""
+	if reaction := msg.Message.GetReactionMessage(); reaction != nil {
+		// Taking a reaction back doesn't acknowledge anything
+		if reaction.GetText() == "" {
+			return
+		}
+		referenced, how = reaction.GetKey().GetID(), store.AcknowledgedByReaction
+	} else if reply := replyContextFromMessage(chatJID, msg.Message); reply != nil {
+		referenced, how = reply.QuotedID, store.AcknowledgedByReply
+	}
+	if referenced != "" {
+		acknowledged, err := messageStore.AcknowledgeAnnouncement(referenced, sender, how, msg.Info.Timestamp)
+		if err != nil {
+			logger.Warnf("Failed to acknowledge forward %s: %v", referenced, err)
+		} else if acknowledged {
+			logger.Infof("[ACKS] Forward %s acknowledged by %s in %s (%s)", referenced, sender, chatJID, how)
+			return
+		}
+	}
+	if msg.Info.Chat.Server != types.DefaultUserServer {
+		return
+	}
+	count, err := messageStore.AcknowledgeChatAnnouncements(chatJID, sender, store.AcknowledgedByMessage, msg.Info.Timestamp)
+	if err != nil {
+		logger.Warnf("Failed to acknowledge the forwards to %s: %v", chatJID, err)
+	} else if count > 0 {
+		logger.Infof("[ACKS] %d forwards acknowledged by %s", count, sender)
+	}
+}
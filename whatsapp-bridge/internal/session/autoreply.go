@@ -0,0 +1,58 @@
+package session
+
+import (
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/autoreply"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// answerAutomatically answers a message someone wrote to the account, or in a monitored group, by the
+// auto-reply rules. The answer is sent in the background so storing the message doesn't wait for it.
+func answerAutomatically(client WhatsAppClient, messageStore *store.MessageStore, msg *events.Message, logger waLog.Logger) {
+	if !config.Current().AutoReply.Enabled || msg.Info.IsFromMe ||
+		msg.Message.GetReactionMessage() != nil || msg.Message.GetProtocolMessage() != nil {
+		return
+	}
+	chat := msg.Info.Chat
+	switch {
+	case msg.Info.IsGroup:
+		if !routing.IsMonitored(chat.String()) {
+			return
+		}
+	case chat.Server != types.DefaultUserServer && chat.Server != types.HiddenUserServer:
+		// Channels, broadcasts and status updates aren't conversations
+		return
+	}
+	sender := msg.Info.Sender.ToNonAD()
+	phone := ""
+	if sender.Server == types.DefaultUserServer {
+		phone = sender.User
+	}
+	incoming := autoreply.Message{
+		ID:      msg.Info.ID,
+		ChatJID: chat.String(),
+		Sender:  sender.String(),
+		Phone:   phone,
+		Text:    extractTextContent(msg.Message),
+		Group:   msg.Info.IsGroup,
+		Contact: chatDisplayName(client, sender) != "",
+		At:      msg.Info.Timestamp,
+	}
+	if incoming.At.IsZero() {
+		incoming.At = time.Now()
+	}
+	go func() {
+		defer crash.Recover("an automatic reply")
+		ctx, cancel := SendTimeout(ShutdownContext())
+		defer cancel()
+		autoreply.Answer(ctx, messageStore, AlertSender(client), incoming, logger)
+	}()
+}
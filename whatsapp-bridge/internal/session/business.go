@@ -0,0 +1,171 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+)
+
+// businessText renders the template, button, list, order and product messages businesses send (and the
+// replies to them) as readable text, so a vendor's order summary isn't stored as an empty message.
+// Returns "" for other messages.
+func businessText(msg *waProto.Message) string {
+	switch {
+	case msg.GetTemplateMessage() != nil:
+		return templateText(msg.GetTemplateMessage())
+	case msg.GetButtonsMessage() != nil:
+		return buttonsText(msg.GetButtonsMessage())
+	case msg.GetListMessage() != nil:
+		return listText(msg.GetListMessage())
+	case msg.GetInteractiveMessage() != nil:
+		return interactiveText(msg.GetInteractiveMessage())
+	case msg.GetOrderMessage() != nil:
+		return orderText(msg.GetOrderMessage())
+	case msg.GetProductMessage() != nil:
+		return productText(msg.GetProductMessage())
+	case msg.GetButtonsResponseMessage() != nil:
+		return msg.GetButtonsResponseMessage().GetSelectedDisplayText()
+	case msg.GetTemplateButtonReplyMessage() != nil:
+		return msg.GetTemplateButtonReplyMessage().GetSelectedDisplayText()
+	case msg.GetListResponseMessage() != nil:
+		return joinLines(msg.GetListResponseMessage().GetTitle(), msg.GetListResponseMessage().GetDescription())
+	case msg.GetInteractiveResponseMessage() != nil:
+		return msg.GetInteractiveResponseMessage().GetBody().GetText()
+	}
+	return ""
+}
+
+// templateText renders a template message: title, body, footer and one line per button
+func templateText(template *waProto.TemplateMessage) string {
+	hydrated := template.GetHydratedTemplate()
+	if hydrated == nil {
+		hydrated = template.GetHydratedFourRowTemplate()
+	}
+	if hydrated == nil {
+		if interactive := template.GetInteractiveMessageTemplate(); interactive != nil {
+			return interactiveText(interactive)
+		}
+		return ""
+	}
+	lines := []string{hydrated.GetHydratedTitleText(), hydrated.GetHydratedContentText(), hydrated.GetHydratedFooterText()}
+	for _, button := range hydrated.GetHydratedButtons() {
+		switch {
+		case button.GetQuickReplyButton() != nil:
+			lines = append(lines, buttonLine(button.GetQuickReplyButton().GetDisplayText(), ""))
+		case button.GetUrlButton() != nil:
+			lines = append(lines, buttonLine(button.GetUrlButton().GetDisplayText(), button.GetUrlButton().GetURL()))
+		case button.GetCallButton() != nil:
+			lines = append(lines, buttonLine(button.GetCallButton().GetDisplayText(), button.GetCallButton().GetPhoneNumber()))
+		}
+	}
+	return joinLines(lines...)
+}
+
+// buttonsText renders a buttons message: header, body, footer and one line per button
+func buttonsText(buttons *waProto.ButtonsMessage) string {
+	lines := []string{buttons.GetText(), buttons.GetContentText(), buttons.GetFooterText()}
+	for _, button := range buttons.GetButtons() {
+		lines = append(lines, buttonLine(button.GetButtonText().GetDisplayText(), ""))
+	}
+	return joinLines(lines...)
+}
+
+// listText renders a list message with its sections and the rows to choose from
+func listText(list *waProto.ListMessage) string {
+	lines := []string{list.GetTitle(), list.GetDescription()}
+	for _, section := range list.GetSections() {
+		lines = append(lines, section.GetTitle())
+		for _, row := range section.GetRows() {
+			if row.GetDescription() != "" {
+				lines = append(lines, fmt.Sprintf("- %s: %s", row.GetTitle(), row.GetDescription()))
+			} else {
+				lines = append(lines, "- "+row.GetTitle())
+			}
+		}
+	}
+	lines = append(lines, list.GetFooterText(), buttonLine(list.GetButtonText(), ""))
+	return joinLines(lines...)
+}
+
+// interactiveText renders an interactive message: header, body, footer and its native flow buttons
+func interactiveText(interactive *waProto.InteractiveMessage) string {
+	lines := []string{interactive.GetHeader().GetTitle(), interactive.GetHeader().GetSubtitle()}
+	if product := interactive.GetHeader().GetProductMessage(); product != nil {
+		lines = append(lines, productText(product))
+	}
+	lines = append(lines, interactive.GetBody().GetText(), interactive.GetFooter().GetText())
+	for _, button := range interactive.GetNativeFlowMessage().GetButtons() {
+		// The button's label and link are only found in its JSON parameters
+		var params struct {
+			DisplayText string `json:"display_text"`
+			URL         string `json:"url"`
+			PhoneNumber string `json:"phone_number"`
+		}
+		if json.Unmarshal([]byte(button.GetButtonParamsJSON()), &params) != nil || params.DisplayText == "" {
+			continue
+		}
+		lines = append(lines, buttonLine(params.DisplayText, params.URL+params.PhoneNumber))
+	}
+	return joinLines(lines...)
+}
+
+// orderText summarises an order: its title, item count, total and the note sent with it
+func orderText(order *waProto.OrderMessage) string {
+	summary := "Order"
+	if order.GetOrderTitle() != "" {
+		summary += ": " + order.GetOrderTitle()
+	}
+	var details []string
+	if order.GetItemCount() > 0 {
+		details = append(details, fmt.Sprintf("%d items", order.GetItemCount()))
+	}
+	if order.GetTotalAmount1000() > 0 {
+		details = append(details, "total "+formatPrice(order.GetTotalAmount1000(), order.GetTotalCurrencyCode()))
+	}
+	if len(details) > 0 {
+		summary += " (" + strings.Join(details, ", ") + ")"
+	}
+	return joinLines(summary, order.GetMessage())
+}
+
+// productText renders a catalog product with its price, description and link
+func productText(product *waProto.ProductMessage) string {
+	snapshot := product.GetProduct()
+	summary := "Product"
+	if snapshot.GetTitle() != "" {
+		summary += ": " + snapshot.GetTitle()
+	}
+	if snapshot.GetPriceAmount1000() > 0 {
+		summary += ", " + formatPrice(snapshot.GetPriceAmount1000(), snapshot.GetCurrencyCode())
+	}
+	return joinLines(product.GetBody(), summary, snapshot.GetDescription(), snapshot.GetURL(), product.GetFooter())
+}
+
+// formatPrice formats an amount given in thousandths of the currency unit
+func formatPrice(amount1000 int64, currency string) string {
+	return strings.TrimSpace(fmt.Sprintf("%.2f %s", float64(amount1000)/1000, currency))
+}
+
+// buttonLine renders a button as "[label]", with the link or phone number it opens
+func buttonLine(label, target string) string {
+	if label == "" {
+		return ""
+	}
+	if target != "" {
+		return fmt.Sprintf("[%s] %s", label, target)
+	}
+	return "[" + label + "]"
+}
+
+// joinLines joins the non-empty lines with newlines
+func joinLines(lines ...string) string {
+	var kept []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
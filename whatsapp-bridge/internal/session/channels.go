@@ -0,0 +1,96 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/routing"
+)
+
+// channelNames caches display names of followed channels, keyed by JID string
+var channelNames sync.Map
+
+// ChannelName returns the cached display name of a channel, falling back to its JID user part
+func ChannelName(jid types.JID) string {
+	if name, ok := channelNames.Load(jid.String()); ok {
+		return name.(string)
+	}
+	return jid.User
+}
+
+// FollowChannels makes sure every configured input channel is followed and subscribed to live updates
+func FollowChannels(client *whatsmeow.Client, logger waLog.Logger) {
+	if len(config.Current().InputChannels) == 0 {
+		return
+	}
+
+	// Collect channels we already follow so we don't send redundant follow requests
+	followed := make(map[string]bool)
+	subscribed, err := client.GetSubscribedNewsletters()
+	if err != nil {
+		logger.Warnf("[CHANNELS] Failed to get subscribed channels: %v", err)
+	}
+	for _, meta := range subscribed {
+		followed[meta.ID.String()] = true
+		channelNames.Store(meta.ID.String(), meta.ThreadMeta.Name.Text)
+	}
+
+	for _, channelJID := range config.Current().InputChannels {
+		jid, err := types.ParseJID(channelJID)
+		if err != nil || !routing.IsChannelJID(jid) {
+			logger.Warnf("[CHANNELS] Invalid channel JID %s, expected <id>@newsletter", channelJID)
+			continue
+		}
+
+		if !followed[jid.String()] {
+			if err := client.FollowNewsletter(jid); err != nil {
+				logger.Errorf("[CHANNELS] Failed to follow channel %s: %v", channelJID, err)
+				continue
+			}
+			logger.Infof("[CHANNELS] Followed channel %s", channelJID)
+		}
+
+		if _, ok := channelNames.Load(jid.String()); !ok {
+			if meta, err := client.GetNewsletterInfo(jid); err == nil {
+				channelNames.Store(jid.String(), meta.ThreadMeta.Name.Text)
+			}
+		}
+
+		// Live updates are needed to receive new posts as message events
+		ctx, cancel := SendTimeout(ShutdownContext())
+		_, err = client.NewsletterSubscribeLiveUpdates(ctx, jid)
+		cancel()
+		if err != nil {
+			logger.Warnf("[CHANNELS] Failed to subscribe to live updates for %s: %v", channelJID, err)
+		}
+
+		logger.Infof("[CHANNEL] Name: %s (JID: %s)", ChannelName(jid), channelJID)
+	}
+}
+
+// ListChannels lists all channels the user follows
+func ListChannels(client *whatsmeow.Client) error {
+	if client == nil || !client.IsConnected() {
+		return fmt.Errorf("client is not connected")
+	}
+
+	channels, err := client.GetSubscribedNewsletters()
+	if err != nil {
+		return fmt.Errorf("failed to get channels: %v", err)
+	}
+
+	fmt.Println("\n=== WhatsApp Channels ===")
+	fmt.Printf("Found %d channels:\n\n", len(channels))
+
+	for i, channel := range channels {
+		fmt.Printf("%d. Name: %s\n   ID: %s\n\n", i+1, channel.ThreadMeta.Name.Text, channel.ID)
+	}
+
+	fmt.Println("To monitor a channel, copy the ID (including @newsletter) into input_channels in your config.json file.")
+	return nil
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// HandleMute records a chat being muted or unmuted on the phone
+func HandleMute(messageStore *store.MessageStore, evt *events.Mute, logger waLog.Logger) {
+	var until time.Time
+	// The end is in milliseconds; -1 or nothing means until unmuted
+	if end := evt.Action.GetMuteEndTimestamp(); end > 0 {
+		until = time.UnixMilli(end)
+	}
+	if err := messageStore.SetChatMuted(evt.JID.String(), evt.Action.GetMuted(), until); err != nil {
+		logger.Warnf("Failed to store mute state of %s: %v", evt.JID, err)
+	}
+}
+
+// HandleArchive records a chat being archived or unarchived on the phone
+func HandleArchive(messageStore *store.MessageStore, evt *events.Archive, logger waLog.Logger) {
+	if err := messageStore.SetChatArchived(evt.JID.String(), evt.Action.GetArchived()); err != nil {
+		logger.Warnf("Failed to store archive state of %s: %v", evt.JID, err)
+	}
+}
+
+// HandlePin records a chat being pinned or unpinned on the phone
+func HandlePin(messageStore *store.MessageStore, evt *events.Pin, logger waLog.Logger) {
+	if err := messageStore.SetChatPinned(evt.JID.String(), evt.Action.GetPinned()); err != nil {
+		logger.Warnf("Failed to store pin state of %s: %v", evt.JID, err)
+	}
+}
+
+// archiveDelay waits for a burst of messages to end before a chat is archived, so a busy group costs
+// one app state patch rather than one per message
+const archiveDelay = time.Minute
+
+// appStateSender is implemented by clients that can change chat settings on the phone
+type appStateSender interface {
+	SendAppState(patch appstate.PatchInfo) error
+}
+
+// pendingArchives holds a timer per chat waiting to be archived
+var pendingArchives = struct {
+	sync.Mutex
+	timers map[string]*time.Timer
+}{timers: make(map[string]*time.Timer)}
+
+// archiveAfterStore archives a monitored chat on the phone shortly after its latest message was stored,
+// if chats.archive_monitored is set. WhatsApp unarchives a chat when a message arrives, unless the phone
+// keeps chats archived, so this is repeated for later messages.
+func archiveAfterStore(client WhatsAppClient, messageStore *store.MessageStore, msg *events.Message) {
+	sender, ok := client.(appStateSender)
+	chatJID := msg.Info.Chat.String()
+	if !ok || !config.Current().Chats.ArchiveMonitored || !routing.IsMonitored(chatJID) {
+		return
+	}
+	key := &waCommon.MessageKey{
+		RemoteJID: proto.String(chatJID),
+		FromMe:    proto.Bool(msg.Info.IsFromMe),
+		ID:        proto.String(msg.Info.ID),
+	}
+	if msg.Info.IsGroup && !msg.Info.IsFromMe {
+		key.Participant = proto.String(msg.Info.Sender.ToNonAD().String())
+	}
+	timestamp := msg.Info.Timestamp
+
+	pendingArchives.Lock()
+	defer pendingArchives.Unlock()
+	if timer, ok := pendingArchives.timers[chatJID]; ok {
+		timer.Stop()
+	}
+	pendingArchives.timers[chatJID] = time.AfterFunc(archiveDelay, func() {
+		pendingArchives.Lock()
+		delete(pendingArchives.timers, chatJID)
+		pendingArchives.Unlock()
+		if err := archiveChat(sender, messageStore, msg.Info.Chat, timestamp, key); err != nil {
+			fmt.Printf("[CHATS] Failed to archive %s: %v\n", chatJID, err)
+		}
+	})
+}
+
+// archiveChat archives a chat on the phone up to its last message and records it
+func archiveChat(sender appStateSender, messageStore *store.MessageStore, chat types.JID, lastTimestamp time.Time, lastKey *waCommon.MessageKey) error {
+	if err := sender.SendAppState(appstate.BuildArchive(chat, true, lastTimestamp, lastKey)); err != nil {
+		return err
+	}
+	fmt.Printf("[CHATS] Archived %s on the phone\n", chat)
+	return messageStore.SetChatArchived(chat.String(), true)
+}
@@ -0,0 +1,125 @@
+// Package session wraps the WhatsApp connection: it turns incoming events into stored messages and
+// sends messages on behalf of the API.
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+)
+
+// WhatsAppClient is the part of the WhatsApp connection the message handling, the sender and the API
+// handlers use. *whatsmeow.Client implements it; the -mock mode substitutes an in-memory fake.
+type WhatsAppClient interface {
+	IsConnected() bool
+	SendMessage(ctx context.Context, to types.JID, message *waProto.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error)
+	Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error)
+	Download(msg whatsmeow.DownloadableMessage) ([]byte, error)
+	GetJoinedGroups() ([]*types.GroupInfo, error)
+}
+
+var _ WhatsAppClient = (*whatsmeow.Client)(nil)
+
+// chatDisplayName returns the name the client knows a chat by, if any. Contact names live in the
+// whatsmeow session store, which is not part of WhatsAppClient.
+func chatDisplayName(client WhatsAppClient, jid types.JID) string {
+	switch c := client.(type) {
+	case *whatsmeow.Client:
+		if contact, err := c.Store.Contacts.GetContact(jid); err == nil {
+			return contact.FullName
+		}
+	case *Mock:
+		return c.ChatName(jid)
+	}
+	return ""
+}
+
+// ListGroups lists all groups the user is a member of
+func ListGroups(client WhatsAppClient) error {
+	if client == nil || !client.IsConnected() {
+		return fmt.Errorf("client is not connected")
+	}
+
+	groups, err := client.GetJoinedGroups()
+	if err != nil {
+		return fmt.Errorf("failed to get groups: %v", err)
+	}
+
+	fmt.Println("\n=== WhatsApp Groups ===")
+	fmt.Printf("Found %d groups:\n\n", len(groups))
+
+	for i, group := range groups {
+		fmt.Printf("%d. Name: %s\n   ID: %s\n\n", i+1, group.Name, group.JID)
+	}
+
+	fmt.Println("To use a group in your configuration, copy the ID (including @g.us) into your config.json file, or refer to it by its exact name through group_aliases.")
+	return nil
+}
+
+// ResolveGroupAliases finds the joined group each configured group alias names and makes the aliases
+// resolve to their JIDs. Aliases that match no group, or more than one, are logged and left unresolved.
+func ResolveGroupAliases(groups []*types.GroupInfo, logger waLog.Logger) {
+	cfg := config.Current()
+	if len(cfg.GroupAliases) == 0 {
+		return
+	}
+	jids, problems := cfg.MatchGroupAliases(groups)
+	for _, problem := range problems {
+		logger.Errorf("[CONFIG] %s", problem)
+	}
+	for alias, jid := range jids {
+		logger.Infof("[CONFIG] Group alias %q is %s", alias, jid)
+	}
+	config.SetGroupJIDs(jids)
+}
+
+// DiscoverInputGroups monitors the joined groups whose names match input_group_patterns, and logs the
+// ones that weren't monitored before
+func DiscoverInputGroups(groups []*types.GroupInfo, logger waLog.Logger) {
+	cfg := config.Current()
+	if len(cfg.InputGroupPatterns) == 0 && len(config.DiscoveredGroups()) == 0 {
+		return
+	}
+	jids := cfg.MatchInputGroupPatterns(groups)
+	names := make(map[string]string)
+	for _, group := range groups {
+		names[group.JID.String()] = group.Name
+	}
+	previous := config.DiscoveredGroups()
+	for _, jid := range jids {
+		if !slices.Contains(previous, jid) {
+			logger.Infof("[GROUPS] Monitoring %q (%s), its name matches input_group_patterns", names[jid], jid)
+		}
+	}
+	for _, jid := range previous {
+		if !slices.Contains(jids, jid) {
+			logger.Infof("[GROUPS] No longer monitoring %s, its name doesn't match input_group_patterns any more", jid)
+		}
+	}
+	config.SetDiscoveredGroups(jids)
+}
+
+// CheckDestinations warns about destination groups the account is not a member of; photos sent there
+// would fail. Destinations that are phone numbers are direct chats and always reachable.
+func CheckDestinations(groups []*types.GroupInfo, logger waLog.Logger) {
+	joined := make(map[string]bool)
+	for _, group := range groups {
+		joined[group.JID.String()] = true
+	}
+	for name, dest := range config.Current().Destinations {
+		if !strings.HasSuffix(dest.Group, "@"+types.GroupServer) {
+			continue
+		}
+		if !joined[dest.Group] {
+			logger.Warnf("[CONFIG] Destination %q: group %s is not among the joined groups, run with -list-groups to copy the right JID", name, dest.Group)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package session
+
+import (
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/commands"
+)
+
+// mockAccount is the phone number of the account the bridge pretends to be linked to with -mock
+const mockAccount = "10000000000"
+
+// OwnChat returns the JID of the linked account's chat with itself, or "" before pairing
+func OwnChat(client WhatsAppClient) string {
+	switch c := client.(type) {
+	case *whatsmeow.Client:
+		if c.Store.ID != nil {
+			return c.Store.ID.ToNonAD().String()
+		}
+	case *Mock:
+		return types.NewJID(mockAccount, types.DefaultUserServer).String()
+	}
+	return ""
+}
+
+// runCommand answers a command the owner sent to themselves and reports whether msg was one. Commands
+// aren't stored as messages.
+func runCommand(client WhatsAppClient, msg *events.Message, logger waLog.Logger) bool {
+	own := OwnChat(client)
+	if !msg.Info.IsFromMe || own == "" || msg.Info.Chat.ToNonAD().String() != own {
+		return false
+	}
+	ctx, cancel := SendTimeout(ShutdownContext())
+	defer cancel()
+	reply, ok := commands.Run(ctx, extractTextContent(msg.Message))
+	if !ok {
+		return false
+	}
+	logger.Infof("[COMMANDS] Running %q from the owner's chat", extractTextContent(msg.Message))
+	if _, err := SendMessage(ctx, client, own, reply, "", "", "", nil, false); err != nil {
+		logger.Warnf("Failed to reply to a command: %v", err)
+	}
+	return true
+}
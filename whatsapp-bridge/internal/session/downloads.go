@@ -0,0 +1,31 @@
+package session
+
+import (
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/notify"
+)
+
+// Download limits for media of live messages and for backfill (history sync, replay and re-downloads);
+// unlimited until ConfigureDownloads is called
+var (
+	liveDownloads    *media.Limiter
+	historyDownloads *media.Limiter
+)
+
+// ConfigureDownloads sets the download limits, so a history sync of thousands of photos doesn't saturate
+// the uplink or trip WhatsApp's rate limits while live photos keep arriving, the storage quota and the
+// largest image that is decoded
+func ConfigureDownloads(cfg config.MediaConfig) {
+	media.MaxPixels = cfg.MaxImagePixels
+	liveDownloads = media.NewLimiter(cfg.LiveDownloads.Concurrency, cfg.LiveDownloads.BytesPerSecond)
+	historyDownloads = media.NewLimiter(cfg.HistoryDownloads.Concurrency, cfg.HistoryDownloads.BytesPerSecond)
+	media.ConfigureStorage(cfg.QuotaBytes, cfg.MinFreeBytes, func(full bool, reason string) {
+		if full {
+			notify.Alert("storage_full", i18n.T("alert.storage_full", reason))
+		} else {
+			notify.Alert("storage_ok", i18n.T("alert.storage_ok"))
+		}
+	})
+}
@@ -0,0 +1,822 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-client/internal/calendar"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/forms"
+	"whatsapp-client/internal/links"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/payments"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+	"whatsapp-client/internal/topics"
+	"whatsapp-client/internal/tracing"
+)
+
+// Extract text content from a message
+func extractTextContent(msg *waProto.Message) string {
+	msg = unwrapMessage(msg)
+	if msg == nil {
+		return ""
+	}
+
+	// Try to get text content
+	if text := msg.GetConversation(); text != "" {
+		return text
+	} else if extendedText := msg.GetExtendedTextMessage(); extendedText != nil {
+		return extendedText.GetText()
+	}
+
+	// Check for image or video caption
+	if imageMsg := msg.GetImageMessage(); imageMsg != nil {
+		return imageMsg.GetCaption()
+	}
+	if videoMsg := msg.GetVideoMessage(); videoMsg != nil {
+		return videoMsg.GetCaption()
+	}
+	if documentMsg := msg.GetDocumentMessage(); documentMsg != nil {
+		return documentMsg.GetCaption()
+	}
+
+	// Business messages keep their text in structured fields
+	return businessText(msg)
+}
+
+// unwrapMessage returns the message inside ephemeral, view-once, document-with-caption and edit
+// envelopes, which can be nested. Live messages arrive unwrapped, but history sync keeps the envelopes.
+func unwrapMessage(msg *waProto.Message) *waProto.Message {
+	for {
+		var inner *waProto.Message
+		switch {
+		case msg.GetEphemeralMessage() != nil:
+			inner = msg.GetEphemeralMessage().GetMessage()
+		case msg.GetViewOnceMessage() != nil:
+			inner = msg.GetViewOnceMessage().GetMessage()
+		case msg.GetViewOnceMessageV2() != nil:
+			inner = msg.GetViewOnceMessageV2().GetMessage()
+		case msg.GetViewOnceMessageV2Extension() != nil:
+			inner = msg.GetViewOnceMessageV2Extension().GetMessage()
+		case msg.GetDocumentWithCaptionMessage() != nil:
+			inner = msg.GetDocumentWithCaptionMessage().GetMessage()
+		case msg.GetEditedMessage() != nil:
+			inner = msg.GetEditedMessage().GetMessage()
+		case msg.GetProtocolMessage().GetEditedMessage() != nil:
+			// An edit carries the new content of the message it replaces
+			inner = msg.GetProtocolMessage().GetEditedMessage()
+		}
+		if inner == nil {
+			return msg
+		}
+		msg = inner
+	}
+}
+
+// now is the clock live messages are judged by; tests pin it to replay recorded events
+var now = time.Now
+
+// Extract media content from a message: the stored file, the embedded thumbnail, the media type and,
+// for videos, the poster frame
+func extractMediaContent(ctx context.Context, client WhatsAppClient, msg *waProto.Message, chatJID string, isHistorical, forward bool, messageTimestamp time.Time) (string, string, string, string, error) {
+	if msg == nil {
+		return "", "", "", "", nil
+	}
+
+	// Only handle image and video messages
+	imageMsg, videoMsg := msg.GetImageMessage(), msg.GetVideoMessage()
+	if imageMsg == nil && videoMsg == nil {
+		return "", "", "", "", nil
+	}
+
+	// Skip old messages in non-historical context
+	if !isHistorical {
+		fiveMinutesAgo := now().Add(-5 * time.Minute)
+		if messageTimestamp.Before(fiveMinutesAgo) {
+			return "", "", "", "", nil
+		}
+	}
+
+	var downloadable whatsmeow.DownloadableMessage = imageMsg
+	mediaType, ext, size, thumbnail := "image", ".jpg", imageMsg.GetFileLength(), imageMsg.GetJPEGThumbnail()
+	if imageMsg == nil {
+		downloadable = videoMsg
+		mediaType, ext, size, thumbnail = "video", ".mp4", videoMsg.GetFileLength(), videoMsg.GetJPEGThumbnail()
+	}
+
+	// With the quota used up or the disk almost full only the thumbnail is kept
+	if err := media.CheckSpace(int64(size)); err != nil {
+		fmt.Printf("[WARN] Not downloading %s: %v\n", mediaType, err)
+		return "", string(thumbnail), mediaType, "", nil
+	}
+
+	// Download the media within the limits for live or history media
+	limiter := liveDownloads
+	if isHistorical {
+		limiter = historyDownloads
+	}
+	release, err := limiter.Wait(ctx, size)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("failed to download %s: %v", mediaType, err)
+	}
+	data, err := download(ctx, client, downloadable)
+	release()
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("failed to download %s: %v", mediaType, err)
+	}
+
+	// Save the media under its content hash; a photo posted to several groups is stored once
+	root := routing.MediaRoot(chatJID)
+	filename, created, err := media.StoreBlobIn(root, data, ext)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("failed to save %s: %v", mediaType, err)
+	}
+
+	// New photos are handed to the face filter of the chat's pipeline unless they are spam or filtered out;
+	// videos aren't, and photos already stored were handled
+	if mediaType == "image" && created && forward {
+		if err := media.QueueForFaceFilter(filename, config.Current().PipelineOf(chatJID)); err != nil {
+			fmt.Printf("[WARN] Failed to queue %s for the face filter: %v\n", filename, err)
+		}
+	}
+
+	// Videos get a poster frame named after the video; a video without one is still stored
+	poster := ""
+	if mediaType == "video" {
+		poster = filepath.Join(root, "posters", strings.TrimSuffix(filepath.Base(filename), ext)+".jpg")
+		if _, err := os.Stat(poster); err == nil && !created {
+			return filename, string(thumbnail), mediaType, poster, nil
+		}
+		if err := media.PosterFrame(ctx, filename, poster, thumbnail); err != nil {
+			fmt.Printf("[WARN] No poster frame for %s: %v\n", filename, err)
+			poster = ""
+		}
+	}
+
+	return filename, string(thumbnail), mediaType, poster, nil
+}
+
+// mediaKeysFromMessage extracts the download keys of an image or video message, if any
+func mediaKeysFromMessage(msg *waProto.Message) *store.MediaKeys {
+	if videoMsg := msg.GetVideoMessage(); videoMsg != nil {
+		return &store.MediaKeys{
+			MediaKey:      videoMsg.GetMediaKey(),
+			DirectPath:    videoMsg.GetDirectPath(),
+			FileSHA256:    videoMsg.GetFileSHA256(),
+			FileEncSHA256: videoMsg.GetFileEncSHA256(),
+			FileLength:    videoMsg.GetFileLength(),
+		}
+	}
+	imageMsg := msg.GetImageMessage()
+	if imageMsg == nil {
+		return nil
+	}
+	return &store.MediaKeys{
+		MediaKey:      imageMsg.GetMediaKey(),
+		DirectPath:    imageMsg.GetDirectPath(),
+		FileSHA256:    imageMsg.GetFileSHA256(),
+		FileEncSHA256: imageMsg.GetFileEncSHA256(),
+		FileLength:    imageMsg.GetFileLength(),
+	}
+}
+
+// mediaDetails separates the caption of a stored photo or video from the message text and describes
+// the file. Without a stored file the caption stays the message text.
+func mediaDetails(msg *waProto.Message, text, path string) (string, *store.MediaDetails) {
+	if videoMsg := msg.GetVideoMessage(); videoMsg != nil && path != "" {
+		return "", &store.MediaDetails{
+			Caption:  text,
+			Filename: filepath.Base(path),
+			MimeType: videoMsg.GetMimetype(),
+			FileSize: int64(videoMsg.GetFileLength()),
+		}
+	}
+	imageMsg := msg.GetImageMessage()
+	if imageMsg == nil || path == "" {
+		return text, nil
+	}
+	return "", &store.MediaDetails{
+		Caption:  text,
+		Filename: filepath.Base(path),
+		MimeType: imageMsg.GetMimetype(),
+		FileSize: int64(imageMsg.GetFileLength()),
+	}
+}
+
+// quotedSnippetLength caps the stored excerpt of a quoted message
+const quotedSnippetLength = 200
+
+// replyContextFromMessage extracts the message a reply quotes, or nil if it isn't a reply
+func replyContextFromMessage(chatJID string, msg *waProto.Message) *store.ReplyContext {
+	var info *waProto.ContextInfo
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		info = msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		info = msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		info = msg.GetVideoMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		info = msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		info = msg.GetAudioMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		info = msg.GetStickerMessage().GetContextInfo()
+	}
+	if info.GetStanzaID() == "" {
+		return nil
+	}
+
+	snippet := extractTextContent(info.GetQuotedMessage())
+	if snippet == "" && info.GetQuotedMessage().GetImageMessage() != nil {
+		snippet = "[photo]"
+	}
+	if runes := []rune(snippet); len(runes) > quotedSnippetLength {
+		snippet = string(runes[:quotedSnippetLength]) + "…"
+	}
+	return &store.ReplyContext{
+		QuotedID:      info.GetStanzaID(),
+		QuotedSender:  info.GetParticipant(),
+		QuotedSnippet: routing.RedactContent(chatJID, snippet),
+	}
+}
+
+// DownloadMediaRef fetches a message's media from WhatsApp using the stored keys, within the backfill limits
+func DownloadMediaRef(ctx context.Context, client WhatsAppClient, ref store.MediaRef) ([]byte, error) {
+	if len(ref.Keys.MediaKey) == 0 || ref.Keys.DirectPath == "" {
+		return nil, fmt.Errorf("no media keys stored")
+	}
+	release, err := historyDownloads.Wait(ctx, ref.Keys.FileLength)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	defer release()
+
+	// The media type selects the decryption keys, so videos must be fetched as videos
+	var msg whatsmeow.DownloadableMessage = &waProto.ImageMessage{
+		DirectPath:    proto.String(ref.Keys.DirectPath),
+		MediaKey:      ref.Keys.MediaKey,
+		FileSHA256:    ref.Keys.FileSHA256,
+		FileEncSHA256: ref.Keys.FileEncSHA256,
+		FileLength:    proto.Uint64(ref.Keys.FileLength),
+	}
+	if ref.MediaType == "video" {
+		msg = &waProto.VideoMessage{
+			DirectPath:    proto.String(ref.Keys.DirectPath),
+			MediaKey:      ref.Keys.MediaKey,
+			FileSHA256:    ref.Keys.FileSHA256,
+			FileEncSHA256: ref.Keys.FileEncSHA256,
+			FileLength:    proto.Uint64(ref.Keys.FileLength),
+		}
+	}
+	data, err := download(ctx, client, msg)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %v", err)
+	}
+	return data, nil
+}
+
+// LogConnectionEvent records a connection state change, logging rather than failing on errors
+func LogConnectionEvent(messageStore *store.MessageStore, event, detail string, logger waLog.Logger) {
+	if err := messageStore.LogConnectionEvent(event, detail); err != nil {
+		logger.Warnf("Failed to record connection event %s: %v", event, err)
+	}
+}
+
+// archiveRawMessage stores the marshaled protobuf of a message with raw's details when archive.raw_messages
+// is set, logging rather than failing on errors
+func archiveRawMessage(messageStore *store.MessageStore, raw store.RawMessage, msg *waProto.Message, logger waLog.Logger) {
+	if msg == nil || raw.ID == "" || !routing.ArchivesRawMessages(raw.ChatJID) {
+		return
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		logger.Warnf("Failed to encode raw message %s: %v", raw.ID, err)
+		return
+	}
+	raw.Data = data
+	if err := messageStore.StoreRawMessage(raw); err != nil {
+		logger.Warnf("Failed to archive raw message %s: %v", raw.ID, err)
+	}
+}
+
+// HandleMessage stores a regular incoming message
+func HandleMessage(client WhatsAppClient, messageStore *store.MessageStore, msg *events.Message, logger waLog.Logger) {
+	// Extract basic message information
+	chatJID := msg.Info.Chat.String()
+	sender := msg.Info.Sender.String()
+	isFromMe := msg.Info.IsFromMe
+
+	// Replies and reactions to forwarded messages acknowledge them
+	acknowledgeAnnouncement(messageStore, msg, logger)
+
+	// Skip processing for non-monitored groups
+	if msg.Info.IsGroup && !routing.IsKindergartenGroup(chatJID) {
+		logger.Infof("Skipping message from non-monitored group: %s", chatJID)
+		return
+	}
+
+	// Skip processing for non-monitored channels
+	if routing.IsChannelJID(msg.Info.Chat) && !routing.IsMonitoredChannel(chatJID) {
+		logger.Infof("Skipping message from non-monitored channel: %s", chatJID)
+		return
+	}
+
+	// The owner asks the bridge for things in their chat with themselves
+	if runCommand(client, msg, logger) {
+		return
+	}
+
+	// Tell people writing to the bridge's number whom to contact instead, if configured
+	answerAutomatically(client, messageStore, msg, logger)
+
+	// Messages of isolated pipelines go to their own database
+	messageStore, err := routing.ChatStore(messageStore, chatJID)
+	if err != nil {
+		logger.Errorf("Failed to open the message store of %s: %v", chatJID, err)
+		return
+	}
+
+	// Keep the message as received, before anything is parsed out of it, if configured
+	raw := msg.RawMessage
+	if raw == nil {
+		raw = msg.Message
+	}
+	archiveRawMessage(messageStore, store.RawMessage{
+		ID:        msg.Info.ID,
+		ChatJID:   chatJID,
+		Sender:    sender,
+		PushName:  msg.Info.PushName,
+		Timestamp: msg.Info.Timestamp,
+		IsFromMe:  isFromMe,
+	}, raw, logger)
+
+	// Reactions are counted for statistics rather than stored as messages
+	if reaction := msg.Message.GetReactionMessage(); reaction != nil {
+		if err := messageStore.StoreReaction(reaction.GetKey().GetID(), chatJID, sender, reaction.GetText(), msg.Info.Timestamp); err != nil {
+			logger.Warnf("Failed to store reaction to %s: %v", reaction.GetKey().GetID(), err)
+		}
+		return
+	}
+
+	// Trace the message from arrival to storage; delivery delay is the time WhatsApp took to hand it over
+	ctx, span := tracing.StartSpan(ShutdownContext(), "message.receive", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttr("chat.jid", chatJID)
+	span.SetAttr("message.id", msg.Info.ID)
+	span.SetAttr("message.delivery_delay_ms", time.Since(msg.Info.Timestamp).Milliseconds())
+
+	// Extract message text and media, applying privacy redaction before anything is stored
+	text := routing.RedactContent(chatJID, extractTextContent(msg.Message))
+
+	// Score probable spam before photos are handed on for forwarding
+	var verdict routing.SpamVerdict
+	if !isFromMe && routing.IsMonitored(chatJID) {
+		verdict = routing.ScoreSpam(messageStore, routing.SpamMessage{
+			ID:          msg.Info.ID,
+			ChatJID:     chatJID,
+			Sender:      sender,
+			Text:        text,
+			Timestamp:   msg.Info.Timestamp,
+			KnownSender: chatDisplayName(client, msg.Info.Sender.ToNonAD()) != "",
+		})
+		if verdict.Spam {
+			logger.Infof("[SPAM] Message %s from %s in %s scored %d (%s), it won't be forwarded", msg.Info.ID, sender, chatJID, verdict.Score, strings.Join(verdict.Reasons, ", "))
+		}
+	}
+
+	mediaCtx, mediaSpan := tracing.StartSpan(ctx, "media.download", tracing.SpanKindClient)
+	// A pipeline may only match photos of some senders, such as the teachers
+	forward := !verdict.Spam
+	if forward && msg.Message.GetImageMessage() != nil && !config.Current().AcceptsPhotoFrom(chatJID, sender) {
+		logger.Infof("[PIPELINE] Photo %s from %s in %s won't be matched, the sender isn't among pipeline %q's senders", msg.Info.ID, sender, chatJID, config.Current().PipelineOf(chatJID))
+		forward = false
+	}
+	imageURL, thumbnailURL, mediaType, posterPath, err := extractMediaContent(mediaCtx, client, msg.Message, chatJID, false, forward, msg.Info.Timestamp)
+	mediaSpan.SetAttr("media.type", mediaType)
+	mediaSpan.RecordError(err)
+	mediaSpan.End()
+	if err != nil {
+		logger.Warnf("Failed to process media: %v", err)
+	}
+
+	// Look for forms to fill in among photos and documents, also those sent without a caption
+	if !isFromMe && !verdict.Spam && routing.IsMonitored(chatJID) {
+		form := forms.Message{
+			ID:         msg.Info.ID,
+			ChatJID:    chatJID,
+			Sender:     sender,
+			SenderName: SenderName(client, msg.Info.Chat, msg.Info.Sender, msg.Info.PushName),
+			Text:       text,
+			MediaType:  mediaType,
+			MediaPath:  imageURL,
+			Timestamp:  msg.Info.Timestamp,
+		}
+		if document := unwrapMessage(msg.Message).GetDocumentMessage(); document != nil {
+			form.MediaType, form.MimeType, form.FileName = "document", document.GetMimetype(), document.GetFileName()
+		}
+		forms.Record(form, logger)
+	}
+
+	// Skip empty messages (no text and no media)
+	if text == "" && imageURL == "" {
+		return
+	}
+	content, details := mediaDetails(msg.Message, text, imageURL)
+
+	// Get chat name if possible
+	name := msg.Info.Chat.User
+	if routing.IsChannelJID(msg.Info.Chat) {
+		name = ChannelName(msg.Info.Chat)
+	} else if contactName := chatDisplayName(client, msg.Info.Chat); contactName != "" {
+		name = contactName
+	}
+
+	// Store the message with its chat, media details, reply context and sender name in one go. Exports
+	// and forwards show the sender by name rather than by phone number.
+	_, dbSpan := tracing.StartSpan(ctx, "db.store_message", tracing.SpanKindClient)
+	incoming := store.IncomingMessage{
+		ID:           msg.Info.ID,
+		ChatJID:      chatJID,
+		ChatName:     name,
+		Sender:       sender,
+		SenderName:   SenderName(client, msg.Info.Chat, msg.Info.Sender, msg.Info.PushName),
+		Content:      content,
+		Timestamp:    msg.Info.Timestamp,
+		IsFromMe:     isFromMe,
+		ImageURL:     imageURL,
+		ThumbnailURL: thumbnailURL,
+		MediaType:    mediaType,
+		PosterPath:   posterPath,
+		Reply:        replyContextFromMessage(chatJID, msg.Message),
+		Spam:         verdict.Spam,
+		SpamReasons:  strings.Join(verdict.Reasons, ","),
+	}
+	if imageURL != "" {
+		// Keep the media keys so the file can be downloaded again if it goes missing
+		incoming.MediaKeys = mediaKeysFromMessage(msg.Message)
+		incoming.Details = details
+	}
+	if err := messageStore.StoreIncoming(incoming); err != nil {
+		logger.Errorf("Failed to store message: %v", err)
+		dbSpan.RecordError(err)
+		dbSpan.End()
+		return
+	}
+	if imageURL != "" {
+		tracing.RememberMediaTrace(ctx, imageURL)
+	}
+	dbSpan.End()
+
+	// Archive any links shared in the message
+	links.Archive(messageStore, msg.Info.ID, chatJID, sender, text, msg.Info.Timestamp, logger)
+
+	// Look for announced events (trips, parties, meetings) for the calendar feed
+	if !verdict.Spam {
+		calendar.DetectEvents(messageStore, msg.Info.ID, chatJID, text, msg.Info.Timestamp, logger)
+	}
+
+	// Tag the message with its topics and send it on to the chats following them
+	topics.Tag(messageStore, topics.Message{
+		ID:         msg.Info.ID,
+		ChatJID:    chatJID,
+		ChatName:   name,
+		Sender:     sender,
+		SenderName: incoming.SenderName,
+		Text:       text,
+		MediaType:  mediaType,
+		MediaPath:  imageURL,
+		Forward:    !isFromMe && !verdict.Spam && routing.IsMonitored(chatJID),
+	}, logger)
+
+	// Keep track of requests for money until they are paid
+	if !isFromMe && !verdict.Spam && routing.IsMonitored(chatJID) {
+		payments.Record(payments.Message{
+			ID:         msg.Info.ID,
+			ChatJID:    chatJID,
+			Sender:     sender,
+			SenderName: incoming.SenderName,
+			Text:       text,
+			Timestamp:  msg.Info.Timestamp,
+		}, logger)
+	}
+
+	// Keep monitored chats out of the phone's chat list if configured
+	archiveAfterStore(client, messageStore, msg)
+
+	// Log successful message storage
+	direction := "←"
+	if isFromMe {
+		direction = "→"
+	}
+
+	mediaInfo := ""
+	if mediaType != "" {
+		mediaInfo = fmt.Sprintf(" [%s: %s]", mediaType, imageURL)
+	}
+
+	logger.Infof("Stored message: [%s] %s %s (%s): %s%s",
+		msg.Info.Timestamp.Format("2006-01-02 15:04:05"),
+		direction, sender, incoming.SenderName, text, mediaInfo)
+}
+
+// historyProgressInterval is how often progress of a running history sync is logged
+const historyProgressInterval = 10 * time.Second
+
+// HandleHistorySync stores the messages of a history sync event. Conversations are handed to a bounded pool
+// of workers and released as soon as they are stored, so memory stays flat for accounts with years of history.
+func HandleHistorySync(client *whatsmeow.Client, messageStore *store.MessageStore, historySync *events.HistorySync, logger waLog.Logger) {
+	conversations := historySync.Data.Conversations
+	total := len(conversations)
+	fmt.Printf("Received history sync event with %d conversations\n", total)
+	ctx, span := tracing.StartSpan(ShutdownContext(), "history.sync", tracing.SpanKindInternal)
+	span.SetAttr("history.conversations", total)
+	defer span.End()
+	historyPending.Add(int64(total))
+
+	workers := config.Current().History.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var synced, done atomic.Int64
+	jobs := make(chan *waHistorySync.Conversation)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conversation := range jobs {
+				func() {
+					defer crash.Recover("history sync of " + conversation.GetID())
+					synced.Add(int64(storeHistoryConversation(ctx, client, messageStore, conversation, logger)))
+				}()
+				done.Add(1)
+				historyPending.Add(-1)
+			}
+		}()
+	}
+
+	// Log progress with the heap size so memory use of big syncs can be watched
+	finished := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(historyProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				var mem runtime.MemStats
+				runtime.ReadMemStats(&mem)
+				fmt.Printf("[HISTORY] %d/%d conversations, %d messages stored, heap %d MB\n",
+					done.Load(), total, synced.Load(), mem.HeapAlloc/(1<<20))
+			case <-finished:
+				return
+			}
+		}
+	}()
+
+	for i, conversation := range conversations {
+		// Drop the reference so each conversation can be collected once its worker is done with it
+		conversations[i] = nil
+		if conversation != nil {
+			jobs <- conversation
+		} else {
+			historyPending.Add(-1)
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(finished)
+	historySync.Data.Conversations = nil
+
+	fmt.Printf("History sync complete. Stored %d text messages.\n", synced.Load())
+	span.SetAttr("history.stored", synced.Load())
+}
+
+// storeHistoryConversation stores the messages of one conversation from a history sync and returns how many
+// were stored
+func storeHistoryConversation(ctx context.Context, client *whatsmeow.Client, messageStore *store.MessageStore, conversation *waHistorySync.Conversation, logger waLog.Logger) int {
+	// Parse JID from the conversation
+	if conversation.ID == nil {
+		return 0
+	}
+
+	chatJID := *conversation.ID
+
+	// Try to parse the JID
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		logger.Warnf("Failed to parse JID %s: %v", chatJID, err)
+		return 0
+	}
+	messageStore, err = routing.ChatStore(messageStore, chatJID)
+	if err != nil {
+		logger.Errorf("Failed to open the message store of %s: %v", chatJID, err)
+		return 0
+	}
+
+	// Get contact name
+	name := jid.User
+	contact, err := client.Store.Contacts.GetContact(jid)
+	if err == nil && contact.FullName != "" {
+		name = contact.FullName
+	}
+
+	// Process messages
+	messages := conversation.Messages
+	if len(messages) == 0 {
+		return 0
+	}
+
+	// Conversations start with their latest message
+	latestMsg := messages[0]
+	if latestMsg == nil || latestMsg.Message == nil {
+		return 0
+	}
+
+	if latestMsg.Message.GetMessageTimestamp() == 0 {
+		return 0
+	}
+
+	// Store messages, releasing each one once it is stored. Photos older than a few minutes are only
+	// downloaded if history.download_media is set.
+	downloadMedia := config.Current().History.DownloadMedia
+	synced := 0
+	for i, msg := range messages {
+		messages[i] = nil
+		if msg == nil || msg.Message == nil {
+			continue
+		}
+
+		// Extract text content
+		var text string
+		if msg.Message.Message != nil {
+			text = routing.RedactContent(chatJID, extractTextContent(msg.Message.Message))
+		}
+
+		// Determine sender
+		var sender string
+		isFromMe := false
+		if msg.Message.Key != nil {
+			if msg.Message.Key.FromMe != nil {
+				isFromMe = *msg.Message.Key.FromMe
+			}
+			if !isFromMe && msg.Message.Key.Participant != nil && *msg.Message.Key.Participant != "" {
+				sender = *msg.Message.Key.Participant
+			} else if isFromMe {
+				sender = client.Store.ID.User
+			} else {
+				sender = jid.User
+			}
+		} else {
+			sender = jid.User
+		}
+
+		// Store message
+		msgID := ""
+		if msg.Message.Key != nil && msg.Message.Key.ID != nil {
+			msgID = *msg.Message.Key.ID
+		}
+
+		// Get message timestamp
+		timestamp := time.Time{}
+		if ts := msg.Message.GetMessageTimestamp(); ts != 0 {
+			timestamp = time.Unix(int64(ts), 0)
+		} else {
+			continue
+		}
+		senderJID := types.NewJID(sender, types.DefaultUserServer)
+		if strings.Contains(sender, "@") {
+			senderJID, _ = types.ParseJID(sender)
+		}
+
+		// Photos go through the same spam and sender checks as live ones before they are handed on
+		var verdict routing.SpamVerdict
+		if !isFromMe && routing.IsMonitored(chatJID) {
+			verdict = routing.ScoreSpam(messageStore, routing.SpamMessage{
+				ID:          msgID,
+				ChatJID:     chatJID,
+				Sender:      sender,
+				Text:        text,
+				Timestamp:   timestamp,
+				KnownSender: chatDisplayName(client, senderJID.ToNonAD()) != "",
+			})
+		}
+		forward := !verdict.Spam
+		if forward && msg.Message.GetMessage().GetImageMessage() != nil && !config.Current().AcceptsPhotoFrom(chatJID, sender) {
+			forward = false
+		}
+
+		// Extract media content
+		imageURL, thumbnailURL, mediaType, posterPath := "", "", "", ""
+		var downloadErr error
+		if msg.Message.Message != nil {
+			imageURL, thumbnailURL, mediaType, posterPath, downloadErr = extractMediaContent(ctx, client, msg.Message.Message, chatJID, downloadMedia, forward, timestamp)
+			if downloadErr != nil {
+				logger.Warnf("Failed to process media: %v", downloadErr)
+			}
+		}
+
+		archiveRawMessage(messageStore, store.RawMessage{
+			ID:        msgID,
+			ChatJID:   chatJID,
+			Sender:    sender,
+			PushName:  msg.Message.GetPushName(),
+			Timestamp: timestamp,
+			IsFromMe:  isFromMe,
+		}, msg.Message.Message, logger)
+
+		// Skip empty messages (no text and no media)
+		if text == "" && imageURL == "" {
+			continue
+		}
+		content, details := mediaDetails(msg.Message.Message, text, imageURL)
+
+		incoming := store.IncomingMessage{
+			ID:           msgID,
+			ChatJID:      chatJID,
+			ChatName:     name,
+			Sender:       sender,
+			SenderName:   SenderName(client, jid, senderJID, msg.Message.GetPushName()),
+			Content:      content,
+			Timestamp:    timestamp,
+			IsFromMe:     isFromMe,
+			ImageURL:     imageURL,
+			ThumbnailURL: thumbnailURL,
+			MediaType:    mediaType,
+			PosterPath:   posterPath,
+			Reply:        replyContextFromMessage(chatJID, msg.Message.Message),
+			Spam:         verdict.Spam,
+			SpamReasons:  strings.Join(verdict.Reasons, ","),
+		}
+		if imageURL != "" {
+			incoming.MediaKeys = mediaKeysFromMessage(msg.Message.Message)
+			incoming.Details = details
+		}
+		if err := messageStore.StoreIncoming(incoming); err != nil {
+			logger.Warnf("Failed to store history message: %v", err)
+			continue
+		}
+		synced++
+		links.Archive(messageStore, msgID, chatJID, sender, text, timestamp, logger)
+		calendar.DetectEvents(messageStore, msgID, chatJID, text, timestamp, logger)
+		// Log successful message storage
+		logger.Infof("Stored message: [%s] %s -> %s: %s", timestamp.Format("2006-01-02 15:04:05"), sender, chatJID, text)
+	}
+	return synced
+}
+
+// RequestHistorySync asks the server for older messages
+func RequestHistorySync(client *whatsmeow.Client) {
+	if client == nil {
+		fmt.Println("Client is not initialized. Cannot request history sync.")
+		return
+	}
+
+	if !client.IsConnected() {
+		fmt.Println("Client is not connected. Please ensure you are connected to WhatsApp first.")
+		return
+	}
+
+	if client.Store.ID == nil {
+		fmt.Println("Client is not logged in. Please scan the QR code first.")
+		return
+	}
+
+	// Build and send a history sync request
+	historyMsg := client.BuildHistorySyncRequest(nil, 100)
+	if historyMsg == nil {
+		fmt.Println("Failed to build history sync request.")
+		return
+	}
+
+	ctx, cancel := SendTimeout(ShutdownContext())
+	defer cancel()
+	_, err := client.SendMessage(ctx, types.JID{
+		Server: "s.whatsapp.net",
+		User:   "status",
+	}, historyMsg)
+
+	if err != nil {
+		fmt.Printf("Failed to request history sync: %v\n", err)
+	} else {
+		fmt.Println("History sync requested. Waiting for server response...")
+	}
+}
@@ -0,0 +1,143 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/store"
+)
+
+// pingTimeout is how long WhatsApp has to answer a liveness ping
+const pingTimeout = 20 * time.Second
+
+// livenessDisabledPoll is how often a turned off liveness check looks whether it was turned on again
+const livenessDisabledPoll = time.Minute
+
+// LivenessStatus is the outcome of the bridge's own pings to WhatsApp, served in /api/status
+type LivenessStatus struct {
+	LastPingAt    *time.Time `json:"last_ping_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	// Round trip of the last answered ping, in milliseconds
+	RoundTripMillis int64 `json:"round_trip_ms"`
+	// Pings that went unanswered since the last answered one
+	Failures int `json:"failures"`
+	// Reconnects forced because pings failed while the connection looked up
+	Reconnects int `json:"reconnects"`
+}
+
+var liveness struct {
+	sync.Mutex
+	status LivenessStatus
+}
+
+// Liveness returns the outcome of the latest liveness pings
+func Liveness() LivenessStatus {
+	liveness.Lock()
+	defer liveness.Unlock()
+	return liveness.status
+}
+
+// WatchLiveness pings WhatsApp with a small info query every liveness.interval_seconds while the
+// connection looks up, and reconnects once no ping was answered for liveness.failure_window_seconds.
+// whatsmeow's keepalive gives up on a connection only when its own pings fail, which misses a socket
+// that is open but no longer serves requests. Runs until the bridge shuts down.
+func WatchLiveness(client *whatsmeow.Client, messageStore *store.MessageStore, logger waLog.Logger) {
+	// When the last ping was answered, or the connection came up, whichever is later
+	var healthySince time.Time
+	// Whether the bridge dropped the connection itself and has to bring it back
+	reconnecting := false
+	for {
+		cfg := config.Current().Liveness
+		wait := time.Duration(cfg.IntervalSeconds) * time.Second
+		if cfg.IntervalSeconds < 0 {
+			wait = livenessDisabledPoll
+		}
+		select {
+		case <-time.After(wait):
+		case <-ShutdownContext().Done():
+			return
+		}
+		if cfg.IntervalSeconds < 0 {
+			healthySince = time.Time{}
+			continue
+		}
+
+		if !client.IsConnected() {
+			// A dropped connection is whatsmeow's to bring back, unless the bridge dropped it
+			healthySince = time.Time{}
+			if reconnecting {
+				if err := client.Connect(); err != nil {
+					logger.Errorf("[LIVENESS] Failed to reconnect: %v", err)
+					continue
+				}
+				reconnecting = false
+			}
+			continue
+		}
+		reconnecting = false
+		if healthySince.IsZero() {
+			healthySince = time.Now()
+		}
+
+		roundTrip, err := ping(client)
+		now := time.Now()
+		liveness.Lock()
+		liveness.status.LastPingAt = &now
+		if err == nil {
+			if liveness.status.Failures > 0 {
+				logger.Infof("[LIVENESS] WhatsApp answers again after %d failed pings", liveness.status.Failures)
+			}
+			liveness.status.LastSuccessAt = &now
+			liveness.status.RoundTripMillis = roundTrip.Milliseconds()
+			liveness.status.Failures = 0
+		} else {
+			liveness.status.Failures++
+		}
+		failures := liveness.status.Failures
+		liveness.Unlock()
+
+		if err == nil {
+			healthySince = now
+			continue
+		}
+		logger.Warnf("[LIVENESS] Ping %d failed although the connection looks up: %v", failures, err)
+		window := time.Duration(cfg.FailureWindowSeconds) * time.Second
+		if now.Sub(healthySince) < window {
+			continue
+		}
+
+		detail := fmt.Sprintf("%d failed pings since %s", failures, healthySince.Format(time.RFC3339))
+		logger.Warnf("[LIVENESS] Reconnecting, %s", detail)
+		LogConnectionEvent(messageStore, store.ConnEventLivenessReconnect, detail, logger)
+		liveness.Lock()
+		liveness.status.Reconnects++
+		liveness.Unlock()
+		client.Disconnect()
+		healthySince = time.Time{}
+		if err := client.Connect(); err != nil {
+			logger.Errorf("[LIVENESS] Failed to reconnect, trying again in %s: %v", wait, err)
+			reconnecting = true
+		}
+	}
+}
+
+// ping sends WhatsApp an info query and returns how long the answer took. Any answer counts, an error
+// answer too: it shows that requests reach WhatsApp and come back.
+func ping(client *whatsmeow.Client) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ShutdownContext(), pingTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetServerPushNotificationConfig(ctx)
+	var iqErr *whatsmeow.IQError
+	if err != nil && !errors.As(err, &iqErr) {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,345 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// MockSend is a message the bridge sent while running with -mock
+type MockSend struct {
+	ID        string    `json:"id"`
+	To        string    `json:"to"`
+	Message   string    `json:"message,omitempty"`
+	MediaType string    `json:"media_type,omitempty"`
+	MediaSize int       `json:"media_size,omitempty"`
+	Caption   string    `json:"caption,omitempty"`
+	Mentions  []string  `json:"mentions,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// MockMessageRequest is an incoming message injected with POST /api/mock/messages
+type MockMessageRequest struct {
+	ID       string `json:"id,omitempty"`
+	ChatJID  string `json:"chat_jid"`
+	ChatName string `json:"chat_name,omitempty"`
+	Sender   string `json:"sender,omitempty"`
+	// Name the sender chose for themselves
+	PushName string `json:"push_name,omitempty"`
+	Content  string `json:"content,omitempty"`
+	// Local photo or video file delivered as if it had been posted to the chat
+	MediaPath string `json:"media_path,omitempty"`
+	// Message the injected one replies to, with its sender and text as shown in the quote
+	QuotedID      string    `json:"quoted_id,omitempty"`
+	QuotedSender  string    `json:"quoted_sender,omitempty"`
+	QuotedContent string    `json:"quoted_content,omitempty"`
+	FromMe        bool      `json:"from_me,omitempty"`
+	Timestamp     time.Time `json:"timestamp,omitempty"`
+}
+
+// MockParticipantsRequest is a change of a group's participants or admins injected with POST
+// /api/mock/participants. Participants and the sender are JIDs or plain phone numbers.
+type MockParticipantsRequest struct {
+	ChatJID string `json:"chat_jid"`
+	// Who made the change; empty for people joining by themselves
+	Sender  string   `json:"sender,omitempty"`
+	Join    []string `json:"join,omitempty"`
+	Leave   []string `json:"leave,omitempty"`
+	Promote []string `json:"promote,omitempty"`
+	Demote  []string `json:"demote,omitempty"`
+	// "invite" for joining by link
+	JoinReason string    `json:"join_reason,omitempty"`
+	Timestamp  time.Time `json:"timestamp,omitempty"`
+}
+
+// Mock is the WhatsAppClient used with -mock: injected messages go through the normal message
+// handling and sends are captured instead of delivered
+type Mock struct {
+	mu     sync.Mutex
+	sent   []MockSend
+	media  map[string][]byte
+	names  map[string]string
+	chats  []types.JID
+	nextID int
+	// offline is set while the connection is down, see SetConnected
+	offline bool
+}
+
+// NewMock returns a fake connection without chats or captured sends
+func NewMock() *Mock {
+	return &Mock{media: make(map[string][]byte), names: make(map[string]string)}
+}
+
+// newID returns a message ID in the style of WhatsApp's
+func (m *Mock) newID() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	return fmt.Sprintf("MOCK%016X", m.nextID)
+}
+
+// IsConnected reports a working connection unless SetConnected took it down
+func (m *Mock) IsConnected() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.offline
+}
+
+// SetConnected brings the fake connection up or down; while it is down sends fail as they would
+// without a connection
+func (m *Mock) SetConnected(connected bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offline = !connected
+}
+
+// Upload keeps the media so the captured send can be inspected and downloaded again
+func (m *Mock) Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	directPath := "/mock/upload/" + m.newID()
+	m.mu.Lock()
+	m.media[directPath] = plaintext
+	m.mu.Unlock()
+	return whatsmeow.UploadResponse{
+		URL:        "https://mock.invalid" + directPath,
+		DirectPath: directPath,
+		FileLength: uint64(len(plaintext)),
+	}, nil
+}
+
+// SendMessage captures an outgoing message
+func (m *Mock) SendMessage(ctx context.Context, to types.JID, message *waProto.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error) {
+	send := MockSend{ID: m.newID(), To: to.String(), Timestamp: time.Now()}
+	var contextInfo *waProto.ContextInfo
+	switch {
+	case message.GetImageMessage() != nil:
+		image := message.GetImageMessage()
+		send.MediaType, send.MediaSize, send.Caption = "image", int(image.GetFileLength()), image.GetCaption()
+		contextInfo = image.GetContextInfo()
+	case message.GetVideoMessage() != nil:
+		video := message.GetVideoMessage()
+		send.MediaType, send.MediaSize, send.Caption = "video", int(video.GetFileLength()), video.GetCaption()
+		contextInfo = video.GetContextInfo()
+	case message.GetExtendedTextMessage() != nil:
+		send.Message = message.GetExtendedTextMessage().GetText()
+		contextInfo = message.GetExtendedTextMessage().GetContextInfo()
+	default:
+		send.Message = message.GetConversation()
+	}
+	send.Mentions = contextInfo.GetMentionedJID()
+
+	m.mu.Lock()
+	m.sent = append(m.sent, send)
+	m.mu.Unlock()
+	fmt.Printf("[MOCK] Captured message %s to %s\n", send.ID, send.To)
+	return whatsmeow.SendResponse{ID: send.ID, Timestamp: send.Timestamp}, nil
+}
+
+// GetJoinedGroups lists the groups messages were injected into
+func (m *Mock) GetJoinedGroups() ([]*types.GroupInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var groups []*types.GroupInfo
+	for _, jid := range m.chats {
+		if jid.Server == types.GroupServer {
+			groups = append(groups, &types.GroupInfo{JID: jid, GroupName: types.GroupName{Name: m.names[jid.String()]}})
+		}
+	}
+	return groups, nil
+}
+
+// Sent returns the captured outgoing messages
+func (m *Mock) Sent() []MockSend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockSend{}, m.sent...)
+}
+
+// Reset forgets the captured outgoing messages
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
+
+// Download returns the data of an injected or uploaded file
+func (m *Mock) Download(msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.media[msg.GetDirectPath()]
+	if !ok {
+		return nil, fmt.Errorf("no injected media at %s", msg.GetDirectPath())
+	}
+	return data, nil
+}
+
+// ChatName returns the name given to an injected chat
+func (m *Mock) ChatName(jid types.JID) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names[jid.String()]
+}
+
+// Inject builds a message event from req as WhatsApp would deliver it
+func (m *Mock) Inject(req MockMessageRequest) (*events.Message, error) {
+	chat, err := types.ParseJID(req.ChatJID)
+	if err != nil || chat.User == "" || chat.Server == "" {
+		return nil, fmt.Errorf("invalid chat_jid %q", req.ChatJID)
+	}
+	NoteEvent()
+	sender := chat
+	if req.Sender != "" {
+		// Plain phone numbers are taken as user JIDs
+		if !strings.Contains(req.Sender, "@") {
+			sender = types.NewJID(req.Sender, types.DefaultUserServer)
+		} else if sender, err = types.ParseJID(req.Sender); err != nil {
+			return nil, fmt.Errorf("invalid sender %q", req.Sender)
+		}
+	}
+	if req.ID == "" {
+		req.ID = m.newID()
+	}
+	if req.Timestamp.IsZero() {
+		req.Timestamp = time.Now()
+	}
+
+	var reply *waProto.ContextInfo
+	if req.QuotedID != "" {
+		reply = &waProto.ContextInfo{
+			StanzaID:      proto.String(req.QuotedID),
+			Participant:   proto.String(req.QuotedSender),
+			QuotedMessage: &waProto.Message{Conversation: proto.String(req.QuotedContent)},
+		}
+	}
+
+	msg := &waProto.Message{}
+	if req.MediaPath != "" {
+		data, err := os.ReadFile(req.MediaPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read media: %v", err)
+		}
+		directPath := "/mock/" + req.ID
+		m.mu.Lock()
+		m.media[directPath] = data
+		m.mu.Unlock()
+		mimeType := http.DetectContentType(data)
+		if strings.HasPrefix(mimeType, "video/") {
+			msg.VideoMessage = &waProto.VideoMessage{
+				DirectPath:  proto.String(directPath),
+				MediaKey:    []byte(req.ID),
+				Mimetype:    proto.String(mimeType),
+				Caption:     proto.String(req.Content),
+				FileLength:  proto.Uint64(uint64(len(data))),
+				ContextInfo: reply,
+			}
+		} else {
+			msg.ImageMessage = &waProto.ImageMessage{
+				DirectPath:  proto.String(directPath),
+				MediaKey:    []byte(req.ID),
+				Mimetype:    proto.String(mimeType),
+				Caption:     proto.String(req.Content),
+				FileLength:  proto.Uint64(uint64(len(data))),
+				ContextInfo: reply,
+			}
+		}
+	} else if req.Content != "" && reply != nil {
+		msg.ExtendedTextMessage = &waProto.ExtendedTextMessage{Text: proto.String(req.Content), ContextInfo: reply}
+	} else if req.Content != "" {
+		msg.Conversation = proto.String(req.Content)
+	} else {
+		return nil, fmt.Errorf("content or media_path is required")
+	}
+
+	m.mu.Lock()
+	if _, known := m.names[chat.String()]; !known {
+		m.chats = append(m.chats, chat)
+		m.names[chat.String()] = ""
+	}
+	if req.ChatName != "" {
+		m.names[chat.String()] = req.ChatName
+	}
+	m.mu.Unlock()
+
+	return &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Chat:     chat,
+				Sender:   sender,
+				IsFromMe: req.FromMe,
+				IsGroup:  chat.Server == types.GroupServer,
+			},
+			ID:        req.ID,
+			PushName:  req.PushName,
+			Timestamp: req.Timestamp,
+		},
+		Message: msg,
+	}, nil
+}
+
+// InjectParticipants builds a group info event from req as WhatsApp would deliver it
+func (m *Mock) InjectParticipants(req MockParticipantsRequest) (*events.GroupInfo, error) {
+	chat, err := types.ParseJID(req.ChatJID)
+	if err != nil || chat.Server != types.GroupServer {
+		return nil, fmt.Errorf("invalid group chat_jid %q", req.ChatJID)
+	}
+	NoteEvent()
+	parse := func(participants []string) ([]types.JID, error) {
+		var jids []types.JID
+		for _, participant := range participants {
+			jid, err := mockUserJID(participant)
+			if err != nil {
+				return nil, err
+			}
+			jids = append(jids, jid)
+		}
+		return jids, nil
+	}
+	evt := &events.GroupInfo{JID: chat, JoinReason: req.JoinReason, Timestamp: req.Timestamp}
+	if evt.Join, err = parse(req.Join); err != nil {
+		return nil, err
+	}
+	if evt.Leave, err = parse(req.Leave); err != nil {
+		return nil, err
+	}
+	if evt.Promote, err = parse(req.Promote); err != nil {
+		return nil, err
+	}
+	if evt.Demote, err = parse(req.Demote); err != nil {
+		return nil, err
+	}
+	if len(evt.Join) == 0 && len(evt.Leave) == 0 && len(evt.Promote) == 0 && len(evt.Demote) == 0 {
+		return nil, fmt.Errorf("join, leave, promote or demote is required")
+	}
+	if req.Sender != "" {
+		sender, err := mockUserJID(req.Sender)
+		if err != nil {
+			return nil, err
+		}
+		evt.Sender = &sender
+	}
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+	return evt, nil
+}
+
+// mockUserJID parses a user JID, taking plain phone numbers as user JIDs
+func mockUserJID(user string) (types.JID, error) {
+	if !strings.Contains(user, "@") {
+		return types.NewJID(strings.TrimPrefix(user, "+"), types.DefaultUserServer), nil
+	}
+	jid, err := types.ParseJID(user)
+	if err != nil || jid.User == "" {
+		return types.JID{}, fmt.Errorf("invalid participant %q", user)
+	}
+	return jid, nil
+}
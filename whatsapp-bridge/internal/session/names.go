@@ -0,0 +1,111 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/store"
+)
+
+// participantNameTTL is how long the participant names of a group are reused before asking again
+const participantNameTTL = time.Hour
+
+// participantNames caches the display names WhatsApp gives group participants, per group
+var participantNames = struct {
+	sync.Mutex
+	groups map[types.JID]participantNameEntry
+}{groups: make(map[types.JID]participantNameEntry)}
+
+type participantNameEntry struct {
+	names   map[types.JID]string
+	fetched time.Time
+}
+
+// groupParticipantName returns the name a group shows for a participant, if it has one
+func groupParticipantName(client *whatsmeow.Client, chat, sender types.JID) string {
+	participantNames.Lock()
+	entry, ok := participantNames.groups[chat]
+	participantNames.Unlock()
+
+	if !ok || time.Since(entry.fetched) > participantNameTTL {
+		entry = participantNameEntry{names: make(map[types.JID]string), fetched: time.Now()}
+		if info, err := client.GetGroupInfo(chat); err == nil {
+			for _, p := range info.Participants {
+				if p.DisplayName != "" {
+					entry.names[p.JID.ToNonAD()] = p.DisplayName
+				}
+			}
+		}
+		participantNames.Lock()
+		participantNames.groups[chat] = entry
+		participantNames.Unlock()
+	}
+	return entry.names[sender.ToNonAD()]
+}
+
+// SenderName resolves the best available name for a sender: their name in the address book, the push
+// name they chose, the name the group shows for them, and finally their phone number
+func SenderName(client WhatsAppClient, chat, sender types.JID, pushName string) string {
+	switch c := client.(type) {
+	case *whatsmeow.Client:
+		contact, err := c.Store.Contacts.GetContact(sender.ToNonAD())
+		if err == nil && contact.FullName != "" {
+			return contact.FullName
+		}
+		if pushName == "" && err == nil {
+			pushName = contact.PushName
+		}
+		if pushName != "" {
+			return pushName
+		}
+		if chat.Server == types.GroupServer {
+			if name := groupParticipantName(c, chat, sender); name != "" {
+				return name
+			}
+		}
+	case *Mock:
+		if pushName != "" {
+			return pushName
+		}
+	}
+	if sender.User == "" {
+		return ""
+	}
+	return "+" + sender.User
+}
+
+// HandlePushName updates stored sender names when someone changes their push name, unless they are
+// known by their address book name
+func HandlePushName(client *whatsmeow.Client, messageStore *store.MessageStore, evt *events.PushName, logger waLog.Logger) {
+	if contact, err := client.Store.Contacts.GetContact(evt.JID.ToNonAD()); err == nil && contact.FullName != "" {
+		return
+	}
+	updateSenderName(messageStore, evt.JID, evt.NewPushName, logger)
+}
+
+// HandleContact updates stored sender names when a contact is renamed in the address book
+func HandleContact(messageStore *store.MessageStore, evt *events.Contact, logger waLog.Logger) {
+	if name := evt.Action.GetFullName(); name != "" {
+		updateSenderName(messageStore, evt.JID, name, logger)
+	}
+}
+
+// updateSenderName renames a sender in all stored messages
+func updateSenderName(messageStore *store.MessageStore, jid types.JID, name string, logger waLog.Logger) {
+	if name == "" {
+		return
+	}
+	updated, err := messageStore.UpdateSenderName(jid.ToNonAD().String(), jid.User, name)
+	if err != nil {
+		logger.Warnf("Failed to update sender name of %s: %v", jid, err)
+		return
+	}
+	if updated > 0 {
+		logger.Infof("Updated sender name of %s to %q in %d messages", jid.User, name, updated)
+	}
+}
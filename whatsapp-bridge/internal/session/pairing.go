@@ -0,0 +1,164 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mdp/qrterminal"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+)
+
+// Errors of CurrentQR
+var (
+	// ErrNoQR means no pairing is in progress
+	ErrNoQR = errors.New("no pairing in progress")
+	// ErrQRPending means the bridge is pairing but waiting for WhatsApp to issue a code
+	ErrQRPending = errors.New("waiting for a QR code")
+)
+
+// pairing holds the QR code waiting to be scanned, so it can be served over HTTP as well as printed
+var pairing struct {
+	sync.Mutex
+	active bool
+	code   string
+}
+
+// CurrentQR returns the QR code to scan to pair the bridge, ErrNoQR if it is paired, or ErrQRPending
+// between codes
+func CurrentQR() (string, error) {
+	pairing.Lock()
+	defer pairing.Unlock()
+	if !pairing.active {
+		return "", ErrNoQR
+	}
+	if pairing.code == "" {
+		return "", ErrQRPending
+	}
+	return pairing.code, nil
+}
+
+// setQR replaces the current QR code; "" while a new one is requested
+func setQR(code string) {
+	pairing.Lock()
+	pairing.code = code
+	pairing.Unlock()
+}
+
+// setPairing marks pairing as started or ended
+func setPairing(active bool) {
+	pairing.Lock()
+	pairing.active, pairing.code = active, ""
+	pairing.Unlock()
+}
+
+// Pair connects a client without a stored session and shows QR codes until the phone scans one. When
+// WhatsApp stops issuing codes it reconnects for new ones, so the QR code stays available in the terminal
+// and over HTTP. Returns an error if timeout passes first; 0 waits until a code is scanned or shutdown.
+func Pair(client *whatsmeow.Client, timeout time.Duration) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	setPairing(true)
+	defer setPairing(false)
+	delay := pairingRetryDelay
+	for {
+		err := pairOnce(client, deadline)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errPairingTimeout) || ShutdownContext().Err() != nil {
+			return err
+		}
+		fmt.Printf("[AUTH] %v, requesting new QR codes in %s\n", err, delay)
+		select {
+		case <-time.After(delay):
+			// Back off while WhatsApp can't be reached
+			delay = min(delay*2, maxPairingRetryDelay)
+		case <-deadline:
+			return errPairingTimeout
+		case <-ShutdownContext().Done():
+			return ShutdownContext().Err()
+		}
+	}
+}
+
+// Pauses before asking for new QR codes, growing while attempts keep failing
+const (
+	pairingRetryDelay    = 2 * time.Second
+	maxPairingRetryDelay = time.Minute
+)
+
+// errPairingTimeout is returned by Pair when no QR code was scanned within its timeout
+var errPairingTimeout = errors.New("timeout waiting for QR code scan")
+
+// pairOnce connects and shows the QR codes of one pairing attempt, until one is scanned, WhatsApp
+// stops issuing codes or deadline passes
+func pairOnce(client *whatsmeow.Client, deadline <-chan time.Time) error {
+	qrChan, err := client.GetQRChannel(ShutdownContext())
+	if err != nil {
+		return err
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+
+	for {
+		select {
+		case evt, ok := <-qrChan:
+			if !ok {
+				return fmt.Errorf("pairing stopped")
+			}
+			switch evt.Event {
+			case whatsmeow.QRChannelEventCode:
+				setQR(evt.Code)
+				fmt.Println("\nScan this QR code with your WhatsApp app:")
+				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+			case whatsmeow.QRChannelSuccess.Event:
+				return nil
+			case whatsmeow.QRChannelTimeout.Event:
+				setQR("")
+				client.Disconnect()
+				return fmt.Errorf("no QR code was scanned in time")
+			default:
+				setQR("")
+				client.Disconnect()
+				return fmt.Errorf("pairing failed: %s %v", evt.Event, evt.Error)
+			}
+		case <-deadline:
+			client.Disconnect()
+			return errPairingTimeout
+		}
+	}
+}
+
+// Logout unlinks the bridge from the WhatsApp account and deletes the session from the device store.
+// The client then waits to be paired again, with the QR code available from CurrentQR.
+func Logout(client WhatsAppClient) error {
+	c, ok := client.(*whatsmeow.Client)
+	if !ok {
+		return fmt.Errorf("logging out needs a WhatsApp connection")
+	}
+	container, ok := c.Store.Container.(*sqlstore.Container)
+	if !ok {
+		return fmt.Errorf("the device store can't create a new session")
+	}
+	if err := c.Logout(); err != nil {
+		return err
+	}
+
+	// The deleted device keeps its keys in memory; pair with fresh ones
+	c.Store = container.NewDevice()
+	go func() {
+		if err := Pair(c, 0); err != nil {
+			fmt.Printf("[AUTH] Pairing stopped: %v\n", err)
+			return
+		}
+		fmt.Println("\nSuccessfully paired again")
+	}()
+	return nil
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// HandleGroupInfo records who joined, left, was promoted to admin or demoted in a monitored group, and
+// greets those who joined a group with a welcome message
+func HandleGroupInfo(client WhatsAppClient, messageStore *store.MessageStore, evt *events.GroupInfo, logger waLog.Logger) {
+	if len(evt.Join) == 0 && len(evt.Leave) == 0 && len(evt.Promote) == 0 && len(evt.Demote) == 0 {
+		return
+	}
+	chatJID := evt.JID.String()
+	if routing.IsMonitored(chatJID) {
+		recordParticipantChanges(client, messageStore, evt, logger)
+	}
+	welcome(client, chatJID, evt.Join, logger)
+}
+
+// recordParticipantChanges stores the participant changes of evt. People another participant brings in
+// or takes out are recorded as added or removed, the others as joining or leaving.
+func recordParticipantChanges(client WhatsAppClient, messageStore *store.MessageStore, evt *events.GroupInfo, logger waLog.Logger) {
+	chatJID := evt.JID.String()
+	messageStore, err := routing.ChatStore(messageStore, chatJID)
+	if err != nil {
+		logger.Errorf("Failed to open the message store of %s: %v", chatJID, err)
+		return
+	}
+	actor := ""
+	if evt.Sender != nil {
+		actor = evt.Sender.ToNonAD().String()
+	}
+	at := evt.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	record := func(participants []types.JID, action, byOther string) {
+		for _, participant := range participants {
+			change := store.ParticipantChange{
+				ChatJID:     chatJID,
+				Participant: participant.ToNonAD().String(),
+				Name:        chatDisplayName(client, participant.ToNonAD()),
+				Action:      action,
+				Actor:       actor,
+				Reason:      evt.JoinReason,
+				ChangedAt:   at,
+			}
+			if byOther != "" && actor != "" && actor != change.Participant {
+				change.Action = byOther
+			}
+			if action != store.ParticipantJoined {
+				change.Reason = ""
+			}
+			if err := messageStore.StoreParticipantChange(change); err != nil {
+				logger.Warnf("Failed to record %s of %s in %s: %v", action, change.Participant, chatJID, err)
+			}
+		}
+	}
+	record(evt.Join, store.ParticipantJoined, store.ParticipantAdded)
+	record(evt.Leave, store.ParticipantLeft, store.ParticipantRemoved)
+	record(evt.Promote, store.ParticipantPromoted, "")
+	record(evt.Demote, store.ParticipantDemoted, "")
+	logger.Infof("[GROUPS] Participants of %s changed: %d joined, %d left, %d promoted, %d demoted", chatJID,
+		len(evt.Join), len(evt.Leave), len(evt.Promote), len(evt.Demote))
+}
+
+// welcome sends the group's welcome message, if it has one, mentioning everyone who joined but the
+// account itself. It is sent in the background, so a slow send doesn't hold up the events after it.
+func welcome(client WhatsAppClient, chatJID string, joined []types.JID, logger waLog.Logger) {
+	text := ""
+	for _, message := range config.Current().Welcome.Messages {
+		if message.ChatJID == chatJID {
+			text = message.Text
+			break
+		}
+	}
+	if text == "" {
+		return
+	}
+	own := OwnChat(client)
+	var mentions, names []string
+	for _, participant := range joined {
+		jid := participant.ToNonAD()
+		if jid.String() == own {
+			continue
+		}
+		mentions = append(mentions, jid.String())
+		names = append(names, "@"+jid.User)
+	}
+	if len(mentions) == 0 {
+		return
+	}
+	text = strings.ReplaceAll(text, "{name}", strings.Join(names, ", "))
+	if routing.IsDryRunSend(chatJID, false) {
+		fmt.Printf("[DRY-RUN] Would welcome %s in %s: %q\n", strings.Join(mentions, ", "), chatJID, text)
+		return
+	}
+	go func() {
+		defer crash.Recover("a welcome message")
+		ctx, cancel := SendTimeout(ShutdownContext())
+		defer cancel()
+		if _, err := SendMessage(ctx, client, chatJID, text, "", "", "", mentions, false); err != nil {
+			logger.Warnf("Failed to welcome %s in %s: %v", strings.Join(mentions, ", "), chatJID, err)
+			return
+		}
+		logger.Infof("[GROUPS] Welcomed %s in %s", strings.Join(mentions, ", "), chatJID)
+	}()
+}
@@ -0,0 +1,424 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/forms"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/payments"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// Run with -update to rewrite the golden files after an intended change in message handling
+var update = flag.Bool("update", false, "rewrite testdata/events/*.golden.json from the current message handling")
+
+// testConfig is the configuration fixtures are replayed with: one monitored group forwarding to one
+// destination. Fixtures change single keys with their config field.
+const testConfig = `{
+	"input_groups": ["120363000000000001@g.us"],
+	"destinations": {"noa": {"group": "120363000000000002@g.us", "name": "Noa"}},
+	"media": {"allowed_extensions": [".jpg"]}
+}`
+
+// ownJID is the account the fixtures were recorded with
+var ownJID = types.NewJID("972500000000", types.DefaultUserServer)
+
+// eventFixture is a recorded sequence of WhatsApp events, stored in testdata/events as JSON. Messages are
+// kept as they arrived on the wire, before whatsmeow unwrapped them.
+type eventFixture struct {
+	Description string `json:"description"`
+	// Time the events are replayed at; live photos older than five minutes aren't downloaded
+	Now time.Time `json:"now"`
+	// Settings changed for this fixture, as with -set key=value
+	Config map[string]string `json:"config,omitempty"`
+	// Files in testdata/media the mock serves as downloads, by direct path
+	Media  map[string]string `json:"media,omitempty"`
+	Events []recordedEvent   `json:"events"`
+}
+
+// recordedEvent is a message (info and raw, a waE2E.Message as protojson), a history sync (data, a
+// waHistorySync.HistorySync as protojson) or a delete_sender purge of everything sender posted, as
+// DELETE /api/senders/{sender} runs it
+type recordedEvent struct {
+	Type   string            `json:"type"`
+	Info   types.MessageInfo `json:"info,omitempty"`
+	Raw    json.RawMessage   `json:"raw,omitempty"`
+	Data   json.RawMessage   `json:"data,omitempty"`
+	Sender string            `json:"sender,omitempty"`
+}
+
+// snapshot is what replaying a fixture left in the message store and the media directory. Paths in
+// the media directory start with $MEDIA.
+type snapshot struct {
+	Chats     []snapshotChat     `json:"chats"`
+	Messages  []snapshotMessage  `json:"messages"`
+	Reactions []snapshotReaction `json:"reactions,omitempty"`
+	// Photos handed to the face filter service for forwarding
+	FaceFilterQueue []string `json:"face_filter_queue"`
+	// What the calendar parser and the payment and form keywords found
+	CalendarEvents  []snapshotCalendarEvent  `json:"calendar_events,omitempty"`
+	PaymentRequests []snapshotPaymentRequest `json:"payment_requests,omitempty"`
+	Forms           []snapshotForm           `json:"forms,omitempty"`
+	// Outcome of each delete_sender event
+	Purges []snapshotPurge `json:"purges,omitempty"`
+}
+
+type snapshotChat struct {
+	JID  string `json:"jid"`
+	Name string `json:"name"`
+}
+
+type snapshotMessage struct {
+	ID            string    `json:"id"`
+	ChatJID       string    `json:"chat_jid"`
+	Sender        string    `json:"sender"`
+	SenderName    string    `json:"sender_name,omitempty"`
+	Content       string    `json:"content,omitempty"`
+	Caption       string    `json:"caption,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	IsFromMe      bool      `json:"is_from_me,omitempty"`
+	MediaType     string    `json:"media_type,omitempty"`
+	MediaPath     string    `json:"media_path,omitempty"`
+	MimeType      string    `json:"mime_type,omitempty"`
+	QuotedID      string    `json:"quoted_id,omitempty"`
+	QuotedSnippet string    `json:"quoted_snippet,omitempty"`
+	Spam          bool      `json:"spam,omitempty"`
+	SpamReasons   string    `json:"spam_reasons,omitempty"`
+	Topics        string    `json:"topics,omitempty"`
+}
+
+type snapshotReaction struct {
+	MessageID string `json:"message_id"`
+	Sender    string `json:"sender"`
+	Emoji     string `json:"emoji"`
+}
+
+type snapshotCalendarEvent struct {
+	MessageID string    `json:"message_id"`
+	Title     string    `json:"title"`
+	Start     time.Time `json:"start"`
+	AllDay    bool      `json:"all_day,omitempty"`
+}
+
+type snapshotPaymentRequest struct {
+	MessageID string  `json:"message_id"`
+	Sender    string  `json:"sender"`
+	Amount    float64 `json:"amount,omitempty"`
+	Link      string  `json:"link,omitempty"`
+}
+
+type snapshotForm struct {
+	MessageID string     `json:"message_id"`
+	Sender    string     `json:"sender"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+}
+
+type snapshotPurge struct {
+	Sender          string `json:"sender"`
+	MessagesDeleted int64  `json:"messages_deleted"`
+	Error           string `json:"error,omitempty"`
+}
+
+// TestRecordedEvents replays every fixture in testdata/events through the message handling and compares
+// the stored rows and routing decisions with its golden file
+func TestRecordedEvents(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "events", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []string
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".golden.json") {
+			fixtures = append(fixtures, path)
+		}
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures in testdata/events")
+	}
+
+	for _, path := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fixture eventFixture
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("invalid fixture: %v", err)
+			}
+
+			got, err := json.MarshalIndent(replay(t, fixture), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(path, ".json") + ".golden.json"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("no golden file, run go test -run TestRecordedEvents -update: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s: stored state differs from %s\n--- got\n%s\n--- want\n%s", fixture.Description, golden, got, want)
+			}
+		})
+	}
+}
+
+// replay runs the events of a fixture against a new message store and media directory and returns
+// what they left behind
+func replay(t *testing.T, fixture eventFixture) snapshot {
+	dir := t.TempDir()
+	storeDir, mediaDir, clock := store.Dir, media.Dir, now
+	t.Cleanup(func() {
+		store.Dir, media.Dir, now = storeDir, mediaDir, clock
+		config.Set(config.Config{})
+	})
+	store.Dir = filepath.Join(dir, "store")
+	media.Dir = filepath.Join(dir, "media")
+	now = func() time.Time { return fixture.Now }
+
+	cfg, err := config.Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range fixture.Config {
+		if err := cfg.Override(key, value); err != nil {
+			t.Fatalf("invalid config %s: %v", key, err)
+		}
+	}
+	config.Set(cfg)
+	if err := routing.CompileRedactionRules(cfg.Privacy.RedactionRules); err != nil {
+		t.Fatal(err)
+	}
+
+	messageStore, err := store.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer messageStore.Close()
+
+	// Payment requests and forms are kept in the store their reminders were started with; the reminders
+	// themselves stop right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	payments.Start(ctx, messageStore, func(context.Context, string, string) error { return nil }, func() string { return "" })
+	forms.Start(ctx, messageStore, func(context.Context, string, string, string, string) error { return nil }, func() string { return "" })
+
+	mock := NewMock()
+	for directPath, file := range fixture.Media {
+		data, err := os.ReadFile(filepath.Join("testdata", "media", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.media[directPath] = data
+	}
+
+	logger := waLog.Noop
+	var purges []snapshotPurge
+	for i, recorded := range fixture.Events {
+		switch recorded.Type {
+		case "message":
+			var raw waE2E.Message
+			if err := protojson.Unmarshal(recorded.Raw, &raw); err != nil {
+				t.Fatalf("event %d: invalid message: %v", i, err)
+			}
+			evt := (&events.Message{Info: recorded.Info, RawMessage: &raw}).UnwrapRaw()
+			HandleMessage(mock, messageStore, evt, logger)
+		case "history_sync":
+			var data waHistorySync.HistorySync
+			if err := protojson.Unmarshal(recorded.Data, &data); err != nil {
+				t.Fatalf("event %d: invalid history sync: %v", i, err)
+			}
+			HandleHistorySync(historyClient(t, dir), messageStore, &events.HistorySync{Data: &data}, logger)
+		case "delete_sender":
+			purge := snapshotPurge{Sender: recorded.Sender}
+			_, deleted, err := messageStore.DeleteMessagesBySender(recorded.Sender)
+			if err != nil {
+				purge.Error = err.Error()
+			}
+			purge.MessagesDeleted = deleted
+			purges = append(purges, purge)
+		default:
+			t.Fatalf("event %d: unknown type %q", i, recorded.Type)
+		}
+	}
+	snap := takeSnapshot(t)
+	snap.Purges = purges
+	return snap
+}
+
+// historyClient returns a whatsmeow client logged in as ownJID that never connects. History syncs look
+// up contact names in its session store, which starts out empty.
+func historyClient(t *testing.T, dir string) *whatsmeow.Client {
+	container, err := sqlstore.New("sqlite3", "file:"+filepath.Join(dir, "whatsapp.db")+"?_foreign_keys=on", waLog.Noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { container.Close() })
+	device := container.NewDevice()
+	device.ID = &ownJID
+	// The session store only accepts a device with the signatures of a paired one
+	device.Account = &waAdv.ADVSignedDeviceIdentity{
+		Details:             []byte{},
+		AccountSignatureKey: make([]byte, 32),
+		AccountSignature:    make([]byte, 64),
+		DeviceSignature:     make([]byte, 64),
+	}
+	// Saving the device sets up its contact and other stores
+	if err := device.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return whatsmeow.NewClient(device, waLog.Noop)
+}
+
+// takeSnapshot reads the stored chats, messages, reactions and what was found in them, and lists the
+// face filter queue
+func takeSnapshot(t *testing.T) snapshot {
+	db, err := sql.Open("sqlite3", "file:"+store.Path("messages.db")+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	snap := snapshot{Chats: []snapshotChat{}, Messages: []snapshotMessage{}, FaceFilterQueue: []string{}}
+	rows, err := db.Query(`SELECT jid, COALESCE(name, '') FROM chats ORDER BY jid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var chat snapshotChat
+		if err := rows.Scan(&chat.JID, &chat.Name); err != nil {
+			t.Fatal(err)
+		}
+		snap.Chats = append(snap.Chats, chat)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT id, chat_jid, sender, COALESCE(sender_name, ''), COALESCE(content, ''), COALESCE(caption, ''),
+		timestamp, is_from_me, COALESCE(media_type, ''), COALESCE(image_url, ''), COALESCE(mime_type, ''),
+		COALESCE(quoted_id, ''), COALESCE(quoted_snippet, ''), spam, COALESCE(spam_reasons, ''), COALESCE(topics, '')
+		FROM messages ORDER BY timestamp, id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var msg snapshotMessage
+		if err := rows.Scan(&msg.ID, &msg.ChatJID, &msg.Sender, &msg.SenderName, &msg.Content, &msg.Caption,
+			&msg.Timestamp, &msg.IsFromMe, &msg.MediaType, &msg.MediaPath, &msg.MimeType,
+			&msg.QuotedID, &msg.QuotedSnippet, &msg.Spam, &msg.SpamReasons, &msg.Topics); err != nil {
+			t.Fatal(err)
+		}
+		msg.Timestamp = msg.Timestamp.UTC()
+		msg.MediaPath = relativeToMedia(msg.MediaPath)
+		snap.Messages = append(snap.Messages, msg)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT message_id, sender, emoji FROM reactions ORDER BY message_id, sender`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var reaction snapshotReaction
+		if err := rows.Scan(&reaction.MessageID, &reaction.Sender, &reaction.Emoji); err != nil {
+			t.Fatal(err)
+		}
+		snap.Reactions = append(snap.Reactions, reaction)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT message_id, title, start_time, all_day FROM calendar_events ORDER BY message_id, start_time`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var event snapshotCalendarEvent
+		if err := rows.Scan(&event.MessageID, &event.Title, &event.Start, &event.AllDay); err != nil {
+			t.Fatal(err)
+		}
+		event.Start = event.Start.UTC()
+		snap.CalendarEvents = append(snap.CalendarEvents, event)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT message_id, sender, COALESCE(amount, 0), COALESCE(link, '') FROM payment_requests ORDER BY message_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var request snapshotPaymentRequest
+		if err := rows.Scan(&request.MessageID, &request.Sender, &request.Amount, &request.Link); err != nil {
+			t.Fatal(err)
+		}
+		snap.PaymentRequests = append(snap.PaymentRequests, request)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT message_id, sender, due_at FROM forms ORDER BY message_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var form snapshotForm
+		var due sql.NullTime
+		if err := rows.Scan(&form.MessageID, &form.Sender, &due); err != nil {
+			t.Fatal(err)
+		}
+		if due.Valid {
+			utc := due.Time.UTC()
+			form.DueAt = &utc
+		}
+		snap.Forms = append(snap.Forms, form)
+	}
+	rows.Close()
+
+	// Photos are queued as links in the top of the media directory
+	entries, err := os.ReadDir(media.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			snap.FaceFilterQueue = append(snap.FaceFilterQueue, "$MEDIA/"+entry.Name())
+		}
+	}
+	sort.Strings(snap.FaceFilterQueue)
+	return snap
+}
+
+// relativeToMedia replaces the temporary media directory at the start of path with $MEDIA
+func relativeToMedia(path string) string {
+	if rel, err := filepath.Rel(media.Dir, path); err == nil && path != "" && !strings.HasPrefix(rel, "..") {
+		return "$MEDIA/" + filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+
+	"whatsapp-client/internal/config"
+)
+
+// presenceCheckInterval is how often a presence schedule is re-evaluated
+const presenceCheckInterval = time.Minute
+
+// presence remembers what was last sent, so the schedule only sends changes
+var presence struct {
+	sync.Mutex
+	sent    types.Presence
+	started bool
+}
+
+// ApplyPresence sends the presence the configuration asks for now and, the first time, keeps following
+// the schedule. Call it on every connect: WhatsApp forgets the presence of a device that reconnects.
+func ApplyPresence(client *whatsmeow.Client) {
+	presence.Lock()
+	presence.sent = ""
+	start := !presence.started
+	presence.started = true
+	presence.Unlock()
+
+	updatePresence(client)
+	if start {
+		go func() {
+			ticker := time.NewTicker(presenceCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					updatePresence(client)
+				case <-ShutdownContext().Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// updatePresence sends the wanted presence if it differs from what was sent last
+func updatePresence(client *whatsmeow.Client) {
+	if !client.IsConnected() {
+		return
+	}
+	want := wantedPresence(config.Current().Presence, time.Now().In(config.Current().Location()))
+	presence.Lock()
+	defer presence.Unlock()
+	if want == presence.sent {
+		return
+	}
+	if err := client.SendPresence(want); err != nil {
+		// Fails until the account has a push name, which arrives with the first app state sync
+		fmt.Printf("[PRESENCE] Failed to set presence to %s: %v\n", want, err)
+		return
+	}
+	presence.sent = want
+	fmt.Printf("[PRESENCE] Now %s\n", want)
+}
+
+// wantedPresence returns the presence cfg asks for at t
+func wantedPresence(cfg config.PresenceConfig, t time.Time) types.Presence {
+	switch cfg.Mode {
+	case config.PresenceAvailable:
+		return types.PresenceAvailable
+	case config.PresenceSchedule:
+		for _, window := range cfg.Windows {
+			if inWindow(window, t) {
+				return types.PresenceAvailable
+			}
+		}
+	}
+	return types.PresenceUnavailable
+}
+
+// inWindow reports whether t falls within window. A window running past midnight belongs to the day it
+// starts on.
+func inWindow(window config.PresenceWindow, t time.Time) bool {
+	from, errFrom := time.Parse("15:04", window.From)
+	to, errTo := time.Parse("15:04", window.To)
+	if errFrom != nil || errTo != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	start, end := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+
+	if start <= end {
+		return onDay(window.Days, t.Weekday()) && minute >= start && minute < end
+	}
+	if minute >= start {
+		return onDay(window.Days, t.Weekday())
+	}
+	return minute < end && onDay(window.Days, (t.Weekday()+6)%7)
+}
+
+// onDay reports whether days includes day; an empty list includes every day
+func onDay(days []string, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if strings.ToLower(d) == config.Weekdays[day] {
+			return true
+		}
+	}
+	return false
+}
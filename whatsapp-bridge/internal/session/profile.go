@@ -0,0 +1,81 @@
+package session
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Profile is what contacts see of the bridge's own account
+type Profile struct {
+	JID   string `json:"jid"`
+	Name  string `json:"name"`
+	About string `json:"about"`
+}
+
+// profileClient returns the WhatsApp connection the bridge's own profile is changed through
+func profileClient(client WhatsAppClient) (*whatsmeow.Client, error) {
+	c, ok := client.(*whatsmeow.Client)
+	if !ok {
+		return nil, fmt.Errorf("changing the profile needs a WhatsApp connection")
+	}
+	if c.Store.ID == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	return c, nil
+}
+
+// GetProfile returns the push name and about text of the bridge's account. The about text is fetched
+// from WhatsApp and left empty when that fails.
+func GetProfile(client WhatsAppClient) (Profile, error) {
+	c, err := profileClient(client)
+	if err != nil {
+		return Profile{}, err
+	}
+	own := c.Store.ID.ToNonAD()
+	profile := Profile{JID: own.String(), Name: c.Store.PushName}
+	if info, err := c.GetUserInfo([]types.JID{own}); err == nil {
+		profile.About = info[own].Status
+	}
+	return profile, nil
+}
+
+// SetProfileName changes the push name shown to contacts who haven't saved the number. It is synced to
+// the phone and the other linked devices as a setting.
+func SetProfileName(client WhatsAppClient, name string) error {
+	c, err := profileClient(client)
+	if err != nil {
+		return err
+	}
+	if err := c.SendAppState(appstate.BuildSettingPushName(name)); err != nil {
+		return err
+	}
+	// Presence updates carry the push name, so the new one is used from now on
+	c.Store.PushName = name
+	if err := c.Store.Save(); err != nil {
+		return fmt.Errorf("failed to save the push name: %v", err)
+	}
+	return nil
+}
+
+// SetProfileAbout changes the about text of the account
+func SetProfileAbout(client WhatsAppClient, about string) error {
+	c, err := profileClient(client)
+	if err != nil {
+		return err
+	}
+	return c.SetStatusMessage(about)
+}
+
+// SetProfilePhoto replaces the account's profile picture with a JPEG, or removes it when photo is nil
+func SetProfilePhoto(client WhatsAppClient, photo []byte) error {
+	c, err := profileClient(client)
+	if err != nil {
+		return err
+	}
+	// Without a target the picture of the account itself is changed
+	_, err = c.SetGroupPhoto(types.EmptyJID, photo)
+	return err
+}
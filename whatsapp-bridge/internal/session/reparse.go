@@ -0,0 +1,107 @@
+package session
+
+import (
+	"fmt"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-client/internal/links"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/store"
+)
+
+// reparseBatchSize is how many archived raw messages are parsed per read of the store
+const reparseBatchSize = 500
+
+// ReparseResult counts what Reparse did
+type ReparseResult struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+	Added   int `json:"added"`
+	Failed  int `json:"failed"`
+}
+
+// Reparse runs the current text extraction over the archived raw messages matching filter and updates the
+// content, media details, reply context and links of stored messages that parse differently now.
+// Messages skipped for lack of text are added once text can be extracted from them. Media isn't
+// downloaded again. client may be nil, then new messages are stored with the sender's push name.
+func Reparse(client WhatsAppClient, messageStore *store.MessageStore, filter store.ExportFilter, logger waLog.Logger) (ReparseResult, error) {
+	var result ReparseResult
+	var afterRow int64
+	for {
+		raws, next, err := messageStore.GetRawMessages(filter, afterRow, reparseBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(raws) == 0 {
+			return result, nil
+		}
+		afterRow = next
+		for _, raw := range raws {
+			result.Scanned++
+			added, updated, err := reparseMessage(client, messageStore, raw, logger)
+			if err != nil {
+				logger.Warnf("Failed to re-parse message %s in %s: %v", raw.ID, raw.ChatJID, err)
+				result.Failed++
+				continue
+			}
+			if added {
+				result.Added++
+			} else if updated {
+				result.Updated++
+			}
+		}
+	}
+}
+
+// reparseMessage parses one archived raw message and stores the result, reporting whether the message
+// was added or an existing one updated
+func reparseMessage(client WhatsAppClient, messageStore *store.MessageStore, raw store.RawMessage, logger waLog.Logger) (bool, bool, error) {
+	var msg waProto.Message
+	if err := proto.Unmarshal(raw.Data, &msg); err != nil {
+		return false, false, fmt.Errorf("failed to decode: %v", err)
+	}
+	text := routing.RedactContent(raw.ChatJID, extractTextContent(&msg))
+	imageURL, stored, err := messageStore.StoredImageURL(raw.ID, raw.ChatJID)
+	if err != nil {
+		return false, false, err
+	}
+	if !stored && text == "" {
+		return false, false, nil
+	}
+	content, details := mediaDetails(&msg, text, imageURL)
+	incoming := store.IncomingMessage{
+		ID:        raw.ID,
+		ChatJID:   raw.ChatJID,
+		Sender:    raw.Sender,
+		Content:   content,
+		Timestamp: raw.Timestamp,
+		IsFromMe:  raw.IsFromMe,
+		ImageURL:  imageURL,
+		Details:   details,
+		Reply:     replyContextFromMessage(raw.ChatJID, &msg),
+	}
+
+	updated := false
+	if stored {
+		if updated, err = messageStore.UpdateParsedMessage(incoming); err != nil {
+			return false, false, err
+		}
+	} else {
+		chat, _ := types.ParseJID(raw.ChatJID)
+		sender, _ := types.ParseJID(raw.Sender)
+		incoming.SenderName = raw.PushName
+		if client != nil || raw.PushName == "" {
+			incoming.SenderName = SenderName(client, chat, sender, raw.PushName)
+		}
+		if err := messageStore.StoreIncoming(incoming); err != nil {
+			return false, false, err
+		}
+	}
+	// Links already archived are kept as they are
+	links.Archive(messageStore, raw.ID, raw.ChatJID, raw.Sender, text, raw.Timestamp, logger)
+	return !stored, updated, nil
+}
@@ -0,0 +1,366 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/links"
+	"whatsapp-client/internal/media"
+	"whatsapp-client/internal/notify"
+	"whatsapp-client/internal/tracing"
+)
+
+// watermarkFor returns the overlay configured for photos sent to phone, or nil. Downloaded photos are
+// written when their message arrives, so the file time is the day the photo was received.
+func watermarkFor(phone string, received time.Time) *media.Overlay {
+	dest, ok := config.DestinationFor(phone)
+	if !ok || !dest.Watermark.Enabled {
+		return nil
+	}
+	text := strings.NewReplacer("{name}", dest.Name, "{date}", i18n.Current().ShortDate(received.In(config.Current().Location()))).Replace(dest.Watermark.Text)
+	return &media.Overlay{Text: text, Position: dest.Watermark.Position, FontPath: dest.Watermark.FontPath}
+}
+
+// buildMentionedJIDs normalizes mention targets (phone numbers or JIDs) into full user JID strings
+func buildMentionedJIDs(mentions []string) []string {
+	var jids []string
+	for _, mention := range mentions {
+		mention = strings.TrimPrefix(strings.TrimSpace(mention), "+")
+		if mention == "" {
+			continue
+		}
+		if !strings.Contains(mention, "@") {
+			mention = mention + "@s.whatsapp.net"
+		}
+		jids = append(jids, mention)
+	}
+	return jids
+}
+
+// buildTextMessage creates a plain conversation message, or an extended text message when context
+// (e.g. mentions) or a link preview is present
+func buildTextMessage(text string, contextInfo *waProto.ContextInfo, withLinkPreview bool) *waProto.Message {
+	var preview *links.Preview
+	if withLinkPreview {
+		preview = links.FetchPreview(text)
+	}
+
+	if contextInfo == nil && preview == nil {
+		return &waProto.Message{
+			Conversation: proto.String(text),
+		}
+	}
+
+	extendedText := &waProto.ExtendedTextMessage{
+		Text:        proto.String(text),
+		ContextInfo: contextInfo,
+	}
+	if preview != nil {
+		extendedText.MatchedText = proto.String(preview.URL)
+		extendedText.Title = proto.String(preview.Title)
+		extendedText.Description = proto.String(preview.Description)
+		extendedText.PreviewType = waProto.ExtendedTextMessage_NONE.Enum()
+		if len(preview.Thumbnail) > 0 {
+			extendedText.JPEGThumbnail = preview.Thumbnail
+		}
+	}
+
+	return &waProto.Message{
+		ExtendedTextMessage: extendedText,
+	}
+}
+
+// Reasons a send fails, for callers to tell them apart with errors.Is
+var (
+	ErrNotConnected  = errors.New("not connected to WhatsApp")
+	ErrInvalidJID    = errors.New("invalid recipient")
+	ErrMediaTooLarge = errors.New("media file too large")
+	ErrInvalidMedia  = errors.New("invalid media file")
+	ErrUploadFailed  = errors.New("media upload failed")
+	ErrSendFailed    = errors.New("sending failed")
+)
+
+// MaxMediaSize is the largest photo, video or audio WhatsApp accepts as media; documents may be up to
+// MaxDocumentSize
+const (
+	MaxMediaSize    = 16 << 20
+	MaxDocumentSize = 100 << 20
+)
+
+// parseRecipient turns a phone number, user JID, group JID or group alias into the JID to send to
+func parseRecipient(phone string) (types.JID, error) {
+	if jid, isAlias := config.LookupGroupAlias(phone); isAlias {
+		if jid == "" {
+			return types.JID{}, fmt.Errorf("%w: group alias %q didn't match a joined group", ErrInvalidJID, phone)
+		}
+		phone = jid
+	}
+	if !strings.Contains(phone, "@") {
+		if phone == "" || strings.Trim(phone, "0123456789") != "" {
+			return types.JID{}, fmt.Errorf("%w: %q is not a phone number", ErrInvalidJID, phone)
+		}
+		return types.JID{User: phone, Server: types.DefaultUserServer}, nil
+	}
+	jid, err := types.ParseJID(phone)
+	if err != nil || jid.User == "" || (jid.Server != types.GroupServer && jid.Server != types.DefaultUserServer) {
+		return types.JID{}, fmt.Errorf("%w: %q is not a user or group JID", ErrInvalidJID, phone)
+	}
+	return jid, nil
+}
+
+// SendMessage sends a WhatsApp message and returns a description of what was sent. Errors wrap one of
+// the Err* reasons above or, when ctx ended first, its error. The request ID carried by ctx tags the
+// log lines.
+func SendMessage(ctx context.Context, client WhatsAppClient, phone, message string, mediaURL, mediaType, caption string, mentions []string, linkPreview bool) (string, error) {
+	id, err := sendMessage(ctx, client, phone, message, mediaURL, mediaType, caption, mentions, linkPreview)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Message sent to %s with ID: %s", phone, id), nil
+}
+
+// sendMessage sends a message as SendMessage does and returns its ID
+func sendMessage(ctx context.Context, client WhatsAppClient, phone, message string, mediaURL, mediaType, caption string, mentions []string, linkPreview bool) (id string, err error) {
+	reqID := tracing.RequestIDFromContext(ctx)
+
+	// Forwarding a downloaded photo continues the trace of the message it came from
+	spanName := "message.send"
+	var requestSpan tracing.SpanContext
+	linkRequest := false
+	if origin, ok := tracing.TakeMediaTrace(mediaURL); ok {
+		spanName = "message.forward"
+		requestSpan, linkRequest = tracing.FromContext(ctx)
+		ctx = tracing.ContextWithParent(ctx, origin)
+	}
+	ctx, span := tracing.StartSpan(ctx, spanName, tracing.SpanKindInternal)
+	if linkRequest {
+		span.AddLink(requestSpan)
+	}
+	span.SetAttr("request.id", reqID)
+	span.SetAttr("media.type", mediaType)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Validate client connection
+	if !client.IsConnected() {
+		fmt.Printf("[SEND] [%s] Not connected to WhatsApp\n", reqID)
+		return "", ErrNotConnected
+	}
+
+	// Create JID for recipient; group JIDs end in @g.us, phone numbers get @s.whatsapp.net
+	recipientJID, err := parseRecipient(phone)
+	if err != nil {
+		return "", err
+	}
+
+	// Build mention context so @-mentioned participants get notified
+	var contextInfo *waProto.ContextInfo
+	if mentionedJIDs := buildMentionedJIDs(mentions); len(mentionedJIDs) > 0 {
+		contextInfo = &waProto.ContextInfo{
+			MentionedJID: mentionedJIDs,
+		}
+	}
+
+	// Create appropriate message based on type
+	var msg *waProto.Message
+
+	if mediaURL != "" && mediaType != "" {
+		// Process media message
+		info, err := os.Stat(mediaURL)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+		}
+		maxSize := int64(MaxMediaSize)
+		if mediaType == "document" {
+			maxSize = MaxDocumentSize
+		}
+		if info.Size() > maxSize {
+			return "", fmt.Errorf("%w: %d bytes, at most %d are allowed", ErrMediaTooLarge, info.Size(), maxSize)
+		}
+		mediaData, err := os.ReadFile(mediaURL)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+		}
+
+		switch mediaType {
+		case "image":
+			// Process and send image
+			jpegData, width, height, err := media.VerifyAndConvertImage(mediaData, config.Current().Media.JPEGQuality, watermarkFor(phone, info.ModTime()))
+			if err != nil {
+				return "", fmt.Errorf("%w: %w", ErrInvalidMedia, err)
+			}
+
+			// Upload the JPEG image to WhatsApp servers
+			fmt.Printf("[SEND] [%s] Uploading image (%d bytes)\n", reqID, len(jpegData))
+			uploadCtx, uploadSpan := tracing.StartSpan(ctx, "whatsapp.upload", tracing.SpanKindClient)
+			uploadSpan.SetAttr("media.size", len(jpegData))
+			uploadCtx, cancel := UploadTimeout(uploadCtx)
+			uploadedImage, err := client.Upload(uploadCtx, jpegData, whatsmeow.MediaImage)
+			cancel()
+			uploadSpan.RecordError(err)
+			uploadSpan.End()
+			if err != nil {
+				fmt.Printf("[SEND] [%s] Image upload failed: %v\n", reqID, err)
+				return "", sendFailure(ErrUploadFailed, err)
+			}
+
+			msg = &waProto.Message{
+				ImageMessage: &waProto.ImageMessage{
+					URL:           proto.String(uploadedImage.URL),
+					DirectPath:    proto.String(uploadedImage.DirectPath),
+					MediaKey:      uploadedImage.MediaKey,
+					FileEncSHA256: uploadedImage.FileEncSHA256,
+					FileSHA256:    uploadedImage.FileSHA256,
+					FileLength:    proto.Uint64(uploadedImage.FileLength),
+					Caption:       proto.String(caption),
+					Mimetype:      proto.String("image/jpeg"),
+					Width:         proto.Uint32(uint32(width)),
+					Height:        proto.Uint32(uint32(height)),
+					ContextInfo:   contextInfo,
+				},
+			}
+
+		case "video":
+			// Upload the video to WhatsApp servers
+			fmt.Printf("[SEND] [%s] Uploading video (%d bytes)\n", reqID, len(mediaData))
+			uploadCtx, uploadSpan := tracing.StartSpan(ctx, "whatsapp.upload", tracing.SpanKindClient)
+			uploadSpan.SetAttr("media.size", len(mediaData))
+			uploadCtx, cancel := UploadTimeout(uploadCtx)
+			uploadedVideo, err := client.Upload(uploadCtx, mediaData, whatsmeow.MediaVideo)
+			cancel()
+			uploadSpan.RecordError(err)
+			uploadSpan.End()
+			if err != nil {
+				fmt.Printf("[SEND] [%s] Video upload failed: %v\n", reqID, err)
+				return "", sendFailure(ErrUploadFailed, err)
+			}
+
+			msg = &waProto.Message{
+				VideoMessage: &waProto.VideoMessage{
+					URL:           proto.String(uploadedVideo.URL),
+					DirectPath:    proto.String(uploadedVideo.DirectPath),
+					MediaKey:      uploadedVideo.MediaKey,
+					FileEncSHA256: uploadedVideo.FileEncSHA256,
+					FileSHA256:    uploadedVideo.FileSHA256,
+					FileLength:    proto.Uint64(uploadedVideo.FileLength),
+					Caption:       proto.String(caption),
+					Mimetype:      proto.String(http.DetectContentType(mediaData)),
+					ContextInfo:   contextInfo,
+				},
+			}
+		case "document":
+			// Upload the file as is, shown in the chat with its name
+			fmt.Printf("[SEND] [%s] Uploading document (%d bytes)\n", reqID, len(mediaData))
+			uploadCtx, uploadSpan := tracing.StartSpan(ctx, "whatsapp.upload", tracing.SpanKindClient)
+			uploadSpan.SetAttr("media.size", len(mediaData))
+			uploadCtx, cancel := UploadTimeout(uploadCtx)
+			uploadedDocument, err := client.Upload(uploadCtx, mediaData, whatsmeow.MediaDocument)
+			cancel()
+			uploadSpan.RecordError(err)
+			uploadSpan.End()
+			if err != nil {
+				fmt.Printf("[SEND] [%s] Document upload failed: %v\n", reqID, err)
+				return "", sendFailure(ErrUploadFailed, err)
+			}
+
+			msg = &waProto.Message{
+				DocumentMessage: &waProto.DocumentMessage{
+					URL:           proto.String(uploadedDocument.URL),
+					DirectPath:    proto.String(uploadedDocument.DirectPath),
+					MediaKey:      uploadedDocument.MediaKey,
+					FileEncSHA256: uploadedDocument.FileEncSHA256,
+					FileSHA256:    uploadedDocument.FileSHA256,
+					FileLength:    proto.Uint64(uploadedDocument.FileLength),
+					FileName:      proto.String(filepath.Base(mediaURL)),
+					Title:         proto.String(filepath.Base(mediaURL)),
+					Caption:       proto.String(caption),
+					Mimetype:      proto.String(http.DetectContentType(mediaData)),
+					ContextInfo:   contextInfo,
+				},
+			}
+		default:
+			// Fallback to text message if media type is not supported
+			msg = buildTextMessage(message, contextInfo, linkPreview)
+		}
+	} else {
+		// Simple text message
+		msg = buildTextMessage(message, contextInfo, linkPreview)
+	}
+
+	// Send the message
+	fmt.Printf("[SEND] [%s] Sending message to %s\n", reqID, recipientJID)
+	sendCtx, sendSpan := tracing.StartSpan(ctx, "whatsapp.send_message", tracing.SpanKindClient)
+	sendCtx, cancel := SendTimeout(sendCtx)
+	sent, err := client.SendMessage(sendCtx, recipientJID, msg)
+	cancel()
+	sendSpan.RecordError(err)
+	sendSpan.End()
+
+	if err != nil {
+		fmt.Printf("[SEND] [%s] Send to %s failed: %v\n", reqID, recipientJID, err)
+		return "", sendFailure(ErrSendFailed, err)
+	}
+
+	fmt.Printf("[SEND] [%s] Sent message %s to %s\n", reqID, sent.ID, recipientJID)
+	return sent.ID, nil
+}
+
+// sendFailure wraps a failed call to WhatsApp in its reason, keeping a deadline or cancellation recognizable
+func sendFailure(reason, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %w", reason, err)
+	}
+	return fmt.Errorf("%w: %v", reason, err)
+}
+
+// AlertSender delivers alerts as text messages through client
+func AlertSender(client WhatsAppClient) notify.Sender {
+	return func(ctx context.Context, chatJID, text string) error {
+		_, err := SendMessage(ctx, client, chatJID, text, "", "", "", nil, false)
+		return err
+	}
+}
+
+// ForwardSender returns a function sending text, or a photo or video with the text as its caption,
+// through client
+func ForwardSender(client WhatsAppClient) func(ctx context.Context, chatJID, text, mediaPath, mediaType string) error {
+	send := AnnouncementSender(client)
+	return func(ctx context.Context, chatJID, text, mediaPath, mediaType string) error {
+		_, err := send(ctx, chatJID, text, mediaPath, mediaType)
+		return err
+	}
+}
+
+// AnnouncementSender returns a function sending as ForwardSender's does that also returns the ID of the
+// sent message, so replies and reactions to it can be told apart
+func AnnouncementSender(client WhatsAppClient) func(ctx context.Context, chatJID, text, mediaPath, mediaType string) (string, error) {
+	return func(ctx context.Context, chatJID, text, mediaPath, mediaType string) (string, error) {
+		if mediaPath != "" && (mediaType == "image" || mediaType == "video") {
+			return sendMessage(ctx, client, chatJID, "", mediaPath, mediaType, text, nil, false)
+		}
+		return sendMessage(ctx, client, chatJID, text, "", "", "", nil, false)
+	}
+}
+
+// DocumentSender returns a function sending a file as a document with a caption through client
+func DocumentSender(client WhatsAppClient) func(ctx context.Context, chatJID, path, caption string) error {
+	return func(ctx context.Context, chatJID, path, caption string) error {
+		_, err := SendMessage(ctx, client, chatJID, "", path, "document", caption, nil, false)
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+)
+
+// started is when the bridge process started
+var started = time.Now()
+
+// lastEvent is the Unix time in nanoseconds of the last event received from WhatsApp, 0 before the first
+var lastEvent atomic.Int64
+
+// historyPending counts conversations of history syncs that are not stored yet
+var historyPending atomic.Int64
+
+// NoteEvent records that an event was received from WhatsApp
+func NoteEvent() {
+	lastEvent.Store(time.Now().UnixNano())
+}
+
+// LastEvent returns when the last event was received from WhatsApp, the zero time if none was
+func LastEvent() time.Time {
+	if t := lastEvent.Load(); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// Started returns when the bridge started
+func Started() time.Time {
+	return started
+}
+
+// Queues is the work the bridge has accepted but not finished
+type Queues struct {
+	LiveDownloads    media.LimiterStats `json:"live_downloads"`
+	HistoryDownloads media.LimiterStats `json:"history_downloads"`
+	// Conversations of running history syncs that are not stored yet
+	HistoryConversations int64 `json:"history_conversations"`
+	// Photos waiting in the media directory for the face filter services, of all pipelines
+	FaceFilter int `json:"face_filter"`
+}
+
+// QueueDepths returns the current length of the bridge's queues
+func QueueDepths() Queues {
+	faceFilter := media.PendingFaceFilter("")
+	for _, pipeline := range config.Current().PipelineNames() {
+		faceFilter += media.PendingFaceFilter(pipeline)
+	}
+	return Queues{
+		LiveDownloads:        liveDownloads.Stats(),
+		HistoryDownloads:     historyDownloads.Stats(),
+		HistoryConversations: historyPending.Load(),
+		FaceFilter:           faceFilter,
+	}
+}
+
+// Account reports whether the client is paired with a WhatsApp account and the account's JID.
+// The mock is always logged in, as an account without a JID.
+func Account(client WhatsAppClient) (loggedIn bool, jid string) {
+	switch c := client.(type) {
+	case *whatsmeow.Client:
+		if c.Store.ID != nil {
+			jid = c.Store.ID.String()
+		}
+		return c.IsLoggedIn(), jid
+	case *Mock:
+		return true, ""
+	}
+	return false, ""
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+
+	"whatsapp-client/internal/chaos"
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+)
+
+// shutdown is cancelled when the bridge exits, aborting uploads, downloads and sends still in flight
+var shutdown, cancelShutdown = context.WithCancel(context.Background())
+
+// ShutdownContext is the parent of all work the bridge does outside of API requests
+func ShutdownContext() context.Context {
+	return shutdown
+}
+
+// Shutdown cancels everything derived from ShutdownContext
+func Shutdown() {
+	cancelShutdown()
+}
+
+// withTimeout derives a context that expires after seconds
+func withTimeout(ctx context.Context, seconds int) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+}
+
+// SendTimeout bounds sending one message to WhatsApp
+func SendTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, config.Current().Timeouts.SendSeconds)
+}
+
+// UploadTimeout bounds uploading one media file to WhatsApp
+func UploadTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, config.Current().Timeouts.UploadSeconds)
+}
+
+// download fetches a message's media, giving up once ctx is done or the download timeout expires.
+// whatsmeow's Download takes no context, so an abandoned download finishes in the background and its
+// result is dropped.
+func download(ctx context.Context, client WhatsAppClient, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx, config.Current().Timeouts.DownloadSeconds)
+	defer cancel()
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if value := recover(); value != nil {
+				crash.Report("media download", value, debug.Stack())
+				done <- result{nil, fmt.Errorf("download panicked: %v", value)}
+			}
+		}()
+		if err := chaos.FailDownload(); err != nil {
+			done <- result{nil, err}
+			return
+		}
+		data, err := client.Download(msg)
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("download aborted: %w", ctx.Err())
+	}
+}
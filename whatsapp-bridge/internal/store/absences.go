@@ -0,0 +1,69 @@
+package store
+
+import "time"
+
+// AbsenceReport is a message sent to a child's teacher saying the child won't come
+type AbsenceReport struct {
+	ID          int64  `json:"id"`
+	Destination string `json:"destination"`
+	ChildName   string `json:"child_name"`
+	// The teacher's chat the report was sent to
+	ChatJID string `json:"chat_jid"`
+	Reason  string `json:"reason"`
+	Note    string `json:"note,omitempty"`
+	Text    string `json:"text"`
+	// Day of the absence, YYYY-MM-DD
+	Day        string    `json:"day"`
+	ReportedAt time.Time `json:"reported_at"`
+	// "api" or "command"
+	Source string `json:"source"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// StoreAbsenceReport records a report sent to a teacher and returns its ID
+func (store *MessageStore) StoreAbsenceReport(report AbsenceReport) (int64, error) {
+	result, err := store.exec(`INSERT INTO absence_reports (destination, child_name, chat_jid, reason, note, text, day, reported_at, source, dry_run)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.Destination, report.ChildName, report.ChatJID, report.Reason, report.Note, report.Text, report.Day, report.ReportedAt, report.Source, report.DryRun)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetAbsenceReports returns the reports of destination, or of every child when it is empty, on the days
+// from and to (YYYY-MM-DD, either may be empty), latest first
+func (store *MessageStore) GetAbsenceReports(destination, from, to string) ([]AbsenceReport, error) {
+	query := `SELECT id, destination, COALESCE(child_name, ''), chat_jid, reason, COALESCE(note, ''), text, day, reported_at,
+		COALESCE(source, ''), dry_run FROM absence_reports WHERE 1 = 1`
+	var args []interface{}
+	if destination != "" {
+		query += " AND destination = ?"
+		args = append(args, destination)
+	}
+	if from != "" {
+		query += " AND day >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		query += " AND day <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY day DESC, reported_at DESC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := []AbsenceReport{}
+	for rows.Next() {
+		var report AbsenceReport
+		if err := rows.Scan(&report.ID, &report.Destination, &report.ChildName, &report.ChatJID, &report.Reason, &report.Note,
+			&report.Text, &report.Day, &report.ReportedAt, &report.Source, &report.DryRun); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
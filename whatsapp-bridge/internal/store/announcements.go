@@ -0,0 +1,139 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// How an announcement was acknowledged
+const (
+	AcknowledgedByReply    = "reply"
+	AcknowledgedByReaction = "reaction"
+	AcknowledgedByMessage  = "message"
+)
+
+// Announcement is a message forwarded by topic to a chat, such as a health notice sent on to the other
+// parent, with whether that chat acknowledged it
+type Announcement struct {
+	ID int64 `json:"id"`
+	// The forwarded copy and the chat it was sent to
+	MessageID string `json:"message_id"`
+	ChatJID   string `json:"chat_jid"`
+	// The message it was forwarded from
+	SourceMessageID string    `json:"source_message_id"`
+	SourceChatJID   string    `json:"source_chat_jid"`
+	SourceSender    string    `json:"-"`
+	Topic           string    `json:"topic"`
+	Text            string    `json:"text"`
+	SentAt          time.Time `json:"sent_at"`
+	Acknowledged    bool      `json:"acknowledged"`
+	// Who acknowledged it, when, and how: reply, reaction or message
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	Acknowledgment string     `json:"acknowledgment,omitempty"`
+	// Nudges sent so far, and when the last one was
+	Nudges   int        `json:"nudges"`
+	NudgedAt *time.Time `json:"nudged_at,omitempty"`
+}
+
+// announcementQuery selects announcements in the column order scanAnnouncement expects
+const announcementQuery = `SELECT id, message_id, chat_jid, COALESCE(source_message_id, ''), COALESCE(source_chat_jid, ''),
+	COALESCE(topic, ''), COALESCE(text, ''), sent_at, acknowledged, COALESCE(acknowledged_by, ''), acknowledged_at,
+	COALESCE(acknowledgment, ''), nudges, nudged_at FROM announcements`
+
+// scanAnnouncement reads a row selected by announcementQuery
+func scanAnnouncement(row interface{ Scan(...interface{}) error }) (Announcement, error) {
+	var announcement Announcement
+	var acknowledgedAt, nudgedAt sql.NullTime
+	err := row.Scan(&announcement.ID, &announcement.MessageID, &announcement.ChatJID, &announcement.SourceMessageID,
+		&announcement.SourceChatJID, &announcement.Topic, &announcement.Text, &announcement.SentAt, &announcement.Acknowledged,
+		&announcement.AcknowledgedBy, &acknowledgedAt, &announcement.Acknowledgment, &announcement.Nudges, &nudgedAt)
+	if acknowledgedAt.Valid {
+		announcement.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	if nudgedAt.Valid {
+		announcement.NudgedAt = &nudgedAt.Time
+	}
+	return announcement, err
+}
+
+// StoreAnnouncement records a forwarded message
+func (store *MessageStore) StoreAnnouncement(announcement Announcement) error {
+	_, err := store.exec(`INSERT OR IGNORE INTO announcements (message_id, chat_jid, source_message_id, source_chat_jid,
+		source_sender, topic, text, sent_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		announcement.MessageID, announcement.ChatJID, announcement.SourceMessageID, announcement.SourceChatJID,
+		announcement.SourceSender, announcement.Topic, announcement.Text, announcement.SentAt)
+	return err
+}
+
+// AcknowledgeAnnouncement marks the announcement sent, or last nudged about, as messageID acknowledged
+// by sender, unless it already was, and reports whether it was an open announcement
+func (store *MessageStore) AcknowledgeAnnouncement(messageID, sender, how string, at time.Time) (bool, error) {
+	result, err := store.exec(`UPDATE announcements SET acknowledged = 1, acknowledged_by = ?, acknowledged_at = ?, acknowledgment = ?
+		WHERE (message_id = ? OR nudge_message_id = ?) AND NOT acknowledged`, sender, at, how, messageID, messageID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// AcknowledgeChatAnnouncements marks the open announcements sent to chatJID before at acknowledged by
+// sender and returns how many there were
+func (store *MessageStore) AcknowledgeChatAnnouncements(chatJID, sender, how string, at time.Time) (int64, error) {
+	result, err := store.exec(`UPDATE announcements SET acknowledged = 1, acknowledged_by = ?, acknowledged_at = ?, acknowledgment = ?
+		WHERE chat_jid = ? AND NOT acknowledged AND sent_at <= ?`, sender, at, how, chatJID, at)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetAnnouncements returns the announcements sent to chatJID, or to every chat when it is empty, newest
+// first: the open ones, the acknowledged ones, or all with acknowledged nil
+func (store *MessageStore) GetAnnouncements(chatJID string, acknowledged *bool) ([]Announcement, error) {
+	query := announcementQuery + " WHERE 1 = 1"
+	var args []interface{}
+	if chatJID != "" {
+		query += " AND chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	if acknowledged != nil {
+		query += " AND acknowledged = ?"
+		args = append(args, *acknowledged)
+	}
+	query += " ORDER BY sent_at DESC"
+	return store.queryAnnouncements(query, args...)
+}
+
+// DueNudges returns the open announcements sent, or last nudged about, before since that got fewer than
+// maxNudges nudges, oldest first
+func (store *MessageStore) DueNudges(since time.Time, maxNudges int) ([]Announcement, error) {
+	return store.queryAnnouncements(announcementQuery+`
+		WHERE NOT acknowledged AND nudges < ? AND COALESCE(nudged_at, sent_at) <= ?
+		ORDER BY sent_at ASC`, maxNudges, since)
+}
+
+// RecordNudge counts a nudge sent about an announcement as messageID
+func (store *MessageStore) RecordNudge(id int64, messageID string, at time.Time) error {
+	_, err := store.exec("UPDATE announcements SET nudges = nudges + 1, nudged_at = ?, nudge_message_id = ? WHERE id = ?", at, messageID, id)
+	return err
+}
+
+// queryAnnouncements runs a query selecting announcementQuery's columns
+func (store *MessageStore) queryAnnouncements(query string, args ...interface{}) ([]Announcement, error) {
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	announcements := []Announcement{}
+	for rows.Next() {
+		announcement, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, announcement)
+	}
+	return announcements, rows.Err()
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// AutoReply is an automatic answer to a message
+type AutoReply struct {
+	ChatJID string
+	Sender  string
+	// The message answered
+	MessageID string
+	Text      string
+	RepliedAt time.Time
+	DryRun    bool
+}
+
+// StoreAutoReply records an automatic answer
+func (store *MessageStore) StoreAutoReply(reply AutoReply) error {
+	_, err := store.exec(`INSERT INTO auto_replies (chat_jid, sender, message_id, text, replied_at, dry_run) VALUES (?, ?, ?, ?, ?, ?)`,
+		reply.ChatJID, reply.Sender, reply.MessageID, reply.Text, reply.RepliedAt, reply.DryRun)
+	return err
+}
+
+// LastAutoReply returns when sender was last answered automatically, or the zero time if never
+func (store *MessageStore) LastAutoReply(sender string) (time.Time, error) {
+	var at time.Time
+	err := store.queryRow(`SELECT replied_at FROM auto_replies WHERE sender = ? ORDER BY replied_at DESC LIMIT 1`, sender).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
+
+// OptOutOfAutoReplies stops automatic answers to sender
+func (store *MessageStore) OptOutOfAutoReplies(sender string, at time.Time) error {
+	_, err := store.exec(`INSERT OR IGNORE INTO auto_reply_opt_outs (sender, opted_out_at) VALUES (?, ?)`, sender, at)
+	return err
+}
+
+// OptedOutOfAutoReplies reports whether sender asked not to be answered automatically
+func (store *MessageStore) OptedOutOfAutoReplies(sender string) (bool, error) {
+	var at time.Time
+	err := store.queryRow(`SELECT opted_out_at FROM auto_reply_opt_outs WHERE sender = ?`, sender).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"whatsapp-client/internal/media"
+)
+
+// BackupManifest describes the contents of a backup archive and is used to verify it on restore
+type BackupManifest struct {
+	CreatedAt time.Time         `json:"created_at"`
+	Files     map[string]string `json:"files"` // archive path -> sha256
+}
+
+const backupManifestName = "manifest.json"
+
+// snapshotDatabase copies a live SQLite database to destPath using the SQLite online backup API,
+// which produces a consistent snapshot even while the bridge keeps writing to it
+func snapshotDatabase(srcPath, destPath string) error {
+	srcDB, err := sql.Open("sqlite3", "file:"+srcPath+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", srcPath, err)
+	}
+	defer srcDB.Close()
+
+	destDB, err := sql.Open("sqlite3", "file:"+destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", destPath, err)
+	}
+	defer destDB.Close()
+
+	ctx := context.Background()
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dest, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			src, ok2 := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return fmt.Errorf("unexpected sqlite driver connection type")
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %v", err)
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Close()
+				return fmt.Errorf("failed to copy database pages: %v", err)
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// CheckDatabaseIntegrity runs PRAGMA integrity_check on a SQLite database file
+func CheckDatabaseIntegrity(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check of %s failed: %s", filepath.Base(path), result)
+	}
+	return nil
+}
+
+// addFileToTar writes a file into the archive under name and returns its sha256
+func addFileToTar(tw *tar.Writer, path, name string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return "", err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, hash), file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CreateBackup snapshots messages.db, whatsapp.db and the databases of isolated pipelines (and
+// optionally the media directory) into a timestamped tar.gz in outputDir and returns the archive path
+func CreateBackup(outputDir string, includeMedia bool) (string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "bridge-backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Take consistent snapshots of the databases first
+	databases := append([]string{"messages.db", "whatsapp.db"}, pipelineDatabases()...)
+	for _, name := range databases {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, name)), 0755); err != nil {
+			return "", err
+		}
+		if err := snapshotDatabase(Path(name), filepath.Join(tmpDir, name)); err != nil {
+			return "", fmt.Errorf("failed to snapshot %s: %v", name, err)
+		}
+	}
+
+	archivePath := filepath.Join(outputDir, fmt.Sprintf("backup-%s.tar.gz", time.Now().Format("20060102-150405")))
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive: %v", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	manifest := BackupManifest{CreatedAt: time.Now().UTC(), Files: make(map[string]string)}
+	for _, name := range databases {
+		sum, err := addFileToTar(tw, filepath.Join(tmpDir, name), name)
+		if err != nil {
+			return "", fmt.Errorf("failed to archive %s: %v", name, err)
+		}
+		manifest.Files[name] = sum
+	}
+
+	// Media is archived under media/, wherever media.store_path puts it
+	if includeMedia {
+		err := filepath.Walk(media.Dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(media.Dir, path)
+			if err != nil {
+				return err
+			}
+			name := "media/" + filepath.ToSlash(rel)
+			sum, err := addFileToTar(tw, path, name)
+			if err != nil {
+				return err
+			}
+			manifest.Files[name] = sum
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to archive media: %v", err)
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(manifestData)), ModTime: time.Now()}); err != nil {
+		return "", err
+	}
+	if _, err := tw.Write(manifestData); err != nil {
+		return "", err
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// RestoreBackup verifies a backup archive and replaces the store directory contents with it.
+// It must only be run while the bridge is stopped.
+func RestoreBackup(archivePath string) error {
+	// Stage inside the data directory so the final renames stay on one file system
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	stagingDir, err := os.MkdirTemp(Dir, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	// Extract everything into a staging directory, hashing as we go
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *BackupManifest
+	sums := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %v", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			return fmt.Errorf("backup contains invalid path %q", header.Name)
+		}
+
+		if header.Name == backupManifestName {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return fmt.Errorf("invalid backup manifest: %v", err)
+			}
+			continue
+		}
+
+		target := filepath.Join(stagingDir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(io.MultiWriter(out, hash), tr)
+		out.Close()
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %v", header.Name, err)
+		}
+		sums[header.Name] = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	// Verify checksums and database integrity before touching the live store
+	if manifest == nil {
+		return fmt.Errorf("backup has no manifest")
+	}
+	for name, expected := range manifest.Files {
+		if sums[name] != expected {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+	for _, name := range []string{"messages.db", "whatsapp.db"} {
+		if _, ok := manifest.Files[name]; !ok {
+			return fmt.Errorf("backup is missing %s", name)
+		}
+	}
+	// The databases of isolated pipelines are checked too
+	for name := range manifest.Files {
+		if strings.HasSuffix(name, ".db") {
+			if err := CheckDatabaseIntegrity(filepath.Join(stagingDir, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Move verified files into place
+	for name := range manifest.Files {
+		target := Path(filepath.FromSlash(name))
+		if rel, ok := strings.CutPrefix(name, "media/"); ok {
+			target = filepath.Join(media.Dir, filepath.FromSlash(rel))
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		// Drop stale WAL/journal files so SQLite doesn't replay them over the restored database
+		if strings.HasSuffix(name, ".db") {
+			os.Remove(target + "-wal")
+			os.Remove(target + "-shm")
+			os.Remove(target + "-journal")
+		}
+		if err := moveFile(filepath.Join(stagingDir, filepath.FromSlash(name)), target); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+
+	fmt.Printf("Restored %d files from %s (created %s)\n", len(manifest.Files), archivePath, manifest.CreatedAt.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"whatsapp-client/internal/chaos"
+)
+
+const (
+	// busyTimeout is how long SQLite itself waits for a lock before reporting the database as busy
+	busyTimeout = 5 * time.Second
+	// readConnections is the size of the read pool shared by API requests and exports
+	readConnections = 4
+	// busyRetries is how often a statement is retried after SQLite gave up waiting
+	busyRetries = 5
+)
+
+// isBusy reports whether err means another connection held the lock for longer than busyTimeout
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy runs fn again with a growing pause while the database is busy, e.g. during a long backup
+func retryBusy(fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt <= busyRetries && isBusy(err); attempt++ {
+		time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		err = fn()
+	}
+	return err
+}
+
+// injectWriteFault slows down a write and makes it find the database busy when chaos testing asks for it
+func injectWriteFault() error {
+	if chaos.SlowWrite() {
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	}
+	return nil
+}
+
+// exec runs a write statement on the writer connection
+func (store *MessageStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := retryBusy(func() error {
+		if err := injectWriteFault(); err != nil {
+			return err
+		}
+		var err error
+		result, err = store.db.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// query runs a read on the read pool
+func (store *MessageStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := retryBusy(func() error {
+		var err error
+		rows, err = store.reads.Query(query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// queryRow runs a single-row read on the read pool
+func (store *MessageStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return store.reads.QueryRow(query, args...)
+}
+
+// transaction runs fn in a write transaction, committing if it returns nil. A transaction that couldn't
+// start or commit because the database was busy is retried as a whole.
+func (store *MessageStore) transaction(fn func(tx *sql.Tx) error) error {
+	return retryBusy(func() error {
+		if err := injectWriteFault(); err != nil {
+			return err
+		}
+		tx, err := store.db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,50 @@
+package store
+
+import "time"
+
+// CalendarEvent is an event detected in a stored message
+type CalendarEvent struct {
+	ID        int64     `json:"id"`
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Title     string    `json:"title"`
+	Start     time.Time `json:"start"`
+	AllDay    bool      `json:"all_day"`
+	Details   string    `json:"details"`
+}
+
+// StoreCalendarEvent records a detected event, ignoring duplicates for the same message and start
+func (store *MessageStore) StoreCalendarEvent(event CalendarEvent) error {
+	_, err := store.exec(
+		"INSERT OR IGNORE INTO calendar_events (message_id, chat_jid, title, start_time, all_day, details) VALUES (?, ?, ?, ?, ?, ?)",
+		event.MessageID, event.ChatJID, event.Title, event.Start, event.AllDay, event.Details,
+	)
+	return err
+}
+
+// GetCalendarEvents returns detected events ordered by start time, optionally for one chat
+func (store *MessageStore) GetCalendarEvents(chatJID string) ([]CalendarEvent, error) {
+	query := "SELECT id, message_id, chat_jid, title, start_time, all_day, details FROM calendar_events"
+	var args []interface{}
+	if chatJID != "" {
+		query += " WHERE chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY start_time ASC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []CalendarEvent
+	for rows.Next() {
+		var event CalendarEvent
+		if err := rows.Scan(&event.ID, &event.MessageID, &event.ChatJID, &event.Title, &event.Start, &event.AllDay, &event.Details); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
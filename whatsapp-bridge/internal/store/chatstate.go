@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ChatState is a chat with the mute, archive and pin settings of the linked phone
+type ChatState struct {
+	JID             string    `json:"jid"`
+	Name            string    `json:"name,omitempty"`
+	LastMessageTime time.Time `json:"last_message_time"`
+	Muted           bool      `json:"muted"`
+	// End of the mute; nil while muted means muted until unmuted
+	MutedUntil *time.Time `json:"muted_until,omitempty"`
+	Archived   bool       `json:"archived"`
+	Pinned     bool       `json:"pinned"`
+}
+
+// ChatStateFilter selects chats by state; nil fields match every chat
+type ChatStateFilter struct {
+	Muted    *bool
+	Archived *bool
+	Pinned   *bool
+}
+
+// SetChatMuted records whether a chat is muted and until when; a zero until mutes it indefinitely
+func (store *MessageStore) SetChatMuted(jid string, muted bool, until time.Time) error {
+	var end interface{}
+	if muted && !until.IsZero() {
+		end = until
+	}
+	_, err := store.exec(`INSERT INTO chats (jid, muted, muted_until) VALUES (?, ?, ?)
+		ON CONFLICT(jid) DO UPDATE SET muted = excluded.muted, muted_until = excluded.muted_until`, jid, muted, end)
+	return err
+}
+
+// SetChatArchived records whether a chat is archived
+func (store *MessageStore) SetChatArchived(jid string, archived bool) error {
+	_, err := store.exec(`INSERT INTO chats (jid, archived) VALUES (?, ?)
+		ON CONFLICT(jid) DO UPDATE SET archived = excluded.archived`, jid, archived)
+	return err
+}
+
+// SetChatPinned records whether a chat is pinned
+func (store *MessageStore) SetChatPinned(jid string, pinned bool) error {
+	_, err := store.exec(`INSERT INTO chats (jid, pinned) VALUES (?, ?)
+		ON CONFLICT(jid) DO UPDATE SET pinned = excluded.pinned`, jid, pinned)
+	return err
+}
+
+// GetChatStates returns the chats matching filter, most recently active first. Mutes that have ended
+// are reported as unmuted.
+func (store *MessageStore) GetChatStates(filter ChatStateFilter) ([]ChatState, error) {
+	now := time.Now()
+	query := `SELECT jid, COALESCE(name, ''), last_message_time,
+		muted AND (muted_until IS NULL OR muted_until > ?), muted_until, archived, pinned FROM chats WHERE 1 = 1`
+	args := []interface{}{now}
+	if filter.Muted != nil {
+		query += " AND (muted AND (muted_until IS NULL OR muted_until > ?)) = ?"
+		args = append(args, now, *filter.Muted)
+	}
+	if filter.Archived != nil {
+		query += " AND archived = ?"
+		args = append(args, *filter.Archived)
+	}
+	if filter.Pinned != nil {
+		query += " AND pinned = ?"
+		args = append(args, *filter.Pinned)
+	}
+	query += " ORDER BY pinned DESC, last_message_time DESC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := []ChatState{}
+	for rows.Next() {
+		var chat ChatState
+		var lastMessage, until sql.NullTime
+		if err := rows.Scan(&chat.JID, &chat.Name, &lastMessage, &chat.Muted, &until, &chat.Archived, &chat.Pinned); err != nil {
+			return nil, err
+		}
+		chat.LastMessageTime = lastMessage.Time
+		if chat.Muted && until.Valid {
+			chat.MutedUntil = &until.Time
+		}
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+// IsChatArchived reports whether a chat is archived
+func (store *MessageStore) IsChatArchived(jid string) bool {
+	var archived bool
+	store.queryRow("SELECT archived FROM chats WHERE jid = ?", jid).Scan(&archived)
+	return archived
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Connection events recorded in connection_log
+const (
+	ConnEventStarted           = "started"
+	ConnEventConnected         = "connected"
+	ConnEventDisconnected      = "disconnected"
+	ConnEventLoggedOut         = "logged_out"
+	ConnEventKeepAliveTimeout  = "keepalive_timeout"
+	ConnEventKeepAliveRestored = "keepalive_restored"
+	ConnEventLivenessReconnect = "liveness_reconnect"
+	ConnEventShutdown          = "shutdown"
+)
+
+// ConnectionEvent is a row of the connection log
+type ConnectionEvent struct {
+	Event     string    `json:"event"`
+	Detail    string    `json:"detail,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ConnectionHistory summarizes connectivity over a time window
+type ConnectionHistory struct {
+	Since           time.Time         `json:"since"`
+	Until           time.Time         `json:"until"`
+	Connected       bool              `json:"connected"`
+	UptimeSeconds   int64             `json:"uptime_seconds"`
+	DowntimeSeconds int64             `json:"downtime_seconds"`
+	UptimePercent   float64           `json:"uptime_percent"`
+	Disconnects     int               `json:"disconnects"`
+	Events          []ConnectionEvent `json:"events"`
+}
+
+// connectionEventIsUp tells whether the connection is usable after the event
+func connectionEventIsUp(event string) bool {
+	return event == ConnEventConnected || event == ConnEventKeepAliveRestored
+}
+
+// LogConnectionEvent records a connection state change
+func (store *MessageStore) LogConnectionEvent(event, detail string) error {
+	_, err := store.exec("INSERT INTO connection_log (event, detail, timestamp) VALUES (?, ?, ?)", event, detail, time.Now())
+	return err
+}
+
+// GetConnectionHistory computes uptime statistics for the window starting at since
+func (store *MessageStore) GetConnectionHistory(since time.Time) (*ConnectionHistory, error) {
+	history := &ConnectionHistory{Since: since, Until: time.Now(), Events: []ConnectionEvent{}}
+
+	// The state at the start of the window is whatever the last earlier event left it in
+	var lastEvent string
+	err := store.queryRow("SELECT event FROM connection_log WHERE timestamp < ? ORDER BY timestamp DESC, id DESC LIMIT 1", since).Scan(&lastEvent)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	up := connectionEventIsUp(lastEvent)
+
+	rows, err := store.query("SELECT event, COALESCE(detail, ''), timestamp FROM connection_log WHERE timestamp >= ? ORDER BY timestamp ASC, id ASC", since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uptime time.Duration
+	cursor := since
+	for rows.Next() {
+		var event ConnectionEvent
+		if err := rows.Scan(&event.Event, &event.Detail, &event.Timestamp); err != nil {
+			return nil, err
+		}
+		if up {
+			uptime += event.Timestamp.Sub(cursor)
+		}
+		cursor = event.Timestamp
+		nowUp := connectionEventIsUp(event.Event)
+		if up && !nowUp && event.Event != ConnEventShutdown {
+			history.Disconnects++
+		}
+		up = nowUp
+		history.Events = append(history.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if up {
+		uptime += history.Until.Sub(cursor)
+	}
+
+	total := history.Until.Sub(since)
+	history.Connected = up
+	history.UptimeSeconds = int64(uptime.Seconds())
+	history.DowntimeSeconds = int64((total - uptime).Seconds())
+	if total > 0 {
+		history.UptimePercent = float64(uptime) / float64(total) * 100
+	}
+	return history, nil
+}
+
+// GetLastConnectionEventID returns the ID of the newest connection log row, 0 if there are none
+func (store *MessageStore) GetLastConnectionEventID() (int64, error) {
+	var id int64
+	err := store.queryRow("SELECT COALESCE(MAX(id), 0) FROM connection_log").Scan(&id)
+	return id, err
+}
+
+// GetConnectionEventsAfter returns the connection events logged after the row with afterID, oldest
+// first, together with the ID of the last one (afterID if there are none)
+func (store *MessageStore) GetConnectionEventsAfter(afterID int64) ([]ConnectionEvent, int64, error) {
+	rows, err := store.query("SELECT id, event, COALESCE(detail, ''), timestamp FROM connection_log WHERE id > ? ORDER BY id ASC", afterID)
+	if err != nil {
+		return nil, afterID, err
+	}
+	defer rows.Close()
+
+	var events []ConnectionEvent
+	for rows.Next() {
+		var event ConnectionEvent
+		if err := rows.Scan(&afterID, &event.Event, &event.Detail, &event.Timestamp); err != nil {
+			return nil, afterID, err
+		}
+		events = append(events, event)
+	}
+	return events, afterID, rows.Err()
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UnreadCount is how far a consumer has read a chat. Chats the consumer has never read count every
+// stored message as unread.
+type UnreadCount struct {
+	ChatJID    string     `json:"chat_jid"`
+	ChatName   string     `json:"chat_name,omitempty"`
+	LastReadID string     `json:"last_read_id,omitempty"`
+	LastReadAt *time.Time `json:"last_read_at,omitempty"`
+	Unread     int        `json:"unread"`
+}
+
+// AdvanceCursor marks everything up to and including a message as read by consumer. Cursors only move
+// forward: advancing to a message older than the current cursor leaves it where it is. Returns
+// sql.ErrNoRows if the message isn't stored.
+func (store *MessageStore) AdvanceCursor(consumer, chatJID, messageID string) (*UnreadCount, error) {
+	var exists int
+	err := store.queryRow("SELECT 1 FROM messages WHERE id = ? AND chat_jid = ?", messageID, chatJID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = store.exec(`
+		INSERT INTO consumer_cursors (consumer, chat_jid, message_id, timestamp, updated_at)
+		SELECT ?, chat_jid, id, timestamp, ? FROM messages WHERE id = ? AND chat_jid = ?
+		ON CONFLICT (consumer, chat_jid) DO UPDATE SET
+			message_id = excluded.message_id, timestamp = excluded.timestamp, updated_at = excluded.updated_at
+		WHERE excluded.timestamp >= consumer_cursors.timestamp`,
+		consumer, time.Now(), messageID, chatJID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := store.GetUnreadCounts(consumer, chatJID)
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &counts[0], nil
+}
+
+// GetUnreadCounts returns the cursor and number of messages after it of every chat (or only chatJID,
+// if given) for consumer, most recently active chats first
+func (store *MessageStore) GetUnreadCounts(consumer, chatJID string) ([]UnreadCount, error) {
+	query := `SELECT chats.jid, COALESCE(chats.name, ''), COALESCE(cursors.message_id, ''), cursors.timestamp,
+		(SELECT COUNT(*) FROM messages WHERE messages.chat_jid = chats.jid
+			AND (cursors.timestamp IS NULL OR messages.timestamp > cursors.timestamp))
+		FROM chats LEFT JOIN consumer_cursors cursors ON cursors.chat_jid = chats.jid AND cursors.consumer = ?`
+	args := []interface{}{consumer}
+	if chatJID != "" {
+		query += " WHERE chats.jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY chats.last_message_time DESC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []UnreadCount{}
+	for rows.Next() {
+		var count UnreadCount
+		var lastRead sql.NullTime
+		if err := rows.Scan(&count.ChatJID, &count.ChatName, &count.LastReadID, &lastRead, &count.Unread); err != nil {
+			return nil, err
+		}
+		if lastRead.Valid {
+			count.LastReadAt = &lastRead.Time
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/media"
+)
+
+// deleteMessagesWhere removes the matching messages and the data derived from them inside tx and returns the media
+// files they referenced together with the number of deleted messages
+func deleteMessagesWhere(tx *sql.Tx, where string, args ...interface{}) ([]string, int64, error) {
+	rows, err := tx.Query("SELECT image_url, COALESCE(poster_path, '') FROM messages WHERE image_url != '' AND "+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var mediaPaths []string
+	for rows.Next() {
+		var path, poster string
+		if err := rows.Scan(&path, &poster); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		mediaPaths = append(mediaPaths, path)
+		if poster != "" {
+			mediaPaths = append(mediaPaths, poster)
+		}
+	}
+	rows.Close()
+
+	// Remove data derived from the messages before the messages themselves
+	for _, table := range []string{"links", "calendar_events", "reactions", "face_cluster_faces", "face_matches"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE EXISTS (SELECT 1 FROM messages WHERE messages.id = "+table+".message_id AND messages.chat_jid = "+table+".chat_jid AND "+where+")", args...); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := deleteLedgerEntries(tx, where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	// Archived raw messages have the columns deletions filter on, and may exist for messages that were
+	// never stored because they had no text or media
+	if _, err := tx.Exec("DELETE FROM raw_messages WHERE "+strings.ReplaceAll(where, "messages.", "raw_messages."), args...); err != nil {
+		return nil, 0, err
+	}
+
+	// Tell event consumers which messages are gone
+	eventArgs := append([]interface{}{EventMessageDeleted, time.Now()}, args...)
+	if _, err := tx.Exec("INSERT INTO events (type, message_id, chat_jid, timestamp) SELECT ?, id, chat_jid, ? FROM messages WHERE "+where, eventArgs...); err != nil {
+		return nil, 0, err
+	}
+
+	result, err := tx.Exec("DELETE FROM messages WHERE "+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	deleted, _ := result.RowsAffected()
+	return mediaPaths, deleted, nil
+}
+
+// ledgers are the tables that keep what was found in messages, with the columns that stand for the
+// message's ID, chat and sender. They are kept in the shared store, also for messages of isolated
+// pipelines, so they are matched by these columns rather than joined with the messages.
+var ledgers = []struct{ table, id, chat, sender string }{
+	{"payment_requests", "message_id", "chat_jid", "sender"},
+	{"forms", "message_id", "chat_jid", "sender"},
+	{"announcements", "source_message_id", "source_chat_jid", "source_sender"},
+}
+
+// deleteLedgerEntries removes what the ledgers keep of the messages matching where
+func deleteLedgerEntries(tx *sql.Tx, where string, args ...interface{}) error {
+	for _, ledger := range ledgers {
+		columns := strings.NewReplacer(
+			"messages.id", ledger.table+"."+ledger.id,
+			"messages.chat_jid", ledger.table+"."+ledger.chat,
+			"messages.sender", ledger.table+"."+ledger.sender,
+		)
+		if _, err := tx.Exec("DELETE FROM "+ledger.table+" WHERE "+columns.Replace(where), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unreferencedPaths returns the media paths no remaining message refers to. Media is stored by content,
+// so the same photo posted to two groups is one file, which has to stay while either message does.
+func unreferencedPaths(tx *sql.Tx, paths []string) ([]string, error) {
+	var unreferenced []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM messages WHERE image_url = ? OR poster_path = ?", path, path).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			unreferenced = append(unreferenced, path)
+		}
+	}
+	return unreferenced, nil
+}
+
+// runDeletion executes a deletion inside a transaction and only returns media paths once it committed.
+// A deletion that finds no messages still commits, as the ledgers of the shared store keep entries of
+// messages stored in isolated pipelines' databases.
+func (store *MessageStore) runDeletion(fn func(tx *sql.Tx) ([]string, int64, error)) ([]string, int64, error) {
+	var mediaPaths []string
+	var deleted int64
+	notFound := false
+	err := store.transaction(func(tx *sql.Tx) error {
+		var err error
+		if mediaPaths, deleted, err = fn(tx); err == sql.ErrNoRows {
+			notFound = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+		mediaPaths, err = unreferencedPaths(tx, mediaPaths)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if notFound {
+		return nil, 0, sql.ErrNoRows
+	}
+	return mediaPaths, deleted, nil
+}
+
+// DeleteChat removes a chat with all of its messages and links
+func (store *MessageStore) DeleteChat(chatJID string) ([]string, int64, error) {
+	return store.runDeletion(func(tx *sql.Tx) ([]string, int64, error) {
+		mediaPaths, deleted, err := deleteMessagesWhere(tx, "messages.chat_jid = ?", chatJID)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, table := range []string{"links", "calendar_events", "reactions", "face_cluster_faces", "face_matches"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE chat_jid = ?", chatJID); err != nil {
+				return nil, 0, err
+			}
+		}
+		result, err := tx.Exec("DELETE FROM chats WHERE jid = ?", chatJID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 && deleted == 0 {
+			return nil, 0, sql.ErrNoRows
+		}
+		return mediaPaths, deleted, nil
+	})
+}
+
+// DeleteMessage removes a single message, optionally restricted to one chat
+func (store *MessageStore) DeleteMessage(id, chatJID string) ([]string, int64, error) {
+	return store.runDeletion(func(tx *sql.Tx) ([]string, int64, error) {
+		where, args := "messages.id = ?", []interface{}{id}
+		if chatJID != "" {
+			where += " AND messages.chat_jid = ?"
+			args = append(args, chatJID)
+		}
+		mediaPaths, deleted, err := deleteMessagesWhere(tx, where, args...)
+		if err == nil && deleted == 0 {
+			err = sql.ErrNoRows
+		}
+		return mediaPaths, deleted, err
+	})
+}
+
+// ErrInvalidSender is returned for a sender that is neither a phone number nor a user JID
+var ErrInvalidSender = errors.New("invalid sender")
+
+// senderUser returns the user part of a sender given as a phone number, with or without "+", or as a
+// (device) JID. Users are digits, phone numbers and LIDs alike.
+func senderUser(sender string) (string, error) {
+	user := strings.TrimPrefix(sender, "+")
+	if i := strings.IndexAny(user, "@:"); i >= 0 {
+		user = user[:i]
+	}
+	if user == "" || strings.TrimLeft(user, "0123456789") != "" {
+		return "", ErrInvalidSender
+	}
+	return user, nil
+}
+
+// DeleteMessagesBySender removes every message sent by the given phone number or JID across all chats.
+// Returns ErrInvalidSender for anything else.
+func (store *MessageStore) DeleteMessagesBySender(sender string) ([]string, int64, error) {
+	// Senders are stored either as a bare phone number or as a (device) JID, so match on the user part
+	user, err := senderUser(sender)
+	if err != nil {
+		return nil, 0, err
+	}
+	args := []interface{}{user, len(user) + 1, user + "@", user + ":"}
+	return store.runDeletion(func(tx *sql.Tx) ([]string, int64, error) {
+		// Their reactions to other people's messages go too
+		if _, err := tx.Exec("DELETE FROM reactions WHERE sender = ? OR substr(sender, 1, ?) IN (?, ?)", args...); err != nil {
+			return nil, 0, err
+		}
+		return deleteMessagesWhere(tx, "(messages.sender = ? OR substr(messages.sender, 1, ?) IN (?, ?))", args...)
+	})
+}
+
+// RemoveMediaFiles deletes media files from disk, returning how many were removed and any failures.
+// Copies still waiting for a face filter are deleted as well.
+func RemoveMediaFiles(paths []string) (int, []string) {
+	removed := 0
+	var failures []string
+	queues := media.QueueDirs()
+	for _, path := range paths {
+		for _, queue := range queues {
+			if queued := filepath.Join(queue, filepath.Base(path)); queued != filepath.Clean(path) {
+				if err := os.Remove(queued); err != nil && !os.IsNotExist(err) {
+					failures = append(failures, fmt.Sprintf("%s: %v", queued, err))
+				}
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		removed++
+	}
+	return removed, failures
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Event types written to the events table
+const (
+	EventMessageStored  = "message.stored"
+	EventMessageUpdated = "message.updated"
+	EventMessageDeleted = "message.deleted"
+)
+
+// Event is an entry of the append-only change log of stored messages. Seq increases with every event,
+// so consumers resume with the last seq they processed.
+type Event struct {
+	Seq       int64         `json:"seq"`
+	Type      string        `json:"type"`
+	MessageID string        `json:"message_id"`
+	ChatJID   string        `json:"chat_jid"`
+	Message   *ExportRecord `json:"message,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// recordMessageEvent appends the stored state of a message to the events table inside tx: message.stored
+// the first time, message.updated when it is stored again
+func recordMessageEvent(tx *sql.Tx, id, chatJID string) error {
+	record, err := scanExportRecord(tx.QueryRow(exportQuery+" WHERE messages.id = ? AND messages.chat_jid = ?", id, chatJID))
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// A message is new unless its latest event says it is still stored
+	_, err = tx.Exec(`INSERT INTO events (type, message_id, chat_jid, payload, timestamp)
+		SELECT CASE COALESCE((SELECT type FROM events WHERE message_id = ? AND chat_jid = ? ORDER BY seq DESC LIMIT 1), ?)
+			WHEN ? THEN ? ELSE ? END, ?, ?, ?, ?`,
+		id, chatJID, EventMessageDeleted, EventMessageDeleted, EventMessageStored, EventMessageUpdated,
+		id, chatJID, string(payload), time.Now(),
+	)
+	return err
+}
+
+// GetEvents returns up to limit events after seq in order, optionally only those of chatJID
+func (store *MessageStore) GetEvents(afterSeq int64, chatJID string, limit int) ([]Event, error) {
+	query := "SELECT seq, type, message_id, chat_jid, COALESCE(payload, ''), timestamp FROM events WHERE seq > ?"
+	args := []interface{}{afterSeq}
+	if chatJID != "" {
+		query += " AND chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY seq ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		var payload string
+		if err := rows.Scan(&event.Seq, &event.Type, &event.MessageID, &event.ChatJID, &payload, &event.Timestamp); err != nil {
+			return nil, err
+		}
+		if payload != "" {
+			event.Message = &ExportRecord{}
+			if err := json.Unmarshal([]byte(payload), event.Message); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// GetPublishedSeq returns the seq of the last event the named publisher delivered, 0 if none
+func (store *MessageStore) GetPublishedSeq(name string) (int64, error) {
+	var seq int64
+	err := store.queryRow("SELECT seq FROM publisher_offsets WHERE name = ?", name).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// SetPublishedSeq records that the named publisher delivered every event up to seq
+func (store *MessageStore) SetPublishedSeq(name string, seq int64) error {
+	_, err := store.exec("INSERT OR REPLACE INTO publisher_offsets (name, seq) VALUES (?, ?)", name, seq)
+	return err
+}
+
+// GetLastEventSeq returns the seq of the newest event, 0 if there are none
+func (store *MessageStore) GetLastEventSeq() (int64, error) {
+	var seq int64
+	err := store.queryRow("SELECT COALESCE(MAX(seq), 0) FROM events").Scan(&seq)
+	return seq, err
+}
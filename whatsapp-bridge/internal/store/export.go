@@ -0,0 +1,209 @@
+package store
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"whatsapp-client/internal/config"
+)
+
+// ExportRecord is one message as written by the exporter
+type ExportRecord struct {
+	ID         string    `json:"id"`
+	ChatJID    string    `json:"chat_jid"`
+	ChatName   string    `json:"chat_name"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"sender_name,omitempty"`
+	Content    string    `json:"content"`
+	Timestamp  time.Time `json:"timestamp"`
+	IsFromMe   bool      `json:"is_from_me"`
+	MediaType  string    `json:"media_type,omitempty"`
+	MediaPath  string    `json:"media_path,omitempty"`
+	PosterPath string    `json:"poster_path,omitempty"`
+	Caption    string    `json:"caption,omitempty"`
+	Filename   string    `json:"filename,omitempty"`
+	MimeType   string    `json:"mime_type,omitempty"`
+	FileSize   int64     `json:"file_size,omitempty"`
+	QuotedID   string    `json:"quoted_id,omitempty"`
+	// Probable spam, with the rules that flagged it
+	Spam        bool   `json:"spam,omitempty"`
+	SpamReasons string `json:"spam_reasons,omitempty"`
+	// Topics the message was tagged with, comma-separated
+	Topics string `json:"topics,omitempty"`
+}
+
+// ExportFilter selects the messages to export; zero values mean "no restriction"
+type ExportFilter struct {
+	ChatJID string
+	From    time.Time
+	To      time.Time
+	// Only messages tagged with this topic
+	Topic string
+}
+
+// MediaManifestEntry describes a media file referenced by exported messages
+type MediaManifestEntry struct {
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Path      string    `json:"path"`
+	MediaType string    `json:"media_type"`
+	Size      int64     `json:"size"`
+	Exists    bool      `json:"exists"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var exportCSVHeader = []string{"id", "chat_jid", "chat_name", "sender", "sender_name", "content", "timestamp", "is_from_me", "media_type", "media_path", "caption", "filename", "mime_type", "file_size", "quoted_id", "poster_path", "spam", "spam_reasons", "topics"}
+
+// exportQuery selects messages with their chat name in the column order scanExportRecord expects
+const exportQuery = `SELECT messages.id, messages.chat_jid, COALESCE(chats.name, ''), messages.sender, COALESCE(messages.sender_name, ''), messages.content,
+	messages.timestamp, messages.is_from_me, COALESCE(messages.media_type, ''), COALESCE(messages.image_url, ''),
+	COALESCE(messages.caption, ''), COALESCE(messages.filename, ''), COALESCE(messages.mime_type, ''), COALESCE(messages.file_size, 0),
+	COALESCE(messages.quoted_id, ''), COALESCE(messages.poster_path, ''), messages.spam, COALESCE(messages.spam_reasons, ''),
+	COALESCE(messages.topics, '')
+	FROM messages LEFT JOIN chats ON chats.jid = messages.chat_jid`
+
+// scanExportRecord reads a row selected by exportQuery
+func scanExportRecord(row interface{ Scan(...interface{}) error }) (ExportRecord, error) {
+	var record ExportRecord
+	err := row.Scan(&record.ID, &record.ChatJID, &record.ChatName, &record.Sender, &record.SenderName, &record.Content,
+		&record.Timestamp, &record.IsFromMe, &record.MediaType, &record.MediaPath,
+		&record.Caption, &record.Filename, &record.MimeType, &record.FileSize, &record.QuotedID, &record.PosterPath,
+		&record.Spam, &record.SpamReasons, &record.Topics)
+	return record, err
+}
+
+// ForEachMessage calls fn for every message matching the filter in chronological order
+func (store *MessageStore) ForEachMessage(filter ExportFilter, fn func(ExportRecord) error) error {
+	where, args := filterClause("messages.", filter)
+	query := exportQuery + " WHERE 1 = 1" + where + " ORDER BY messages.timestamp ASC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record, err := scanExportRecord(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Export writes matching messages to w as "jsonl" or "csv" and returns the media manifest. Times are
+// written in the configured timezone.
+func (store *MessageStore) Export(filter ExportFilter, format string, w io.Writer) ([]MediaManifestEntry, error) {
+	location := config.Current().Location()
+	manifest := []MediaManifestEntry{}
+	addToManifest := func(record ExportRecord) {
+		if record.MediaPath == "" {
+			return
+		}
+		entry := MediaManifestEntry{
+			MessageID: record.ID,
+			ChatJID:   record.ChatJID,
+			Path:      record.MediaPath,
+			MediaType: record.MediaType,
+			Timestamp: record.Timestamp,
+		}
+		if info, err := os.Stat(record.MediaPath); err == nil {
+			entry.Exists = true
+			entry.Size = info.Size()
+		}
+		manifest = append(manifest, entry)
+	}
+
+	switch format {
+	case "jsonl", "":
+		encoder := json.NewEncoder(w)
+		err := store.ForEachMessage(filter, func(record ExportRecord) error {
+			record.Timestamp = record.Timestamp.In(location)
+			addToManifest(record)
+			return encoder.Encode(record)
+		})
+		return manifest, err
+
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(exportCSVHeader); err != nil {
+			return nil, err
+		}
+		err := store.ForEachMessage(filter, func(record ExportRecord) error {
+			record.Timestamp = record.Timestamp.In(location)
+			addToManifest(record)
+			return writer.Write([]string{
+				record.ID, record.ChatJID, record.ChatName, record.Sender, record.SenderName, record.Content,
+				record.Timestamp.Format(time.RFC3339), strconv.FormatBool(record.IsFromMe),
+				record.MediaType, record.MediaPath, record.Caption, record.Filename, record.MimeType,
+				strconv.FormatInt(record.FileSize, 10), record.QuotedID, record.PosterPath,
+				strconv.FormatBool(record.Spam), record.SpamReasons, record.Topics,
+			})
+		})
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+		return manifest, err
+
+	default:
+		return nil, fmt.Errorf("unsupported export format %q (use jsonl or csv)", format)
+	}
+}
+
+// parseExportDate parses a YYYY-MM-DD date in the configured timezone; an empty string yields the zero time
+func parseExportDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, config.Current().Location())
+}
+
+// ParseExportFilter builds an ExportFilter from a chat JID, inclusive from/to dates and a topic
+func ParseExportFilter(chatJID, from, to, topic string) (ExportFilter, error) {
+	filter := ExportFilter{ChatJID: chatJID, Topic: topic}
+	var err error
+	if filter.From, err = parseExportDate(from); err != nil {
+		return filter, fmt.Errorf("invalid from date: %v", err)
+	}
+	if filter.To, err = parseExportDate(to); err != nil {
+		return filter, fmt.Errorf("invalid to date: %v", err)
+	}
+	if !filter.To.IsZero() {
+		// The to date is inclusive, so export up to the start of the following day
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// ExportToFile writes an export file and its media manifest (<path>.manifest.json) to disk
+func (store *MessageStore) ExportToFile(filter ExportFilter, format, outPath string) (int, error) {
+	out, err := os.Create(outPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", outPath, err)
+	}
+	defer out.Close()
+
+	manifest, err := store.Export(filter, format, out)
+	if err != nil {
+		return 0, err
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(outPath+".manifest.json", manifestData, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return len(manifest), nil
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// Feedback parents give on a face match decision
+const (
+	// The photo was forwarded but isn't of the child
+	FeedbackWrong = "wrong"
+	// The photo is of the child but wasn't forwarded
+	FeedbackMissed = "missed"
+	// The decision was right, either way
+	FeedbackCorrect = "correct"
+)
+
+// FaceMatch is a decision of the face filter on whether a photo shows a destination's child
+type FaceMatch struct {
+	ID        int64  `json:"id"`
+	MediaPath string `json:"media_path"`
+	MessageID string `json:"message_id,omitempty"`
+	ChatJID   string `json:"chat_jid,omitempty"`
+	// The photo's message media served by the API, to review the decision
+	MediaURL    string `json:"media_url,omitempty"`
+	Destination string `json:"destination"`
+	// Distance of the closest face in the photo to the child's reference faces; nil if the photo has no faces
+	Distance *float64 `json:"distance"`
+	// The distance a reference face had to be under to count
+	Threshold float64 `json:"threshold"`
+	// How many reference faces were close enough, out of how many
+	MatchedCount    int        `json:"matched_count"`
+	TotalReferences int        `json:"total_references"`
+	Matched         bool       `json:"matched"`
+	Feedback        string     `json:"feedback,omitempty"`
+	FeedbackAt      *time.Time `json:"feedback_at,omitempty"`
+	Timestamp       time.Time  `json:"timestamp"`
+}
+
+// FaceMatchFilter selects face match decisions; zero values match everything
+type FaceMatchFilter struct {
+	Destination string
+	Matched     *bool
+	// A feedback value, or "none" for decisions without feedback
+	Feedback string
+	Limit    int
+}
+
+// ThresholdTuning is the distance threshold tuned for a destination from the feedback on its decisions
+type ThresholdTuning struct {
+	Threshold float64 `json:"threshold"`
+	// Whether there was enough feedback to move the threshold from the base
+	Tuned bool `json:"tuned"`
+	// Feedback counted: photos wrongly forwarded, missed, and decisions confirmed right
+	Wrong   int `json:"wrong"`
+	Missed  int `json:"missed"`
+	Correct int `json:"correct"`
+	// Reported mistakes the tuned threshold would still make
+	Errors int `json:"errors"`
+}
+
+// RecordFaceMatches stores the decisions of the face filter on a photo; IDs and Timestamps are assigned here
+func (store *MessageStore) RecordFaceMatches(matches []FaceMatch) error {
+	now := time.Now()
+	return store.transaction(func(tx *sql.Tx) error {
+		for _, m := range matches {
+			if _, err := tx.Exec(`INSERT INTO face_matches (media_path, message_id, chat_jid, destination, distance, threshold, matched_count, total_references, matched, timestamp)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				m.MediaPath, m.MessageID, m.ChatJID, m.Destination, m.Distance, m.Threshold, m.MatchedCount, m.TotalReferences, m.Matched, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// faceMatchColumns are the columns scanned by scanFaceMatch
+const faceMatchColumns = `id, COALESCE(media_path, ''), COALESCE(message_id, ''), COALESCE(chat_jid, ''), destination, distance,
+	threshold, matched_count, total_references, matched, COALESCE(feedback, ''), feedback_at, timestamp`
+
+// scanFaceMatch reads a row of faceMatchColumns
+func scanFaceMatch(scan func(dest ...interface{}) error) (FaceMatch, error) {
+	var m FaceMatch
+	var distance sql.NullFloat64
+	var feedbackAt sql.NullTime
+	err := scan(&m.ID, &m.MediaPath, &m.MessageID, &m.ChatJID, &m.Destination, &distance,
+		&m.Threshold, &m.MatchedCount, &m.TotalReferences, &m.Matched, &m.Feedback, &feedbackAt, &m.Timestamp)
+	if distance.Valid {
+		m.Distance = &distance.Float64
+	}
+	if feedbackAt.Valid {
+		m.FeedbackAt = &feedbackAt.Time
+	}
+	if m.MessageID != "" {
+		m.MediaURL = mediaURL(m.MessageID, m.ChatJID)
+	}
+	return m, err
+}
+
+// GetFaceMatches returns the most recent decisions matching filter
+func (store *MessageStore) GetFaceMatches(filter FaceMatchFilter) ([]FaceMatch, error) {
+	query := "SELECT " + faceMatchColumns + " FROM face_matches WHERE 1 = 1"
+	var args []interface{}
+	if filter.Destination != "" {
+		query += " AND destination = ?"
+		args = append(args, filter.Destination)
+	}
+	if filter.Matched != nil {
+		query += " AND matched = ?"
+		args = append(args, *filter.Matched)
+	}
+	if filter.Feedback == "none" {
+		query += " AND COALESCE(feedback, '') = ''"
+	} else if filter.Feedback != "" {
+		query += " AND feedback = ?"
+		args = append(args, filter.Feedback)
+	}
+	query += " ORDER BY timestamp DESC, id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []FaceMatch{}
+	for rows.Next() {
+		m, err := scanFaceMatch(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// GetFaceMatch returns a decision. Returns sql.ErrNoRows if there is no such decision.
+func (store *MessageStore) GetFaceMatch(id int64) (*FaceMatch, error) {
+	m, err := scanFaceMatch(store.queryRow("SELECT "+faceMatchColumns+" FROM face_matches WHERE id = ?", id).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SetFaceMatchFeedback records feedback on a decision, or clears it with an empty feedback
+func (store *MessageStore) SetFaceMatchFeedback(id int64, feedback string) error {
+	var feedbackAt interface{}
+	if feedback != "" {
+		feedbackAt = time.Now()
+	}
+	result, err := store.exec("UPDATE face_matches SET feedback = ?, feedback_at = ? WHERE id = ?", feedback, feedbackAt, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// labelledDistance is the distance of a decision with whether the photo really showed the child
+type labelledDistance struct {
+	distance float64
+	isChild  bool
+}
+
+// TuneThresholds finds, per destination, the distance threshold that best separates the photos of the
+// child from the others, moving at most maxShift from base. Forwarded photos nobody marked wrong count
+// as the child. Thresholds only move once a destination has minMistakes reported mistakes.
+func (store *MessageStore) TuneThresholds(base, maxShift float64, minMistakes int) (map[string]ThresholdTuning, error) {
+	rows, err := store.query(`SELECT destination, distance, matched, COALESCE(feedback, '') FROM face_matches
+		WHERE distance IS NOT NULL AND (matched = 1 OR COALESCE(feedback, '') != '')`)
+	if err != nil {
+		return nil, err
+	}
+	samples := make(map[string][]labelledDistance)
+	tunings := make(map[string]ThresholdTuning)
+	for rows.Next() {
+		var destination, feedback string
+		var distance float64
+		var matched bool
+		if err := rows.Scan(&destination, &distance, &matched, &feedback); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tuning := tunings[destination]
+		switch feedback {
+		case FeedbackWrong:
+			tuning.Wrong++
+		case FeedbackMissed:
+			tuning.Missed++
+		case FeedbackCorrect:
+			tuning.Correct++
+		}
+		tunings[destination] = tuning
+		isChild := (matched && feedback != FeedbackWrong) || (!matched && feedback == FeedbackMissed)
+		samples[destination] = append(samples[destination], labelledDistance{distance, isChild})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for destination, tuning := range tunings {
+		tuning.Threshold = base
+		tuning.Errors = thresholdErrors(samples[destination], base)
+		if tuning.Wrong+tuning.Missed >= minMistakes {
+			tuning.Threshold, tuning.Errors = bestThreshold(samples[destination], base, maxShift)
+			tuning.Tuned = true
+		}
+		tunings[destination] = tuning
+	}
+	return tunings, nil
+}
+
+// thresholdErrors counts the samples a threshold gets wrong: other children under it and the child not
+func thresholdErrors(samples []labelledDistance, threshold float64) int {
+	errors := 0
+	for _, s := range samples {
+		if s.isChild != (s.distance < threshold) {
+			errors++
+		}
+	}
+	return errors
+}
+
+// bestThreshold returns the threshold within maxShift of base with the fewest errors, the one closest
+// to base among equally good ones. Candidates lie halfway between neighbouring distances.
+func bestThreshold(samples []labelledDistance, base, maxShift float64) (float64, int) {
+	distances := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		distances = append(distances, s.distance)
+	}
+	sort.Float64s(distances)
+	candidates := []float64{base, base - maxShift, base + maxShift}
+	for i := 1; i < len(distances); i++ {
+		if middle := (distances[i-1] + distances[i]) / 2; math.Abs(middle-base) <= maxShift {
+			candidates = append(candidates, middle)
+		}
+	}
+
+	best, bestErrors := base, thresholdErrors(samples, base)
+	for _, candidate := range candidates {
+		errors := thresholdErrors(samples, candidate)
+		if errors < bestErrors || (errors == bestErrors && math.Abs(candidate-base) < math.Abs(best-base)) {
+			best, bestErrors = candidate, errors
+		}
+	}
+	// Rounded, so the threshold doesn't wobble with every new decision
+	best = math.Round(best*1000) / 1000
+	return best, thresholdErrors(samples, best)
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"whatsapp-client/internal/media"
+)
+
+// Face is a face the face filter service found in a stored photo
+type Face struct {
+	ID        int64  `json:"id"`
+	MediaPath string `json:"media_path"`
+	MessageID string `json:"message_id,omitempty"`
+	ChatJID   string `json:"chat_jid,omitempty"`
+	// Where the face is in the photo, in pixels: top, right, bottom, left
+	Box [4]int `json:"box"`
+	// The face's 128-dimensional encoding, only sent by the face filter service
+	Encoding []float64 `json:"encoding,omitempty"`
+	// The face cropped from its photo, served by the API
+	URL string `json:"url,omitempty"`
+}
+
+// FaceCluster is a group of similar faces, probably of the same person
+type FaceCluster struct {
+	ID int64 `json:"id"`
+	// The destination parents said the faces belong to; empty until labelled
+	Label string `json:"label,omitempty"`
+	Size  int    `json:"size"`
+	// A sample of the faces, or all of them for a single cluster
+	Faces []Face `json:"faces"`
+}
+
+// faceKey identifies a face across clustering runs, which see the same photo under the same file name
+func faceKey(mediaPath string, box [4]int) string {
+	return fmt.Sprintf("%s|%d,%d,%d,%d", media.FileKey(mediaPath), box[0], box[1], box[2], box[3])
+}
+
+// ReplaceFaceClusters stores the clusters of a new clustering run in place of the previous ones. Each
+// new cluster keeps the label most of its faces had before, so parents only label a child once.
+// Returns the number of clusters that kept a label.
+func (store *MessageStore) ReplaceFaceClusters(clusters [][]Face) (int, error) {
+	kept := 0
+	err := store.transaction(func(tx *sql.Tx) error {
+		kept = 0
+		labels := make(map[string]string)
+		rows, err := tx.Query(`SELECT f.media_path, f.box_top, f.box_right, f.box_bottom, f.box_left, c.label
+			FROM face_cluster_faces f JOIN face_clusters c ON c.id = f.cluster_id WHERE COALESCE(c.label, '') != ''`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var path, label string
+			var box [4]int
+			if err := rows.Scan(&path, &box[0], &box[1], &box[2], &box[3], &label); err != nil {
+				rows.Close()
+				return err
+			}
+			labels[faceKey(path, box)] = label
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM face_cluster_faces"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM face_clusters"); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, faces := range clusters {
+			if len(faces) == 0 {
+				continue
+			}
+			votes := make(map[string]int)
+			label := ""
+			for _, face := range faces {
+				if l := labels[faceKey(face.MediaPath, face.Box)]; l != "" {
+					votes[l]++
+					if votes[l] > votes[label] || (votes[l] == votes[label] && l < label) {
+						label = l
+					}
+				}
+			}
+			if label != "" {
+				kept++
+			}
+
+			result, err := tx.Exec("INSERT INTO face_clusters (label, created_at) VALUES (?, ?)", label, now)
+			if err != nil {
+				return err
+			}
+			clusterID, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			for _, face := range faces {
+				encoding, err := json.Marshal(face.Encoding)
+				if err != nil {
+					return err
+				}
+				if _, err := tx.Exec(`INSERT INTO face_cluster_faces (cluster_id, media_path, message_id, chat_jid, box_top, box_right, box_bottom, box_left, encoding)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					clusterID, face.MediaPath, face.MessageID, face.ChatJID, face.Box[0], face.Box[1], face.Box[2], face.Box[3], string(encoding)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return kept, err
+}
+
+// GetFaceClusters returns the clusters, largest first, each with up to samples of its faces
+func (store *MessageStore) GetFaceClusters(samples int) ([]FaceCluster, error) {
+	rows, err := store.query(`SELECT c.id, COALESCE(c.label, ''), COUNT(f.id)
+		FROM face_clusters c JOIN face_cluster_faces f ON f.cluster_id = c.id
+		GROUP BY c.id ORDER BY COUNT(f.id) DESC, c.id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	clusters := []FaceCluster{}
+	for rows.Next() {
+		var cluster FaceCluster
+		if err := rows.Scan(&cluster.ID, &cluster.Label, &cluster.Size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range clusters {
+		if clusters[i].Faces, err = store.clusterFaces(clusters[i].ID, samples, false); err != nil {
+			return nil, err
+		}
+	}
+	return clusters, nil
+}
+
+// GetFaceCluster returns a cluster with all its faces. Returns sql.ErrNoRows if there is no such cluster.
+func (store *MessageStore) GetFaceCluster(id int64) (*FaceCluster, error) {
+	cluster := FaceCluster{ID: id}
+	if err := store.queryRow("SELECT COALESCE(label, '') FROM face_clusters WHERE id = ?", id).Scan(&cluster.Label); err != nil {
+		return nil, err
+	}
+	faces, err := store.clusterFaces(id, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	cluster.Faces, cluster.Size = faces, len(faces)
+	return &cluster, nil
+}
+
+// clusterFaces returns up to limit (0 for all) faces of a cluster, with their encodings if asked for
+func (store *MessageStore) clusterFaces(clusterID int64, limit int, withEncoding bool) ([]Face, error) {
+	query := `SELECT id, media_path, COALESCE(message_id, ''), COALESCE(chat_jid, ''), box_top, box_right, box_bottom, box_left, COALESCE(encoding, '')
+		FROM face_cluster_faces WHERE cluster_id = ? ORDER BY id`
+	args := []interface{}{clusterID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	faces := []Face{}
+	for rows.Next() {
+		var face Face
+		var encoding string
+		if err := rows.Scan(&face.ID, &face.MediaPath, &face.MessageID, &face.ChatJID, &face.Box[0], &face.Box[1], &face.Box[2], &face.Box[3], &encoding); err != nil {
+			return nil, err
+		}
+		if withEncoding && encoding != "" {
+			if err := json.Unmarshal([]byte(encoding), &face.Encoding); err != nil {
+				return nil, fmt.Errorf("invalid encoding of face %d: %v", face.ID, err)
+			}
+		}
+		faces = append(faces, face)
+	}
+	return faces, rows.Err()
+}
+
+// GetFace returns a face of a cluster. Returns sql.ErrNoRows if the cluster has no such face.
+func (store *MessageStore) GetFace(clusterID, id int64) (*Face, error) {
+	var face Face
+	err := store.queryRow(`SELECT id, media_path, COALESCE(message_id, ''), COALESCE(chat_jid, ''), box_top, box_right, box_bottom, box_left
+		FROM face_cluster_faces WHERE cluster_id = ? AND id = ?`, clusterID, id).
+		Scan(&face.ID, &face.MediaPath, &face.MessageID, &face.ChatJID, &face.Box[0], &face.Box[1], &face.Box[2], &face.Box[3])
+	if err != nil {
+		return nil, err
+	}
+	return &face, nil
+}
+
+// LabelFaceCluster sets the destination a cluster's faces belong to, or clears it with an empty label.
+// Returns sql.ErrNoRows if there is no such cluster.
+func (store *MessageStore) LabelFaceCluster(id int64, label string) error {
+	result, err := store.exec("UPDATE face_clusters SET label = ? WHERE id = ?", label, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FaceLabelEncodings returns, per label, the encodings of up to perLabel faces of the clusters with that
+// label, those closest to their cluster's average first, for the face filter to match against like
+// reference photos
+func (store *MessageStore) FaceLabelEncodings(perLabel int) (map[string][][]float64, error) {
+	rows, err := store.query("SELECT id, label FROM face_clusters WHERE COALESCE(label, '') != '' ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	type labelled struct {
+		id    int64
+		label string
+	}
+	var clusters []labelled
+	for rows.Next() {
+		var c labelled
+		if err := rows.Scan(&c.id, &c.label); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		clusters = append(clusters, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string][]scoredEncoding)
+	for _, c := range clusters {
+		faces, err := store.clusterFaces(c.id, 0, true)
+		if err != nil {
+			return nil, err
+		}
+		candidates[c.label] = append(candidates[c.label], byCentrality(faces)...)
+	}
+	encodings := make(map[string][][]float64)
+	for label, scored := range candidates {
+		sort.SliceStable(scored, func(i, j int) bool { return scored[i].distance < scored[j].distance })
+		for i, s := range scored {
+			if perLabel > 0 && i == perLabel {
+				break
+			}
+			encodings[label] = append(encodings[label], s.encoding)
+		}
+	}
+	return encodings, nil
+}
+
+// scoredEncoding is a face encoding with its distance to the average of its cluster
+type scoredEncoding struct {
+	encoding []float64
+	distance float64
+}
+
+// byCentrality scores the encodings of a cluster's faces by their distance to the cluster's average
+func byCentrality(faces []Face) []scoredEncoding {
+	var mean []float64
+	count := 0
+	for _, face := range faces {
+		if len(face.Encoding) == 0 {
+			continue
+		}
+		if mean == nil {
+			mean = make([]float64, len(face.Encoding))
+		}
+		if len(face.Encoding) != len(mean) {
+			continue
+		}
+		for i, v := range face.Encoding {
+			mean[i] += v
+		}
+		count++
+	}
+	var scored []scoredEncoding
+	if count == 0 {
+		return scored
+	}
+	for i := range mean {
+		mean[i] /= float64(count)
+	}
+	for _, face := range faces {
+		if len(face.Encoding) != len(mean) {
+			continue
+		}
+		sum := 0.0
+		for i, v := range face.Encoding {
+			sum += (v - mean[i]) * (v - mean[i])
+		}
+		scored = append(scored, scoredEncoding{encoding: face.Encoding, distance: math.Sqrt(sum)})
+	}
+	return scored
+}
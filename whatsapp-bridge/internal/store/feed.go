@@ -0,0 +1,45 @@
+package store
+
+import "time"
+
+// FeedItem is a stored message published in a chat feed
+type FeedItem struct {
+	ID        string
+	Sender    string
+	Content   string
+	Timestamp time.Time
+	MediaType string
+	MediaPath string
+	Caption   string
+	// Poster frame, for videos
+	PosterPath string
+}
+
+// GetFeedItems returns the most recent photos and announcements of a chat, newest first. Probable spam
+// is left out.
+func (store *MessageStore) GetFeedItems(chatJID string, limit int) ([]FeedItem, error) {
+	rows, err := store.query(`SELECT id, COALESCE(NULLIF(sender_name, ''), sender), content, timestamp, COALESCE(media_type, ''), COALESCE(image_url, ''), COALESCE(caption, ''),
+		COALESCE(poster_path, '')
+		FROM messages WHERE chat_jid = ? AND (content != '' OR image_url != '') AND NOT spam ORDER BY timestamp DESC LIMIT ?`, chatJID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []FeedItem
+	for rows.Next() {
+		var item FeedItem
+		if err := rows.Scan(&item.ID, &item.Sender, &item.Content, &item.Timestamp, &item.MediaType, &item.MediaPath, &item.Caption, &item.PosterPath); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetFeedMediaPath returns the media file of a message in the given chat
+func (store *MessageStore) GetFeedMediaPath(chatJID, id string) (string, error) {
+	var path string
+	err := store.queryRow("SELECT image_url FROM messages WHERE id = ? AND chat_jid = ? AND image_url != ''", id, chatJID).Scan(&path)
+	return path, err
+}
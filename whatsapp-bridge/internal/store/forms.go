@@ -0,0 +1,145 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Form is a document or photo parents must fill in or sign, such as a permission slip
+type Form struct {
+	ID         int64  `json:"id"`
+	MessageID  string `json:"message_id"`
+	ChatJID    string `json:"chat_jid"`
+	ChatName   string `json:"chat_name,omitempty"`
+	Sender     string `json:"sender"`
+	SenderName string `json:"sender_name,omitempty"`
+	// Caption, and the text read from a photo of the form
+	Text string `json:"text,omitempty"`
+	// Name of a document, or the stored photo
+	FileName  string `json:"file_name,omitempty"`
+	MediaPath string `json:"media_path,omitempty"`
+	// When the form must be handed in, if the message said
+	DueAt          *time.Time `json:"due_at,omitempty"`
+	ReceivedAt     time.Time  `json:"received_at"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	// Reminders sent so far, and when the last one was
+	Reminders  int        `json:"reminders"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+}
+
+// formQuery selects forms with their chat name in the column order scanForm expects
+const formQuery = `SELECT forms.id, forms.message_id, forms.chat_jid, COALESCE(chats.name, ''), forms.sender,
+	COALESCE(forms.sender_name, ''), COALESCE(forms.text, ''), COALESCE(forms.file_name, ''), COALESCE(forms.media_path, ''),
+	forms.due_at, forms.received_at, forms.acknowledged, forms.acknowledged_at, forms.reminders, forms.reminded_at
+	FROM forms LEFT JOIN chats ON chats.jid = forms.chat_jid`
+
+// scanForm reads a row selected by formQuery
+func scanForm(row interface{ Scan(...interface{}) error }) (Form, error) {
+	var form Form
+	var dueAt, acknowledgedAt, remindedAt sql.NullTime
+	err := row.Scan(&form.ID, &form.MessageID, &form.ChatJID, &form.ChatName, &form.Sender, &form.SenderName, &form.Text,
+		&form.FileName, &form.MediaPath, &dueAt, &form.ReceivedAt, &form.Acknowledged, &acknowledgedAt, &form.Reminders, &remindedAt)
+	if dueAt.Valid {
+		form.DueAt = &dueAt.Time
+	}
+	if acknowledgedAt.Valid {
+		form.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	if remindedAt.Valid {
+		form.RemindedAt = &remindedAt.Time
+	}
+	return form, err
+}
+
+// StoreForm records a form. A message stored again, e.g. after an edit, keeps its form, reminders and
+// acknowledgment; created is false then.
+func (store *MessageStore) StoreForm(form Form) (id int64, created bool, err error) {
+	var dueAt interface{}
+	if form.DueAt != nil {
+		dueAt = *form.DueAt
+	}
+	result, err := store.exec(`INSERT OR IGNORE INTO forms (message_id, chat_jid, sender, sender_name, text, file_name, media_path, due_at, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		form.MessageID, form.ChatJID, form.Sender, form.SenderName, form.Text, form.FileName, form.MediaPath, dueAt, form.ReceivedAt)
+	if err != nil {
+		return 0, false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		id, err = result.LastInsertId()
+		return id, true, err
+	}
+	err = store.queryRow("SELECT id FROM forms WHERE message_id = ? AND chat_jid = ?", form.MessageID, form.ChatJID).Scan(&id)
+	return id, false, err
+}
+
+// GetForm returns a form by ID, or sql.ErrNoRows
+func (store *MessageStore) GetForm(id int64) (Form, error) {
+	return scanForm(store.queryRow(formQuery+" WHERE forms.id = ?", id))
+}
+
+// GetForms returns the forms of chatJID, or of every chat when it is empty, newest first: the open ones,
+// the acknowledged ones, or all with acknowledged nil
+func (store *MessageStore) GetForms(chatJID string, acknowledged *bool) ([]Form, error) {
+	query := formQuery + " WHERE 1 = 1"
+	var args []interface{}
+	if chatJID != "" {
+		query += " AND forms.chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	if acknowledged != nil {
+		query += " AND forms.acknowledged = ?"
+		args = append(args, *acknowledged)
+	}
+	query += " ORDER BY forms.received_at DESC"
+	return store.queryForms(query, args...)
+}
+
+// AcknowledgeForm marks a form as handled, which stops its reminders, or as open again. Returns
+// sql.ErrNoRows for an unknown ID.
+func (store *MessageStore) AcknowledgeForm(id int64, acknowledged bool) error {
+	var acknowledgedAt interface{}
+	if acknowledged {
+		acknowledgedAt = time.Now()
+	}
+	result, err := store.exec("UPDATE forms SET acknowledged = ?, acknowledged_at = ? WHERE id = ?", acknowledged, acknowledgedAt, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DueFormReminders returns the open forms not reminded about yet, or last reminded about before since,
+// oldest first
+func (store *MessageStore) DueFormReminders(since time.Time) ([]Form, error) {
+	return store.queryForms(formQuery+`
+		WHERE NOT forms.acknowledged AND (forms.reminded_at IS NULL OR forms.reminded_at <= ?)
+		ORDER BY forms.received_at ASC`, since)
+}
+
+// RecordFormReminder counts a reminder sent about a form
+func (store *MessageStore) RecordFormReminder(id int64, at time.Time) error {
+	_, err := store.exec("UPDATE forms SET reminders = reminders + 1, reminded_at = ? WHERE id = ?", at, id)
+	return err
+}
+
+// queryForms runs a query selecting formQuery's columns
+func (store *MessageStore) queryForms(query string, args ...interface{}) ([]Form, error) {
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	forms := []Form{}
+	for rows.Next() {
+		form, err := scanForm(rows)
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, form)
+	}
+	return forms, rows.Err()
+}
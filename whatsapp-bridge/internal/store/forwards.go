@@ -0,0 +1,97 @@
+package store
+
+import (
+	"time"
+
+	"whatsapp-client/internal/media"
+)
+
+// Forward is an entry of the forward ledger: a message the bridge sent, or would have sent in dry-run mode
+type Forward struct {
+	ID        int64  `json:"id"`
+	MessageID string `json:"message_id,omitempty"`
+	ChatJID   string `json:"chat_jid,omitempty"`
+	// Who posted the forwarded message, resolved from the messages table
+	SenderName  string    `json:"sender_name,omitempty"`
+	Destination string    `json:"destination"`
+	MediaPath   string    `json:"media_path,omitempty"`
+	Caption     string    `json:"caption,omitempty"`
+	DryRun      bool      `json:"dry_run"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// FindMessageByMedia returns the stored message a media file belongs to
+func (store *MessageStore) FindMessageByMedia(path string) (string, string, bool) {
+	if path == "" {
+		return "", "", false
+	}
+	var id, chatJID string
+	err := store.queryRow("SELECT id, chat_jid FROM messages WHERE image_url = ? OR image_url LIKE ? LIMIT 1",
+		path, "%/"+media.FileKey(path)).Scan(&id, &chatJID)
+	if err != nil {
+		return "", "", false
+	}
+	return id, chatJID, true
+}
+
+// RecordForward adds an entry to the forward ledger; its ID and Timestamp are assigned here
+func (store *MessageStore) RecordForward(f Forward) error {
+	_, err := store.exec(
+		"INSERT INTO forward_log (message_id, chat_jid, destination, media_path, caption, dry_run, request_id, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		f.MessageID, f.ChatJID, f.Destination, f.MediaPath, f.Caption, f.DryRun, f.RequestID, time.Now(),
+	)
+	return err
+}
+
+// HasForwarded reports whether the ledger holds a real (not dry-run) send of a message to a destination
+// with the given caption
+func (store *MessageStore) HasForwarded(messageID, chatJID, destination, caption string) (bool, error) {
+	var count int
+	err := store.queryRow(
+		"SELECT COUNT(*) FROM forward_log WHERE message_id = ? AND chat_jid = ? AND destination = ? AND COALESCE(caption, '') = ? AND dry_run = 0",
+		messageID, chatJID, destination, caption,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// GetForwards returns the most recent ledger entries, optionally only dry-run or only real ones
+func (store *MessageStore) GetForwards(dryRun *bool, limit int) ([]Forward, error) {
+	query := `SELECT f.id, COALESCE(f.message_id, ''), COALESCE(f.chat_jid, ''), COALESCE(m.sender_name, ''), f.destination,
+		COALESCE(f.media_path, ''), COALESCE(f.caption, ''), f.dry_run, COALESCE(f.request_id, ''), f.timestamp
+		FROM forward_log f LEFT JOIN messages m ON m.id = f.message_id AND m.chat_jid = f.chat_jid`
+	var args []interface{}
+	if dryRun != nil {
+		query += " WHERE f.dry_run = ?"
+		args = append(args, *dryRun)
+	}
+	query += " ORDER BY f.timestamp DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forwards := []Forward{}
+	for rows.Next() {
+		var f Forward
+		if err := rows.Scan(&f.ID, &f.MessageID, &f.ChatJID, &f.SenderName, &f.Destination, &f.MediaPath, &f.Caption, &f.DryRun, &f.RequestID, &f.Timestamp); err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, f)
+	}
+	return forwards, rows.Err()
+}
+
+// CountForwardedPhotos returns how many different photos the ledger shows forwarded (or dry-run
+// forwarded) to destination since the given time
+func (store *MessageStore) CountForwardedPhotos(destination string, since time.Time) (int, error) {
+	var count int
+	err := store.queryRow(
+		"SELECT COUNT(DISTINCT COALESCE(NULLIF(message_id, ''), media_path)) FROM forward_log WHERE destination = ? AND COALESCE(media_path, '') != '' AND timestamp >= ?",
+		destination, since,
+	).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IncomingMessage is a received message together with everything stored about it
+type IncomingMessage struct {
+	ID      string
+	ChatJID string
+	// Name of the chat; empty keeps the stored name
+	ChatName     string
+	Sender       string
+	SenderName   string
+	Content      string
+	Timestamp    time.Time
+	IsFromMe     bool
+	ImageURL     string
+	ThumbnailURL string
+	MediaType    string
+	// Poster frame of a video, if one could be made
+	PosterPath string
+	// Optional: download keys and details of the media, and the message it replies to
+	MediaKeys *MediaKeys
+	Details   *MediaDetails
+	Reply     *ReplyContext
+	// Probable spam, with the rules that flagged it
+	Spam        bool
+	SpamReasons string
+}
+
+// StoreIncoming stores a message, its chat and its event in one transaction, so a crash can't leave a
+// message without its chat or an event consumers never see. The chat's last message time only moves
+// forward, so storing older history doesn't make a chat look idle. Messages without text or media are
+// skipped.
+func (store *MessageStore) StoreIncoming(msg IncomingMessage) error {
+	if msg.Content == "" && msg.ImageURL == "" {
+		return nil
+	}
+	keys := msg.MediaKeys
+	if keys == nil {
+		keys = &MediaKeys{}
+	}
+	details := msg.Details
+	if details == nil {
+		details = &MediaDetails{}
+	}
+	reply := msg.Reply
+	if reply == nil {
+		reply = &ReplyContext{}
+	}
+
+	return store.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO chats (jid, name, last_message_time) VALUES (?, ?, ?)
+			ON CONFLICT (jid) DO UPDATE SET
+				name = COALESCE(NULLIF(excluded.name, ''), chats.name),
+				last_message_time = CASE WHEN chats.last_message_time IS NULL OR excluded.last_message_time > chats.last_message_time
+					THEN excluded.last_message_time ELSE chats.last_message_time END`,
+			msg.ChatJID, msg.ChatName, msg.Timestamp,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT OR REPLACE INTO messages (id, chat_jid, sender, sender_name, content, timestamp, is_from_me,
+				image_url, thumbnail_url, media_type, media_key, direct_path, file_sha256, file_enc_sha256, file_length,
+				caption, filename, mime_type, file_size, quoted_id, quoted_sender, quoted_snippet, poster_path, spam, spam_reasons)
+			VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0),
+				NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, NULLIF(?, ''))`,
+			msg.ID, msg.ChatJID, msg.Sender, msg.SenderName, msg.Content, msg.Timestamp, msg.IsFromMe,
+			msg.ImageURL, msg.ThumbnailURL, msg.MediaType, keys.MediaKey, keys.DirectPath, keys.FileSHA256, keys.FileEncSHA256, keys.FileLength,
+			details.Caption, details.Filename, details.MimeType, details.FileSize,
+			reply.QuotedID, reply.QuotedSender, reply.QuotedSnippet, msg.PosterPath, msg.Spam, msg.SpamReasons,
+		)
+		if err != nil {
+			return err
+		}
+
+		return recordMessageEvent(tx, msg.ID, msg.ChatJID)
+	})
+}
@@ -0,0 +1,77 @@
+package store
+
+import "time"
+
+// Link represents a URL shared in a stored message
+type Link struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Sender    string    `json:"sender"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// LinkTitleJob is a pending asynchronous title lookup for an archived link
+type LinkTitleJob struct {
+	ID  int64
+	URL string
+}
+
+// StoreLinks records the URLs shared in a message and returns title lookups for the newly added links
+func (store *MessageStore) StoreLinks(messageID, chatJID, sender string, urls []string, timestamp time.Time) ([]LinkTitleJob, error) {
+	var added []LinkTitleJob
+	for _, u := range urls {
+		result, err := store.exec(
+			"INSERT OR IGNORE INTO links (url, message_id, chat_jid, sender, timestamp) VALUES (?, ?, ?, ?, ?)",
+			u, messageID, chatJID, sender, timestamp,
+		)
+		if err != nil {
+			return added, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return added, err
+		}
+		added = append(added, LinkTitleJob{ID: id, URL: u})
+	}
+	return added, nil
+}
+
+// SetLinkTitle updates the fetched title of an archived link
+func (store *MessageStore) SetLinkTitle(id int64, title string) error {
+	_, err := store.exec("UPDATE links SET title = ? WHERE id = ?", title, id)
+	return err
+}
+
+// GetLinks returns archived links, newest first, optionally filtered by chat
+func (store *MessageStore) GetLinks(chatJID string, limit int) ([]Link, error) {
+	query := "SELECT id, url, COALESCE(title, ''), message_id, chat_jid, sender, timestamp FROM links"
+	var args []interface{}
+	if chatJID != "" {
+		query += " WHERE chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY timestamp DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []Link{}
+	for rows.Next() {
+		var link Link
+		if err := rows.Scan(&link.ID, &link.URL, &link.Title, &link.MessageID, &link.ChatJID, &link.Sender, &link.Timestamp); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"whatsapp-client/internal/config"
+)
+
+// LogEntry is a warning or error line of the bridge's log
+type LogEntry struct {
+	ID        int64     `json:"id"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SaveLogEntries stores log lines in one transaction
+func (store *MessageStore) SaveLogEntries(entries []LogEntry) error {
+	return store.transaction(func(tx *sql.Tx) error {
+		for _, entry := range entries {
+			if _, err := tx.Exec("INSERT INTO logs (level, message, timestamp) VALUES (?, ?, ?)",
+				entry.Level, entry.Message, entry.Timestamp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetLogEntries returns up to limit log lines since a time, newest first. Level warn includes errors.
+func (store *MessageStore) GetLogEntries(level string, since time.Time, limit int) ([]LogEntry, error) {
+	query := "SELECT id, level, message, timestamp FROM logs WHERE timestamp >= ?"
+	args := []interface{}{since}
+	if level == config.LogLevelError {
+		query += " AND level = ?"
+		args = append(args, config.LogLevelError)
+	}
+	query += " ORDER BY timestamp DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LogEntry{}
+	for rows.Next() {
+		var entry LogEntry
+		if err := rows.Scan(&entry.ID, &entry.Level, &entry.Message, &entry.Timestamp); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteLogEntriesBefore removes log lines older than a time and returns how many were removed
+func (store *MessageStore) DeleteLogEntriesBefore(before time.Time) (int64, error) {
+	result, err := store.exec("DELETE FROM logs WHERE timestamp < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
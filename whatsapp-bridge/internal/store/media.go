@@ -0,0 +1,202 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MediaKeys holds what is needed to download a media attachment again after the fact
+type MediaKeys struct {
+	MediaKey      []byte
+	DirectPath    string
+	FileSHA256    []byte
+	FileEncSHA256 []byte
+	FileLength    uint64
+}
+
+// MediaDetails describes the attachment of a stored message, so clients can render galleries
+type MediaDetails struct {
+	Caption  string
+	Filename string
+	MimeType string
+	FileSize int64
+}
+
+// MissingMedia describes a message whose media file is no longer on disk
+type MissingMedia struct {
+	MessageID    string `json:"message_id"`
+	ChatJID      string `json:"chat_jid"`
+	Path         string `json:"path"`
+	Redownloaded bool   `json:"redownloaded"`
+	Error        string `json:"error,omitempty"`
+}
+
+// MediaVerifyReport is the result of cross-checking the messages table against the media directory
+type MediaVerifyReport struct {
+	CheckedAt    time.Time      `json:"checked_at"`
+	Referenced   int            `json:"referenced"`
+	FilesOnDisk  int            `json:"files_on_disk"`
+	Missing      []MissingMedia `json:"missing"`
+	Orphans      []string       `json:"orphans"`
+	Redownloaded int            `json:"redownloaded"`
+}
+
+// MediaRef is a messages row that references a media file
+type MediaRef struct {
+	ID        string
+	ChatJID   string
+	Path      string
+	MediaType string
+	Timestamp time.Time
+	Keys      MediaKeys
+	// Poster frame, for videos
+	PosterPath string
+	// Probable spam, which isn't forwarded
+	Spam bool
+}
+
+// GetMediaRefs returns the message rows matching filter that reference a media file, oldest first
+func (store *MessageStore) GetMediaRefs(filter ExportFilter) ([]MediaRef, error) {
+	query := `SELECT id, chat_jid, image_url, COALESCE(media_type, ''), timestamp, media_key, COALESCE(direct_path, ''), file_sha256, file_enc_sha256, COALESCE(file_length, 0),
+		COALESCE(poster_path, ''), spam
+		FROM messages WHERE image_url != ''`
+	var args []interface{}
+	if filter.ChatJID != "" {
+		query += " AND chat_jid = ?"
+		args = append(args, filter.ChatJID)
+	}
+	if !filter.From.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, filter.To)
+	}
+	query += " ORDER BY timestamp ASC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []MediaRef
+	for rows.Next() {
+		var ref MediaRef
+		if err := rows.Scan(&ref.ID, &ref.ChatJID, &ref.Path, &ref.MediaType, &ref.Timestamp, &ref.Keys.MediaKey, &ref.Keys.DirectPath, &ref.Keys.FileSHA256, &ref.Keys.FileEncSHA256, &ref.Keys.FileLength, &ref.PosterPath, &ref.Spam); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// VerifyMedia reports media files that are referenced but missing, and files nobody references.
+// When fetch is non-nil, missing files are fetched again with it and written back to their path.
+func (store *MessageStore) VerifyMedia(mediaDir string, fetch func(MediaRef) ([]byte, error)) (*MediaVerifyReport, error) {
+	refs, err := store.GetMediaRefs(ExportFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media references: %v", err)
+	}
+
+	report := &MediaVerifyReport{CheckedAt: time.Now(), Referenced: len(refs), Missing: []MissingMedia{}, Orphans: []string{}}
+	referenced := make(map[string]bool)
+	// Photos waiting for the face filter are links to stored files at the top of the media directory, or
+	// of a pipeline's directory in it
+	queued := make(map[string]bool)
+	for _, ref := range refs {
+		referenced[filepath.Clean(ref.Path)] = true
+		queued[filepath.Base(ref.Path)] = true
+		if ref.PosterPath != "" {
+			referenced[filepath.Clean(ref.PosterPath)] = true
+		}
+		if _, err := os.Stat(ref.Path); err == nil {
+			continue
+		}
+
+		missing := MissingMedia{MessageID: ref.ID, ChatJID: ref.ChatJID, Path: ref.Path}
+		if fetch != nil {
+			if err := restoreMediaFile(ref, fetch); err != nil {
+				missing.Error = err.Error()
+			} else {
+				missing.Redownloaded = true
+				report.Redownloaded++
+			}
+		}
+		report.Missing = append(report.Missing, missing)
+	}
+
+	err = filepath.Walk(mediaDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			// The media of isolated pipelines, stored in their directories, belongs to their own databases
+			if filepath.Dir(filepath.Dir(filepath.Clean(path))) == filepath.Join(mediaDir, "pipelines") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		report.FilesOnDisk++
+		dir := filepath.Dir(filepath.Clean(path))
+		inQueue := dir == filepath.Clean(mediaDir) || filepath.Dir(dir) == filepath.Join(mediaDir, "pipelines")
+		if !referenced[filepath.Clean(path)] && !(inQueue && queued[filepath.Base(path)]) {
+			report.Orphans = append(report.Orphans, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan media directory: %v", err)
+	}
+
+	return report, nil
+}
+
+// restoreMediaFile fetches a message's media again and writes it to its original path
+func restoreMediaFile(ref MediaRef, fetch func(MediaRef) ([]byte, error)) error {
+	data, err := fetch(ref)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ref.Path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(ref.Path, data, 0644)
+}
+
+// MediaFile is the stored media file of one message
+type MediaFile struct {
+	MessageID string
+	ChatJID   string
+	Path      string
+	MimeType  string
+	Filename  string
+	// Poster frame, for videos
+	PosterPath string
+}
+
+// GetMediaFile returns the media file of a message, optionally restricted to one chat. Returns
+// sql.ErrNoRows if the message isn't stored or has no media.
+func (store *MessageStore) GetMediaFile(id, chatJID string) (*MediaFile, error) {
+	query := `SELECT id, chat_jid, image_url, COALESCE(mime_type, ''), COALESCE(filename, ''), COALESCE(poster_path, '')
+		FROM messages WHERE id = ? AND image_url != ''`
+	args := []interface{}{id}
+	if chatJID != "" {
+		query += " AND chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY timestamp DESC LIMIT 1"
+
+	var file MediaFile
+	err := store.queryRow(query, args...).Scan(&file.MessageID, &file.ChatJID, &file.Path, &file.MimeType, &file.Filename, &file.PosterPath)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mediaDirSetting remembers the media directory the stored paths point into
+const mediaDirSetting = "media_dir"
+
+// getSetting returns a value from the settings table, or "" if it isn't set
+func (store *MessageStore) getSetting(key string) (string, error) {
+	var value string
+	err := store.queryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// RelocateMedia moves media files from the directory the store last used to dir when
+// media.store_path changed, and rewrites the paths stored for them. Stores from before the
+// directory was recorded used previous. Returns the number of files moved.
+func (store *MessageStore) RelocateMedia(previous, dir string) (int, error) {
+	old, err := store.getSetting(mediaDirSetting)
+	if err != nil {
+		return 0, err
+	}
+	if old == "" {
+		old = previous
+	}
+	old, dir = filepath.Clean(old), filepath.Clean(dir)
+
+	moved := 0
+	if old != dir {
+		oldAbs, err := filepath.Abs(old)
+		if err != nil {
+			return 0, err
+		}
+		dirAbs, err := filepath.Abs(dir)
+		if err != nil {
+			return 0, err
+		}
+		if oldAbs != dirAbs {
+			if strings.HasPrefix(dirAbs+string(filepath.Separator), oldAbs+string(filepath.Separator)) ||
+				strings.HasPrefix(oldAbs+string(filepath.Separator), dirAbs+string(filepath.Separator)) {
+				return 0, fmt.Errorf("can't move media from %s to %s, one is inside the other", old, dir)
+			}
+			if moved, err = moveTree(old, dir); err != nil {
+				return moved, err
+			}
+		}
+
+		// Paths are stored as written, so the same directory spelled differently needs them updated too
+		err = store.transaction(func(tx *sql.Tx) error {
+			oldPrefix, newPrefix := old+string(filepath.Separator), dir+string(filepath.Separator)
+			for _, column := range []struct{ table, name string }{{"messages", "image_url"}, {"messages", "poster_path"}, {"forward_log", "media_path"}} {
+				_, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? || substr(%s, ?) WHERE substr(%s, 1, ?) = ?", column.table, column.name, column.name, column.name),
+					newPrefix, len(oldPrefix)+1, len(oldPrefix), oldPrefix)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return moved, fmt.Errorf("failed to update media paths: %v", err)
+		}
+	}
+
+	return moved, store.SetSetting(mediaDirSetting, dir)
+}
+
+// moveTree moves every file below src to the same place below dst and removes the emptied
+// directories. Files that already exist in dst are left where they are.
+func moveTree(src, dst string) (int, error) {
+	moved := 0
+	var dirs []string
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("[MEDIA] Not moving %s, %s already exists\n", path, target)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := moveFile(path, target); err != nil {
+			return fmt.Errorf("failed to move %s: %v", path, err)
+		}
+		moved++
+		return nil
+	})
+	if err != nil {
+		return moved, err
+	}
+
+	// Deepest directories first; directories that still hold files stay
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+	return moved, nil
+}
+
+// moveFile renames src to dst, replacing dst, and copies it when they are on different file systems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// messageStoreMigrations upgrade an existing messages.db in order. The schema version is kept in
+// PRAGMA user_version, so a migration's index+1 is the version it produces. Only append to this list.
+var messageStoreMigrations = []string{
+	// 1: media keys, so missing media can be downloaded again later
+	`ALTER TABLE messages ADD COLUMN media_key BLOB;
+	 ALTER TABLE messages ADD COLUMN direct_path TEXT;
+	 ALTER TABLE messages ADD COLUMN file_sha256 BLOB;
+	 ALTER TABLE messages ADD COLUMN file_enc_sha256 BLOB;
+	 ALTER TABLE messages ADD COLUMN file_length INTEGER;`,
+	// 2: media details, with captions of stored media moved out of content
+	`ALTER TABLE messages ADD COLUMN caption TEXT;
+	 ALTER TABLE messages ADD COLUMN filename TEXT;
+	 ALTER TABLE messages ADD COLUMN mime_type TEXT;
+	 ALTER TABLE messages ADD COLUMN file_size INTEGER;
+	 UPDATE messages SET caption = content, content = '' WHERE COALESCE(image_url, '') != '' AND COALESCE(content, '') != '';
+	 UPDATE messages SET file_size = file_length WHERE file_length IS NOT NULL;`,
+	// 3: reply context, so threads can be reconstructed
+	`ALTER TABLE messages ADD COLUMN quoted_id TEXT;
+	 ALTER TABLE messages ADD COLUMN quoted_sender TEXT;
+	 ALTER TABLE messages ADD COLUMN quoted_snippet TEXT;
+	 CREATE INDEX IF NOT EXISTS idx_messages_quoted ON messages(chat_jid, quoted_id);`,
+	// 4: resolved sender names
+	`ALTER TABLE messages ADD COLUMN sender_name TEXT;
+	 CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages(sender);`,
+	// 5: per-chat time index, so unread counts after a consumer's cursor stay cheap
+	`CREATE INDEX IF NOT EXISTS idx_messages_chat_timestamp ON messages(chat_jid, timestamp);`,
+	// 6: poster frames of videos
+	`ALTER TABLE messages ADD COLUMN poster_path TEXT;`,
+	// 7: store-wide settings, such as the media directory stored paths point into
+	`CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT
+	 );`,
+	// 8: mute, archive and pin state of chats, synced from the phone
+	`ALTER TABLE chats ADD COLUMN muted BOOLEAN NOT NULL DEFAULT 0;
+	 ALTER TABLE chats ADD COLUMN muted_until TIMESTAMP;
+	 ALTER TABLE chats ADD COLUMN archived BOOLEAN NOT NULL DEFAULT 0;
+	 ALTER TABLE chats ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT 0;`,
+	// 9: probable spam, which is kept but not forwarded or shown in feeds
+	`ALTER TABLE messages ADD COLUMN spam BOOLEAN NOT NULL DEFAULT 0;
+	 ALTER TABLE messages ADD COLUMN spam_reasons TEXT;`,
+	// 10: raw protobufs of received messages, including those stored without text or media
+	`CREATE TABLE IF NOT EXISTS raw_messages (
+		id TEXT,
+		chat_jid TEXT,
+		sender TEXT,
+		push_name TEXT,
+		timestamp TIMESTAMP,
+		is_from_me BOOLEAN,
+		data BLOB,
+		PRIMARY KEY (id, chat_jid)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_raw_messages_chat_timestamp ON raw_messages(chat_jid, timestamp);`,
+	// 11: emoji reactions, one per sender and message
+	`CREATE TABLE IF NOT EXISTS reactions (
+		message_id TEXT,
+		chat_jid TEXT,
+		sender TEXT,
+		emoji TEXT,
+		timestamp TIMESTAMP,
+		PRIMARY KEY (message_id, chat_jid, sender)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_reactions_chat_timestamp ON reactions(chat_jid, timestamp);`,
+	// 12: faces found across stored photos, grouped by similarity, and the destinations parents labelled them with
+	`CREATE TABLE IF NOT EXISTS face_clusters (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		label TEXT,
+		created_at TIMESTAMP
+	 );
+	 CREATE TABLE IF NOT EXISTS face_cluster_faces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cluster_id INTEGER,
+		media_path TEXT,
+		message_id TEXT,
+		chat_jid TEXT,
+		box_top INTEGER,
+		box_right INTEGER,
+		box_bottom INTEGER,
+		box_left INTEGER,
+		encoding TEXT
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_face_cluster_faces_cluster ON face_cluster_faces(cluster_id);
+	 CREATE INDEX IF NOT EXISTS idx_face_cluster_faces_message ON face_cluster_faces(message_id, chat_jid);`,
+	// 13: face match decisions of the face filter and the feedback parents gave on them
+	`CREATE TABLE IF NOT EXISTS face_matches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_path TEXT,
+		message_id TEXT,
+		chat_jid TEXT,
+		destination TEXT,
+		distance REAL,
+		threshold REAL,
+		matched_count INTEGER,
+		total_references INTEGER,
+		matched BOOLEAN,
+		feedback TEXT,
+		feedback_at TIMESTAMP,
+		timestamp TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_face_matches_destination ON face_matches(destination, timestamp);
+	 CREATE INDEX IF NOT EXISTS idx_face_matches_message ON face_matches(message_id, chat_jid);`,
+	// 14: users of the dashboard and API with their roles, and their login sessions
+	`CREATE TABLE IF NOT EXISTS users (
+		name TEXT PRIMARY KEY,
+		password_hash TEXT,
+		role TEXT,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP
+	 );
+	 CREATE TABLE IF NOT EXISTS user_sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_name TEXT,
+		created_at TIMESTAMP,
+		expires_at TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_name);`,
+	// 15: warnings and errors of the bridge's log
+	`CREATE TABLE IF NOT EXISTS logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		level TEXT,
+		message TEXT,
+		timestamp TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);`,
+	// 16: timestamps in UTC, so they compare right as text whatever offset they were written with
+	utcTimestamps("chats.last_message_time", "chats.muted_until", "messages.timestamp", "links.timestamp",
+		"calendar_events.start_time", "forward_log.timestamp", "connection_log.timestamp", "events.timestamp",
+		"consumer_cursors.timestamp", "consumer_cursors.updated_at", "raw_messages.timestamp", "reactions.timestamp",
+		"face_clusters.created_at", "face_matches.feedback_at", "face_matches.timestamp", "users.created_at",
+		"users.updated_at", "user_sessions.created_at", "user_sessions.expires_at", "logs.timestamp"),
+	// 17: full-text index of the messages' text and senders, kept up to date by triggers, with the
+	// messages stored so far
+	`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts4(text, sender, tokenize=unicode61 "remove_diacritics=2");
+	 CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT OR REPLACE INTO messages_fts(docid, text, sender)
+		VALUES (new.rowid, TRIM(COALESCE(new.content, '') || ' ' || COALESCE(new.caption, '')), COALESCE(new.sender_name, ''));
+	 END;
+	 CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content, caption, sender_name ON messages BEGIN
+		INSERT OR REPLACE INTO messages_fts(docid, text, sender)
+		VALUES (new.rowid, TRIM(COALESCE(new.content, '') || ' ' || COALESCE(new.caption, '')), COALESCE(new.sender_name, ''));
+	 END;
+	 CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE docid = old.rowid;
+	 END;
+	 INSERT INTO messages_fts(docid, text, sender)
+	 SELECT rowid, TRIM(COALESCE(content, '') || ' ' || COALESCE(caption, '')), COALESCE(sender_name, '') FROM messages;`,
+	// 18: embeddings of the messages' text for semantic search, dropped when the text changes so it is
+	// embedded again
+	`CREATE TABLE IF NOT EXISTS message_vectors (
+		message_rowid INTEGER PRIMARY KEY,
+		model TEXT,
+		vector BLOB
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_message_vectors_model ON message_vectors(model);
+	 CREATE TRIGGER IF NOT EXISTS message_vectors_update AFTER UPDATE OF content, caption ON messages BEGIN
+		DELETE FROM message_vectors WHERE message_rowid = old.rowid;
+	 END;
+	 CREATE TRIGGER IF NOT EXISTS message_vectors_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM message_vectors WHERE message_rowid = old.rowid;
+	 END;`,
+	// 19: topics of a message, comma-separated; NULL until it is tagged, and again when its text changes
+	`ALTER TABLE messages ADD COLUMN topics TEXT;
+	 CREATE TRIGGER IF NOT EXISTS messages_topics_update AFTER UPDATE OF content, caption ON messages BEGIN
+		UPDATE messages SET topics = NULL WHERE rowid = new.rowid;
+	 END;`,
+	// 20: requests for money found in monitored chats, reminded about until they are marked done
+	`CREATE TABLE IF NOT EXISTS payment_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT,
+		chat_jid TEXT,
+		sender TEXT,
+		sender_name TEXT,
+		text TEXT,
+		amount REAL,
+		link TEXT,
+		requested_at TIMESTAMP,
+		done BOOLEAN DEFAULT 0,
+		done_at TIMESTAMP,
+		reminders INTEGER DEFAULT 0,
+		reminded_at TIMESTAMP,
+		UNIQUE (message_id, chat_jid)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_payment_requests_done ON payment_requests(done);`,
+	// 21: forms to fill in or sign found in monitored chats, reminded about until they are acknowledged
+	`CREATE TABLE IF NOT EXISTS forms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT,
+		chat_jid TEXT,
+		sender TEXT,
+		sender_name TEXT,
+		text TEXT,
+		file_name TEXT,
+		media_path TEXT,
+		due_at TIMESTAMP,
+		received_at TIMESTAMP,
+		acknowledged BOOLEAN DEFAULT 0,
+		acknowledged_at TIMESTAMP,
+		reminders INTEGER DEFAULT 0,
+		reminded_at TIMESTAMP,
+		UNIQUE (message_id, chat_jid)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_forms_acknowledged ON forms(acknowledged);`,
+	// 22: absences reported to the teachers, for the parents' records
+	`CREATE TABLE IF NOT EXISTS absence_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		destination TEXT,
+		child_name TEXT,
+		chat_jid TEXT,
+		reason TEXT,
+		note TEXT,
+		text TEXT,
+		day TEXT,
+		reported_at TIMESTAMP,
+		source TEXT,
+		dry_run BOOLEAN DEFAULT 0
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_absence_reports_destination ON absence_reports(destination, day);`,
+	// 23: messages forwarded by topic, and whether the chat they went to acknowledged them
+	`CREATE TABLE IF NOT EXISTS announcements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id TEXT,
+		chat_jid TEXT,
+		source_message_id TEXT,
+		source_chat_jid TEXT,
+		topic TEXT,
+		text TEXT,
+		sent_at TIMESTAMP,
+		acknowledged BOOLEAN DEFAULT 0,
+		acknowledged_by TEXT,
+		acknowledged_at TIMESTAMP,
+		acknowledgment TEXT,
+		nudges INTEGER DEFAULT 0,
+		nudged_at TIMESTAMP,
+		nudge_message_id TEXT,
+		UNIQUE (message_id)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_announcements_chat ON announcements(chat_jid, acknowledged);`,
+	// 24: automatic replies sent, and the senders who asked not to get them
+	`CREATE TABLE IF NOT EXISTS auto_replies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_jid TEXT,
+		sender TEXT,
+		message_id TEXT,
+		text TEXT,
+		replied_at TIMESTAMP,
+		dry_run BOOLEAN DEFAULT 0
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_auto_replies_sender ON auto_replies(sender, replied_at);
+	 CREATE TABLE IF NOT EXISTS auto_reply_opt_outs (
+		sender TEXT PRIMARY KEY,
+		opted_out_at TIMESTAMP
+	 );`,
+	// 25: people joining and leaving groups
+	`CREATE TABLE IF NOT EXISTS participant_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_jid TEXT,
+		participant TEXT,
+		name TEXT,
+		action TEXT,
+		actor TEXT,
+		reason TEXT,
+		changed_at TIMESTAMP
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_participant_changes_chat ON participant_changes(chat_jid, changed_at);`,
+	// 26: who sent the message an announcement was forwarded from, so it is deleted along with their messages
+	`ALTER TABLE announcements ADD COLUMN source_sender TEXT;`,
+}
+
+// LatestSchemaVersion is the schema version this build of the bridge writes
+func LatestSchemaVersion() int {
+	return len(messageStoreMigrations)
+}
+
+// SchemaVersion returns the current schema version of the message store
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// migrate applies all pending schema migrations
+func migrate(db *sql.DB) error {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	for i := version; i < len(messageStoreMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(messageStoreMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %v", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set schema version %d: %v", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// How a group's participants changed: joining or leaving by themselves, added or removed by an admin,
+// or made an admin or a member again
+const (
+	ParticipantJoined   = "join"
+	ParticipantAdded    = "add"
+	ParticipantLeft     = "leave"
+	ParticipantRemoved  = "remove"
+	ParticipantPromoted = "promote"
+	ParticipantDemoted  = "demote"
+)
+
+// ParticipantActions are the ways a group's participants change
+var ParticipantActions = []string{ParticipantJoined, ParticipantAdded, ParticipantLeft, ParticipantRemoved, ParticipantPromoted, ParticipantDemoted}
+
+// ParticipantChange is someone joining or leaving a group, or becoming or ceasing to be its admin
+type ParticipantChange struct {
+	ID          int64  `json:"id"`
+	ChatJID     string `json:"chat_jid"`
+	Participant string `json:"participant"`
+	// The participant's name, if known when they changed
+	Name   string `json:"name,omitempty"`
+	Action string `json:"action"`
+	// Who made the change, e.g. the admin who added the participant; empty when not told
+	Actor string `json:"actor,omitempty"`
+	// Why, as WhatsApp tells it, e.g. "invite" for joining by link
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// StoreParticipantChange records a change of a group's participants
+func (store *MessageStore) StoreParticipantChange(change ParticipantChange) error {
+	_, err := store.exec(`INSERT INTO participant_changes (chat_jid, participant, name, action, actor, reason, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		change.ChatJID, change.Participant, change.Name, change.Action, change.Actor, change.Reason, change.ChangedAt)
+	return err
+}
+
+// GetParticipantChanges returns the changes of the participants of the filter's chat, or of all groups,
+// between its dates, latest first. actions limits them to those kinds of change.
+func (store *MessageStore) GetParticipantChanges(filter ExportFilter, actions []string) ([]ParticipantChange, error) {
+	query := `SELECT id, chat_jid, participant, COALESCE(name, ''), action, COALESCE(actor, ''), COALESCE(reason, ''), changed_at
+		FROM participant_changes WHERE 1 = 1`
+	var args []interface{}
+	if filter.ChatJID != "" {
+		query += " AND chat_jid = ?"
+		args = append(args, filter.ChatJID)
+	}
+	if !filter.From.IsZero() {
+		query += " AND changed_at >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += " AND changed_at < ?"
+		args = append(args, filter.To)
+	}
+	if len(actions) > 0 {
+		query += " AND action IN (?" + strings.Repeat(", ?", len(actions)-1) + ")"
+		for _, action := range actions {
+			args = append(args, action)
+		}
+	}
+	query += " ORDER BY changed_at DESC, id DESC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := []ParticipantChange{}
+	for rows.Next() {
+		var change ParticipantChange
+		if err := rows.Scan(&change.ID, &change.ChatJID, &change.Participant, &change.Name, &change.Action, &change.Actor,
+			&change.Reason, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PaymentRequest is a message asking for money, such as a Bit link or "please transfer 50₪"
+type PaymentRequest struct {
+	ID         int64   `json:"id"`
+	MessageID  string  `json:"message_id"`
+	ChatJID    string  `json:"chat_jid"`
+	ChatName   string  `json:"chat_name,omitempty"`
+	Sender     string  `json:"sender"`
+	SenderName string  `json:"sender_name,omitempty"`
+	Text       string  `json:"text"`
+	Amount     float64 `json:"amount,omitempty"`
+	// Bit or PayBox link to pay with
+	Link        string     `json:"link,omitempty"`
+	RequestedAt time.Time  `json:"requested_at"`
+	Done        bool       `json:"done"`
+	DoneAt      *time.Time `json:"done_at,omitempty"`
+	// Reminders sent so far, and when the last one was
+	Reminders  int        `json:"reminders"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+}
+
+// paymentRequestQuery selects payment requests with their chat name in the column order
+// scanPaymentRequest expects
+const paymentRequestQuery = `SELECT payment_requests.id, payment_requests.message_id, payment_requests.chat_jid, COALESCE(chats.name, ''),
+	payment_requests.sender, COALESCE(payment_requests.sender_name, ''), payment_requests.text, COALESCE(payment_requests.amount, 0),
+	COALESCE(payment_requests.link, ''), payment_requests.requested_at, payment_requests.done, payment_requests.done_at,
+	payment_requests.reminders, payment_requests.reminded_at
+	FROM payment_requests LEFT JOIN chats ON chats.jid = payment_requests.chat_jid`
+
+// scanPaymentRequest reads a row selected by paymentRequestQuery
+func scanPaymentRequest(row interface{ Scan(...interface{}) error }) (PaymentRequest, error) {
+	var request PaymentRequest
+	var doneAt, remindedAt sql.NullTime
+	err := row.Scan(&request.ID, &request.MessageID, &request.ChatJID, &request.ChatName, &request.Sender, &request.SenderName,
+		&request.Text, &request.Amount, &request.Link, &request.RequestedAt, &request.Done, &doneAt, &request.Reminders, &remindedAt)
+	if doneAt.Valid {
+		request.DoneAt = &doneAt.Time
+	}
+	if remindedAt.Valid {
+		request.RemindedAt = &remindedAt.Time
+	}
+	return request, err
+}
+
+// StorePaymentRequest records a payment request. A message stored again, e.g. after an edit, keeps its
+// request, reminders and done flag; created is false then.
+func (store *MessageStore) StorePaymentRequest(request PaymentRequest) (id int64, created bool, err error) {
+	result, err := store.exec(`INSERT OR IGNORE INTO payment_requests (message_id, chat_jid, sender, sender_name, text, amount, link, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		request.MessageID, request.ChatJID, request.Sender, request.SenderName, request.Text, request.Amount, request.Link, request.RequestedAt)
+	if err != nil {
+		return 0, false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		id, err = result.LastInsertId()
+		return id, true, err
+	}
+	err = store.queryRow("SELECT id FROM payment_requests WHERE message_id = ? AND chat_jid = ?", request.MessageID, request.ChatJID).Scan(&id)
+	return id, false, err
+}
+
+// GetPaymentRequest returns a payment request by ID, or sql.ErrNoRows
+func (store *MessageStore) GetPaymentRequest(id int64) (PaymentRequest, error) {
+	return scanPaymentRequest(store.queryRow(paymentRequestQuery+" WHERE payment_requests.id = ?", id))
+}
+
+// GetPaymentRequests returns the payment requests of chatJID, or of every chat when it is empty, newest
+// first: the open ones, the done ones, or all with done nil
+func (store *MessageStore) GetPaymentRequests(chatJID string, done *bool) ([]PaymentRequest, error) {
+	query := paymentRequestQuery + " WHERE 1 = 1"
+	var args []interface{}
+	if chatJID != "" {
+		query += " AND payment_requests.chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	if done != nil {
+		query += " AND payment_requests.done = ?"
+		args = append(args, *done)
+	}
+	query += " ORDER BY payment_requests.requested_at DESC"
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	requests := []PaymentRequest{}
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// SetPaymentDone marks a payment request as paid, which stops its reminders, or as open again.
+// Returns sql.ErrNoRows for an unknown ID.
+func (store *MessageStore) SetPaymentDone(id int64, done bool) error {
+	var doneAt interface{}
+	if done {
+		doneAt = time.Now()
+	}
+	result, err := store.exec("UPDATE payment_requests SET done = ?, done_at = ? WHERE id = ?", done, doneAt, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DuePaymentReminders returns the open payment requests not reminded about yet, or last reminded about
+// before since, that got fewer than maxReminders reminders, oldest first
+func (store *MessageStore) DuePaymentReminders(since time.Time, maxReminders int) ([]PaymentRequest, error) {
+	rows, err := store.query(paymentRequestQuery+`
+		WHERE NOT payment_requests.done AND payment_requests.reminders < ?
+		AND (payment_requests.reminded_at IS NULL OR payment_requests.reminded_at <= ?)
+		ORDER BY payment_requests.requested_at ASC`, maxReminders, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var requests []PaymentRequest
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// RecordPaymentReminder counts a reminder sent about a payment request
+func (store *MessageStore) RecordPaymentReminder(id int64, at time.Time) error {
+	_, err := store.exec("UPDATE payment_requests SET reminders = reminders + 1, reminded_at = ? WHERE id = ?", at, id)
+	return err
+}
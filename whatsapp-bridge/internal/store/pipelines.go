@@ -0,0 +1,73 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// pipelinesDir is the subdirectory of Dir with a directory per isolated pipeline
+const pipelinesDir = "pipelines"
+
+var (
+	// pipelineStores are the message stores of isolated pipelines opened so far, by pipeline name
+	pipelineStores = make(map[string]*MessageStore)
+	pipelineMu     sync.Mutex
+)
+
+// PipelinePath returns the location of a file in the data directory of an isolated pipeline,
+// <data_dir>/pipelines/<name>
+func PipelinePath(pipeline, name string) string {
+	return Path(filepath.Join(pipelinesDir, pipeline, name))
+}
+
+// ForPipeline returns the message store of an isolated pipeline, which keeps its messages in a
+// messages.db of its own. It is opened on first use and stays open until ClosePipelines.
+func ForPipeline(pipeline string) (*MessageStore, error) {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	if store, ok := pipelineStores[pipeline]; ok {
+		return store, nil
+	}
+	store, err := open(PipelinePath(pipeline, "messages.db"))
+	if err != nil {
+		return nil, fmt.Errorf("pipeline %s: %v", pipeline, err)
+	}
+	pipelineStores[pipeline] = store
+	return store, nil
+}
+
+// ClosePipelines closes the message stores of isolated pipelines
+func ClosePipelines() {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	for pipeline, store := range pipelineStores {
+		store.Close()
+		delete(pipelineStores, pipeline)
+	}
+}
+
+// pipelineDatabases returns the message databases of isolated pipelines that exist, relative to Dir
+func pipelineDatabases() []string {
+	matches, _ := filepath.Glob(PipelinePath("*", "messages.db"))
+	var names []string
+	for _, match := range matches {
+		if rel, err := filepath.Rel(Dir, match); err == nil {
+			names = append(names, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PipelineStores returns the message stores of isolated pipelines opened so far, by pipeline name
+func PipelineStores() map[string]*MessageStore {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	stores := make(map[string]*MessageStore, len(pipelineStores))
+	for pipeline, store := range pipelineStores {
+		stores[pipeline] = store
+	}
+	return stores
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RawMessage is the archived protobuf of a received message, with what is needed to store it again
+type RawMessage struct {
+	ID        string
+	ChatJID   string
+	Sender    string
+	PushName  string
+	Timestamp time.Time
+	IsFromMe  bool
+	// The marshaled waE2E.Message
+	Data []byte
+}
+
+// StoreRawMessage archives the raw protobuf of a message, replacing an earlier copy
+func (store *MessageStore) StoreRawMessage(raw RawMessage) error {
+	_, err := store.exec(`INSERT OR REPLACE INTO raw_messages (id, chat_jid, sender, push_name, timestamp, is_from_me, data)
+		VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?)`,
+		raw.ID, raw.ChatJID, raw.Sender, raw.PushName, raw.Timestamp, raw.IsFromMe, raw.Data,
+	)
+	return err
+}
+
+// GetRawMessages returns up to limit archived raw messages matching filter that were archived after
+// afterRow, together with the row to continue from
+func (store *MessageStore) GetRawMessages(filter ExportFilter, afterRow int64, limit int) ([]RawMessage, int64, error) {
+	query := `SELECT rowid, id, chat_jid, COALESCE(sender, ''), COALESCE(push_name, ''), timestamp, is_from_me, data
+		FROM raw_messages WHERE rowid > ?`
+	args := []interface{}{afterRow}
+	if filter.ChatJID != "" {
+		query += " AND chat_jid = ?"
+		args = append(args, filter.ChatJID)
+	}
+	if !filter.From.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, filter.To)
+	}
+	query += " ORDER BY rowid ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.query(query, args...)
+	if err != nil {
+		return nil, afterRow, err
+	}
+	defer rows.Close()
+
+	var raws []RawMessage
+	for rows.Next() {
+		var raw RawMessage
+		if err := rows.Scan(&afterRow, &raw.ID, &raw.ChatJID, &raw.Sender, &raw.PushName, &raw.Timestamp, &raw.IsFromMe, &raw.Data); err != nil {
+			return nil, afterRow, err
+		}
+		raws = append(raws, raw)
+	}
+	return raws, afterRow, rows.Err()
+}
+
+// StoredImageURL returns the media path of a stored message, and whether the message is stored at all
+func (store *MessageStore) StoredImageURL(id, chatJID string) (string, bool, error) {
+	var imageURL string
+	err := store.queryRow("SELECT COALESCE(image_url, '') FROM messages WHERE id = ? AND chat_jid = ?", id, chatJID).Scan(&imageURL)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return imageURL, err == nil, err
+}
+
+// parsedFields are the message columns derived from parsing its protobuf
+type parsedFields struct {
+	Content, Caption, Filename, MimeType  string
+	FileSize                              int64
+	QuotedID, QuotedSender, QuotedSnippet string
+}
+
+// UpdateParsedMessage replaces the fields parsed from a stored message (content, media details and reply
+// context) with those of msg. A message.updated event is recorded if anything changed; the message's
+// media, sender and spam verdict are left as they are. Reports whether the message changed.
+func (store *MessageStore) UpdateParsedMessage(msg IncomingMessage) (bool, error) {
+	parsed := parsedFields{Content: msg.Content}
+	if d := msg.Details; d != nil {
+		parsed.Caption, parsed.Filename, parsed.MimeType, parsed.FileSize = d.Caption, d.Filename, d.MimeType, d.FileSize
+	}
+	if r := msg.Reply; r != nil {
+		parsed.QuotedID, parsed.QuotedSender, parsed.QuotedSnippet = r.QuotedID, r.QuotedSender, r.QuotedSnippet
+	}
+
+	changed := false
+	err := store.transaction(func(tx *sql.Tx) error {
+		var stored parsedFields
+		err := tx.QueryRow(`SELECT COALESCE(content, ''), COALESCE(caption, ''), COALESCE(filename, ''), COALESCE(mime_type, ''),
+				COALESCE(file_size, 0), COALESCE(quoted_id, ''), COALESCE(quoted_sender, ''), COALESCE(quoted_snippet, '')
+			FROM messages WHERE id = ? AND chat_jid = ?`, msg.ID, msg.ChatJID,
+		).Scan(&stored.Content, &stored.Caption, &stored.Filename, &stored.MimeType, &stored.FileSize,
+			&stored.QuotedID, &stored.QuotedSender, &stored.QuotedSnippet)
+		if err != nil || stored == parsed {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE messages SET content = ?, caption = NULLIF(?, ''), filename = NULLIF(?, ''), mime_type = NULLIF(?, ''),
+				file_size = NULLIF(?, 0), quoted_id = NULLIF(?, ''), quoted_sender = NULLIF(?, ''), quoted_snippet = NULLIF(?, '')
+			WHERE id = ? AND chat_jid = ?`,
+			parsed.Content, parsed.Caption, parsed.Filename, parsed.MimeType, parsed.FileSize,
+			parsed.QuotedID, parsed.QuotedSender, parsed.QuotedSnippet, msg.ID, msg.ChatJID,
+		)
+		if err != nil {
+			return err
+		}
+		changed = true
+		return recordMessageEvent(tx, msg.ID, msg.ChatJID)
+	})
+	return changed, err
+}
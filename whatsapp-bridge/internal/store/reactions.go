@@ -0,0 +1,17 @@
+package store
+
+import (
+	"time"
+)
+
+// StoreReaction records sender's emoji reaction to a message, replacing their earlier one. An empty
+// emoji means the reaction was taken back.
+func (store *MessageStore) StoreReaction(messageID, chatJID, sender, emoji string, timestamp time.Time) error {
+	if emoji == "" {
+		_, err := store.exec("DELETE FROM reactions WHERE message_id = ? AND chat_jid = ? AND sender = ?", messageID, chatJID, sender)
+		return err
+	}
+	_, err := store.exec("INSERT OR REPLACE INTO reactions (message_id, chat_jid, sender, emoji, timestamp) VALUES (?, ?, ?, ?, ?)",
+		messageID, chatJID, sender, emoji, timestamp)
+	return err
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReplyContext is the message an incoming message quotes
+type ReplyContext struct {
+	QuotedID      string
+	QuotedSender  string
+	QuotedSnippet string
+}
+
+// ThreadMessage is a stored message together with the message it replies to
+type ThreadMessage struct {
+	ID            string    `json:"id"`
+	ChatJID       string    `json:"chat_jid"`
+	Sender        string    `json:"sender"`
+	SenderName    string    `json:"sender_name,omitempty"`
+	Content       string    `json:"content,omitempty"`
+	Caption       string    `json:"caption,omitempty"`
+	MediaType     string    `json:"media_type,omitempty"`
+	IsFromMe      bool      `json:"is_from_me"`
+	Timestamp     time.Time `json:"timestamp"`
+	QuotedID      string    `json:"quoted_id,omitempty"`
+	QuotedSender  string    `json:"quoted_sender,omitempty"`
+	QuotedSnippet string    `json:"quoted_snippet,omitempty"`
+}
+
+// maxThreadDepth bounds the walk up a reply chain
+const maxThreadDepth = 100
+
+const threadColumns = `id, chat_jid, sender, COALESCE(sender_name, ''), content, COALESCE(caption, ''), COALESCE(media_type, ''), is_from_me, timestamp,
+	COALESCE(quoted_id, ''), COALESCE(quoted_sender, ''), COALESCE(quoted_snippet, '')`
+
+// getThreadMessage returns a single stored message with its reply context
+func (store *MessageStore) getThreadMessage(chatJID, id string) (ThreadMessage, error) {
+	var msg ThreadMessage
+	err := store.queryRow("SELECT "+threadColumns+" FROM messages WHERE chat_jid = ? AND id = ?", chatJID, id).Scan(
+		&msg.ID, &msg.ChatJID, &msg.Sender, &msg.SenderName, &msg.Content, &msg.Caption, &msg.MediaType, &msg.IsFromMe, &msg.Timestamp,
+		&msg.QuotedID, &msg.QuotedSender, &msg.QuotedSnippet)
+	return msg, err
+}
+
+// GetThread reconstructs the conversation a message belongs to: the chain of messages it replies to up to
+// the first one, and every reply below that, oldest first. The first message keeps its quoted_* fields when
+// the message it quotes isn't stored. An unknown message yields sql.ErrNoRows.
+func (store *MessageStore) GetThread(chatJID, messageID string) ([]ThreadMessage, error) {
+	root, err := store.getThreadMessage(chatJID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	for depth := 0; root.QuotedID != "" && depth < maxThreadDepth; depth++ {
+		parent, err := store.getThreadMessage(chatJID, root.QuotedID)
+		if err != nil {
+			break
+		}
+		root = parent
+	}
+
+	thread := []ThreadMessage{root}
+	seen := map[string]bool{root.ID: true}
+	parents := []string{root.ID}
+	for len(parents) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(parents)), ",")
+		args := []interface{}{chatJID}
+		for _, id := range parents {
+			args = append(args, id)
+		}
+		rows, err := store.query("SELECT "+threadColumns+" FROM messages WHERE chat_jid = ? AND quoted_id IN ("+placeholders+")", args...)
+		if err != nil {
+			return nil, err
+		}
+		parents = nil
+		for rows.Next() {
+			var msg ThreadMessage
+			if err := rows.Scan(&msg.ID, &msg.ChatJID, &msg.Sender, &msg.SenderName, &msg.Content, &msg.Caption, &msg.MediaType, &msg.IsFromMe,
+				&msg.Timestamp, &msg.QuotedID, &msg.QuotedSender, &msg.QuotedSnippet); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !seen[msg.ID] {
+				seen[msg.ID] = true
+				thread = append(thread, msg)
+				parents = append(parents, msg.ID)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(thread, func(i, j int) bool { return thread[i].Timestamp.Before(thread[j].Timestamp) })
+	return thread, nil
+}
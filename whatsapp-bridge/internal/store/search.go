@@ -0,0 +1,97 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// searchCandidates is how many of the newest messages matching any search term are ranked
+const searchCandidates = 500
+
+// SearchResult is a stored message found by a search
+type SearchResult struct {
+	MessageID  string    `json:"message_id"`
+	ChatJID    string    `json:"chat_jid"`
+	ChatName   string    `json:"chat_name,omitempty"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"sender_name,omitempty"`
+	Text       string    `json:"text"`
+	Timestamp  time.Time `json:"timestamp"`
+	MediaType  string    `json:"media_type,omitempty"`
+	// Share of the search terms found in the message or its sender's name, from 0 to 1
+	Score float64 `json:"score"`
+	// Cosine similarity of the message's embedding to the search's, for semantic matches
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// SearchTerms splits text into lowercase words for SearchMessages, dropping punctuation and duplicates
+func SearchTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// SearchMessages finds messages matching filter whose text or sender has a word starting with one of
+// terms, as split by SearchTerms. Of the newest matches, those with the most terms come first, then the
+// newest. Probable spam is left out.
+func (store *MessageStore) SearchMessages(terms []string, filter ExportFilter, limit int) ([]SearchResult, error) {
+	results := []SearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+	// Terms are plain lowercase words, so they can't be taken for the operators of the match syntax
+	match := strings.Join(terms, "* OR ") + "*"
+	where, args := filterClause("messages.", filter)
+	rows, err := store.query(`SELECT messages.id, messages.chat_jid, COALESCE(chats.name, ''), messages.sender, COALESCE(messages.sender_name, ''),
+		messages_fts.text, messages.timestamp, COALESCE(messages.media_type, '')
+		FROM messages_fts JOIN messages ON messages.rowid = messages_fts.docid LEFT JOIN chats ON chats.jid = messages.chat_jid
+		WHERE messages_fts MATCH ? AND NOT messages.spam`+where+`
+		ORDER BY messages.timestamp DESC LIMIT ?`, append(append([]interface{}{match}, args...), searchCandidates)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var result SearchResult
+		if err := rows.Scan(&result.MessageID, &result.ChatJID, &result.ChatName, &result.Sender, &result.SenderName,
+			&result.Text, &result.Timestamp, &result.MediaType); err != nil {
+			return nil, err
+		}
+		result.Score = termScore(terms, result.Text+" "+result.SenderName)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// termScore is the share of terms that a word of text starts with
+func termScore(terms []string, text string) float64 {
+	words := SearchTerms(text)
+	found := 0
+	for _, term := range terms {
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				found++
+				break
+			}
+		}
+	}
+	return float64(found) / float64(len(terms))
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// sessionMagic starts every session export, followed by the scrypt salt, the GCM nonce and the
+// encrypted whatsapp.db
+const sessionMagic = "JMK-SESSION-1\n"
+
+// Lengths of the salt and nonce in a session export
+const (
+	sessionSaltSize  = 16
+	sessionNonceSize = 12
+)
+
+// sessionKey derives the encryption key of a session export from its passphrase
+func sessionKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// ExportSession writes the WhatsApp session (the device store, whatsapp.db) to path, encrypted with
+// passphrase, so the bridge can move to another machine without pairing again. Whoever has the file and
+// the passphrase can read and send messages as the linked device.
+func ExportSession(path, passphrase string) error {
+	if _, err := os.Stat(Path("whatsapp.db")); err != nil {
+		return fmt.Errorf("no session to export: %v", err)
+	}
+	snapshot, err := os.CreateTemp(Dir, ".session-*.db")
+	if err != nil {
+		return err
+	}
+	snapshot.Close()
+	defer os.Remove(snapshot.Name())
+	if err := snapshotDatabase(Path("whatsapp.db"), snapshot.Name()); err != nil {
+		return fmt.Errorf("failed to snapshot whatsapp.db: %v", err)
+	}
+	if err := checkSessionDatabase(snapshot.Name()); err != nil {
+		return err
+	}
+	plaintext, err := os.ReadFile(snapshot.Name())
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, sessionSaltSize)
+	nonce := make([]byte, sessionNonceSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	gcm, err := sessionCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(sessionMagic)
+	out.Write(salt)
+	out.Write(nonce)
+	// The header is authenticated too, so a tampered salt fails like a wrong passphrase
+	out.Write(gcm.Seal(nil, nonce, plaintext, out.Bytes()))
+	return os.WriteFile(path, out.Bytes(), 0600)
+}
+
+// ImportSession decrypts a session written by ExportSession and installs it as whatsapp.db. The bridge
+// must be stopped; an existing session is kept as whatsapp.db.bak.
+func ImportSession(path, passphrase string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	headerSize := len(sessionMagic) + sessionSaltSize + sessionNonceSize
+	if len(data) < headerSize || string(data[:len(sessionMagic)]) != sessionMagic {
+		return fmt.Errorf("%s is not a session export", path)
+	}
+	salt := data[len(sessionMagic) : len(sessionMagic)+sessionSaltSize]
+	nonce := data[len(sessionMagic)+sessionSaltSize : headerSize]
+	gcm, err := sessionCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	plaintext, err := gcm.Open(nil, nonce, data[headerSize:], data[:headerSize])
+	if err != nil {
+		return fmt.Errorf("wrong passphrase or damaged file")
+	}
+
+	// Stage inside the data directory so the final rename stays on one file system
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	staged, err := os.CreateTemp(Dir, ".session-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+	_, err = staged.Write(plaintext)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := CheckDatabaseIntegrity(staged.Name()); err != nil {
+		return err
+	}
+	if err := checkSessionDatabase(staged.Name()); err != nil {
+		return err
+	}
+
+	target := Path("whatsapp.db")
+	if _, err := os.Stat(target); err == nil {
+		if err := moveFile(target, target+".bak"); err != nil {
+			return fmt.Errorf("failed to keep the existing session: %v", err)
+		}
+		fmt.Printf("Kept the previous session as %s\n", filepath.Base(target)+".bak")
+	}
+	// Drop stale WAL/journal files so SQLite doesn't replay them over the imported database
+	os.Remove(target + "-wal")
+	os.Remove(target + "-shm")
+	os.Remove(target + "-journal")
+	return moveFile(staged.Name(), target)
+}
+
+// sessionCipher returns the AES-256-GCM cipher for passphrase and salt
+func sessionCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := sessionKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkSessionDatabase makes sure a device store holds a paired device
+func checkSessionDatabase(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var devices int
+	if err := db.QueryRow("SELECT COUNT(*) FROM whatsmeow_device").Scan(&devices); err != nil {
+		return fmt.Errorf("not a WhatsApp session database: %v", err)
+	}
+	if devices == 0 {
+		return fmt.Errorf("the session is not paired with a WhatsApp account")
+	}
+	return nil
+}
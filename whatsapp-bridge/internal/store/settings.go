@@ -0,0 +1,12 @@
+package store
+
+// Setting returns a value kept in the settings table, or "" if it isn't set
+func (store *MessageStore) Setting(key string) (string, error) {
+	return store.getSetting(key)
+}
+
+// SetSetting keeps a value in the settings table, replacing an earlier one
+func (store *MessageStore) SetSetting(key, value string) error {
+	_, err := store.exec("INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value", key, value)
+	return err
+}
@@ -0,0 +1,18 @@
+package store
+
+import "time"
+
+// HasPostedBefore reports whether sender has a stored message in the chat from before t
+func (store *MessageStore) HasPostedBefore(chatJID, sender string, t time.Time) bool {
+	var found int
+	err := store.queryRow("SELECT 1 FROM messages WHERE chat_jid = ? AND sender = ? AND timestamp < ? LIMIT 1", chatJID, sender, t).Scan(&found)
+	return err == nil
+}
+
+// CountSameText counts stored messages other than id whose text or caption is text, since t
+func (store *MessageStore) CountSameText(id, text string, since time.Time) int {
+	var count int
+	store.queryRow(`SELECT COUNT(*) FROM messages WHERE timestamp >= ? AND id != ? AND (content = ? OR caption = ?)`,
+		since, id, text, text).Scan(&count)
+	return count
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"whatsapp-client/internal/config"
+)
+
+// SenderStats is the activity of one sender in a chat
+type SenderStats struct {
+	Sender            string `json:"sender"`
+	SenderName        string `json:"sender_name,omitempty"`
+	Messages          int    `json:"messages"`
+	Media             int    `json:"media"`
+	ReactionsGiven    int    `json:"reactions_given"`
+	ReactionsReceived int    `json:"reactions_received"`
+}
+
+// ReactionCount is how often an emoji was used as a reaction
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// ChatStats summarises the activity in a chat, or in all chats, over a period
+type ChatStats struct {
+	ChatJID  string     `json:"chat_jid,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	Messages int        `json:"messages"`
+	Media    int        `json:"media"`
+	// Most active first
+	Senders []SenderStats `json:"senders"`
+	// Messages per hour of the day, in local time
+	Hours     [24]int         `json:"hours"`
+	Reactions []ReactionCount `json:"reactions"`
+}
+
+// senderKey identifies a sender whether it was stored as a phone number, a JID or a device JID
+func senderKey(sender string) string {
+	if i := strings.IndexAny(sender, "@:"); i >= 0 {
+		return sender[:i]
+	}
+	return strings.TrimPrefix(sender, "+")
+}
+
+// filterClause returns the conditions and arguments restricting column-prefixed rows to filter
+func filterClause(prefix string, filter ExportFilter) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	if filter.ChatJID != "" {
+		where += " AND " + prefix + "chat_jid = ?"
+		args = append(args, filter.ChatJID)
+	}
+	if !filter.From.IsZero() {
+		where += " AND " + prefix + "timestamp >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where += " AND " + prefix + "timestamp < ?"
+		args = append(args, filter.To)
+	}
+	// Topics are only kept on messages; reactions are selected by the topics of the message reacted to
+	if filter.Topic != "" {
+		where += " AND instr(',' || messages.topics || ',', ',' || ? || ',') > 0"
+		args = append(args, filter.Topic)
+	}
+	return where, args
+}
+
+// GetChatStats counts the messages, media and reactions per sender matching filter, with the busy hours
+// of the day. Messages from the linked account and probable spam are left out.
+func (store *MessageStore) GetChatStats(filter ExportFilter) (*ChatStats, error) {
+	stats := &ChatStats{ChatJID: filter.ChatJID, Senders: []SenderStats{}, Reactions: []ReactionCount{}}
+	if !filter.From.IsZero() {
+		stats.From = &filter.From
+	}
+	if !filter.To.IsZero() {
+		stats.To = &filter.To
+	}
+	senders := make(map[string]*SenderStats)
+	sender := func(raw, name string) *SenderStats {
+		key := senderKey(raw)
+		s, ok := senders[key]
+		if !ok {
+			s = &SenderStats{Sender: key}
+			senders[key] = s
+		}
+		if s.SenderName == "" {
+			s.SenderName = name
+		}
+		return s
+	}
+
+	where, args := filterClause("", filter)
+	rows, err := store.query(`SELECT sender, COALESCE(sender_name, ''), COALESCE(image_url, '') != '', timestamp
+		FROM messages WHERE NOT is_from_me AND NOT spam`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	location := config.Current().Location()
+	for rows.Next() {
+		var raw, name string
+		var hasMedia bool
+		var timestamp time.Time
+		if err := rows.Scan(&raw, &name, &hasMedia, &timestamp); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s := sender(raw, name)
+		s.Messages++
+		stats.Messages++
+		if hasMedia {
+			s.Media++
+			stats.Media++
+		}
+		stats.Hours[timestamp.In(location).Hour()]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	where, args = filterClause("reactions.", filter)
+	rows, err = store.query(`SELECT reactions.sender, reactions.emoji, COALESCE(messages.sender, ''), COALESCE(messages.sender_name, '')
+		FROM reactions LEFT JOIN messages ON messages.id = reactions.message_id AND messages.chat_jid = reactions.chat_jid
+		WHERE 1 = 1`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	emojis := make(map[string]int)
+	for rows.Next() {
+		var from, emoji, to, toName string
+		if err := rows.Scan(&from, &emoji, &to, &toName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sender(from, "").ReactionsGiven++
+		if to != "" {
+			sender(to, toName).ReactionsReceived++
+		}
+		emojis[emoji]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range senders {
+		stats.Senders = append(stats.Senders, *s)
+	}
+	sort.Slice(stats.Senders, func(i, j int) bool {
+		a, b := stats.Senders[i], stats.Senders[j]
+		if a.Messages != b.Messages {
+			return a.Messages > b.Messages
+		}
+		if a.ReactionsGiven != b.ReactionsGiven {
+			return a.ReactionsGiven > b.ReactionsGiven
+		}
+		return a.Sender < b.Sender
+	})
+	for emoji, count := range emojis {
+		stats.Reactions = append(stats.Reactions, ReactionCount{Emoji: emoji, Count: count})
+	}
+	sort.Slice(stats.Reactions, func(i, j int) bool {
+		if stats.Reactions[i].Count != stats.Reactions[j].Count {
+			return stats.Reactions[i].Count > stats.Reactions[j].Count
+		}
+		return stats.Reactions[i].Emoji < stats.Reactions[j].Emoji
+	})
+	return stats, nil
+}
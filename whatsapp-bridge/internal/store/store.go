@@ -0,0 +1,339 @@
+// Package store is the SQLite message store: chats, messages and the data derived from them.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Message represents a chat message for our client
+type Message struct {
+	Time     time.Time
+	Sender   string
+	Content  string
+	IsFromMe bool
+	// Add image-related fields
+	ImageURL     string
+	ThumbnailURL string
+	MediaType    string
+	MediaDetails
+}
+
+// Dir holds the databases, relative to the bridge's working directory. It follows data_dir and is set
+// at startup.
+var Dir = "store"
+
+// Path returns the location of a file in the data directory
+func Path(name string) string {
+	return filepath.Join(Dir, name)
+}
+
+// DatabaseSizes returns the size in bytes of the message store and the WhatsApp session database,
+// including their write-ahead logs. Databases that don't exist yet are left out.
+func DatabaseSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, name := range []string{"messages.db", "whatsapp.db"} {
+		info, err := os.Stat(Path(name))
+		if err != nil {
+			continue
+		}
+		size := info.Size()
+		if wal, err := os.Stat(Path(name + "-wal")); err == nil {
+			size += wal.Size()
+		}
+		sizes[name] = size
+	}
+	return sizes
+}
+
+// Database handler for storing message history. All writes go through db, a single connection, so
+// writers queue in Go instead of competing for SQLite's lock; reads use a pool of read-only connections
+// that WAL mode lets run alongside the writer.
+type MessageStore struct {
+	db    *sql.DB
+	reads *sql.DB
+}
+
+// Initialize message store
+func New() (*MessageStore, error) {
+	return open(Path("messages.db"))
+}
+
+// open opens the message database at path, creating it and its directory if needed
+func open(path string) (*MessageStore, error) {
+	// Create directory for database if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %v", err)
+	}
+
+	// Open SQLite database for messages. Transactions take the write lock when they begin, so they wait
+	// for other processes (backups, the MCP server) instead of failing halfway. Recursive triggers make
+	// INSERT OR REPLACE fire the delete triggers of the row it replaces, which keeps the full-text index
+	// and the embeddings free of stale rows.
+	db, err := sql.Open(utcDriverName, fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate&_recursive_triggers=on&_busy_timeout=%d",
+		path, busyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	// Create tables if they don't exist
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS chats (
+			jid TEXT PRIMARY KEY,
+			name TEXT,
+			last_message_time TIMESTAMP
+		);
+		
+		CREATE TABLE IF NOT EXISTS messages (
+			id TEXT,
+			chat_jid TEXT,
+			sender TEXT,
+			content TEXT,
+			timestamp TIMESTAMP,
+			is_from_me BOOLEAN,
+			image_url TEXT,
+			thumbnail_url TEXT,
+			media_type TEXT,
+			PRIMARY KEY (id, chat_jid),
+			FOREIGN KEY (chat_jid) REFERENCES chats(jid)
+		);
+		
+		CREATE TABLE IF NOT EXISTS links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			title TEXT,
+			message_id TEXT,
+			chat_jid TEXT,
+			sender TEXT,
+			timestamp TIMESTAMP,
+			UNIQUE (url, message_id, chat_jid)
+		);
+		
+		CREATE INDEX IF NOT EXISTS idx_links_timestamp ON links(timestamp);
+		
+		CREATE TABLE IF NOT EXISTS calendar_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id TEXT,
+			chat_jid TEXT,
+			title TEXT,
+			start_time TIMESTAMP,
+			all_day BOOLEAN,
+			details TEXT,
+			UNIQUE (message_id, chat_jid, start_time)
+		);
+		
+		CREATE TABLE IF NOT EXISTS forward_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id TEXT,
+			chat_jid TEXT,
+			destination TEXT,
+			media_path TEXT,
+			caption TEXT,
+			dry_run BOOLEAN,
+			request_id TEXT,
+			timestamp TIMESTAMP
+		);
+		
+		CREATE INDEX IF NOT EXISTS idx_forward_log_message ON forward_log(message_id, chat_jid);
+		
+		CREATE TABLE IF NOT EXISTS connection_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event TEXT,
+			detail TEXT,
+			timestamp TIMESTAMP
+		);
+		
+		CREATE INDEX IF NOT EXISTS idx_connection_log_timestamp ON connection_log(timestamp);
+		
+		CREATE TABLE IF NOT EXISTS events (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT,
+			message_id TEXT,
+			chat_jid TEXT,
+			payload TEXT,
+			timestamp TIMESTAMP
+		);
+		
+		CREATE INDEX IF NOT EXISTS idx_events_message ON events(message_id, chat_jid);
+		
+		CREATE TABLE IF NOT EXISTS publisher_offsets (
+			name TEXT PRIMARY KEY,
+			seq INTEGER
+		);
+		
+		CREATE TABLE IF NOT EXISTS consumer_cursors (
+			consumer TEXT,
+			chat_jid TEXT,
+			message_id TEXT,
+			timestamp TIMESTAMP,
+			updated_at TIMESTAMP,
+			PRIMARY KEY (consumer, chat_jid)
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %v", err)
+	}
+
+	// Bring older databases up to the current schema
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	reads, err := sql.Open(utcDriverName, fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d", path, busyTimeout.Milliseconds()))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open message database for reading: %v", err)
+	}
+	reads.SetMaxOpenConns(readConnections)
+
+	return &MessageStore{db: db, reads: reads}, nil
+}
+
+// Close the database connections
+func (store *MessageStore) Close() error {
+	store.reads.Close()
+	return store.db.Close()
+}
+
+// PoolStats describes a database connection pool
+type PoolStats struct {
+	Open  int `json:"open"`
+	InUse int `json:"in_use"`
+	Idle  int `json:"idle"`
+	// Connections that had to be waited for since the store was opened, and how long that took in total
+	WaitCount  int64 `json:"wait_count"`
+	WaitMillis int64 `json:"wait_ms"`
+}
+
+// ConnectionStats are the connection pools of a message store's writer and readers
+type ConnectionStats struct {
+	Writes PoolStats `json:"writes"`
+	Reads  PoolStats `json:"reads"`
+}
+
+// ConnectionStats returns the current state of the store's connection pools
+func (store *MessageStore) ConnectionStats() ConnectionStats {
+	return ConnectionStats{Writes: poolStats(store.db.Stats()), Reads: poolStats(store.reads.Stats())}
+}
+
+func poolStats(stats sql.DBStats) PoolStats {
+	return PoolStats{
+		Open:       stats.OpenConnections,
+		InUse:      stats.InUse,
+		Idle:       stats.Idle,
+		WaitCount:  stats.WaitCount,
+		WaitMillis: stats.WaitDuration.Milliseconds(),
+	}
+}
+
+// UpdateSenderName renames a sender in all of their stored messages; senders are stored either as a JID
+// or as a bare phone number. It returns the number of messages updated.
+func (store *MessageStore) UpdateSenderName(senderJID, phone, name string) (int64, error) {
+	result, err := store.exec(
+		"UPDATE messages SET sender_name = ? WHERE sender IN (?, ?) AND COALESCE(sender_name, '') != ?",
+		name, senderJID, phone, name,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Get messages from a chat
+func (store *MessageStore) GetMessages(chatJID string, limit int) ([]Message, error) {
+	rows, err := store.query(
+		`SELECT sender, content, timestamp, is_from_me, image_url, thumbnail_url, media_type, COALESCE(caption, ''),
+			COALESCE(filename, ''), COALESCE(mime_type, ''), COALESCE(file_size, 0)
+			FROM messages WHERE chat_jid = ? ORDER BY timestamp DESC LIMIT ?`,
+		chatJID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var timestamp time.Time
+		err := rows.Scan(&msg.Sender, &msg.Content, &timestamp, &msg.IsFromMe, &msg.ImageURL, &msg.ThumbnailURL, &msg.MediaType,
+			&msg.Caption, &msg.Filename, &msg.MimeType, &msg.FileSize)
+		if err != nil {
+			return nil, err
+		}
+		msg.Time = timestamp
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// Get all chats
+func (store *MessageStore) GetChats() (map[string]time.Time, error) {
+	rows, err := store.query("SELECT jid, last_message_time FROM chats ORDER BY last_message_time DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := make(map[string]time.Time)
+	for rows.Next() {
+		var jid string
+		var lastMessageTime time.Time
+		err := rows.Scan(&jid, &lastMessageTime)
+		if err != nil {
+			return nil, err
+		}
+		chats[jid] = lastMessageTime
+	}
+
+	return chats, nil
+}
+
+// ChatName returns the stored name of a chat, or "" if it is unknown
+func (store *MessageStore) ChatName(jid string) string {
+	var name string
+	store.queryRow("SELECT COALESCE(name, '') FROM chats WHERE jid = ?", jid).Scan(&name)
+	return name
+}
+
+// EnsureChat stores a chat unless it is already known
+func (store *MessageStore) EnsureChat(jid, name string, lastMessageTime time.Time) error {
+	_, err := store.exec("INSERT OR IGNORE INTO chats (jid, name, last_message_time) VALUES (?, ?, ?)", jid, name, lastMessageTime)
+	return err
+}
+
+// HasMessage checks whether a message with the given ID is stored in the chat
+func (store *MessageStore) HasMessage(id, chatJID string) (bool, error) {
+	var count int
+	err := store.queryRow("SELECT COUNT(*) FROM messages WHERE id = ? AND chat_jid = ?", id, chatJID).Scan(&count)
+	return count > 0, err
+}
+
+// HasSimilarMessage checks whether the chat already holds a message with the same content within a
+// minute of the timestamp; exports drop seconds on Android, so exact matches are not enough
+func (store *MessageStore) HasSimilarMessage(chatJID, content string, timestamp time.Time) (bool, error) {
+	rows, err := store.query("SELECT timestamp FROM messages WHERE chat_jid = ? AND content = ?", chatJID, content)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var existing time.Time
+		if err := rows.Scan(&existing); err != nil {
+			return false, err
+		}
+		if diff := existing.Sub(timestamp); diff < time.Minute && diff > -time.Minute {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
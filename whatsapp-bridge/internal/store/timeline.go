@@ -0,0 +1,222 @@
+package store
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Timeline item types
+const (
+	TimelinePhoto   = "photo"
+	TimelineMention = "mention"
+	TimelineEvent   = "event"
+)
+
+// TimelineItem is an entry of a child's timeline: a photo forwarded to their destination, a message
+// mentioning them by name, or an event detected in a monitored group
+type TimelineItem struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	MessageID string    `json:"message_id,omitempty"`
+	ChatJID   string    `json:"chat_jid,omitempty"`
+	// Who posted the photo or message
+	SenderName string `json:"sender_name,omitempty"`
+	// The caption of a photo, the text of a mention or the title of an event
+	Text      string `json:"text,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	// Served by /api/media/{id}
+	MediaURL string `json:"media_url,omitempty"`
+	// Photos only recorded in dry-run mode, never actually sent
+	DryRun  bool   `json:"dry_run,omitempty"`
+	AllDay  bool   `json:"all_day,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// hebrewPrefixes are the one-letter prefixes written together with a name ("לדני", "שנועה")
+const hebrewPrefixes = "בהוכלמש"
+
+// MentionsName reports whether text contains name as a word of its own, ignoring case. A Hebrew
+// one-letter prefix before the name still counts as a mention.
+func MentionsName(text, name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return false
+	}
+	text = strings.ToLower(text)
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], name)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(name)
+		offset = start + 1
+		before := []rune(text[:start])
+		after := []rune(text[end:])
+		if len(after) > 0 && isWordRune(after[0]) {
+			continue
+		}
+		if n := len(before); n == 0 || !isWordRune(before[n-1]) ||
+			(strings.ContainsRune(hebrewPrefixes, before[n-1]) && (n == 1 || !isWordRune(before[n-2]))) {
+			return true
+		}
+	}
+}
+
+// isWordRune reports whether r is part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// mediaURL is the API path serving the media of a stored message
+func mediaURL(id, chatJID string) string {
+	return "/api/media/" + id + "?chat_jid=" + chatJID
+}
+
+// GetTimeline returns the timeline of a child, oldest first: the photos forwarded (or dry-run
+// forwarded) to destination, and the messages mentioning name and the events detected in chats,
+// within the time range of filter. At most limit items are returned, the earliest ones.
+func (store *MessageStore) GetTimeline(destination, name string, chats []string, filter ExportFilter, limit int) ([]TimelineItem, error) {
+	items := []TimelineItem{}
+	inRange := func(t time.Time) bool {
+		return (filter.From.IsZero() || !t.Before(filter.From)) && (filter.To.IsZero() || t.Before(filter.To))
+	}
+
+	// Photos, once each however often they were forwarded, at the time they were posted
+	rows, err := store.query(`SELECT COALESCE(f.message_id, ''), COALESCE(f.chat_jid, ''), COALESCE(m.sender_name, ''),
+		f.media_path, COALESCE(f.caption, ''), f.dry_run, f.timestamp, m.timestamp, COALESCE(m.media_type, '')
+		FROM forward_log f LEFT JOIN messages m ON m.id = f.message_id AND m.chat_jid = f.chat_jid
+		WHERE f.destination = ? AND COALESCE(f.media_path, '') != '' ORDER BY f.timestamp ASC`, destination)
+	if err != nil {
+		return nil, err
+	}
+	photos := make(map[string]int)
+	for rows.Next() {
+		var item TimelineItem
+		var mediaPath string
+		var posted sql.NullTime
+		if err := rows.Scan(&item.MessageID, &item.ChatJID, &item.SenderName, &mediaPath, &item.Text, &item.DryRun,
+			&item.Timestamp, &posted, &item.MediaType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		key := mediaPath
+		if item.MessageID != "" {
+			key = item.MessageID + "|" + item.ChatJID
+		}
+		if i, ok := photos[key]; ok {
+			// A real send of a photo first recorded in dry-run mode
+			items[i].DryRun = items[i].DryRun && item.DryRun
+			continue
+		}
+		if posted.Valid {
+			item.Timestamp = posted.Time
+		}
+		if !inRange(item.Timestamp) {
+			continue
+		}
+		item.Type = TimelinePhoto
+		if item.MessageID != "" {
+			item.MediaURL = mediaURL(item.MessageID, item.ChatJID)
+		}
+		if item.MediaType == "" {
+			item.MediaType = "image"
+		}
+		photos[key] = len(items)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(chats) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chats)), ", ")
+		chatArgs := make([]interface{}, len(chats))
+		for i, chat := range chats {
+			chatArgs[i] = chat
+		}
+
+		// Messages mentioning the child, narrowed down in SQL and matched as whole words here
+		if strings.TrimSpace(name) != "" {
+			where, args := filterClause("", ExportFilter{From: filter.From, To: filter.To})
+			pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSpace(name)) + "%"
+			args = append(append(chatArgs, pattern, pattern), args...)
+			rows, err := store.query(`SELECT id, chat_jid, COALESCE(sender_name, ''), COALESCE(content, ''), COALESCE(caption, ''),
+				timestamp, COALESCE(image_url, ''), COALESCE(media_type, '')
+				FROM messages WHERE chat_jid IN (`+placeholders+`) AND NOT spam
+				AND (content LIKE ? ESCAPE '\' OR caption LIKE ? ESCAPE '\')`+where, args...)
+			if err != nil {
+				return nil, err
+			}
+			for rows.Next() {
+				var item TimelineItem
+				var content, caption, imageURL string
+				if err := rows.Scan(&item.MessageID, &item.ChatJID, &item.SenderName, &content, &caption, &item.Timestamp, &imageURL, &item.MediaType); err != nil {
+					rows.Close()
+					return nil, err
+				}
+				if _, ok := photos[item.MessageID+"|"+item.ChatJID]; ok {
+					continue
+				}
+				item.Text = content
+				if caption != "" && caption != content {
+					item.Text = strings.TrimSpace(content + "\n" + caption)
+				}
+				if !MentionsName(item.Text, name) {
+					continue
+				}
+				item.Type = TimelineMention
+				if imageURL != "" {
+					item.MediaURL = mediaURL(item.MessageID, item.ChatJID)
+				} else {
+					item.MediaType = ""
+				}
+				items = append(items, item)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		// Events, at the time they take place
+		query := `SELECT message_id, chat_jid, title, start_time, all_day, COALESCE(details, '')
+			FROM calendar_events WHERE chat_jid IN (` + placeholders + `)`
+		args := chatArgs
+		if !filter.From.IsZero() {
+			query += " AND start_time >= ?"
+			args = append(args, filter.From)
+		}
+		if !filter.To.IsZero() {
+			query += " AND start_time < ?"
+			args = append(args, filter.To)
+		}
+		rows, err := store.query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			item := TimelineItem{Type: TimelineEvent}
+			if err := rows.Scan(&item.MessageID, &item.ChatJID, &item.Text, &item.Timestamp, &item.AllDay, &item.Details); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Timestamp.Before(items[j].Timestamp)
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
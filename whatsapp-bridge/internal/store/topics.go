@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// TaggingJob is a stored message whose topics haven't been decided yet
+type TaggingJob struct {
+	Rowid     int64
+	Text      string
+	MediaType string
+}
+
+// TopicCount is how many messages are tagged with a topic
+type TopicCount struct {
+	Topic    string `json:"topic"`
+	Messages int    `json:"messages"`
+}
+
+// UntaggedMessages returns up to limit messages that haven't been tagged, newest first
+func (store *MessageStore) UntaggedMessages(limit int) ([]TaggingJob, error) {
+	rows, err := store.query(`SELECT rowid, TRIM(COALESCE(content, '') || ' ' || COALESCE(caption, '')), COALESCE(media_type, '')
+		FROM messages WHERE topics IS NULL ORDER BY timestamp DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []TaggingJob
+	for rows.Next() {
+		var job TaggingJob
+		if err := rows.Scan(&job.Rowid, &job.Text, &job.MediaType); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveTopics stores the topics of messages by rowid; a message without topics is stored as tagged with
+// none, so it isn't tagged again until its text changes
+func (store *MessageStore) SaveTopics(topics map[int64][]string) error {
+	return store.transaction(func(tx *sql.Tx) error {
+		for rowid, tags := range topics {
+			if _, err := tx.Exec("UPDATE messages SET topics = ? WHERE rowid = ?", strings.Join(tags, ","), rowid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetMessageTopics stores the topics of a message
+func (store *MessageStore) SetMessageTopics(messageID, chatJID string, topics []string) error {
+	_, err := store.exec("UPDATE messages SET topics = ? WHERE id = ? AND chat_jid = ?", strings.Join(topics, ","), messageID, chatJID)
+	return err
+}
+
+// CountTopics returns how many messages matching filter are tagged with each topic, most first.
+// Probable spam is left out.
+func (store *MessageStore) CountTopics(filter ExportFilter) ([]TopicCount, error) {
+	where, args := filterClause("messages.", filter)
+	rows, err := store.query(`SELECT COALESCE(topics, '') FROM messages WHERE COALESCE(topics, '') != '' AND NOT spam`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var topics string
+		if err := rows.Scan(&topics); err != nil {
+			return nil, err
+		}
+		for _, topic := range strings.Split(topics, ",") {
+			counts[topic]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result := []TopicCount{}
+	for topic, count := range counts {
+		result = append(result, TopicCount{Topic: topic, Messages: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Messages != result[j].Messages {
+			return result[i].Messages > result[j].Messages
+		}
+		return result[i].Topic < result[j].Topic
+	})
+	return result, nil
+}
@@ -0,0 +1,266 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"whatsapp-client/internal/config"
+)
+
+// minPasswordLength is the shortest password a user may have
+const minPasswordLength = 8
+
+// ErrInvalidLogin is returned for an unknown user or a wrong password; which of the two isn't told apart
+var ErrInvalidLogin = errors.New("invalid user name or password")
+
+// ErrPasswordUser is returned when someone logs in through an identity provider with the name of a user
+// who logs in with a password, which would otherwise hand them that user's account
+var ErrPasswordUser = errors.New("the user logs in with a password")
+
+// ErrNoAdmin is returned when saving a user would leave the users without an admin
+var ErrNoAdmin = errors.New("users need an admin to manage them")
+
+// User is someone who may log in to the dashboard and API
+type User struct {
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckUser rejects user names, roles and passwords that can't be saved. An empty password is allowed
+// and keeps an existing user's password.
+func CheckUser(name, password, role string) error {
+	if name == "" || strings.TrimSpace(name) != name || strings.ContainsAny(name, "/:") {
+		return fmt.Errorf("invalid user name %q", name)
+	}
+	if !slices.Contains(config.Roles, role) {
+		return fmt.Errorf("invalid role %q, use %s", role, strings.Join(config.Roles, ", "))
+	}
+	if password != "" && len(password) < minPasswordLength {
+		return fmt.Errorf("the password must have at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// SaveUser adds a user or changes an existing user's role, and password unless it is empty. New users
+// need a password. Users turn on login, so one of them must be an admin: the first user can't have
+// another role. Changing the password ends the user's sessions.
+func (store *MessageStore) SaveUser(name, password, role string) error {
+	if err := CheckUser(name, password, role); err != nil {
+		return err
+	}
+	var hash []byte
+	if password != "" {
+		var err error
+		if hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			return err
+		}
+	}
+	return store.transaction(func(tx *sql.Tx) error {
+		if err := checkAdminLeft(tx, name, role); err != nil {
+			return err
+		}
+		now := time.Now()
+		result, err := tx.Exec("UPDATE users SET role = ?, password_hash = COALESCE(?, password_hash), updated_at = ? WHERE name = ?",
+			role, nullIfEmpty(string(hash)), now, name)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			if hash == nil {
+				return fmt.Errorf("new users need a password")
+			}
+			if _, err := tx.Exec("INSERT INTO users (name, password_hash, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+				name, string(hash), role, now, now); err != nil {
+				return err
+			}
+		}
+		if hash != nil {
+			_, err = tx.Exec("DELETE FROM user_sessions WHERE user_name = ?", name)
+		}
+		return err
+	})
+}
+
+// nullIfEmpty stores empty strings as NULL
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ListUsers returns all users by name
+func (store *MessageStore) ListUsers() ([]User, error) {
+	rows, err := store.query("SELECT name, role, created_at, updated_at FROM users ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// HasUsers reports whether any users were added, which turns on login for the API
+func (store *MessageStore) HasUsers() (bool, error) {
+	var exists bool
+	err := store.queryRow("SELECT EXISTS (SELECT 1 FROM users)").Scan(&exists)
+	return exists, err
+}
+
+// DeleteUser removes a user and ends their sessions. Returns sql.ErrNoRows if there is no such user.
+func (store *MessageStore) DeleteUser(name string) error {
+	return store.transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM users WHERE name = ?", name)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tx.Exec("DELETE FROM user_sessions WHERE user_name = ?", name)
+		return err
+	})
+}
+
+// checkAdminLeft returns ErrNoAdmin if saving name with role would leave no admin among the users
+func checkAdminLeft(tx *sql.Tx, name, role string) error {
+	if role == config.RoleAdmin {
+		return nil
+	}
+	var otherAdmin bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE role = ? AND name != ?)", config.RoleAdmin, name).Scan(&otherAdmin); err != nil {
+		return err
+	}
+	if !otherAdmin {
+		return fmt.Errorf("%w, save %s as admin or add an admin first", ErrNoAdmin, name)
+	}
+	return nil
+}
+
+// SaveSignedInUser adds or updates a user who logged in through an identity provider, with the role
+// their groups there grant. Such users have no password; returns ErrPasswordUser for the name of a user
+// who has one, and ErrNoAdmin like SaveUser when the users would be left without an admin.
+func (store *MessageStore) SaveSignedInUser(name, role string) (*User, error) {
+	if err := CheckUser(name, "", role); err != nil {
+		return nil, err
+	}
+	err := store.transaction(func(tx *sql.Tx) error {
+		var hasPassword bool
+		err := tx.QueryRow("SELECT password_hash IS NOT NULL FROM users WHERE name = ?", name).Scan(&hasPassword)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if hasPassword {
+			return ErrPasswordUser
+		}
+		if err := checkAdminLeft(tx, name, role); err != nil {
+			return err
+		}
+		now := time.Now()
+		_, err = tx.Exec(`INSERT INTO users (name, role, created_at, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET role = excluded.role, updated_at = excluded.updated_at`,
+			name, role, now, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	err = store.queryRow("SELECT name, role, created_at, updated_at FROM users WHERE name = ?", name).
+		Scan(&user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login checks a user's password and starts a session of the given length
+func (store *MessageStore) Login(name, password string, lifetime time.Duration) (*User, string, time.Time, error) {
+	var user User
+	var hash sql.NullString
+	err := store.queryRow("SELECT name, role, created_at, updated_at, password_hash FROM users WHERE name = ?", name).
+		Scan(&user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", time.Time{}, ErrInvalidLogin
+	}
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if !hash.Valid || bcrypt.CompareHashAndPassword([]byte(hash.String), []byte(password)) != nil {
+		return nil, "", time.Time{}, ErrInvalidLogin
+	}
+	token, expires, err := store.StartSession(user.Name, lifetime)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return &user, token, expires, nil
+}
+
+// StartSession starts a session of the given length for a user who has proven who they are. The returned
+// token authenticates the session; only its hash is stored.
+func (store *MessageStore) StartSession(name string, lifetime time.Duration) (string, time.Time, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(secret)
+	now := time.Now()
+	expires := now.Add(lifetime)
+	err := store.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM user_sessions WHERE expires_at < ?", now); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO user_sessions (token_hash, user_name, created_at, expires_at) VALUES (?, ?, ?, ?)",
+			sessionTokenHash(token), name, now, expires)
+		return err
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// SessionUser returns the user of an unexpired session. Returns sql.ErrNoRows for unknown or expired
+// tokens.
+func (store *MessageStore) SessionUser(token string) (*User, error) {
+	var user User
+	err := store.queryRow(`SELECT users.name, users.role, users.created_at, users.updated_at
+		FROM user_sessions JOIN users ON users.name = user_sessions.user_name
+		WHERE user_sessions.token_hash = ? AND user_sessions.expires_at > ?`, sessionTokenHash(token), time.Now()).
+		Scan(&user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Logout ends the session of a token
+func (store *MessageStore) Logout(token string) error {
+	_, err := store.exec("DELETE FROM user_sessions WHERE token_hash = ?", sessionTokenHash(token))
+	return err
+}
+
+// sessionTokenHash is what is stored of a session token, so a copy of the database can't be used to log in
+func sessionTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// utcDriverName is the SQLite driver the message store is opened with. It stores every time in UTC:
+// SQLite compares timestamps as text, so times written with different offsets, such as before and after
+// a change to daylight saving time, would sort and filter wrong.
+const utcDriverName = "sqlite3_utc"
+
+func init() {
+	sql.Register(utcDriverName, utcDriver{})
+}
+
+// utcDriver opens SQLite connections that convert time arguments to UTC
+type utcDriver struct{}
+
+func (utcDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// utcConn is a SQLite connection whose time arguments are converted to UTC before they are bound
+type utcConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// CheckNamedValue converts arguments as database/sql does by default, then moves times to UTC
+func (utcConn) CheckNamedValue(arg *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(arg.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	arg.Value = value
+	return nil
+}
+
+// utcTimestamps rewrites the timestamps of columns, given as table.column, that were stored with an
+// offset other than UTC in the same format the driver writes: fractions of a second are kept to the
+// millisecond
+func utcTimestamps(columns ...string) string {
+	var statements string
+	for _, column := range columns {
+		table, name, _ := strings.Cut(column, ".")
+		statements += fmt.Sprintf(`UPDATE %[1]s SET %[2]s = strftime(CASE WHEN %[2]s LIKE '%%.%%' THEN '%%Y-%%m-%%d %%H:%%M:%%f' ELSE '%%Y-%%m-%%d %%H:%%M:%%S' END, %[2]s) || '+00:00'
+		 WHERE %[2]s NOT LIKE '%%+00:00' AND (%[2]s LIKE '%%+__:__' OR %[2]s LIKE '%%-__:__');
+`, table, name)
+	}
+	return statements
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"container/heap"
+	"database/sql"
+	"encoding/binary"
+	"math"
+)
+
+// EmbeddingJob is the text of a stored message that has no embedding of the current model yet
+type EmbeddingJob struct {
+	Rowid int64
+	Text  string
+}
+
+// Embedding is the vector of a message's text by a model, scaled to unit length
+type Embedding struct {
+	Rowid  int64
+	Vector []float32
+}
+
+// PendingEmbeddings returns up to limit messages with text that have no embedding of model, newest
+// first, so recent messages become searchable by meaning before old ones. Probable spam is left out.
+func (store *MessageStore) PendingEmbeddings(model string, limit int) ([]EmbeddingJob, error) {
+	rows, err := store.query(`SELECT messages.rowid, TRIM(COALESCE(messages.content, '') || ' ' || COALESCE(messages.caption, ''))
+		FROM messages LEFT JOIN message_vectors ON message_vectors.message_rowid = messages.rowid AND message_vectors.model = ?
+		WHERE message_vectors.message_rowid IS NULL AND NOT messages.spam
+		AND TRIM(COALESCE(messages.content, '') || ' ' || COALESCE(messages.caption, '')) != ''
+		ORDER BY messages.timestamp DESC LIMIT ?`, model, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []EmbeddingJob
+	for rows.Next() {
+		var job EmbeddingJob
+		if err := rows.Scan(&job.Rowid, &job.Text); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveEmbeddings stores the embeddings of model, replacing those of another model
+func (store *MessageStore) SaveEmbeddings(model string, embeddings []Embedding) error {
+	return store.transaction(func(tx *sql.Tx) error {
+		for _, embedding := range embeddings {
+			if _, err := tx.Exec("INSERT OR REPLACE INTO message_vectors (message_rowid, model, vector) VALUES (?, ?, ?)",
+				embedding.Rowid, model, encodeVector(embedding.Vector)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountEmbeddings returns how many messages have an embedding of model and how many have text to embed
+func (store *MessageStore) CountEmbeddings(model string) (embedded, total int, err error) {
+	err = store.queryRow(`SELECT (SELECT COUNT(*) FROM message_vectors WHERE model = ?),
+		(SELECT COUNT(*) FROM messages WHERE NOT spam AND TRIM(COALESCE(content, '') || ' ' || COALESCE(caption, '')) != '')`,
+		model).Scan(&embedded, &total)
+	return embedded, total, err
+}
+
+// NearestMessages returns up to limit messages matching filter whose embedding of model is closest to
+// vector, a unit vector of the same model, most similar first. Messages with nothing in common with
+// it are left out. Every embedding is compared, which takes well under a second for the archive of a
+// family's groups.
+func (store *MessageStore) NearestMessages(model string, vector []float32, filter ExportFilter, limit int) ([]SearchResult, error) {
+	where, args := filterClause("messages.", filter)
+	rows, err := store.query(`SELECT message_vectors.message_rowid, message_vectors.vector
+		FROM message_vectors JOIN messages ON messages.rowid = message_vectors.message_rowid
+		WHERE message_vectors.model = ? AND NOT messages.spam`+where, append([]interface{}{model}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	nearest := &similarityHeap{}
+	for rows.Next() {
+		var rowid int64
+		var blob []byte
+		if err := rows.Scan(&rowid, &blob); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		similarity := dot(vector, blob)
+		if similarity <= 0 {
+			continue
+		}
+		if nearest.Len() < limit {
+			heap.Push(nearest, scoredRow{rowid, similarity})
+		} else if limit > 0 && similarity > (*nearest)[0].similarity {
+			(*nearest)[0] = scoredRow{rowid, similarity}
+			heap.Fix(nearest, 0)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, nearest.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		row := heap.Pop(nearest).(scoredRow)
+		result := SearchResult{Similarity: row.similarity}
+		err := store.queryRow(`SELECT messages.id, messages.chat_jid, COALESCE(chats.name, ''), messages.sender, COALESCE(messages.sender_name, ''),
+			TRIM(COALESCE(messages.content, '') || ' ' || COALESCE(messages.caption, '')), messages.timestamp, COALESCE(messages.media_type, '')
+			FROM messages LEFT JOIN chats ON chats.jid = messages.chat_jid WHERE messages.rowid = ?`, row.rowid).Scan(
+			&result.MessageID, &result.ChatJID, &result.ChatName, &result.Sender, &result.SenderName, &result.Text, &result.Timestamp, &result.MediaType)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// scoredRow is a message's rowid with the similarity of its embedding to a query
+type scoredRow struct {
+	rowid      int64
+	similarity float64
+}
+
+// similarityHeap keeps the most similar rows seen, with the least similar of them on top
+type similarityHeap []scoredRow
+
+func (h similarityHeap) Len() int            { return len(h) }
+func (h similarityHeap) Less(i, j int) bool  { return h[i].similarity < h[j].similarity }
+func (h similarityHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *similarityHeap) Push(x interface{}) { *h = append(*h, x.(scoredRow)) }
+func (h *similarityHeap) Pop() interface{} {
+	old := *h
+	row := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return row
+}
+
+// encodeVector stores a vector as little-endian float32s
+func encodeVector(vector []float32) []byte {
+	blob := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(value))
+	}
+	return blob
+}
+
+// dot is the dot product of vector and an encoded vector, their cosine similarity as both are unit
+// vectors. Vectors of different lengths only compare their common part.
+func dot(vector []float32, blob []byte) float64 {
+	var sum float64
+	for i := 0; i < len(vector) && 4*i+4 <= len(blob); i++ {
+		sum += float64(vector[i]) * float64(math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:])))
+	}
+	return sum
+}
@@ -0,0 +1,323 @@
+// Package topics tags stored messages with what they are about, such as health or payments, so they can
+// be found by topic and sent on to whoever follows a topic, e.g. health messages to the other parent.
+package topics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/crash"
+	"whatsapp-client/internal/i18n"
+	"whatsapp-client/internal/routing"
+	"whatsapp-client/internal/search"
+	"whatsapp-client/internal/store"
+)
+
+const (
+	// backfillBatch is how many untagged messages are tagged at a time
+	backfillBatch = 200
+	// idleInterval is how often untagged messages are looked for once all stored ones are tagged
+	idleInterval = time.Minute
+	// forwardTimeout bounds sending one copy of a message
+	forwardTimeout = 2 * time.Minute
+)
+
+// Classifier decides the topics of a message's text, or of its media when it has no text
+type Classifier interface {
+	Classify(text, mediaType string) ([]string, error)
+}
+
+// defaultKeywords are the built-in words of each topic. Some Hebrew entries are stems, so "תמונ" matches
+// "תמונות"; words of up to three letters only match themselves, so "טקס" doesn't match "טקסט".
+var defaultKeywords = map[string][]string{
+	config.TopicLogistics: {
+		"pickup", "pick up", "drop off", "bring", "closed", "early", "schedule", "shoes", "clothes",
+		"איסוף", "לאסוף", "הסעה", "להביא", "תביאו", "סגור", "סגורה", "מוקדם", "ציוד", "בגדי", "נעלי", "כובע", "בקבוק",
+	},
+	config.TopicHealth: {
+		"sick", "fever", "lice", "doctor", "allergy", "allergic", "vaccin", "rash", "virus", "covid", "medicine",
+		"חולה", "חולים", "מחלה", "מחלות", "כינים", "חום גבוה", "רופא", "אלרגי", "חיסון", "פריחה", "אבעבועות", "וירוס", "קורונה", "תרופ", "שלשול", "הקאות",
+	},
+	config.TopicPhotos: {
+		"photo", "picture", "pics", "album", "video",
+		"תמונ", "צילו", "אלבום", "סרטון",
+	},
+	config.TopicPayments: {
+		"pay", "paid", "payment", "money", "fees", "transfer", "paybox", "₪", "nis", "shekel",
+		"תשלו", "לשלם", "שילמ", "כסף", "העברה", "פייבוקס", "ש\"ח", "שקל",
+	},
+	config.TopicEvents: {
+		"trip", "party", "meeting", "ceremony", "holiday", "show", "birthday", "event", "celebration",
+		"טיול", "מסיב", "אסיפ", "טקס", "הצגה", "הצגות", "יום הולדת", "אירוע", "חגיג", "מפגש",
+	},
+}
+
+// keywordClassifier tags a message with every topic one of whose words it contains
+type keywordClassifier struct {
+	keywords map[string]search.Keywords
+}
+
+// Classify implements Classifier with keywords; photos and videos are always about photos
+func (c keywordClassifier) Classify(text, mediaType string) ([]string, error) {
+	var topics []string
+	if mediaType == "image" || mediaType == "video" {
+		topics = append(topics, config.TopicPhotos)
+	}
+	for topic, keywords := range c.keywords {
+		if !slices.Contains(topics, topic) && keywords.Match(text) {
+			topics = append(topics, topic)
+		}
+	}
+	slices.Sort(topics)
+	return topics, nil
+}
+
+// remoteClassifier delegates tagging to an external service, such as a machine learning model
+type remoteClassifier struct {
+	url    string
+	client *http.Client
+}
+
+// Classify implements Classifier by POSTing the message to the configured service
+func (c remoteClassifier) Classify(text, mediaType string) ([]string, error) {
+	body, err := json.Marshal(map[string]string{"text": text, "media_type": mediaType})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("classifier returned %s", resp.Status)
+	}
+
+	var result struct {
+		Topics []string `json:"topics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid classifier response: %v", err)
+	}
+	// Topics are stored comma-separated, so a topic can't contain one
+	var topics []string
+	for _, topic := range result.Topics {
+		topic = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(topic, ",", " ")))
+		if topic != "" && !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	slices.Sort(topics)
+	return topics, nil
+}
+
+// keywords returns the built-in words of each topic with the configured ones added
+func keywords(cfg config.TopicsConfig) map[string]search.Keywords {
+	all := make(map[string]search.Keywords, len(defaultKeywords)+len(cfg.Keywords))
+	for topic, words := range defaultKeywords {
+		all[topic] = search.Keywords{Stems: slices.Clone(words)}
+	}
+	for topic, words := range cfg.Keywords {
+		all[topic] = search.Keywords{Stems: append(all[topic].Stems, words...)}
+	}
+	return all
+}
+
+// classify returns the topics of a message by the configured classifier, falling back to the keywords
+// while the classifier fails
+func classify(cfg config.TopicsConfig, text, mediaType string) []string {
+	rules := keywordClassifier{keywords: keywords(cfg)}
+	if cfg.ClassifierURL != "" {
+		remote := remoteClassifier{url: cfg.ClassifierURL, client: &http.Client{Timeout: 10 * time.Second}}
+		topics, err := remote.Classify(text, mediaType)
+		if err == nil {
+			return topics
+		}
+		fmt.Printf("[TOPICS] Classifier failed, using keywords: %v\n", err)
+	}
+	topics, _ := rules.Classify(text, mediaType)
+	return topics
+}
+
+// Sender sends text to a chat, or a photo or video with the text as its caption when mediaPath is set,
+// and returns the ID of the sent message
+type Sender func(ctx context.Context, chatJID, text, mediaPath, mediaType string) (string, error)
+
+var (
+	mu   sync.Mutex
+	send Sender
+	// announcements is the store forwards are recorded in for acknowledgment tracking, the main one also
+	// for messages of isolated pipelines
+	announcements *store.MessageStore
+)
+
+// Message is a message just stored, to be tagged
+type Message struct {
+	ID         string
+	ChatJID    string
+	ChatName   string
+	Sender     string
+	SenderName string
+	Text       string
+	MediaType  string
+	// Downloaded photo or video, sent along with the forwards that ask for it
+	MediaPath string
+	// Send the message on to the chats following its topics
+	Forward bool
+}
+
+// Start tags the stored messages that have no topics yet in the background, newest first, and sends
+// forwards of new messages with send
+func Start(messageStore *store.MessageStore, sender Sender) {
+	mu.Lock()
+	send = sender
+	announcements = messageStore
+	mu.Unlock()
+	if !config.Current().Topics.Enabled {
+		return
+	}
+	go backfill(messageStore)
+}
+
+// Tag stores the topics of a message just stored and, when msg.Forward is set, sends a copy of it to the
+// chats following one of them
+func Tag(messageStore *store.MessageStore, msg Message, logger waLog.Logger) {
+	cfg := config.Current().Topics
+	if !cfg.Enabled {
+		return
+	}
+	topics := classify(cfg, msg.Text, msg.MediaType)
+	if err := messageStore.SetMessageTopics(msg.ID, msg.ChatJID, topics); err != nil {
+		logger.Warnf("Failed to store the topics of %s: %v", msg.ID, err)
+		return
+	}
+	if len(topics) == 0 {
+		return
+	}
+	logger.Infof("[TOPICS] Tagged message %s in %s: %s", msg.ID, msg.ChatJID, strings.Join(topics, ", "))
+	if msg.Forward {
+		forward(cfg, msg, topics)
+	}
+}
+
+// forward sends a copy of msg to every chat following one of its topics, once per chat, in the background.
+// The photo or video only goes along to the chats whose rule asks for it.
+func forward(cfg config.TopicsConfig, msg Message, topics []string) {
+	mu.Lock()
+	sender, ledger := send, announcements
+	mu.Unlock()
+	if sender == nil {
+		return
+	}
+	sent := make(map[string]bool)
+	for _, rule := range cfg.Forwards {
+		if !slices.Contains(topics, rule.Topic) || sent[rule.ChatJID] || rule.ChatJID == msg.ChatJID {
+			continue
+		}
+		sent[rule.ChatJID] = true
+		text := i18n.T("topics.forward", label(rule.Topic), msg.SenderName, msg.ChatName)
+		if msg.Text != "" {
+			text += "\n" + msg.Text
+		}
+		if routing.IsDryRunSend(rule.ChatJID, false) {
+			fmt.Printf("[TOPICS] Dry run: would forward %s message %s to %s\n", rule.Topic, msg.ID, rule.ChatJID)
+			continue
+		}
+		mediaPath, mediaType := "", ""
+		if rule.Media {
+			mediaPath, mediaType = msg.MediaPath, msg.MediaType
+		}
+		go func(chatJID, topic string) {
+			ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+			defer cancel()
+			sentID, err := sender(ctx, chatJID, text, mediaPath, mediaType)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to forward %s message %s to %s: %v\n", topic, msg.ID, chatJID, err)
+				return
+			}
+			fmt.Printf("[TOPICS] Forwarded %s message %s to %s\n", topic, msg.ID, chatJID)
+			if config.Current().Acknowledgments.Enabled && ledger != nil {
+				err := ledger.StoreAnnouncement(store.Announcement{
+					MessageID:       sentID,
+					ChatJID:         recipientJID(chatJID),
+					SourceMessageID: msg.ID,
+					SourceChatJID:   msg.ChatJID,
+					SourceSender:    msg.Sender,
+					Topic:           topic,
+					Text:            text,
+					SentAt:          time.Now(),
+				})
+				if err != nil {
+					fmt.Printf("[TOPICS] Failed to record the forward of %s to %s: %v\n", msg.ID, chatJID, err)
+				}
+			}
+		}(rule.ChatJID, rule.Topic)
+	}
+}
+
+// recipientJID is the JID of a chat given as a JID or as a phone number, the way its messages arrive
+func recipientJID(chatJID string) string {
+	if strings.Contains(chatJID, "@") {
+		return chatJID
+	}
+	return config.JIDUser(chatJID) + "@" + types.DefaultUserServer
+}
+
+// label names a topic in the configured locale; topics of one's own keep their name
+func label(topic string) string {
+	key := "topic." + topic
+	if text := i18n.T(key); text != key {
+		return text
+	}
+	return topic
+}
+
+// backfill tags untagged messages batch after batch until the process exits: those stored before topics
+// were turned on, from history syncs and imports, and those whose text was edited
+func backfill(messageStore *store.MessageStore) {
+	for {
+		count, err := tagBatch(messageStore)
+		if err != nil {
+			fmt.Printf("[TOPICS] Failed to tag messages: %v\n", err)
+		}
+		if err != nil || count < backfillBatch {
+			time.Sleep(idleInterval)
+		}
+	}
+}
+
+// tagBatch tags up to backfillBatch untagged messages and returns how many there were. A panic in the
+// classifier fails the batch rather than stopping the tagging.
+func tagBatch(messageStore *store.MessageStore) (count int, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			crash.Report("the topic tagger", value, debug.Stack())
+			err = fmt.Errorf("classifier panicked: %v", value)
+		}
+	}()
+	cfg := config.Current().Topics
+	if !cfg.Enabled {
+		return 0, nil
+	}
+	jobs, err := messageStore.UntaggedMessages(backfillBatch)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+	tagged := make(map[int64][]string, len(jobs))
+	for _, job := range jobs {
+		tagged[job.Rowid] = classify(cfg, job.Text, job.MediaType)
+	}
+	return len(jobs), messageStore.SaveTopics(tagged)
+}
@@ -0,0 +1,353 @@
+// Package tracing records spans of the message pipeline and exports them over OTLP/HTTP, and carries
+// request IDs through contexts.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-client/internal/config"
+	"whatsapp-client/internal/media"
+)
+
+// OTLP span kinds
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// Span is a timed operation of the message pipeline. A nil *Span is valid and records nothing,
+// which is what callers get while tracing is disabled.
+type Span struct {
+	ctx    SpanContext
+	parent [8]byte
+	name   string
+	kind   int
+	start  time.Time
+	end    time.Time
+	attrs  map[string]interface{}
+	links  []SpanContext
+	errMsg string
+}
+
+type spanContextKey struct{}
+
+// tracer batches finished spans and ships them to the collector
+type tracer struct {
+	config config.TracingConfig
+	spans  chan *Span
+	flush  chan chan struct{}
+	client *http.Client
+}
+
+var activeTracer *tracer
+
+// mediaTraceTTL bounds how long a downloaded file is remembered; most photos are never forwarded
+const mediaTraceTTL = time.Hour
+
+// mediaTrace is the span that downloaded a media file
+type mediaTrace struct {
+	span SpanContext
+	at   time.Time
+}
+
+// mediaTraces remembers which trace downloaded a media file, so forwarding that file later joins the
+// same trace and "photo posted" to "photo forwarded" shows up as one timeline
+var mediaTraces sync.Map
+
+// Start enables span export according to cfg
+func Start(cfg config.TracingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	activeTracer = &tracer{
+		config: cfg,
+		spans:  make(chan *Span, 2048),
+		flush:  make(chan chan struct{}),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	go activeTracer.run()
+	fmt.Printf("[TRACE] Exporting spans to %s\n", cfg.Endpoint)
+}
+
+// StartSpan begins a span as a child of the span in ctx, or as a new trace if there is none
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if activeTracer == nil {
+		return ctx, nil
+	}
+	span := &Span{name: name, kind: kind, start: time.Now(), attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanContextKey{}).(SpanContext); ok {
+		span.ctx.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.ctx.TraceID[:])
+	}
+	rand.Read(span.ctx.SpanID[:])
+	return context.WithValue(ctx, spanContextKey{}, span.ctx), span
+}
+
+// SetAttr records an attribute on the span
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs[key] = value
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.errMsg = err.Error()
+}
+
+// AddLink relates the span to a span of another trace
+func (s *Span) AddLink(other SpanContext) {
+	if s == nil {
+		return
+	}
+	s.links = append(s.links, other)
+}
+
+// End finishes the span and queues it for export; spans are dropped if the exporter falls behind
+func (s *Span) End() {
+	if s == nil || activeTracer == nil {
+		return
+	}
+	s.end = time.Now()
+	select {
+	case activeTracer.spans <- s:
+	default:
+	}
+}
+
+// FromContext returns the identity of the current span in ctx
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// ContextWithParent makes sc the parent of spans started from the returned context
+func ContextWithParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// RememberMediaTrace records the trace that produced a media file
+func RememberMediaTrace(ctx context.Context, path string) {
+	sc, ok := FromContext(ctx)
+	if !ok || path == "" {
+		return
+	}
+	now := time.Now()
+	mediaTraces.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(mediaTrace).at) > mediaTraceTTL {
+			mediaTraces.Delete(key)
+		}
+		return true
+	})
+	mediaTraces.Store(media.FileKey(path), mediaTrace{span: sc, at: now})
+}
+
+// TakeMediaTrace returns (and forgets) the trace that produced a media file
+func TakeMediaTrace(path string) (SpanContext, bool) {
+	if path == "" {
+		return SpanContext{}, false
+	}
+	value, ok := mediaTraces.LoadAndDelete(media.FileKey(path))
+	if !ok {
+		return SpanContext{}, false
+	}
+	return value.(mediaTrace).span, true
+}
+
+// parseTraceparent reads a W3C traceparent header ("00-<trace id>-<span id>-<flags>")
+func parseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	return sc, true
+}
+
+// Middleware wraps every API request in a server span, continuing the caller's trace if it sent
+// a traceparent header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if activeTracer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		if sc, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = ContextWithParent(ctx, sc)
+		}
+		ctx, span := StartSpan(ctx, r.Method+" "+r.URL.Path, SpanKindServer)
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.target", r.URL.Path)
+		span.SetAttr("request.id", RequestIDFromContext(ctx))
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Stop exports the spans that are still queued, waiting at most timeout
+func Stop(timeout time.Duration) {
+	if activeTracer == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case activeTracer.flush <- done:
+		select {
+		case <-done:
+		case <-time.After(timeout):
+		}
+	case <-time.After(timeout):
+	}
+}
+
+// run exports queued spans in batches
+func (t *tracer) run() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	var batch []*Span
+	exportBatch := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			fmt.Printf("[TRACE] Failed to export %d spans: %v\n", len(batch), err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span := <-t.spans:
+			batch = append(batch, span)
+			if len(batch) >= 256 {
+				exportBatch()
+			}
+		case <-ticker.C:
+			exportBatch()
+		case done := <-t.flush:
+			for len(t.spans) > 0 {
+				batch = append(batch, <-t.spans)
+			}
+			exportBatch()
+			close(done)
+		}
+	}
+}
+
+// otlpAttributes converts span attributes into OTLP key/value pairs
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for key, value := range attrs {
+		var v map[string]interface{}
+		switch value := value.(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		out = append(out, map[string]interface{}{"key": key, "value": v})
+	}
+	return out
+}
+
+// export posts spans to the collector using the OTLP/HTTP JSON encoding
+func (t *tracer) export(batch []*Span) error {
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, s := range batch {
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.ctx.TraceID[:]),
+			"spanId":            hex.EncodeToString(s.ctx.SpanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parent != ([8]byte{}) {
+			span["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		if s.errMsg != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.errMsg}
+		}
+		if len(s.links) > 0 {
+			var links []map[string]string
+			for _, link := range s.links {
+				links = append(links, map[string]string{
+					"traceId": hex.EncodeToString(link.TraceID[:]),
+					"spanId":  hex.EncodeToString(link.SpanID[:]),
+				})
+			}
+			span["links"] = links
+		}
+		spans = append(spans, span)
+	}
+
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": t.config.ServiceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "whatsapp-bridge"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(t.config.Endpoint, "/")+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Package version describes the build of the bridge. Release builds set the variables with
+//
+//	go build -ldflags "-X whatsapp-client/internal/version.Version=v1.2.0 -X whatsapp-client/internal/version.Commit=$(git rev-parse HEAD) -X whatsapp-client/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without them fall back to the commit and time Go records from the git checkout.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags -X
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info is the build of the bridge, as reported by -version, /api/status and published events
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information, filling in what ldflags didn't set from the VCS stamp of the binary
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	modified := false
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified && Commit == "" && info.Commit != "" {
+		info.Commit += "-dirty"
+	}
+	return info
+}
+
+// String formats the build information for -version
+func (i Info) String() string {
+	s := "whatsapp-bridge " + i.Version
+	if i.Commit != "" {
+		s += " (" + i.Commit
+		if i.BuildDate != "" {
+			s += ", " + i.BuildDate
+		}
+		s += ")"
+	}
+	return s + " " + i.GoVersion
+}
@@ -155,16 +155,18 @@ def send_message(
     message: str, 
     media_url: Optional[str] = None, 
     media_type: Optional[str] = None, 
-    caption: Optional[str] = None
+    caption: Optional[str] = None,
+    mentions: Optional[List[str]] = None
 ) -> Dict[str, Any]:
     """Send a WhatsApp message to the specified phone number.
     
     Args:
         phone_number: The recipient's phone number, with country code but no + or other symbols
         message: The message text to send
-        media_url: Optional URL or file path to the media to send
-        media_type: Optional type of media being sent (e.g. "image", "video", "document")
+        media_url: Optional path of a file in the bridge's media directory to send
+        media_type: Optional type of media being sent: "image" or "video"
         caption: Optional caption for the media
+        mentions: Optional phone numbers to @-mention; include "@<number>" in the message text for each
     
     Returns:
         A dictionary containing success status and a status message
@@ -174,7 +176,8 @@ def send_message(
         message=message,
         media_url=media_url,
         media_type=media_type,
-        caption=caption
+        caption=caption,
+        mentions=mentions
     )
     return {
         "success": success,
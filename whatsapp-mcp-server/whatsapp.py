@@ -6,8 +6,11 @@ import os.path
 import requests
 import json
 
-MESSAGES_DB_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', 'whatsapp-bridge', 'store', 'messages.db')
-WHATSAPP_API_BASE_URL = "http://localhost:8080/api"
+# Follow the bridge's JMK_DATA_DIR and JMK_API_PORT overrides when they are set
+MESSAGES_DB_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', 'whatsapp-bridge', os.environ.get("JMK_DATA_DIR", "store"), 'messages.db')
+WHATSAPP_API_BASE_URL = f"http://localhost:{os.environ.get('JMK_API_PORT', '8080')}/api"
+# API key for the bridge, needed when api_keys are configured there
+WHATSAPP_API_KEY = os.environ.get("WHATSAPP_API_KEY", "")
 
 @dataclass
 class Message:
@@ -139,7 +142,7 @@ def print_recent_messages(limit=10) -> List[Message]:
             m.timestamp,
             m.sender,
             c.name,
-            m.content,
+            COALESCE(NULLIF(m.content, ''), m.caption, ''),
             m.is_from_me,
             c.jid,
             m.id
@@ -203,7 +206,7 @@ def list_messages(
         cursor = conn.cursor()
         
         # Build base query
-        query_parts = ["SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id FROM messages"]
+        query_parts = ["SELECT messages.timestamp, messages.sender, chats.name, COALESCE(NULLIF(messages.content, ''), messages.caption, ''), messages.is_from_me, chats.jid, messages.id FROM messages"]
         query_parts.append("JOIN chats ON messages.chat_jid = chats.jid")
         where_clauses = []
         params = []
@@ -222,7 +225,7 @@ def list_messages(
             params.append(chat_jid)
             
         if query:
-            where_clauses.append("LOWER(messages.content) LIKE LOWER(?)")
+            where_clauses.append("LOWER(COALESCE(NULLIF(messages.content, ''), messages.caption, '')) LIKE LOWER(?)")
             params.append(f"%{query}%")
             
         if where_clauses:
@@ -282,7 +285,7 @@ def get_message_context(
         
         # Get the target message first
         cursor.execute("""
-            SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id, messages.chat_jid
+            SELECT messages.timestamp, messages.sender, chats.name, COALESCE(NULLIF(messages.content, ''), messages.caption, ''), messages.is_from_me, chats.jid, messages.id, messages.chat_jid
             FROM messages
             JOIN chats ON messages.chat_jid = chats.jid
             WHERE messages.id = ?
@@ -304,7 +307,7 @@ def get_message_context(
         
         # Get messages before
         cursor.execute("""
-            SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id
+            SELECT messages.timestamp, messages.sender, chats.name, COALESCE(NULLIF(messages.content, ''), messages.caption, ''), messages.is_from_me, chats.jid, messages.id
             FROM messages
             JOIN chats ON messages.chat_jid = chats.jid
             WHERE messages.chat_jid = ? AND messages.timestamp < ?
@@ -326,7 +329,7 @@ def get_message_context(
         
         # Get messages after
         cursor.execute("""
-            SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id
+            SELECT messages.timestamp, messages.sender, chats.name, COALESCE(NULLIF(messages.content, ''), messages.caption, ''), messages.is_from_me, chats.jid, messages.id
             FROM messages
             JOIN chats ON messages.chat_jid = chats.jid
             WHERE messages.chat_jid = ? AND messages.timestamp > ?
@@ -378,7 +381,7 @@ def list_chats(
                 chats.jid,
                 chats.name,
                 chats.last_message_time,
-                messages.content as last_message,
+                COALESCE(NULLIF(messages.content, ''), messages.caption, '') as last_message,
                 messages.sender as last_sender,
                 messages.is_from_me as last_is_from_me
             FROM chats
@@ -493,7 +496,7 @@ def get_contact_chats(jid: str, limit: int = 20, page: int = 0) -> List[Chat]:
                 c.jid,
                 c.name,
                 c.last_message_time,
-                m.content as last_message,
+                COALESCE(NULLIF(m.content, ''), m.caption, '') as last_message,
                 m.sender as last_sender,
                 m.is_from_me as last_is_from_me
             FROM chats c
@@ -538,7 +541,7 @@ def get_last_interaction(jid: str) -> Optional[Message]:
                 m.timestamp,
                 m.sender,
                 c.name,
-                m.content,
+                COALESCE(NULLIF(m.content, ''), m.caption, ''),
                 m.is_from_me,
                 c.jid,
                 m.id
@@ -583,7 +586,7 @@ def get_chat(chat_jid: str, include_last_message: bool = True) -> Optional[Chat]
                 c.jid,
                 c.name,
                 c.last_message_time,
-                m.content as last_message,
+                COALESCE(NULLIF(m.content, ''), m.caption, '') as last_message,
                 m.sender as last_sender,
                 m.is_from_me as last_is_from_me
             FROM chats c
@@ -631,7 +634,7 @@ def get_direct_chat_by_contact(sender_phone_number: str) -> Optional[Chat]:
                 c.jid,
                 c.name,
                 c.last_message_time,
-                m.content as last_message,
+                COALESCE(NULLIF(m.content, ''), m.caption, '') as last_message,
                 m.sender as last_sender,
                 m.is_from_me as last_is_from_me
             FROM chats c
@@ -662,15 +665,16 @@ def get_direct_chat_by_contact(sender_phone_number: str) -> Optional[Chat]:
         if 'conn' in locals():
             conn.close()
 
-def send_message(phone_number: str, message: str, media_url: Optional[str] = None, media_type: Optional[str] = None, caption: Optional[str] = None) -> Tuple[bool, str]:
+def send_message(phone_number: str, message: str, media_url: Optional[str] = None, media_type: Optional[str] = None, caption: Optional[str] = None, mentions: Optional[List[str]] = None) -> Tuple[bool, str]:
     """Send a WhatsApp message to the specified phone number.
     
     Args:
         phone_number (str): The recipient's phone number, with country code but no + or other symbols
         message (str): The message text to send
-        media_url (str, optional): URL or file path to the media to send
-        media_type (str, optional): Type of media being sent (e.g. "image", "video", "document")
+        media_url (str, optional): Path of a file in the bridge's media directory to send
+        media_type (str, optional): Type of media being sent: "image" or "video"
         caption (str, optional): Caption for the media
+        mentions (List[str], optional): Phone numbers or JIDs to @-mention
         
     Returns:
         Tuple[bool, str]: A tuple containing success status and a status message
@@ -689,15 +693,20 @@ def send_message(phone_number: str, message: str, media_url: Optional[str] = Non
             payload["media_type"] = media_type
         if caption:
             payload["caption"] = caption
+        if mentions:
+            payload["mentions"] = mentions
         
-        response = requests.post(url, json=payload)
+        headers = {"X-API-Key": WHATSAPP_API_KEY} if WHATSAPP_API_KEY else {}
+        response = requests.post(url, json=payload, headers=headers)
         
         # Check if the request was successful
         if response.status_code == 200:
             result = response.json()
             return result.get("success", False), result.get("message", "Unknown response")
         else:
-            return False, f"Error: HTTP {response.status_code} - {response.text}"
+            # Failures come as {"error": {"code": ..., "message": ...}}
+            error = response.json().get("error", {})
+            return False, f"Error: {error.get('code', response.status_code)} - {error.get('message', response.text)}"
             
     except requests.RequestException as e:
         return False, f"Request error: {str(e)}"